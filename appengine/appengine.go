@@ -126,7 +126,7 @@ func init() {
 	if err != nil {
 		clog.Fatalf("Error opening database: %v", err)
 	}
-	if err := http.SetupRoutes(handle, cfg); err != nil {
+	if _, err := http.SetupRoutes(handle, cfg); err != nil {
 		clog.Fatalf("Error setting up routes: %v", err)
 	}
 }
@@ -1,13 +1,19 @@
 package glog
 
 import (
+	"flag"
 	"fmt"
+	"strconv"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/golang/glog"
 )
 
+// verbosity is the glog flag; it's kept, since applications may replace the default flag set
+var verbosity *flag.Flag
+
 func init() {
+	verbosity = flag.Lookup("v")
 	clog.SetLogger(Logger{})
 }
 
@@ -31,5 +37,9 @@ func (Logger) V(level int) bool {
 }
 
 func (Logger) SetV(v int) {
-	glog.Warningf("changing log level is not supported; run command with '-v %d' flag", v)
+	if verbosity == nil {
+		glog.Warningf("changing log level is not supported; run command with '-v %d' flag", v)
+		return
+	}
+	verbosity.Value.Set(strconv.Itoa(v))
 }
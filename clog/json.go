@@ -0,0 +1,105 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ StructuredLogger = (*JSONLogger)(nil)
+
+// JSONLogger writes each message as a JSON object on a separate line:
+//
+//	{"time":"2019-06-01T10:00:00Z","level":"info","module":"http","msg":"request","status":200}
+//
+// Fields follow the message in order. Fields that have the same name as one of the standard keys are
+// prefixed with "field.".
+type JSONLogger struct {
+	mu        sync.Mutex
+	w         io.Writer
+	verbosity int32
+}
+
+// NewJSONLogger creates a logger that writes JSON lines to w.
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{w: w}
+}
+
+func (l *JSONLogger) Infof(format string, args ...interface{}) {
+	l.Log(Entry{Time: time.Now(), Level: Info, Message: fmt.Sprintf(format, args...)})
+}
+func (l *JSONLogger) Warningf(format string, args ...interface{}) {
+	l.Log(Entry{Time: time.Now(), Level: Warning, Message: fmt.Sprintf(format, args...)})
+}
+func (l *JSONLogger) Errorf(format string, args ...interface{}) {
+	l.Log(Entry{Time: time.Now(), Level: Error, Message: fmt.Sprintf(format, args...)})
+}
+func (l *JSONLogger) Fatalf(format string, args ...interface{}) {
+	l.Log(Entry{Time: time.Now(), Level: Fatal, Message: fmt.Sprintf(format, args...)})
+	os.Exit(1)
+}
+func (l *JSONLogger) V(level int) bool { return int(atomic.LoadInt32(&l.verbosity)) >= level }
+func (l *JSONLogger) SetV(level int)   { atomic.StoreInt32(&l.verbosity, int32(level)) }
+
+// Log writes a single entry.
+func (l *JSONLogger) Log(e Entry) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, e.Time.UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, e.Level.String())
+	if e.Module != "" {
+		buf.WriteString(`,"module":`)
+		writeJSON(&buf, e.Module)
+	}
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, e.Message)
+	for _, f := range e.Fields {
+		key := f.Key
+		switch key {
+		case "time", "level", "module", "msg":
+			key = "field." + key
+		}
+		buf.WriteByte(',')
+		writeJSON(&buf, key)
+		buf.WriteByte(':')
+		writeJSON(&buf, f.Value)
+	}
+	buf.WriteString("}\n")
+	l.mu.Lock()
+	l.w.Write(buf.Bytes())
+	l.mu.Unlock()
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case error:
+		v = x.Error()
+	case time.Duration:
+		v = x.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(data)
+}
@@ -0,0 +1,64 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogger(t *testing.T) {
+	old := logger
+	defer func() {
+		SetLogger(old)
+		SetModuleLevels(nil)
+	}()
+	var buf bytes.Buffer
+	SetLogger(NewJSONLogger(&buf))
+
+	m := Module("kv").With(F("index", "spo"))
+	m.Info("building index", F("entries", 3), F("msg", "dup"))
+	m.Warning("failed", F("error", errors.New("boom")))
+	Infof("plain %d", 1)
+
+	SetModuleLevels(map[string]int{"kv": -1})
+	m.Info("hidden")
+	m.Error("shown")
+	if m.V(0) {
+		t.Error("verbose logs of the module must be disabled")
+	}
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		delete(e, "time")
+		entries = append(entries, e)
+	}
+	expect := []map[string]interface{}{
+		{"level": "info", "module": "kv", "msg": "building index", "index": "spo", "entries": 3.0, "field.msg": "dup"},
+		{"level": "warning", "module": "kv", "msg": "failed", "index": "spo", "error": "boom"},
+		{"level": "info", "msg": "plain 1"},
+		{"level": "error", "module": "kv", "msg": "shown", "index": "spo"},
+	}
+	if len(entries) != len(expect) {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	for i, e := range expect {
+		got, _ := json.Marshal(entries[i])
+		exp, _ := json.Marshal(e)
+		if !bytes.Equal(got, exp) {
+			t.Errorf("entry %d: expected %s, got %s", i, exp, got)
+		}
+	}
+}
+
+func TestEntryString(t *testing.T) {
+	e := Entry{Module: "http", Message: "completed", Fields: []Field{F("status", 200), F("path", "/")}}
+	if s := e.String(); s != "http: completed status=200 path=/" {
+		t.Errorf("unexpected text: %q", s)
+	}
+}
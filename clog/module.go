@@ -0,0 +1,204 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clog
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Level is a severity of a log message.
+type Level int
+
+const (
+	Info Level = iota
+	Warning
+	Error
+	Fatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	case Fatal:
+		return "fatal"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Field is a named value attached to a log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a field with a given key and value.
+func F(key string, val interface{}) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Entry is a structured log message.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Module  string
+	Message string
+	Fields  []Field
+}
+
+// String formats the entry as a single line of text, with fields after the message.
+func (e Entry) String() string {
+	var buf bytes.Buffer
+	if e.Module != "" {
+		buf.WriteString(e.Module)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(e.Message)
+	for _, f := range e.Fields {
+		fmt.Fprintf(&buf, " %s=%v", f.Key, f.Value)
+	}
+	return buf.String()
+}
+
+// StructuredLogger is an optional interface for loggers that keep the module and fields of messages
+// separate from the text. Other loggers receive messages formatted by Entry.String.
+type StructuredLogger interface {
+	Logger
+	Log(e Entry)
+}
+
+var modules struct {
+	sync.RWMutex
+	levels map[string]int
+}
+
+// SetModuleLevels replaces verbosity levels of modules. Modules that are not listed use the global level.
+//
+// A negative level also hides info messages of the module; warnings and errors are always logged.
+func SetModuleLevels(levels map[string]int) {
+	m := make(map[string]int, len(levels))
+	for k, v := range levels {
+		m[k] = v
+	}
+	modules.Lock()
+	modules.levels = m
+	modules.Unlock()
+}
+
+// ModuleLevels returns verbosity levels set for modules.
+func ModuleLevels() map[string]int {
+	modules.RLock()
+	defer modules.RUnlock()
+	m := make(map[string]int, len(modules.levels))
+	for k, v := range modules.levels {
+		m[k] = v
+	}
+	return m
+}
+
+func moduleLevel(name string) (int, bool) {
+	modules.RLock()
+	v, ok := modules.levels[name]
+	modules.RUnlock()
+	return v, ok
+}
+
+// ModuleLogger logs messages of a single module, such as "kv" or "http", with a set of fields.
+type ModuleLogger struct {
+	name   string
+	fields []Field
+}
+
+// Module returns a logger for a given module.
+func Module(name string) *ModuleLogger {
+	return &ModuleLogger{name: name}
+}
+
+// With returns a logger that adds given fields to all messages.
+func (m *ModuleLogger) With(fields ...Field) *ModuleLogger {
+	out := make([]Field, 0, len(m.fields)+len(fields))
+	out = append(out, m.fields...)
+	out = append(out, fields...)
+	return &ModuleLogger{name: m.name, fields: out}
+}
+
+// V returns whether the verbosity of the module is above the specified level.
+func (m *ModuleLogger) V(level int) bool {
+	if v, ok := moduleLevel(m.name); ok {
+		return v >= level
+	}
+	return V(level)
+}
+
+// Info logs a message with fields at the info level.
+func (m *ModuleLogger) Info(msg string, fields ...Field) { m.log(Info, msg, fields) }
+
+// Warning logs a message with fields at the warning level.
+func (m *ModuleLogger) Warning(msg string, fields ...Field) { m.log(Warning, msg, fields) }
+
+// Error logs a message with fields at the error level.
+func (m *ModuleLogger) Error(msg string, fields ...Field) { m.log(Error, msg, fields) }
+
+// Infof logs a formatted message at the info level.
+func (m *ModuleLogger) Infof(format string, args ...interface{}) {
+	m.log(Info, fmt.Sprintf(format, args...), nil)
+}
+
+// Warningf logs a formatted message at the warning level.
+func (m *ModuleLogger) Warningf(format string, args ...interface{}) {
+	m.log(Warning, fmt.Sprintf(format, args...), nil)
+}
+
+// Errorf logs a formatted message at the error level.
+func (m *ModuleLogger) Errorf(format string, args ...interface{}) {
+	m.log(Error, fmt.Sprintf(format, args...), nil)
+}
+
+func (m *ModuleLogger) log(level Level, msg string, fields []Field) {
+	l := logger
+	if l == nil {
+		return
+	}
+	if level == Info {
+		if v, ok := moduleLevel(m.name); ok && v < 0 {
+			return
+		}
+	}
+	e := Entry{Time: time.Now(), Level: level, Module: m.name, Message: msg, Fields: fields}
+	if len(m.fields) != 0 {
+		e.Fields = append(append([]Field{}, m.fields...), fields...)
+	}
+	if sl, ok := l.(StructuredLogger); ok {
+		sl.Log(e)
+		return
+	}
+	switch level {
+	case Warning:
+		l.Warningf("%s", e)
+	case Error:
+		l.Errorf("%s", e)
+	case Fatal:
+		l.Fatalf("%s", e)
+	default:
+		l.Infof("%s", e)
+	}
+}
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"expvar"
 	"flag"
 	"fmt"
 	"os"
@@ -38,6 +39,7 @@ import (
 
 	// Load all supported quad formats.
 	_ "github.com/cayleygraph/cayley/quad/dot"
+	_ "github.com/cayleygraph/cayley/quad/geojson"
 	_ "github.com/cayleygraph/cayley/quad/gml"
 	_ "github.com/cayleygraph/cayley/quad/graphml"
 	_ "github.com/cayleygraph/cayley/quad/json"
@@ -60,7 +62,6 @@ var (
 		Use:   "cayley",
 		Short: "Cayley is a graph store and graph query layer.",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			clog.Infof("Cayley version: %s (%s)", version.Version, version.GitHash)
 			if conf, _ := cmd.Flags().GetString("config"); conf != "" {
 				viper.SetConfigFile(conf)
 			}
@@ -68,6 +69,12 @@ var (
 			if _, ok := err.(viper.ConfigFileNotFoundError); !ok && err != nil {
 				return err
 			}
+			// the format must be set before the first message
+			if err = command.SetupLogFormat(cmd); err != nil {
+				return err
+			}
+			command.ApplyLogConfig()
+			clog.Infof("Cayley version: %s (%s)", version.Version, version.GitHash)
 			if conf := viper.ConfigFileUsed(); conf != "" {
 				wd, _ := os.Getwd()
 				if rel, _ := filepath.Rel(wd, conf); rel != "" && strings.Count(rel, "..") < 3 {
@@ -79,6 +86,11 @@ var (
 			graph.IgnoreDuplicates = viper.GetBool("load.ignore_duplicates")
 			graph.IgnoreMissing = viper.GetBool("load.ignore_missing")
 			quad.DefaultBatch = viper.GetInt("load.batch")
+			if graph.TrackEstimates = viper.GetBool("query.track_estimates"); graph.TrackEstimates {
+				expvar.Publish("estimates", expvar.Func(func() interface{} {
+					return graph.Estimates()
+				}))
+			}
 			return nil
 		},
 	}
@@ -125,6 +137,24 @@ func init() {
 		command.NewHttpCmd(),
 		command.NewConvertCmd(),
 		command.NewDedupCommand(),
+		command.NewDedupeCmd(),
+		command.NewReindexCmd(),
+		command.NewFsckCmd(),
+		command.NewStatsCmd(),
+		command.NewAnalyzeCmd(),
+		command.NewBenchCmd(),
+		command.NewWALCmd(),
+		command.NewAuditCmd(),
+		command.NewBackupCmd(),
+		command.NewRestoreCmd(),
+		command.NewDiffCmd(),
+		command.NewSyncCmd(),
+		command.NewMigrateCmd(),
+		command.NewCopyCmd(),
+		command.NewCompactCmd(),
+		command.NewAlgoCmd(),
+		command.NewValidateCmd(),
+		command.NewGenSchemaCmd(),
 	)
 	rootCmd.PersistentFlags().StringP("config", "c", "", "path to an explicit configuration file")
 
@@ -132,11 +162,14 @@ func init() {
 	rootCmd.PersistentFlags().StringP("db", "d", "memstore", "database backend to use: "+strings.Join(qnames, ", "))
 	rootCmd.PersistentFlags().StringP("dbpath", "a", "", "path or address string for database")
 	rootCmd.PersistentFlags().Bool("read_only", false, "open database in read-only mode")
+	rootCmd.PersistentFlags().String("wal", "", "path to the write-ahead log file; enables the log if set")
 
 	rootCmd.PersistentFlags().Bool("dup", true, "don't stop loading on duplicated on add")
 	rootCmd.PersistentFlags().Bool("missing", false, "don't stop loading on missing key on delete")
 	rootCmd.PersistentFlags().Int("batch", quad.DefaultBatch, "size of quads batch to load at once")
 
+	rootCmd.PersistentFlags().String("log_format", "text", `format of log messages ("text" or "json")`)
+
 	rootCmd.PersistentFlags().String("memprofile", "", "path to output memory profile")
 	rootCmd.PersistentFlags().String("cpuprofile", "", "path to output cpu profile")
 
@@ -144,9 +177,11 @@ func init() {
 	viper.BindPFlag(command.KeyBackend, rootCmd.PersistentFlags().Lookup("db"))
 	viper.BindPFlag(command.KeyAddress, rootCmd.PersistentFlags().Lookup("dbpath"))
 	viper.BindPFlag(command.KeyReadOnly, rootCmd.PersistentFlags().Lookup("read_only"))
+	viper.BindPFlag(command.KeyWAL, rootCmd.PersistentFlags().Lookup("wal"))
 	viper.BindPFlag("load.ignore_duplicates", rootCmd.PersistentFlags().Lookup("dup"))
 	viper.BindPFlag("load.ignore_missing", rootCmd.PersistentFlags().Lookup("missing"))
 	viper.BindPFlag(command.KeyLoadBatch, rootCmd.PersistentFlags().Lookup("batch"))
+	viper.BindPFlag(command.KeyLogFormat, rootCmd.PersistentFlags().Lookup("log_format"))
 
 	// make both store.path and store.address work
 	viper.RegisterAlias(command.KeyPath, command.KeyAddress)
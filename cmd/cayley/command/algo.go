@@ -0,0 +1,262 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/algo"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func NewAlgoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "algo",
+		Short: "Run graph algorithms over the database.",
+		Long: "Run graph algorithms over all nodes of the database.\n" +
+			"Links between nodes are loaded into memory first; quads with literal objects are ignored.",
+	}
+	cmd.AddCommand(
+		newPageRankCmd(),
+		newComponentsCmd(),
+		newCentralityCmd("degree", "Compute the number of neighbors of each node."),
+		newCentralityCmd("closeness", "Compute closeness centrality of each node."),
+		newCentralityCmd("betweenness", "Compute betweenness centrality of each node, optionally from a sample of nodes."),
+		newTrianglesCmd(),
+		newClusteringCmd(),
+	)
+	return cmd
+}
+
+// addAlgoFlags adds flags shared by all algorithms.
+func addAlgoFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("predicate", nil, "predicates of quads to load as edges (default: all)")
+	cmd.Flags().Int("top", 10, "number of nodes with the largest values to print (-1 for all)")
+	cmd.Flags().String("write", "", "predicate to save values to the database with, replacing existing quads with it")
+}
+
+// algoResult is a value computed by an algorithm for each node.
+type algoResult struct {
+	vals []float64
+	// value converts values to quads for --write.
+	value func(float64) quad.Value
+	// print writes a summary of the result; nodes with the largest values are printed if it's not set.
+	print func(w io.Writer, g *algo.Graph, top int)
+}
+
+func floatValue(v float64) quad.Value { return quad.Float(v) }
+
+func intValue(v float64) quad.Value { return quad.Int(int64(v)) }
+
+// runAlgo loads the graph from the database and runs an algorithm on it.
+// The result is printed and optionally saved.
+func runAlgo(cmd *cobra.Command, fn func(ctx context.Context, g *algo.Graph) (*algoResult, error)) error {
+	printBackendInfo()
+	h, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	var preds []quad.Value
+	names, _ := cmd.Flags().GetStringSlice("predicate")
+	for _, p := range names {
+		preds = append(preds, quad.StringToValue(p))
+	}
+	ctx := context.TODO()
+	g, err := algo.Load(ctx, h.QuadStore, preds...)
+	if err != nil {
+		return err
+	}
+	clog.Infof("loaded %d nodes and %d edges", g.Len(), g.Edges())
+	res, err := fn(ctx, g)
+	if err != nil {
+		return err
+	}
+	top, _ := cmd.Flags().GetInt("top")
+	w := bufio.NewWriter(os.Stdout)
+	if res.print != nil {
+		res.print(w, g, top)
+	} else {
+		for _, i := range algo.Top(res.vals, top) {
+			fmt.Fprintf(w, "%s\t%v\n", g.NameOf(i), res.value(res.vals[i]).Native())
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if pred, _ := cmd.Flags().GetString("write"); pred != "" {
+		n, err := g.Save(quad.StringToValue(pred), func(i int) quad.Value {
+			return res.value(res.vals[i])
+		})
+		if err != nil {
+			return err
+		}
+		clog.Infof("saved values of %d nodes", n)
+	}
+	return nil
+}
+
+func newPageRankCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pagerank",
+		Short: "Compute PageRank scores of nodes.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var opts algo.PageRankOptions
+			opts.Damping, _ = cmd.Flags().GetFloat64("damping")
+			opts.Iterations, _ = cmd.Flags().GetInt("iterations")
+			opts.Tolerance, _ = cmd.Flags().GetFloat64("tolerance")
+			return runAlgo(cmd, func(ctx context.Context, g *algo.Graph) (*algoResult, error) {
+				ranks, iter, err := g.PageRank(ctx, opts)
+				if err != nil {
+					return nil, err
+				}
+				clog.Infof("computed scores in %d iterations", iter)
+				return &algoResult{vals: ranks, value: floatValue}, nil
+			})
+		},
+	}
+	addAlgoFlags(cmd)
+	cmd.Flags().Float64("damping", algo.DefaultDamping, "probability of following a link instead of jumping to a random node")
+	cmd.Flags().Int("iterations", algo.DefaultIterations, "maximal number of iterations")
+	cmd.Flags().Float64("tolerance", algo.DefaultTolerance, "stop once the total change of scores gets below this value")
+	return cmd
+}
+
+func newComponentsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "components",
+		Short: "Find connected components and label each node with the component id.",
+		Long: "Find weakly connected components (nodes linked in any direction), or strongly connected " +
+			"components (nodes reachable from each other) with --strong.\n" +
+			"The largest components are printed with their size and one of the nodes.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			strong, _ := cmd.Flags().GetBool("strong")
+			return runAlgo(cmd, func(ctx context.Context, g *algo.Graph) (*algoResult, error) {
+				var (
+					c   *algo.Components
+					err error
+				)
+				if strong {
+					c, err = g.StrongComponents(ctx)
+				} else {
+					c, err = g.WeakComponents(ctx)
+				}
+				if err != nil {
+					return nil, err
+				}
+				clog.Infof("found %d components", c.Len())
+				vals := make([]float64, len(c.ID))
+				for i, id := range c.ID {
+					vals[i] = float64(id)
+				}
+				return &algoResult{vals: vals, value: intValue, print: func(w io.Writer, g *algo.Graph, top int) {
+					sizes := make([]float64, c.Len())
+					first := make([]int, c.Len())
+					for i := len(c.ID) - 1; i >= 0; i-- {
+						first[c.ID[i]] = i
+					}
+					for id, n := range c.Sizes {
+						sizes[id] = float64(n)
+					}
+					for _, id := range algo.Top(sizes, top) {
+						fmt.Fprintf(w, "%d\t%d\t%s\n", id, c.Sizes[id], g.NameOf(first[id]))
+					}
+				}}, nil
+			})
+		},
+	}
+	addAlgoFlags(cmd)
+	cmd.Flags().Bool("strong", false, "find strongly connected components")
+	return cmd
+}
+
+func newCentralityCmd(name, short string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				opts algo.CentralityOptions
+				err  error
+			)
+			dir, _ := cmd.Flags().GetString("direction")
+			if opts.Direction, err = algo.ParseDirection(dir); err != nil {
+				return err
+			}
+			opts.Workers, _ = cmd.Flags().GetInt("workers")
+			if name == "betweenness" {
+				opts.Samples, _ = cmd.Flags().GetInt("samples")
+				opts.Seed, _ = cmd.Flags().GetInt64("seed")
+			}
+			return runAlgo(cmd, func(ctx context.Context, g *algo.Graph) (*algoResult, error) {
+				switch name {
+				case "degree":
+					return &algoResult{vals: g.Degree(opts.Direction), value: intValue}, nil
+				case "closeness":
+					vals, err := g.Closeness(ctx, opts)
+					return &algoResult{vals: vals, value: floatValue}, err
+				default:
+					vals, err := g.Betweenness(ctx, opts)
+					return &algoResult{vals: vals, value: floatValue}, err
+				}
+			})
+		},
+	}
+	addAlgoFlags(cmd)
+	cmd.Flags().String("direction", "both", "direction of edges to follow: both, out or in")
+	if name != "degree" {
+		cmd.Flags().Int("workers", 0, "number of goroutines to use (default: number of CPUs)")
+	}
+	if name == "betweenness" {
+		cmd.Flags().Int("samples", 0, "estimate values from paths that start at a given number of random nodes (default: all nodes)")
+		cmd.Flags().Int64("seed", 0, "random seed for choosing the samples")
+	}
+	return cmd
+}
+
+func newTrianglesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "triangles",
+		Short: "Count triangles each node is a part of.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAlgo(cmd, func(ctx context.Context, g *algo.Graph) (*algoResult, error) {
+				cnt, total, err := g.Triangles(ctx)
+				if err != nil {
+					return nil, err
+				}
+				clog.Infof("found %d triangles", total)
+				vals := make([]float64, len(cnt))
+				for i, n := range cnt {
+					vals[i] = float64(n)
+				}
+				return &algoResult{vals: vals, value: intValue}, nil
+			})
+		},
+	}
+	addAlgoFlags(cmd)
+	return cmd
+}
+
+func newClusteringCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clustering",
+		Short: "Compute the local clustering coefficient of each node.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAlgo(cmd, func(ctx context.Context, g *algo.Graph) (*algoResult, error) {
+				cc, avg, err := g.Clustering(ctx)
+				if err != nil {
+					return nil, err
+				}
+				clog.Infof("average clustering coefficient: %g", avg)
+				return &algoResult{vals: cc, value: floatValue}, nil
+			})
+		},
+	}
+	addAlgoFlags(cmd)
+	return cmd
+}
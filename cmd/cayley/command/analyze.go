@@ -0,0 +1,89 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/stats"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	KeyAutoAnalyze         = "store.auto_analyze_percent"
+	KeyAutoAnalyzeInterval = "store.auto_analyze_interval"
+)
+
+func NewAnalyzeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Recompute statistics of the database used by the query optimizer.",
+		Long: "Scan all quads and save the number of distinct values and a histogram of their degrees in each direction,\n" +
+			"as well as the number of quads of the most frequent predicates. The optimizer uses them to estimate sizes of queries.\n" +
+			"Statistics are not updated by writes; set store.auto_analyze_percent to refresh them on a running server.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			top, _ := cmd.Flags().GetInt("top")
+			ctx, cancel := getContext()
+			defer cancel()
+			printBackendInfo()
+			qs, err := graph.NewQuadStore(viper.GetString(KeyBackend), viper.GetString(KeyAddress), graph.Options(viper.GetStringMap(KeyOptions)))
+			if err != nil {
+				return err
+			}
+			defer qs.Close()
+			s, ok := qs.(graph.StatisticsStore)
+			if !ok {
+				return fmt.Errorf("backend %q does not keep statistics", viper.GetString(KeyBackend))
+			}
+			start := time.Now()
+			st, err := stats.Analyze(ctx, qs, top)
+			if err != nil {
+				return err
+			}
+			if err = s.SetStatistics(ctx, st); err != nil {
+				return err
+			}
+			clog.Infof("analyzed %d quads in %v", st.Quads, time.Since(start))
+			printStatistics(st)
+			return nil
+		},
+	}
+	cmd.Flags().Int("top", stats.DefaultTopPredicates, "number of the most frequent predicates to keep; 0 keeps all")
+	return cmd
+}
+
+func printStatistics(st *graph.Statistics) {
+	fmt.Printf("quads: %d\n\n", st.Quads)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "direction\tvalues\tfanout\t")
+	for _, d := range quad.Directions {
+		fmt.Fprintf(tw, "%v\t%d\t%d\t\n", d, st.Dir(d).Values, st.Fanout(d))
+	}
+	tw.Flush()
+}
+
+// startAnalyzer refreshes statistics of the quad store in the background, if it's enabled in the config.
+func startAnalyzer(ctx context.Context, qs graph.QuadStore) {
+	pct := viper.GetFloat64(KeyAutoAnalyze)
+	if pct <= 0 {
+		return
+	}
+	s, ok := qs.(graph.StatisticsStore)
+	if !ok {
+		clog.Warningf("backend %q does not keep statistics; automatic analysis is disabled", viper.GetString(KeyBackend))
+		return
+	}
+	interval := viper.GetDuration(KeyAutoAnalyzeInterval)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	clog.Infof("analyzing the database after %v%% of quads change", pct)
+	go stats.RunAnalyzer(ctx, qs, s, pct/100, interval)
+}
@@ -0,0 +1,166 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/audit"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	KeyAudit          = "audit.path"
+	KeyAuditRetention = "audit.retention"
+)
+
+// openAudit opens the audit log, if it's configured.
+func openAudit() (*audit.Log, error) {
+	path := viper.GetString(KeyAudit)
+	if path == "" {
+		return nil, nil
+	}
+	l, err := audit.Open(path, viper.GetDuration(KeyAuditRetention))
+	if err != nil {
+		return nil, err
+	}
+	clog.Infof("recording changes to audit log %q", path)
+	return l, nil
+}
+
+func auditPath() (string, error) {
+	path := viper.GetString(KeyAudit)
+	if path == "" {
+		return "", errors.New("audit log is not configured (" + KeyAudit + ")")
+	}
+	return path, nil
+}
+
+func parseTimeFlag(cmd *cobra.Command, name string) (time.Time, error) {
+	s, _ := cmd.Flags().GetString(name)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if dt, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-dt), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func NewAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Export and maintenance of the audit log.",
+	}
+	cmd.AddCommand(
+		newAuditExportCmd(),
+		newAuditHistoryCmd(),
+		newAuditPruneCmd(),
+	)
+	return cmd
+}
+
+func newAuditExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write audit log entries as JSON lines.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := auditPath()
+			if err != nil {
+				return err
+			}
+			since, err := parseTimeFlag(cmd, "since")
+			if err != nil {
+				return err
+			}
+			until, err := parseTimeFlag(cmd, "until")
+			if err != nil {
+				return err
+			}
+			out, _ := cmd.Flags().GetString("out")
+			w := os.Stdout
+			if out != "" && out != "-" {
+				w, err = os.Create(out)
+				if err != nil {
+					return err
+				}
+				defer w.Close()
+			}
+			return audit.Export(w, path, since, until)
+		},
+	}
+	cmd.Flags().StringP("out", "o", "", "output file (stdout by default)")
+	cmd.Flags().String("since", "", "export entries recorded since a given time (RFC 3339, or a duration before now)")
+	cmd.Flags().String("until", "", "export entries recorded before a given time (RFC 3339, or a duration before now)")
+	return cmd
+}
+
+func newAuditHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history <node>",
+		Short: "Write changes of a node recorded in the audit log as JSON lines.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := auditPath()
+			if err != nil {
+				return err
+			}
+			node := quad.StringToValue(args[0])
+			if node == nil {
+				return errors.New("node is empty")
+			}
+			since, err := parseTimeFlag(cmd, "since")
+			if err != nil {
+				return err
+			}
+			until, err := parseTimeFlag(cmd, "until")
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(os.Stdout)
+			return audit.History(path, node, since, until, func(e audit.Entry) error {
+				return enc.Encode(e)
+			})
+		},
+	}
+	cmd.Flags().String("since", "", "only show changes made since a given time (RFC 3339, or a duration before now)")
+	cmd.Flags().String("until", "", "only show changes made before a given time (RFC 3339, or a duration before now)")
+	return cmd
+}
+
+func newAuditPruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove old entries from the audit log.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := auditPath()
+			if err != nil {
+				return err
+			}
+			before, err := parseTimeFlag(cmd, "before")
+			if err != nil {
+				return err
+			}
+			if before.IsZero() {
+				ret := viper.GetDuration(KeyAuditRetention)
+				if ret <= 0 {
+					return errors.New("either --before or " + KeyAuditRetention + " must be set")
+				}
+				before = time.Now().Add(-ret)
+			}
+			l, err := audit.Open(path, 0)
+			if err != nil {
+				return err
+			}
+			defer l.Close()
+			return l.Prune(before)
+		},
+	}
+	cmd.Flags().String("before", "", "remove entries recorded before a given time (RFC 3339, or a duration before now)")
+	return cmd
+}
@@ -0,0 +1,137 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/internal/backup"
+)
+
+func NewBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup <dir>",
+		Short: "Write a consistent backup of the database to a new directory.",
+		Long: "Write a backup of the database to a new directory without stopping writes.\n" +
+			"Backends that support snapshots (bolt, leveldb, badger) produce a consistent backup pinned to a single horizon.\n" +
+			"Use --from to back up a database that is already opened by a running server.\n" +
+			"Use --incremental to only write changes made since a previous backup (KV backends only).",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("backup directory must be specified")
+			}
+			var base *backup.Meta
+			if dir, _ := cmd.Flags().GetString("incremental"); dir != "" {
+				m, err := backup.ReadMeta(dir)
+				if err != nil {
+					return err
+				}
+				base = m
+			}
+			if addr, _ := cmd.Flags().GetString("from"); addr != "" {
+				if base != nil {
+					return errors.New("incremental backups require direct access to the database")
+				}
+				m, err := backup.Download(args[0], addr, nil)
+				if err != nil {
+					return err
+				}
+				logBackup(m, args[0])
+				return nil
+			}
+			printBackendInfo()
+			// backup only reads the database
+			viper.Set(KeyCoalesceDelay, 0)
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+			var m *backup.Meta
+			if base != nil {
+				m, err = backup.CreateIncremental(args[0], baseStore(h.QuadStore), viper.GetString(KeyBackend), base)
+			} else {
+				m, err = backup.Create(args[0], baseStore(h.QuadStore), viper.GetString(KeyBackend))
+			}
+			if err != nil {
+				return err
+			}
+			logBackup(m, args[0])
+			return nil
+		},
+	}
+	cmd.Flags().String("from", "", "base URL of a running Cayley server to back up (instead of opening the database)")
+	cmd.Flags().String("incremental", "", "directory of a previous backup; only changes made after it are written")
+	return cmd
+}
+
+func NewRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <full-backup> [incremental-backup...]",
+		Short: "Restore the database from backups, optionally to a point in time.",
+		Long: "Load a full backup into an empty database and replay incremental backups on top of it, in order.\n" +
+			"Use --horizon or --time to stop after the last transaction committed at or before it.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("at least one backup directory must be specified")
+			}
+			var target backup.Target
+			target.Horizon, _ = cmd.Flags().GetInt64("horizon")
+			if s, _ := cmd.Flags().GetString("time"); s != "" {
+				t, err := time.Parse(time.RFC3339Nano, s)
+				if err != nil {
+					return fmt.Errorf("invalid time: %v", err)
+				}
+				target.Time = t
+			}
+			printBackendInfo()
+			if init, _ := cmd.Flags().GetBool("init"); init {
+				if err := initDatabase(); err != nil {
+					return err
+				}
+			}
+			// deltas must be applied one transaction at a time
+			viper.Set(KeyCoalesceDelay, 0)
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+			it := h.QuadStore.QuadsAllIterator()
+			nonEmpty := it.Next(context.TODO())
+			it.Close()
+			if nonEmpty {
+				return errors.New("database is not empty")
+			}
+			res, err := backup.Restore(h.QuadStore, args, target)
+			if err != nil {
+				return err
+			}
+			if res.Time.IsZero() {
+				clog.Infof("restored %d quads and %d deltas up to horizon %d", res.Quads, res.Deltas, res.Horizon)
+			} else {
+				clog.Infof("restored %d quads and %d deltas up to horizon %d (%v)", res.Quads, res.Deltas, res.Horizon, res.Time)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("init", false, "initialize the database before restoring")
+	cmd.Flags().Int64("horizon", 0, "restore transactions committed at or before this horizon of the original database")
+	cmd.Flags().String("time", "", "restore transactions committed at or before this time (RFC3339)")
+	return cmd
+}
+
+func logBackup(m *backup.Meta, dir string) {
+	if m.Incremental {
+		clog.Infof("backed up %d deltas from horizon %d to %d to %q", m.Deltas, m.Base, m.Horizon, dir)
+	} else if m.Consistent {
+		clog.Infof("backed up %d quads at horizon %d to %q", m.Quads, m.Horizon, dir)
+	} else {
+		clog.Infof("backed up %d quads to %q", m.Quads, dir)
+	}
+}
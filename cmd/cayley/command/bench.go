@@ -0,0 +1,153 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/internal/bench"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func NewBenchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Measure the performance of backends with a standard query mix.",
+		Long: "Load a dataset into a new database of each backend and run a mix of lookups, 2-hop joins,\n" +
+			"recursive traversals and writes, reporting the throughput and latency percentiles.\n" +
+			"A synthetic social graph is generated unless a quad file is given.\n" +
+			"Backends are given by name to use a temporary database, or as <backend>:<address>\n" +
+			"to use an existing empty database. Defaults to the backend from the config.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			backends, _ := cmd.Flags().GetStringSlice("backends")
+			if len(backends) == 0 {
+				backends = []string{viper.GetString(KeyBackend)}
+			}
+			file, _ := cmd.Flags().GetString(flagLoad)
+			typ, _ := cmd.Flags().GetString(flagLoadFormat)
+			people, _ := cmd.Flags().GetInt("people")
+			degree, _ := cmd.Flags().GetInt("degree")
+			sample, _ := cmd.Flags().GetInt("sample")
+			var opts bench.Options
+			opts.Duration, _ = cmd.Flags().GetDuration("duration")
+			opts.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+			opts.Seed, _ = cmd.Flags().GetInt64("seed")
+
+			var quads []quad.Quad
+			if file == "" {
+				quads = bench.Generate(people, degree, opts.Seed)
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintln(tw, "backend\tquery\tops\tops/s\tp50\tp90\tp99\tmax\terrors\t")
+			for _, spec := range backends {
+				res, err := benchBackend(ctx, spec, file, typ, quads, sample, opts)
+				if err != nil {
+					return fmt.Errorf("%s: %v", spec, err)
+				}
+				for _, r := range res {
+					fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%d\t\n", spec, r.Name, r.Ops, r.Throughput(),
+						latency(r.P50), latency(r.P90), latency(r.P99), latency(r.Max), r.Errors)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringSlice("backends", nil, "backends to compare (default: the backend from the config)")
+	cmd.Flags().StringP(flagLoad, "i", "", "quad file to use instead of a synthetic dataset")
+	cmd.Flags().String(flagLoadFormat, "", "format of the quad file instead of auto-detection")
+	cmd.Flags().Int("people", 10000, "number of people in the synthetic dataset")
+	cmd.Flags().Int("degree", 10, "number of people followed by each person in the synthetic dataset")
+	cmd.Flags().Int("sample", 1000, "number of edges to start queries from")
+	cmd.Flags().Duration("duration", bench.DefaultDuration, "duration of each query in the mix")
+	cmd.Flags().Int("concurrency", runtime.GOMAXPROCS(0), "number of workers that run read queries")
+	cmd.Flags().Int64("seed", 1, "seed of the synthetic dataset and query sequence")
+	return cmd
+}
+
+// latency formats a latency for the report. The load has no latencies.
+func latency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// benchBackend loads the dataset into a new database and runs the query mix on it.
+// The load is reported as the first result.
+func benchBackend(ctx context.Context, spec, file, typ string, quads []quad.Quad, sample int, opts bench.Options) ([]bench.Result, error) {
+	name, addr := spec, ""
+	if i := strings.Index(spec, ":"); i > 0 {
+		name, addr = spec[:i], spec[i+1:]
+	}
+	if !graph.IsRegistered(name) {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	if addr == "" && graph.IsPersistent(name) {
+		dir, err := ioutil.TempDir("", "cayley-bench-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		addr = dir
+	}
+	if err := graph.InitQuadStore(name, addr, nil); err != nil && err != graph.ErrDatabaseExists && err != graph.ErrOperationNotSupported {
+		return nil, err
+	}
+	qs, err := graph.NewQuadStore(name, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer qs.Close()
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{IgnoreDup: true})
+	if err != nil {
+		return nil, err
+	}
+	h := &graph.Handle{QuadStore: qs, QuadWriter: qw}
+
+	clog.Infof("%s: loading the dataset", spec)
+	start := time.Now()
+	n := len(quads)
+	if file != "" {
+		if err = internal.Load(qw, quad.DefaultBatch, file, typ); err != nil {
+			return nil, err
+		}
+		// the number of quads is only used for throughput
+		n = int(qs.Size())
+	} else {
+		for i := 0; i < len(quads); i += quad.DefaultBatch {
+			end := i + quad.DefaultBatch
+			if end > len(quads) {
+				end = len(quads)
+			}
+			if err = qw.AddQuadSet(quads[i:end]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	load := bench.Result{Name: "load", Ops: n, Elapsed: time.Since(start)}
+
+	w, err := bench.Sample(ctx, qs, sample)
+	if err != nil {
+		return nil, err
+	}
+	clog.Infof("%s: running the query mix", spec)
+	res, err := bench.Run(ctx, h, w, bench.Mix, opts)
+	if err != nil {
+		return nil, err
+	}
+	return append([]bench.Result{load}, res...), nil
+}
@@ -0,0 +1,39 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/kv/bolt"
+)
+
+func NewCompactCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact <dir>",
+		Short: "Write a compacted copy of a Bolt database for read-only serving.",
+		Long: "Write a copy of a Bolt database to a new directory with all pages filled, which makes it smaller and faster to read.\n" +
+			"The copy is meant to be served with the read_only store option, which memory-maps it without a write overhead.\n" +
+			"The database may be opened by other read-only processes while it runs, but not by writers.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("destination directory must be specified")
+			}
+			if name := viper.GetString(KeyBackend); name != bolt.Type {
+				return fmt.Errorf("compaction is only supported by the %s backend, got %q", bolt.Type, name)
+			}
+			printBackendInfo()
+			start := time.Now()
+			if err := bolt.Compact(args[0], viper.GetString(KeyAddress)); err != nil {
+				return err
+			}
+			clog.Infof("compacted database to %s in %v", args[0], time.Since(start))
+			return nil
+		},
+	}
+	return cmd
+}
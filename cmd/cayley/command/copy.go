@@ -0,0 +1,115 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/clone"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+// parseLabels parses label remappings in the <old>=<new> form. An empty old label stands for quads without a label.
+func parseLabels(list []string) (map[string]quad.Value, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]quad.Value, len(list))
+	for _, m := range list {
+		i := strings.LastIndex(m, "=")
+		if i < 0 || i == len(m)-1 {
+			return nil, fmt.Errorf("expected <old>=<new>, got %q", m)
+		}
+		key := ""
+		if old := m[:i]; old != "" {
+			key = quad.StringToValue(old).String()
+		}
+		out[key] = quad.StringToValue(m[i+1:])
+	}
+	return out, nil
+}
+
+func NewCopyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "copy --graph <label> --rename <label>",
+		Short: "Copy a named graph, or all quads, to another graph or database.",
+		Long: "Copy quads of a named graph (--graph), or all quads, to another graph of the same database,\n" +
+			"or to another database (--to), replacing (--rename) or remapping (--label) their labels.\n" +
+			"Databases are in the <backend>:<address> form, for example bolt:./data.\n" +
+			"Use the current database from the config with \"-\".",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			if to == "" {
+				to = from
+			}
+			init, _ := cmd.Flags().GetBool("init")
+			var opts clone.Options
+			if g, _ := cmd.Flags().GetString("graph"); g != "" {
+				opts.Graph = quad.StringToValue(g)
+			}
+			if l, _ := cmd.Flags().GetString("rename"); l != "" {
+				opts.Label = quad.StringToValue(l)
+			}
+			labels, _ := cmd.Flags().GetStringArray("label")
+			var err error
+			if opts.Labels, err = parseLabels(labels); err != nil {
+				return err
+			}
+			if from == to && opts.Label == nil && opts.Labels == nil {
+				return errors.New("copying to the same database requires a new label")
+			}
+			opts.Batch, _ = cmd.Flags().GetInt("batch")
+
+			src, err := openStore(from, false)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			dst := src
+			if to != from {
+				if dst, err = openStore(to, init); err != nil {
+					return err
+				}
+				defer dst.Close()
+			}
+			qw, err := writer.NewSingle(dst, graph.IgnoreOpts{IgnoreDup: graph.IgnoreDuplicates})
+			if err != nil {
+				return err
+			}
+			defer qw.Close()
+
+			var last time.Time
+			opts.Progress = func(n int64) {
+				// log at most once per second
+				if now := time.Now(); now.Sub(last) >= time.Second {
+					last = now
+					clog.Infof("copied %d quads", n)
+				}
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			start := time.Now()
+			n, err := clone.Copy(ctx, qw, src, opts)
+			if err != nil {
+				return err
+			}
+			clog.Infof("copied %d quads in %v", n, time.Since(start))
+			return nil
+		},
+	}
+	cmd.Flags().String("from", "-", "source database")
+	cmd.Flags().String("to", "", "destination database (default: the source)")
+	cmd.Flags().Bool("init", false, "initialize the destination database")
+	cmd.Flags().String("graph", "", "label of the graph to copy (default: all quads)")
+	cmd.Flags().String("rename", "", "new label of all copied quads")
+	cmd.Flags().StringArray("label", nil, "remap a label of copied quads, in the <old>=<new> form (empty old label for quads without one)")
+	cmd.Flags().Int("batch", 0, "number of quads in one write (default: 10000)")
+	return cmd
+}
@@ -1,6 +1,8 @@
 package command
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -15,8 +17,14 @@ import (
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/coalesce"
+	"github.com/cayleygraph/cayley/graph/sameas"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/graph/replication"
 	"github.com/cayleygraph/cayley/internal"
+	loader "github.com/cayleygraph/cayley/internal/load"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
 )
 
 const (
@@ -25,6 +33,17 @@ const (
 	KeyPath     = "store.path"
 	KeyReadOnly = "store.read_only"
 	KeyOptions  = "store.options"
+	KeyWAL      = "store.wal"
+	KeyWALSync  = "store.wal_sync"
+
+	KeyCoalesceDelay = "store.coalesce.delay"
+	KeyCoalesceQuads = "store.coalesce.max_quads"
+
+	KeySameAs    = "store.same_as"
+	KeyTextIndex      = "store.text_index"
+	KeyTextAnalyzers  = "store.text_analyzers"
+	KeyExpirySweep    = "store.expiry_sweep"
+	KeyTrashRetention = "store.trash_retention"
 
 	KeyLoadBatch = "load.batch"
 )
@@ -105,6 +124,10 @@ func NewLoadDatabaseCmd() *cobra.Command {
 					return err
 				}
 			}
+			if bulk, _ := cmd.Flags().GetBool("bulk"); bulk {
+				format, _ := cmd.Flags().GetString(flagLoadFormat)
+				return bulkLoad(load, format)
+			}
 			h, err := openDatabase()
 			if err != nil {
 				return err
@@ -112,10 +135,38 @@ func NewLoadDatabaseCmd() *cobra.Command {
 			defer h.Close()
 
 			// TODO: check read-only flag in config before that?
-			typ, _ := cmd.Flags().GetString(flagLoadFormat)
-			if err = internal.Load(h.QuadWriter, quad.DefaultBatch, load, typ); err != nil {
+			var opts loader.Options
+			opts.Format, _ = cmd.Flags().GetString(flagLoadFormat)
+			opts.Workers, _ = cmd.Flags().GetInt("workers")
+			opts.Checkpoint, _ = cmd.Flags().GetString("checkpoint")
+			opts.Batch = quad.DefaultBatch
+			if viper.GetString(KeyBackend) == "memstore" {
+				// memstore is not safe for concurrent writes
+				opts.Workers = 1
+			}
+			if opts.Checkpoint != "" && !graph.IgnoreDuplicates {
+				if _, err := os.Stat(opts.Checkpoint); err == nil {
+					return errors.New("resuming a load requires ignoring duplicates (--dup)")
+				}
+			}
+			var last time.Time
+			opts.Progress = func(done int64) {
+				// log at most once per second
+				if now := time.Now(); now.Sub(last) >= time.Second {
+					last = now
+					clog.Infof("loaded %d quads", done)
+				}
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			start := time.Now()
+			st, err := loader.Load(ctx, h.QuadWriter, load, opts)
+			if err == context.Canceled && opts.Checkpoint != "" {
+				return errors.New("interrupted; run the command again without --init to continue")
+			} else if err != nil {
 				return err
 			}
+			clog.Infof("loaded %d quads in %v", st.Quads-st.Resumed, time.Since(start))
 
 			if dump, _ := cmd.Flags().GetString(flagDump); dump != "" {
 				typ, _ := cmd.Flags().GetString(flagDumpFormat)
@@ -127,11 +178,40 @@ func NewLoadDatabaseCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
+	cmd.Flags().Int("workers", 0, "number of parallel workers (default: number of CPUs)")
+	cmd.Flags().String("checkpoint", "", "file to save the progress to, so that an interrupted load can be resumed")
+	cmd.Flags().Bool("bulk", false, "load into an empty database directly, bypassing transactions (KV and SQL backends)")
 	registerLoadFlags(cmd)
 	registerDumpFlags(cmd)
 	return cmd
 }
 
+// bulkLoad loads a quad file into an empty database with graph.BulkLoader. The database is opened
+// without the write-ahead log and the change feed, so the loaded quads are not recorded in them.
+func bulkLoad(path, format string) error {
+	name := viper.GetString(KeyBackend)
+	qs, err := graph.NewQuadStore(name, viper.GetString(KeyAddress), graph.Options(viper.GetStringMap(KeyOptions)))
+	if err != nil {
+		return err
+	}
+	defer qs.Close()
+	bl, ok := qs.(graph.BulkLoader)
+	if !ok {
+		return fmt.Errorf("backend %q does not support bulk loading", name)
+	}
+	qr, err := internal.QuadReaderFor(path, format)
+	if err != nil {
+		return err
+	}
+	defer qr.Close()
+	start := time.Now()
+	if err = bl.BulkLoad(qr); err != nil {
+		return err
+	}
+	clog.Infof("loaded %d quads in %v", qs.Size(), time.Since(start))
+	return nil
+}
+
 func NewDumpDatabaseCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "dump",
@@ -152,29 +232,18 @@ func NewDumpDatabaseCmd() *cobra.Command {
 			defer h.Close()
 
 			typ, _ := cmd.Flags().GetString(flagDumpFormat)
+			if qu, _ := cmd.Flags().GetString("query"); qu != "" {
+				lang, _ := cmd.Flags().GetString("lang")
+				ctx, cancel := getContext()
+				defer cancel()
+				return dumpQuery(ctx, h, lang, qu, dump, typ)
+			}
 			return dumpDatabase(h, dump, typ)
 		},
 	}
 	registerDumpFlags(cmd)
-	return cmd
-}
-
-func NewUpgradeCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "upgrade",
-		Short: "Upgrade Cayley database to current supported format.",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			printBackendInfo()
-			name := viper.GetString(KeyBackend)
-			if graph.IsRegistered(name) && !graph.IsPersistent(name) {
-				return ErrNotPersistent
-			}
-			addr := viper.GetString(KeyAddress)
-			opts := graph.Options(viper.GetStringMap(KeyOptions))
-			clog.Infof("upgrading database...")
-			return graph.UpgradeQuadStore(name, addr, opts)
-		},
-	}
+	cmd.Flags().String("query", "", "dump only quads of nodes matched by the query")
+	cmd.Flags().String("lang", "gizmo", `query language of the --query ("`+strings.Join(query.Languages(), `", "`)+`")`)
 	return cmd
 }
 
@@ -202,6 +271,49 @@ func openDatabase() (*graph.Handle, error) {
 	if err != nil {
 		return nil, err
 	}
+	base := qs
+	if p := viper.GetString(KeyWAL); p != "" {
+		if qs, err = openWAL(qs, p); err != nil {
+			return nil, err
+		}
+	}
+	if viper.GetBool(KeyTextIndex) {
+		var conf text.Analyzers
+		if err = decodeJSONKey(KeyTextAnalyzers, &conf); err != nil {
+			return nil, err
+		}
+		// the wal doesn't expose the metadata of the backend; changes applied by replicas
+		// are written below the feed, thus the index must be below it as well
+		meta, _ := base.(graph.MetadataStore)
+		qs, err = text.NewQuadStoreWith(context.TODO(), qs, text.Options{Analyzers: conf, Meta: meta})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// peers follow each other's feeds, standbys follow the feed of the primary,
+	// and the external search index follows the local feed
+	if viper.GetBool(KeyReplFeed) || viper.GetString(KeyReplPeer) != "" || viper.GetString(KeyFailoverLease) != "" ||
+		viper.GetString(KeyElasticAddr) != "" {
+		if qs, err = replication.NewFeed(qs, viper.GetInt(KeyReplFeedSize)); err != nil {
+			return nil, err
+		}
+	}
+	if dt := viper.GetDuration(KeyCoalesceDelay); dt > 0 {
+		// coalesced batches are written to the log as a single batch
+		qs = coalesce.New(qs, coalesce.Options{
+			MaxDelay: dt,
+			MaxQuads: viper.GetInt(KeyCoalesceQuads),
+		})
+	}
+	if m := viper.GetString(KeySameAs); m != "" {
+		mode, err := sameas.ParseMode(m)
+		if err != nil {
+			return nil, err
+		}
+		if qs, err = sameas.NewQuadStore(context.TODO(), qs, mode); err != nil {
+			return nil, err
+		}
+	}
 	qw, err := graph.NewQuadWriter("single", qs, opts)
 	if err != nil {
 		return nil, err
@@ -209,6 +321,47 @@ func openDatabase() (*graph.Handle, error) {
 	return &graph.Handle{QuadStore: qs, QuadWriter: qw}, nil
 }
 
+// decodeJSONKey decodes a nested config value into a struct with JSON tags.
+func decodeJSONKey(key string, dst interface{}) error {
+	v := viper.Get(key)
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(stringKeys(v))
+	if err == nil {
+		err = json.Unmarshal(data, dst)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse %s: %v", key, err)
+	}
+	return nil
+}
+
+// stringKeys converts maps decoded from YAML to a form accepted by the JSON encoder.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = stringKeys(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, val := range v {
+			arr[i] = stringKeys(val)
+		}
+		return arr
+	}
+	return v
+}
+
 func openForQueries(cmd *cobra.Command) (*graph.Handle, error) {
 	if init, err := cmd.Flags().GetBool("init"); err != nil {
 		return nil, err
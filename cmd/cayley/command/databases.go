@@ -0,0 +1,76 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/quota"
+	chttp "github.com/cayleygraph/cayley/internal/http"
+)
+
+const KeyDatabases = "databases"
+
+// databaseConfig is a database served by the http command next to the main one.
+type databaseConfig struct {
+	Name     string                 `json:"name"`
+	Backend  string                 `json:"backend"`
+	Address  string                 `json:"address"`
+	Options  map[string]interface{} `json:"options"`
+	ReadOnly bool                   `json:"read_only"`
+	// Init initializes the database if it doesn't exist.
+	Init bool `json:"init"`
+	// Quota limits the database and its graphs.
+	Quota *quota.Config `json:"quota"`
+}
+
+// openDatabases opens additional databases set in the config. Handles of returned databases must be closed.
+func openDatabases() ([]chttp.Database, error) {
+	var confs []databaseConfig
+	if err := decodeJSONKey(KeyDatabases, &confs); err != nil {
+		return nil, err
+	}
+	var out []chttp.Database
+	closeAll := func() {
+		for _, db := range out {
+			db.Handle.Close()
+		}
+	}
+	for _, c := range confs {
+		var lim *quota.Limits
+		if c.Quota != nil {
+			var err error
+			if lim, err = quota.New(*c.Quota); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("database %q: %v", c.Name, err)
+			}
+		}
+		h, err := openNamedDatabase(c)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("database %q: %v", c.Name, err)
+		}
+		clog.Infof("serving database %q with backend %q at /db/%s", c.Name, c.Backend, c.Name)
+		out = append(out, chttp.Database{Name: c.Name, Handle: h, Backend: c.Backend, ReadOnly: c.ReadOnly, Quota: lim})
+	}
+	return out, nil
+}
+
+func openNamedDatabase(c databaseConfig) (*graph.Handle, error) {
+	opts := graph.Options(c.Options)
+	if c.Init {
+		if err := graph.InitQuadStore(c.Backend, c.Address, opts); err != nil && err != graph.ErrDatabaseExists {
+			return nil, err
+		}
+	}
+	qs, err := graph.NewQuadStore(c.Backend, c.Address, opts)
+	if err != nil {
+		return nil, err
+	}
+	qw, err := graph.NewQuadWriter("single", qs, opts)
+	if err != nil {
+		qs.Close()
+		return nil, err
+	}
+	return &graph.Handle{QuadStore: qs, QuadWriter: qw}, nil
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/internal/dedupe"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func NewDedupeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Remove logically duplicate quads.",
+		Long: "Find quads that are stored separately, but are equal after the values are converted to a canonical form\n" +
+			"(full IRIs, native values of typed strings, lowercase language tags), and remove all but the first of them.\n" +
+			"With --normalize, string literals are also compared after applying normalization rules.\n" +
+			"With --dry-run, duplicates are only printed.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, _ := cmd.Flags().GetStringSlice("normalize")
+			rules, err := dedupe.RuleNames(names)
+			if err != nil {
+				return err
+			}
+			dry, _ := cmd.Flags().GetBool("dry-run")
+			ctx, cancel := getContext()
+			defer cancel()
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+
+			st, err := dedupe.Dedupe(ctx, h.QuadStore, h.QuadWriter, dedupe.Options{
+				Rules:  rules,
+				DryRun: dry,
+				Batch:  viper.GetInt(KeyLoadBatch),
+				Duplicate: func(keep, dup quad.Quad) {
+					fmt.Printf("%s # duplicate of %s\n", dup.NQuad(), keep.NQuad())
+				},
+			})
+			if err != nil {
+				return err
+			}
+			clog.Infof("scanned %d quads: %d duplicates, %d removed", st.Quads, st.Duplicates, st.Removed)
+			return nil
+		},
+	}
+	cmd.Flags().StringSlice("normalize", nil, `normalization rules for string literals ("space", "case")`)
+	cmd.Flags().Bool("dry-run", false, "print duplicates without removing them")
+	return cmd
+}
@@ -0,0 +1,129 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/diff"
+)
+
+const sourceHelp = "Each store is either a quad file (format is detected from the extension) " +
+	"or a database in the <backend>:<address> form, for example bolt:./data.\n" +
+	"Use the current database from the config with \"-\"."
+
+// openSource opens a quad file or a database for diff and sync. The returned quad store is nil for files.
+func openSource(spec, typ string) (diff.Source, graph.QuadStore, func(), error) {
+	if spec == "-" {
+		printBackendInfo()
+		h, err := openDatabase()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return diff.Store(h.QuadStore), h.QuadStore, func() { h.Close() }, nil
+	}
+	if i := strings.Index(spec, ":"); i > 0 && graph.IsRegistered(spec[:i]) {
+		qs, err := graph.NewQuadStore(spec[:i], spec[i+1:], nil)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return diff.Store(qs), qs, func() { qs.Close() }, nil
+	}
+	if _, err := os.Stat(spec); err != nil {
+		return nil, nil, nil, err
+	}
+	return diff.File(spec, typ), nil, func() {}, nil
+}
+
+func NewDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <store-a> <store-b>",
+		Short: "Print quads removed and added between two stores.",
+		Long: "Print quads that are only in store A with \"-\" and quads that are only in store B with \"+\".\n" +
+			sourceHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("two stores must be specified")
+			}
+			typ, _ := cmd.Flags().GetString(flagLoadFormat)
+			a, _, closeA, err := openSource(args[0], typ)
+			if err != nil {
+				return err
+			}
+			defer closeA()
+			b, _, closeB, err := openSource(args[1], typ)
+			if err != nil {
+				return err
+			}
+			defer closeB()
+			w := bufio.NewWriter(os.Stdout)
+			defer w.Flush()
+			st, err := diff.Diff(a, b, func(d graph.Delta) error {
+				op := '+'
+				if d.Action == graph.Delete {
+					op = '-'
+				}
+				_, err := fmt.Fprintf(w, "%c %s\n", op, d.Quad.NQuad())
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			clog.Infof("%d quads added, %d removed", st.Added, st.Removed)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagLoadFormat, "", "format of quad files instead of auto-detection")
+	return cmd
+}
+
+func NewSyncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync <source> <destination>",
+		Short: "Make the destination database contain the same quads as the source.",
+		Long: "Remove quads that are not in the source from the destination database and add missing ones.\n" +
+			sourceHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("source and destination must be specified")
+			}
+			typ, _ := cmd.Flags().GetString(flagLoadFormat)
+			src, _, closeSrc, err := openSource(args[0], typ)
+			if err != nil {
+				return err
+			}
+			defer closeSrc()
+			_, dst, closeDst, err := openSource(args[1], typ)
+			if err != nil {
+				return err
+			} else if dst == nil {
+				closeDst()
+				return errors.New("destination must be a database")
+			}
+			defer closeDst()
+			var opts diff.SyncOptions
+			opts.DryRun, _ = cmd.Flags().GetBool("dry_run")
+			opts.NoDelete, _ = cmd.Flags().GetBool("no_delete")
+			st, err := diff.Sync(dst, src, opts)
+			if err != nil {
+				return err
+			}
+			if opts.DryRun {
+				clog.Infof("would add %d quads and remove %d", st.Added, st.Removed)
+			} else {
+				clog.Infof("added %d quads, removed %d", st.Added, st.Removed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String(flagLoadFormat, "", "format of quad files instead of auto-detection")
+	cmd.Flags().Bool("dry_run", false, "only count the changes")
+	cmd.Flags().Bool("no_delete", false, "only add missing quads")
+	return cmd
+}
@@ -2,6 +2,7 @@ package command
 
 import (
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
 )
 
 func writerQuadsTo(path string, typ string, qr quad.Reader) error {
@@ -67,8 +69,17 @@ func writerQuadsTo(path string, typ string, qr quad.Reader) error {
 }
 
 func dumpDatabase(h *graph.Handle, path string, typ string) error {
-	//TODO: add possible support for exporting specific queries only
 	qr := graph.NewQuadStoreReader(h.QuadStore)
 	defer qr.Close()
 	return writerQuadsTo(path, typ, qr)
 }
+
+// dumpQuery writes the subgraph matched by a query.
+func dumpQuery(ctx context.Context, h *graph.Handle, lang, qu string, path string, typ string) error {
+	qr, err := query.Subgraph(ctx, h.QuadStore, lang, qu, -1)
+	if err != nil {
+		return err
+	}
+	defer qr.Close()
+	return writerQuadsTo(path, typ, qr)
+}
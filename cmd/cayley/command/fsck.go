@@ -0,0 +1,54 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+func NewFsckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Check the consistency of the database.",
+		Long: "Cross-check the primary data of the database against its indexes and reference counters,\n" +
+			"and print each inconsistency. Supported by KV and SQL backends.\n" +
+			"With --repair, problems that can be fixed without losing data are repaired.\n" +
+			"The database must not be used by other processes during the check.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repair, _ := cmd.Flags().GetBool("repair")
+			ctx, cancel := getContext()
+			defer cancel()
+			printBackendInfo()
+			name := viper.GetString(KeyBackend)
+			qs, err := graph.NewQuadStore(name, viper.GetString(KeyAddress), graph.Options(viper.GetStringMap(KeyOptions)))
+			if err != nil {
+				return err
+			}
+			defer qs.Close()
+			c, ok := qs.(graph.Checker)
+			if !ok {
+				return fmt.Errorf("backend %q does not support consistency checks", name)
+			}
+			st, err := c.Check(ctx, graph.CheckOptions{
+				Repair: repair,
+				Problem: func(p graph.Problem) {
+					fmt.Println(p)
+				},
+			})
+			if err != nil {
+				return err
+			}
+			clog.Infof("checked %d quads and %d nodes: %d problems, %d repaired", st.Quads, st.Nodes, st.Problems, st.Repaired)
+			if st.Problems != st.Repaired {
+				return fmt.Errorf("%d problems are not repaired", st.Problems-st.Repaired)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("repair", false, "repair problems that can be fixed without losing data")
+	return cmd
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema/gen"
+)
+
+func NewGenSchemaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "genschema [ontology files]",
+		Short: "Generate Go types from an ontology.",
+		Long: "Generate Go structs with quad tags for classes defined with RDFS, OWL or SHACL.\n" +
+			"The ontology is read from the database, unless ontology files are specified.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.TODO()
+			var qs graph.QuadStore
+			if len(args) != 0 {
+				typ, _ := cmd.Flags().GetString(flagLoadFormat)
+				var quads []quad.Quad
+				for _, path := range args {
+					qr, err := internal.QuadReaderFor(path, typ)
+					if err != nil {
+						return err
+					}
+					arr, err := quad.ReadAll(qr)
+					qr.Close()
+					if err != nil {
+						return err
+					}
+					quads = append(quads, arr...)
+				}
+				qs = memstore.New(quads...)
+			} else {
+				printBackendInfo()
+				h, err := openDatabase()
+				if err != nil {
+					return err
+				}
+				defer h.Close()
+				qs = h.QuadStore
+			}
+			structs, err := gen.Load(ctx, qs)
+			if err != nil {
+				return err
+			}
+			clog.Infof("generating %d types", len(structs))
+			pkg, _ := cmd.Flags().GetString("package")
+			out, _ := cmd.Flags().GetString("out")
+			if out == "" || out == "-" {
+				return gen.Write(os.Stdout, pkg, structs)
+			}
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			if err = gen.Write(f, pkg, structs); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		},
+	}
+	cmd.Flags().String("package", "model", "name of the generated Go package")
+	cmd.Flags().StringP("out", "o", "", `Go file to write ("-" for stdout)`)
+	cmd.Flags().String(flagLoadFormat, "", "quad file format to use for ontology files instead of auto-detection")
+	return cmd
+}
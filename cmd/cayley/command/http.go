@@ -1,15 +1,66 @@
 package command
 
 import (
+	"context"
 	"net"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/quota"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/graph/trash"
 	chttp "github.com/cayleygraph/cayley/internal/http"
+	"github.com/cayleygraph/cayley/internal/memory"
+	"github.com/cayleygraph/cayley/internal/system"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+const (
+	keyHTTPBasePath = "http.base_path"
+	keyHTTPProxies  = "http.trusted_proxies"
+
+	keyCORSDisabled    = "http.cors.disabled"
+	keyCORSOrigins     = "http.cors.allowed_origins"
+	keyCORSMethods     = "http.cors.allowed_methods"
+	keyCORSHeaders     = "http.cors.allowed_headers"
+	keyCORSExpose      = "http.cors.exposed_headers"
+	keyCORSCredentials = "http.cors.allow_credentials"
+	keyCORSMaxAge      = "http.cors.max_age"
+
+	keyHTTPReload     = "http.reload_endpoint"
+	keyHTTPAdminToken = "http.admin_token"
+	keyHTTPDebug      = "http.debug"
+
+	keyHTTPNoQueryStats = "http.query_stats.disabled"
+	keyHTTPSlowQuery    = "http.slow_query"
+	keyHTTPQueryMemory  = "http.query_memory_mb"
+	keyHTTPMemBudget    = "http.memory_budget_mb"
+	keyHTTPParallelism  = "http.query_parallelism"
+
+	keyHTTPACL   = "http.acl"
+	keyHTTPQuota = "http.quota"
+
+	keyHTTPTxTimeout           = "http.tx_timeout"
+	keyHTTPWriteIDTTL          = "http.write_id_ttl"
+	keyHTTPSessionWait         = "http.session_wait"
+	keyHTTPQuerySessionTimeout = "http.query_session_timeout"
+
+	keyTLSCert      = "http.tls.cert_file"
+	keyTLSKey       = "http.tls.key_file"
+	keyTLSClientCA  = "http.tls.client_ca_file"
+	keyTLSClientOpt = "http.tls.client_cert_optional"
+	keyACMEHosts    = "http.tls.acme.hosts"
+	keyACMECache    = "http.tls.acme.cache_dir"
+	keyACMEEmail    = "http.tls.acme.email"
+	keyACMEHTTP     = "http.tls.acme.http_addr"
 )
 
 func NewHttpCmd() *cobra.Command {
@@ -27,9 +78,145 @@ func NewHttpCmd() *cobra.Command {
 			}
 			defer h.Close()
 
-			err = chttp.SetupRoutes(h, &chttp.Config{
-				Timeout:  viper.GetDuration(keyQueryTimeout),
-				ReadOnly: viper.GetBool(KeyReadOnly),
+			dbs, err := openDatabases()
+			if err != nil {
+				return err
+			}
+			for _, db := range dbs {
+				defer db.Handle.Close()
+			}
+
+			al, err := openAudit()
+			if err != nil {
+				return err
+			}
+			if al != nil {
+				defer al.Close()
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			// the feed is hidden by the failover guard
+			feed := feedOf(h.QuadStore)
+			if err = startSearchSync(ctx, feed); err != nil {
+				return err
+			}
+			replica, err := startReplica(ctx, h)
+			if err != nil {
+				return err
+			}
+			if d := viper.GetDuration(KeyExpirySweep); d > 0 && !replica && !viper.GetBool(KeyReadOnly) {
+				clog.Infof("deleting expired labels every %v", d)
+				go temporal.RunSweeper(ctx, h.QuadStore, h.QuadWriter, d)
+			}
+			if d := viper.GetDuration(KeyTrashRetention); d > 0 && !replica && !viper.GetBool(KeyReadOnly) {
+				// the window is checked at least hourly, so purges are not delayed much past it
+				interval := time.Hour
+				if d < interval {
+					interval = d
+				}
+				clog.Infof("purging deleted graphs after %v", d)
+				go trash.RunPurger(ctx, h.QuadStore, h.QuadWriter, d, interval)
+			}
+			if !replica && !viper.GetBool(KeyReadOnly) {
+				startAnalyzer(ctx, baseStore(h.QuadStore))
+			}
+
+			views, err := startViews(ctx, h, replica || viper.GetBool(KeyReadOnly))
+			if err != nil {
+				return err
+			}
+
+			// caches are primed before the server starts accepting queries
+			if err = warmUp(ctx, h.QuadStore); err != nil {
+				return err
+			}
+
+			// settings that can be changed by reloading the configuration
+			settings := func() chttp.Settings {
+				return chttp.Settings{
+					ReadOnly: viper.GetBool(KeyReadOnly) || replica,
+					Timeout:  viper.GetDuration(keyQueryTimeout),
+				}
+			}
+			var (
+				srv    *chttp.Server
+				tc     *chttp.TLSConfig
+				reload = func() error {
+					if err := reloadConfig(); err != nil {
+						return err
+					}
+					ApplyLogConfig()
+					srv.Reload(settings())
+					if tc.Enabled() {
+						if err := tc.Reload(); err != nil {
+							return err
+						}
+					}
+					clog.Infof("configuration reloaded")
+					return nil
+				}
+				reloadHTTP func() error
+			)
+			if viper.GetBool(keyHTTPReload) {
+				reloadHTTP = reload
+			}
+			active := query.NewActive()
+			if d := viper.GetDuration(keyHTTPSlowQuery); d > 0 {
+				go active.Watch(ctx, d)
+			}
+			var budget *memory.Budget
+			if mb := viper.GetInt64(keyHTTPMemBudget); mb > 0 {
+				budget = memory.NewBudget(mb << 20)
+			}
+			access, err := httpACL()
+			if err != nil {
+				return err
+			}
+			limits, err := httpQuota()
+			if err != nil {
+				return err
+			}
+			var stats *system.QueryStats
+			if !viper.GetBool(keyHTTPNoQueryStats) {
+				stats = system.NewQueryStats(system.DefaultMaxClasses)
+			}
+			st := settings()
+			srv, err = chttp.SetupRoutes(h, &chttp.Config{
+				Timeout:             st.Timeout,
+				ReadOnly:            st.ReadOnly,
+				TxTimeout:           viper.GetDuration(keyHTTPTxTimeout),
+				WriteIDTTL:          viper.GetDuration(keyHTTPWriteIDTTL),
+				SessionWait:         viper.GetDuration(keyHTTPSessionWait),
+				QuerySessionTimeout: viper.GetDuration(keyHTTPQuerySessionTimeout),
+				Audit:               al,
+				Feed:                feed,
+				QueryStats:          stats,
+				SystemSources:       []system.Source{system.NewStore(h.QuadStore, viper.GetString(KeyBackend))},
+				Active:              active,
+				MemoryBudget:        budget,
+				QueryMemory:         viper.GetInt64(keyHTTPQueryMemory) << 20,
+				QueryParallelism:    viper.GetInt(keyHTTPParallelism),
+				DefaultGraph:        defaultGraph(),
+				ACL:                 access,
+				Quota:               limits,
+				Views:               views,
+				TrashRetention:      viper.GetDuration(KeyTrashRetention),
+				BasePath:            viper.GetString(keyHTTPBasePath),
+				TrustedProxies:      viper.GetStringSlice(keyHTTPProxies),
+				Reload:              reloadHTTP,
+				AdminToken:          viper.GetString(keyHTTPAdminToken),
+				Debug:               viper.GetBool(keyHTTPDebug),
+				Databases:           dbs,
+				CORS: chttp.CORSConfig{
+					Disabled:         viper.GetBool(keyCORSDisabled),
+					AllowedOrigins:   viper.GetStringSlice(keyCORSOrigins),
+					AllowedMethods:   viper.GetStringSlice(keyCORSMethods),
+					AllowedHeaders:   viper.GetStringSlice(keyCORSHeaders),
+					ExposedHeaders:   viper.GetStringSlice(keyCORSExpose),
+					AllowCredentials: viper.GetBool(keyCORSCredentials),
+					MaxAge:           viper.GetDuration(keyCORSMaxAge),
+				},
 			})
 			if err != nil {
 				return err
@@ -39,15 +226,101 @@ func NewHttpCmd() *cobra.Command {
 			if host, port, err := net.SplitHostPort(host); err == nil && host == "" {
 				phost = net.JoinHostPort("localhost", port)
 			}
-			clog.Infof("listening on %s, web interface at http://%s", host, phost)
-			return http.ListenAndServe(host, nil)
+			tc = &chttp.TLSConfig{
+				CertFile:           viper.GetString(keyTLSCert),
+				KeyFile:            viper.GetString(keyTLSKey),
+				ACMEHosts:          viper.GetStringSlice(keyACMEHosts),
+				ACMECacheDir:       viper.GetString(keyACMECache),
+				ACMEEmail:          viper.GetString(keyACMEEmail),
+				ACMEHTTPAddr:       viper.GetString(keyACMEHTTP),
+				ClientCAFile:       viper.GetString(keyTLSClientCA),
+				ClientCertOptional: viper.GetBool(keyTLSClientOpt),
+			}
+			scheme := "http"
+			if tc.Enabled() {
+				scheme = "https"
+			}
+			go func() {
+				sig := make(chan os.Signal, 1)
+				signal.Notify(sig, syscall.SIGHUP)
+				for range sig {
+					if err := reload(); err != nil {
+						clog.Errorf("reload failed: %v", err)
+					}
+				}
+			}()
+			clog.Infof("listening on %s, web interface at %s://%s", host, scheme, phost)
+			return chttp.ListenAndServe(host, tc)
 		},
 	}
 	cmd.Flags().String("host", "127.0.0.1:64210", "host:port to listen on")
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
 	cmd.Flags().DurationP("timeout", "t", 30*time.Second, "elapsed time until an individual query times out")
 	cmd.Flags().StringVar(&chttp.AssetsPath, "assets", "", "explicit path to the HTTP assets")
+	cmd.Flags().String("base_path", "", "path prefix to serve all handlers from (when running behind a reverse proxy)")
+	cmd.Flags().StringSlice("trusted_proxies", nil, "IP addresses or CIDR ranges of proxies allowed to set X-Forwarded-For")
+	cmd.Flags().StringSlice("cors_origins", nil, `origins allowed to make cross-origin requests (default: any)`)
+	cmd.Flags().String("tls_cert", "", "path to a PEM-encoded TLS certificate")
+	cmd.Flags().String("tls_key", "", "path to a PEM-encoded TLS private key")
+	cmd.Flags().String("tls_client_ca", "", "path to PEM-encoded CA certificates to verify client certificates with (enables mTLS)")
+	cmd.Flags().StringSlice("acme_hosts", nil, "host names to request TLS certificates for from Let's Encrypt")
+	cmd.Flags().String("replicate_from", "", "base URL of the primary to replicate from (serves read-only queries)")
+	cmd.Flags().String("peer", "", "base URL of a peer server for active-active replication")
 	registerLoadFlags(cmd)
+	viper.BindPFlag(KeyReplPrimary, cmd.Flags().Lookup("replicate_from"))
+	viper.BindPFlag(KeyReplPeer, cmd.Flags().Lookup("peer"))
 	viper.BindPFlag(keyQueryTimeout, cmd.Flags().Lookup("timeout"))
+	viper.BindPFlag(keyTLSCert, cmd.Flags().Lookup("tls_cert"))
+	viper.BindPFlag(keyTLSKey, cmd.Flags().Lookup("tls_key"))
+	viper.BindPFlag(keyTLSClientCA, cmd.Flags().Lookup("tls_client_ca"))
+	viper.BindPFlag(keyACMEHosts, cmd.Flags().Lookup("acme_hosts"))
+	viper.BindPFlag(keyHTTPBasePath, cmd.Flags().Lookup("base_path"))
+	viper.BindPFlag(keyHTTPProxies, cmd.Flags().Lookup("trusted_proxies"))
+	viper.BindPFlag(keyCORSOrigins, cmd.Flags().Lookup("cors_origins"))
 	return cmd
 }
+
+// defaultGraph returns the named graph selected by queries by default, or nil if queries use all graphs.
+func defaultGraph() quad.Value {
+	if s := viper.GetString(keyQueryGraph); s != "" {
+		return quad.StringToValue(s)
+	}
+	return nil
+}
+
+// httpACL returns access lists of named graphs, or nil if access is not restricted.
+func httpACL() (*acl.ACL, error) {
+	if !viper.IsSet(keyHTTPACL) {
+		return nil, nil
+	}
+	var a acl.ACL
+	if err := decodeJSONKey(keyHTTPACL, &a); err != nil {
+		return nil, err
+	} else if err = a.Validate(); err != nil {
+		return nil, err
+	}
+	if viper.GetString(keyTLSClientCA) == "" {
+		clog.Warningf("access lists are set without client certificates; all clients are anonymous")
+	}
+	return &a, nil
+}
+
+// httpQuota returns quotas of the main database and its graphs, or nil if nothing is limited.
+func httpQuota() (*quota.Limits, error) {
+	if !viper.IsSet(keyHTTPQuota) {
+		return nil, nil
+	}
+	var c quota.Config
+	if err := decodeJSONKey(keyHTTPQuota, &c); err != nil {
+		return nil, err
+	}
+	return quota.New(c)
+}
+
+// reloadConfig reads the configuration file again, if it's used. Values set by flags are not changed.
+func reloadConfig() error {
+	if viper.ConfigFileUsed() == "" {
+		return nil
+	}
+	return viper.ReadInConfig()
+}
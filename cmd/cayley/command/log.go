@@ -0,0 +1,54 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+)
+
+const (
+	// KeyLogVerbosity is a level of verbose logs. It overrides the -v flag if set,
+	// and is applied again when the configuration of the server is reloaded.
+	KeyLogVerbosity = "log.verbosity"
+	// KeyLogFormat is a format of log messages: "text" or "json".
+	KeyLogFormat = "log.format"
+	// KeyLogModules maps module names to their verbosity levels. Like KeyLogVerbosity,
+	// it's applied again on reload.
+	KeyLogModules = "log.modules"
+)
+
+// SetupLogFormat switches the logger to the format set in the configuration.
+// Verbosity set by flags is kept.
+func SetupLogFormat(cmd *cobra.Command) error {
+	switch f := viper.GetString(KeyLogFormat); f {
+	case "", "text":
+		return nil
+	case "json":
+		l := clog.NewJSONLogger(os.Stderr)
+		if fl := cmd.Flags().Lookup("verbose"); fl != nil {
+			v, _ := strconv.Atoi(fl.Value.String())
+			l.SetV(v)
+		}
+		clog.SetLogger(l)
+		return nil
+	default:
+		return fmt.Errorf("unsupported log format: %q", f)
+	}
+}
+
+// ApplyLogConfig sets the level of verbose logs and levels of modules from the configuration, if they are set.
+func ApplyLogConfig() {
+	if viper.IsSet(KeyLogVerbosity) {
+		clog.SetV(viper.GetInt(KeyLogVerbosity))
+	}
+	levels := make(map[string]int)
+	for name := range viper.GetStringMap(KeyLogModules) {
+		levels[name] = viper.GetInt(KeyLogModules + "." + name)
+	}
+	clog.SetModuleLevels(levels)
+}
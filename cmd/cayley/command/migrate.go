@@ -0,0 +1,103 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/migrate"
+)
+
+// openStore opens a database in the <backend>:<address> form, or the database from the config for "-".
+func openStore(spec string, init bool) (graph.QuadStore, error) {
+	if spec == "-" {
+		printBackendInfo()
+		h, err := openDatabase()
+		if err != nil {
+			return nil, err
+		}
+		return h.QuadStore, nil
+	}
+	i := strings.Index(spec, ":")
+	if i <= 0 || !graph.IsRegistered(spec[:i]) {
+		return nil, fmt.Errorf("expected <backend>:<address>, got %q", spec)
+	}
+	name, addr := spec[:i], spec[i+1:]
+	if init {
+		if err := graph.InitQuadStore(name, addr, nil); err != nil && err != graph.ErrDatabaseExists {
+			return nil, err
+		}
+	}
+	return graph.NewQuadStore(name, addr, nil)
+}
+
+func NewMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate --from <backend>:<address> --to <backend>:<address>",
+		Short: "Copy all quads from one database to another.",
+		Long: "Copy all quads, including namespaces and other metadata stored in the graph, to an empty database,\n" +
+			"possibly of a different backend, and verify the number of quads in the destination.\n" +
+			"The progress is saved to the checkpoint file, and an interrupted migration continues\n" +
+			"from it when the command is run again.\n" +
+			"Databases are in the <backend>:<address> form, for example bolt:./data.\n" +
+			"Use the current database from the config with \"-\".",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			if from == "" || to == "" {
+				return errors.New("source and destination must be specified")
+			} else if from == to {
+				return errors.New("source and destination must be different")
+			}
+			init, _ := cmd.Flags().GetBool("init")
+			var opts migrate.Options
+			opts.Workers, _ = cmd.Flags().GetInt("workers")
+			opts.Batch, _ = cmd.Flags().GetInt("batch")
+			opts.Checkpoint, _ = cmd.Flags().GetString("checkpoint")
+
+			src, err := openStore(from, false)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			dst, err := openStore(to, init)
+			if err != nil {
+				return err
+			}
+			defer dst.Close()
+
+			var last time.Time
+			opts.Progress = func(done int64) {
+				// log at most once per second
+				if now := time.Now(); now.Sub(last) >= time.Second {
+					last = now
+					clog.Infof("migrated %d quads", done)
+				}
+			}
+			ctx, cancel := getContext()
+			defer cancel()
+			start := time.Now()
+			st, err := migrate.Migrate(ctx, dst, src, opts)
+			if err == context.Canceled && opts.Checkpoint != "" {
+				return errors.New("interrupted, run the command again to continue")
+			} else if err != nil {
+				return err
+			}
+			clog.Infof("migrated %d quads in %v", st.Quads, time.Since(start))
+			return nil
+		},
+	}
+	cmd.Flags().String("from", "", "source database")
+	cmd.Flags().String("to", "", "destination database")
+	cmd.Flags().Bool("init", false, "initialize the destination database")
+	cmd.Flags().Int("workers", 0, "number of parallel workers (default: number of CPUs)")
+	cmd.Flags().Int("batch", 0, "number of quads in one write (default: 10000)")
+	cmd.Flags().String("checkpoint", "cayley-migrate.json", "file to save the progress to (empty to disable)")
+	return cmd
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+)
+
+// indexText is the name of the full-text index in the reindex command.
+const indexText = "text"
+
+func NewReindexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild secondary indexes of the database.",
+		Long: "Rebuild optional secondary indexes from the quad log, for recovery or to enable them on an existing database.\n" +
+			"Value indexes of KV backends (" + strings.Join(kv.ValueIndexes(), ", ") + ") are rebuilt in parallel,\n" +
+			"and an interrupted rebuild continues from the last saved step when the command is run again.\n" +
+			"The text index rebuilds the external search index, if one is configured.\n" +
+			"The database must not be used by other processes during the rebuild.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, _ := cmd.Flags().GetStringSlice("index")
+			if len(names) == 0 {
+				return fmt.Errorf("no indexes specified")
+			}
+			workers, _ := cmd.Flags().GetInt("workers")
+			batch, _ := cmd.Flags().GetInt("batch")
+			ctx, cancel := getContext()
+			defer cancel()
+			printBackendInfo()
+			return reindex(ctx, names, kv.ReindexOptions{Workers: workers, Batch: batch})
+		},
+	}
+	cmd.Flags().StringSlice("index", nil, `indexes to rebuild ("`+strings.Join(append(kv.ValueIndexes(), indexText), `", "`)+`")`)
+	cmd.Flags().Int("workers", 0, "number of parallel workers (default: number of CPUs)")
+	cmd.Flags().Int("batch", kv.DefaultReindexBatch, "number of log entries indexed by each worker in one step")
+	return cmd
+}
+
+func reindex(ctx context.Context, names []string, opts kv.ReindexOptions) error {
+	name := viper.GetString(KeyBackend)
+	path := viper.GetString(KeyAddress)
+	// index options are not passed to the backend, as it would build missing indexes on open
+	conf := make(graph.Options)
+	for k, v := range viper.GetStringMap(KeyOptions) {
+		conf[k] = v
+	}
+	for _, n := range kv.ValueIndexes() {
+		delete(conf, n)
+	}
+	qs, err := graph.NewQuadStore(name, path, conf)
+	if err != nil {
+		return err
+	}
+	defer qs.Close()
+	for _, n := range names {
+		if n == indexText {
+			if err = reindexText(ctx, qs); err != nil {
+				return err
+			}
+			continue
+		}
+		kqs, ok := qs.(*kv.QuadStore)
+		if !ok {
+			return fmt.Errorf("backend %q has no %s index", name, n)
+		}
+		last := -1
+		opts.Progress = func(done, total uint64) {
+			// log each percent at most once
+			if p := int(done * 100 / total); p != last {
+				last = p
+				clog.Infof("%s: %d%% (%d/%d)", n, p, done, total)
+			}
+		}
+		if err = kqs.RebuildIndex(ctx, n, opts); err == context.Canceled {
+			return fmt.Errorf("%s: interrupted, run the command again to continue", n)
+		} else if err != nil {
+			return err
+		}
+		clog.Infof("%s: index is ready", n)
+	}
+	return nil
+}
+
+// reindexText rebuilds the external search index. The in-memory index is always rebuilt on startup.
+func reindexText(ctx context.Context, qs graph.QuadStore) error {
+	addr := viper.GetString(KeyElasticAddr)
+	if addr == "" {
+		clog.Infof("%s: no external index is configured; the in-memory index is rebuilt on startup", indexText)
+		return nil
+	}
+	idx, err := dialElastic(ctx, addr)
+	if err != nil {
+		return err
+	}
+	clog.Infof("%s: rebuilding index at %q", indexText, addr)
+	return idx.Reindex(ctx, qs)
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/named"
 	"github.com/cayleygraph/cayley/internal/repl"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/cayleygraph/cayley/query"
@@ -22,6 +23,7 @@ import (
 
 const (
 	keyQueryTimeout = "query.timeout"
+	keyQueryGraph   = "query.default_graph"
 )
 
 func getContext() (context.Context, func()) {
@@ -44,10 +46,17 @@ func registerQueryFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("init", false, "initialize the database before using it")
 	cmd.Flags().String("lang", "gizmo", `query language to use ("`+strings.Join(langs, `", "`)+`")`)
 	cmd.Flags().DurationP("timeout", "t", 30*time.Second, "elapsed time until an individual query times out")
+	cmd.Flags().String("graph", "", `named graph to query, or "*" for all graphs (default: query.default_graph)`)
 	viper.BindPFlag(keyQueryTimeout, cmd.Flags().Lookup("timeout"))
 	registerLoadFlags(cmd)
 }
 
+// queryGraph restricts a quad store to the named graph selected by the graph flag or the config.
+func queryGraph(cmd *cobra.Command, qs graph.QuadStore) graph.QuadStore {
+	name, _ := cmd.Flags().GetString("graph")
+	return named.Select(qs, name, defaultGraph())
+}
+
 func NewReplCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "repl",
@@ -63,15 +72,20 @@ func NewReplCmd() *cobra.Command {
 			}
 			defer h.Close()
 
-			ctx, cancel := getContext()
-			defer cancel()
+			// the REPL handles interrupts itself
+			ctx := context.Background()
 
-			timeout := viper.GetDuration("timeout")
 			lang, _ := cmd.Flags().GetString("lang")
-			return repl.Repl(ctx, h, lang, timeout)
+			history, _ := cmd.Flags().GetString("history")
+			return repl.Repl(ctx, &graph.Handle{QuadStore: queryGraph(cmd, h.QuadStore), QuadWriter: h.QuadWriter}, repl.Options{
+				Language: lang,
+				Timeout:  viper.GetDuration("timeout"),
+				History:  history,
+			})
 		},
 	}
 	registerQueryFlags(cmd)
+	cmd.Flags().String("history", repl.DefaultHistory(), `path of the history file, or "-" to disable it`)
 	return cmd
 }
 
@@ -123,7 +137,7 @@ func NewQueryCmd() *cobra.Command {
 				return fmt.Errorf("unknown query language: %q", lang)
 			}
 			enc := json.NewEncoder(os.Stdout)
-			sess := l.Session(h)
+			sess := l.Session(queryGraph(cmd, h.QuadStore))
 			ch := make(chan query.Result, 100)
 			go sess.Execute(ctx, querystr, ch, limit)
 			for i := 0; limit <= 0 || i < limit; i++ {
@@ -0,0 +1,166 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"expvar"
+
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/coalesce"
+	"github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/graph/sameas"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/graph/wal"
+)
+
+const (
+	KeyReplFeed     = "replication.feed"
+	KeyReplFeedSize = "replication.feed_size"
+	KeyReplPrimary  = "replication.primary"
+	KeyReplPollWait = "replication.poll_wait"
+
+	KeyReplPeer           = "replication.peer"
+	KeyReplConflict       = "replication.conflict"
+	KeyReplConflictWindow = "replication.conflict_window"
+
+	KeyFailoverLease = "replication.failover.lease"
+	KeyFailoverAddr  = "replication.failover.addr"
+	KeyFailoverID    = "replication.failover.id"
+	KeyFailoverTTL   = "replication.failover.ttl"
+)
+
+// feedOf returns the replication feed of the quad store opened by openDatabase.
+func feedOf(qs graph.QuadStore) *replication.Feed {
+	switch qs := qs.(type) {
+	case *replication.Feed:
+		return qs
+	case *coalesce.QuadStore:
+		return feedOf(qs.QuadStore)
+	case *sameas.QuadStore:
+		return feedOf(qs.QuadStore)
+	case *text.QuadStore:
+		return feedOf(qs.QuadStore)
+	case graph.Wrapper:
+		// the failover guard
+		return feedOf(qs.Unwrapped())
+	}
+	return nil
+}
+
+// baseStore returns the backend quad store opened by openDatabase, without write wrappers.
+// It exposes optional interfaces of the backend, but bypasses the log and the feed on writes.
+func baseStore(qs graph.QuadStore) graph.QuadStore {
+	for {
+		switch s := qs.(type) {
+		case *coalesce.QuadStore:
+			qs = s.QuadStore
+		case *replication.Feed:
+			qs = s.QuadStore
+		case *wal.QuadStore:
+			qs = s.QuadStore
+		case *sameas.QuadStore:
+			qs = s.QuadStore
+		case *text.QuadStore:
+			qs = s.QuadStore
+		case graph.Wrapper:
+			// the failover guard
+			qs = s.Unwrapped()
+		default:
+			return qs
+		}
+	}
+}
+
+// startReplica follows the primary or the peer set in the config, if any. It reports if the server
+// must be read-only. Replication status is published to expvar.
+func startReplica(ctx context.Context, h *graph.Handle) (bool, error) {
+	primary, peer := viper.GetString(KeyReplPrimary), viper.GetString(KeyReplPeer)
+	if lease := viper.GetString(KeyFailoverLease); lease != "" {
+		if primary != "" || peer != "" {
+			return false, errors.New("replication: failover cannot be used with a fixed primary or a peer")
+		}
+		return false, startFailover(ctx, h, lease)
+	}
+	ropts := replication.ReplicaOptions{
+		PollWait: viper.GetDuration(KeyReplPollWait),
+		// servers of a group share the admin token, which grants access to all graphs
+		Token: viper.GetString(keyHTTPAdminToken),
+	}
+	// replicas and peers continue from the position saved in the backend after a restart
+	ropts.Meta, _ = baseStore(h.QuadStore).(graph.MetadataStore)
+	var (
+		r        *replication.Replica
+		readOnly bool
+	)
+	switch {
+	case primary != "" && peer != "":
+		return false, errors.New("replication: primary and peer cannot be set at the same time")
+	case primary != "":
+		r = replication.NewReplica(h.QuadStore, primary, ropts)
+		// replicas only apply changes from the primary
+		readOnly = true
+		clog.Infof("replicating from %q", primary)
+	case peer != "":
+		feed := feedOf(h.QuadStore)
+		if feed == nil {
+			return false, errors.New("replication: feed is not enabled")
+		}
+		name := viper.GetString(KeyReplConflict)
+		if name == "" {
+			name = "lww"
+		}
+		resolve, err := replication.GetResolver(name)
+		if err != nil {
+			return false, err
+		}
+		r = replication.NewPeer(feed, peer, replication.PeerOptions{
+			ReplicaOptions: ropts,
+			Resolver:       resolve,
+			Window:         viper.GetDuration(KeyReplConflictWindow),
+		})
+		clog.Infof("replicating with peer %q (conflicts: %s)", peer, name)
+	default:
+		return false, nil
+	}
+	expvar.Publish("replication", expvar.Func(func() interface{} {
+		return r.Status()
+	}))
+	go r.Run(ctx)
+	return readOnly, nil
+}
+
+// startFailover joins the election of the primary. Writes are rejected while the server is a standby.
+func startFailover(ctx context.Context, h *graph.Handle, lease string) error {
+	feed := feedOf(h.QuadStore)
+	if feed == nil {
+		return errors.New("replication: feed is not enabled")
+	}
+	f, err := replication.NewFailover(feed.QuadStore, replication.NewFileLease(lease), replication.FailoverOptions{
+		ReplicaOptions: replication.ReplicaOptions{
+			PollWait: viper.GetDuration(KeyReplPollWait),
+			Token:    viper.GetString(keyHTTPAdminToken),
+		},
+		ID:   viper.GetString(KeyFailoverID),
+		Addr: viper.GetString(KeyFailoverAddr),
+		TTL:  viper.GetDuration(KeyFailoverTTL),
+	})
+	if err != nil {
+		return err
+	}
+	if err = h.QuadWriter.Close(); err != nil {
+		return err
+	}
+	h.QuadStore = f.Guard(h.QuadStore)
+	if h.QuadWriter, err = graph.NewQuadWriter("single", h.QuadStore, graph.Options(viper.GetStringMap(KeyOptions))); err != nil {
+		return err
+	}
+	expvar.Publish("failover", expvar.Func(func() interface{} {
+		return f.Status()
+	}))
+	clog.Infof("joining the election of the primary (lease %q)", lease)
+	go f.Run(ctx)
+	return nil
+}
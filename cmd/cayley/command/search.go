@@ -0,0 +1,64 @@
+package command
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/graph/text/elastic"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	KeyElasticAddr  = "search.elastic.address"
+	KeyElasticIndex = "search.elastic.index"
+	KeyElasticPreds = "search.elastic.predicates"
+)
+
+// startSearchSync mirrors literals to an external search index set in the config, if any.
+// The index is rebuilt first, and then follows the replication feed.
+func startSearchSync(ctx context.Context, feed *replication.Feed) error {
+	addr := viper.GetString(KeyElasticAddr)
+	if addr == "" {
+		return nil
+	} else if feed == nil {
+		return errors.New("elastic: feed is not enabled")
+	}
+	idx, err := dialElastic(ctx, addr)
+	if err != nil {
+		return err
+	}
+	seq := feed.Seq()
+	if err = idx.Reindex(ctx, feed.QuadStore); err != nil {
+		return err
+	}
+	clog.Infof("mirroring literals to %q", addr)
+	go func() {
+		if _, err := idx.Follow(ctx, feed, seq); err != nil {
+			clog.Errorf("elastic: sync stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// dialElastic connects to the external search index configured for a given address and creates it, if necessary.
+func dialElastic(ctx context.Context, addr string) (*elastic.Index, error) {
+	var preds []quad.IRI
+	for _, p := range viper.GetStringSlice(KeyElasticPreds) {
+		preds = append(preds, quad.IRI(p))
+	}
+	idx, err := elastic.Dial(addr, elastic.Options{
+		Index:      viper.GetString(KeyElasticIndex),
+		Predicates: preds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = idx.Init(ctx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
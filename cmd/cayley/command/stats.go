@@ -0,0 +1,112 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/internal/stats"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func NewStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Print statistics of the database.",
+		Long: "Print the number of quads and nodes, the number of quads per predicate and per named graph, a summary of node degrees\n" +
+			"and sizes of indexes, if the backend reports them. All quads and nodes are scanned.\n\n" +
+			"With --graph, statistics only describe a single named graph.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			degrees, _ := cmd.Flags().GetBool("degrees")
+			top, _ := cmd.Flags().GetInt("top")
+			ctx, cancel := getContext()
+			defer cancel()
+			printBackendInfo()
+			qs, err := graph.NewQuadStore(viper.GetString(KeyBackend), viper.GetString(KeyAddress), graph.Options(viper.GetStringMap(KeyOptions)))
+			if err != nil {
+				return err
+			}
+			defer qs.Close()
+			if name, _ := cmd.Flags().GetString("graph"); name != "" {
+				qs = named.Select(qs, name, nil)
+			}
+			st, err := stats.Compute(ctx, qs, stats.Options{Degrees: degrees})
+			if err != nil {
+				return err
+			}
+			printStats(st, top)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("degrees", true, "compute the distribution of node degrees")
+	cmd.Flags().Int("top", 20, "number of predicates to print; 0 prints all")
+	cmd.Flags().String("graph", "", "named graph to describe")
+	return cmd
+}
+
+func printStats(st *stats.Stats, top int) {
+	fmt.Printf("quads: %d (size reported by the backend: %d)\n", st.Quads, st.Size)
+	fmt.Printf("nodes: %d\n", st.Nodes)
+	fmt.Printf("predicates: %d\n\n", len(st.Predicates))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "predicate\tquads\t")
+	for i, p := range st.Predicates {
+		if top > 0 && i >= top {
+			fmt.Fprintf(tw, "(%d more)\t\t\n", len(st.Predicates)-top)
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%d\t\n", quad.StringOf(p.Predicate), p.Quads)
+	}
+	tw.Flush()
+
+	if len(st.Graphs) > 1 || (len(st.Graphs) == 1 && st.Graphs[0].Label != nil) {
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "graph\tquads\t")
+		for i, g := range st.Graphs {
+			if top > 0 && i >= top {
+				fmt.Fprintf(tw, "(%d more)\t\t\n", len(st.Graphs)-top)
+				break
+			}
+			name := "(no label)"
+			if g.Label != nil {
+				name = quad.StringOf(g.Label)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t\n", name, g.Quads)
+		}
+		tw.Flush()
+	}
+
+	if st.Out != nil {
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "degree\tmin\tmean\tp50\tp90\tp99\tmax\t")
+		for _, d := range []struct {
+			name string
+			d    *stats.Degrees
+		}{{"out", st.Out}, {"in", st.In}} {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f\t%d\t%d\t%d\t%d\t\n", d.name, d.d.Min, d.d.Mean, d.d.P50, d.d.P90, d.d.P99, d.d.Max)
+		}
+		tw.Flush()
+	}
+
+	if len(st.Indexes) != 0 {
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "index\tentries\tbytes\t")
+		for _, ind := range st.Indexes {
+			size := "-"
+			if ind.Bytes != 0 {
+				size = fmt.Sprint(ind.Bytes)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t\n", ind.Name, ind.Entries, size)
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,120 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+func NewUpgradeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade Cayley database to current supported format.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			name := viper.GetString(KeyBackend)
+			if graph.IsRegistered(name) && !graph.IsPersistent(name) {
+				return ErrNotPersistent
+			}
+			addr := viper.GetString(KeyAddress)
+			opts := graph.Options(viper.GetStringMap(KeyOptions))
+			cur, latest, err := graph.QuadStoreVersion(name, addr, opts)
+			if err == graph.ErrOperationNotSupported {
+				fmt.Printf("backend %q does not version its data; nothing to upgrade\n", name)
+				return nil
+			} else if err != nil {
+				return err
+			}
+			fmt.Printf("data version: %d, latest: %d\n", cur, latest)
+			if cur == latest {
+				fmt.Println("database is up to date")
+				return nil
+			} else if cur > latest {
+				return fmt.Errorf("database was written by a newer version of Cayley")
+			}
+			if check, _ := cmd.Flags().GetBool("check"); check {
+				return fmt.Errorf("database must be upgraded")
+			}
+			var backup string
+			if skip, _ := cmd.Flags().GetBool("no-backup"); !skip {
+				dst, _ := cmd.Flags().GetString("backup")
+				if dst == "" {
+					dst = fmt.Sprintf("%s.v%d.bak", filepath.Clean(addr), cur)
+				}
+				if _, err := os.Stat(addr); err != nil {
+					return fmt.Errorf("cannot back up %q: %v; use --no-backup to upgrade without a backup", addr, err)
+				} else if _, err := os.Stat(dst); err == nil {
+					return fmt.Errorf("backup %q already exists", dst)
+				}
+				clog.Infof("copying database to %q", dst)
+				if err := copyPath(dst, addr); err != nil {
+					os.RemoveAll(dst)
+					return fmt.Errorf("backup failed: %v", err)
+				}
+				fmt.Printf("database was copied to %q\n", dst)
+				backup = dst
+			}
+			clog.Infof("upgrading database...")
+			if err := graph.UpgradeQuadStore(name, addr, opts); err != nil {
+				if backup != "" {
+					fmt.Printf("upgrade failed; the original database is kept in %q\n", backup)
+				}
+				return err
+			}
+			fmt.Printf("database was upgraded to version %d\n", latest)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("check", false, "only check if the database must be upgraded (exits with an error if so)")
+	cmd.Flags().String("backup", "", `path to copy the database to before the upgrade (default "<address>.v<version>.bak")`)
+	cmd.Flags().Bool("no-backup", false, "upgrade the database without a backup")
+	return cmd
+}
+
+// copyPath copies a file or a directory with all its files.
+func copyPath(dst, src string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(target, fi.Mode()|0700)
+		} else if !fi.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(target, path, fi.Mode())
+	})
+}
+
+func copyFile(dst, src string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
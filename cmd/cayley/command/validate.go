@@ -0,0 +1,64 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/shacl"
+)
+
+func NewValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the database against SHACL shapes.",
+		Long: "Validate all data in the database against SHACL shapes and print validation results.\n" +
+			"Shapes are read from the database, unless a shapes file is specified.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+			ctx := context.TODO()
+			var shapes *shacl.Shapes
+			if path, _ := cmd.Flags().GetString("shapes"); path != "" {
+				typ, _ := cmd.Flags().GetString(flagLoadFormat)
+				qr, err := internal.QuadReaderFor(path, typ)
+				if err != nil {
+					return err
+				}
+				shapes, err = shacl.Read(ctx, qr)
+				qr.Close()
+				if err != nil {
+					return err
+				}
+			} else if shapes, err = shacl.Load(ctx, h.QuadStore); err != nil {
+				return err
+			}
+			clog.Infof("loaded %d shapes", len(shapes.Shapes))
+			rep, err := shapes.Validate(ctx, h.QuadStore)
+			if err != nil {
+				return err
+			}
+			w := bufio.NewWriter(os.Stdout)
+			defer w.Flush()
+			for _, r := range rep.Results {
+				fmt.Fprintf(w, "%s\t%v\n", r.Severity.Short(), r)
+			}
+			if !rep.Conforms {
+				return fmt.Errorf("data does not conform to shapes: %d results", len(rep.Results))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String("shapes", "", "quad file to read shapes from (default: shapes in the database)")
+	cmd.Flags().String(flagLoadFormat, "", "quad file format to use for the shapes file instead of auto-detection")
+	return cmd
+}
@@ -0,0 +1,63 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/view"
+)
+
+const KeyViews = "views"
+
+// viewConfig is a virtual graph defined by a saved query.
+type viewConfig struct {
+	Name         string `json:"name"`
+	Lang         string `json:"lang"`
+	Query        string `json:"query"`
+	Limit        int    `json:"limit"`
+	Materialized bool   `json:"materialized"`
+	// Refresh is an interval of scheduled refreshes, in the format of time.ParseDuration.
+	Refresh  string `json:"refresh"`
+	OnChange bool   `json:"on_change"`
+}
+
+// startViews defines views set in the config and starts refreshing materialized views, unless the database
+// is read-only. It returns nil if no views are set.
+func startViews(ctx context.Context, h *graph.Handle, readOnly bool) (*view.Views, error) {
+	var confs []viewConfig
+	if err := decodeJSONKey(KeyViews, &confs); err != nil {
+		return nil, err
+	} else if len(confs) == 0 {
+		return nil, nil
+	}
+	defs := make([]view.Definition, 0, len(confs))
+	materialized := false
+	for _, c := range confs {
+		d := view.Definition{
+			Name: c.Name, Lang: c.Lang, Query: c.Query, Limit: c.Limit,
+			Materialized: c.Materialized, OnChange: c.OnChange,
+		}
+		if c.Refresh != "" {
+			dt, err := time.ParseDuration(c.Refresh)
+			if err != nil {
+				return nil, fmt.Errorf("view %s: invalid refresh interval: %v", c.Name, err)
+			}
+			d.Interval = dt
+		}
+		materialized = materialized || c.Materialized
+		defs = append(defs, d)
+	}
+	vs, err := view.New(h, defs)
+	if err != nil {
+		return nil, err
+	}
+	if materialized && readOnly {
+		clog.Warningf("database is read-only; materialized views are not refreshed")
+	} else if materialized {
+		go vs.Run(ctx, view.DefaultPoll)
+	}
+	return vs, nil
+}
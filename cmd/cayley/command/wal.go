@@ -0,0 +1,99 @@
+package command
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/coalesce"
+	"github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/graph/sameas"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/graph/wal"
+)
+
+// openWAL wraps the quad store with a write-ahead log. Batches that were not applied are replayed.
+func openWAL(qs graph.QuadStore, path string) (graph.QuadStore, error) {
+	l, err := wal.Open(path)
+	if err != nil {
+		qs.Close()
+		return nil, err
+	}
+	// sync is enabled by default
+	l.SetNoSync(viper.IsSet(KeyWALSync) && !viper.GetBool(KeyWALSync))
+	ws, err := wal.New(qs, l)
+	if err != nil {
+		l.Close()
+		qs.Close()
+		return nil, err
+	}
+	clog.Infof("using write-ahead log %q", path)
+	return ws, nil
+}
+
+// walOf returns the write-ahead log wrapper of the quad store opened by openDatabase.
+func walOf(qs graph.QuadStore) *wal.QuadStore {
+	switch qs := qs.(type) {
+	case *wal.QuadStore:
+		return qs
+	case *replication.Feed:
+		return walOf(qs.QuadStore)
+	case *coalesce.QuadStore:
+		return walOf(qs.QuadStore)
+	case *sameas.QuadStore:
+		return walOf(qs.QuadStore)
+	case *text.QuadStore:
+		return walOf(qs.QuadStore)
+	case graph.Wrapper:
+		// the failover guard
+		return walOf(qs.Unwrapped())
+	}
+	return nil
+}
+
+func NewWALCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wal",
+		Short: "Maintenance of the write-ahead log.",
+	}
+	cmd.AddCommand(newWALTruncateCmd())
+	return cmd
+}
+
+func newWALTruncateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "truncate",
+		Short: "Replay pending batches and remove applied batches from the write-ahead log.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if viper.GetString(KeyWAL) == "" {
+				return errors.New("write-ahead log is not configured (" + KeyWAL + ")")
+			}
+			printBackendInfo()
+			h, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer h.Close()
+			ws := walOf(h.QuadStore)
+			if ws == nil {
+				return errors.New("write-ahead log is not used by the database")
+			}
+			before, err := ws.Log().Size()
+			if err != nil {
+				return err
+			}
+			if err = ws.Truncate(); err != nil {
+				return err
+			}
+			after, err := ws.Log().Size()
+			if err != nil {
+				return err
+			}
+			clog.Infof("truncated write-ahead log from %d to %d bytes", before, after)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/warmup"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	KeyWarmupTop        = "warmup.top_predicates"
+	KeyWarmupPredicates = "warmup.predicates"
+	KeyWarmupSample     = "warmup.sample"
+	KeyWarmupQuads      = "warmup.quads"
+	KeyWarmupQueries    = "warmup.queries"
+	KeyWarmupTimeout    = "warmup.timeout"
+)
+
+// warmUp primes caches of the quad store as set in the config, if any. Failed queries are logged,
+// so a broken warm-up query does not prevent the server from starting.
+func warmUp(ctx context.Context, qs graph.QuadStore) error {
+	opts := warmup.Options{
+		Top:    viper.GetInt(KeyWarmupTop),
+		Sample: viper.GetInt(KeyWarmupSample),
+		Quads:  viper.GetInt(KeyWarmupQuads),
+	}
+	for _, p := range viper.GetStringSlice(KeyWarmupPredicates) {
+		opts.Predicates = append(opts.Predicates, quad.StringToValue(p))
+	}
+	if err := decodeJSONKey(KeyWarmupQueries, &opts.Queries); err != nil {
+		return err
+	}
+	if opts.Top <= 0 && len(opts.Predicates) == 0 && len(opts.Queries) == 0 {
+		return nil
+	}
+	if d := viper.GetDuration(KeyWarmupTimeout); d > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	clog.Infof("warming up caches")
+	start := time.Now()
+	st, err := warmup.Run(ctx, qs, opts)
+	if ctx.Err() == context.DeadlineExceeded {
+		clog.Warningf("warm-up timed out after %v", time.Since(start))
+		return nil
+	} else if st == nil {
+		return err
+	} else if err != nil {
+		clog.Warningf("%v", err)
+	}
+	clog.Infof("warmed up %d predicates (%d quads) and %d queries (%d results) in %v",
+		st.Predicates, st.Quads, st.Queries, st.Results, time.Since(start))
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package acl restricts access of principals to named graphs.
+//
+// An access list binds principals, directly or through roles, to permissions to read or write specific graphs.
+// A quad store restricted to a scope of a principal only scans labels of readable graphs, so quads of other graphs
+// are never returned by queries, and rejects deltas that change graphs that are not writable.
+package acl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	// Anyone is a principal that matches all clients, including anonymous ones.
+	Anyone = "*"
+	// All is a graph name that matches all graphs, including the default graph of quads without a label.
+	All = "*"
+)
+
+// ErrForbidden is returned when a principal changes a graph it may not write to.
+var ErrForbidden = errors.New("acl: access to the graph is denied")
+
+// Rule grants permissions on graphs to principals and to all principals with given roles.
+type Rule struct {
+	Principals []string `json:"principals,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	// Graphs are labels of the graphs, parsed with quad.StringToValue, or All.
+	Graphs []string `json:"graphs"`
+	Read   bool     `json:"read,omitempty"`
+	Write  bool     `json:"write,omitempty"`
+}
+
+func (r Rule) matches(principal string, roles map[string]struct{}) bool {
+	if hasPrincipal(r.Principals, principal) {
+		return true
+	}
+	for _, role := range r.Roles {
+		if _, ok := roles[role]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrincipal(list []string, principal string) bool {
+	for _, p := range list {
+		if p == Anyone || p == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named group of principals.
+type Role struct {
+	Name       string   `json:"name"`
+	Principals []string `json:"principals"`
+}
+
+// ACL is a list of rules. Permissions of all matching rules are combined, and nothing is allowed by default.
+type ACL struct {
+	Roles []Role `json:"roles,omitempty"`
+	Rules []Rule `json:"rules"`
+}
+
+// Validate checks that each rule applies to someone and grants something.
+func (a *ACL) Validate() error {
+	for i, r := range a.Rules {
+		switch {
+		case len(r.Principals) == 0 && len(r.Roles) == 0:
+			return fmt.Errorf("acl: rule %d has no principals or roles", i)
+		case len(r.Graphs) == 0:
+			return fmt.Errorf("acl: rule %d has no graphs", i)
+		case !r.Read && !r.Write:
+			return fmt.Errorf("acl: rule %d grants no permissions", i)
+		}
+	}
+	return nil
+}
+
+// Scope returns graphs a principal may read and write. An anonymous client has an empty principal.
+func (a *ACL) Scope(principal string) *Scope {
+	s := &Scope{}
+	roles := make(map[string]struct{})
+	for _, r := range a.Roles {
+		if hasPrincipal(r.Principals, principal) {
+			roles[r.Name] = struct{}{}
+		}
+	}
+	for _, r := range a.Rules {
+		if !r.matches(principal, roles) {
+			continue
+		}
+		if r.Read {
+			s.read.add(r.Graphs)
+		}
+		if r.Write {
+			s.write.add(r.Graphs)
+		}
+	}
+	return s
+}
+
+type graphSet struct {
+	all    bool
+	labels map[string]quad.Value
+}
+
+func (g *graphSet) add(names []string) {
+	for _, name := range names {
+		if name == All {
+			g.all = true
+			continue
+		}
+		if g.labels == nil {
+			g.labels = make(map[string]quad.Value)
+		}
+		v := quad.StringToValue(name)
+		g.labels[v.String()] = v
+	}
+}
+
+func (g *graphSet) has(label quad.Value) bool {
+	if g.all {
+		return true
+	} else if label == nil {
+		return false
+	}
+	_, ok := g.labels[label.String()]
+	return ok
+}
+
+// Scope is a set of graphs a principal may read and write.
+type Scope struct {
+	read, write graphSet
+}
+
+// CanRead checks if a graph with a given label can be read. A nil label is the default graph.
+func (s *Scope) CanRead(label quad.Value) bool {
+	return s.read.has(label)
+}
+
+// CanWrite checks if a graph with a given label can be changed. A nil label is the default graph.
+func (s *Scope) CanWrite(label quad.Value) bool {
+	return s.write.has(label)
+}
+
+// Unrestricted checks if all graphs can be read and written.
+func (s *Scope) Unrestricted() bool {
+	return s.read.all && s.write.all
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func names(t testing.TB, qs graph.QuadStore, p *path.Path) []string {
+	vals, err := p.Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	var out []string
+	for _, v := range vals {
+		out = append(out, v.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+var testACL = &ACL{
+	Roles: []Role{{Name: "editors", Principals: []string{"bob"}}},
+	Rules: []Rule{
+		{Principals: []string{"alice"}, Graphs: []string{"<g1>"}, Read: true},
+		{Roles: []string{"editors"}, Graphs: []string{"<g1>", "<g2>"}, Read: true, Write: true},
+		{Principals: []string{"root"}, Graphs: []string{All}, Read: true, Write: true},
+	},
+}
+
+func TestScope(t *testing.T) {
+	require.NoError(t, testACL.Validate())
+	require.Error(t, (&ACL{Rules: []Rule{{Principals: []string{Anyone}, Graphs: []string{All}}}}).Validate())
+
+	alice := testACL.Scope("alice")
+	require.True(t, alice.CanRead(quad.IRI("g1")))
+	require.False(t, alice.CanWrite(quad.IRI("g1")))
+	require.False(t, alice.CanRead(quad.IRI("g2")))
+	require.False(t, alice.CanRead(nil))
+
+	bob := testACL.Scope("bob")
+	require.True(t, bob.CanWrite(quad.IRI("g2")))
+	require.False(t, bob.Unrestricted())
+
+	require.True(t, testACL.Scope("root").Unrestricted())
+	require.False(t, testACL.Scope("").CanRead(quad.IRI("g1")))
+}
+
+func TestRestrict(t *testing.T) {
+	base := memstore.New(
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g2"),
+		quad.MakeIRI("a", "knows", "d", "g3"),
+		quad.MakeIRI("a", "knows", "e", ""),
+	)
+	require.Equal(t, graph.QuadStore(base), Restrict(base, testACL.Scope("root")))
+
+	alice := Restrict(base, testACL.Scope("alice"))
+	require.Equal(t, int64(1), alice.Size())
+	require.Equal(t, []string{"<b>"}, names(t, alice, path.StartPath(alice, quad.IRI("a")).Out(quad.IRI("knows"))))
+	require.Equal(t, []string{"<a>", "<b>", "<g1>", "<knows>"}, names(t, alice, path.StartPath(alice)))
+	require.Empty(t, names(t, alice, path.StartPath(alice, quad.IRI("b")).Out(quad.IRI("knows"))))
+	err := alice.ApplyDeltas([]graph.Delta{{Quad: quad.MakeIRI("a", "knows", "c", "g1"), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.Equal(t, ErrForbidden, err)
+
+	bob := Restrict(base, testACL.Scope("bob"))
+	require.Equal(t, []string{"<b>", "<c>"}, names(t, bob, path.StartPath(bob, quad.IRI("a"), quad.IRI("b")).Out(quad.IRI("knows"))))
+	require.NoError(t, bob.ApplyDeltas([]graph.Delta{{Quad: quad.MakeIRI("a", "knows", "c", "g2"), Action: graph.Add}}, graph.IgnoreOpts{}))
+	// all deltas are rejected if one of them is out of scope
+	err = bob.ApplyDeltas([]graph.Delta{
+		{Quad: quad.MakeIRI("c", "knows", "a", "g2"), Action: graph.Add},
+		{Quad: quad.MakeIRI("c", "knows", "a", ""), Action: graph.Add},
+	}, graph.IgnoreOpts{})
+	require.Equal(t, ErrForbidden, err)
+	require.Empty(t, names(t, base, path.StartPath(base, quad.IRI("c")).Out(quad.IRI("knows"))))
+
+	anon := Restrict(base, testACL.Scope(""))
+	require.Empty(t, names(t, anon, path.StartPath(anon)))
+}
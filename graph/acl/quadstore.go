@@ -0,0 +1,165 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+var _ graph.Wrapper = (*QuadStore)(nil)
+
+// QuadStore is a view of graphs of the underlying quad store that are accessible in a scope.
+type QuadStore struct {
+	graph.QuadStore
+	scope *Scope
+}
+
+// Restrict limits reads and writes of a quad store to a scope.
+// If the scope is nil or unrestricted, the quad store is returned as is.
+func Restrict(qs graph.QuadStore, s *Scope) graph.QuadStore {
+	if s == nil || s.Unrestricted() {
+		return qs
+	}
+	return &QuadStore{QuadStore: qs, scope: s}
+}
+
+// Scope returns the scope of the view.
+func (qs *QuadStore) Scope() *Scope {
+	return qs.scope
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// snapshot is a view of a snapshot of the underlying quad store.
+type snapshot struct {
+	*QuadStore
+	snap graph.Snapshot
+}
+
+func (s *snapshot) Horizon() int64 {
+	return s.snap.Horizon()
+}
+
+// WrapSnapshot restricts a snapshot of the underlying quad store to the same scope.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return &snapshot{QuadStore: &QuadStore{QuadStore: s, scope: qs.scope}, snap: s}, nil
+}
+
+// quads returns quads of all readable graphs. Labels are resolved on each call,
+// since graphs may not exist until the first write.
+func (qs *QuadStore) quads() graph.Iterator {
+	if qs.scope.read.all {
+		return qs.QuadStore.QuadsAllIterator()
+	}
+	keys := make([]string, 0, len(qs.scope.read.labels))
+	for k := range qs.scope.read.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var its []graph.Iterator
+	for _, k := range keys {
+		if ref := qs.QuadStore.ValueOf(qs.scope.read.labels[k]); ref != nil {
+			its = append(its, qs.QuadStore.QuadIterator(quad.Label, ref))
+		}
+	}
+	switch len(its) {
+	case 0:
+		return iterator.NewNull()
+	case 1:
+		return its[0]
+	}
+	return iterator.NewOr(its...)
+}
+
+func (qs *QuadStore) QuadIterator(d quad.Direction, v graph.Value) graph.Iterator {
+	if qs.scope.read.all {
+		return qs.QuadStore.QuadIterator(d, v)
+	} else if d == quad.Label {
+		if !qs.scope.CanRead(qs.QuadStore.NameOf(v)) {
+			return iterator.NewNull()
+		}
+		return qs.QuadStore.QuadIterator(d, v)
+	}
+	return iterator.NewAnd(qs, qs.QuadStore.QuadIterator(d, v), qs.quads())
+}
+
+func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
+	return qs.quads()
+}
+
+// NodesAllIterator returns all nodes of quads in readable graphs, including their labels.
+func (qs *QuadStore) NodesAllIterator() graph.Iterator {
+	if qs.scope.read.all {
+		return qs.QuadStore.NodesAllIterator()
+	}
+	its := make([]graph.Iterator, 0, len(quad.Directions))
+	for _, d := range quad.Directions {
+		its = append(its, iterator.NewHasA(qs, qs.quads(), d))
+	}
+	return iterator.NewUnique(iterator.NewOr(its...))
+}
+
+// Size returns the number of quads in readable graphs, which might be an estimate.
+func (qs *QuadStore) Size() int64 {
+	if qs.scope.read.all {
+		return qs.QuadStore.Size()
+	}
+	it := qs.quads()
+	defer it.Close()
+	sz, _ := it.Size()
+	return sz
+}
+
+// OptimizeIterator doesn't let the underlying quad store optimize iterators if reads are restricted,
+// since it would replace quad iterators of the view with its own.
+func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
+	if qs.scope.read.all {
+		return qs.QuadStore.OptimizeIterator(it)
+	}
+	return it, false
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf rejects all deltas with ErrForbidden if any of them changes a graph that is not writable,
+// or if any of preconditions checks a graph that is not readable.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	for _, d := range deltas {
+		if !qs.scope.CanWrite(d.Quad.Label) {
+			return ErrForbidden
+		}
+	}
+	for _, c := range conds {
+		if !qs.scope.CanRead(c.Quad.Label) {
+			return ErrForbidden
+		}
+	}
+	if len(conds) == 0 {
+		return qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		return ca.ApplyDeltasIf(conds, deltas, opts)
+	}
+	return graph.ErrPreconditionsNotSupported
+}
@@ -0,0 +1,220 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func edges(pred string, pairs ...string) []quad.Quad {
+	var out []quad.Quad
+	for i := 0; i+1 < len(pairs); i += 2 {
+		out = append(out, quad.MakeIRI(pairs[i], pred, pairs[i+1], ""))
+	}
+	return out
+}
+
+func names(g *Graph, idx []int) []quad.Value {
+	out := make([]quad.Value, 0, len(idx))
+	for _, i := range idx {
+		out = append(out, g.NameOf(i))
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	quads := edges("follows", "a", "b", "a", "c", "b", "c")
+	quads = append(quads, quad.MakeIRI("a", "likes", "c", ""))
+	quads = append(quads, quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("A"), nil))
+	qs := memstore.New(quads...)
+	ctx := context.TODO()
+
+	g, err := Load(ctx, qs)
+	require.NoError(t, err)
+	require.Equal(t, 3, g.Len())
+	require.Equal(t, 3, g.Edges(), "literals and duplicate links must be skipped")
+
+	g, err = Load(ctx, qs, quad.IRI("likes"))
+	require.NoError(t, err)
+	require.Equal(t, 2, g.Len())
+	require.Equal(t, 1, g.Edges())
+	a := g.Index(qs.ValueOf(quad.IRI("a")))
+	require.Equal(t, []quad.Value{quad.IRI("c")}, names(g, []int{int(g.Out(a)[0])}))
+	require.Equal(t, -1, g.Index(qs.ValueOf(quad.IRI("b"))))
+}
+
+func TestPageRank(t *testing.T) {
+	// c is linked by everyone, d links to nothing
+	qs := memstore.New(edges("links", "a", "c", "b", "c", "d", "c", "c", "a", "a", "b", "e", "d")...)
+	g, err := Load(context.TODO(), qs)
+	require.NoError(t, err)
+	ranks, iter, err := g.PageRank(context.TODO(), PageRankOptions{})
+	require.NoError(t, err)
+	require.True(t, iter > 1 && iter < DefaultIterations, "%d", iter)
+	sum := 0.0
+	for _, r := range ranks {
+		sum += r
+	}
+	require.InDelta(t, 1.0, sum, 1e-6)
+	top := names(g, Top(ranks, 2))
+	require.Equal(t, []quad.Value{quad.IRI("c"), quad.IRI("a")}, top)
+
+	n, err := g.Save(quad.IRI("rank"), func(i int) quad.Value {
+		return quad.Float(ranks[i])
+	})
+	require.NoError(t, err)
+	require.Equal(t, g.Len(), n)
+	// saving again replaces old values
+	_, err = g.Save(quad.IRI("rank"), func(i int) quad.Value {
+		return quad.Int(i)
+	})
+	require.NoError(t, err)
+	it := qs.QuadIterator(quad.Predicate, qs.ValueOf(quad.IRI("rank")))
+	defer it.Close()
+	cnt := 0
+	for it.Next(context.TODO()) {
+		q := qs.Quad(it.Result())
+		_, ok := q.Object.(quad.Int)
+		require.True(t, ok, "%v", q)
+		cnt++
+	}
+	require.Equal(t, g.Len(), cnt)
+}
+
+func TestComponents(t *testing.T) {
+	// a <-> b -> c -> d -> c; e -> f; g alone with a self-loop
+	quads := edges("links", "a", "b", "b", "a", "b", "c", "c", "d", "d", "c", "e", "f", "g", "g")
+	qs := memstore.New(quads...)
+	g, err := Load(context.TODO(), qs)
+	require.NoError(t, err)
+	byName := func(c *Components) map[string]int {
+		m := make(map[string]int)
+		for i, id := range c.ID {
+			m[string(g.NameOf(i).(quad.IRI))] = id
+		}
+		return m
+	}
+
+	c, err := g.WeakComponents(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, 3, c.Len())
+	require.Equal(t, []int{4, 2, 1}, c.Sizes)
+	ids := byName(c)
+	require.Equal(t, map[string]int{"a": 0, "b": 0, "c": 0, "d": 0, "e": 1, "f": 1, "g": 2}, ids)
+
+	c, err = g.StrongComponents(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, 5, c.Len())
+	ids = byName(c)
+	require.Equal(t, ids["a"], ids["b"])
+	require.Equal(t, ids["c"], ids["d"])
+	require.NotEqual(t, ids["a"], ids["c"])
+	require.NotEqual(t, ids["e"], ids["f"])
+	sum := 0
+	for _, s := range c.Sizes {
+		sum += s
+	}
+	require.Equal(t, g.Len(), sum)
+}
+
+func TestCentrality(t *testing.T) {
+	// a star with the center c, and a path c - x - y
+	quads := edges("links", "a", "c", "b", "c", "d", "c", "c", "x", "x", "y")
+	g, err := Load(context.TODO(), memstore.New(quads...))
+	require.NoError(t, err)
+	idx := func(name string) int {
+		for i := 0; i < g.Len(); i++ {
+			if g.NameOf(i) == quad.IRI(name) {
+				return i
+			}
+		}
+		t.Fatalf("no node %q", name)
+		return -1
+	}
+	c, x, y, a := idx("c"), idx("x"), idx("y"), idx("a")
+
+	deg := g.Degree(Both)
+	require.Equal(t, 4.0, deg[c])
+	require.Equal(t, 1.0, g.Degree(Out)[c])
+	require.Equal(t, 3.0, g.Degree(In)[c])
+
+	cl, err := g.Closeness(context.TODO(), CentralityOptions{})
+	require.NoError(t, err)
+	// c reaches a, b, d, x in one step and y in two
+	require.InDelta(t, 5.0/6, cl[c], 1e-9)
+	require.Equal(t, c, Top(cl, 1)[0])
+	cl, err = g.Closeness(context.TODO(), CentralityOptions{Direction: Out})
+	require.NoError(t, err)
+	require.Equal(t, 0.0, cl[y], "y links to nothing")
+	// a reaches c, x, y in 1+2+3 steps; 3 of 5 other nodes are reachable
+	require.InDelta(t, 3.0/5*3.0/6, cl[a], 1e-9)
+
+	bc, err := g.Betweenness(context.TODO(), CentralityOptions{})
+	require.NoError(t, err)
+	// paths through c: between any two of a, b, d (3), and from each of them to x and y (6)
+	require.InDelta(t, 9.0, bc[c], 1e-9)
+	require.InDelta(t, 4.0, bc[x], 1e-9)
+	require.InDelta(t, 0.0, bc[a], 1e-9)
+
+	bd, err := g.Betweenness(context.TODO(), CentralityOptions{Direction: Out})
+	require.NoError(t, err)
+	require.InDelta(t, 6.0, bd[c], 1e-9)
+
+	// sampling all but one node keeps the order of the most central nodes
+	bs, err := g.Betweenness(context.TODO(), CentralityOptions{Samples: g.Len() - 1, Seed: 1, Workers: 2})
+	require.NoError(t, err)
+	require.Equal(t, c, Top(bs, 1)[0])
+}
+
+func TestTriangles(t *testing.T) {
+	// two triangles sharing the a-b edge, linked in different directions; d is outside of them
+	quads := edges("links", "a", "b", "b", "c", "c", "a", "a", "x", "x", "b", "b", "a", "c", "d", "d", "d")
+	g, err := Load(context.TODO(), memstore.New(quads...))
+	require.NoError(t, err)
+	cnt, total, err := g.Triangles(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	byName := make(map[quad.Value]int64)
+	for i, n := range cnt {
+		byName[g.NameOf(i)] = n
+	}
+	require.Equal(t, map[quad.Value]int64{
+		quad.IRI("a"): 2, quad.IRI("b"): 2, quad.IRI("c"): 1, quad.IRI("x"): 1, quad.IRI("d"): 0,
+	}, byName)
+
+	cc, avg, err := g.Clustering(context.TODO())
+	require.NoError(t, err)
+	for i, v := range cc {
+		switch g.NameOf(i) {
+		case quad.IRI("a"), quad.IRI("b"):
+			// neighbors: two of the others and each other; 2 of 3 pairs are linked
+			require.InDelta(t, 2.0/3, v, 1e-9)
+		case quad.IRI("c"):
+			// neighbors a, b and d
+			require.InDelta(t, 1.0/3, v, 1e-9)
+		case quad.IRI("x"):
+			require.InDelta(t, 1.0, v, 1e-9)
+		case quad.IRI("d"):
+			require.Equal(t, 0.0, v, "self-loops are ignored")
+		}
+	}
+	require.InDelta(t, (2.0/3*2+1.0/3+1)/5, avg, 1e-9)
+}
@@ -0,0 +1,291 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// Direction selects edges an algorithm follows.
+type Direction int
+
+const (
+	// Both ignores the direction of edges.
+	Both Direction = iota
+	// Out follows edges from the subject to the object.
+	Out
+	// In follows edges from the object to the subject.
+	In
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Both:
+		return "both"
+	case Out:
+		return "out"
+	case In:
+		return "in"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// ParseDirection parses a direction name: "both", "out" or "in".
+func ParseDirection(s string) (Direction, error) {
+	for _, d := range []Direction{Both, Out, In} {
+		if d.String() == s {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("algo: unknown direction: %q", s)
+}
+
+// adjacency returns neighbors of each node in a given direction.
+func (g *Graph) adjacency(dir Direction) [][]int32 {
+	switch dir {
+	case Out:
+		return g.out
+	case In:
+		return g.in
+	}
+	adj := make([][]int32, len(g.nodes))
+	for i := range adj {
+		out, in := g.out[i], g.in[i]
+		// merge sorted lists, so nodes linked in both directions are a single neighbor
+		nb := make([]int32, 0, len(out)+len(in))
+		for len(out) != 0 || len(in) != 0 {
+			switch {
+			case len(in) == 0 || (len(out) != 0 && out[0] < in[0]):
+				nb, out = append(nb, out[0]), out[1:]
+			case len(out) == 0 || in[0] < out[0]:
+				nb, in = append(nb, in[0]), in[1:]
+			default:
+				nb, out, in = append(nb, out[0]), out[1:], in[1:]
+			}
+		}
+		adj[i] = nb
+	}
+	return adj
+}
+
+// Degree returns the number of neighbors of each node in a given direction.
+func (g *Graph) Degree(dir Direction) []float64 {
+	deg := make([]float64, len(g.nodes))
+	for i, nb := range g.adjacency(dir) {
+		deg[i] = float64(len(nb))
+	}
+	return deg
+}
+
+// CentralityOptions configures Closeness and Betweenness.
+type CentralityOptions struct {
+	// Direction of edges to follow. Default is Both.
+	Direction Direction
+	// Samples is the number of randomly chosen source nodes Betweenness starts paths from.
+	// All nodes are used if it's zero or larger than the number of nodes.
+	Samples int
+	// Seed for choosing the samples.
+	Seed int64
+	// Workers is the number of goroutines to use. Default is the number of CPUs.
+	Workers int
+}
+
+// parallel calls fn for each source node on a given number of goroutines.
+// Fn gets the index of the worker, so it can reuse the state of previous calls in the same goroutine.
+func parallel(ctx context.Context, workers int, sources []int, fn func(w, src int)) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		next int
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				mu.Lock()
+				i := next
+				next++
+				mu.Unlock()
+				if i >= len(sources) {
+					return
+				}
+				fn(w, sources[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// workers returns the number of goroutines to use for n source nodes.
+func workers(opts CentralityOptions, n int) int {
+	w := opts.Workers
+	if w <= 0 {
+		w = runtime.NumCPU()
+	}
+	if w > n {
+		w = n
+	}
+	if w < 1 {
+		w = 1
+	}
+	return w
+}
+
+func allNodes(n int) []int {
+	nodes := make([]int, n)
+	for i := range nodes {
+		nodes[i] = i
+	}
+	return nodes
+}
+
+// Closeness computes the closeness centrality of each node: how close the node is to all other nodes
+// reachable from it. For disconnected graphs the Wasserman-Faust normalization is used: the inverse of the average
+// distance to reachable nodes, scaled by the fraction of nodes that are reachable.
+//
+// Distances from every node are found, thus the cost is proportional to the number of nodes times the number of edges.
+func (g *Graph) Closeness(ctx context.Context, opts CentralityOptions) ([]float64, error) {
+	n := len(g.nodes)
+	adj := g.adjacency(opts.Direction)
+	res := make([]float64, n)
+	nw := workers(opts, n)
+	dists := make([][]int32, nw)
+	queues := make([][]int32, nw)
+	err := parallel(ctx, nw, allNodes(n), func(w, src int) {
+		dist := dists[w]
+		if dist == nil {
+			dist = make([]int32, n)
+			for i := range dist {
+				dist[i] = -1
+			}
+			dists[w] = dist
+		}
+		queue := append(queues[w][:0], int32(src))
+		dist[src] = 0
+		sum := 0
+		for i := 0; i < len(queue); i++ {
+			v := queue[i]
+			for _, u := range adj[v] {
+				if dist[u] < 0 {
+					dist[u] = dist[v] + 1
+					sum += int(dist[u])
+					queue = append(queue, u)
+				}
+			}
+		}
+		if r := len(queue) - 1; r > 0 && n > 1 {
+			res[src] = float64(r) / float64(n-1) * float64(r) / float64(sum)
+		}
+		for _, v := range queue {
+			dist[v] = -1
+		}
+		queues[w] = queue
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Betweenness computes the betweenness centrality of each node: the number of shortest paths between
+// other nodes that pass through it. When direction is ignored, each path is counted once.
+//
+// Exact computation makes a search from each node. Set Samples to estimate betweenness from paths that
+// start at a random subset of nodes; values are scaled up to estimate the total for all nodes.
+func (g *Graph) Betweenness(ctx context.Context, opts CentralityOptions) ([]float64, error) {
+	n := len(g.nodes)
+	adj := g.adjacency(opts.Direction)
+	sources := allNodes(n)
+	if k := opts.Samples; k > 0 && k < n {
+		rnd := rand.New(rand.NewSource(opts.Seed))
+		rnd.Shuffle(n, func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+		sources = sources[:k]
+	}
+	type state struct {
+		res   []float64
+		sigma []float64
+		delta []float64
+		dist  []int32
+		order []int32
+		preds [][]int32
+	}
+	nw := workers(opts, n)
+	states := make([]*state, nw)
+	// Brandes' algorithm
+	err := parallel(ctx, nw, sources, func(w, src int) {
+		st := states[w]
+		if st == nil {
+			st = &state{
+				res: make([]float64, n), sigma: make([]float64, n), delta: make([]float64, n),
+				dist: make([]int32, n), preds: make([][]int32, n),
+			}
+			for i := range st.dist {
+				st.dist[i] = -1
+			}
+			states[w] = st
+		}
+		st.order = append(st.order[:0], int32(src))
+		st.dist[src], st.sigma[src] = 0, 1
+		for i := 0; i < len(st.order); i++ {
+			v := st.order[i]
+			for _, u := range adj[v] {
+				if st.dist[u] < 0 {
+					st.dist[u] = st.dist[v] + 1
+					st.order = append(st.order, u)
+				}
+				if st.dist[u] == st.dist[v]+1 {
+					st.sigma[u] += st.sigma[v]
+					st.preds[u] = append(st.preds[u], v)
+				}
+			}
+		}
+		for i := len(st.order) - 1; i >= 0; i-- {
+			u := st.order[i]
+			for _, v := range st.preds[u] {
+				st.delta[v] += st.sigma[v] / st.sigma[u] * (1 + st.delta[u])
+			}
+			if int(u) != src {
+				st.res[u] += st.delta[u]
+			}
+		}
+		for _, v := range st.order {
+			st.dist[v], st.sigma[v], st.delta[v] = -1, 0, 0
+			st.preds[v] = st.preds[v][:0]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]float64, n)
+	scale := float64(n) / float64(len(sources))
+	if opts.Direction == Both {
+		scale /= 2 // each path is found from both ends
+	}
+	for _, st := range states {
+		if st == nil {
+			continue
+		}
+		for i, v := range st.res {
+			res[i] += v * scale
+		}
+	}
+	return res, nil
+}
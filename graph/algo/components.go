@@ -0,0 +1,160 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import "context"
+
+// Components is a partition of nodes of the graph into components.
+type Components struct {
+	// ID is the component of each node. Components are numbered from zero,
+	// in the order of the first node of each component.
+	ID []int
+	// Sizes is the number of nodes in each component.
+	Sizes []int
+}
+
+// Len returns the number of components.
+func (c *Components) Len() int { return len(c.Sizes) }
+
+// number assigns ids to components, given a representative node of each node.
+func number(repr []int) *Components {
+	c := &Components{ID: make([]int, len(repr))}
+	ids := make(map[int]int)
+	for i, r := range repr {
+		id, ok := ids[r]
+		if !ok {
+			id = len(c.Sizes)
+			ids[r] = id
+			c.Sizes = append(c.Sizes, 0)
+		}
+		c.ID[i] = id
+		c.Sizes[id]++
+	}
+	return c
+}
+
+// WeakComponents finds weakly connected components: sets of nodes that are linked
+// to each other when the direction of edges is ignored.
+func (g *Graph) WeakComponents(ctx context.Context) (*Components, error) {
+	parent := make([]int, len(g.nodes))
+	size := make([]int, len(g.nodes))
+	for i := range parent {
+		parent[i], size[i] = i, 1
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	for i, out := range g.out {
+		if i%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		for _, j := range out {
+			a, b := find(i), find(int(j))
+			if a == b {
+				continue
+			}
+			if size[a] < size[b] {
+				a, b = b, a
+			}
+			parent[b] = a
+			size[a] += size[b]
+		}
+	}
+	for i := range parent {
+		parent[i] = find(i)
+	}
+	return number(parent), nil
+}
+
+// StrongComponents finds strongly connected components: sets of nodes where each node
+// is reachable from any other node by following edges.
+func (g *Graph) StrongComponents(ctx context.Context) (*Components, error) {
+	// Tarjan's algorithm with an explicit stack, so deep graphs don't overflow the goroutine stack
+	const unvisited = -1
+	n := len(g.nodes)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	repr := make([]int, n)
+	for i := range index {
+		index[i] = unvisited
+	}
+	type frame struct {
+		node, edge int
+	}
+	var (
+		next  int
+		stack []int
+		calls []frame
+	)
+	for root := 0; root < n; root++ {
+		if index[root] != unvisited {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		calls = append(calls, frame{node: root})
+		for len(calls) != 0 {
+			f := &calls[len(calls)-1]
+			v := f.node
+			if f.edge == 0 {
+				index[v], low[v] = next, next
+				next++
+				stack = append(stack, v)
+				onStack[v] = true
+			}
+			recursed := false
+			for f.edge < len(g.out[v]) {
+				w := int(g.out[v][f.edge])
+				f.edge++
+				if index[w] == unvisited {
+					calls = append(calls, frame{node: w})
+					recursed = true
+					break
+				} else if onStack[w] && index[w] < low[v] {
+					low[v] = index[w]
+				}
+			}
+			if recursed {
+				continue
+			}
+			if low[v] == index[v] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					repr[w] = v
+					if w == v {
+						break
+					}
+				}
+			}
+			calls = calls[:len(calls)-1]
+			if len(calls) != 0 {
+				if u := calls[len(calls)-1].node; low[v] < low[u] {
+					low[u] = low[v]
+				}
+			}
+		}
+	}
+	return number(repr), nil
+}
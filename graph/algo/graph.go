@@ -0,0 +1,210 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package algo implements graph algorithms that run over all nodes of a quad store.
+//
+// Algorithms work on a Graph: a compact in-memory copy of links between nodes, loaded
+// from the quad store once. Results are computed for each node of the graph and can be
+// written back to the quad store as quads.
+package algo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Graph is a directed graph of nodes linked by quads. Nodes are indexed from 0 to Len()-1.
+type Graph struct {
+	qs    graph.QuadStore
+	nodes []graph.Value
+	ids   map[interface{}]int32
+	out   [][]int32
+	in    [][]int32
+	edges int
+}
+
+// Load builds a graph from quads with given predicates, or from all quads if none are given.
+//
+// Each quad is an edge from the subject to the object. Quads with literal objects (strings, numbers, etc)
+// are skipped, as well as nodes that have no edges. Multiple quads linking the same nodes are a single edge.
+func Load(ctx context.Context, qs graph.QuadStore, preds ...quad.Value) (*Graph, error) {
+	g := &Graph{qs: qs, ids: make(map[interface{}]int32)}
+	isNode := make(map[interface{}]bool)
+	add := func(it graph.Iterator) error {
+		defer it.Close()
+		for it.Next(ctx) {
+			q := it.Result()
+			o := qs.QuadDirection(q, quad.Object)
+			k := graph.ToKey(o)
+			ok, known := isNode[k]
+			if !known {
+				switch qs.NameOf(o).(type) {
+				case quad.IRI, quad.BNode:
+					ok = true
+				}
+				isNode[k] = ok
+			}
+			if !ok {
+				continue
+			}
+			s, oi := g.id(qs.QuadDirection(q, quad.Subject)), g.id(o)
+			g.out[s] = append(g.out[s], oi)
+		}
+		return it.Err()
+	}
+	if len(preds) == 0 {
+		if err := add(qs.QuadsAllIterator()); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range preds {
+		pv := qs.ValueOf(p)
+		if pv == nil {
+			continue
+		}
+		if err := add(qs.QuadIterator(quad.Predicate, pv)); err != nil {
+			return nil, err
+		}
+	}
+	g.in = make([][]int32, len(g.nodes))
+	for i, out := range g.out {
+		out = uniq(out)
+		g.out[i] = out
+		g.edges += len(out)
+		for _, j := range out {
+			g.in[j] = append(g.in[j], int32(i))
+		}
+	}
+	return g, nil
+}
+
+func (g *Graph) id(v graph.Value) int32 {
+	k := graph.ToKey(v)
+	if i, ok := g.ids[k]; ok {
+		return i
+	}
+	i := int32(len(g.nodes))
+	g.ids[k] = i
+	g.nodes = append(g.nodes, v)
+	g.out = append(g.out, nil)
+	return i
+}
+
+// uniq sorts ids and removes duplicates.
+func uniq(ids []int32) []int32 {
+	if len(ids) < 2 {
+		return ids
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	n := 1
+	for _, id := range ids[1:] {
+		if id != ids[n-1] {
+			ids[n] = id
+			n++
+		}
+	}
+	return ids[:n]
+}
+
+// Len returns the number of nodes.
+func (g *Graph) Len() int { return len(g.nodes) }
+
+// Edges returns the number of edges.
+func (g *Graph) Edges() int { return g.edges }
+
+// Node returns a quad store value of the node with a given index.
+func (g *Graph) Node(i int) graph.Value { return g.nodes[i] }
+
+// NameOf returns the value of the node with a given index.
+func (g *Graph) NameOf(i int) quad.Value { return g.qs.NameOf(g.nodes[i]) }
+
+// Index returns the index of a node, or -1 if it's not in the graph.
+func (g *Graph) Index(v graph.Value) int {
+	if i, ok := g.ids[graph.ToKey(v)]; ok {
+		return int(i)
+	}
+	return -1
+}
+
+// Out returns sorted indexes of nodes the node links to. The slice must not be modified.
+func (g *Graph) Out(i int) []int32 { return g.out[i] }
+
+// In returns sorted indexes of nodes that link to the node. The slice must not be modified.
+func (g *Graph) In(i int) []int32 { return g.in[i] }
+
+// Top returns indexes of the nodes with the largest values, in descending order of values.
+func Top(vals []float64, n int) []int {
+	idx := make([]int, len(vals))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return vals[idx[i]] > vals[idx[j]] })
+	if n >= 0 && n < len(idx) {
+		idx = idx[:n]
+	}
+	return idx
+}
+
+// Save replaces all quads with a given predicate by quads linking each node to its value:
+//	<node> <pred> value .
+// Nodes with a nil value are skipped.
+//
+// Changes are applied in batches, thus readers might see the old values removed before
+// the new ones are written.
+func (g *Graph) Save(pred quad.Value, value func(i int) quad.Value) (int, error) {
+	var deltas []graph.Delta
+	flush := func(force bool) error {
+		if len(deltas) == 0 || (!force && len(deltas) < quad.DefaultBatch) {
+			return nil
+		}
+		err := g.qs.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true})
+		deltas = nil // wrappers might keep the slice
+		return err
+	}
+	if pv := g.qs.ValueOf(pred); pv != nil {
+		it := g.qs.QuadIterator(quad.Predicate, pv)
+		// collect old quads first, the index should not change while it's iterated
+		var old []quad.Quad
+		for it.Next(context.TODO()) {
+			old = append(old, g.qs.Quad(it.Result()))
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return 0, err
+		}
+		for _, q := range old {
+			deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Delete})
+			if err := flush(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	n := 0
+	for i := range g.nodes {
+		v := value(i)
+		if v == nil {
+			continue
+		}
+		n++
+		deltas = append(deltas, graph.Delta{Quad: quad.Quad{Subject: g.NameOf(i), Predicate: pred, Object: v}, Action: graph.Add})
+		if err := flush(false); err != nil {
+			return 0, err
+		}
+	}
+	return n, flush(true)
+}
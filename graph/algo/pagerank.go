@@ -0,0 +1,90 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import (
+	"context"
+	"math"
+)
+
+const (
+	DefaultDamping    = 0.85
+	DefaultIterations = 100
+	DefaultTolerance  = 1e-6
+)
+
+// PageRankOptions configures PageRank.
+type PageRankOptions struct {
+	// Damping is the probability to follow a link instead of jumping to a random node. Default is DefaultDamping.
+	Damping float64
+	// Iterations is the maximal number of iterations. Default is DefaultIterations.
+	Iterations int
+	// Tolerance stops iterations once the total change of scores gets below it. Default is DefaultTolerance.
+	Tolerance float64
+}
+
+// PageRank computes the PageRank score of each node with the power iteration method.
+// Scores of all nodes sum up to one. The rank of nodes without outgoing edges is spread over all nodes.
+//
+// It returns the scores and the number of iterations made.
+func (g *Graph) PageRank(ctx context.Context, opts PageRankOptions) ([]float64, int, error) {
+	if opts.Damping <= 0 || opts.Damping >= 1 {
+		opts.Damping = DefaultDamping
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = DefaultIterations
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = DefaultTolerance
+	}
+	n := len(g.nodes)
+	if n == 0 {
+		return nil, 0, nil
+	}
+	d := opts.Damping
+	rank := make([]float64, n)
+	next := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	iter := 0
+	for iter < opts.Iterations {
+		if err := ctx.Err(); err != nil {
+			return nil, iter, err
+		}
+		iter++
+		dangling := 0.0
+		for i, out := range g.out {
+			if len(out) == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-d)/float64(n) + d*dangling/float64(n)
+		delta := 0.0
+		for i, in := range g.in {
+			sum := 0.0
+			for _, j := range in {
+				sum += rank[j] / float64(len(g.out[j]))
+			}
+			next[i] = base + d*sum
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < opts.Tolerance {
+			break
+		}
+	}
+	return rank, iter, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package algo
+
+import "context"
+
+// simple returns neighbors of each node ignoring the direction of edges, without self-loops.
+func (g *Graph) simple() [][]int32 {
+	adj := g.adjacency(Both)
+	for i, nb := range adj {
+		for j, v := range nb {
+			if int(v) == i {
+				adj[i] = append(nb[:j:j], nb[j+1:]...)
+				break
+			}
+		}
+	}
+	return adj
+}
+
+// Triangles counts triangles each node is a part of, ignoring the direction of edges.
+// It returns counts for each node and the total number of triangles in the graph.
+func (g *Graph) Triangles(ctx context.Context) ([]int64, int64, error) {
+	return g.triangles(ctx, g.simple())
+}
+
+func (g *Graph) triangles(ctx context.Context, adj [][]int32) ([]int64, int64, error) {
+	cnt := make([]int64, len(adj))
+	var total int64
+	for u, nu := range adj {
+		if u%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+		}
+		// each triangle u < v < w is found once, from its smallest node
+		for _, v := range nu {
+			if int(v) <= u {
+				continue
+			}
+			a, b := nu, adj[v]
+			for len(a) != 0 && len(b) != 0 {
+				switch {
+				case a[0] < b[0]:
+					a = a[1:]
+				case a[0] > b[0]:
+					b = b[1:]
+				default:
+					if w := a[0]; w > v {
+						cnt[u]++
+						cnt[v]++
+						cnt[w]++
+						total++
+					}
+					a, b = a[1:], b[1:]
+				}
+			}
+		}
+	}
+	return cnt, total, nil
+}
+
+// Clustering computes the local clustering coefficient of each node: the fraction of pairs of its neighbors
+// that are linked to each other. The direction of edges is ignored. Nodes with less than two neighbors have zero.
+//
+// It also returns the average coefficient of all nodes.
+func (g *Graph) Clustering(ctx context.Context) ([]float64, float64, error) {
+	adj := g.simple()
+	cnt, _, err := g.triangles(ctx, adj)
+	if err != nil {
+		return nil, 0, err
+	}
+	res := make([]float64, len(adj))
+	sum := 0.0
+	for i, nb := range adj {
+		if d := float64(len(nb)); d >= 2 {
+			res[i] = 2 * float64(cnt[i]) / (d * (d - 1))
+			sum += res[i]
+		}
+	}
+	avg := 0.0
+	if len(res) != 0 {
+		avg = sum / float64(len(res))
+	}
+	return res, avg, nil
+}
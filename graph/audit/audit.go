@@ -0,0 +1,488 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit implements an append-only log of all changes applied to a quad store.
+//
+// Each batch of deltas is recorded as a single JSON line together with the time,
+// an authenticated principal and an id of the request that made the change.
+//
+// Batches are recorded as pending before they are applied, and the outcome is appended once it's known,
+// so the log has an entry for every write, even if the process stops in the middle of it.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+)
+
+var ErrClosed = errors.New("audit: log is closed")
+
+// pruneInterval is the minimal time between automatic removals of expired entries.
+const pruneInterval = time.Hour
+
+// Authentication methods of clients.
+const (
+	AuthNone  = "none"
+	AuthTLS   = "tls"
+	AuthToken = "token"
+)
+
+// Info describes the origin of a change.
+type Info struct {
+	// Principal is an authenticated identity of the client. Empty for anonymous clients.
+	Principal string
+	// Auth is the method used to authenticate the principal: AuthTLS, AuthToken or AuthNone.
+	Auth string
+	// Addr is a network address of the client.
+	Addr string
+	// RequestID is a unique id of the request that made the change.
+	RequestID string
+}
+
+// State is a state of the write recorded by an entry.
+type State string
+
+const (
+	// Committed entries were recorded for writes that were applied.
+	Committed State = "committed"
+	// Pending entries were recorded before the deltas were applied, and the outcome is not known yet.
+	Pending State = "pending"
+	// Interrupted entries were pending when the log was closed without recording the outcome,
+	// for example because the process crashed. Their deltas may or may not be applied.
+	Interrupted State = "interrupted"
+
+	// aborted is the outcome of pending entries that were not applied. Such entries are not reported.
+	aborted State = "aborted"
+)
+
+// Entry is a single batch of deltas recorded in the log.
+type Entry struct {
+	Info
+	// ID identifies entries that were recorded as pending.
+	ID     string
+	State  State
+	Time   time.Time
+	Deltas []graph.Delta
+}
+
+type jsonDelta struct {
+	Action string `json:"action"`
+	Quad   string `json:"quad"`
+}
+
+// jsonEntry is either an entry, or an outcome of a pending entry with a given id.
+type jsonEntry struct {
+	Time      time.Time   `json:"time"`
+	ID        string      `json:"id,omitempty"`
+	State     State       `json:"state,omitempty"`
+	Outcome   State       `json:"outcome,omitempty"`
+	Principal string      `json:"principal,omitempty"`
+	Auth      string      `json:"auth,omitempty"`
+	Addr      string      `json:"addr,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Deltas    []jsonDelta `json:"deltas,omitempty"`
+}
+
+// MarshalJSON encodes quads of the entry in N-Quads format.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	je := jsonEntry{
+		Time: e.Time, ID: e.ID, State: e.State,
+		Principal: e.Principal, Auth: e.Auth, Addr: e.Addr, RequestID: e.RequestID,
+		Deltas: make([]jsonDelta, 0, len(e.Deltas)),
+	}
+	for _, d := range e.Deltas {
+		act := "add"
+		if d.Action == graph.Delete {
+			act = "delete"
+		}
+		je.Deltas = append(je.Deltas, jsonDelta{Action: act, Quad: d.Quad.NQuad()})
+	}
+	return json.Marshal(je)
+}
+
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var je jsonEntry
+	if err := json.Unmarshal(data, &je); err != nil {
+		return err
+	}
+	return je.decode(e)
+}
+
+func (je *jsonEntry) decode(e *Entry) error {
+	*e = Entry{
+		Info:   Info{Principal: je.Principal, Auth: je.Auth, Addr: je.Addr, RequestID: je.RequestID},
+		ID:     je.ID,
+		State:  je.State,
+		Time:   je.Time,
+		Deltas: make([]graph.Delta, 0, len(je.Deltas)),
+	}
+	if e.State == "" {
+		// entries recorded before the states were introduced
+		e.State = Committed
+	}
+	for _, jd := range je.Deltas {
+		var d graph.Delta
+		switch jd.Action {
+		case "add":
+			d.Action = graph.Add
+		case "delete":
+			d.Action = graph.Delete
+		default:
+			return fmt.Errorf("audit: unknown action: %q", jd.Action)
+		}
+		q, err := nquads.Parse(jd.Quad)
+		if err != nil {
+			return err
+		}
+		d.Quad = q
+		e.Deltas = append(e.Deltas, d)
+	}
+	return nil
+}
+
+// Log is a file-based audit log.
+type Log struct {
+	mu        sync.Mutex
+	path      string
+	f         *os.File
+	retention time.Duration
+	pruned    time.Time
+
+	// prefix and last make ids of pending entries unique across reopens of the log
+	prefix string
+	last   uint64
+}
+
+// Open opens or creates an audit log file. If retention is set, entries older than
+// the retention period are removed from the log periodically.
+//
+// Entries left pending by a previous process are marked as interrupted.
+func Open(path string, retention time.Duration) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	l := &Log{path: path, f: f, retention: retention, prefix: strconv.FormatInt(now.UnixNano(), 36)}
+	if retention > 0 {
+		if err = l.prune(now.Add(-retention)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err = l.interrupt(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// interrupt records an interrupted outcome for all entries that are still pending.
+func (l *Log) interrupt() error {
+	var ids []string
+	err := Read(l.path, func(e Entry) error {
+		if e.State == Pending {
+			ids = append(ids, e.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = l.write(jsonEntry{Time: time.Now(), ID: id, Outcome: Interrupted}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write appends a line to the log and syncs it to disk. It must be called with the lock held.
+func (l *Log) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if l.f == nil {
+		return ErrClosed
+	}
+	if _, err = l.f.Write(data); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// Record appends an entry of an applied write to the log and syncs it to disk.
+func (l *Log) Record(e Entry) error {
+	e.ID, e.State = "", Committed
+	_, err := l.record(e)
+	return err
+}
+
+// Begin appends a pending entry to the log before its deltas are applied. The caller must
+// record the outcome by calling Commit or Abort on the result.
+func (l *Log) Begin(e Entry) (*PendingEntry, error) {
+	e.State = Pending
+	id, err := l.record(e)
+	if err != nil {
+		return nil, err
+	}
+	return &PendingEntry{l: l, id: id}, nil
+}
+
+func (l *Log) record(e Entry) (string, error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e.State == Pending {
+		l.last++
+		e.ID = l.prefix + "-" + strconv.FormatUint(l.last, 10)
+	}
+	if err := l.write(e); err != nil {
+		return "", err
+	}
+	if l.retention > 0 && e.Time.Sub(l.pruned) > pruneInterval {
+		return e.ID, l.prune(e.Time.Add(-l.retention))
+	}
+	return e.ID, nil
+}
+
+// PendingEntry is an entry recorded before its deltas were applied.
+type PendingEntry struct {
+	l  *Log
+	id string
+}
+
+// Commit records that the deltas of the entry were applied.
+func (p *PendingEntry) Commit() error {
+	return p.finish(Committed)
+}
+
+// Abort records that the deltas of the entry were not applied. The entry will not be reported.
+func (p *PendingEntry) Abort() error {
+	return p.finish(aborted)
+}
+
+func (p *PendingEntry) finish(st State) error {
+	p.l.mu.Lock()
+	defer p.l.mu.Unlock()
+	return p.l.write(jsonEntry{Time: time.Now(), ID: p.id, Outcome: st})
+}
+
+// Prune removes all entries recorded before a given time.
+func (l *Log) Prune(before time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return ErrClosed
+	}
+	return l.prune(before)
+}
+
+func (l *Log) prune(before time.Time) error {
+	l.pruned = time.Now()
+	tmp, err := os.Create(filepath.Join(filepath.Dir(l.path), "."+filepath.Base(l.path)+".tmp"))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	bw := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(bw)
+	removed := 0
+	err = Read(l.path, func(e Entry) error {
+		if e.Time.Before(before) {
+			removed++
+			return nil
+		}
+		return enc.Encode(e)
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || removed == 0 {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	l.f.Close()
+	l.f = f
+	return nil
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Read calls fn for each entry in the audit log file, in the order their outcome was recorded.
+// Aborted entries are skipped, and entries that are still pending are reported last.
+func Read(path string, fn func(Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return read(f, fn)
+}
+
+func read(r io.Reader, fn func(Entry) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var (
+		pending = make(map[string]Entry)
+		order   []string
+	)
+	for {
+		var je jsonEntry
+		if err := dec.Decode(&je); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		var e Entry
+		if je.Outcome != "" {
+			var ok bool
+			if e, ok = pending[je.ID]; !ok {
+				// an outcome of the entry that was pruned
+				continue
+			}
+			delete(pending, je.ID)
+			if je.Outcome == aborted {
+				continue
+			}
+			e.State = je.Outcome
+		} else if err := je.decode(&e); err != nil {
+			return err
+		} else if e.State == Pending {
+			pending[e.ID] = e
+			order = append(order, e.ID)
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	for _, id := range order {
+		if e, ok := pending[id]; ok {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Export writes entries recorded in a given time range to w as JSON lines.
+// Zero time values leave the range open.
+func Export(w io.Writer, path string, since, until time.Time) error {
+	enc := json.NewEncoder(w)
+	return Read(path, func(e Entry) error {
+		if (!since.IsZero() && e.Time.Before(since)) || (!until.IsZero() && !e.Time.Before(until)) {
+			return nil
+		}
+		return enc.Encode(e)
+	})
+}
+
+// History calls fn for each entry in the audit log file that changed a given node, in the order they were recorded.
+// Entries only include deltas of quads that have the node in any direction. Zero time values leave the range open.
+func History(path string, node quad.Value, since, until time.Time, fn func(Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return history(f, node, since, until, fn)
+}
+
+// History is like the History function, but reads entries recorded to the log so far.
+// It can be called while entries are recorded.
+func (l *Log) History(node quad.Value, since, until time.Time, fn func(Entry) error) error {
+	// only read the entries that were completely written when the history was requested
+	l.mu.Lock()
+	if l.f == nil {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	f, err := os.Open(l.path)
+	var size int64
+	if err == nil {
+		var fi os.FileInfo
+		if fi, err = f.Stat(); err == nil {
+			size = fi.Size()
+		} else {
+			f.Close()
+		}
+	}
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return history(io.LimitReader(f, size), node, since, until, fn)
+}
+
+func history(r io.Reader, node quad.Value, since, until time.Time, fn func(Entry) error) error {
+	key := quad.StringOf(node)
+	return read(r, func(e Entry) error {
+		if (!since.IsZero() && e.Time.Before(since)) || (!until.IsZero() && !e.Time.Before(until)) {
+			return nil
+		}
+		deltas := e.Deltas[:0]
+		for _, d := range e.Deltas {
+			for _, dir := range quad.Directions {
+				if v := d.Quad.Get(dir); v != nil && quad.StringOf(v) == key {
+					deltas = append(deltas, d)
+					break
+				}
+			}
+		}
+		if len(deltas) == 0 {
+			return nil
+		}
+		e.Deltas = deltas
+		return fn(e)
+	})
+}
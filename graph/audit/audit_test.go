@@ -0,0 +1,224 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func tempLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cayley-audit-")
+	require.NoError(t, err)
+	return filepath.Join(dir, "audit.log"), func() { os.RemoveAll(dir) }
+}
+
+func readAll(t *testing.T, path string) []Entry {
+	var out []Entry
+	err := Read(path, func(e Entry) error {
+		out = append(out, e)
+		return nil
+	})
+	require.NoError(t, err)
+	return out
+}
+
+var (
+	q1 = quad.Make("a", "follows", "b", nil)
+	q2 = quad.Make("b", "age", quad.Int(42), "g")
+)
+
+func TestLog(t *testing.T) {
+	path, closer := tempLog(t)
+	defer closer()
+
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	t0 := time.Now().Add(-2 * time.Hour).Round(0)
+	t1 := t0.Add(time.Hour)
+	e1 := Entry{
+		Info:   Info{Principal: "bob", RequestID: "r1"},
+		State:  Committed,
+		Time:   t0,
+		Deltas: []graph.Delta{{Quad: q1, Action: graph.Add}},
+	}
+	e2 := Entry{
+		Info:   Info{Principal: "alice", Addr: "127.0.0.1:80", RequestID: "r2"},
+		State:  Committed,
+		Time:   t1,
+		Deltas: []graph.Delta{{Quad: q1, Action: graph.Delete}, {Quad: q2, Action: graph.Add}},
+	}
+	require.NoError(t, l.Record(e1))
+	require.NoError(t, l.Record(e2))
+
+	got := readAll(t, path)
+	require.Len(t, got, 2)
+	require.True(t, got[1].Time.Equal(t1))
+	got[0].Time, got[1].Time = t0, t1
+	require.Equal(t, []Entry{e1, e2}, got)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, Export(buf, path, t0.Add(time.Minute), time.Time{}))
+	require.Equal(t, 1, strings.Count(buf.String(), "\n"))
+	require.Contains(t, buf.String(), `"request_id":"r2"`)
+
+	require.NoError(t, l.Prune(t1))
+	require.NoError(t, l.Record(Entry{Info: Info{RequestID: "r3"}}))
+	require.NoError(t, l.Close())
+	got = readAll(t, path)
+	require.Len(t, got, 2)
+	require.Equal(t, "r2", got[0].RequestID)
+	require.Equal(t, "r3", got[1].RequestID)
+
+	// retention is applied when the log is opened
+	l, err = Open(path, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	got = readAll(t, path)
+	require.Len(t, got, 1)
+	require.Equal(t, "r3", got[0].RequestID)
+}
+
+func TestPending(t *testing.T) {
+	path, closer := tempLog(t)
+	defer closer()
+
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, l.Record(Entry{Info: Info{RequestID: "r0"}, Time: time.Now().Add(-2 * time.Hour)}))
+	p1, err := l.Begin(Entry{Info: Info{RequestID: "r1"}, Deltas: []graph.Delta{{Quad: q1, Action: graph.Add}}})
+	require.NoError(t, err)
+	p2, err := l.Begin(Entry{Info: Info{RequestID: "r2"}, Deltas: []graph.Delta{{Quad: q2, Action: graph.Add}}})
+	require.NoError(t, err)
+	p3, err := l.Begin(Entry{Info: Info{RequestID: "r3"}, Deltas: []graph.Delta{{Quad: q1, Action: graph.Delete}}})
+	require.NoError(t, err)
+	require.NoError(t, p2.Commit())
+	require.NoError(t, p3.Abort())
+
+	// aborted entries are not reported, and pending ones are reported last
+	got := readAll(t, path)
+	require.Len(t, got, 3)
+	require.Equal(t, "r2", got[1].RequestID)
+	require.Equal(t, Committed, got[1].State)
+	require.Equal(t, "r1", got[2].RequestID)
+	require.Equal(t, Pending, got[2].State)
+
+	// the outcome is still recorded after the entry was rewritten by pruning
+	require.NoError(t, l.Prune(time.Now().Add(-time.Hour)))
+	_, err = l.Begin(Entry{Info: Info{RequestID: "r4"}, Deltas: []graph.Delta{{Quad: q2, Action: graph.Delete}}})
+	require.NoError(t, err)
+	require.NoError(t, p1.Commit())
+	got = readAll(t, path)
+	require.Len(t, got, 3)
+	require.Equal(t, "r1", got[1].RequestID)
+	require.Equal(t, Committed, got[1].State)
+	require.Equal(t, Pending, got[2].State)
+	require.NoError(t, l.Close())
+
+	// entries left pending are interrupted when the log is reopened
+	l, err = Open(path, 0)
+	require.NoError(t, err)
+	defer l.Close()
+	got = readAll(t, path)
+	require.Len(t, got, 3)
+	require.Equal(t, "r4", got[2].RequestID)
+	require.Equal(t, Interrupted, got[2].State)
+	p5, err := l.Begin(Entry{Info: Info{RequestID: "r5"}, Deltas: []graph.Delta{{Quad: q1, Action: graph.Add}}})
+	require.NoError(t, err)
+	require.NoError(t, p5.Commit())
+	got = readAll(t, path)
+	require.Len(t, got, 4)
+	require.Equal(t, Committed, got[3].State)
+}
+
+func TestQuadStore(t *testing.T) {
+	path, closer := tempLog(t)
+	defer closer()
+
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	defer l.Close()
+
+	mem := memstore.New()
+	info := Info{Principal: "bob", RequestID: "r1"}
+	qs := New(mem, l, info)
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q1, Action: graph.Add}}, graph.IgnoreOpts{}))
+	// failed writes are not recorded
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: q1, Action: graph.Add}}, graph.IgnoreOpts{})
+	require.True(t, graph.IsQuadExist(err), "%v", err)
+	err = qs.ApplyDeltasIf([]graph.Precondition{{Quad: q2, Exists: true}}, []graph.Delta{{Quad: q1, Action: graph.Delete}}, graph.IgnoreOpts{})
+	require.True(t, graph.IsPreconditionFailed(err), "%v", err)
+	// the underlying store is shared, so it stays open
+	require.NoError(t, qs.Close())
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q1, Action: graph.Delete}}, graph.IgnoreOpts{}))
+
+	got := readAll(t, path)
+	require.Len(t, got, 2)
+	for _, e := range got {
+		require.Equal(t, info, e.Info)
+	}
+	require.Equal(t, graph.Add, got[0].Deltas[0].Action)
+	require.Equal(t, graph.Delete, got[1].Deltas[0].Action)
+}
+
+func TestHistory(t *testing.T) {
+	path, closer := tempLog(t)
+	defer closer()
+
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	defer l.Close()
+	t0 := time.Now().Add(-time.Hour).Round(0)
+	require.NoError(t, l.Record(Entry{Info: Info{Principal: "bob"}, Time: t0, Deltas: []graph.Delta{
+		{Quad: q1, Action: graph.Add},
+		{Quad: q2, Action: graph.Add},
+	}}))
+	require.NoError(t, l.Record(Entry{Info: Info{Principal: "alice"}, Deltas: []graph.Delta{
+		{Quad: q1, Action: graph.Delete},
+	}}))
+
+	history := func(node quad.Value, since time.Time) (out []Entry) {
+		err := l.History(node, since, time.Time{}, func(e Entry) error {
+			out = append(out, e)
+			return nil
+		})
+		require.NoError(t, err)
+		return out
+	}
+	got := history(quad.String("a"), time.Time{})
+	require.Len(t, got, 2)
+	require.Equal(t, "bob", got[0].Principal)
+	require.Equal(t, []graph.Delta{{Quad: q1, Action: graph.Add}}, got[0].Deltas)
+	require.Equal(t, "alice", got[1].Principal)
+
+	// the node is matched in any direction, including labels
+	got = history(quad.String("g"), time.Time{})
+	require.Len(t, got, 1)
+	require.Equal(t, []graph.Delta{{Quad: q2, Action: graph.Add}}, got[0].Deltas)
+
+	require.Len(t, history(quad.String("b"), t0.Add(time.Minute)), 1)
+	require.Len(t, history(quad.String("c"), time.Time{}), 0)
+}
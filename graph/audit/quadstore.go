@@ -0,0 +1,93 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+var _ graph.Wrapper = (*QuadStore)(nil)
+
+// QuadStore records all deltas applied through it to the audit log.
+//
+// It is meant to be created for each request, thus it does not own the log and
+// the underlying quad store, and Close is a no-op.
+type QuadStore struct {
+	graph.QuadStore
+	log  *Log
+	info Info
+}
+
+// New wraps a quad store to record changes made on behalf of a given client.
+func New(qs graph.QuadStore, l *Log, info Info) *QuadStore {
+	return &QuadStore{QuadStore: qs, log: l, info: info}
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf records deltas as pending, applies them to the underlying quad store, and records the outcome.
+// Writes are rejected if they cannot be recorded.
+//
+// Deltas ignored by the options are recorded as well.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	var (
+		p   *PendingEntry
+		err error
+	)
+	if len(deltas) != 0 {
+		if p, err = qs.log.Begin(Entry{Info: qs.info, Deltas: deltas}); err != nil {
+			return err
+		}
+	}
+	if len(conds) == 0 {
+		err = qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		err = ca.ApplyDeltasIf(conds, deltas, opts)
+	} else {
+		err = graph.ErrPreconditionsNotSupported
+	}
+	if p == nil {
+		return err
+	} else if err != nil {
+		if aerr := p.Abort(); aerr != nil {
+			clog.Errorf("audit: failed to abort %d deltas of request %q: %v", len(deltas), qs.info.RequestID, aerr)
+		}
+		return err
+	}
+	if err = p.Commit(); err != nil {
+		// changes are already applied, so the client must not see an error;
+		// the entry will be reported as interrupted when the log is reopened
+		clog.Errorf("audit: failed to commit %d deltas of request %q: %v", len(deltas), qs.info.RequestID, err)
+	}
+	return nil
+}
+
+func (qs *QuadStore) Close() error {
+	return nil
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// WrapSnapshot returns a snapshot of the underlying quad store as is, since the audit log only records writes.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return s, nil
+}
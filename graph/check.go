@@ -0,0 +1,87 @@
+// Copyright 2014 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// ProblemKind is a kind of inconsistency found by a Checker.
+type ProblemKind string
+
+const (
+	// MissingIndex is a quad or a node that is in the log, but not in one of the indexes.
+	MissingIndex = ProblemKind("missing index entry")
+	// ExtraIndex is an index entry that points to a missing, deleted or different quad or node.
+	ExtraIndex = ProblemKind("extra index entry")
+	// DanglingRef is a quad that refers to a missing node.
+	DanglingRef = ProblemKind("dangling reference")
+	// WrongRefs is a node with a reference count that differs from the number of quads that use it.
+	WrongRefs = ProblemKind("wrong reference count")
+	// WrongSize is a stored number of quads that differs from the actual number.
+	WrongSize = ProblemKind("wrong size")
+)
+
+// Problem is an inconsistency found by a Checker.
+type Problem struct {
+	Kind ProblemKind
+	// Desc describes the entry that is inconsistent.
+	Desc string
+	// Repaired is set if the problem was fixed.
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("%s: %s", p.Kind, p.Desc)
+	if p.Repaired {
+		s += " (repaired)"
+	}
+	return s
+}
+
+// CheckOptions configures a consistency check.
+type CheckOptions struct {
+	// Repair fixes problems that can be fixed without losing data.
+	Repair bool
+	// Problem is called for each problem found.
+	Problem func(p Problem)
+}
+
+// CheckStats is a summary of a consistency check.
+type CheckStats struct {
+	Quads    int64
+	Nodes    int64
+	Problems int
+	Repaired int
+}
+
+// Checker is an optional interface for quad stores that can cross-check their data against indexes.
+type Checker interface {
+	// Check verifies the consistency of the quad store and optionally repairs it.
+	// The quad store must not be written to during the check.
+	Check(ctx context.Context, opts CheckOptions) (*CheckStats, error)
+}
+
+// Report passes a problem to the callback and updates the stats.
+func (opts CheckOptions) Report(st *CheckStats, p Problem) {
+	st.Problems++
+	if p.Repaired {
+		st.Repaired++
+	}
+	if opts.Problem != nil {
+		opts.Problem(p)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package coalesce implements a quad store wrapper that groups small concurrent writes
+// into larger batches.
+package coalesce
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	// DefaultMaxDelay is the default time a write waits for other writes to join its batch.
+	DefaultMaxDelay = 5 * time.Millisecond
+)
+
+var ErrClosed = errors.New("coalesce: quad store is closed")
+
+// Options controls when a batch is flushed.
+type Options struct {
+	// MaxQuads flushes a batch when it reaches a given number of deltas.
+	MaxQuads int
+	// MaxDelay flushes a batch after the first write in it waited for a given time.
+	MaxDelay time.Duration
+}
+
+type request struct {
+	deltas []graph.Delta
+	conds  []graph.Precondition
+	opts   graph.IgnoreOpts
+	errc   chan error
+}
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+var _ graph.Wrapper = (*QuadStore)(nil)
+
+// QuadStore groups deltas from concurrent ApplyDeltas calls into a single call to the
+// underlying quad store. Each call still blocks until its deltas are applied.
+//
+// If the combined batch fails, it is split into smaller batches, thus the caller only sees
+// errors caused by its own deltas. This assumes that the underlying quad store applies
+// batches atomically. Writes with preconditions are never combined.
+// Optional interfaces of the underlying quad store (except for conditional writes) are not exposed,
+// but snapshots and the history can be found with graph.SnapshotOf and graph.AsOf.
+type QuadStore struct {
+	graph.QuadStore
+	opts Options
+
+	mu     sync.RWMutex
+	closed bool
+	reqs   chan *request
+	done   chan struct{}
+}
+
+// New wraps a quad store with a write coalescer.
+func New(qs graph.QuadStore, opts Options) *QuadStore {
+	if opts.MaxQuads <= 0 {
+		opts.MaxQuads = quad.DefaultBatch
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+	s := &QuadStore{
+		QuadStore: qs,
+		opts:      opts,
+		reqs:      make(chan *request),
+		done:      make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	if len(deltas) == 0 && len(conds) == 0 {
+		return nil
+	}
+	r := &request{deltas: deltas, conds: conds, opts: opts, errc: make(chan error, 1)}
+	qs.mu.RLock()
+	if qs.closed {
+		qs.mu.RUnlock()
+		return ErrClosed
+	}
+	qs.reqs <- r
+	qs.mu.RUnlock()
+	return <-r.errc
+}
+
+func (qs *QuadStore) apply(r *request) error {
+	if len(r.conds) == 0 {
+		return qs.QuadStore.ApplyDeltas(r.deltas, r.opts)
+	}
+	ca, ok := qs.QuadStore.(graph.ConditionalApplier)
+	if !ok {
+		return graph.ErrPreconditionsNotSupported
+	}
+	return ca.ApplyDeltasIf(r.conds, r.deltas, r.opts)
+}
+
+// batch is a set of writes that can be applied together.
+type batch struct {
+	reqs  []*request
+	n     int
+	quads map[quad.Quad]struct{}
+}
+
+// accepts checks if a write can be added to the batch without changing the result of
+// applying writes one by one.
+func (b *batch) accepts(r *request) bool {
+	if len(b.reqs) == 0 {
+		return true
+	} else if len(r.conds) != 0 || len(b.reqs[0].conds) != 0 || r.opts != b.reqs[0].opts {
+		return false
+	}
+	for _, d := range r.deltas {
+		if _, ok := b.quads[d.Quad]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *batch) add(r *request) {
+	if b.quads == nil {
+		b.quads = make(map[quad.Quad]struct{})
+	}
+	b.reqs = append(b.reqs, r)
+	b.n += len(r.deltas)
+	for _, d := range r.deltas {
+		b.quads[d.Quad] = struct{}{}
+	}
+}
+
+func (qs *QuadStore) flush(b *batch) {
+	qs.flushReqs(b.reqs, b.n)
+}
+
+// flushReqs applies writes as a single batch. If it fails, the batch is split in halves
+// to find the writes that caused an error.
+func (qs *QuadStore) flushReqs(reqs []*request, n int) {
+	if len(reqs) == 1 {
+		r := reqs[0]
+		r.errc <- qs.apply(r)
+		return
+	}
+	deltas := make([]graph.Delta, 0, n)
+	for _, r := range reqs {
+		deltas = append(deltas, r.deltas...)
+	}
+	if err := qs.QuadStore.ApplyDeltas(deltas, reqs[0].opts); err == nil {
+		for _, r := range reqs {
+			r.errc <- nil
+		}
+		return
+	}
+	half := len(reqs) / 2
+	var n1 int
+	for _, r := range reqs[:half] {
+		n1 += len(r.deltas)
+	}
+	qs.flushReqs(reqs[:half], n1)
+	qs.flushReqs(reqs[half:], n-n1)
+}
+
+func (qs *QuadStore) loop() {
+	defer close(qs.done)
+	var next *request
+	for {
+		if next == nil {
+			r, ok := <-qs.reqs
+			if !ok {
+				return
+			}
+			next = r
+		}
+		var b batch
+		b.add(next)
+		next = nil
+		timer := time.NewTimer(qs.opts.MaxDelay)
+	collect:
+		for b.n < qs.opts.MaxQuads && len(b.reqs[0].conds) == 0 {
+			select {
+			case r, ok := <-qs.reqs:
+				if !ok {
+					break collect
+				} else if !b.accepts(r) {
+					next = r
+					break collect
+				}
+				b.add(r)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		qs.flush(&b)
+	}
+}
+
+// Close flushes all pending writes and closes the underlying quad store.
+func (qs *QuadStore) Close() error {
+	qs.mu.Lock()
+	if qs.closed {
+		qs.mu.Unlock()
+		return nil
+	}
+	qs.closed = true
+	close(qs.reqs)
+	qs.mu.Unlock()
+	<-qs.done
+	return qs.QuadStore.Close()
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// WrapSnapshot returns a snapshot of the underlying quad store as is, since batching only changes writes.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return s, nil
+}
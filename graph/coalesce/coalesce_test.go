@@ -0,0 +1,97 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coalesce
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// countingStore counts calls to ApplyDeltas.
+type countingStore struct {
+	graph.QuadStore
+	calls int32
+}
+
+func (qs *countingStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	atomic.AddInt32(&qs.calls, 1)
+	return qs.QuadStore.ApplyDeltas(deltas, opts)
+}
+
+func add(q quad.Quad) []graph.Delta {
+	return []graph.Delta{{Quad: q, Action: graph.Add}}
+}
+
+func TestCoalesce(t *testing.T) {
+	existing := quad.Make("a", "follows", "b", nil)
+	mem := &countingStore{QuadStore: memstore.New(existing)}
+	qs := New(mem, Options{MaxQuads: 1000, MaxDelay: 50 * time.Millisecond})
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make([]error, n+1)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = qs.ApplyDeltas(add(quad.Make("n"+strconv.Itoa(i), "follows", "a", nil)), graph.IgnoreOpts{})
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[n] = qs.ApplyDeltas(add(existing), graph.IgnoreOpts{})
+	}()
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, errs[i])
+	}
+	require.True(t, graph.IsQuadExist(errs[n]), "%v", errs[n])
+	require.True(t, atomic.LoadInt32(&mem.calls) < n, "writes were not coalesced: %d calls", mem.calls)
+	require.Len(t, graphtest.IteratedQuads(t, qs, qs.QuadsAllIterator()), n+1)
+
+	require.NoError(t, qs.Close())
+	require.Equal(t, ErrClosed, qs.ApplyDeltas(add(existing), graph.IgnoreOpts{}))
+}
+
+func TestCoalesceOrder(t *testing.T) {
+	q := quad.Make("a", "follows", "b", nil)
+	qs := New(memstore.New(), Options{MaxDelay: 50 * time.Millisecond})
+	defer qs.Close()
+
+	// add and remove of the same quad must not be merged into one batch
+	errc := make(chan error, 1)
+	go func() {
+		errc <- qs.ApplyDeltas(add(q), graph.IgnoreOpts{})
+	}()
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Delete}}, graph.IgnoreOpts{}))
+	require.NoError(t, <-errc)
+	require.Len(t, graphtest.IteratedQuads(t, qs, qs.QuadsAllIterator()), 0)
+
+	err := qs.ApplyDeltasIf([]graph.Precondition{{Quad: q, Exists: true}}, add(q), graph.IgnoreOpts{})
+	require.True(t, graph.IsPreconditionFailed(err), "%v", err)
+}
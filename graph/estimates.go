@@ -0,0 +1,143 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+)
+
+// TrackEstimates enables comparison of estimated sizes of iterators with the number of results they actually
+// returned. It must be set before running queries.
+var TrackEstimates = false
+
+// MisestimateRatio is the minimal ratio between estimated and actual size of an iterator that is counted as a misestimate.
+const MisestimateRatio = 10
+
+var optLogger = clog.Module("optimizer")
+
+// EstimateStats compares estimated and actual sizes of iterators of one type.
+//
+// Only iterators that were iterated to the end are measured. Iterators used for Contains checks and iterators
+// that do not count their results are ignored.
+type EstimateStats struct {
+	Type Type `json:"type"`
+	// Samples is the number of measured iterators.
+	Samples int64 `json:"samples"`
+	// Estimated and Actual are the sums of estimated sizes and actual numbers of results.
+	Estimated int64 `json:"estimated"`
+	Actual    int64 `json:"actual"`
+	// Under and Over count iterators that returned at least MisestimateRatio times more or less results than estimated.
+	Under int64 `json:"under"`
+	Over  int64 `json:"over"`
+	// MaxRatio is the largest ratio between estimated and actual size, in either direction.
+	MaxRatio float64 `json:"max_ratio"`
+}
+
+var estimates struct {
+	sync.Mutex
+	types map[Type]*EstimateStats
+}
+
+// Estimates returns statistics of estimated sizes of iterators recorded since the start or the last reset,
+// sorted by iterator type.
+func Estimates() []EstimateStats {
+	estimates.Lock()
+	defer estimates.Unlock()
+	out := make([]EstimateStats, 0, len(estimates.types))
+	for _, st := range estimates.types {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+// ResetEstimates removes all recorded statistics of estimated sizes.
+func ResetEstimates() {
+	estimates.Lock()
+	estimates.types = nil
+	estimates.Unlock()
+}
+
+// estimateRatio returns how many times the estimate is off. Both sizes are increased by one to allow empty iterators.
+func estimateRatio(est, act int64) float64 {
+	r := float64(act+1) / float64(est+1)
+	if r < 1 {
+		r = 1 / r
+	}
+	return r
+}
+
+// actualSize returns the number of results of an iterator that was iterated to the end.
+func actualSize(st StatsContainer) int64 {
+	if st.Type == LinksTo {
+		// counts each step over the quads of a node as a call of Next
+		return st.ContainsNext
+	}
+	// the last call of Next returned no results
+	return st.Next - 1
+}
+
+// recordEstimates compares stats of an iterator tree taken before and after it was iterated to the end.
+func recordEstimates(it Iterator, before, after StatsContainer) {
+	estimates.Lock()
+	defer estimates.Unlock()
+	recordEstimate(it, before, after)
+}
+
+func recordEstimate(it Iterator, before, after StatsContainer) {
+	if before.UID != after.UID || before.Type == Limit {
+		// the tree was changed, or sub-iterators were not iterated to the end
+		return
+	}
+	if after.Next > 0 && after.Contains == 0 {
+		if estimates.types == nil {
+			estimates.types = make(map[Type]*EstimateStats)
+		}
+		st := estimates.types[after.Type]
+		if st == nil {
+			st = &EstimateStats{Type: after.Type}
+			estimates.types[after.Type] = st
+		}
+		est, act := before.Size, actualSize(after)
+		st.Samples++
+		st.Estimated += est
+		st.Actual += act
+		r := estimateRatio(est, act)
+		if r > st.MaxRatio {
+			st.MaxRatio = r
+		}
+		if r >= MisestimateRatio {
+			if act > est {
+				st.Under++
+			} else {
+				st.Over++
+			}
+			if optLogger.V(1) {
+				optLogger.Info("misestimated iterator size",
+					clog.F("type", after.Type), clog.F("estimated", est), clog.F("actual", act), clog.F("iterator", it))
+			}
+		}
+	}
+	subs := it.SubIterators()
+	if len(subs) != len(before.SubIts) || len(subs) != len(after.SubIts) {
+		return
+	}
+	for i, sub := range subs {
+		recordEstimate(sub, before.SubIts[i], after.SubIts[i])
+	}
+}
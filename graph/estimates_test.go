@@ -0,0 +1,49 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+)
+
+func TestEstimates(t *testing.T) {
+	graph.TrackEstimates = true
+	defer func() {
+		graph.TrackEstimates = false
+		graph.ResetEstimates()
+	}()
+	graph.ResetEstimates()
+
+	ctx := context.TODO()
+	newIt := func() graph.Iterator {
+		fixed := iterator.NewFixed()
+		for i := 0; i < 40; i++ {
+			fixed.Add(iterator.Int64Node(1))
+		}
+		// unique iterator expects every second value to be a duplicate
+		return iterator.NewUnique(fixed)
+	}
+	if n, err := graph.Iterate(ctx, newIt()).Count(); err != nil || n != 1 {
+		t.Fatalf("unexpected count: %v, %v", n, err)
+	}
+	// iterators that are not iterated to the end are not measured
+	if _, err := graph.Iterate(ctx, newIt()).Limit(1).All(); err != nil {
+		t.Fatal(err)
+	}
+	var st *graph.EstimateStats
+	for _, s := range graph.Estimates() {
+		if s.Type == graph.Unique {
+			s := s
+			st = &s
+		}
+	}
+	if st == nil {
+		t.Fatalf("no stats for unique iterator: %+v", graph.Estimates())
+	}
+	exp := graph.EstimateStats{Type: graph.Unique, Samples: 1, Estimated: 20, Actual: 1, Over: 1, MaxRatio: 10.5}
+	if *st != exp {
+		t.Errorf("unexpected stats: %+v, expected %+v", *st, exp)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package geo implements geographic points, regions and geohash cells used by geospatial queries.
+//
+// Points are stored as WKT literals:
+//
+//	<place> <location> "POINT(-122.4194 37.7749)"^^<http://www.opengis.net/ont/geosparql#wktLiteral> .
+//
+// Note that WKT lists the longitude first, while all functions of this package accept the latitude first.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc"
+)
+
+func init() {
+	voc.RegisterPrefix(Prefix, NS)
+}
+
+const (
+	// NS is the namespace of the GeoSPARQL vocabulary.
+	NS     = `http://www.opengis.net/ont/geosparql#`
+	Prefix = `geo:`
+)
+
+// WKTLiteral is the datatype of WKT literals, as defined by GeoSPARQL.
+const WKTLiteral = quad.IRI(NS + "wktLiteral")
+
+// crs84 is the default coordinate reference system of WKT literals; it may prefix the geometry.
+const crs84 = "<http://www.opengis.net/def/crs/OGC/1.3/CRS84>"
+
+// EarthRadius is the mean radius of the Earth in meters.
+const EarthRadius = 6371008.8
+
+// Point is a location on the Earth, in degrees.
+type Point struct {
+	Lat, Lng float64
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("POINT(%s %s)", ftoa(p.Lng), ftoa(p.Lat))
+}
+
+// Value returns the point as a WKT literal.
+func (p Point) Value() quad.Value {
+	return quad.TypedString{Value: quad.String(p.String()), Type: WKTLiteral}
+}
+
+// Valid checks if coordinates of the point are within the valid range.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ParsePoint returns a point from a WKT literal. Plain strings are accepted as well.
+func ParsePoint(v quad.Value) (Point, bool) {
+	var s string
+	switch v := v.(type) {
+	case quad.TypedString:
+		if v.Type.Full() != WKTLiteral {
+			return Point{}, false
+		}
+		s = string(v.Value)
+	case quad.String:
+		s = string(v)
+	default:
+		return Point{}, false
+	}
+	return ParseWKT(s)
+}
+
+// ParseWKT parses a point in WKT format, for example, "POINT(-122.4194 37.7749)".
+func ParseWKT(s string) (Point, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, crs84) {
+		s = strings.TrimSpace(s[len(crs84):])
+	}
+	const pref = "POINT"
+	if len(s) < len(pref) || !strings.EqualFold(s[:len(pref)], pref) {
+		return Point{}, false
+	}
+	s = strings.TrimSpace(s[len(pref):])
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return Point{}, false
+	}
+	f := strings.Fields(s[1 : len(s)-1])
+	if len(f) != 2 {
+		return Point{}, false
+	}
+	lng, err1 := strconv.ParseFloat(f[0], 64)
+	lat, err2 := strconv.ParseFloat(f[1], 64)
+	p := Point{Lat: lat, Lng: lng}
+	if err1 != nil || err2 != nil || !p.Valid() {
+		return Point{}, false
+	}
+	return p, true
+}
+
+func radians(d float64) float64 { return d * math.Pi / 180 }
+
+// Distance returns the great-circle distance between two points in meters.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dlat, dlng := lat2-lat1, radians(b.Lng-a.Lng)
+	h := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dlng/2)*math.Sin(dlng/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Rect is a latitude-longitude rectangle. If MinLng is greater than MaxLng, the rectangle crosses the antimeridian.
+type Rect struct {
+	MinLat, MinLng float64
+	MaxLat, MaxLng float64
+}
+
+// Valid checks if the rectangle is not empty and its coordinates are within the valid range.
+func (r Rect) Valid() bool {
+	return r.MinLat <= r.MaxLat && Point{Lat: r.MinLat, Lng: r.MinLng}.Valid() && Point{Lat: r.MaxLat, Lng: r.MaxLng}.Valid()
+}
+
+// Contains checks if the point is inside the rectangle.
+func (r Rect) Contains(p Point) bool {
+	if p.Lat < r.MinLat || p.Lat > r.MaxLat {
+		return false
+	}
+	if r.MinLng <= r.MaxLng {
+		return p.Lng >= r.MinLng && p.Lng <= r.MaxLng
+	}
+	return p.Lng >= r.MinLng || p.Lng <= r.MaxLng
+}
+
+// split returns rectangles that don't cross the antimeridian.
+func (r Rect) split() []Rect {
+	if r.MinLng <= r.MaxLng {
+		return []Rect{r}
+	}
+	a, b := r, r
+	a.MaxLng, b.MinLng = 180, -180
+	return []Rect{a, b}
+}
+
+// Region is an area on the Earth.
+type Region interface {
+	// Bounds returns a rectangle that contains the region.
+	Bounds() Rect
+	// Contains checks if the point is inside the region.
+	Contains(p Point) bool
+}
+
+var (
+	_ Region = Rect{}
+	_ Region = Circle{}
+	_ Region = Polygon{}
+)
+
+// Bounds implements Region.
+func (r Rect) Bounds() Rect { return r }
+
+// Circle is a region within a given distance from the center, in meters.
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// Contains implements Region.
+func (c Circle) Contains(p Point) bool {
+	return Distance(c.Center, p) <= c.Radius
+}
+
+// Bounds implements Region.
+func (c Circle) Bounds() Rect {
+	dlat := c.Radius / EarthRadius * 180 / math.Pi
+	r := Rect{
+		MinLat: math.Max(-90, c.Center.Lat-dlat),
+		MaxLat: math.Min(90, c.Center.Lat+dlat),
+	}
+	cos := math.Cos(radians(c.Center.Lat))
+	dlng := 180.0
+	if cos > 0 {
+		dlng = dlat / cos
+	}
+	if dlng >= 180 || r.MinLat == -90 || r.MaxLat == 90 {
+		// the circle contains a pole, or the whole range of longitudes
+		r.MinLng, r.MaxLng = -180, 180
+		return r
+	}
+	r.MinLng, r.MaxLng = wrapLng(c.Center.Lng-dlng), wrapLng(c.Center.Lng+dlng)
+	return r
+}
+
+func wrapLng(lng float64) float64 {
+	if lng < -180 {
+		return lng + 360
+	} else if lng > 180 {
+		return lng - 360
+	}
+	return lng
+}
+
+// Polygon is a region bounded by a closed ring of points. The last point is connected to the first one.
+//
+// Edges are treated as straight lines in latitude-longitude coordinates, which is accurate enough for polygons
+// that are small compared to the Earth. Polygons must not cross the antimeridian.
+type Polygon []Point
+
+// Bounds implements Region.
+func (p Polygon) Bounds() Rect {
+	if len(p) == 0 {
+		return Rect{MinLat: 1, MaxLat: -1} // empty
+	}
+	r := Rect{MinLat: p[0].Lat, MaxLat: p[0].Lat, MinLng: p[0].Lng, MaxLng: p[0].Lng}
+	for _, pt := range p[1:] {
+		r.MinLat, r.MaxLat = math.Min(r.MinLat, pt.Lat), math.Max(r.MaxLat, pt.Lat)
+		r.MinLng, r.MaxLng = math.Min(r.MinLng, pt.Lng), math.Max(r.MaxLng, pt.Lng)
+	}
+	return r
+}
+
+// Contains implements Region.
+func (p Polygon) Contains(pt Point) bool {
+	if len(p) < 3 {
+		return false
+	}
+	in := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Lat > pt.Lat) != (b.Lat > pt.Lat) &&
+			pt.Lng < (b.Lng-a.Lng)*(pt.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			in = !in
+		}
+	}
+	return in
+}
+
+// String returns the polygon in WKT format.
+func (p Polygon) String() string {
+	parts := make([]string, 0, len(p)+1)
+	for _, pt := range p {
+		parts = append(parts, ftoa(pt.Lng)+" "+ftoa(pt.Lat))
+	}
+	if len(p) != 0 && p[0] != p[len(p)-1] {
+		parts = append(parts, parts[0])
+	}
+	return "POLYGON((" + strings.Join(parts, ", ") + "))"
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geo
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestParsePoint(t *testing.T) {
+	for _, c := range []struct {
+		v  quad.Value
+		p  Point
+		ok bool
+	}{
+		{v: Point{Lat: 37.7749, Lng: -122.4194}.Value(), p: Point{Lat: 37.7749, Lng: -122.4194}, ok: true},
+		{v: quad.String("point( 10 -20 )"), p: Point{Lat: -20, Lng: 10}, ok: true},
+		{v: quad.TypedString{Value: "<http://www.opengis.net/def/crs/OGC/1.3/CRS84> POINT(1 2)", Type: "geo:wktLiteral"}, p: Point{Lat: 2, Lng: 1}, ok: true},
+		{v: quad.String("POINT(10 100)")},
+		{v: quad.String("POINT(10)")},
+		{v: quad.String("LINESTRING(1 2, 3 4)")},
+		{v: quad.TypedString{Value: "POINT(1 2)", Type: quad.IRI("xsd:string")}},
+		{v: quad.Int(1)},
+	} {
+		p, ok := ParsePoint(c.v)
+		if ok != c.ok || p != c.p {
+			t.Errorf("unexpected result for %v: %v, %v", c.v, p, ok)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	sf, la := Point{Lat: 37.7749, Lng: -122.4194}, Point{Lat: 34.0522, Lng: -118.2437}
+	if d := Distance(sf, la); math.Abs(d-559000) > 2000 {
+		t.Errorf("unexpected distance: %v", d)
+	}
+	if d := Distance(Point{Lng: 179.5}, Point{Lng: -179.5}); math.Abs(d-111195) > 100 {
+		t.Errorf("unexpected distance: %v", d)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	if h := Encode(Point{Lat: 57.64911, Lng: 10.40744}, 11); h != "u4pruydqqvj" {
+		t.Errorf("unexpected geohash: %q", h)
+	}
+}
+
+func TestCover(t *testing.T) {
+	points := []Point{
+		{Lat: 37.7749, Lng: -122.4194},
+		{Lat: -15, Lng: 179.99},
+		{Lat: -15, Lng: -179.99},
+		{Lat: 89.9, Lng: 0},
+		{Lat: 0, Lng: 0},
+	}
+	for _, p := range points {
+		for _, r := range []float64{10, 1000, 100000, 3000000} {
+			c := Circle{Center: p, Radius: r}
+			cells := Cover(c.Bounds(), 16)
+			if len(cells) == 0 || len(cells) > 16 {
+				t.Fatalf("unexpected number of cells for %v: %d", c, len(cells))
+			}
+			// points on the circle must be in one of the cells
+			for a := 0.0; a < 2*math.Pi; a += math.Pi / 8 {
+				dlat := r * 0.99 / EarthRadius * 180 / math.Pi
+				q := Point{
+					Lat: p.Lat + dlat*math.Sin(a),
+					Lng: wrapLng(p.Lng + dlat*math.Cos(a)/math.Cos(radians(p.Lat))),
+				}
+				if !q.Valid() || !c.Contains(q) {
+					continue
+				}
+				h := Encode(q, MaxPrecision)
+				found := false
+				for _, cell := range cells {
+					if strings.HasPrefix(h, cell) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("point %v of %v is not covered by %v", q, c, cells)
+				}
+			}
+		}
+	}
+}
+
+func TestPolygon(t *testing.T) {
+	poly := Polygon{{Lat: 0, Lng: 0}, {Lat: 0, Lng: 10}, {Lat: 10, Lng: 10}, {Lat: 10, Lng: 0}}
+	if !poly.Contains(Point{Lat: 5, Lng: 5}) {
+		t.Error("expected the point to be inside")
+	}
+	if poly.Contains(Point{Lat: 5, Lng: 15}) {
+		t.Error("expected the point to be outside")
+	}
+	if s := poly.String(); s != "POLYGON((0 0, 10 0, 10 10, 0 10, 0 0))" {
+		t.Errorf("unexpected WKT: %q", s)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// MaxPrecision is the length of geohashes used by indexes. Cells of this size are a few centimeters wide.
+const MaxPrecision = 12
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Encode returns a geohash of the point with a given number of characters.
+// Geohashes of nearby points usually share a prefix, thus a cell is a range of sorted index keys.
+func Encode(p Point, precision int) string {
+	latMin, latMax := -90.0, 90.0
+	lngMin, lngMax := -180.0, 180.0
+	out := make([]byte, 0, precision)
+	even := true
+	var ch, bit uint
+	for len(out) < precision {
+		if even {
+			if mid := (lngMin + lngMax) / 2; p.Lng >= mid {
+				ch |= 1 << (4 - bit)
+				lngMin = mid
+			} else {
+				lngMax = mid
+			}
+		} else {
+			if mid := (latMin + latMax) / 2; p.Lat >= mid {
+				ch |= 1 << (4 - bit)
+				latMin = mid
+			} else {
+				latMax = mid
+			}
+		}
+		even = !even
+		if bit++; bit == 5 {
+			out = append(out, base32[ch])
+			ch, bit = 0, 0
+		}
+	}
+	return string(out)
+}
+
+// cellSize returns the size of geohash cells of a given precision in degrees.
+func cellSize(precision int) (lat, lng float64) {
+	bits := uint(precision * 5)
+	latBits, lngBits := bits/2, bits-bits/2
+	return 180 / float64(uint64(1)<<latBits), 360 / float64(uint64(1)<<lngBits)
+}
+
+// Cover returns geohash cells that together contain the rectangle. It selects the longest geohashes
+// that need no more than maxCells cells, thus the cover may only be a little larger than the rectangle.
+// Points with any of the returned geohashes as a prefix should be checked against the exact region.
+func Cover(r Rect, maxCells int) []string {
+	if r.MinLat > r.MaxLat {
+		return nil
+	}
+	if maxCells < 1 {
+		maxCells = 1
+	}
+	parts := r.split()
+	prec := 0
+	for p := 1; p <= MaxPrecision; p++ {
+		if cellCount(parts, p) > float64(maxCells) {
+			break
+		}
+		prec = p
+	}
+	if prec == 0 {
+		// the whole world
+		return []string{""}
+	}
+	dlat, dlng := cellSize(prec)
+	seen := make(map[string]struct{})
+	var out []string
+	for _, r := range parts {
+		for lat := cellStart(r.MinLat, -90, dlat); lat <= r.MaxLat; lat += dlat {
+			for lng := cellStart(r.MinLng, -180, dlng); lng <= r.MaxLng; lng += dlng {
+				h := Encode(Point{Lat: clamp(lat, 90), Lng: clamp(lng, 180)}, prec)
+				if _, ok := seen[h]; !ok {
+					seen[h] = struct{}{}
+					out = append(out, h)
+				}
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// cellStart returns the start of a cell that contains the coordinate.
+func cellStart(v, min, size float64) float64 {
+	return min + math.Floor((v-min)/size)*size
+}
+
+func clamp(v, max float64) float64 {
+	// cells that start at the max value are the last cells
+	if v >= max {
+		return math.Nextafter(max, 0)
+	}
+	return v
+}
+
+func cellCount(parts []Rect, precision int) float64 {
+	dlat, dlng := cellSize(precision)
+	n := 0.0
+	for _, r := range parts {
+		nlat := math.Floor((r.MaxLat-cellStart(r.MinLat, -90, dlat))/dlat) + 1
+		nlng := math.Floor((r.MaxLng-cellStart(r.MinLng, -180, dlng))/dlng) + 1
+		n += nlat * nlng
+	}
+	return n
+}
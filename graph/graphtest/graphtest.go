@@ -56,6 +56,8 @@ var graphTests = []struct {
 	{"compare typed values", TestCompareTypedValues},
 	{"schema", TestSchema},
 	{"delete reinserted", TestDeleteReinserted},
+	{"preconditions", TestPreconditions},
+	{"next batch", TestNextBatch},
 }
 
 func TestAll(t *testing.T, gen testutil.DatabaseFunc, conf *Config) {
@@ -1113,3 +1115,108 @@ func TestDeleteReinserted(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
 	}
 
 }
+
+func TestPreconditions(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	qs, opts, closer := gen(t)
+	defer closer()
+	if _, ok := qs.(graph.ConditionalApplier); !ok {
+		t.SkipNow()
+	}
+
+	w := testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	exists := quad.Make("A", "follows", "B", nil)
+	missing := quad.Make("A", "follows", "G", nil)
+	added := quad.Make("A", "follows", "E", nil)
+
+	for _, c := range []graph.Precondition{
+		{Quad: exists, Exists: false},
+		{Quad: missing, Exists: true},
+	} {
+		tx := graph.NewTransaction()
+		tx.Preconditions = []graph.Precondition{c}
+		tx.AddQuad(added)
+		err := w.ApplyTransaction(tx)
+		require.True(t, graph.IsPreconditionFailed(err), "expected precondition error, got: %v", err)
+		require.Equal(t, c, err.(*graph.PreconditionError).Precondition)
+	}
+	it := qs.QuadIterator(quad.Object, qs.ValueOf(quad.String("E")))
+	require.Empty(t, IteratedQuads(t, qs, it), "transaction should not be applied")
+
+	tx := graph.NewTransaction()
+	tx.RequireQuad(exists)
+	tx.RequireNoQuad(missing)
+	tx.AddQuad(added)
+	tx.RemoveQuad(exists)
+	require.NoError(t, w.ApplyTransaction(tx))
+
+	// the same preconditions must fail now
+	tx = graph.NewTransaction()
+	tx.RequireQuad(exists)
+	tx.RemoveQuad(added)
+	err := w.ApplyTransaction(tx)
+	require.True(t, graph.IsPreconditionFailed(err), "expected precondition error, got: %v", err)
+	it = qs.QuadIterator(quad.Object, qs.ValueOf(quad.String("E")))
+	require.Equal(t, []quad.Quad{added}, IteratedQuads(t, qs, it))
+}
+
+func TestNextBatch(t testing.TB, gen testutil.DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	qs, opts, closer := gen(t)
+	defer closer()
+
+	testutil.MakeWriter(t, qs, opts, MakeQuadSet()...)
+
+	trees := []func() graph.Iterator{
+		func() graph.Iterator {
+			return iterator.NewOr(
+				iterator.NewHasA(qs, iterator.NewLinksTo(qs, iterator.NewFixed(qs.ValueOf(quad.Raw("B"))), quad.Object), quad.Subject),
+				qs.NodesAllIterator(),
+			)
+		},
+		func() graph.Iterator {
+			// inner And has multiple paths for some subjects
+			follows := iterator.NewAnd(qs,
+				iterator.NewLinksTo(qs, iterator.NewFixed(qs.ValueOf(quad.Raw("follows"))), quad.Predicate),
+				iterator.NewLinksTo(qs, qs.NodesAllIterator(), quad.Object),
+			)
+			subjects := iterator.NewHasA(qs, follows, quad.Subject)
+			return iterator.NewHasA(qs, iterator.NewLinksTo(qs, subjects, quad.Subject), quad.Object)
+		},
+	}
+	names := func(vals []graph.Value) []string {
+		out := make([]string, 0, len(vals))
+		for _, v := range vals {
+			out = append(out, quad.ToString(qs.NameOf(v)))
+		}
+		return out
+	}
+	for i, tree := range trees {
+		it := tree()
+		var exp []graph.Value
+		for it.Next(ctx) {
+			exp = append(exp, it.Result())
+			for it.NextPath(ctx) {
+				exp = append(exp, it.Result())
+			}
+		}
+		require.NoError(t, it.Err())
+		it.Close()
+		require.NotEmpty(t, exp)
+
+		for _, n := range []int{1, 2, 3, 100} {
+			it := tree()
+			var got []graph.Value
+			for {
+				vals, err := graph.NextBatch(ctx, it, n)
+				require.NoError(t, err)
+				got = append(got, vals...)
+				if len(vals) < n {
+					break
+				}
+			}
+			it.Close()
+			require.Equal(t, names(exp), names(got), "tree %d, batch %d", i, n)
+		}
+	}
+}
@@ -20,6 +20,10 @@ type IterateChain struct {
 
 	limit int
 	n     int
+
+	// estimated sizes of iterators, if they are tracked
+	est       *StatsContainer
+	exhausted bool
 }
 
 // Iterate is a set of helpers for iteration. Context may be used to cancel execution.
@@ -42,9 +46,14 @@ func (c *IterateChain) next() bool {
 		return false
 	default:
 	}
-	ok := (c.limit < 0 || c.n < c.limit) && c.it.Next(c.ctx)
+	if c.limit >= 0 && c.n >= c.limit {
+		return false
+	}
+	ok := c.it.Next(c.ctx)
 	if ok {
 		c.n++
+	} else {
+		c.exhausted = true
 	}
 	return ok
 }
@@ -60,6 +69,38 @@ func (c *IterateChain) nextPath() bool {
 	}
 	return ok
 }
+
+// iterateBatch is the number of results requested in each batch by iteration helpers that don't need tags.
+const iterateBatch = 256
+
+// nextBatch returns the next batch of results, including sub-paths. It's used instead of next and nextPath
+// when sub-paths are enabled.
+func (c *IterateChain) nextBatch() ([]Value, error) {
+	if c.exhausted {
+		return nil, nil
+	}
+	select {
+	case <-c.ctx.Done():
+		return nil, nil
+	default:
+	}
+	n := iterateBatch
+	if c.limit >= 0 && c.limit-c.n < n {
+		n = c.limit - c.n
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	vals, err := NextBatch(c.ctx, c.it, n)
+	if len(vals) < n && err == nil {
+		c.exhausted = true
+	} else if len(vals) > n {
+		vals = vals[:n]
+	}
+	c.n += len(vals)
+	return vals, err
+}
+
 func (c *IterateChain) start() {
 	if c.optimize {
 		c.it, _ = c.it.Optimize()
@@ -67,6 +108,13 @@ func (c *IterateChain) start() {
 			c.it, _ = c.qs.OptimizeIterator(c.it)
 		}
 	}
+	if p := planFromContext(c.ctx); p != nil {
+		p.set(c.it)
+	}
+	if TrackEstimates {
+		st := DumpStats(c.it)
+		c.est = &st
+	}
 	if !clog.V(2) {
 		return
 	}
@@ -78,6 +126,9 @@ func (c *IterateChain) start() {
 }
 func (c *IterateChain) end() {
 	c.it.Close()
+	if c.est != nil && c.exhausted && c.it.Err() == nil {
+		recordEstimates(c.it, *c.est, DumpStats(c.it))
+	}
 	if !clog.V(2) {
 		return
 	}
@@ -119,6 +170,19 @@ func (c *IterateChain) Each(fnc func(Value)) error {
 	defer c.end()
 	done := c.ctx.Done()
 
+	if c.paths {
+		for {
+			vals, err := c.nextBatch()
+			for _, v := range vals {
+				fnc(v)
+			}
+			if err != nil {
+				return err
+			} else if len(vals) == 0 {
+				return c.ctx.Err()
+			}
+		}
+	}
 	for c.next() {
 		select {
 		case <-done:
@@ -148,8 +212,17 @@ func (c *IterateChain) Count() (int64, error) {
 	if size, exact := c.it.Size(); exact {
 		return size, nil
 	}
-	done := c.ctx.Done()
 	var cnt int64
+	if c.paths {
+		for {
+			vals, err := c.nextBatch()
+			cnt += int64(len(vals))
+			if err != nil || len(vals) == 0 {
+				return cnt, err
+			}
+		}
+	}
+	done := c.ctx.Done()
 iteration:
 	for c.next() {
 		select {
@@ -174,8 +247,17 @@ iteration:
 func (c *IterateChain) All() ([]Value, error) {
 	c.start()
 	defer c.end()
-	done := c.ctx.Done()
 	var out []Value
+	if c.paths {
+		for {
+			vals, err := c.nextBatch()
+			out = append(out, vals...)
+			if err != nil || len(vals) == 0 {
+				return out, err
+			}
+		}
+	}
+	done := c.ctx.Done()
 iteration:
 	for c.next() {
 		select {
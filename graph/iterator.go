@@ -216,6 +216,36 @@ type NoNext interface {
 	NoNext()
 }
 
+// BatchIterator is an optional interface for iterators that can return multiple results at once.
+type BatchIterator interface {
+	Iterator
+
+	// NextBatch advances the iterator by about n results and returns them. The results are the same
+	// as the ones returned by Next, followed by NextPath, and the batch may be larger than n to include
+	// all paths of the last result. A batch with less than n results means that the iterator is exhausted.
+	//
+	// If an error is encountered, it is returned along with the results produced before it.
+	// The batch may be shared with the iterator and must not be modified. TagResults must not be called
+	// after NextBatch.
+	NextBatch(ctx context.Context, n int) ([]Value, error)
+}
+
+// NextBatch returns the next batch of about n results of an iterator, including all paths.
+// It calls Next and NextPath if the iterator doesn't implement BatchIterator.
+func NextBatch(ctx context.Context, it Iterator, n int) ([]Value, error) {
+	if b, ok := it.(BatchIterator); ok {
+		return b.NextBatch(ctx, n)
+	}
+	var out []Value
+	for len(out) < n && it.Next(ctx) {
+		out = append(out, it.Result())
+		for it.NextPath(ctx) {
+			out = append(out, it.Result())
+		}
+	}
+	return out, it.Err()
+}
+
 // Height is a convienence function to measure the height of an iterator tree.
 func Height(it Iterator, until Type) int {
 	if it.Type() == until {
@@ -253,26 +283,31 @@ type Type string
 
 // These are the iterator types, defined as constants
 const (
-	Invalid     = Type("")
-	All         = Type("all")
-	And         = Type("and")
-	Or          = Type("or")
-	HasA        = Type("hasa")
-	LinksTo     = Type("linksto")
-	Comparison  = Type("comparison")
-	Null        = Type("null")
-	Err         = Type("error")
-	Fixed       = Type("fixed")
-	Not         = Type("not")
-	Optional    = Type("optional")
-	Materialize = Type("materialize")
-	Unique      = Type("unique")
-	Limit       = Type("limit")
-	Skip        = Type("skip")
-	Regex       = Type("regexp")
-	Count       = Type("count")
-	Recursive   = Type("recursive")
-	Resolver    = Type("resolver")
+	Invalid      = Type("")
+	All          = Type("all")
+	And          = Type("and")
+	Or           = Type("or")
+	HasA         = Type("hasa")
+	LinksTo      = Type("linksto")
+	Comparison   = Type("comparison")
+	Null         = Type("null")
+	Err          = Type("error")
+	Fixed        = Type("fixed")
+	Scored       = Type("scored")
+	Not          = Type("not")
+	Optional     = Type("optional")
+	Materialize  = Type("materialize")
+	Unique       = Type("unique")
+	Limit        = Type("limit")
+	Skip         = Type("skip")
+	Sort         = Type("sort")
+	Regex        = Type("regexp")
+	Filter       = Type("filter")
+	Count        = Type("count")
+	Recursive    = Type("recursive")
+	ShortestPath = Type("shortestpath")
+	Sequence     = Type("sequence")
+	Resolver     = Type("resolver")
 )
 
 // String returns a string representation of the Type.
@@ -27,7 +27,7 @@ import (
 	"github.com/cayleygraph/cayley/graph"
 )
 
-var _ graph.Iterator = &Fixed{}
+var _ graph.BatchIterator = &Fixed{}
 
 // A Fixed iterator consists of it's values, an index (where it is in the process of Next()ing) and
 // an equality function.
@@ -125,6 +125,20 @@ func (it *Fixed) Next(ctx context.Context) bool {
 	return graph.NextLogOut(it, true)
 }
 
+// NextBatch returns up to n next values. The batch must not be modified.
+func (it *Fixed) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	i := it.lastIndex
+	if n > len(it.values)-i {
+		n = len(it.values) - i
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	it.lastIndex += n
+	it.result = it.values[it.lastIndex-1]
+	return it.values[i:it.lastIndex:it.lastIndex], nil
+}
+
 func (it *Fixed) Err() error {
 	return nil
 }
@@ -42,7 +42,7 @@ import (
 	"github.com/cayleygraph/cayley/quad"
 )
 
-var _ graph.Iterator = &HasA{}
+var _ graph.BatchIterator = &HasA{}
 
 // A HasA consists of a reference back to the graph.QuadStore that it references,
 // a primary subiterator, a direction in which the quads for that subiterator point,
@@ -223,6 +223,24 @@ func (it *HasA) Next(ctx context.Context) bool {
 	return graph.NextLogOut(it, true)
 }
 
+// NextBatch maps a batch of quads of the primary iterator to their nodes in the direction.
+func (it *HasA) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	if it.resultIt != nil {
+		it.resultIt.Close()
+	}
+	quads, err := graph.NextBatch(ctx, it.primaryIt, n)
+	it.runstats.Next += int64(len(quads))
+	out := make([]graph.Value, len(quads))
+	for i, q := range quads {
+		out[i] = it.qs.QuadDirection(q, it.dir)
+	}
+	if len(out) != 0 {
+		it.result = out[len(out)-1]
+	}
+	it.err = err
+	return out, err
+}
+
 func (it *HasA) Err() error {
 	return it.err
 }
@@ -239,10 +257,8 @@ func (it *HasA) Result() graph.Value {
 // if there are many repeated values, it could be much smaller in totality.
 func (it *HasA) Stats() graph.IteratorStats {
 	subitStats := it.primaryIt.Stats()
-	// TODO(barakmich): These should really come from the quadstore itself
-	// and be optimized.
 	faninFactor := int64(1)
-	fanoutFactor := int64(30)
+	fanoutFactor := fanout(it.qs, it.dir, 30)
 	nextConstant := int64(2)
 	quadConstant := int64(1)
 	return graph.IteratorStats{
@@ -37,7 +37,7 @@ import (
 	"github.com/cayleygraph/cayley/quad"
 )
 
-var _ graph.Iterator = &LinksTo{}
+var _ graph.BatchIterator = &LinksTo{}
 
 // A LinksTo has a reference back to the graph.QuadStore (to create the iterators
 // for each node) the subiterator, and the direction the iterator comes from.
@@ -197,6 +197,50 @@ func (it *LinksTo) Close() error {
 }
 
 // We won't ever have a new result, but our subiterators might.
+// NextBatch returns batches of quads linked to the results of the primary iterator.
+func (it *LinksTo) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	var (
+		out   []graph.Value
+		fresh bool // primary iterator was advanced, but its paths were not checked yet
+	)
+	for len(out) < n {
+		quads, err := graph.NextBatch(ctx, it.nextIt, n-len(out))
+		got := len(quads)
+		it.runstats.Next += int64(got)
+		it.runstats.ContainsNext += int64(got)
+		if got != 0 && fresh {
+			// same as Next, each path of the primary iterator repeats the first quad
+			fresh = false
+			out = append(out, quads[0])
+			for it.primaryIt.NextPath(ctx) {
+				out = append(out, quads[0])
+			}
+			if err == nil {
+				err = it.primaryIt.Err()
+			}
+			quads = quads[1:]
+		}
+		out = append(out, quads...)
+		if err != nil {
+			it.err = err
+			break
+		} else if got != 0 {
+			continue
+		}
+		if !it.primaryIt.Next(ctx) {
+			it.err = it.primaryIt.Err()
+			break
+		}
+		it.nextIt.Close()
+		it.nextIt = it.qs.QuadIterator(it.dir, it.primaryIt.Result())
+		fresh = true
+	}
+	if len(out) != 0 {
+		it.result = out[len(out)-1]
+	}
+	return out, it.err
+}
+
 func (it *LinksTo) NextPath(ctx context.Context) bool {
 	ok := it.primaryIt.NextPath(ctx)
 	if !ok {
@@ -245,10 +289,19 @@ func (it *LinksTo) Size() (int64, bool) {
 		it.runstats.Size, it.runstats.ExactSize = sz, exact
 		return sz, exact
 	}
-	// TODO(barakmich): It should really come from the quadstore itself
-	const fanoutFactor = 20
 	sz, _ := it.primaryIt.Size()
-	sz *= fanoutFactor
+	sz *= fanout(it.qs, it.dir, 20)
 	it.runstats.Size, it.runstats.ExactSize = sz, false
 	return sz, false
 }
+
+// fanout returns the average number of quads per node in a given direction, from statistics of the quad store.
+// It returns a default value if the quad store was not analyzed.
+func fanout(qs graph.QuadStore, d quad.Direction, def int64) int64 {
+	if st := graph.StatisticsOf(qs); st != nil {
+		if n := st.Fanout(d); n > 0 {
+			return n
+		}
+	}
+	return def
+}
@@ -46,6 +46,7 @@ type Materialize struct {
 	aborted     bool
 	runstats    graph.IteratorStats
 	err         error
+	mem         memUsage
 }
 
 func NewMaterialize(sub graph.Iterator) *Materialize {
@@ -75,6 +76,7 @@ func (it *Materialize) Close() error {
 	it.containsMap = nil
 	it.values = nil
 	it.hasRun = false
+	it.mem.release()
 	return it.subIt.Close()
 }
 
@@ -272,6 +274,11 @@ func (it *Materialize) materializeSet(ctx context.Context) {
 			it.aborted = true
 			break
 		}
+		// results are not cached if there is not enough memory for them
+		if it.mem.reserve(ctx, 1) != nil {
+			it.aborted = true
+			break
+		}
 		id := it.subIt.Result()
 		val := graph.ToKey(id)
 		if _, ok := it.containsMap[val]; !ok {
@@ -288,7 +295,7 @@ func (it *Materialize) materializeSet(ctx context.Context) {
 		it.actualSize += 1
 		for it.subIt.NextPath(ctx) {
 			i++
-			if i > MaterializeLimit {
+			if i > MaterializeLimit || it.mem.reserve(ctx, 1) != nil {
 				it.aborted = true
 				break
 			}
@@ -308,6 +315,7 @@ func (it *Materialize) materializeSet(ctx context.Context) {
 		}
 		it.values = nil
 		it.containsMap = nil
+		it.mem.release()
 		it.subIt.Reset()
 	}
 	it.hasRun = true
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/internal/memory"
 )
 
 func TestMaterializeIteratorError(t *testing.T) {
@@ -74,3 +75,24 @@ func TestMaterializeIteratorErrorAbort(t *testing.T) {
 		t.Errorf("Materialize iterator did not pass through underlying Err")
 	}
 }
+
+func TestMaterializeIteratorMemoryLimit(t *testing.T) {
+	acc := memory.NewBudget(1 << 20).NewAccount(2 * memory.ValueSize)
+	ctx := memory.NewContext(context.TODO(), acc)
+
+	// Materialize falls back to the underlying iterator when it runs out of memory.
+	mIt := NewMaterialize(NewInt64(1, 5, true))
+	n := 0
+	for mIt.Next(ctx) {
+		n++
+	}
+	if n != 5 {
+		t.Errorf("expected 5 values, got %d", n)
+	}
+	if err := mIt.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if acc.Used() != 0 {
+		t.Errorf("memory was not released: %d", acc.Used())
+	}
+}
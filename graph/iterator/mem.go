@@ -0,0 +1,48 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/internal/memory"
+)
+
+// memUsage tracks memory kept by an iterator, reserved from the account of the query.
+type memUsage struct {
+	acc *memory.Account
+	n   int64
+}
+
+// reserve reserves memory for a given number of values.
+func (m *memUsage) reserve(ctx context.Context, values int) error {
+	if m.acc == nil {
+		if m.acc = memory.FromContext(ctx); m.acc == nil {
+			return nil
+		}
+	}
+	n := int64(values) * memory.ValueSize
+	if err := m.acc.Reserve(n); err != nil {
+		return err
+	}
+	m.n += n
+	return nil
+}
+
+// release returns all reserved memory.
+func (m *memUsage) release() {
+	m.acc.Release(m.n)
+	m.n = 0
+}
@@ -27,7 +27,7 @@ import (
 	"github.com/cayleygraph/cayley/graph"
 )
 
-var _ graph.Iterator = &Or{}
+var _ graph.BatchIterator = &Or{}
 
 type Or struct {
 	uid               uint64
@@ -37,6 +37,11 @@ type Or struct {
 	currentIterator   int
 	result            graph.Value
 	err               error
+
+	// set when subiterators run concurrently, see parallel.go
+	par     *parallel
+	paths   []map[string]graph.Value
+	curPath int
 }
 
 func NewOr(sub ...graph.Iterator) *Or {
@@ -66,6 +71,7 @@ func (it *Or) UID() uint64 {
 
 // Reset all internal iterators
 func (it *Or) Reset() {
+	it.stopParallel()
 	for _, sub := range it.internalIterators {
 		sub.Reset()
 	}
@@ -100,6 +106,12 @@ func (it *Or) SubIterators() []graph.Iterator {
 func (it *Or) TagResults(dst map[string]graph.Value) {
 	it.tags.TagResult(dst, it.Result())
 
+	if it.paths != nil {
+		for k, v := range it.paths[it.curPath] {
+			dst[k] = v
+		}
+		return
+	}
 	it.internalIterators[it.currentIterator].TagResults(dst)
 }
 
@@ -120,6 +132,15 @@ func (it *Or) Next(ctx context.Context) bool {
 		return false
 	}
 	graph.NextLogIn(it)
+	if it.currentIterator == -1 && !it.isShortCircuiting {
+		if n := parallelismFromContext(ctx); n > 1 && worthParallel(it.internalIterators) {
+			it.par = newParallel(ctx, it.internalIterators, n)
+			it.currentIterator = 0
+		}
+	}
+	if it.par != nil {
+		return graph.NextLogOut(it, it.nextParallel())
+	}
 	var first bool
 	for {
 		if it.currentIterator == -1 {
@@ -150,6 +171,61 @@ func (it *Or) Next(ctx context.Context) bool {
 	return graph.NextLogOut(it, false)
 }
 
+// NextBatch returns batches of subiterators in order. Short-circuiting and parallel iterators
+// return results one by one.
+func (it *Or) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	var out []graph.Value
+	if it.isShortCircuiting || it.par != nil || (it.currentIterator == -1 && parallelismFromContext(ctx) > 1) {
+		for len(out) < n && it.Next(ctx) {
+			out = append(out, it.Result())
+			for it.NextPath(ctx) {
+				out = append(out, it.Result())
+			}
+		}
+		return out, it.err
+	}
+	if it.currentIterator == -1 {
+		it.currentIterator = 0
+	}
+	for len(out) < n && it.currentIterator < len(it.internalIterators) {
+		vals, err := graph.NextBatch(ctx, it.internalIterators[it.currentIterator], n-len(out))
+		out = append(out, vals...)
+		if err != nil {
+			it.err = err
+			break
+		} else if len(vals) == 0 {
+			it.currentIterator++
+		}
+	}
+	if len(out) != 0 {
+		it.result = out[len(out)-1]
+	}
+	return out, it.err
+}
+
+// nextParallel reads the next result of concurrently running subiterators.
+func (it *Or) nextParallel() bool {
+	res, ok, err := it.par.next()
+	if !ok {
+		it.err = err
+		it.paths = nil
+		it.currentIterator = len(it.internalIterators)
+		return false
+	}
+	it.result = res.id
+	it.paths, it.curPath = res.paths, 0
+	return true
+}
+
+// stopParallel stops subiterators that run concurrently, if any.
+func (it *Or) stopParallel() {
+	if it.par != nil {
+		it.par.close()
+		it.par = nil
+	}
+	it.paths = nil
+}
+
 func (it *Or) Err() error {
 	return it.err
 }
@@ -179,6 +255,7 @@ func (it *Or) subItsContain(ctx context.Context, val graph.Value) (bool, error)
 // Check a value against the entire graph.iterator, in order.
 func (it *Or) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
+	it.paths = nil
 	anyGood, err := it.subItsContain(ctx, val)
 	if err != nil {
 		it.err = err
@@ -223,7 +300,14 @@ func (it *Or) Size() (int64, bool) {
 // subiterators might, however, so just pass the call recursively. In the case of
 // shortcircuiting, only allow new results from the currently checked graph.iterator
 func (it *Or) NextPath(ctx context.Context) bool {
-	if it.currentIterator != -1 {
+	if it.paths != nil {
+		if it.curPath+1 >= len(it.paths) {
+			return false
+		}
+		it.curPath++
+		return true
+	}
+	if it.currentIterator != -1 && it.currentIterator < len(it.internalIterators) {
 		currIt := it.internalIterators[it.currentIterator]
 		ok := currIt.NextPath(ctx)
 		if !ok {
@@ -234,8 +318,10 @@ func (it *Or) NextPath(ctx context.Context) bool {
 	return false
 }
 
-// Perform or-specific cleanup, of which there currently is none.
-func (it *Or) cleanUp() {}
+// Perform or-specific cleanup: stop subiterators that run concurrently.
+func (it *Or) cleanUp() {
+	it.stopParallel()
+}
 
 // Close this graph.iterator, and, by extension, close the subiterators.
 // Close should be idempotent, and it follows that if it's subiterators
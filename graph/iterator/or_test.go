@@ -204,3 +204,51 @@ func TestShortCircuitOrIteratorErr(t *testing.T) {
 		t.Errorf("Or iterator did not pass through underlying Err")
 	}
 }
+
+func TestOrIteratorParallel(t *testing.T) {
+	newOr := func() *Or {
+		var subs []graph.Iterator
+		for i := 0; i < 4; i++ {
+			var vals []graph.Value
+			for j := 0; j < 100; j++ {
+				vals = append(vals, Int64Node(i*1000+j))
+			}
+			sub := NewFixed(vals...)
+			sub.Tagger().Add("sub")
+			subs = append(subs, sub)
+		}
+		return NewOr(subs...)
+	}
+	collect := func(ctx context.Context, or *Or) []map[string]graph.Value {
+		var out []map[string]graph.Value
+		for or.Next(ctx) {
+			tags := make(map[string]graph.Value)
+			or.TagResults(tags)
+			out = append(out, tags)
+		}
+		if err := or.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	expect := collect(context.TODO(), newOr())
+	if len(expect) != 400 {
+		t.Fatalf("unexpected number of results: %d", len(expect))
+	}
+
+	ctx := NewParallelContext(context.TODO(), 2)
+	or := newOr()
+	for i := 0; i < 2; i++ {
+		if got := collect(ctx, or); !reflect.DeepEqual(got, expect) {
+			t.Errorf("Failed to iterate Or in parallel on repeat %d", i)
+		}
+		or.Reset()
+	}
+
+	// stop in the middle of the iteration
+	for i := 0; i < 150 && or.Next(ctx); i++ {
+	}
+	if err := or.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+const (
+	// ParallelMinCost is the minimal estimated cost of iterating a branch of an Or (the size of the branch
+	// multiplied by the cost of Next), for the branch to be run in parallel with the other ones.
+	ParallelMinCost = 1000
+
+	// parallelBuffer is the number of results each branch can produce ahead of the consumer.
+	parallelBuffer = 64
+)
+
+type parallelKey struct{}
+
+// NewParallelContext returns a context that allows iterators to run up to n independent branches
+// of the query concurrently. Branches run one by one if n is less than 2, which is the default.
+func NewParallelContext(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, parallelKey{}, n)
+}
+
+func parallelismFromContext(ctx context.Context) int {
+	n, _ := ctx.Value(parallelKey{}).(int)
+	return n
+}
+
+// expensive checks if an iterator costs enough to be run in parallel with other ones.
+func expensive(it graph.Iterator) bool {
+	st := it.Stats()
+	return st.NextCost*st.Size >= ParallelMinCost
+}
+
+// worthParallel checks if at least two of the iterators are expensive.
+func worthParallel(its []graph.Iterator) bool {
+	n := 0
+	for _, it := range its {
+		if expensive(it) {
+			if n++; n >= 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathsResult is a result of an iterator with tags of each of its paths.
+type pathsResult struct {
+	id    graph.Value
+	paths []map[string]graph.Value
+}
+
+// branch is a clone of a subiterator that runs in its own goroutine.
+type branch struct {
+	it  graph.Iterator
+	out chan pathsResult
+	err error // set before out is closed
+}
+
+func (b *branch) run(ctx context.Context) {
+	defer close(b.out)
+	defer b.it.Close()
+	for b.it.Next(ctx) {
+		res := pathsResult{id: b.it.Result()}
+		for {
+			tags := make(map[string]graph.Value)
+			b.it.TagResults(tags)
+			res.paths = append(res.paths, tags)
+			if !b.it.NextPath(ctx) {
+				break
+			}
+		}
+		select {
+		case b.out <- res:
+		case <-ctx.Done():
+			return
+		}
+	}
+	b.err = b.it.Err()
+}
+
+// parallel runs branches concurrently and returns their results in the order of branches.
+//
+// Branches are started in order, and at most n of them run at the same time. The consumer reads
+// the earliest unfinished branch, which is always running, so branches that are ahead only block
+// when their buffers are full.
+type parallel struct {
+	branches []*branch
+	cur      int
+	cancel   func()
+	wg       sync.WaitGroup
+}
+
+// newParallel starts clones of iterators. Iterators are cloned by the caller's goroutine.
+func newParallel(ctx context.Context, its []graph.Iterator, n int) *parallel {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &parallel{cancel: cancel}
+	for _, it := range its {
+		p.branches = append(p.branches, &branch{it: it.Clone(), out: make(chan pathsResult, parallelBuffer)})
+	}
+	sem := make(chan struct{}, n)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for i, b := range p.branches {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				// branches that were not started are closed here
+				for _, b := range p.branches[i:] {
+					b.it.Close()
+					close(b.out)
+				}
+				return
+			}
+			p.wg.Add(1)
+			go func(b *branch) {
+				defer p.wg.Done()
+				defer func() { <-sem }()
+				b.run(ctx)
+			}(b)
+		}
+	}()
+	return p
+}
+
+// next returns the next result, in the order of branches.
+func (p *parallel) next() (pathsResult, bool, error) {
+	for p.cur < len(p.branches) {
+		b := p.branches[p.cur]
+		if res, ok := <-b.out; ok {
+			return res, true, nil
+		} else if b.err != nil {
+			return pathsResult{}, false, b.err
+		}
+		p.cur++
+	}
+	return pathsResult{}, false, nil
+}
+
+// close stops all branches and waits for them to exit.
+func (p *parallel) close() {
+	p.cancel()
+	for _, b := range p.branches {
+		for range b.out {
+		}
+	}
+	p.wg.Wait()
+}
@@ -3,6 +3,7 @@ package iterator
 import (
 	"context"
 	"math"
+	"strconv"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/quad"
@@ -29,6 +30,8 @@ type Recursive struct {
 	depthTags     graph.Tagger
 	depthCache    []graph.Value
 	baseIt        graph.FixedIterator
+	pathTag       string
+	mem           memUsage
 }
 
 type seenAt struct {
@@ -70,6 +73,7 @@ func (it *Recursive) Reset() {
 	it.err = nil
 	it.subIt.Reset()
 	it.seen = make(map[interface{}]seenAt)
+	it.mem.release()
 	it.pathMap = make(map[interface{}][]map[string]graph.Value)
 	it.containsValue = nil
 	it.pathIndex = 0
@@ -86,9 +90,36 @@ func (it *Recursive) AddDepthTag(s string) {
 	it.depthTags.Add(s)
 }
 
+// SetPathTag sets a tag prefix for the chain of nodes that leads to each result. The start node is tagged
+// as the prefix followed by 0, and each next node as the prefix followed by its depth; the last one is the result itself.
+func (it *Recursive) SetPathTag(tag string) {
+	it.pathTag = tag
+}
+
+// tagPath tags the chain of nodes the current result was reached by.
+func (it *Recursive) tagPath(dst map[string]graph.Value) {
+	val := it.result.val
+	if val == nil {
+		return
+	}
+	at, ok := it.seen[graph.ToKey(val)]
+	if !ok {
+		return
+	}
+	for d := at.depth; d > 0; d-- {
+		dst[it.pathTag+strconv.Itoa(d)] = val
+		val = at.val
+		at = it.seen[graph.ToKey(val)]
+	}
+	dst[it.pathTag+"0"] = val
+}
+
 func (it *Recursive) TagResults(dst map[string]graph.Value) {
 	it.tags.TagResult(dst, it.Result())
 	it.depthTags.TagResult(dst, graph.PreFetched(quad.Int(it.result.depth)))
+	if it.pathTag != "" {
+		it.tagPath(dst)
+	}
 
 	if it.containsValue != nil {
 		paths := it.pathMap[graph.ToKey(it.containsValue)]
@@ -108,6 +139,7 @@ func (it *Recursive) Clone() graph.Iterator {
 	n := NewRecursive(it.qs, it.subIt.Clone(), it.morphism, it.maxDepth)
 	n.tags.CopyFrom(it)
 	n.depthTags.CopyFromTagger(&it.depthTags)
+	n.pathTag = it.pathTag
 	return n
 }
 
@@ -155,6 +187,10 @@ func (it *Recursive) Next(ctx context.Context) bool {
 		it.nextIt.TagResults(results)
 		key := graph.ToKey(val)
 		if _, seen := it.seen[key]; !seen {
+			// a value is kept in the seen set and in the cache of the next depth
+			if it.err = it.mem.reserve(ctx, 2); it.err != nil {
+				return graph.NextLogOut(it, false)
+			}
 			it.seen[key] = seenAt{
 				val:   results["__base_recursive"],
 				depth: it.depth,
@@ -227,6 +263,7 @@ func (it *Recursive) Close() error {
 		return err
 	}
 	it.seen = nil
+	it.mem.release()
 	return it.err
 }
 
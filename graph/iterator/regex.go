@@ -107,6 +107,7 @@ func (it *Regex) Tagger() *graph.Tagger {
 
 func (it *Regex) Clone() graph.Iterator {
 	out := NewRegex(it.subIt.Clone(), it.re, it.qs)
+	out.allowRefs = it.allowRefs
 	out.tags.CopyFrom(it)
 	return out
 }
@@ -157,6 +158,8 @@ func (it *Regex) Contains(ctx context.Context, val graph.Value) bool {
 	ok := it.subIt.Contains(ctx, val)
 	if !ok {
 		it.err = it.subIt.Err()
+	} else {
+		it.result = val
 	}
 	return ok
 }
@@ -0,0 +1,77 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = &Scored{}
+
+// Scored is a fixed iterator that also tags each value with its score, for example, a relevance
+// of a full-text match. If the tag is empty, scores are not tagged.
+type Scored struct {
+	*Fixed
+	tag    string
+	scores map[interface{}]float64
+}
+
+// NewScored creates an empty iterator that tags scores of values with a given tag.
+func NewScored(tag string) *Scored {
+	return &Scored{Fixed: NewFixed(), tag: tag, scores: make(map[interface{}]float64)}
+}
+
+// AddScored adds a value with its score to the iterator.
+func (it *Scored) AddScored(v graph.Value, score float64) {
+	it.Fixed.Add(v)
+	it.scores[graph.ToKey(v)] = score
+}
+
+// Score returns a score of the current result.
+func (it *Scored) Score() float64 {
+	return it.scores[graph.ToKey(it.Result())]
+}
+
+func (it *Scored) TagResults(dst map[string]graph.Value) {
+	it.Fixed.TagResults(dst)
+	if it.tag != "" && it.Result() != nil {
+		dst[it.tag] = graph.PreFetched(quad.Float(it.Score()))
+	}
+}
+
+func (it *Scored) Clone() graph.Iterator {
+	out := NewScored(it.tag)
+	for _, v := range it.Values() {
+		out.AddScored(v, it.scores[graph.ToKey(v)])
+	}
+	out.tags.CopyFrom(it)
+	return out
+}
+
+func (it *Scored) Type() graph.Type { return graph.Scored }
+
+func (it *Scored) Optimize() (graph.Iterator, bool) {
+	if len(it.Values()) == 0 {
+		return NewNull(), true
+	}
+	return it, false
+}
+
+func (it *Scored) String() string {
+	return fmt.Sprintf("Scored(%d, %q)", len(it.Values()), it.tag)
+}
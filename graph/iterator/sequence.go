@@ -0,0 +1,304 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = &Sequence{}
+
+// Sequence iterator returns events of each node of the sub-iterator, ordered by their time.
+// Events of a node are found with a morphism that must save the time of each event to a tag;
+// events without it are skipped. The tag itself is not returned, see SetTimeTag. Values are compared in the same way as in the Sort iterator.
+//
+// Sequences are returned one after another, in the order of the sub-iterator. Only the sequence
+// of the current node is kept in memory.
+type Sequence struct {
+	uid      uint64
+	tags     graph.Tagger
+	subIt    graph.Iterator
+	qs       graph.QuadStore
+	events   graph.ApplyMorphism
+	timeTag  string // set by the events morphism
+	outTag   string
+	indexTag string
+	desc     bool
+	skip     int64
+	limit    int64
+	runstats graph.IteratorStats
+	err      error
+
+	base  map[string]graph.Value // tags of the current node
+	seq   []seqEvent
+	index int
+	event *seqEvent
+
+	contains graph.Iterator // a copy of the sub-iterator used by Contains
+}
+
+type seqEvent struct {
+	id   graph.Value
+	pos  int64 // position in the whole sequence, before skip and limit
+	time graph.Value
+	tags map[string]graph.Value
+}
+
+// NewSequence creates an iterator that returns events of each node of the sub-iterator, ordered by
+// the values saved to timeTag by the events morphism, in a descending order if desc is set.
+//
+// Skip and limit select a window of each sequence; a zero limit means no limit.
+func NewSequence(qs graph.QuadStore, sub graph.Iterator, events graph.ApplyMorphism, timeTag string, desc bool, skip, limit int64) *Sequence {
+	return &Sequence{
+		uid:     NextUID(),
+		subIt:   sub,
+		qs:      qs,
+		events:  events,
+		timeTag: timeTag,
+		desc:    desc,
+		skip:    skip,
+		limit:   limit,
+	}
+}
+
+// SetTimeTag sets a tag for the time of each event.
+func (it *Sequence) SetTimeTag(tag string) {
+	it.outTag = tag
+}
+
+// SetIndexTag sets a tag for the position of each event in its sequence, starting from zero.
+// Skipped events are counted as well.
+func (it *Sequence) SetIndexTag(tag string) {
+	it.indexTag = tag
+}
+
+func (it *Sequence) UID() uint64 {
+	return it.uid
+}
+
+func (it *Sequence) Reset() {
+	it.subIt.Reset()
+	it.err = nil
+	it.base, it.seq, it.index, it.event = nil, nil, 0, nil
+	if it.contains != nil {
+		it.contains.Close()
+		it.contains = nil
+	}
+}
+
+func (it *Sequence) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Sequence) TagResults(dst map[string]graph.Value) {
+	if it.event == nil {
+		return
+	}
+	for k, v := range it.base {
+		dst[k] = v
+	}
+	for k, v := range it.event.tags {
+		if k != it.timeTag {
+			dst[k] = v
+		}
+	}
+	if it.outTag != "" {
+		dst[it.outTag] = it.event.time
+	}
+	if it.indexTag != "" {
+		dst[it.indexTag] = graph.PreFetched(quad.Int(it.event.pos))
+	}
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *Sequence) Clone() graph.Iterator {
+	n := NewSequence(it.qs, it.subIt.Clone(), it.events, it.timeTag, it.desc, it.skip, it.limit)
+	n.outTag, n.indexTag = it.outTag, it.indexTag
+	n.tags.CopyFrom(it)
+	return n
+}
+
+func (it *Sequence) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.subIt}
+}
+
+// load reads and orders events of a node, and keeps only the selected window of the sequence.
+func (it *Sequence) load(ctx context.Context, node graph.Value) ([]seqEvent, error) {
+	sub := it.events(it.qs, NewFixed(node))
+	defer sub.Close()
+	var (
+		seq   []seqEvent
+		times []quad.Value
+	)
+	for sub.Next(ctx) {
+		for {
+			tags := make(map[string]graph.Value)
+			sub.TagResults(tags)
+			if t, ok := tags[it.timeTag]; ok {
+				seq = append(seq, seqEvent{id: sub.Result(), time: t, tags: tags})
+				times = append(times, it.qs.NameOf(t))
+			}
+			if !sub.NextPath(ctx) {
+				break
+			}
+		}
+	}
+	if err := sub.Err(); err != nil {
+		return nil, err
+	}
+	order := make([]int, len(seq))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		c := compareValues(times[order[i]], times[order[j]])
+		if it.desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	out := make([]seqEvent, 0, len(seq))
+	for pos, i := range order {
+		if int64(pos) < it.skip {
+			continue
+		} else if it.limit > 0 && int64(len(out)) >= it.limit {
+			break
+		}
+		ev := seq[i]
+		ev.pos = int64(pos)
+		out = append(out, ev)
+	}
+	return out, nil
+}
+
+// nextEvent moves to the next event of sequences of nodes returned by sub.
+func (it *Sequence) nextEvent(ctx context.Context, sub graph.Iterator) bool {
+	for it.index >= len(it.seq) {
+		if !sub.Next(ctx) {
+			it.err = sub.Err()
+			it.event = nil
+			return false
+		}
+		it.base = make(map[string]graph.Value)
+		sub.TagResults(it.base)
+		it.seq, it.err = it.load(ctx, sub.Result())
+		if it.err != nil {
+			it.event = nil
+			return false
+		}
+		it.index = 0
+	}
+	it.event = &it.seq[it.index]
+	it.index++
+	return true
+}
+
+func (it *Sequence) Next(ctx context.Context) bool {
+	it.runstats.Next += 1
+	return graph.NextLogOut(it, it.nextEvent(ctx, it.subIt))
+}
+
+func (it *Sequence) Err() error {
+	return it.err
+}
+
+func (it *Sequence) Result() graph.Value {
+	if it.event == nil {
+		return nil
+	}
+	return it.event.id
+}
+
+// Contains checks if the value is an event of any sequence. It has to walk sequences of all nodes, thus
+// the iterator should only be used as a primary one.
+func (it *Sequence) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	if it.contains != nil {
+		it.contains.Close()
+	}
+	it.contains = it.subIt.Clone()
+	it.seq, it.index = nil, 0
+	return graph.ContainsLogOut(it, val, it.nextMatch(ctx, graph.ToKey(val)))
+}
+
+func (it *Sequence) nextMatch(ctx context.Context, key interface{}) bool {
+	for it.nextEvent(ctx, it.contains) {
+		if graph.ToKey(it.event.id) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// NextPath returns the same event in sequences of other nodes, after a call to Contains.
+func (it *Sequence) NextPath(ctx context.Context) bool {
+	if it.contains == nil || it.event == nil {
+		return false
+	}
+	return it.nextMatch(ctx, graph.ToKey(it.event.id))
+}
+
+func (it *Sequence) Close() error {
+	err := it.subIt.Close()
+	if it.contains != nil {
+		it.contains.Close()
+		it.contains = nil
+	}
+	it.base, it.seq = nil, nil
+	if err != nil {
+		return err
+	}
+	return it.err
+}
+
+func (it *Sequence) Type() graph.Type { return graph.Sequence }
+
+func (it *Sequence) Optimize() (graph.Iterator, bool) {
+	if nit, ok := it.subIt.Optimize(); ok {
+		it.subIt = nit
+	}
+	return it, false
+}
+
+func (it *Sequence) Size() (int64, bool) {
+	return it.Stats().Size, false
+}
+
+func (it *Sequence) Stats() graph.IteratorStats {
+	base := NewFixed(Int64Node(20))
+	fanout := it.events(it.qs, base).Stats()
+	st := it.subIt.Stats()
+	size := fanout.Size
+	if it.limit > 0 && size > it.limit {
+		size = it.limit
+	}
+	return graph.IteratorStats{
+		NextCost:     st.NextCost + fanout.NextCost*fanout.Size,
+		ContainsCost: st.Size * (st.NextCost + fanout.NextCost*fanout.Size),
+		Size:         st.Size * size,
+		Next:         it.runstats.Next,
+		Contains:     it.runstats.Contains,
+		ContainsNext: it.runstats.ContainsNext,
+	}
+}
+
+func (it *Sequence) String() string {
+	return "Sequence(" + it.timeTag + ")"
+}
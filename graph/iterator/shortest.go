@@ -0,0 +1,394 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = &ShortestPath{}
+
+// spBaseTag marks the frontier nodes, so the node each step was made from can be recovered.
+const spBaseTag = "__base_shortest"
+
+// ShortestPath iterator finds the shortest path from each node of the first sub-iterator to each node
+// of the second one, following a morphism. Results are the nodes of the second iterator, once for each
+// node of the first iterator they are reachable from.
+//
+// Paths are found with a bidirectional breadth-first search, so the reverse of the morphism
+// must be provided as well.
+type ShortestPath struct {
+	uid      uint64
+	tags     graph.Tagger
+	from, to graph.Iterator
+	runstats graph.IteratorStats
+	err      error
+
+	qs       graph.QuadStore
+	fwd, rev graph.ApplyMorphism
+	maxDepth int
+	hopTag   string
+
+	weight    Weight // set for weighted paths
+	heuristic Heuristic
+	costTag   string
+
+	loaded  bool
+	sources []spNode
+	targets []spNode
+	si, ti  int
+
+	source, target *spNode
+	path           []graph.Value // current path, from the source to the target
+	cost           float64
+
+	contains spNode // node checked by Contains
+	ci       int    // next source to check for it
+}
+
+type spNode struct {
+	val  graph.Value
+	tags map[string]graph.Value
+}
+
+// spStep is a node reached by the search. Prev is the node it was reached from, in the direction of the search.
+type spStep struct {
+	prev  graph.Value
+	depth int
+}
+
+// NewShortestPath creates an iterator that finds the shortest paths from nodes of one iterator to nodes
+// of the other, by following the morphism fwd, or rev in the reverse direction. A maxDepth limits the number
+// of steps in each path in the same way as in the Recursive iterator.
+func NewShortestPath(qs graph.QuadStore, from, to graph.Iterator, fwd, rev graph.ApplyMorphism, maxDepth int) *ShortestPath {
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxRecursiveSteps
+	}
+	return &ShortestPath{
+		uid:      NextUID(),
+		from:     from,
+		to:       to,
+		qs:       qs,
+		fwd:      fwd,
+		rev:      rev,
+		maxDepth: maxDepth,
+	}
+}
+
+// SetHopTag sets a tag prefix for nodes of each path. A node at step i of the path is tagged
+// as the prefix followed by i; zero is the first node and the last one is the result.
+func (it *ShortestPath) SetHopTag(tag string) {
+	it.hopTag = tag
+}
+
+func (it *ShortestPath) UID() uint64 {
+	return it.uid
+}
+
+func (it *ShortestPath) Reset() {
+	it.from.Reset()
+	it.to.Reset()
+	it.err = nil
+	it.loaded = false
+	it.sources, it.targets = nil, nil
+	it.si, it.ti = 0, 0
+	it.source, it.target = nil, nil
+	it.path, it.cost = nil, 0
+}
+
+func (it *ShortestPath) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *ShortestPath) TagResults(dst map[string]graph.Value) {
+	if it.source == nil || it.target == nil {
+		return
+	}
+	for k, v := range it.source.tags {
+		dst[k] = v
+	}
+	for k, v := range it.target.tags {
+		dst[k] = v
+	}
+	if it.hopTag != "" {
+		for i, v := range it.path {
+			dst[it.hopTag+strconv.Itoa(i)] = v
+		}
+	}
+	if it.costTag != "" {
+		dst[it.costTag] = graph.PreFetched(quad.Float(it.cost))
+	}
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *ShortestPath) Clone() graph.Iterator {
+	n := NewShortestPath(it.qs, it.from.Clone(), it.to.Clone(), it.fwd, it.rev, it.maxDepth)
+	n.hopTag = it.hopTag
+	n.weight, n.heuristic, n.costTag = it.weight, it.heuristic, it.costTag
+	n.tags.CopyFrom(it)
+	return n
+}
+
+func (it *ShortestPath) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.from, it.to}
+}
+
+// collect reads all distinct nodes of the iterator with their tags.
+func collect(ctx context.Context, it graph.Iterator) ([]spNode, error) {
+	var out []spNode
+	seen := make(map[interface{}]struct{})
+	for it.Next(ctx) {
+		v := it.Result()
+		k := graph.ToKey(v)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		tags := make(map[string]graph.Value)
+		it.TagResults(tags)
+		out = append(out, spNode{val: v, tags: tags})
+	}
+	return out, it.Err()
+}
+
+func (it *ShortestPath) load(ctx context.Context) bool {
+	if it.loaded {
+		return it.err == nil
+	}
+	it.loaded = true
+	it.sources, it.err = collect(ctx, it.from)
+	return it.err == nil
+}
+
+func (it *ShortestPath) Next(ctx context.Context) bool {
+	it.runstats.Next += 1
+	if !it.load(ctx) {
+		return graph.NextLogOut(it, false)
+	}
+	if it.targets == nil {
+		if it.targets, it.err = collect(ctx, it.to); it.err != nil {
+			return graph.NextLogOut(it, false)
+		}
+	}
+	it.target = nil
+	for ; it.ti < len(it.targets); it.ti, it.si = it.ti+1, 0 {
+		t := &it.targets[it.ti]
+		for it.si < len(it.sources) {
+			s := &it.sources[it.si]
+			it.si++
+			if p, cost := it.search(ctx, s.val, t.val); p != nil {
+				it.source, it.target, it.path, it.cost = s, t, p, cost
+				return graph.NextLogOut(it, true)
+			} else if it.err != nil {
+				return graph.NextLogOut(it, false)
+			}
+		}
+	}
+	return graph.NextLogOut(it, false)
+}
+
+// search returns the shortest path from s to t and its cost, or nil if t is not reachable.
+func (it *ShortestPath) search(ctx context.Context, s, t graph.Value) ([]graph.Value, float64) {
+	if it.weight != nil {
+		return it.searchWeighted(ctx, s, t)
+	}
+	p := it.searchBFS(ctx, s, t)
+	return p, float64(len(p) - 1)
+}
+
+// searchBFS returns one of the paths from s to t with the least number of steps, or nil if t is not reachable.
+func (it *ShortestPath) searchBFS(ctx context.Context, s, t graph.Value) []graph.Value {
+	ks, kt := graph.ToKey(s), graph.ToKey(t)
+	if ks == kt {
+		return []graph.Value{s}
+	}
+	fwd := map[interface{}]spStep{ks: {}}
+	rev := map[interface{}]spStep{kt: {}}
+	ff, rf := []graph.Value{s}, []graph.Value{t}
+	for depth := 0; len(ff) != 0 && len(rf) != 0; depth++ {
+		if it.maxDepth > 0 && depth >= it.maxDepth {
+			return nil
+		}
+		var meet graph.Value
+		// expand the smaller side, this is what makes the bidirectional search cheaper
+		if len(ff) <= len(rf) {
+			ff, meet = it.expand(ctx, it.fwd, ff, fwd, rev)
+		} else {
+			rf, meet = it.expand(ctx, it.rev, rf, rev, fwd)
+		}
+		if it.err != nil {
+			return nil
+		} else if meet == nil {
+			continue
+		}
+		var path []graph.Value
+		for k, v := graph.ToKey(meet), meet; v != nil; {
+			path = append(path, v)
+			v = fwd[k].prev
+			k = graph.ToKey(v)
+		}
+		for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+			path[i], path[j] = path[j], path[i]
+		}
+		for v := rev[graph.ToKey(meet)].prev; v != nil; v = rev[graph.ToKey(v)].prev {
+			path = append(path, v)
+		}
+		return path
+	}
+	return nil
+}
+
+// expand makes one step of the search from all frontier nodes and returns the next frontier.
+// If any of the new nodes was already reached from the other side, the one closest to the other end is returned.
+func (it *ShortestPath) expand(ctx context.Context, m graph.ApplyMorphism, frontier []graph.Value, seen, other map[interface{}]spStep) ([]graph.Value, graph.Value) {
+	base := NewFixed(frontier...)
+	base.Tagger().Add(spBaseTag)
+	sub := m(it.qs, base)
+	defer sub.Close()
+	var (
+		next []graph.Value
+		meet graph.Value
+		best = -1
+	)
+	tags := make(map[string]graph.Value)
+	for sub.Next(ctx) {
+		v := sub.Result()
+		k := graph.ToKey(v)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		for t := range tags {
+			delete(tags, t)
+		}
+		sub.TagResults(tags)
+		prev, ok := tags[spBaseTag]
+		if !ok {
+			continue
+		}
+		seen[k] = spStep{prev: prev, depth: seen[graph.ToKey(prev)].depth + 1}
+		next = append(next, v)
+		if o, ok := other[k]; ok && (best < 0 || o.depth < best) {
+			meet, best = v, o.depth
+		}
+	}
+	if err := sub.Err(); err != nil {
+		it.err = err
+	} else if err = ctx.Err(); err != nil {
+		it.err = err
+	}
+	return next, meet
+}
+
+func (it *ShortestPath) Err() error {
+	return it.err
+}
+
+func (it *ShortestPath) Result() graph.Value {
+	if it.target == nil {
+		return nil
+	}
+	return it.target.val
+}
+
+func (it *ShortestPath) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	it.target = nil
+	if !it.load(ctx) || !it.to.Contains(ctx, val) {
+		return graph.ContainsLogOut(it, val, false)
+	}
+	tags := make(map[string]graph.Value)
+	it.to.TagResults(tags)
+	it.contains = spNode{val: it.to.Result(), tags: tags}
+	it.ci = 0
+	return graph.ContainsLogOut(it, val, it.nextSource(ctx))
+}
+
+// nextSource finds a path to the node checked by Contains from the next source node.
+func (it *ShortestPath) nextSource(ctx context.Context) bool {
+	for it.ci < len(it.sources) {
+		s := &it.sources[it.ci]
+		it.ci++
+		if p, cost := it.search(ctx, s.val, it.contains.val); p != nil {
+			it.source, it.target, it.path, it.cost = s, &it.contains, p, cost
+			return true
+		} else if it.err != nil {
+			break
+		}
+	}
+	it.target = nil
+	return false
+}
+
+// NextPath returns paths to the same node from other source nodes, after a call to Contains.
+// It yields nothing after Next: all sources are returned by Next as separate results.
+func (it *ShortestPath) NextPath(ctx context.Context) bool {
+	if it.target == nil || it.target != &it.contains {
+		return false
+	}
+	return it.nextSource(ctx)
+}
+
+func (it *ShortestPath) Close() error {
+	err := it.from.Close()
+	if err2 := it.to.Close(); err == nil {
+		err = err2
+	}
+	it.sources, it.targets = nil, nil
+	if err != nil {
+		return err
+	}
+	return it.err
+}
+
+func (it *ShortestPath) Type() graph.Type { return graph.ShortestPath }
+
+func (it *ShortestPath) Optimize() (graph.Iterator, bool) {
+	if nit, ok := it.from.Optimize(); ok {
+		it.from = nit
+	}
+	if nit, ok := it.to.Optimize(); ok {
+		it.to = nit
+	}
+	return it, false
+}
+
+func (it *ShortestPath) Size() (int64, bool) {
+	return it.Stats().Size, false
+}
+
+func (it *ShortestPath) Stats() graph.IteratorStats {
+	base := NewFixed(Int64Node(20))
+	fanout := it.fwd(it.qs, base).Stats()
+	fst, tst := it.from.Stats(), it.to.Stats()
+	// each search walks a part of the graph around both ends
+	search := fanout.NextCost * fanout.Size * fanout.Size
+	return graph.IteratorStats{
+		NextCost:     fst.NextCost + tst.NextCost + fst.Size*search,
+		ContainsCost: tst.ContainsCost + fst.Size*search,
+		Size:         fst.Size * tst.Size,
+		Next:         it.runstats.Next,
+		Contains:     it.runstats.Contains,
+		ContainsNext: it.runstats.ContainsNext,
+	}
+}
+
+func (it *ShortestPath) String() string {
+	return "ShortestPath"
+}
@@ -0,0 +1,305 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.Iterator = &Sort{}
+
+// Sort iterator returns all results of the sub-iterator ordered by their values.
+//
+// Numbers are ordered by their value and go first, followed by times, and all other values
+// ordered by their string representation. Results with equal values keep the order of the sub-iterator.
+//
+// All results are read from the sub-iterator on the first call to Next.
+type Sort struct {
+	uid      uint64
+	tags     graph.Tagger
+	subIt    graph.Iterator
+	qs       graph.QuadStore
+	desc     bool
+	tag      string // sort by values of this tag instead of results
+	runstats graph.IteratorStats
+	err      error
+
+	sorted   bool
+	results  []sortResult
+	index    int
+	path     int
+	contains bool // last result came from Contains
+	mem      memUsage
+}
+
+type sortResult struct {
+	id    graph.Value
+	val   quad.Value
+	paths []map[string]graph.Value
+}
+
+// NewSort creates an iterator that orders results of the sub-iterator, in a descending order if desc is set.
+func NewSort(qs graph.QuadStore, sub graph.Iterator, desc bool) *Sort {
+	return &Sort{
+		uid:   NextUID(),
+		subIt: sub,
+		qs:    qs,
+		desc:  desc,
+		index: -1,
+	}
+}
+
+// NewSortByTag creates an iterator that orders results of the sub-iterator by values of a given tag.
+// If a result has multiple paths, the first value in the sort order is used. Results without the tag go last.
+func NewSortByTag(qs graph.QuadStore, sub graph.Iterator, tag string, desc bool) *Sort {
+	it := NewSort(qs, sub, desc)
+	it.tag = tag
+	return it
+}
+
+func (it *Sort) UID() uint64 {
+	return it.uid
+}
+
+func (it *Sort) Reset() {
+	it.subIt.Reset()
+	it.err = nil
+	it.index, it.path = -1, 0
+	it.contains = false
+}
+
+func (it *Sort) Tagger() *graph.Tagger {
+	return &it.tags
+}
+
+func (it *Sort) TagResults(dst map[string]graph.Value) {
+	if it.contains {
+		it.subIt.TagResults(dst)
+	} else if it.index >= 0 && it.index < len(it.results) {
+		for k, v := range it.results[it.index].paths[it.path] {
+			dst[k] = v
+		}
+	}
+	it.tags.TagResult(dst, it.Result())
+}
+
+func (it *Sort) Clone() graph.Iterator {
+	n := NewSort(it.qs, it.subIt.Clone(), it.desc)
+	n.tag = it.tag
+	n.tags.CopyFrom(it)
+	return n
+}
+
+func (it *Sort) SubIterators() []graph.Iterator {
+	return []graph.Iterator{it.subIt}
+}
+
+// compareValues orders values for the Sort iterator.
+func compareValues(a, b quad.Value) int {
+	rank := func(v quad.Value) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case quad.Int, quad.Float:
+			return 1
+		case quad.Time:
+			return 2
+		}
+		return 3
+	}
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case nil:
+		return 0
+	case quad.Int, quad.Float:
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case quad.Time:
+		ta, tb := time.Time(a), time.Time(b.(quad.Time))
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(quad.StringOf(a), quad.StringOf(b))
+}
+
+func toFloat(v quad.Value) float64 {
+	if i, ok := v.(quad.Int); ok {
+		return float64(i)
+	}
+	return float64(v.(quad.Float))
+}
+
+func (it *Sort) materialize(ctx context.Context) {
+	it.sorted = true
+	for it.subIt.Next(ctx) {
+		id := it.subIt.Result()
+		r := sortResult{id: id}
+		if it.tag == "" {
+			r.val = it.qs.NameOf(id)
+		}
+		for {
+			if it.err = it.mem.reserve(ctx, 1); it.err != nil {
+				return
+			}
+			tags := make(map[string]graph.Value)
+			it.subIt.TagResults(tags)
+			r.paths = append(r.paths, tags)
+			if it.tag != "" {
+				if v, ok := tags[it.tag]; ok && v != nil {
+					r.val = it.better(r.val, it.qs.NameOf(v))
+				}
+			}
+			if !it.subIt.NextPath(ctx) {
+				break
+			}
+		}
+		it.results = append(it.results, r)
+	}
+	if it.err = it.subIt.Err(); it.err != nil {
+		return
+	}
+	sort.SliceStable(it.results, func(i, j int) bool {
+		a, b := it.results[i].val, it.results[j].val
+		if it.tag != "" && (a == nil || b == nil) {
+			// missing tags go last in both orders
+			return a != nil && b == nil
+		}
+		c := compareValues(a, b)
+		if it.desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// better returns a value that goes first in the sort order.
+func (it *Sort) better(cur, v quad.Value) quad.Value {
+	if cur == nil {
+		return v
+	}
+	c := compareValues(v, cur)
+	if (it.desc && c > 0) || (!it.desc && c < 0) {
+		return v
+	}
+	return cur
+}
+
+func (it *Sort) Next(ctx context.Context) bool {
+	it.runstats.Next += 1
+	it.contains = false
+	if !it.sorted {
+		it.materialize(ctx)
+	}
+	if it.err != nil || it.index+1 >= len(it.results) {
+		it.index = len(it.results)
+		return graph.NextLogOut(it, false)
+	}
+	it.index++
+	it.path = 0
+	return graph.NextLogOut(it, true)
+}
+
+func (it *Sort) NextPath(ctx context.Context) bool {
+	if it.contains {
+		return it.subIt.NextPath(ctx)
+	}
+	if it.index < 0 || it.index >= len(it.results) || it.path+1 >= len(it.results[it.index].paths) {
+		return false
+	}
+	it.path++
+	return true
+}
+
+func (it *Sort) Err() error {
+	return it.err
+}
+
+func (it *Sort) Result() graph.Value {
+	if it.contains {
+		return it.subIt.Result()
+	}
+	if it.index < 0 || it.index >= len(it.results) {
+		return nil
+	}
+	return it.results[it.index].id
+}
+
+// Contains checks the sub-iterator directly: the order of results doesn't matter for it.
+func (it *Sort) Contains(ctx context.Context, val graph.Value) bool {
+	graph.ContainsLogIn(it, val)
+	it.runstats.Contains += 1
+	it.contains = true
+	return graph.ContainsLogOut(it, val, it.subIt.Contains(ctx, val))
+}
+
+func (it *Sort) Close() error {
+	it.results = nil
+	it.mem.release()
+	return it.subIt.Close()
+}
+
+func (it *Sort) Type() graph.Type { return graph.Sort }
+
+func (it *Sort) Optimize() (graph.Iterator, bool) {
+	if nit, ok := it.subIt.Optimize(); ok {
+		it.subIt = nit
+	}
+	return it, false
+}
+
+func (it *Sort) Size() (int64, bool) {
+	return it.subIt.Size()
+}
+
+func (it *Sort) Stats() graph.IteratorStats {
+	st := it.subIt.Stats()
+	// all results are read and sorted before the first one is returned
+	st.NextCost += 1
+	st.Next = it.runstats.Next
+	st.Contains = it.runstats.Contains
+	st.ContainsNext = it.runstats.ContainsNext
+	return st
+}
+
+func (it *Sort) String() string {
+	s := "Sort"
+	if it.tag != "" {
+		s += "(" + it.tag + ")"
+	}
+	if it.desc {
+		s += "(desc)"
+	}
+	return s
+}
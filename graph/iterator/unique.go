@@ -17,6 +17,7 @@ type Unique struct {
 	runstats graph.IteratorStats
 	err      error
 	seen     map[interface{}]bool
+	mem      memUsage
 }
 
 func NewUnique(subIt graph.Iterator) *Unique {
@@ -36,6 +37,7 @@ func (it *Unique) Reset() {
 	it.result = nil
 	it.subIt.Reset()
 	it.seen = make(map[interface{}]bool)
+	it.mem.release()
 }
 
 func (it *Unique) Tagger() *graph.Tagger {
@@ -72,6 +74,9 @@ func (it *Unique) Next(ctx context.Context) bool {
 		curr := it.subIt.Result()
 		key := graph.ToKey(curr)
 		if ok := it.seen[key]; !ok {
+			if it.err = it.mem.reserve(ctx, 1); it.err != nil {
+				return graph.NextLogOut(it, false)
+			}
 			it.result = curr
 			it.seen[key] = true
 			return graph.NextLogOut(it, true)
@@ -94,7 +99,11 @@ func (it *Unique) Result() graph.Value {
 func (it *Unique) Contains(ctx context.Context, val graph.Value) bool {
 	graph.ContainsLogIn(it, val)
 	it.runstats.Contains += 1
-	return graph.ContainsLogOut(it, val, it.subIt.Contains(ctx, val))
+	ok := it.subIt.Contains(ctx, val)
+	if ok {
+		it.result = val
+	}
+	return graph.ContainsLogOut(it, val, ok)
 }
 
 // NextPath for unique always returns false. If we were to return multiple
@@ -107,6 +116,7 @@ func (it *Unique) NextPath(ctx context.Context) bool {
 // Close closes the primary iterators.
 func (it *Unique) Close() error {
 	it.seen = nil
+	it.mem.release()
 	return it.subIt.Close()
 }
 
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	. "github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/internal/memory"
 )
 
 func TestUniqueIteratorBasics(t *testing.T) {
@@ -34,3 +35,29 @@ func TestUniqueIteratorBasics(t *testing.T) {
 		}
 	}
 }
+
+func TestUniqueIteratorMemoryLimit(t *testing.T) {
+	acc := memory.NewBudget(1 << 20).NewAccount(2 * memory.ValueSize)
+	ctx := memory.NewContext(context.TODO(), acc)
+	u := NewUnique(NewFixed(
+		Int64Node(1),
+		Int64Node(1),
+		Int64Node(2),
+		Int64Node(3),
+	))
+
+	n := 0
+	for u.Next(ctx) {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 values before the limit, got %d", n)
+	}
+	if u.Err() != memory.ErrQueryLimit {
+		t.Errorf("expected a memory limit error, got %v", u.Err())
+	}
+	u.Close()
+	if acc.Used() != 0 {
+		t.Errorf("memory was not released: %d", acc.Used())
+	}
+}
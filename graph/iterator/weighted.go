@@ -0,0 +1,149 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// EdgeTag is the tag a step morphism of a weighted path sets on the edge node it passes through,
+// if edges are reified. The tagged value is passed to the Weight function.
+const EdgeTag = "__edge"
+
+// Weight returns the cost of a step from one node to another. Edge is the node tagged with EdgeTag
+// by the step morphism, or nil. If ok is false, the step cannot be made.
+//
+// Weights must not be negative.
+type Weight func(from, edge, to graph.Value) (w float64, ok bool)
+
+// Heuristic estimates the cost of the path from a node to the target. It turns the search into A*.
+// To find the shortest path, the estimate must never exceed the actual cost, and must not decrease
+// by more than the weight of a step made towards the target.
+type Heuristic func(node, target graph.Value) float64
+
+// NewWeightedPath creates an iterator that finds paths with the least total cost from nodes of one iterator
+// to nodes of the other. Steps are made by following the morphism and are weighted by a given function.
+// Heuristic is optional; without it the search is equal to Dijkstra's algorithm.
+//
+// Results are tagged in the same way as for the ShortestPath iterator; SetCostTag adds a tag with the total cost.
+func NewWeightedPath(qs graph.QuadStore, from, to graph.Iterator, step graph.ApplyMorphism, weight Weight, h Heuristic) *ShortestPath {
+	it := NewShortestPath(qs, from, to, step, nil, -1)
+	it.weight, it.heuristic = weight, h
+	return it
+}
+
+// SetCostTag sets a tag for the total cost of each path.
+func (it *ShortestPath) SetCostTag(tag string) {
+	it.costTag = tag
+}
+
+// wpStep is a node reached by the weighted search.
+type wpStep struct {
+	val  graph.Value
+	prev interface{} // key of the previous node
+	cost float64
+	done bool // the cost is final
+}
+
+type wpItem struct {
+	key  interface{}
+	cost float64 // cost so far with an estimate of the rest
+}
+
+type wpQueue []wpItem
+
+func (q wpQueue) Len() int            { return len(q) }
+func (q wpQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q wpQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *wpQueue) Push(x interface{}) { *q = append(*q, x.(wpItem)) }
+func (q *wpQueue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// searchWeighted returns the path from s to t with the least cost, or nil if t is not reachable.
+func (it *ShortestPath) searchWeighted(ctx context.Context, s, t graph.Value) ([]graph.Value, float64) {
+	ks, kt := graph.ToKey(s), graph.ToKey(t)
+	estimate := func(v graph.Value) float64 {
+		if it.heuristic == nil {
+			return 0
+		}
+		return it.heuristic(v, t)
+	}
+	steps := map[interface{}]*wpStep{ks: {val: s}}
+	q := &wpQueue{{key: ks, cost: estimate(s)}}
+	tags := make(map[string]graph.Value)
+	for q.Len() != 0 {
+		cur := steps[heap.Pop(q).(wpItem).key]
+		if cur.done {
+			continue // a stale entry; the node was reached with a lower cost since it was queued
+		}
+		cur.done = true
+		if k := graph.ToKey(cur.val); k == kt {
+			var path []graph.Value
+			for ; k != nil; k = steps[k].prev {
+				path = append(path, steps[k].val)
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path, cur.cost
+		}
+		if err := ctx.Err(); err != nil {
+			it.err = err
+			return nil, 0
+		}
+		sub := it.fwd(it.qs, NewFixed(cur.val))
+		for sub.Next(ctx) {
+			for {
+				v := sub.Result()
+				for t := range tags {
+					delete(tags, t)
+				}
+				sub.TagResults(tags)
+				k := graph.ToKey(v)
+				if w, ok := it.weight(cur.val, tags[EdgeTag], v); !ok {
+					// no weight for this edge
+				} else if w < 0 {
+					it.err = fmt.Errorf("negative weight of a step to %v", v)
+				} else if next, seen := steps[k]; !seen || (!next.done && cur.cost+w < next.cost) {
+					steps[k] = &wpStep{val: v, prev: graph.ToKey(cur.val), cost: cur.cost + w}
+					heap.Push(q, wpItem{key: k, cost: cur.cost + w + estimate(v)})
+				}
+				// parallel edges between the same nodes are returned as alternative paths
+				if it.err != nil || !sub.NextPath(ctx) {
+					break
+				}
+			}
+			if it.err != nil {
+				break
+			}
+		}
+		if err := sub.Err(); err != nil && it.err == nil {
+			it.err = err
+		}
+		sub.Close()
+		if it.err != nil {
+			return nil, 0
+		}
+	}
+	return nil, 0
+}
@@ -36,7 +36,7 @@ type AllIterator struct {
 	cons    *constraint
 }
 
-var _ graph.Iterator = &AllIterator{}
+var _ graph.BatchIterator = &AllIterator{}
 
 type constraint struct {
 	dir quad.Direction
@@ -155,6 +155,15 @@ func (it *AllIterator) Next(ctx context.Context) bool {
 	}
 }
 
+// NextBatch returns up to n next nodes or quads.
+func (it *AllIterator) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	var out []graph.Value
+	for len(out) < n && it.Next(ctx) {
+		out = append(out, it.Result())
+	}
+	return out, it.err
+}
+
 func (it *AllIterator) NextPath(ctx context.Context) bool {
 	return false
 }
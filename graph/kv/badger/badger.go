@@ -125,20 +125,26 @@ func (tx *Tx) Del(k []byte) error {
 
 func (tx *Tx) Scan(pref []byte) kv.KVIterator {
 	it := tx.txn.NewIterator(IteratorOpts)
-	return &Iterator{iter: it, pref: pref, first: true}
+	return &Iterator{iter: it, pref: pref, from: pref, first: true}
+}
+
+func (tx *Tx) ScanFrom(pref, from []byte) kv.KVIterator {
+	it := tx.txn.NewIterator(IteratorOpts)
+	return &Iterator{iter: it, pref: pref, from: from, first: true}
 }
 
 type Iterator struct {
 	iter  *badger.Iterator
 	first bool
 	pref  []byte
+	from  []byte
 	err   error
 }
 
 func (it *Iterator) Next(ctx context.Context) bool {
 	if it.first {
 		it.first = false
-		it.iter.Seek(it.pref)
+		it.iter.Seek(it.from)
 	} else {
 		it.iter.Next()
 	}
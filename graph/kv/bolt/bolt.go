@@ -37,6 +37,10 @@ func init() {
 
 const (
 	Type = "bolt"
+
+	// OptReadOnly opens the database file in read-only mode. The file is memory-mapped and shared
+	// with other read-only processes, and all writes fail.
+	OptReadOnly = "read_only"
 )
 
 func getBoltFile(cfgpath string) string {
@@ -57,11 +61,19 @@ func Create(path string, _ graph.Options) (kv.BucketKV, error) {
 }
 
 func Open(path string, opt graph.Options) (kv.BucketKV, error) {
-	db, err := bolt.Open(getBoltFile(path), 0600, nil)
+	ro, err := opt.BoolKey(OptReadOnly, false)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(getBoltFile(path), 0600, &bolt.Options{ReadOnly: ro})
 	if err != nil {
 		clog.Errorf("Error, couldn't open! %v", err)
 		return nil, err
 	}
+	if ro {
+		clog.Infof("Running in read-only mode")
+		return &DB{DB: db}, nil
+	}
 	// BoolKey returns false on non-existence. IE, Sync by default.
 	db.NoSync, err = opt.BoolKey("nosync", false)
 	if err != nil {
@@ -82,6 +94,11 @@ func (db *DB) Type() string {
 	return Type
 }
 
+// ReadOnly checks if the database was opened with the read_only option.
+func (db *DB) ReadOnly() bool {
+	return db.DB.IsReadOnly()
+}
+
 func (db *DB) Close() error {
 	return db.DB.Close()
 }
@@ -101,14 +118,34 @@ type Tx struct {
 
 func (tx *Tx) Get(ctx context.Context, keys []kv.BucketKey) ([][]byte, error) {
 	vals := make([][]byte, len(keys))
+	var (
+		name []byte
+		c    *bolt.Cursor
+	)
 	for i, k := range keys {
-		if b := tx.Tx.Bucket(k.Bucket); b != nil {
-			vals[i] = b.Get(k.Key)
+		if c == nil || !bytes.Equal(name, k.Bucket) {
+			name, c = k.Bucket, nil
+			if b := tx.Tx.Bucket(k.Bucket); b != nil {
+				c = b.Cursor()
+			}
+		}
+		if c != nil {
+			vals[i] = get(c, k.Key)
 		}
 	}
 	return vals, nil
 }
 
+// get returns a value of the key, or nil if it doesn't exist. Unlike bolt.Bucket.Get,
+// it reuses the cursor, thus a batch of reads allocates a single one.
+func get(c *bolt.Cursor, key []byte) []byte {
+	k, v := c.Seek(key)
+	if !bytes.Equal(k, key) {
+		return nil
+	}
+	return v
+}
+
 func (tx *Tx) Commit(ctx context.Context) error {
 	if tx.err != nil {
 		_ = tx.Tx.Rollback()
@@ -150,8 +187,9 @@ func (b *Bucket) Get(ctx context.Context, keys [][]byte) ([][]byte, error) {
 		return nil, kv.ErrNotFound
 	}
 	vals := make([][]byte, len(keys))
+	c := b.Bucket.Cursor()
 	for i, k := range keys {
-		vals[i] = b.Bucket.Get(k)
+		vals[i] = get(c, k)
 	}
 	return vals, nil
 }
@@ -168,12 +206,16 @@ func (b *Bucket) Del(k []byte) error {
 	return b.Bucket.Delete(k)
 }
 func (b *Bucket) Scan(pref []byte) kv.KVIterator {
-	return &Iterator{b: b, pref: pref}
+	return &Iterator{b: b, pref: pref, from: pref}
+}
+func (b *Bucket) ScanFrom(pref, from []byte) kv.KVIterator {
+	return &Iterator{b: b, pref: pref, from: from}
 }
 
 type Iterator struct {
 	b    *Bucket
 	pref []byte
+	from []byte
 	c    *bolt.Cursor
 	k, v []byte
 }
@@ -186,10 +228,10 @@ func (it *Iterator) Next(ctx context.Context) bool {
 	}
 	if it.c == nil {
 		it.c = it.b.Bucket.Cursor()
-		if len(it.pref) == 0 {
+		if len(it.from) == 0 {
 			it.k, it.v = it.c.First()
 		} else {
-			it.k, it.v = it.c.Seek(it.pref)
+			it.k, it.v = it.c.Seek(it.from)
 		}
 	} else {
 		it.k, it.v = it.c.Next()
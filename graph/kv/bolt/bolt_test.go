@@ -15,13 +15,20 @@
 package bolt
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/kv"
 	"github.com/cayleygraph/cayley/graph/kv/kvtest"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
 )
 
 func makeBolt(t testing.TB) (kv.BucketKV, graph.Options, func()) {
@@ -47,3 +54,42 @@ func TestBolt(t *testing.T) {
 func BenchmarkBolt(b *testing.B) {
 	kvtest.BenchmarkAll(b, makeBolt, nil)
 }
+
+func TestCompactReadOnly(t *testing.T) {
+	ctx := context.TODO()
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "cayley_test_"+Type)
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	src, dst := filepath.Join(tmpDir, "src"), filepath.Join(tmpDir, "dst")
+
+	db, err := Create(src, nil)
+	require.NoError(t, err)
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	var quads []quad.Quad
+	for i := 0; i < 100; i++ {
+		quads = append(quads, quad.MakeIRI("a", "b", strconv.Itoa(i), ""))
+	}
+	w, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuadSet(quads))
+	require.NoError(t, qs.Close())
+
+	require.NoError(t, Compact(dst, src))
+	require.Error(t, Compact(dst, src), "existing database must not be overwritten")
+
+	db, err = Open(dst, graph.Options{OptReadOnly: true})
+	require.NoError(t, err)
+	require.True(t, db.(kv.ReadOnlyKV).ReadOnly())
+	qs, err = kv.New(db, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	all, err := graph.Iterate(ctx, qs.QuadsAllIterator()).All()
+	require.NoError(t, err)
+	require.Len(t, all, len(quads))
+	require.Equal(t, quads[0], qs.Quad(all[0]))
+
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: quad.MakeIRI("a", "b", "c", ""), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.Error(t, err)
+}
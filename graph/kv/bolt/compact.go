@@ -0,0 +1,137 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bolt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boltdb/bolt"
+)
+
+// compactTxSize is the size of keys and values written by Compact in a single transaction.
+const compactTxSize = 64 << 20
+
+// Compact writes a copy of the database at src to a new database at dst. Pages of the copy are filled
+// completely, so it is smaller and faster to read than a database written by the quad store, but later
+// writes to it would split most of its pages. It's meant to be served with the read_only option.
+func Compact(dst, src string) error {
+	if _, err := os.Stat(getBoltFile(dst)); err == nil {
+		return fmt.Errorf("bolt: database already exists at %q", dst)
+	}
+	sdb, err := bolt.Open(getBoltFile(src), 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer sdb.Close()
+	if err = os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	ddb, err := bolt.Open(getBoltFile(dst), 0600, nil)
+	if err != nil {
+		return err
+	}
+	// the copy is synced on close
+	ddb.NoSync = true
+	c := &compactor{db: ddb}
+	err = sdb.View(func(stx *bolt.Tx) error {
+		tx, err := ddb.Begin(true)
+		if err != nil {
+			return err
+		}
+		c.tx = tx
+		err = stx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return c.copy([][]byte{name}, b)
+		})
+		if err != nil {
+			c.tx.Rollback()
+			return err
+		}
+		return c.tx.Commit()
+	})
+	if err == nil {
+		err = ddb.Sync()
+	}
+	if err2 := ddb.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(getBoltFile(dst))
+	}
+	return err
+}
+
+// compactor copies buckets to a new database in multiple transactions.
+type compactor struct {
+	db   *bolt.DB
+	tx   *bolt.Tx
+	size int
+}
+
+// bucket returns a destination bucket by its path in the current transaction.
+func (c *compactor) bucket(path [][]byte) (*bolt.Bucket, error) {
+	b, err := c.tx.CreateBucketIfNotExists(path[0])
+	for _, name := range path[1:] {
+		if err != nil {
+			break
+		}
+		b, err = b.CreateBucketIfNotExists(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// keys are copied in order, thus pages don't need free space for inserts
+	b.FillPercent = 1
+	return b, nil
+}
+
+// copy copies a bucket with all nested buckets. Keys and values must stay valid until the copy is complete,
+// thus the source transaction must be kept open.
+func (c *compactor) copy(path [][]byte, src *bolt.Bucket) error {
+	dst, err := c.bucket(path)
+	if err != nil {
+		return err
+	}
+	cur := src.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		if v == nil {
+			// nested bucket
+			if err = c.copy(append(path[:len(path):len(path)], k), src.Bucket(k)); err != nil {
+				return err
+			}
+			// transaction may be committed by a nested copy
+			if dst, err = c.bucket(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if c.size += len(k) + len(v); c.size > compactTxSize {
+			if err = c.tx.Commit(); err != nil {
+				return err
+			}
+			if c.tx, err = c.db.Begin(true); err != nil {
+				return err
+			}
+			if dst, err = c.bucket(path); err != nil {
+				return err
+			}
+			c.size = len(k) + len(v)
+		}
+		if err = dst.Put(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -136,12 +136,16 @@ func (b *Bucket) Del(k []byte) error {
 	return nil
 }
 func (b *Bucket) Scan(pref []byte) kv.KVIterator {
-	return &Iterator{b: b, pref: pref}
+	return &Iterator{b: b, pref: pref, from: pref}
+}
+func (b *Bucket) ScanFrom(pref, from []byte) kv.KVIterator {
+	return &Iterator{b: b, pref: pref, from: from}
 }
 
 type Iterator struct {
 	b    *Bucket
 	pref []byte
+	from []byte
 	e    *Enumerator
 	k, v []byte
 }
@@ -153,7 +157,7 @@ func (it *Iterator) Next(ctx context.Context) bool {
 		return false
 	}
 	if it.e == nil {
-		it.e, _ = it.b.tree.Seek(it.pref)
+		it.e, _ = it.b.tree.Seek(it.from)
 	}
 	k, v, err := it.e.Next()
 	if err == io.EOF {
@@ -28,6 +28,7 @@ func makeBtree(t testing.TB) (kv.BucketKV, graph.Options, func()) {
 
 var conf = &kvtest.Config{
 	AlwaysRunIntegration: true,
+	NoSnapshotIsolation:  true,
 }
 
 func TestBtree(t *testing.T) {
@@ -0,0 +1,354 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultBulkBatch is the number of keys written by BulkLoad in one transaction.
+const DefaultBulkBatch = 100000
+
+// metaBulkLoad is a meta key that is set while a bulk load is in progress.
+const metaBulkLoad = "bulk"
+
+var errBulkIncomplete = errors.New("kv: bulk load was interrupted; initialize the database again")
+
+var _ graph.BulkLoader = (*QuadStore)(nil)
+
+// BulkLoad loads quads into an empty database, bypassing transactions used by ApplyDeltas.
+// It returns graph.ErrCannotBulkLoad if the database is not empty.
+//
+// All quads are read and sorted in memory first. Nodes, quads and their indexes are then written
+// directly in large batches, so no lookups are made for existing entries. The load is published by
+// the last transaction, which sets the size and the horizon of the database. If the load is interrupted,
+// the database cannot be opened until it's initialized again.
+func (qs *QuadStore) BulkLoad(qr quad.Reader) error {
+	return qs.bulkLoad(context.TODO(), qr, DefaultBulkBatch)
+}
+
+// bulkQuads is a set of quads with values replaced by node ids, in the order of directions.
+type bulkQuads [][4]uint64
+
+func (a bulkQuads) Len() int      { return len(a) }
+func (a bulkQuads) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a bulkQuads) Less(i, j int) bool {
+	for k := range a[i] {
+		if a[i][k] != a[j][k] {
+			return a[i][k] < a[j][k]
+		}
+	}
+	return false
+}
+
+// bulkWriter writes keys in transactions of a given size. Keys should be written in the order of buckets.
+type bulkWriter struct {
+	ctx   context.Context
+	db    BucketKV
+	tx    BucketTx
+	n     int
+	batch int
+
+	// the last used bucket
+	name []byte
+	b    Bucket
+}
+
+func (w *bulkWriter) put(bucket, key, val []byte) error {
+	if w.tx == nil {
+		tx, err := w.db.Tx(true)
+		if err != nil {
+			return err
+		}
+		w.tx, w.b = tx, nil
+	}
+	if w.b == nil || !bytes.Equal(w.name, bucket) {
+		w.name, w.b = bucket, w.tx.Bucket(bucket)
+	}
+	if err := w.b.Put(key, val); err != nil {
+		return err
+	}
+	if w.n++; w.n >= w.batch {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *bulkWriter) flush() error {
+	if w.tx == nil {
+		return nil
+	}
+	tx := w.tx
+	w.tx, w.b, w.n = nil, nil, 0
+	if err := w.ctx.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit(w.ctx)
+}
+
+func (w *bulkWriter) rollback() {
+	if w.tx != nil {
+		w.tx.Rollback()
+		w.tx, w.b = nil, nil
+	}
+}
+
+func (qs *QuadStore) bulkLoad(ctx context.Context, qr quad.Reader, batch int) error {
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+	if _, err := qs.getMetaInt(ctx, "horizon"); err == nil {
+		return graph.ErrCannotBulkLoad
+	} else if err != ErrNoBucket {
+		return err
+	}
+
+	// node ids are assigned in the order of the first occurrence
+	var (
+		vals   []quad.Value
+		hashes []graph.ValueHash
+		ids    = make(map[graph.ValueHash]uint64)
+		quads  bulkQuads
+	)
+	for {
+		q, err := qr.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if !q.IsValid() {
+			continue
+		}
+		var l [4]uint64
+		for i, dir := range quad.Directions {
+			v := q.Get(dir)
+			if v == nil {
+				continue
+			}
+			h := graph.HashOf(v)
+			id, ok := ids[h]
+			if !ok {
+				vals = append(vals, v)
+				hashes = append(hashes, h)
+				id = uint64(len(vals))
+				ids[h] = id
+			}
+			l[i] = id
+		}
+		quads = append(quads, l)
+	}
+	ids = nil
+	sort.Sort(quads)
+	uniq := quads[:0]
+	for i, l := range quads {
+		if i == 0 || l != quads[i-1] {
+			uniq = append(uniq, l)
+		}
+	}
+	quads = uniq
+	refs := make([]uint64, len(vals))
+	for _, l := range quads {
+		for _, id := range l {
+			if id != 0 {
+				refs[id-1]++
+			}
+		}
+	}
+	clog.Infof("kv: bulk loading %d quads with %d nodes", len(quads), len(vals))
+
+	if err := qs.putMeta(ctx, metaBulkLoad, 1); err != nil {
+		return err
+	}
+	w := &bulkWriter{ctx: ctx, db: qs.db, batch: batch}
+	defer w.rollback()
+	if err := qs.bulkNodes(w, vals, hashes, refs); err != nil {
+		return err
+	}
+	hashes, refs = nil, nil
+	nodes := uint64(len(vals))
+	if err := qs.bulkQuads(w, vals, quads, nodes); err != nil {
+		return err
+	}
+	if err := qs.bulkIndexes(w, quads, nodes); err != nil {
+		return err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	horizon := int64(nodes) + int64(len(quads))
+	return Update(ctx, qs.db, func(tx BucketTx) error {
+		if horizon != 0 {
+			if err := putMetaInt(tx, "horizon", horizon); err != nil {
+				return err
+			}
+			if err := putMetaInt(tx, "size", int64(len(quads))); err != nil {
+				return err
+			}
+			if qs.history {
+				err := tx.Bucket(commitsBucket).Put(uint64KeyBytes(uint64(horizon)), uint64KeyBytes(uint64(time.Now().UnixNano())))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Bucket(metaBucket).Del([]byte(metaBulkLoad))
+	})
+}
+
+// bulkNodes writes all nodes to the log, value indexes, and the hash and reference buckets.
+func (qs *QuadStore) bulkNodes(w *bulkWriter, vals []quad.Value, hashes []graph.ValueHash, refs []uint64) error {
+	for i, v := range vals {
+		id := uint64(i + 1)
+		p, err := createNodePrimitive(v)
+		if err != nil {
+			return err
+		}
+		p.ID = id
+		buf, err := p.Marshal()
+		if err != nil {
+			return err
+		}
+		if err = w.put(logIndex, uint64KeyBytes(id), buf); err != nil {
+			return err
+		}
+		for _, ind := range valueIndexes {
+			if qs.valIndexes&ind.flag == 0 {
+				continue
+			}
+			for _, k := range ind.keys(id, v) {
+				if err = w.put(ind.bucket, k, []byte{}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	// hashes are written in order, so buckets are created and filled one by one
+	order := make([]int, len(hashes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return bytes.Compare(hashes[order[i]][:], hashes[order[j]][:]) < 0
+	})
+	for _, i := range order {
+		h := hashes[i]
+		if err := w.put(bucketForValRefs(h[0], h[1]), append([]byte{}, h[:]...), uint64toBytes(refs[i])); err != nil {
+			return err
+		}
+	}
+	for _, i := range order {
+		h := hashes[i]
+		if err := w.put(bucketForVal(h[0], h[1]), append([]byte{}, h[:]...), uint64toBytes(uint64(i+1))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bulkQuads writes all quads to the log and the time index. Quad ids follow node ids in the sorted order.
+func (qs *QuadStore) bulkQuads(w *bulkWriter, vals []quad.Value, quads bulkQuads, nodes uint64) error {
+	ti := qs.timeIdx
+	now := time.Now().UnixNano()
+	for i, l := range quads {
+		p := proto.Primitive{ID: nodes + uint64(i) + 1, Timestamp: now}
+		for j, dir := range quad.Directions {
+			p.SetDirection(dir, l[j])
+		}
+		buf, err := p.Marshal()
+		if err != nil {
+			return err
+		}
+		if err = w.put(logIndex, uint64KeyBytes(p.ID), buf); err != nil {
+			return err
+		}
+		qs.bloomAdd(&p)
+		q := quad.Quad{Subject: vals[l[0]-1], Predicate: vals[l[1]-1], Object: vals[l[2]-1]}
+		if t, ok := ti.timeOf(q); ok {
+			if err = w.put(timeBucket, ti.key(p.Predicate, t, p.ID), []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bulkIndexes writes quad indexes. Quads are sorted by the key of each index, so every key is written once
+// with all its quad ids in ascending order.
+func (qs *QuadStore) bulkIndexes(w *bulkWriter, quads bulkQuads, nodes uint64) error {
+	qs.indexes.RLock()
+	all := qs.indexes.all
+	qs.indexes.RUnlock()
+	order := make([]int, len(quads))
+	for _, ind := range all {
+		for i := range order {
+			order[i] = i
+		}
+		key := func(i int) []byte {
+			return ind.KeyFor(bulkPrimitive(quads[i]))
+		}
+		// sorting is stable, so quads with the same key keep the order of their ids
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := quads[order[i]], quads[order[j]]
+			for _, d := range ind.Dirs {
+				if x, y := a[d-1], b[d-1]; x != y {
+					return x < y
+				}
+			}
+			return false
+		})
+		bucket := ind.Bucket()
+		for i := 0; i < len(order); {
+			k := key(order[i])
+			var list []uint64
+			for ; i < len(order); i++ {
+				if !bytes.Equal(key(order[i]), k) {
+					break
+				}
+				list = append(list, nodes+uint64(order[i])+1)
+			}
+			if err := w.put(bucket, k, appendIndex(nil, list)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func bulkPrimitive(l [4]uint64) *proto.Primitive {
+	return &proto.Primitive{
+		Subject: l[0], Predicate: l[1], Object: l[2], Label: l[3],
+	}
+}
+
+// checkBulkLoad returns an error if the database has an unfinished bulk load.
+func (qs *QuadStore) checkBulkLoad(ctx context.Context) error {
+	if _, err := qs.getMetaInt(ctx, metaBulkLoad); err == nil {
+		return errBulkIncomplete
+	} else if err != ErrNoBucket {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestBulkLoad(t *testing.T) {
+	ctx := context.TODO()
+	var quads []quad.Quad
+	for i := 0; i < 50; i++ {
+		n := quad.IRI(fmt.Sprintf("n%d", i))
+		quads = append(quads,
+			quad.Make(n, quad.IRI("name"), quad.String(fmt.Sprintf("name %d", i)), nil),
+			quad.Make(n, quad.IRI("follows"), quad.IRI(fmt.Sprintf("n%d", (i+1)%50)), quad.IRI("g")),
+		)
+	}
+	// duplicates are loaded once
+	quads = append(quads, quads[3], quads[10])
+
+	db := btree.New()
+	opts := graph.Options{kv.OptPrefix: true}
+	require.NoError(t, kv.Init(db, opts))
+	qs, err := kv.New(db, opts)
+	require.NoError(t, err)
+	bl := qs.(graph.BulkLoader)
+	require.NoError(t, bl.BulkLoad(quad.NewReader(quads)))
+	require.Equal(t, int64(100), qs.Size())
+	require.Equal(t, graph.ErrCannotBulkLoad, bl.BulkLoad(quad.NewReader(quads[:1])))
+
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(qs))
+	require.NoError(t, err)
+	exp := append([]quad.Quad{}, quads[:100]...)
+	sort.Sort(quad.ByQuadString(exp))
+	sort.Sort(quad.ByQuadString(got))
+	require.Equal(t, exp, got)
+
+	vals, err := path.StartPath(qs, quad.IRI("n7")).Out(quad.IRI("follows")).Out(quad.IRI("name")).Iterate(ctx).AllValues(qs)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("name 8")}, vals)
+	vals, err = path.StartPath(qs, quad.IRI("n7")).In(quad.IRI("follows")).Iterate(ctx).AllValues(qs)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.IRI("n6")}, vals)
+
+	it, ok := qs.(graph.PrefixIndexer).NodesWithPrefix(ctx, "name 4", 0)
+	require.True(t, ok)
+	n, err := graph.Iterate(ctx, it).Count()
+	require.NoError(t, err)
+	require.Equal(t, int64(11), n)
+
+	// the database is written to as usual after the load
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.Error(t, qw.AddQuad(quads[0]))
+	require.NoError(t, qw.RemoveQuad(quads[0]))
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("n1", "follows", "n3", "g")))
+	require.Equal(t, int64(100), qs.Size())
+	vals, err = path.StartPath(qs, quad.IRI("n1")).Out(quad.IRI("follows")).Iterate(ctx).AllValues(qs)
+	require.NoError(t, err)
+	require.Len(t, vals, 2)
+
+	qs, err = kv.New(db, opts)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), qs.Size())
+}
+
+func TestBulkLoadInterrupted(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	// the marker and the data are written, but the load is not published
+	fdb := &failingKV{BucketKV: db, n: 2}
+	qs, err := kv.New(fdb, nil)
+	require.NoError(t, err)
+	err = qs.(graph.BulkLoader).BulkLoad(quad.NewReader([]quad.Quad{quad.MakeIRI("a", "b", "c", "")}))
+	require.Error(t, err)
+	_, err = kv.New(db, nil)
+	require.Error(t, err, "partially loaded database must not be opened")
+	require.Contains(t, err.Error(), "bulk load")
+}
+
+// failingKV fails write transactions after a given number of them.
+type failingKV struct {
+	kv.BucketKV
+	n int
+}
+
+func (db *failingKV) Tx(update bool) (kv.BucketTx, error) {
+	if update {
+		if db.n--; db.n < 0 {
+			return nil, errors.New("write failed")
+		}
+	}
+	return db.BucketKV.Tx(update)
+}
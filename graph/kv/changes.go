@@ -0,0 +1,192 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+)
+
+var _ graph.ChangeLog = (*QuadStore)(nil)
+
+// changeBatch is the number of log entries read at once.
+const changeBatch = 1000
+
+type commitRecord struct {
+	horizon uint64
+	time    time.Time
+}
+
+// deletion is a quad removed by a transaction with a given deletion stamp.
+type deletion struct {
+	stamp uint64
+	p     *proto.Primitive
+}
+
+// Changes lists deltas between two horizons using the log and the history of deletions.
+//
+// Quads are added in the order of their ids, and deletions are ordered by their stamps,
+// so both can be merged into a single stream without reading the state of the graph.
+// The history must be enabled with OptHistory, and the range must not start before the retention.
+func (qs *QuadStore) Changes(ctx context.Context, from, to int64, fn func(graph.LoggedDelta) error) error {
+	if from < 0 || to < from {
+		return fmt.Errorf("kv: invalid horizon range: (%d, %d]", from, to)
+	}
+	s, err := qs.snapshot()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if cur := s.horizon(ctx); to > cur {
+		return fmt.Errorf("kv: horizon %d is ahead of the quad store (%d)", to, cur)
+	} else if from == to {
+		return nil
+	}
+	// values of removed nodes are loaded from the history
+	s.asOf = to
+	return View(s.db, func(tx BucketTx) error {
+		if since, err := s.historySince(ctx, tx); err != nil {
+			return err
+		} else if from < since {
+			return ErrNoHistory
+		}
+		commits, err := s.commitsAfter(ctx, tx, uint64(from))
+		if err != nil {
+			return err
+		}
+		dels, err := s.deletionsBetween(ctx, tx, uint64(from), uint64(to))
+		if err != nil {
+			return err
+		}
+		emit := func(p *proto.Primitive, pos uint64, act graph.Procedure) error {
+			q, err := s.primitiveToQuad(ctx, tx, p)
+			if err != nil {
+				return err
+			}
+			d := graph.LoggedDelta{Delta: graph.Delta{Quad: q, Action: act}, Horizon: int64(pos)}
+			// first transaction committed after the delta was applied
+			if i := sort.Search(len(commits), func(i int) bool { return commits[i].horizon >= pos }); i < len(commits) {
+				d.Horizon, d.Time = int64(commits[i].horizon), commits[i].time
+			}
+			return fn(d)
+		}
+		keys := make([]uint64, 0, changeBatch)
+		for id := uint64(from) + 1; id <= uint64(to); {
+			keys = keys[:0]
+			for ; id <= uint64(to) && len(keys) < changeBatch; id++ {
+				keys = append(keys, id)
+			}
+			prims, err := s.getPrimitivesFromLog(ctx, tx, keys)
+			if err != nil {
+				return err
+			}
+			for i, p := range prims {
+				if p == nil || p.IsNode() {
+					continue
+				}
+				for len(dels) != 0 && dels[0].stamp < keys[i] {
+					if err = emit(dels[0].p, dels[0].stamp, graph.Delete); err != nil {
+						return err
+					}
+					dels = dels[1:]
+				}
+				if err = emit(p, keys[i], graph.Add); err != nil {
+					return err
+				}
+			}
+		}
+		for _, d := range dels {
+			if err = emit(d.p, d.stamp, graph.Delete); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// commitsAfter returns all commits with a horizon greater than a given one.
+func (qs *QuadStore) commitsAfter(ctx context.Context, tx BucketTx, from uint64) ([]commitRecord, error) {
+	var out []commitRecord
+	it := tx.Bucket(commitsBucket).Scan(nil)
+	defer it.Close()
+	for it.Next(ctx) {
+		k, v := it.Key(), it.Val()
+		if len(k) != 8 || len(v) != 8 {
+			return nil, fmt.Errorf("kv: invalid commit record")
+		}
+		if h := quadKeyEnc.Uint64(k); h > from {
+			out = append(out, commitRecord{horizon: h, time: time.Unix(0, int64(quadKeyEnc.Uint64(v)))})
+		}
+	}
+	err := it.Err()
+	if err == ErrNoBucket {
+		err = nil
+	}
+	return out, err
+}
+
+// deletionsBetween returns quads deleted after horizon from and at or before horizon to, ordered by deletion stamp.
+func (qs *QuadStore) deletionsBetween(ctx context.Context, tx BucketTx, from, to uint64) ([]deletion, error) {
+	var (
+		ids    []uint64
+		stamps []uint64
+	)
+	it := tx.Bucket(deletedBucket).Scan(nil)
+	for it.Next(ctx) {
+		stamp, n := binary.Uvarint(it.Val())
+		if n <= 0 || len(it.Key()) != 8 {
+			it.Close()
+			return nil, fmt.Errorf("kv: invalid deletion record")
+		}
+		if stamp > from && stamp <= to {
+			ids = append(ids, quadKeyEnc.Uint64(it.Key()))
+			stamps = append(stamps, stamp)
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err == ErrNoBucket {
+		err = nil
+	}
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	var out []deletion
+	for len(ids) != 0 {
+		n := changeBatch
+		if n > len(ids) {
+			n = len(ids)
+		}
+		prims, err := qs.getPrimitivesFromLog(ctx, tx, ids[:n])
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range prims {
+			// removed nodes are stamped as well, but they are not part of the changes
+			if p != nil && !p.IsNode() {
+				out = append(out, deletion{stamp: stamps[i], p: p})
+			}
+		}
+		ids, stamps = ids[n:], stamps[n:]
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].stamp < out[j].stamp })
+	return out, nil
+}
@@ -0,0 +1,399 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/internal/lru"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+var _ graph.Checker = (*QuadStore)(nil)
+
+type checkLink struct {
+	dirs    [4]uint64
+	deleted bool
+}
+
+func (l checkLink) primitive() *proto.Primitive {
+	p := &proto.Primitive{}
+	for i, d := range quad.Directions {
+		p.SetDirection(d, l.dirs[i])
+	}
+	return p
+}
+
+// checker keeps the state of a consistency check between passes.
+type checker struct {
+	qs   *QuadStore
+	opts graph.CheckOptions
+	st   graph.CheckStats
+
+	nodes  map[uint64]graph.ValueHash
+	hashes map[graph.ValueHash]uint64
+	links  map[uint64]checkLink
+	refs   map[uint64]int64
+
+	// fixes are applied in a single transaction after all passes
+	puts map[string]map[string][]byte
+	dels map[string]map[string]struct{}
+	// index keys with a new list of IDs and IDs to add to them
+	lists map[string]map[string][]uint64
+}
+
+func (c *checker) report(kind graph.ProblemKind, format string, args ...interface{}) {
+	c.opts.Report(&c.st, graph.Problem{Kind: kind, Desc: fmt.Sprintf(format, args...), Repaired: c.opts.Repair})
+}
+
+func (c *checker) put(bucket, key, val []byte) {
+	m := c.puts[string(bucket)]
+	if m == nil {
+		m = make(map[string][]byte)
+		c.puts[string(bucket)] = m
+	}
+	m[string(key)] = val
+}
+
+func (c *checker) del(bucket, key []byte) {
+	m := c.dels[string(bucket)]
+	if m == nil {
+		m = make(map[string]struct{})
+		c.dels[string(bucket)] = m
+	}
+	m[string(key)] = struct{}{}
+}
+
+func (c *checker) list(bucket []byte) map[string][]uint64 {
+	m := c.lists[string(bucket)]
+	if m == nil {
+		m = make(map[string][]uint64)
+		c.lists[string(bucket)] = m
+	}
+	return m
+}
+
+// Check cross-checks the quad log against quad indexes, the node index and reference counters.
+//
+// The log is the primary source of truth: missing index entries are added, and entries that point
+// to missing or different primitives are removed. Quads that refer to missing nodes cannot be repaired
+// and are only reported. Value indexes are not checked; use RebuildIndex for them.
+//
+// The whole log is scanned in memory, and all repairs are applied in a single transaction.
+func (qs *QuadStore) Check(ctx context.Context, opts graph.CheckOptions) (*graph.CheckStats, error) {
+	if qs.asOf > 0 {
+		return nil, errors.New("kv: cannot check a past state of the graph")
+	}
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+	c := &checker{
+		qs: qs, opts: opts,
+		nodes:  make(map[uint64]graph.ValueHash),
+		hashes: make(map[graph.ValueHash]uint64),
+		links:  make(map[uint64]checkLink),
+		refs:   make(map[uint64]int64),
+		puts:   make(map[string]map[string][]byte),
+		dels:   make(map[string]map[string]struct{}),
+		lists:  make(map[string]map[string][]uint64),
+	}
+	qs.indexes.RLock()
+	indexes := qs.indexes.all
+	qs.indexes.RUnlock()
+	err := View(qs.db, func(tx BucketTx) error {
+		if err := c.scanLog(ctx, tx); err != nil {
+			return err
+		}
+		for _, ind := range indexes {
+			if err := c.checkQuadIndex(ctx, tx, ind); err != nil {
+				return err
+			}
+		}
+		if err := c.checkNodeIndex(ctx, tx); err != nil {
+			return err
+		}
+		if err := c.checkRefs(ctx, tx); err != nil {
+			return err
+		}
+		return c.checkSize(ctx, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Repair && c.st.Problems != 0 {
+		if err = c.repair(ctx); err != nil {
+			return nil, err
+		}
+		// cached IDs of values could come from removed index entries
+		qs.valueLRU = lru.New(2000)
+	}
+	return &c.st, nil
+}
+
+// scanLog reads all primitives from the log and counts references of live quads.
+func (c *checker) scanLog(ctx context.Context, tx BucketTx) error {
+	it := tx.Bucket(logIndex).Scan(nil)
+	defer it.Close()
+	for it.Next(ctx) {
+		var p proto.Primitive
+		if err := p.Unmarshal(it.Val()); err != nil {
+			return fmt.Errorf("kv: cannot decode log entry %x: %v", it.Key(), err)
+		}
+		if p.IsNode() {
+			if p.Deleted {
+				continue
+			}
+			v, err := pquads.UnmarshalValue(p.Value)
+			if err != nil {
+				return fmt.Errorf("kv: cannot decode node %d: %v", p.ID, err)
+			}
+			h := graph.HashOf(v)
+			c.nodes[p.ID] = h
+			c.hashes[h] = p.ID
+			c.st.Nodes++
+			continue
+		}
+		var l checkLink
+		for i, d := range quad.Directions {
+			l.dirs[i] = p.GetDirection(d)
+		}
+		l.deleted = p.Deleted
+		c.links[p.ID] = l
+		if !p.Deleted {
+			c.st.Quads++
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	for id, l := range c.links {
+		if l.deleted {
+			continue
+		}
+		for _, n := range l.dirs {
+			if n == 0 {
+				continue
+			}
+			if _, ok := c.nodes[n]; !ok {
+				// cannot be repaired without losing the quad
+				c.opts.Report(&c.st, graph.Problem{Kind: graph.DanglingRef, Desc: fmt.Sprintf("quad %d refers to node %d", id, n)})
+				continue
+			}
+			c.refs[n]++
+		}
+	}
+	return nil
+}
+
+// checkQuadIndex verifies that each live quad is listed under its key in the index, and that each entry
+// of the index is a quad with this key. Deleted quads are allowed in the index.
+func (c *checker) checkQuadIndex(ctx context.Context, tx BucketTx, ind QuadIndex) error {
+	name := ind.Bucket()
+	seen := make(map[uint64]struct{}, len(c.links))
+	it := tx.Bucket(name).Scan(nil)
+	defer it.Close()
+	for it.Next(ctx) {
+		key := it.Key()
+		ids, err := decodeIndex(it.Val())
+		if err != nil {
+			return fmt.Errorf("kv: cannot decode index %s entry %x: %v", name, key, err)
+		}
+		keep := ids[:0:0]
+		for _, id := range ids {
+			l, ok := c.links[id]
+			if ok && bytes.Equal(ind.KeyFor(l.primitive()), key) {
+				seen[id] = struct{}{}
+				keep = append(keep, id)
+				continue
+			}
+			c.report(graph.ExtraIndex, "quad %d in index %s", id, name)
+		}
+		if len(keep) != len(ids) {
+			c.list(name)[string(key)] = keep
+		}
+	}
+	if err := it.Err(); err != nil && err != ErrNoBucket {
+		return err
+	}
+	// add missing quads to their keys
+	var missing []uint64
+	for id, l := range c.links {
+		if _, ok := seen[id]; !ok && !l.deleted {
+			missing = append(missing, id)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	for _, id := range missing {
+		c.report(graph.MissingIndex, "quad %d in index %s", id, name)
+		key := ind.KeyFor(c.links[id].primitive())
+		m := c.list(name)
+		list, ok := m[string(key)]
+		if !ok {
+			cur, err := GetOne(ctx, tx.Bucket(name), key)
+			if err != nil && err != ErrNotFound && err != ErrNoBucket {
+				return err
+			}
+			if list, err = decodeIndex(cur); err != nil {
+				return err
+			}
+		}
+		m[string(key)] = append(list, id)
+	}
+	return nil
+}
+
+// checkNodeIndex verifies that hashes of all live nodes point to them.
+func (c *checker) checkNodeIndex(ctx context.Context, tx BucketTx) error {
+	seen := make(map[uint64]struct{}, len(c.nodes))
+	for i := 0; i < 256; i++ {
+		for j := 0; j < 256; j++ {
+			b := bucketForVal(byte(i), byte(j))
+			it := tx.Bucket(b).Scan(nil)
+			for it.Next(ctx) {
+				id, _ := binary.Uvarint(it.Val())
+				var h graph.ValueHash
+				copy(h[:], it.Key())
+				if nh, ok := c.nodes[id]; ok && nh == h {
+					seen[id] = struct{}{}
+					continue
+				}
+				c.report(graph.ExtraIndex, "node %d with hash %x", id, it.Key())
+				c.del(b, it.Key())
+			}
+			err := it.Err()
+			it.Close()
+			if err != nil && err != ErrNoBucket {
+				return err
+			}
+		}
+	}
+	for id, h := range c.nodes {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		c.report(graph.MissingIndex, "node %d with hash %x", id, h[:])
+		b := bucketForVal(h[0], h[1])
+		// the deletion of an extra entry with the same hash is replaced by this one
+		if m := c.dels[string(b)]; m != nil {
+			delete(m, string(h[:]))
+		}
+		c.put(b, h[:], uint64toBytes(id))
+	}
+	return nil
+}
+
+// checkRefs verifies reference counters of nodes.
+func (c *checker) checkRefs(ctx context.Context, tx BucketTx) error {
+	seen := make(map[uint64]struct{}, len(c.nodes))
+	for i := 0; i < 256; i++ {
+		for j := 0; j < 256; j++ {
+			b := bucketForValRefs(byte(i), byte(j))
+			it := tx.Bucket(b).Scan(nil)
+			for it.Next(ctx) {
+				cnt, _ := binary.Uvarint(it.Val())
+				var h graph.ValueHash
+				copy(h[:], it.Key())
+				id, ok := c.hashes[h]
+				if !ok {
+					c.report(graph.ExtraIndex, "reference count of a missing node with hash %x", it.Key())
+					c.del(b, it.Key())
+					continue
+				}
+				seen[id] = struct{}{}
+				if exp := c.refs[id]; int64(cnt) != exp {
+					c.report(graph.WrongRefs, "node %d has %d references, expected %d", id, cnt, exp)
+					if exp > 0 {
+						c.put(b, it.Key(), uint64toBytes(uint64(exp)))
+					} else {
+						c.del(b, it.Key())
+					}
+				}
+			}
+			err := it.Err()
+			it.Close()
+			if err != nil && err != ErrNoBucket {
+				return err
+			}
+		}
+	}
+	for id, h := range c.nodes {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		if exp := c.refs[id]; exp > 0 {
+			c.report(graph.WrongRefs, "node %d has no references, expected %d", id, exp)
+			c.put(bucketForValRefs(h[0], h[1]), h[:], uint64toBytes(uint64(exp)))
+		}
+	}
+	return nil
+}
+
+func (c *checker) checkSize(ctx context.Context, tx BucketTx) error {
+	sz, err := c.qs.getMetaIntTx(ctx, tx, "size")
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if sz != c.st.Quads {
+		c.report(graph.WrongSize, "stored size is %d, expected %d", sz, c.st.Quads)
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, uint64(c.st.Quads))
+		c.put(metaBucket, []byte("size"), buf)
+	}
+	return nil
+}
+
+func (c *checker) repair(ctx context.Context) error {
+	return Update(ctx, c.qs.db, func(tx BucketTx) error {
+		for name, m := range c.dels {
+			b := tx.Bucket([]byte(name))
+			for k := range m {
+				if err := b.Del([]byte(k)); err != nil {
+					return err
+				}
+			}
+		}
+		for name, m := range c.puts {
+			b := tx.Bucket([]byte(name))
+			for k, v := range m {
+				if err := b.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+		}
+		for name, m := range c.lists {
+			b := tx.Bucket([]byte(name))
+			for k, ids := range m {
+				var err error
+				if len(ids) == 0 {
+					err = b.Del([]byte(k))
+				} else {
+					sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+					err = b.Put([]byte(k), appendIndex(nil, ids))
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
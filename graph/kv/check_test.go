@@ -0,0 +1,103 @@
+package kv_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestCheck(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	var quads []quad.Quad
+	for i := 0; i < 10; i++ {
+		quads = append(quads, quad.Make(quad.IRI(fmt.Sprintf("n%d", i)), quad.IRI("name"), quad.String(fmt.Sprintf("name %d", i)), nil))
+	}
+	require.NoError(t, qw.AddQuadSet(quads))
+	require.NoError(t, qw.RemoveQuad(quads[3]))
+
+	check := func(repair bool) ([]graph.Problem, *graph.CheckStats) {
+		var got []graph.Problem
+		st, err := qs.(graph.Checker).Check(ctx, graph.CheckOptions{
+			Repair:  repair,
+			Problem: func(p graph.Problem) { got = append(got, p) },
+		})
+		require.NoError(t, err)
+		return got, st
+	}
+	got, st := check(false)
+	require.Empty(t, got)
+	require.Equal(t, &graph.CheckStats{Quads: 9, Nodes: 19}, st)
+
+	h := quad.HashOf(quad.IRI("name"))
+	err = kv.Update(ctx, db, func(tx kv.BucketTx) error {
+		// drop an entry of the object index
+		it := tx.Bucket([]byte("o")).Scan(nil)
+		it.Next(ctx)
+		key := append([]byte{}, it.Key()...)
+		it.Close()
+		if err := tx.Bucket([]byte("o")).Del(key); err != nil {
+			return err
+		}
+		// point the subject index to a missing quad
+		if err := tx.Bucket([]byte("s")).Put([]byte("bogus key"), []byte{0x7f}); err != nil {
+			return err
+		}
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(buf[:], 1)
+		if err := tx.Bucket([]byte{'n', h[0], h[1]}).Put(h, buf[:n]); err != nil {
+			return err
+		}
+		size := make([]byte, 8)
+		binary.LittleEndian.PutUint64(size, 100)
+		return tx.Bucket([]byte("meta")).Put([]byte("size"), size)
+	})
+	require.NoError(t, err)
+
+	kinds := func(arr []graph.Problem) map[graph.ProblemKind]int {
+		m := make(map[graph.ProblemKind]int)
+		for _, p := range arr {
+			m[p.Kind]++
+		}
+		return m
+	}
+	exp := map[graph.ProblemKind]int{
+		graph.MissingIndex: 1,
+		graph.ExtraIndex:   1,
+		graph.WrongRefs:    1,
+		graph.WrongSize:    1,
+	}
+	got, st = check(false)
+	require.Equal(t, exp, kinds(got))
+	require.Equal(t, 0, st.Repaired)
+
+	got, st = check(true)
+	require.Equal(t, exp, kinds(got))
+	require.Equal(t, 4, st.Repaired)
+
+	got, _ = check(false)
+	require.Empty(t, got)
+	require.Equal(t, int64(9), qs.Size())
+	// the object index is usable again
+	for _, q := range quads[4:] {
+		it := qs.QuadIterator(quad.Object, qs.ValueOf(q.Object))
+		require.True(t, it.Next(ctx), "%v", q)
+		require.NoError(t, it.Close())
+	}
+}
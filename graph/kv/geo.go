@@ -0,0 +1,83 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// OptGeo enables the index of point values by their geohash, used by near and within filters.
+const OptGeo = "geo"
+
+// maxGeoCells is the maximal number of geohash cells scanned for a single region.
+const maxGeoCells = 16
+
+var (
+	// geoBucket maps a geohash of a point and a node id to an empty value
+	geoBucket = []byte("geo")
+
+	_ graph.GeoIndexer = (*QuadStore)(nil)
+)
+
+func geoKeys(id uint64, v quad.Value) [][]byte {
+	p, ok := geo.ParsePoint(v)
+	if !ok {
+		return nil
+	}
+	h := geo.Encode(p, geo.MaxPrecision)
+	key := make([]byte, len(h)+8)
+	copy(key, h)
+	quadKeyEnc.PutUint64(key[len(h):], id)
+	return [][]byte{key}
+}
+
+// NodesInRegion returns point values with a geohash in one of the cells that cover bounds of the region.
+//
+// It returns false if the geo index is not enabled.
+func (qs *QuadStore) NodesInRegion(ctx context.Context, r geo.Region) (graph.Iterator, bool) {
+	if !qs.hasValueIndex(valueIndexes[2].flag) {
+		return nil, false
+	}
+	cells := geo.Cover(r.Bounds(), maxGeoCells)
+	if len(cells) == 0 {
+		return iterator.NewNull(), true
+	}
+	it := iterator.NewFixed()
+	err := View(qs.db, func(tx BucketTx) error {
+		b := tx.Bucket(geoBucket)
+		for _, c := range cells {
+			kit := b.Scan([]byte(c))
+			for kit.Next(ctx) {
+				k := kit.Key()
+				it.Add(Int64Value(quadKeyEnc.Uint64(k[len(k)-8:])))
+			}
+			err := kit.Err()
+			kit.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return iterator.NewError(err), true
+	}
+	return it, true
+}
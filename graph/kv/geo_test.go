@@ -0,0 +1,87 @@
+package kv_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestGeoIndex(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+
+	var (
+		sf      = geo.Point{Lat: 37.7749, Lng: -122.4194}
+		oakland = geo.Point{Lat: 37.8044, Lng: -122.2712}
+		la      = geo.Point{Lat: 34.0522, Lng: -118.2437}
+		fiji    = geo.Point{Lat: -17.7134, Lng: 179.9}
+		samoa   = geo.Point{Lat: -13.759, Lng: -172.1046}
+	)
+	loc := quad.IRI("location")
+	qs, err := kv.New(db, graph.Options{kv.OptGeo: true})
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = qw.AddQuadSet([]quad.Quad{
+		quad.Make(quad.IRI("sf"), loc, sf.Value(), nil),
+		quad.Make(quad.IRI("oakland"), loc, oakland.Value(), nil),
+		quad.Make(quad.IRI("la"), loc, la.Value(), nil),
+		quad.Make(quad.IRI("fiji"), loc, fiji.Value(), nil),
+		quad.Make(quad.IRI("samoa"), loc, samoa.Value(), nil),
+		quad.Make(quad.IRI("sf"), quad.IRI("name"), quad.String("San Francisco"), nil),
+	})
+	require.NoError(t, err)
+
+	candidates := func(r geo.Region) []quad.Value {
+		it, ok := qs.(graph.GeoIndexer).NodesInRegion(ctx, r)
+		require.True(t, ok)
+		vals, err := graph.Iterate(ctx, it).AllValues(qs)
+		require.NoError(t, err)
+		return vals
+	}
+	// the index may return more points than the region contains, but not less
+	near := candidates(geo.Circle{Center: sf, Radius: 20000})
+	require.Contains(t, near, sf.Value())
+	require.Contains(t, near, oakland.Value())
+	require.NotContains(t, near, la.Value())
+
+	// regions that cross the antimeridian
+	near = candidates(geo.Circle{Center: geo.Point{Lat: -15, Lng: 180}, Radius: 1500000})
+	require.Contains(t, near, fiji.Value())
+	require.Contains(t, near, samoa.Value())
+
+	places := func(p *path.Path) []string {
+		vals, err := p.In(loc).Iterate(ctx).AllValues(qs)
+		require.NoError(t, err)
+		var out []string
+		for _, v := range vals {
+			out = append(out, string(v.(quad.IRI)))
+		}
+		sort.Strings(out)
+		return out
+	}
+	all := path.StartPath(qs).Out(loc)
+	require.Equal(t, []string{"oakland", "sf"}, places(all.Near(sf.Lat, sf.Lng, 20000)))
+	require.Equal(t, []string{"sf"}, places(all.Near(sf.Lat, sf.Lng, 1000)))
+	require.Equal(t, []string{"la", "oakland", "sf"}, places(all.Within(
+		geo.Point{Lat: 33, Lng: -123}, geo.Point{Lat: 38, Lng: -123},
+		geo.Point{Lat: 38, Lng: -118}, geo.Point{Lat: 33, Lng: -118},
+	)))
+	require.Equal(t, []string{"oakland", "sf"}, places(all.WithinBox(geo.Rect{MinLat: 37, MinLng: -123, MaxLat: 38, MaxLng: -122})))
+	require.Equal(t, []string{"fiji", "samoa"}, places(all.WithinBox(geo.Rect{MinLat: -20, MinLng: 170, MaxLat: -10, MaxLng: -170})))
+
+	err = qw.RemoveQuad(quad.Make(quad.IRI("oakland"), loc, oakland.Value(), nil))
+	require.NoError(t, err)
+	require.NotContains(t, candidates(geo.Circle{Center: sf, Radius: 20000}), oakland.Value())
+}
@@ -0,0 +1,502 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+const (
+	// OptHistory enables the history of the graph, which is required by AsOf, HorizonAt and Changes.
+	// Like the time index, the history is only recorded while the option is set, and it's dropped
+	// when the database is opened without it.
+	OptHistory = "history"
+	// OptHistoryRetention is the time the history is kept for, such as "720h". Older versions are
+	// removed by writes, at most once per historyPruneInterval. Defaults to keeping the whole history.
+	OptHistoryRetention = "history_retention"
+)
+
+// metaHistory stores the oldest horizon that can be viewed with AsOf. It's not set if the history is disabled.
+const metaHistory = "history_since"
+
+// historyPruneInterval is the minimal interval between removals of versions older than the retention.
+const historyPruneInterval = time.Hour
+
+// The history of the graph is kept next to the log, if it's enabled with OptHistory:
+//
+//   - quads are never removed from the log, they are only marked as deleted;
+//   - primitives of deleted nodes are moved to the history bucket, and their
+//     ids are indexed by the value hash in the history values bucket;
+//   - all removals in a single transaction get a deletion stamp, which is
+//     an id allocated after all ids added by this transaction;
+//   - the horizon of each transaction is recorded with its commit time.
+//
+// A primitive is visible at a given horizon if its id is not greater than the
+// horizon, and it was not deleted at or before this horizon.
+var (
+	historyBucket     = []byte("history")
+	historyValsBucket = []byte("history_vals")
+	deletedBucket     = []byte("deleted")
+	commitsBucket     = []byte("commits")
+)
+
+var ErrNoHistory = errors.New("kv: no history is recorded for this time")
+
+var _ graph.TimeTraveler = (*QuadStore)(nil)
+
+// loadHistory starts or stops recording the history, depending on the options.
+func (qs *QuadStore) loadHistory(ctx context.Context, opt graph.Options) error {
+	on, err := opt.BoolKey(OptHistory, false)
+	if err != nil {
+		return err
+	}
+	ret, err := opt.StringKey(OptHistoryRetention, "")
+	if err != nil {
+		return err
+	}
+	if ret != "" {
+		d, err := time.ParseDuration(ret)
+		if err != nil {
+			return fmt.Errorf("kv: invalid %s: %v", OptHistoryRetention, err)
+		} else if d <= 0 {
+			return fmt.Errorf("kv: %s must be positive, got %v", OptHistoryRetention, d)
+		}
+		qs.retention = d
+	}
+	if ro, ok := qs.db.(ReadOnlyKV); ok && ro.ReadOnly() {
+		// the history is never written, but it can still be read
+		return nil
+	}
+	_, err = qs.getMetaInt(ctx, metaHistory)
+	recorded := err == nil
+	if err != nil && err != ErrNoBucket {
+		return err
+	}
+	qs.history = on
+	switch {
+	case on && !recorded:
+		return Update(ctx, qs.db, func(tx BucketTx) error {
+			// the current state is the oldest version that can be viewed
+			horizon, err := qs.getMetaIntTx(ctx, tx, "horizon")
+			if err == ErrNotFound {
+				return putMetaInt(tx, metaHistory, 0)
+			} else if err != nil {
+				return err
+			}
+			if err = qs.recordCommit(ctx, tx); err != nil {
+				return err
+			}
+			return putMetaInt(tx, metaHistory, horizon)
+		})
+	case !on && recorded:
+		clog.Infof("kv: history is disabled, removing recorded history")
+		return Update(ctx, qs.db, func(tx BucketTx) error {
+			if err := qs.pruneHistory(ctx, tx, ^uint64(0)); err != nil {
+				return err
+			}
+			return tx.Bucket(metaBucket).Del([]byte(metaHistory))
+		})
+	}
+	return nil
+}
+
+// historySince returns the oldest horizon that can be viewed with AsOf, or ErrNoHistory if the history is disabled.
+func (qs *QuadStore) historySince(ctx context.Context, tx BucketTx) (int64, error) {
+	since, err := qs.getMetaIntTx(ctx, tx, metaHistory)
+	if err == ErrNotFound {
+		return 0, ErrNoHistory
+	}
+	return since, err
+}
+
+// AsOf returns a read-only view of the graph at a given horizon.
+//
+// The history must be enabled with OptHistory, and the horizon must not be older than the retention.
+func (qs *QuadStore) AsOf(horizon int64) (graph.Snapshot, error) {
+	ctx := context.TODO()
+	if horizon <= 0 {
+		return nil, fmt.Errorf("kv: invalid horizon: %d", horizon)
+	}
+	s, err := qs.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if cur := s.horizon(ctx); horizon > cur {
+		s.Close()
+		return nil, fmt.Errorf("kv: horizon %d is ahead of the quad store (%d)", horizon, cur)
+	}
+	err = View(s.db, func(tx BucketTx) error {
+		since, err := s.historySince(ctx, tx)
+		if err == nil && horizon < since {
+			err = ErrNoHistory
+		}
+		return err
+	})
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.asOf = horizon
+	return s, nil
+}
+
+// HorizonAt returns the horizon of the last transaction committed at or before t.
+func (qs *QuadStore) HorizonAt(t time.Time) (int64, error) {
+	ctx := context.TODO()
+	ts := uint64(t.UnixNano())
+	var horizon uint64
+	err := View(qs.db, func(tx BucketTx) error {
+		it := tx.Bucket(commitsBucket).Scan(nil)
+		defer it.Close()
+		for it.Next(ctx) {
+			k, v := it.Key(), it.Val()
+			if len(k) != 8 || len(v) != 8 {
+				return fmt.Errorf("kv: invalid commit record")
+			}
+			if quadKeyEnc.Uint64(v) > ts {
+				// history is ordered by the horizon, which grows with time
+				break
+			}
+			horizon = quadKeyEnc.Uint64(k)
+		}
+		err := it.Err()
+		if err == ErrNoBucket {
+			err = nil
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	} else if horizon == 0 {
+		return 0, ErrNoHistory
+	}
+	return int64(horizon), nil
+}
+
+// PruneHistory removes versions of the graph committed before a given time. The state at the last commit
+// before it can still be viewed with AsOf, but older ones can not.
+func (qs *QuadStore) PruneHistory(ctx context.Context, before time.Time) error {
+	qs.writer.Lock()
+	defer qs.writer.Unlock()
+	return qs.pruneHistoryBefore(ctx, before)
+}
+
+func (qs *QuadStore) pruneHistoryBefore(ctx context.Context, before time.Time) error {
+	horizon, err := qs.HorizonAt(before)
+	if err == ErrNoHistory {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return Update(ctx, qs.db, func(tx BucketTx) error {
+		since, err := qs.historySince(ctx, tx)
+		if err == ErrNoHistory || (err == nil && horizon <= since) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = qs.pruneHistory(ctx, tx, uint64(horizon)); err != nil {
+			return err
+		}
+		return putMetaInt(tx, metaHistory, horizon)
+	})
+}
+
+// maybePruneHistory removes versions older than the retention, if it's set and they were not removed recently.
+// It must be called with the writer lock held.
+func (qs *QuadStore) maybePruneHistory(ctx context.Context) {
+	if !qs.history || qs.retention <= 0 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(qs.pruned) < historyPruneInterval {
+		return
+	}
+	qs.pruned = now
+	if err := qs.pruneHistoryBefore(ctx, now.Add(-qs.retention)); err != nil {
+		clog.Errorf("kv: cannot remove old history: %v", err)
+	}
+}
+
+// pruneHistory removes records that are only needed to view the graph before a given horizon:
+// deletion stamps and removed nodes that are not newer than it, and commits older than it.
+func (qs *QuadStore) pruneHistory(ctx context.Context, tx BucketTx, horizon uint64) error {
+	var ids [][]byte
+	it := tx.Bucket(deletedBucket).Scan(nil)
+	for it.Next(ctx) {
+		if stamp, n := binary.Uvarint(it.Val()); n > 0 && stamp <= horizon {
+			ids = append(ids, append([]byte{}, it.Key()...))
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil && err != ErrNoBucket {
+		return err
+	}
+	hist := tx.Bucket(historyBucket)
+	vals := tx.Bucket(historyValsBucket)
+	for _, k := range ids {
+		if err = qs.unarchiveNode(ctx, hist, vals, k); err != nil {
+			return err
+		}
+		if err = tx.Bucket(deletedBucket).Del(k); err != nil {
+			return err
+		}
+	}
+	var commits [][]byte
+	it = tx.Bucket(commitsBucket).Scan(nil)
+	for it.Next(ctx) {
+		k := it.Key()
+		if len(k) != 8 || quadKeyEnc.Uint64(k) >= horizon {
+			// commits are ordered by the horizon
+			break
+		}
+		commits = append(commits, append([]byte{}, k...))
+	}
+	err = it.Err()
+	it.Close()
+	if err != nil && err != ErrNoBucket {
+		return err
+	}
+	for _, k := range commits {
+		if err = tx.Bucket(commitsBucket).Del(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unarchiveNode removes a node primitive with a given id key from the history, if it's there.
+func (qs *QuadStore) unarchiveNode(ctx context.Context, hist, vals Bucket, k []byte) error {
+	buf, err := GetOne(ctx, hist, k)
+	if err == ErrNotFound {
+		// deletion stamp of a quad
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var p proto.Primitive
+	if err = p.Unmarshal(buf); err != nil {
+		return err
+	}
+	v, err := pquads.UnmarshalValue(p.Value)
+	if err != nil {
+		return err
+	}
+	h := graph.HashOf(v)
+	list, err := GetOne(ctx, vals, h[:])
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if len(list) != 0 {
+		ids, err := decodeIndex(list)
+		if err != nil {
+			return err
+		}
+		id := quadKeyEnc.Uint64(k)
+		keep := ids[:0]
+		for _, x := range ids {
+			if x != id {
+				keep = append(keep, x)
+			}
+		}
+		if len(keep) == 0 {
+			err = vals.Del(h[:])
+		} else {
+			err = vals.Put(h[:], appendIndex(nil, keep))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return hist.Del(k)
+}
+
+func (qs *QuadStore) recordCommit(ctx context.Context, tx BucketTx) error {
+	horizon, err := qs.getMetaIntTx(ctx, tx, "horizon")
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(commitsBucket).Put(uint64KeyBytes(uint64(horizon)), uint64KeyBytes(uint64(time.Now().UnixNano())))
+}
+
+func (qs *QuadStore) stampDeleted(tx BucketTx, id, stamp uint64) error {
+	return tx.Bucket(deletedBucket).Put(uint64KeyBytes(id), uint64toBytes(stamp))
+}
+
+// archiveNode moves a node primitive that is about to be removed from the log to the history.
+func (qs *QuadStore) archiveNode(ctx context.Context, tx BucketTx, id uint64, h graph.ValueHash, stamp uint64) error {
+	p, err := qs.getPrimitiveFromLog(ctx, tx, id)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	buf, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+	if err = tx.Bucket(historyBucket).Put(uint64KeyBytes(id), buf); err != nil {
+		return err
+	}
+	b := tx.Bucket(historyValsBucket)
+	vals, err := b.Get(ctx, [][]byte{h[:]})
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	var prev []byte
+	if len(vals) != 0 {
+		prev = vals[0]
+	}
+	if err = b.Put(h[:], appendIndex(prev, []uint64{id})); err != nil {
+		return err
+	}
+	return qs.stampDeleted(tx, id, stamp)
+}
+
+// deletedAt returns deletion stamps for given ids. Zero stamp means that the id was not deleted.
+func (qs *QuadStore) deletedAt(ctx context.Context, tx BucketTx, ids []uint64) ([]uint64, error) {
+	keys := make([]BucketKey, len(ids))
+	for i, id := range ids {
+		keys[i] = BucketKey{Bucket: deletedBucket, Key: uint64KeyBytes(id)}
+	}
+	vals, err := tx.Get(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]uint64, len(ids))
+	for i, v := range vals {
+		if len(v) != 0 {
+			out[i], _ = binary.Uvarint(v)
+		}
+	}
+	return out, nil
+}
+
+// primitivesAsOf rewrites primitives loaded from the log to reflect the state at qs.asOf.
+// Nodes that were removed from the log are loaded from the history.
+func (qs *QuadStore) primitivesAsOf(ctx context.Context, tx BucketTx, keys []uint64, prims []*proto.Primitive) error {
+	var (
+		hist     []BucketKey
+		histInds []int
+	)
+	for i, k := range keys {
+		if k > uint64(qs.asOf) {
+			prims[i] = nil
+		} else if prims[i] == nil {
+			hist = append(hist, BucketKey{Bucket: historyBucket, Key: uint64KeyBytes(k)})
+			histInds = append(histInds, i)
+		}
+	}
+	if len(hist) != 0 {
+		vals, err := tx.Get(ctx, hist)
+		if err != nil {
+			return err
+		}
+		for j, v := range vals {
+			if len(v) == 0 {
+				continue
+			}
+			var p proto.Primitive
+			if err := p.Unmarshal(v); err != nil {
+				return err
+			}
+			p.Deleted = true
+			prims[histInds[j]] = &p
+		}
+	}
+	var (
+		dead     []uint64
+		deadInds []int
+	)
+	for i, p := range prims {
+		if p != nil && p.Deleted {
+			dead = append(dead, keys[i])
+			deadInds = append(deadInds, i)
+		}
+	}
+	if len(dead) == 0 {
+		return nil
+	}
+	stamps, err := qs.deletedAt(ctx, tx, dead)
+	if err != nil {
+		return err
+	}
+	for j, st := range stamps {
+		if st > uint64(qs.asOf) {
+			// deleted after the horizon; primitives are shared with no one, so it's safe to modify them
+			prims[deadInds[j]].Deleted = false
+		}
+	}
+	return nil
+}
+
+// resolveValuesAsOf fixes node ids resolved from the current state to ids that were
+// visible at qs.asOf. Nodes could be removed and added again, thus one value might
+// have multiple ids in its history.
+func (qs *QuadStore) resolveValuesAsOf(ctx context.Context, tx BucketTx, vals []quad.Value, ids []uint64) error {
+	var (
+		keys []BucketKey
+		inds []int
+	)
+	for i, v := range vals {
+		if v == nil || (ids[i] != 0 && ids[i] <= uint64(qs.asOf)) {
+			continue
+		}
+		ids[i] = 0
+		h := graph.HashOf(v)
+		keys = append(keys, BucketKey{Bucket: historyValsBucket, Key: h[:]})
+		inds = append(inds, i)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	lists, err := qs.getBucketIndexes(ctx, tx, keys)
+	if err != nil {
+		return err
+	}
+	for j, list := range lists {
+		var cand []uint64
+		for _, id := range list {
+			if id <= uint64(qs.asOf) {
+				cand = append(cand, id)
+			}
+		}
+		if len(cand) == 0 {
+			continue
+		}
+		stamps, err := qs.deletedAt(ctx, tx, cand)
+		if err != nil {
+			return err
+		}
+		for k, id := range cand {
+			if stamps[k] > uint64(qs.asOf) {
+				ids[inds[j]] = id
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestHistoryOption(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+
+	open := func(opt graph.Options) *kv.QuadStore {
+		qs, err := kv.New(db, opt)
+		require.NoError(t, err)
+		return qs.(*kv.QuadStore)
+	}
+	changes := func(qs *kv.QuadStore, from, to int64) ([]graph.LoggedDelta, error) {
+		var out []graph.LoggedDelta
+		err := qs.Changes(ctx, from, to, func(d graph.LoggedDelta) error {
+			out = append(out, d)
+			return nil
+		})
+		return out, err
+	}
+	// counts records of the history in all buckets
+	records := func() int {
+		n := 0
+		err := kv.View(db, func(tx kv.BucketTx) error {
+			for _, name := range []string{"history", "history_vals", "deleted", "commits"} {
+				it := tx.Bucket([]byte(name)).Scan(nil)
+				for it.Next(ctx) {
+					n++
+				}
+				it.Close()
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		return n
+	}
+
+	a := quad.MakeIRI("a", "follows", "b", "")
+	x := quad.MakeIRI("x", "follows", "y", "")
+
+	// history is disabled by default
+	qs := open(nil)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuad(a))
+	require.NoError(t, qw.RemoveQuad(a))
+	_, err = qs.AsOf(qs.Horizon())
+	require.Equal(t, kv.ErrNoHistory, err)
+	_, err = qs.HorizonAt(time.Now())
+	require.Equal(t, kv.ErrNoHistory, err)
+	_, err = changes(qs, 0, qs.Horizon())
+	require.Equal(t, kv.ErrNoHistory, err)
+	require.Equal(t, 0, records())
+
+	// history starts at the current state
+	qs = open(graph.Options{kv.OptHistory: true})
+	h0 := qs.Horizon()
+	h, err := qs.HorizonAt(time.Now())
+	require.NoError(t, err)
+	require.Equal(t, h0, h)
+	_, err = qs.AsOf(h0 - 1)
+	require.Equal(t, kv.ErrNoHistory, err)
+
+	qw, err = writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuad(x))
+	h1 := qs.Horizon()
+	require.NoError(t, qw.RemoveQuad(x))
+	h2 := qs.Horizon()
+
+	snap, err := qs.AsOf(h1)
+	require.NoError(t, err)
+	require.NotNil(t, snap.ValueOf(quad.IRI("y")))
+	require.NoError(t, snap.Close())
+	got, err := changes(qs, h0, h2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	_, err = changes(qs, h0-1, h2)
+	require.Equal(t, kv.ErrNoHistory, err)
+	require.NotEqual(t, 0, records())
+
+	// disabling the history removes it
+	qs = open(nil)
+	_, err = qs.AsOf(h2)
+	require.Equal(t, kv.ErrNoHistory, err)
+	require.Equal(t, 0, records())
+
+	_, err = kv.New(db, graph.Options{kv.OptHistory: true, kv.OptHistoryRetention: "week"})
+	require.Error(t, err)
+}
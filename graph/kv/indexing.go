@@ -18,8 +18,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"io"
 	"sort"
 	"time"
 
@@ -32,6 +32,12 @@ import (
 	boom "github.com/tylertreat/BoomFilters"
 )
 
+// intersectSearchRatio is the ratio of lengths of sorted lists, starting from which
+// they are intersected by a binary search instead of a merge.
+const intersectSearchRatio = 16
+
+var errInvalidIndex = errors.New("kv: invalid index list")
+
 var (
 	metaBucket = []byte("meta")
 	logIndex   = []byte("log")
@@ -40,6 +46,10 @@ var (
 	buckets = [][]byte{
 		metaBucket,
 		logIndex,
+		historyBucket,
+		historyValsBucket,
+		deletedBucket,
+		commitsBucket,
 	}
 
 	DefaultQuadIndexes = []QuadIndex{
@@ -301,6 +311,9 @@ func (qs *QuadStore) incNodes(ctx context.Context, tx BucketTx, deltas []graphlo
 			if err := qs.indexNode(tx, node, iv.Val); err != nil {
 				return ids, err
 			}
+			if err := qs.indexValue(tx, id, iv.Val); err != nil {
+				return ids, err
+			}
 			ins[i].ID = id
 		}
 		// note to increment counters
@@ -310,7 +323,7 @@ func (qs *QuadStore) incNodes(ctx context.Context, tx BucketTx, deltas []graphlo
 	_, err = qs.incNodesCnt(ctx, tx, upd)
 	return ids, err
 }
-func (qs *QuadStore) decNodes(ctx context.Context, tx BucketTx, deltas []graphlog.NodeUpdate, nodes map[graph.ValueHash]uint64) error {
+func (qs *QuadStore) decNodes(ctx context.Context, tx BucketTx, deltas []graphlog.NodeUpdate, nodes map[graph.ValueHash]uint64, stamp uint64) error {
 	upds := make([]nodeUpdate, 0, len(deltas))
 	for i, d := range deltas {
 		id := nodes[d.Hash]
@@ -332,6 +345,14 @@ func (qs *QuadStore) decNodes(ctx context.Context, tx BucketTx, deltas []graphlo
 		if iri, ok := d.Val.(quad.IRI); ok {
 			qs.valueLRU.Del(string(iri))
 		}
+		if err := qs.unindexValue(tx, d.ID, d.Val); err != nil {
+			return err
+		}
+		if qs.history {
+			if err := qs.archiveNode(ctx, tx, d.ID, d.Hash, stamp); err != nil {
+				return err
+			}
+		}
 		if err := qs.delLog(tx, d.ID); err != nil {
 			return err
 		}
@@ -339,7 +360,40 @@ func (qs *QuadStore) decNodes(ctx context.Context, tx BucketTx, deltas []graphlo
 	return nil
 }
 
+// hasQuad checks if the quad exists in the database.
+func (qs *QuadStore) hasQuad(ctx context.Context, tx BucketTx, q quad.Quad) (bool, error) {
+	vals := make([]quad.Value, len(quad.Directions))
+	for i, dir := range quad.Directions {
+		vals[i] = q.Get(dir)
+	}
+	ids, err := qs.resolveQuadValues(ctx, tx, vals)
+	if err != nil {
+		return false, err
+	}
+	var link proto.Primitive
+	for i, dir := range quad.Directions {
+		if vals[i] == nil {
+			continue
+		} else if ids[i] == 0 {
+			return false, nil
+		}
+		link.SetDirection(dir, ids[i])
+	}
+	p, err := qs.hasPrimitive(ctx, tx, &link, true)
+	if err != nil {
+		return false, err
+	}
+	return p != nil && !p.Deleted, nil
+}
+
 func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, in, ignoreOpts)
+}
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+
+// ApplyDeltasIf checks preconditions and applies deltas in the same write transaction.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, in []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
 	ctx := context.TODO()
 	qs.writer.Lock()
 	defer qs.writer.Unlock()
@@ -348,6 +402,14 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 		return err
 	}
 	defer tx.Rollback()
+	for _, c := range conds {
+		ok, err := qs.hasQuad(ctx, tx, c.Quad)
+		if err != nil {
+			return err
+		} else if ok != c.Exists {
+			return &graph.PreconditionError{Precondition: c}
+		}
+	}
 	b := tx.Bucket(logIndex)
 	if f, ok := b.(FillBucket); ok {
 		f.SetFillPercent(0.9)
@@ -361,6 +423,8 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 	}
 	deltas.IncNode = nil
 	// resolve and insert all new quads
+	ti := qs.timeIdx
+	var timed []timedLink
 	links := make([]proto.Primitive, 0, len(deltas.QuadAdd))
 	qadd := make(map[[4]uint64]struct{}, len(deltas.QuadAdd))
 	for _, q := range deltas.QuadAdd {
@@ -393,6 +457,9 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 			}
 		}
 		links = append(links, link)
+		if t, ok := ti.timeOf(in[q.Ind].Quad); ok {
+			timed = append(timed, timedLink{i: len(links) - 1, t: t})
+		}
 	}
 	qadd = nil
 	deltas.QuadAdd = nil
@@ -401,6 +468,7 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 	if err != nil {
 		return err
 	}
+	changed := len(links) != 0
 	for i := range links {
 		links[i].ID = qstart + uint64(i)
 		links[i].Timestamp = time.Now().UnixNano()
@@ -408,9 +476,27 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 	if err := qs.indexLinks(ctx, tx, links); err != nil {
 		return err
 	}
+	for _, l := range timed {
+		p := &links[l.i]
+		if err := tx.Bucket(timeBucket).Put(ti.key(p.Predicate, l.t, p.ID), []byte{}); err != nil {
+			return err
+		}
+	}
+	timed = nil
 	links = links[:0]
 
 	if len(deltas.QuadDel) != 0 || len(deltas.DecNode) != 0 {
+		// with history, all removals of this transaction are stamped with
+		// a single ID that is larger than IDs of quads added by it
+		var stamp uint64
+		if qs.history {
+			stamp, err = qs.genIDs(ctx, tx, 1)
+			if err != nil {
+				return err
+			}
+		}
+		changed = true
+
 		// resolve all nodes that will be removed
 		dnodes := make(map[graph.ValueHash]uint64, len(deltas.DecNode))
 		if err := qs.resolveValDeltas(ctx, tx, deltas.DecNode, func(i int, id uint64) {
@@ -463,9 +549,14 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 				continue
 			}
 			links = append(links, link)
+			if t, ok := ti.timeOf(in[q.Ind].Quad); ok {
+				if err := tx.Bucket(timeBucket).Del(ti.key(link.Predicate, t, link.ID)); err != nil {
+					return err
+				}
+			}
 		}
 		deltas.QuadDel = nil
-		if err := qs.markLinksDead(ctx, tx, links); err != nil {
+		if err := qs.markLinksDead(ctx, tx, links, stamp); err != nil {
 			return err
 		}
 		links = nil
@@ -481,18 +572,27 @@ func (qs *QuadStore) ApplyDeltas(in []graph.Delta, ignoreOpts graph.IgnoreOpts)
 		}
 
 		// finally decrement and remove nodes
-		if err := qs.decNodes(ctx, tx, deltas.DecNode, dnodes); err != nil {
+		if err := qs.decNodes(ctx, tx, deltas.DecNode, dnodes, stamp); err != nil {
 			return err
 		}
 		deltas = nil
 		dnodes = nil
 	}
+	if changed && qs.history {
+		if err := qs.recordCommit(ctx, tx); err != nil {
+			return err
+		}
+	}
 	// flush quad indexes and commit
 	err = qs.flushMapBucket(ctx, tx)
 	if err != nil {
 		return err
 	}
-	return tx.Commit(ctx)
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	qs.maybePruneHistory(ctx)
+	return nil
 }
 
 func (qs *QuadStore) indexNode(tx BucketTx, p *proto.Primitive, val quad.Value) error {
@@ -553,11 +653,17 @@ func (qs *QuadStore) delLog(tx BucketTx, id uint64) error {
 	return tx.Bucket(logIndex).Del(uint64KeyBytes(id))
 }
 
-func (qs *QuadStore) markLinksDead(ctx context.Context, tx BucketTx, links []proto.Primitive) error {
+func (qs *QuadStore) markLinksDead(ctx context.Context, tx BucketTx, links []proto.Primitive, stamp uint64) error {
 	for _, p := range links {
 		if err := qs.markAsDead(tx, &p); err != nil {
 			return err
 		}
+		if !qs.history {
+			continue
+		}
+		if err := qs.stampDeleted(tx, p.ID, stamp); err != nil {
+			return err
+		}
 	}
 	return qs.incSize(ctx, tx, -int64(len(links)))
 }
@@ -581,31 +687,50 @@ func (qs *QuadStore) getBucketIndexes(ctx context.Context, tx BucketTx, keys []B
 	return out, nil
 }
 
+// decodeIndex decodes a sorted list of ids. The first id is stored as a varint,
+// and each of the following ones as a varint delta from the previous id.
 func decodeIndex(b []byte) ([]uint64, error) {
-	r := bytes.NewBuffer(b)
-	var err error
-	var out []uint64
-	for {
-		var x uint64
-		x, err = binary.ReadUvarint(r)
-		if err != nil {
-			break
+	var (
+		out  []uint64
+		last uint64
+	)
+	for len(b) != 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errInvalidIndex
 		}
-		out = append(out, x)
-	}
-	if err != nil && err != io.EOF {
-		return nil, err
+		last += x
+		out = append(out, last)
+		b = b[n:]
 	}
 	return out, nil
 }
 
+// lastInIndex returns the last id of an encoded list.
+func lastInIndex(b []byte) uint64 {
+	var last uint64
+	for len(b) != 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			break
+		}
+		last += x
+		b = b[n:]
+	}
+	return last
+}
+
+// appendIndex appends sorted ids to an encoded list. All ids must be larger than the ones in the list.
 func appendIndex(bytelist []byte, l []uint64) []byte {
 	b := make([]byte, len(bytelist)+(binary.MaxVarintLen64*len(l)))
 	copy(b[:len(bytelist)], bytelist)
 	off := len(bytelist)
+	last := lastInIndex(bytelist)
 	for _, x := range l {
-		n := binary.PutUvarint(b[off:], x)
+		// unordered ids wrap around and are still decoded correctly, but take more space
+		n := binary.PutUvarint(b[off:], x-last)
 		off += n
+		last = x
 	}
 	return b[:off]
 }
@@ -624,8 +749,8 @@ func (qs *QuadStore) bestUnique() ([]QuadIndex, error) {
 	}
 	for _, in := range qs.indexes.all {
 		if in.Unique {
-			if clog.V(2) {
-				clog.Infof("using unique index: %v", in.Dirs)
+			if logger.V(2) {
+				logger.Info("using unique index", clog.F("dirs", in.Dirs))
 			}
 			qs.indexes.exists = []QuadIndex{in}
 			return qs.indexes.exists, nil
@@ -636,8 +761,8 @@ func (qs *QuadStore) bestUnique() ([]QuadIndex, error) {
 	if len(inds) == 0 {
 		return nil, fmt.Errorf("no indexes defined")
 	}
-	if clog.V(2) {
-		clog.Infof("using index intersection: %v", inds)
+	if logger.V(2) {
+		logger.Info("using index intersection", clog.F("indexes", inds))
 	}
 	qs.indexes.exists = inds
 	return qs.indexes.exists, nil
@@ -693,28 +818,39 @@ func (qs *QuadStore) hasPrimitive(ctx context.Context, tx BucketTx, p *proto.Pri
 	return nil, nil
 }
 
+// intersectSortedUint64 returns ids that are present in both sorted lists.
+//
+// Lists of similar length are merged. If one of the lists is much shorter, the position of each of its ids
+// in the longer list is found by a binary search instead, thus short lists are intersected with hubs quickly.
 func intersectSortedUint64(a, b []uint64) []uint64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
 	var c []uint64
-	boff := 0
-outer:
-	for _, x := range a {
-		for {
-			if boff >= len(b) {
-				break outer
-			}
-			if x > b[boff] {
-				boff++
-				continue
-			}
-			if x < b[boff] {
-				break
-			}
-			if x == b[boff] {
-				c = append(c, x)
-				boff++
-				break
+	if len(b) < intersectSearchRatio*len(a) {
+		for i, j := 0, 0; i < len(a) && j < len(b); {
+			switch {
+			case a[i] < b[j]:
+				i++
+			case a[i] > b[j]:
+				j++
+			default:
+				c = append(c, a[i])
+				i++
+				j++
 			}
 		}
+		return c
+	}
+	for _, x := range a {
+		i := sort.Search(len(b), func(i int) bool { return b[i] >= x })
+		if i == len(b) {
+			break
+		} else if b[i] == x {
+			c = append(c, x)
+			i++
+		}
+		b = b[i:]
 	}
 	return c
 }
@@ -847,8 +983,14 @@ func (qs *QuadStore) resolveQuadValues(ctx context.Context, tx BucketTx, vals []
 		if len(b) == 0 {
 			continue
 		}
-		ind := inds[i]
-		out[ind], _ = binary.Uvarint(b)
+		out[inds[i]], _ = binary.Uvarint(b)
+	}
+	if qs.asOf > 0 {
+		if err = qs.resolveValuesAsOf(ctx, tx, vals, out); err != nil {
+			return out, err
+		}
+	}
+	for _, ind := range inds {
 		if iri, ok := vals[ind].(quad.IRI); ok && out[ind] != 0 {
 			qs.valueLRU.Put(string(iri), uint64(out[ind]))
 		}
@@ -872,27 +1014,55 @@ func uint64KeyBytes(x uint64) []byte {
 	return k
 }
 
+// uint64KeysBytes is the same as uint64KeyBytes for multiple keys, but it allocates a single buffer for them.
+func uint64KeysBytes(xs []uint64) [][]byte {
+	buf := make([]byte, 8*len(xs))
+	out := make([][]byte, len(xs))
+	for i, x := range xs {
+		out[i] = buf[8*i : 8*i+8 : 8*i+8]
+		quadKeyEnc.PutUint64(out[i], x)
+	}
+	return out
+}
+
 func (qs *QuadStore) getPrimitivesFromLog(ctx context.Context, tx BucketTx, keys []uint64) ([]*proto.Primitive, error) {
 	b := tx.Bucket(logIndex)
-	bkeys := make([][]byte, len(keys))
-	for i, k := range keys {
-		bkeys[i] = uint64KeyBytes(k)
-	}
+	bkeys := uint64KeysBytes(keys)
 	vals, err := b.Get(ctx, bkeys)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]*proto.Primitive, len(keys))
-	var last error
+	// all primitives and their values are decoded into two allocations per batch
+	size := 0
+	for _, v := range vals {
+		size += len(v)
+	}
+	var (
+		out   = make([]*proto.Primitive, len(keys))
+		prims = make([]proto.Primitive, len(keys))
+		arena = make([]byte, 0, size)
+		last  error
+	)
 	for i, v := range vals {
 		if v == nil {
 			continue
 		}
-		var p proto.Primitive
-		if err = p.Unmarshal(v); err != nil {
+		p := &prims[i]
+		if err = p.UnmarshalShallow(v); err != nil {
 			last = err
-		} else {
-			out[i] = &p
+			continue
+		}
+		if len(p.Value) != 0 {
+			// values are only valid during the transaction
+			off := len(arena)
+			arena = append(arena, p.Value...)
+			p.Value = arena[off:len(arena):len(arena)]
+		}
+		out[i] = p
+	}
+	if qs.asOf > 0 {
+		if err = qs.primitivesAsOf(ctx, tx, keys, out); err != nil {
+			return out, err
 		}
 	}
 	return out, last
@@ -1,8 +1,16 @@
 package kv
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestIntersectSorted(t *testing.T) {
+	var hub []uint64
+	for i := uint64(1); i <= 100; i++ {
+		hub = append(hub, i)
+	}
 	tt := []struct {
 		a      []uint64
 		b      []uint64
@@ -18,6 +26,16 @@ func TestIntersectSorted(t *testing.T) {
 			b:      []uint64{1, 2, 3, 4, 5, 6},
 			expect: []uint64{6},
 		},
+		{
+			a:      []uint64{0, 5, 40, 77, 200},
+			b:      hub,
+			expect: []uint64{5, 40, 77},
+		},
+		{
+			a:      hub,
+			b:      []uint64{100, 101},
+			expect: []uint64{100},
+		},
 	}
 
 	for i, x := range tt {
@@ -49,3 +67,22 @@ func TestIndexlist(t *testing.T) {
 		}
 	}
 }
+
+func TestIndexlistAppend(t *testing.T) {
+	b := appendIndex(nil, []uint64{5, 10})
+	b = appendIndex(b, []uint64{2340, 3243366})
+	// deltas of sorted ids take a single byte
+	require.Len(t, appendIndex(nil, []uint64{100, 101, 102}), 3)
+	out, err := decodeIndex(b)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5, 10, 2340, 3243366}, out)
+
+	// unordered ids are still decoded
+	b = appendIndex(b, []uint64{7})
+	out, err = decodeIndex(b)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5, 10, 2340, 3243366, 7}, out)
+
+	_, err = decodeIndex([]byte{0x80})
+	require.Error(t, err)
+}
@@ -43,6 +43,41 @@ type KVIterator interface {
 	Val() []byte
 }
 
+// Seeker is an optional interface for buckets and flat transactions that can start a scan
+// from a given key without reading the keys before it.
+type Seeker interface {
+	// ScanFrom iterates over keys with a given prefix, starting from the first key
+	// that is not less than from. The from key must not be less than the prefix.
+	ScanFrom(pref, from []byte) KVIterator
+}
+
+// ScanFrom iterates over keys of the bucket with a given prefix, starting from the first key
+// that is not less than from. Buckets that don't implement Seeker scan and skip the keys before it.
+func ScanFrom(b Bucket, pref, from []byte) KVIterator {
+	if bytes.Compare(from, pref) < 0 {
+		from = pref
+	}
+	if s, ok := b.(Seeker); ok {
+		return s.ScanFrom(pref, from)
+	}
+	return &skipIter{KVIterator: b.Scan(pref), from: from}
+}
+
+type skipIter struct {
+	KVIterator
+	from []byte
+}
+
+func (it *skipIter) Next(ctx context.Context) bool {
+	for it.KVIterator.Next(ctx) {
+		if it.from == nil || bytes.Compare(it.Key(), it.from) >= 0 {
+			it.from = nil
+			return true
+		}
+	}
+	return false
+}
+
 type BucketKey struct {
 	Bucket, Key []byte
 }
@@ -68,6 +103,12 @@ type BucketKV interface {
 	Tx(update bool) (BucketTx, error)
 }
 
+// ReadOnlyKV is an optional interface for stores that can be opened in read-only mode.
+type ReadOnlyKV interface {
+	// ReadOnly checks if all writes to the store fail.
+	ReadOnly() bool
+}
+
 type FlatKV interface {
 	Base
 	Tx(update bool) (FlatTx, error)
@@ -223,3 +264,10 @@ func (b *flatBucket) Scan(pref []byte) KVIterator {
 	pref = b.key(pref)
 	return &prefIter{KVIterator: b.tx.Scan(pref), trim: b.pref}
 }
+func (b *flatBucket) ScanFrom(pref, from []byte) KVIterator {
+	pref, from = b.key(pref), b.key(from)
+	if s, ok := b.tx.(Seeker); ok {
+		return &prefIter{KVIterator: s.ScanFrom(pref, from), trim: b.pref}
+	}
+	return &prefIter{KVIterator: &skipIter{KVIterator: b.tx.Scan(pref), from: from}, trim: b.pref}
+}
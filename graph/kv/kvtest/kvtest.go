@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/graphtest"
@@ -18,6 +19,8 @@ type DatabaseFunc func(t testing.TB) (kv.BucketKV, graph.Options, func())
 
 type Config struct {
 	AlwaysRunIntegration bool
+	// NoSnapshotIsolation is set for backends where read transactions observe concurrent writes.
+	NoSnapshotIsolation bool
 }
 
 func (c Config) quadStore() *graphtest.Config {
@@ -27,23 +30,24 @@ func (c Config) quadStore() *graphtest.Config {
 	}
 }
 
-func newQuadStoreFunc(gen DatabaseFunc, bloom bool) testutil.DatabaseFunc {
+func newQuadStoreFunc(gen DatabaseFunc, extra graph.Options) testutil.DatabaseFunc {
 	return func(t testing.TB) (graph.QuadStore, graph.Options, func()) {
-		return newQuadStore(t, gen, bloom)
+		return newQuadStore(t, gen, extra)
 	}
 }
 
 func NewQuadStoreFunc(gen DatabaseFunc) testutil.DatabaseFunc {
-	return newQuadStoreFunc(gen, true)
+	return newQuadStoreFunc(gen, nil)
 }
 
-func newQuadStore(t testing.TB, gen DatabaseFunc, bloom bool) (graph.QuadStore, graph.Options, func()) {
+// newQuadStore creates a quad store with additional options set on top of the options of the database.
+func newQuadStore(t testing.TB, gen DatabaseFunc, extra graph.Options) (graph.QuadStore, graph.Options, func()) {
 	db, opt, closer := gen(t)
 	if opt == nil {
 		opt = make(graph.Options)
 	}
-	if !bloom {
-		opt[kv.OptNoBloom] = true
+	for k, v := range extra {
+		opt[k] = v
 	}
 	err := kv.Init(db, opt)
 	if err != nil {
@@ -64,7 +68,7 @@ func newQuadStore(t testing.TB, gen DatabaseFunc, bloom bool) (graph.QuadStore,
 }
 
 func NewQuadStore(t testing.TB, gen DatabaseFunc) (graph.QuadStore, graph.Options, func()) {
-	return newQuadStore(t, gen, true)
+	return newQuadStore(t, gen, nil)
 }
 
 func TestAll(t *testing.T, gen DatabaseFunc, conf *Config) {
@@ -75,13 +79,181 @@ func TestAll(t *testing.T, gen DatabaseFunc, conf *Config) {
 	t.Run("qs", func(t *testing.T) {
 		graphtest.TestAll(t, qsgen, conf.quadStore())
 	})
-	qsgenNoBloom := newQuadStoreFunc(gen, false)
+	qsgenNoBloom := newQuadStoreFunc(gen, graph.Options{kv.OptNoBloom: true})
 	t.Run("qs-no-bloom", func(t *testing.T) {
 		graphtest.TestAll(t, qsgenNoBloom, conf.quadStore())
 	})
 	t.Run("optimize", func(t *testing.T) {
 		testOptimize(t, gen, conf)
 	})
+	t.Run("snapshot", func(t *testing.T) {
+		testSnapshot(t, gen, conf)
+	})
+	t.Run("as of", func(t *testing.T) {
+		testAsOf(t, gen, conf)
+	})
+	t.Run("changes", func(t *testing.T) {
+		testChanges(t, gen, conf)
+	})
+	t.Run("scan from", func(t *testing.T) {
+		testScanFrom(t, gen, conf)
+	})
+}
+
+func testScanFrom(t *testing.T, gen DatabaseFunc, _ *Config) {
+	ctx := context.TODO()
+	db, _, closer := gen(t)
+	defer closer()
+	defer db.Close()
+
+	name := []byte("scan")
+	err := kv.Update(ctx, db, func(tx kv.BucketTx) error {
+		b := tx.Bucket(name)
+		for _, k := range []string{"a1", "a2", "a3", "b1", "b2"} {
+			if err := b.Put([]byte(k), []byte("v"+k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	scan := func(pref, from string) []string {
+		var keys []string
+		err := kv.View(db, func(tx kv.BucketTx) error {
+			it := kv.ScanFrom(tx.Bucket(name), []byte(pref), []byte(from))
+			defer it.Close()
+			for it.Next(ctx) {
+				keys = append(keys, string(it.Key()))
+				require.Equal(t, "v"+string(it.Key()), string(it.Val()))
+			}
+			return it.Err()
+		})
+		require.NoError(t, err)
+		return keys
+	}
+	require.Equal(t, []string{"a1", "a2", "a3", "b1", "b2"}, scan("", ""))
+	require.Equal(t, []string{"a3", "b1", "b2"}, scan("", "a2\x00"))
+	require.Equal(t, []string{"a2", "a3"}, scan("a", "a2"))
+	require.Equal(t, []string{"a1", "a2", "a3"}, scan("a", ""))
+	require.Equal(t, []string(nil), scan("a", "a4"))
+	require.Equal(t, []string{"b1", "b2"}, scan("b", "a2"))
+}
+
+func testSnapshot(t *testing.T, gen DatabaseFunc, conf *Config) {
+	qs, opts, closer := NewQuadStore(t, gen)
+	defer closer()
+
+	quads := graphtest.MakeQuadSet()
+	w := testutil.MakeWriter(t, qs, opts, quads...)
+
+	snap, err := qs.(graph.Snapshotter).Snapshot()
+	require.NoError(t, err)
+	defer snap.Close()
+	horizon := snap.Horizon()
+	require.Equal(t, qs.(graph.Snapshot).Horizon(), horizon)
+
+	expect := graphtest.IteratedQuads(t, snap, snap.QuadsAllIterator())
+	require.Len(t, expect, len(quads))
+
+	err = snap.ApplyDeltas([]graph.Delta{{Quad: quads[0], Action: graph.Delete}}, graph.IgnoreOpts{})
+	require.Equal(t, kv.ErrReadOnly, err)
+
+	if conf.NoSnapshotIsolation {
+		return
+	}
+	// some backends (Bolt) may block writes until the snapshot is closed
+	errc := make(chan error, 1)
+	go func() {
+		err := w.AddQuad(quad.Make("X", "follows", "Y", nil))
+		if err == nil {
+			err = w.RemoveQuad(quads[0])
+		}
+		errc <- err
+	}()
+	select {
+	case err = <-errc:
+		require.NoError(t, err)
+		errc <- nil
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.Equal(t, horizon, snap.Horizon())
+	require.Equal(t, expect, graphtest.IteratedQuads(t, snap, snap.QuadsAllIterator()))
+	require.Equal(t, int64(len(quads)), snap.Size())
+	require.Nil(t, snap.ValueOf(quad.String("X")), "node added after snapshot")
+	it := snap.QuadIterator(quad.Subject, snap.ValueOf(quads[0].Subject))
+	require.Contains(t, graphtest.IteratedQuads(t, snap, it), quads[0])
+
+	require.NoError(t, snap.Close())
+	require.NoError(t, <-errc)
+	require.True(t, qs.(graph.Snapshot).Horizon() > horizon)
+}
+
+func testAsOf(t *testing.T, gen DatabaseFunc, _ *Config) {
+	qs, opts, closer := newQuadStore(t, gen, graph.Options{kv.OptHistory: true})
+	defer closer()
+	tt := qs.(graph.TimeTraveler)
+
+	_, err := tt.HorizonAt(time.Now())
+	require.Equal(t, kv.ErrNoHistory, err)
+
+	first := []quad.Quad{
+		quad.Make("A", "follows", "B", nil),
+		quad.Make("X", "follows", "Y", nil),
+	}
+	w := testutil.MakeWriter(t, qs, opts, first...)
+	h1 := qs.(graph.Snapshot).Horizon()
+	time.Sleep(time.Millisecond)
+	t1 := time.Now()
+	time.Sleep(time.Millisecond)
+
+	// removes nodes X and Y, and adds X back with a new id
+	err = w.RemoveQuad(first[1])
+	require.NoError(t, err)
+	err = w.AddQuad(quad.Make("X", "likes", "Z", nil))
+	require.NoError(t, err)
+	h2 := qs.(graph.Snapshot).Horizon()
+	require.True(t, h2 > h1)
+
+	h, err := tt.HorizonAt(t1)
+	require.NoError(t, err)
+	require.Equal(t, h1, h)
+
+	_, err = tt.AsOf(h2 + 1)
+	require.Error(t, err)
+
+	check := func(horizon int64, exp []quad.Quad) {
+		snap, err := tt.AsOf(horizon)
+		require.NoError(t, err)
+		defer snap.Close()
+		require.Equal(t, horizon, snap.Horizon())
+		graphtest.ExpectIteratedQuads(t, snap, snap.QuadsAllIterator(), exp, true)
+
+		x := snap.ValueOf(quad.String("X"))
+		require.NotNil(t, x)
+		var sub []quad.Quad
+		for _, q := range exp {
+			if q.Subject == quad.String("X") {
+				sub = append(sub, q)
+			}
+		}
+		graphtest.ExpectIteratedQuads(t, snap, snap.QuadIterator(quad.Subject, x), sub, true)
+	}
+	check(h1, first)
+	check(h2, []quad.Quad{first[0], quad.Make("X", "likes", "Z", nil)})
+
+	snap, err := tt.AsOf(h1)
+	require.NoError(t, err)
+	require.Nil(t, snap.ValueOf(quad.String("Z")), "node added after the horizon")
+	require.NotNil(t, snap.ValueOf(quad.String("Y")), "node removed after the horizon")
+	require.NoError(t, snap.Close())
+
+	// the last version is kept, and the older ones are removed
+	require.NoError(t, qs.(*kv.QuadStore).PruneHistory(context.TODO(), time.Now()))
+	_, err = tt.AsOf(h1)
+	require.Equal(t, kv.ErrNoHistory, err)
+	check(h2, []quad.Quad{first[0], quad.Make("X", "likes", "Z", nil)})
 }
 
 func testOptimize(t *testing.T, gen DatabaseFunc, _ *Config) {
@@ -132,3 +304,49 @@ func BenchmarkAll(t *testing.B, gen DatabaseFunc, conf *Config) {
 		graphtest.BenchmarkAll(t, qsgen, conf.quadStore())
 	})
 }
+
+func testChanges(t *testing.T, gen DatabaseFunc, _ *Config) {
+	qs, opts, closer := newQuadStore(t, gen, graph.Options{kv.OptHistory: true})
+	defer closer()
+	cl := qs.(graph.ChangeLog)
+
+	a := quad.Make("A", "follows", "B", nil)
+	x := quad.Make("X", "follows", "Y", nil)
+	w := testutil.MakeWriter(t, qs, opts, a, x)
+	h1 := qs.(graph.Snapshot).Horizon()
+
+	// removes nodes X and Y, so their values must be loaded from the history
+	require.NoError(t, w.RemoveQuad(x))
+	require.NoError(t, w.AddQuad(x))
+	h2 := qs.(graph.Snapshot).Horizon()
+
+	changes := func(from, to int64) []graph.LoggedDelta {
+		var out []graph.LoggedDelta
+		err := cl.Changes(context.TODO(), from, to, func(d graph.LoggedDelta) error {
+			out = append(out, d)
+			return nil
+		})
+		require.NoError(t, err)
+		return out
+	}
+	got := changes(0, h2)
+	require.Len(t, got, 4)
+	var deltas []graph.Delta
+	for _, d := range got {
+		require.False(t, d.Time.IsZero())
+		deltas = append(deltas, d.Delta)
+	}
+	require.Equal(t, []graph.Delta{
+		{Quad: a, Action: graph.Add},
+		{Quad: x, Action: graph.Add},
+		{Quad: x, Action: graph.Delete},
+		{Quad: x, Action: graph.Add},
+	}, deltas)
+	require.Equal(t, h1, got[1].Horizon)
+	require.Equal(t, h2, got[3].Horizon)
+	require.True(t, got[2].Horizon > h1 && got[2].Horizon < h2)
+
+	require.Equal(t, got[2:], changes(h1, h2))
+	require.Len(t, changes(h2, h2), 0)
+	require.Error(t, cl.Changes(context.TODO(), 0, h2+1, func(graph.LoggedDelta) error { return nil }))
+}
@@ -160,7 +160,15 @@ func (tx *Tx) Del(k []byte) error {
 	return tx.tx.Delete(k, tx.db.wo)
 }
 func (tx *Tx) Scan(pref []byte) kv.KVIterator {
-	r, ro := util.BytesPrefix(pref), tx.db.ro
+	return tx.scan(util.BytesPrefix(pref))
+}
+func (tx *Tx) ScanFrom(pref, from []byte) kv.KVIterator {
+	r := util.BytesPrefix(pref)
+	r.Start = from
+	return tx.scan(r)
+}
+func (tx *Tx) scan(r *util.Range) kv.KVIterator {
+	ro := tx.db.ro
 	var it iterator.Iterator
 	if tx.tx != nil {
 		it = tx.tx.NewIterator(r, ro)
@@ -0,0 +1,91 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// OptPrefix enables the sorted index of lowercase string literals, used for prefix matching.
+const OptPrefix = "prefix"
+
+// maxPrefixKey is the maximal length of a text stored in the prefix index.
+// Longer values are truncated, thus matches for longer prefixes must be checked.
+const maxPrefixKey = 128
+
+var (
+	// prefixBucket maps a lowercase text and a node id to an empty value
+	prefixBucket = []byte("prefix")
+
+	_ graph.PrefixIndexer = (*QuadStore)(nil)
+)
+
+func prefixText(s string) string {
+	s = strings.ToLower(s)
+	if len(s) > maxPrefixKey {
+		s = s[:maxPrefixKey]
+	}
+	return s
+}
+
+func prefixKeys(id uint64, v quad.Value) [][]byte {
+	var s string
+	switch v := v.(type) {
+	case quad.String:
+		s = string(v)
+	case quad.TypedString:
+		s = string(v.Value)
+	case quad.LangString:
+		s = string(v.Value)
+	default:
+		return nil
+	}
+	s = prefixText(s)
+	key := make([]byte, len(s)+8)
+	copy(key, s)
+	quadKeyEnc.PutUint64(key[len(s):], id)
+	return [][]byte{key}
+}
+
+// NodesWithPrefix returns string literals that start with a given prefix, ignoring case.
+// Nodes are returned in the lexical order of their lowercase text, and the iterator is limited to
+// the given number of nodes, unless the limit is zero.
+//
+// It returns false if the prefix index is not enabled.
+func (qs *QuadStore) NodesWithPrefix(ctx context.Context, prefix string, limit int) (graph.Iterator, bool) {
+	if !qs.hasValueIndex(valueIndexes[1].flag) {
+		return nil, false
+	}
+	pref := []byte(prefixText(prefix))
+	it := iterator.NewFixed()
+	err := View(qs.db, func(tx BucketTx) error {
+		kit := tx.Bucket(prefixBucket).Scan(pref)
+		defer kit.Close()
+		for n := 0; (limit <= 0 || n < limit) && kit.Next(ctx); n++ {
+			k := kit.Key()
+			it.Add(Int64Value(quadKeyEnc.Uint64(k[len(k)-8:])))
+		}
+		return kit.Err()
+	})
+	if err != nil {
+		return iterator.NewError(err), true
+	}
+	return it, true
+}
@@ -0,0 +1,70 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestPrefixIndex(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+
+	qs, err := kv.New(db, graph.Options{kv.OptTrigram: true})
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = qw.AddQuadSet([]quad.Quad{
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Alice"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("albert"), nil),
+		quad.Make(quad.IRI("c"), quad.IRI("city"), quad.String("Albany"), nil),
+		quad.Make(quad.IRI("d"), quad.IRI("name"), quad.String("Bob"), nil),
+	})
+	require.NoError(t, err)
+	_, ok := qs.(graph.PrefixIndexer).NodesWithPrefix(ctx, "al", 0)
+	require.False(t, ok, "index is not enabled")
+
+	// enabling one more index must not rebuild or drop the other one
+	qs, err = kv.New(db, graph.Options{kv.OptPrefix: true})
+	require.NoError(t, err)
+	_, ok = qs.(graph.SubstringIndexer).NodesWithSubstrings(ctx, "lic")
+	require.True(t, ok)
+	qw, err = writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = qw.AddQuad(quad.Make(quad.IRI("e"), quad.IRI("name"), quad.String("ALF"), nil))
+	require.NoError(t, err)
+
+	values := func(pref string, limit int) []quad.Value {
+		it, ok := qs.(graph.PrefixIndexer).NodesWithPrefix(ctx, pref, limit)
+		require.True(t, ok)
+		vals, err := graph.Iterate(ctx, it).AllValues(qs)
+		require.NoError(t, err)
+		return vals
+	}
+	require.Equal(t, []quad.Value{
+		quad.String("Albany"), quad.String("albert"), quad.String("ALF"), quad.String("Alice"),
+	}, values("AL", 0))
+	require.Equal(t, []quad.Value{quad.String("Albany"), quad.String("albert")}, values("al", 2))
+
+	err = qw.RemoveQuad(quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("albert"), nil))
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("Albany")}, values("alb", 0))
+
+	vals, err := path.StartPath(qs).Out(quad.IRI("name")).Prefix("ali").Iterate(ctx).AllValues(qs)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("Alice")}, vals)
+
+	vals, err = text.Complete(ctx, qs, "al", 2, quad.IRI("name"))
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("ALF"), quad.String("Alice")}, vals)
+}
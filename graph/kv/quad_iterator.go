@@ -44,7 +44,7 @@ type QuadIterator struct {
 	prim *proto.Primitive
 }
 
-var _ graph.Iterator = &QuadIterator{}
+var _ graph.BatchIterator = &QuadIterator{}
 
 func NewQuadIterator(qs *QuadStore, ind QuadIndex, vals []uint64) *QuadIterator {
 	return &QuadIterator{
@@ -179,6 +179,15 @@ func (it *QuadIterator) Next(ctx context.Context) bool {
 	}
 }
 
+// NextBatch returns up to n next quads.
+func (it *QuadIterator) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	var out []graph.Value
+	for len(out) < n && it.Next(ctx) {
+		out = append(out, it.prim)
+	}
+	return out, it.err
+}
+
 func (it *QuadIterator) NextPath(ctx context.Context) bool {
 	return false
 }
@@ -20,6 +20,8 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
@@ -30,6 +32,9 @@ import (
 	boom "github.com/tylertreat/BoomFilters"
 )
 
+// logger is used for all messages of KV backends.
+var logger = clog.Module("kv")
+
 type Registration struct {
 	NewFunc      NewFunc
 	InitFunc     InitFunc
@@ -68,12 +73,37 @@ func Register(name string, r Registration) {
 			}
 			return New(kv, opt)
 		},
+		UpgradeFunc: func(addr string, opt graph.Options) error {
+			if !r.IsPersistent {
+				return nil
+			}
+			kv, err := r.NewFunc(addr, opt)
+			if err != nil {
+				return err
+			}
+			defer kv.Close()
+			if err = Upgrade(context.TODO(), kv); err != nil {
+				return err
+			}
+			return kv.Close()
+		},
+		VersionFunc: func(addr string, opt graph.Options) (int64, int64, error) {
+			if !r.IsPersistent {
+				return latestDataVersion, latestDataVersion, nil
+			}
+			kv, err := r.NewFunc(addr, opt)
+			if err != nil {
+				return 0, 0, err
+			}
+			defer kv.Close()
+			return DataVersion(kv)
+		},
 		IsPersistent: r.IsPersistent,
 	})
 }
 
 const (
-	latestDataVersion = 2
+	latestDataVersion = 3
 	nilDataVersion    = 1
 )
 
@@ -91,6 +121,23 @@ type QuadStore struct {
 
 	valueLRU *lru.Cache
 
+	// asOf is set for views of a past state of the graph; see AsOf
+	asOf int64
+
+	// valIndexes is a bit set of maintained value indexes; see valueIndexes
+	valIndexes int64
+	// timeIdx is set if quads of some predicates are indexed by time buckets; see OptTimeIndex
+	timeIdx *timeIndex
+
+	// history is set if writes record the history; see OptHistory
+	history   bool
+	retention time.Duration
+	// pruned is the last time old versions were removed; it's protected by the writer lock
+	pruned time.Time
+
+	// stats holds *graph.Statistics for the optimizer; it's shared with snapshots
+	stats *atomic.Value
+
 	writer    sync.Mutex
 	mapBucket map[string]map[string][]uint64
 
@@ -103,7 +150,7 @@ type QuadStore struct {
 }
 
 func newQuadStore(kv BucketKV) *QuadStore {
-	qs := &QuadStore{db: kv}
+	qs := &QuadStore{db: kv, stats: new(atomic.Value)}
 	qs.indexes.all = DefaultQuadIndexes
 	return qs
 }
@@ -141,13 +188,32 @@ func New(kv BucketKV, opt graph.Options) (graph.QuadStore, error) {
 	} else if err != nil {
 		return nil, err
 	} else if vers != latestDataVersion {
-		return nil, errors.New("kv: data version is out of date. Run cayleyupgrade for your config to update the data.")
+		return nil, errors.New("kv: data version is out of date; run cayley upgrade for your config to update the data")
+	}
+	if err := qs.checkBulkLoad(ctx); err != nil {
+		return nil, err
 	}
 	qs.valueLRU = lru.New(2000)
 	qs.exists.disabled, _ = opt.BoolKey(OptNoBloom, false)
+	if ro, ok := kv.(ReadOnlyKV); ok && ro.ReadOnly() {
+		// the filter is only checked by writes, and building it reads the whole log
+		qs.exists.disabled = true
+	}
 	if err := qs.initBloomFilter(ctx); err != nil {
 		return nil, err
 	}
+	if err := qs.loadValueIndexes(ctx, opt); err != nil {
+		return nil, err
+	}
+	if err := qs.loadTimeIndex(ctx, opt); err != nil {
+		return nil, err
+	}
+	if err := qs.loadHistory(ctx, opt); err != nil {
+		return nil, err
+	}
+	if err := qs.loadStatistics(ctx); err != nil {
+		return nil, err
+	}
 	return qs, nil
 }
 
@@ -185,6 +251,34 @@ func (qs *QuadStore) getMetaInt(ctx context.Context, key string) (int64, error)
 	return v, err
 }
 
+// userMetaPrefix separates metadata keys set with SetMetadata from internal ones
+const userMetaPrefix = "user:"
+
+var _ graph.MetadataStore = (*QuadStore)(nil)
+
+// Metadata returns a value of a metadata key set with SetMetadata, or nil if it's not set.
+func (qs *QuadStore) Metadata(ctx context.Context, key string) ([]byte, error) {
+	var out []byte
+	err := View(qs.db, func(tx BucketTx) error {
+		vals, err := tx.Bucket(metaBucket).Get(ctx, [][]byte{[]byte(userMetaPrefix + key)})
+		if err != nil {
+			return err
+		}
+		if vals[0] != nil {
+			out = append([]byte{}, vals[0]...)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// SetMetadata stores a value of a metadata key along with the data.
+func (qs *QuadStore) SetMetadata(ctx context.Context, key string, val []byte) error {
+	return Update(ctx, qs.db, func(tx BucketTx) error {
+		return tx.Bucket(metaBucket).Put([]byte(userMetaPrefix+key), append([]byte{}, val...))
+	})
+}
+
 func (qs *QuadStore) Size() int64 {
 	sz, _ := qs.getMetaInt(context.TODO(), "size")
 	return sz
@@ -229,6 +323,9 @@ func asInt64(b []byte, empty int64) (int64, error) {
 }
 
 func (qs *QuadStore) horizon(ctx context.Context) int64 {
+	if qs.asOf > 0 {
+		return qs.asOf
+	}
 	h, _ := qs.getMetaInt(ctx, "horizon")
 	return h
 }
@@ -260,21 +357,17 @@ func (qs *QuadStore) ValuesOf(ctx context.Context, vals []graph.Value) ([]quad.V
 	if len(refs) == 0 {
 		return out, nil
 	}
-	prim, err := qs.getPrimitives(ctx, refs)
-	if err != nil {
-		return out, err
-	}
 	var last error
-	for i, p := range prim {
-		if !p.IsNode() {
-			continue
-		}
-		qv, err := pquads.UnmarshalValue(p.Value)
-		if err != nil {
-			last = err
-			continue
+	err := View(qs.db, func(tx BucketTx) error {
+		qvals, err := qs.valuesFromLog(ctx, tx, refs)
+		for i, qv := range qvals {
+			out[inds[i]] = qv
 		}
-		out[inds[i]] = qv
+		last = err
+		return nil
+	})
+	if err != nil {
+		return out, err
 	}
 	return out, last
 }
@@ -301,7 +394,7 @@ func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 	ctx := context.TODO()
 	vals, err := qs.ValuesOf(ctx, []graph.Value{v})
 	if err != nil {
-		clog.Errorf("error getting NameOf %d: %s", v, err)
+		logger.Errorf("error getting NameOf %d: %s", v, err)
 		return nil
 	}
 	return vals[0]
@@ -310,7 +403,7 @@ func (qs *QuadStore) NameOf(v graph.Value) quad.Value {
 func (qs *QuadStore) Quad(k graph.Value) quad.Quad {
 	key, ok := k.(*proto.Primitive)
 	if !ok {
-		clog.Errorf("passed value was not a quad primitive: %T", k)
+		logger.Errorf("passed value was not a quad primitive: %T", k)
 		return quad.Quad{}
 	}
 	ctx := context.TODO()
@@ -322,7 +415,7 @@ func (qs *QuadStore) Quad(k graph.Value) quad.Quad {
 	})
 	if err != nil {
 		if err != ErrNotFound {
-			clog.Errorf("error fetching quad %#v: %s", key, err)
+			logger.Errorf("error fetching quad %#v: %s", key, err)
 		}
 		return quad.Quad{}
 	}
@@ -330,27 +423,66 @@ func (qs *QuadStore) Quad(k graph.Value) quad.Quad {
 }
 
 func (qs *QuadStore) primitiveToQuad(ctx context.Context, tx BucketTx, p *proto.Primitive) (quad.Quad, error) {
-	q := &quad.Quad{}
+	var (
+		q    quad.Quad
+		ids  [4]uint64
+		dirs [4]quad.Direction
+	)
+	n := 0
 	for _, dir := range quad.Directions {
-		v := p.GetDirection(dir)
-		val, err := qs.getValFromLog(ctx, tx, v)
-		if err != nil {
-			return *q, err
+		if id := p.GetDirection(dir); id != 0 {
+			ids[n], dirs[n] = id, dir
+			n++
 		}
-		q.Set(dir, val)
 	}
-	return *q, nil
+	vals, err := qs.valuesFromLog(ctx, tx, ids[:n])
+	if err != nil {
+		return q, err
+	}
+	for i, v := range vals {
+		q.Set(dirs[i], v)
+	}
+	return q, nil
 }
 
-func (qs *QuadStore) getValFromLog(ctx context.Context, tx BucketTx, k uint64) (quad.Value, error) {
-	if k == 0 {
-		return nil, nil
+// valuesFromLog returns values of nodes with given ids. Values are decoded directly from the buffers
+// of the transaction, without copying the primitives. If some of the nodes are missing,
+// values of the other ones are returned with ErrNotFound.
+func (qs *QuadStore) valuesFromLog(ctx context.Context, tx BucketTx, ids []uint64) ([]quad.Value, error) {
+	out := make([]quad.Value, len(ids))
+	var last error
+	if qs.asOf > 0 {
+		// removed nodes are loaded from the history
+		prims, err := qs.getPrimitivesFromLog(ctx, tx, ids)
+		if err != nil {
+			return out, err
+		}
+		for i, p := range prims {
+			if p == nil {
+				last = ErrNotFound
+			} else if out[i], err = pquads.UnmarshalValue(p.Value); err != nil {
+				last = err
+			}
+		}
+		return out, last
 	}
-	p, err := qs.getPrimitiveFromLog(ctx, tx, k)
+	vals, err := tx.Bucket(logIndex).Get(ctx, uint64KeysBytes(ids))
 	if err != nil {
-		return nil, err
+		return out, err
+	}
+	var p proto.Primitive
+	for i, v := range vals {
+		if v == nil {
+			last = ErrNotFound
+			continue
+		}
+		if err = p.UnmarshalShallow(v); err != nil {
+			last = err
+		} else if out[i], err = pquads.UnmarshalValue(p.Value); err != nil {
+			last = err
+		}
 	}
-	return pquads.UnmarshalValue(p.Value)
+	return out, last
 }
 
 func (qs *QuadStore) ValueOf(s quad.Value) graph.Value {
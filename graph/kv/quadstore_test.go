@@ -55,11 +55,21 @@ func le(v uint64) []byte {
 const (
 	bMeta = "meta"
 	bLog  = "log"
+
+	bHistory     = "history"
+	bHistoryVals = "history_vals"
+	bDeleted     = "deleted"
+	bCommits     = "commits"
 )
 
 var (
-	kVers = []byte("version")
-	vVers = le(2)
+	kVers       = []byte("version")
+	kValIndexes = []byte("value_indexes")
+	kTimeIndex  = []byte("time_index")
+	kHistory    = []byte("history_since")
+	kStats      = []byte("statistics")
+	kBulk       = []byte("bulk")
+	vVers       = le(3)
 
 	vAuto = []byte("auto")
 )
@@ -126,6 +136,11 @@ func TestApplyDeltas(t *testing.T) {
 
 	expect(Ops{
 		{opGet, bMeta, kVers, vVers, nil},
+		{opGet, bMeta, kBulk, nil, nil},
+		{opGet, bMeta, kValIndexes, nil, nil},
+		{opGet, bMeta, kTimeIndex, nil, nil},
+		{opGet, bMeta, kHistory, nil, nil},
+		{opGet, bMeta, kStats, nil, nil},
 	})
 
 	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
@@ -193,12 +208,12 @@ func TestApplyDeltas(t *testing.T) {
 		{opGet, "o", be(5), nil, nil},
 		{opPut, "o", be(5), hex("06"), nil},
 		{opGet, "s", be(1), hex("04"), nil},
-		{opPut, "s", be(1), hex("0406"), nil},
+		{opPut, "s", be(1), hex("0402"), nil},
 	})
 
 	err = qw.RemoveQuad(quad.MakeIRI("a", "b", "c", ""))
 	expect(Ops{
-		{opGet, "s", be(1), hex("0406"), nil},
+		{opGet, "s", be(1), hex("0402"), nil},
 		{opGet, "o", be(3), hex("04"), nil},
 		{opGet, bLog, be(4), vAuto, nil},
 		{opPut, bLog, be(4), vAuto, nil},
@@ -0,0 +1,254 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+// DefaultReindexBatch is the number of log entries indexed by each worker in one step of RebuildIndex.
+const DefaultReindexBatch = 10000
+
+// metaReindexPrefix is a prefix of meta keys that store the last log entry indexed by an unfinished rebuild
+const metaReindexPrefix = "reindex:"
+
+// ReindexOptions configures a rebuild of a value index.
+type ReindexOptions struct {
+	// Workers is the number of goroutines that read and index log entries. Defaults to GOMAXPROCS.
+	Workers int
+	// Batch is the number of log entries indexed by each worker in one step. Defaults to DefaultReindexBatch.
+	Batch int
+	// Progress is called after each step with the last indexed log entry and the last entry of the log.
+	Progress func(done, total uint64)
+}
+
+// ValueIndexes returns names of all optional value indexes, as accepted by RebuildIndex.
+func ValueIndexes() []string {
+	out := make([]string, 0, len(valueIndexes))
+	for _, ind := range valueIndexes {
+		out = append(out, ind.name)
+	}
+	return out
+}
+
+// HasIndex checks if a value index is built and maintained by the quad store.
+func (qs *QuadStore) HasIndex(name string) bool {
+	for _, ind := range valueIndexes {
+		if ind.name == name {
+			return qs.valIndexes&ind.flag != 0
+		}
+	}
+	return false
+}
+
+// RebuildIndex builds a value index from the log and marks it as available. It can be used to recover
+// a damaged index, or to enable an index on an existing database without opening it with the option.
+//
+// Log entries are indexed in parallel, and the progress is saved after each step. If the rebuild is interrupted,
+// the next call for the same index continues from the last saved entry. The index is not used by queries
+// until the rebuild completes, and the database must not be written to in the meantime.
+func (qs *QuadStore) RebuildIndex(ctx context.Context, name string, opts ReindexOptions) error {
+	for _, ind := range valueIndexes {
+		if ind.name == name {
+			return qs.rebuildValueIndex(ctx, ind, opts)
+		}
+	}
+	return fmt.Errorf("kv: unknown index %q", name)
+}
+
+func (qs *QuadStore) rebuildValueIndex(ctx context.Context, ind valueIndex, opts ReindexOptions) error {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = DefaultReindexBatch
+	}
+	progress := metaReindexPrefix + ind.name
+	last, err := qs.getMetaInt(ctx, progress)
+	if err == ErrNoBucket {
+		logger.Info("building index", clog.F("index", ind.name))
+		// start from scratch, so keys of deleted nodes are not left behind
+		if err = qs.dropValueIndex(ctx, ind, opts.Batch); err != nil {
+			return err
+		}
+		last = 0
+		if err = qs.putMeta(ctx, progress, last); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else {
+		logger.Info("resuming index", clog.F("index", ind.name), clog.F("entry", last))
+	}
+	total := uint64(qs.horizon(ctx))
+	step := uint64(opts.Batch)
+	for from := uint64(last) + 1; from <= total; {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		// index a few batches in parallel, but write them together, so the progress is consistent
+		end := from + step*uint64(opts.Workers)
+		if end > total+1 {
+			end = total + 1
+		}
+		var (
+			wg   sync.WaitGroup
+			keys = make([][][]byte, opts.Workers)
+			errs = make([]error, opts.Workers)
+		)
+		for i := 0; i < opts.Workers; i++ {
+			start := from + step*uint64(i)
+			if start >= end {
+				break
+			}
+			stop := start + step
+			if stop > end {
+				stop = end
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				keys[i], errs[i] = qs.valueIndexKeys(ctx, ind, start, stop)
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		err = Update(ctx, qs.db, func(tx BucketTx) error {
+			b := tx.Bucket(ind.bucket)
+			for _, arr := range keys {
+				for _, k := range arr {
+					if err := b.Put(k, []byte{}); err != nil {
+						return err
+					}
+				}
+			}
+			return putMetaInt(tx, progress, int64(end-1))
+		})
+		if err != nil {
+			return err
+		}
+		if opts.Progress != nil {
+			opts.Progress(end-1, total)
+		}
+		from = end
+	}
+	flags := qs.valIndexes | ind.flag
+	err = Update(ctx, qs.db, func(tx BucketTx) error {
+		if err := putMetaInt(tx, metaValueIndexes, flags); err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Del([]byte(progress))
+	})
+	if err != nil {
+		return err
+	}
+	qs.valIndexes = flags
+	return nil
+}
+
+// valueIndexKeys returns index keys of all nodes in a given range of log entries.
+func (qs *QuadStore) valueIndexKeys(ctx context.Context, ind valueIndex, from, to uint64) ([][]byte, error) {
+	ids := make([]uint64, 0, to-from)
+	for id := from; id < to; id++ {
+		ids = append(ids, id)
+	}
+	var out [][]byte
+	err := View(qs.db, func(tx BucketTx) error {
+		prims, err := qs.getPrimitivesFromLog(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range prims {
+			if p == nil || !p.IsNode() || p.Deleted {
+				continue
+			}
+			val, err := pquads.UnmarshalValue(p.Value)
+			if err != nil {
+				return err
+			}
+			out = append(out, ind.keys(p.ID, val)...)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// dropValueIndex marks the index as unavailable and removes all its keys.
+func (qs *QuadStore) dropValueIndex(ctx context.Context, ind valueIndex, batch int) error {
+	flags := qs.valIndexes &^ ind.flag
+	err := Update(ctx, qs.db, func(tx BucketTx) error {
+		return putMetaInt(tx, metaValueIndexes, flags)
+	})
+	if err != nil {
+		return err
+	}
+	qs.valIndexes = flags
+	return qs.clearBucket(ctx, ind.bucket, batch)
+}
+
+// clearBucket removes all keys from the bucket, a given number of keys per transaction.
+func (qs *QuadStore) clearBucket(ctx context.Context, bucket []byte, batch int) error {
+	for {
+		var keys [][]byte
+		err := View(qs.db, func(tx BucketTx) error {
+			it := tx.Bucket(bucket).Scan(nil)
+			defer it.Close()
+			for len(keys) < batch && it.Next(ctx) {
+				keys = append(keys, append([]byte{}, it.Key()...))
+			}
+			return it.Err()
+		})
+		if err == ErrNoBucket {
+			return nil
+		} else if err != nil || len(keys) == 0 {
+			return err
+		}
+		err = Update(ctx, qs.db, func(tx BucketTx) error {
+			b := tx.Bucket(bucket)
+			for _, k := range keys {
+				if err := b.Del(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (qs *QuadStore) putMeta(ctx context.Context, key string, v int64) error {
+	return Update(ctx, qs.db, func(tx BucketTx) error {
+		return putMetaInt(tx, key, v)
+	})
+}
+
+func putMetaInt(tx BucketTx, key string, v int64) error {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(v))
+	return tx.Bucket(metaBucket).Put([]byte(key), buf)
+}
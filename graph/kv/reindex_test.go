@@ -0,0 +1,81 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestRebuildIndex(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	var quads []quad.Quad
+	for i := 0; i < 50; i++ {
+		quads = append(quads, quad.Make(quad.IRI(fmt.Sprintf("n%d", i)), quad.IRI("name"), quad.String(fmt.Sprintf("name %d", i)), nil))
+	}
+	require.NoError(t, qw.AddQuadSet(quads))
+	require.NoError(t, qw.RemoveQuad(quads[7]))
+
+	kqs := qs.(*kv.QuadStore)
+	require.False(t, kqs.HasIndex(kv.OptPrefix))
+	require.Error(t, kqs.RebuildIndex(ctx, "unknown", kv.ReindexOptions{}))
+
+	// interrupt the rebuild after the first step
+	cctx, cancel := context.WithCancel(ctx)
+	steps := 0
+	err = kqs.RebuildIndex(cctx, kv.OptPrefix, kv.ReindexOptions{
+		Workers: 2, Batch: 10,
+		Progress: func(done, total uint64) {
+			steps++
+			require.Equal(t, uint64(20), done)
+			cancel()
+		},
+	})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 1, steps)
+	require.False(t, kqs.HasIndex(kv.OptPrefix))
+	_, ok := qs.(graph.PrefixIndexer).NodesWithPrefix(ctx, "name", 0)
+	require.False(t, ok, "partial index must not be used")
+
+	var last uint64
+	err = kqs.RebuildIndex(ctx, kv.OptPrefix, kv.ReindexOptions{
+		Workers: 3, Batch: 10,
+		Progress: func(done, total uint64) {
+			require.True(t, done > last && done <= total)
+			last = done
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, kqs.HasIndex(kv.OptPrefix))
+
+	it, ok := qs.(graph.PrefixIndexer).NodesWithPrefix(ctx, "NAME 1", 0)
+	require.True(t, ok)
+	vals, err := graph.Iterate(ctx, it).AllValues(qs)
+	require.NoError(t, err)
+	require.Len(t, vals, 11)
+
+	it, ok = qs.(graph.PrefixIndexer).NodesWithPrefix(ctx, "name ", 0)
+	require.True(t, ok)
+	n, err := graph.Iterate(ctx, it).Count()
+	require.NoError(t, err)
+	require.Equal(t, int64(49), n, "deleted nodes must not be indexed")
+
+	// the index is maintained after reopening
+	qs, err = kv.New(db, nil)
+	require.NoError(t, err)
+	require.True(t, qs.(*kv.QuadStore).HasIndex(kv.OptPrefix))
+}
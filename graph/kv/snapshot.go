@@ -0,0 +1,157 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/lru"
+)
+
+var ErrReadOnly = errors.New("kv: snapshot is read-only")
+
+var _ graph.Snapshotter = (*QuadStore)(nil)
+
+// Snapshot returns a read-only view of the quad store that serves all reads from a single
+// read transaction of the underlying KV. Isolation guarantees are the same as for read
+// transactions of the backend: Bolt, LevelDB and Badger views are not affected by concurrent writes.
+func (qs *QuadStore) Snapshot() (graph.Snapshot, error) {
+	return qs.snapshot()
+}
+
+func (qs *QuadStore) snapshot() (*QuadStore, error) {
+	tx, err := qs.db.Tx(false)
+	if err != nil {
+		return nil, err
+	}
+	s := newQuadStore(&snapshotKV{kv: qs.db, tx: tx})
+	qs.indexes.RLock()
+	s.indexes.all = qs.indexes.all
+	s.indexes.exists = qs.indexes.exists
+	qs.indexes.RUnlock()
+	s.valIndexes = qs.valIndexes
+	s.stats = qs.stats
+	// node ids in the cache of the parent might not exist in the snapshot
+	s.valueLRU = lru.New(2000)
+	// bloom filter is only an optimization for writes
+	s.exists.disabled = true
+	return s, nil
+}
+
+// Horizon returns the last ID assigned by the quad store.
+func (qs *QuadStore) Horizon() int64 {
+	return qs.horizon(context.TODO())
+}
+
+// snapshotKV serves all read transactions from a single long-lived transaction.
+//
+// Transactions of KV backends are not safe for concurrent use, thus all access is serialized.
+type snapshotKV struct {
+	mu sync.Mutex
+	kv BucketKV
+	tx BucketTx
+}
+
+func (s *snapshotKV) Type() string { return s.kv.Type() }
+func (s *snapshotKV) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tx == nil {
+		return nil
+	}
+	err := s.tx.Rollback()
+	s.tx = nil
+	return err
+}
+func (s *snapshotKV) Tx(update bool) (BucketTx, error) {
+	if update {
+		return nil, ErrReadOnly
+	}
+	return &snapshotTx{s: s}, nil
+}
+
+type snapshotTx struct {
+	s *snapshotKV
+}
+
+func (tx *snapshotTx) Commit(ctx context.Context) error { return nil }
+func (tx *snapshotTx) Rollback() error                  { return nil }
+func (tx *snapshotTx) Get(ctx context.Context, keys []BucketKey) ([][]byte, error) {
+	tx.s.mu.Lock()
+	defer tx.s.mu.Unlock()
+	return tx.s.tx.Get(ctx, keys)
+}
+func (tx *snapshotTx) Bucket(name []byte) Bucket {
+	tx.s.mu.Lock()
+	defer tx.s.mu.Unlock()
+	return &snapshotBucket{s: tx.s, b: tx.s.tx.Bucket(name)}
+}
+
+type snapshotBucket struct {
+	s *snapshotKV
+	b Bucket
+}
+
+func (b *snapshotBucket) Get(ctx context.Context, keys [][]byte) ([][]byte, error) {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+	return b.b.Get(ctx, keys)
+}
+func (b *snapshotBucket) Put(k, v []byte) error { return ErrReadOnly }
+func (b *snapshotBucket) Del(k []byte) error    { return ErrReadOnly }
+func (b *snapshotBucket) Scan(pref []byte) KVIterator {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+	return &snapshotIterator{s: b.s, it: b.b.Scan(pref)}
+}
+func (b *snapshotBucket) ScanFrom(pref, from []byte) KVIterator {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+	return &snapshotIterator{s: b.s, it: ScanFrom(b.b, pref, from)}
+}
+
+type snapshotIterator struct {
+	s  *snapshotKV
+	it KVIterator
+}
+
+func (it *snapshotIterator) Next(ctx context.Context) bool {
+	it.s.mu.Lock()
+	defer it.s.mu.Unlock()
+	return it.it.Next(ctx)
+}
+func (it *snapshotIterator) Err() error {
+	it.s.mu.Lock()
+	defer it.s.mu.Unlock()
+	return it.it.Err()
+}
+func (it *snapshotIterator) Close() error {
+	it.s.mu.Lock()
+	defer it.s.mu.Unlock()
+	return it.it.Close()
+}
+func (it *snapshotIterator) Key() []byte {
+	it.s.mu.Lock()
+	defer it.s.mu.Unlock()
+	return it.it.Key()
+}
+func (it *snapshotIterator) Val() []byte {
+	it.s.mu.Lock()
+	defer it.s.mu.Unlock()
+	return it.it.Val()
+}
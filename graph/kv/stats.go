@@ -0,0 +1,148 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var (
+	_ graph.IndexStatser    = (*QuadStore)(nil)
+	_ graph.Versioner       = (*QuadStore)(nil)
+	_ graph.StatisticsStore = (*QuadStore)(nil)
+)
+
+// DataVersion implements graph.Versioner. Quad stores are only opened with the latest version,
+// older ones must be upgraded first.
+func (qs *QuadStore) DataVersion() int64 {
+	return latestDataVersion
+}
+
+// IndexStats implements graph.IndexStatser. Sizes are computed by scanning each index,
+// and the log includes entries of nodes and deleted quads.
+func (qs *QuadStore) IndexStats(ctx context.Context) ([]graph.IndexStats, error) {
+	qs.indexes.RLock()
+	all := qs.indexes.all
+	qs.indexes.RUnlock()
+	var out []graph.IndexStats
+	err := View(qs.db, func(tx BucketTx) error {
+		scan := func(st *graph.IndexStats, b []byte) error {
+			it := tx.Bucket(b).Scan(nil)
+			defer it.Close()
+			for it.Next(ctx) {
+				st.Entries++
+				st.Bytes += int64(len(it.Key()) + len(it.Val()))
+			}
+			// buckets of the node index are created lazily
+			if err := it.Err(); err != ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+		add := func(name string, buckets ...[]byte) error {
+			st := graph.IndexStats{Name: name}
+			for _, b := range buckets {
+				if err := scan(&st, b); err != nil {
+					return err
+				}
+			}
+			out = append(out, st)
+			return nil
+		}
+		if err := add(string(logIndex), logIndex); err != nil {
+			return err
+		}
+		for _, ind := range all {
+			if err := add(string(ind.Bucket()), ind.Bucket()); err != nil {
+				return err
+			}
+		}
+		var nodes, refs [][]byte
+		for i := 0; i < 256; i++ {
+			for j := 0; j < 256; j++ {
+				nodes = append(nodes, bucketForVal(byte(i), byte(j)))
+				refs = append(refs, bucketForValRefs(byte(i), byte(j)))
+			}
+		}
+		if err := add("nodes", nodes...); err != nil {
+			return err
+		} else if err = add("refs", refs...); err != nil {
+			return err
+		}
+		for _, ind := range valueIndexes {
+			if qs.valIndexes&ind.flag == 0 {
+				continue
+			}
+			if err := add(ind.name, ind.bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// loadStatistics reads statistics saved by SetStatistics, if any.
+func (qs *QuadStore) loadStatistics(ctx context.Context) error {
+	var data []byte
+	err := View(qs.db, func(tx BucketTx) error {
+		v, err := GetOne(ctx, tx.Bucket(metaBucket), []byte(graph.StatisticsKey))
+		if err == ErrNotFound {
+			return nil
+		}
+		data = append([]byte{}, v...)
+		return err
+	})
+	if err != nil || data == nil {
+		return err
+	}
+	st := new(graph.Statistics)
+	if err = json.Unmarshal(data, st); err != nil {
+		// statistics are only an optimization
+		logger.Warningf("ignoring invalid statistics: %v", err)
+		return nil
+	}
+	qs.stats.Store(st)
+	return nil
+}
+
+// Statistics implements graph.StatisticsStore.
+func (qs *QuadStore) Statistics() *graph.Statistics {
+	st, _ := qs.stats.Load().(*graph.Statistics)
+	return st
+}
+
+// SetStatistics implements graph.StatisticsStore. Statistics are saved in the metadata,
+// so they are loaded when the quad store is opened.
+func (qs *QuadStore) SetStatistics(ctx context.Context, st *graph.Statistics) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	err = Update(ctx, qs.db, func(tx BucketTx) error {
+		return tx.Bucket(metaBucket).Put([]byte(graph.StatisticsKey), data)
+	})
+	if err != nil {
+		return err
+	}
+	qs.stats.Store(st)
+	return nil
+}
@@ -0,0 +1,339 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+const (
+	// OptTimeIndex is a comma-separated list of predicates with time values, such as "<created>,<startDate>",
+	// that are indexed by time buckets. Range filters on objects of these predicates only scan overlapping buckets.
+	OptTimeIndex = "time_index"
+	// OptTimeBucket is the size of buckets of the time index, such as "1h". Defaults to DefaultTimeBucket.
+	OptTimeBucket = "time_bucket"
+)
+
+// DefaultTimeBucket is the default size of buckets of the time index.
+const DefaultTimeBucket = 24 * time.Hour
+
+// metaTimeIndex stores the configuration of the time index, as returned by timeIndex.config
+const metaTimeIndex = "time_index"
+
+// maxTimeBuckets is the maximal number of buckets scanned one by one; larger ranges scan all keys of the predicate.
+const maxTimeBuckets = 1024
+
+var (
+	// timeBucket maps a predicate id, a time bucket and a quad id to an empty value
+	timeBucket = []byte("time")
+
+	_ graph.TimeIndexer = (*QuadStore)(nil)
+)
+
+// timeIndex is a configuration of the time index.
+type timeIndex struct {
+	preds map[quad.Value]struct{}
+	size  int64 // in seconds
+}
+
+func parseTimeIndex(opt graph.Options) (*timeIndex, error) {
+	list, err := opt.StringKey(OptTimeIndex, "")
+	if err != nil {
+		return nil, err
+	}
+	size, err := opt.StringKey(OptTimeBucket, "")
+	if err != nil {
+		return nil, err
+	}
+	ti := &timeIndex{preds: make(map[quad.Value]struct{}), size: int64(DefaultTimeBucket / time.Second)}
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			ti.preds[quad.StringToValue(p)] = struct{}{}
+		}
+	}
+	if len(ti.preds) == 0 {
+		return nil, nil
+	}
+	if size != "" {
+		d, err := time.ParseDuration(size)
+		if err != nil {
+			return nil, fmt.Errorf("kv: invalid %s: %v", OptTimeBucket, err)
+		} else if d < time.Second {
+			return nil, fmt.Errorf("kv: %s must be at least a second, got %v", OptTimeBucket, d)
+		}
+		ti.size = int64(d / time.Second)
+	}
+	return ti, nil
+}
+
+// config returns a string that changes if keys of the index change.
+func (ti *timeIndex) config() string {
+	if ti == nil {
+		return ""
+	}
+	preds := make([]string, 0, len(ti.preds))
+	for p := range ti.preds {
+		preds = append(preds, p.String())
+	}
+	sort.Strings(preds)
+	return fmt.Sprintf("%ds %s", ti.size, strings.Join(preds, ","))
+}
+
+// timeOf returns the time value of the quad if it should be indexed.
+func (ti *timeIndex) timeOf(q quad.Quad) (time.Time, bool) {
+	if ti == nil {
+		return time.Time{}, false
+	}
+	t, ok := q.Object.(quad.Time)
+	if !ok {
+		return time.Time{}, false
+	}
+	if _, ok = ti.preds[q.Predicate]; !ok {
+		return time.Time{}, false
+	}
+	return time.Time(t), true
+}
+
+func (ti *timeIndex) bucketOf(t time.Time) int64 {
+	sec := t.Unix()
+	b := sec / ti.size
+	if sec%ti.size < 0 {
+		b--
+	}
+	return b
+}
+
+func timeBucketKey(pred uint64, bucket int64) []byte {
+	key := make([]byte, 16, 24)
+	quadKeyEnc.PutUint64(key, pred)
+	// flip the sign bit, so negative buckets are sorted before positive ones
+	quadKeyEnc.PutUint64(key[8:], uint64(bucket)^(1<<63))
+	return key
+}
+
+func (ti *timeIndex) key(pred uint64, t time.Time, id uint64) []byte {
+	key := timeBucketKey(pred, ti.bucketOf(t))
+	key = key[:24]
+	quadKeyEnc.PutUint64(key[16:], id)
+	return key
+}
+
+// timedLink is a new quad that will be added to the time index.
+type timedLink struct {
+	i int // index in the list of links
+	t time.Time
+}
+
+func (qs *QuadStore) loadTimeIndex(ctx context.Context, opt graph.Options) error {
+	ti, err := parseTimeIndex(opt)
+	if err != nil {
+		return err
+	}
+	var cur string
+	err = View(qs.db, func(tx BucketTx) error {
+		v, err := GetOne(ctx, tx.Bucket(metaBucket), []byte(metaTimeIndex))
+		if err == ErrNotFound {
+			return nil
+		}
+		cur = string(v)
+		return err
+	})
+	if err != nil && err != ErrNoBucket {
+		return err
+	}
+	if cur == ti.config() {
+		qs.timeIdx = ti
+		return nil
+	}
+	if cur != "" {
+		// the index was built with a different configuration
+		err = Update(ctx, qs.db, func(tx BucketTx) error {
+			return tx.Bucket(metaBucket).Del([]byte(metaTimeIndex))
+		})
+		if err != nil {
+			return err
+		}
+		if err = qs.clearBucket(ctx, timeBucket, DefaultReindexBatch); err != nil {
+			return err
+		}
+	}
+	if ti == nil {
+		return nil
+	}
+	if err = qs.rebuildTimeIndex(ctx, ti); err != nil {
+		return err
+	}
+	err = Update(ctx, qs.db, func(tx BucketTx) error {
+		return tx.Bucket(metaBucket).Put([]byte(metaTimeIndex), []byte(ti.config()))
+	})
+	if err != nil {
+		return err
+	}
+	qs.timeIdx = ti
+	return nil
+}
+
+// rebuildTimeIndex adds all quads from the log to the time index.
+func (qs *QuadStore) rebuildTimeIndex(ctx context.Context, ti *timeIndex) error {
+	logger.Info("building time index")
+	preds := make(map[uint64]struct{}, len(ti.preds))
+	for p := range ti.preds {
+		if id, ok := qs.ValueOf(p).(Int64Value); ok {
+			preds[uint64(id)] = struct{}{}
+		}
+	}
+	if len(preds) == 0 {
+		return nil
+	}
+	total := uint64(qs.horizon(ctx))
+	for from := uint64(1); from <= total; from += DefaultReindexBatch {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ids := make([]uint64, 0, DefaultReindexBatch)
+		for id := from; id < from+DefaultReindexBatch && id <= total; id++ {
+			ids = append(ids, id)
+		}
+		err := Update(ctx, qs.db, func(tx BucketTx) error {
+			prims, err := qs.getPrimitivesFromLog(ctx, tx, ids)
+			if err != nil {
+				return err
+			}
+			var (
+				links []int
+				objs  []uint64
+			)
+			for i, p := range prims {
+				if p == nil || p.IsNode() || p.Deleted {
+					continue
+				} else if _, ok := preds[p.Predicate]; !ok {
+					continue
+				}
+				links = append(links, i)
+				objs = append(objs, p.Object)
+			}
+			if len(links) == 0 {
+				return nil
+			}
+			nodes, err := qs.getPrimitivesFromLog(ctx, tx, objs)
+			if err != nil {
+				return err
+			}
+			b := tx.Bucket(timeBucket)
+			for i, n := range nodes {
+				if n == nil {
+					continue
+				}
+				v, err := pquads.UnmarshalValue(n.Value)
+				if err != nil {
+					return err
+				}
+				t, ok := v.(quad.Time)
+				if !ok {
+					continue
+				}
+				p := prims[links[i]]
+				if err = b.Put(ti.key(p.Predicate, time.Time(t), p.ID), []byte{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QuadsInTimeRange returns quads with a given predicate and time values in buckets that overlap the range.
+//
+// It returns false if the predicate is not in the time index.
+func (qs *QuadStore) QuadsInTimeRange(ctx context.Context, pred quad.Value, from, to time.Time) (graph.Iterator, bool) {
+	ti := qs.timeIdx
+	if ti == nil || qs.asOf != 0 {
+		return nil, false
+	} else if _, ok := ti.preds[pred]; !ok {
+		return nil, false
+	}
+	pid, _ := qs.ValueOf(pred).(Int64Value)
+	if pid == 0 {
+		return iterator.NewNull(), true
+	}
+	lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+	if !from.IsZero() {
+		lo = ti.bucketOf(from)
+	}
+	if !to.IsZero() {
+		hi = ti.bucketOf(to)
+	}
+	if lo > hi {
+		return iterator.NewNull(), true
+	}
+	var ids []uint64
+	scan := func(b Bucket, pref []byte, lo, hi []byte) error {
+		kit := b.Scan(pref)
+		defer kit.Close()
+		for kit.Next(ctx) {
+			k := kit.Key()
+			if bytes.Compare(k[8:16], lo) < 0 {
+				continue
+			} else if bytes.Compare(k[8:16], hi) > 0 {
+				break
+			}
+			ids = append(ids, quadKeyEnc.Uint64(k[16:]))
+		}
+		return kit.Err()
+	}
+	it := iterator.NewFixed()
+	err := View(qs.db, func(tx BucketTx) error {
+		b := tx.Bucket(timeBucket)
+		klo, khi := timeBucketKey(uint64(pid), lo)[8:], timeBucketKey(uint64(pid), hi)[8:]
+		if !from.IsZero() && !to.IsZero() && hi-lo < maxTimeBuckets {
+			for bk := lo; bk <= hi; bk++ {
+				if err := scan(b, timeBucketKey(uint64(pid), bk), klo, khi); err != nil {
+					return err
+				}
+			}
+		} else if err := scan(b, timeBucketKey(uint64(pid), 0)[:8], klo, khi); err != nil {
+			return err
+		}
+		prims, err := qs.getPrimitivesFromLog(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range prims {
+			if p != nil && !p.Deleted {
+				it.Add(p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return iterator.NewError(err), true
+	}
+	return it, true
+}
@@ -0,0 +1,100 @@
+package kv_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestTimeIndex(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+
+	at, other := quad.IRI("at"), quad.IRI("other")
+	day := func(d int) quad.Time {
+		return quad.Time(time.Date(2019, 1, d, 12, 0, 0, 0, time.UTC))
+	}
+	var quads []quad.Quad
+	for d := 1; d <= 20; d++ {
+		ev := quad.IRI("e" + string(rune('a'+d-1)))
+		quads = append(quads, quad.Make(ev, at, day(d), nil))
+		quads = append(quads, quad.Make(ev, other, day(d), nil))
+	}
+	old := quad.Make(quad.IRI("old"), at, quad.Time(time.Date(1960, 5, 1, 0, 0, 0, 0, time.UTC)), nil)
+	quads = append(quads, old)
+
+	open := func(opt graph.Options) graph.QuadStore {
+		qs, err := kv.New(db, opt)
+		require.NoError(t, err)
+		return qs
+	}
+	qs := open(graph.Options{kv.OptTimeIndex: "<at>"})
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuadSet(quads))
+
+	between := func(qs graph.QuadStore, pred quad.IRI, from, to quad.Time) []string {
+		p := path.StartPath(qs).HasFilter(pred, false,
+			shape.Comparison{Op: iterator.CompareGTE, Val: from},
+			shape.Comparison{Op: iterator.CompareLT, Val: to},
+		)
+		var got []string
+		err := p.Iterate(ctx).EachValue(nil, func(v quad.Value) {
+			got = append(got, string(v.(quad.IRI)))
+		})
+		require.NoError(t, err)
+		sort.Strings(got)
+		return got
+	}
+	candidates := func(qs graph.QuadStore, from, to quad.Time) int {
+		it, ok := qs.(graph.TimeIndexer).QuadsInTimeRange(ctx, at, time.Time(from), time.Time(to))
+		require.True(t, ok)
+		n := 0
+		for it.Next(ctx) {
+			n++
+		}
+		require.NoError(t, it.Err())
+		return n
+	}
+
+	exp := []string{"ec", "ed", "ee"}
+	require.Equal(t, exp, between(qs, at, day(3), day(6)))
+	require.Equal(t, exp, between(qs, other, day(3), day(6)))
+	// buckets are days, thus only events of 4 days are checked
+	require.Equal(t, 4, candidates(qs, day(3), day(6)))
+	require.Equal(t, []string{"old"}, between(qs, at, quad.Time(time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC)), day(1)))
+
+	_, ok := qs.(graph.TimeIndexer).QuadsInTimeRange(ctx, other, time.Time(day(3)), time.Time(day(6)))
+	require.False(t, ok)
+
+	require.NoError(t, qw.RemoveQuad(quads[6])) // ed
+	require.Equal(t, []string{"ec", "ee"}, between(qs, at, day(3), day(6)))
+	require.Equal(t, 3, candidates(qs, day(3), day(6)))
+
+	// changing the bucket size rebuilds the index
+	qs = open(graph.Options{kv.OptTimeIndex: "<at>", kv.OptTimeBucket: "1h"})
+	require.Equal(t, []string{"ec", "ee"}, between(qs, at, day(3), day(6)))
+	require.Equal(t, 3, candidates(qs, day(3), day(6)))
+	require.Equal(t, []string{"old"}, between(qs, at, quad.Time(time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC)), day(1)))
+
+	qs = open(nil)
+	_, ok = qs.(graph.TimeIndexer).QuadsInTimeRange(ctx, at, time.Time(day(3)), time.Time(day(6)))
+	require.False(t, ok)
+	require.Equal(t, []string{"ec", "ee"}, between(qs, at, day(3), day(6)))
+
+	_, err = kv.New(db, graph.Options{kv.OptTimeIndex: "<at>", kv.OptTimeBucket: "1ms"})
+	require.Error(t, err)
+}
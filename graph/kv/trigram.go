@@ -0,0 +1,118 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// OptTrigram enables the trigram index of node values. The index is built when the database is opened
+// with this option for the first time, and is maintained on all writes after that.
+const OptTrigram = "trigram"
+
+var (
+	// trigramBucket maps a trigram and a node id to an empty value
+	trigramBucket = []byte("trigram")
+
+	_ graph.SubstringIndexer = (*QuadStore)(nil)
+)
+
+// trigramsOf returns all distinct trigrams of the string.
+func trigramsOf(s string) [][3]byte {
+	if len(s) < 3 {
+		return nil
+	}
+	out := make([][3]byte, 0, len(s)-2)
+	seen := make(map[[3]byte]struct{}, len(s)-2)
+	for i := 0; i+3 <= len(s); i++ {
+		var t [3]byte
+		copy(t[:], s[i:i+3])
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+func trigramKeys(id uint64, v quad.Value) [][]byte {
+	s, ok := nodeText(v)
+	if !ok {
+		return nil
+	}
+	grams := trigramsOf(s)
+	keys := make([][]byte, 0, len(grams))
+	for _, t := range grams {
+		key := make([]byte, 3+8)
+		copy(key, t[:])
+		quadKeyEnc.PutUint64(key[3:], id)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// NodesWithSubstrings returns nodes with values that contain all given substrings.
+//
+// Only substrings of at least 3 bytes can be searched in the index, thus the result must still be filtered.
+// It returns false if the trigram index is not enabled, or if none of the substrings is long enough.
+func (qs *QuadStore) NodesWithSubstrings(ctx context.Context, subs ...string) (graph.Iterator, bool) {
+	if !qs.hasValueIndex(valueIndexes[0].flag) {
+		return nil, false
+	}
+	var grams [][3]byte
+	for _, s := range subs {
+		grams = append(grams, trigramsOf(s)...)
+	}
+	if len(grams) == 0 {
+		return nil, false
+	}
+	var ids []uint64
+	err := View(qs.db, func(tx BucketTx) error {
+		b := tx.Bucket(trigramBucket)
+		for i, t := range grams {
+			var cur []uint64
+			// keys are sorted, thus ids are sorted as well
+			err := Each(ctx, b, t[:], func(k, _ []byte) error {
+				cur = append(cur, quadKeyEnc.Uint64(k[3:]))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				ids = cur
+			} else {
+				ids = intersectSortedUint64(ids, cur)
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return iterator.NewError(err), true
+	}
+	it := iterator.NewFixed()
+	for _, id := range ids {
+		it.Add(Int64Value(id))
+	}
+	return it, true
+}
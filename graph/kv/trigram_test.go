@@ -0,0 +1,76 @@
+package kv_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestTrigramIndex(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = qw.AddQuadSet([]quad.Quad{
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Alice Foobar"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("Bob"), nil),
+	})
+	require.NoError(t, err)
+	_, ok := qs.(graph.SubstringIndexer).NodesWithSubstrings(ctx, "oob")
+	require.False(t, ok, "index is not enabled")
+
+	// existing nodes are indexed when the option is set for the first time
+	qs, err = kv.New(db, graph.Options{kv.OptTrigram: true})
+	require.NoError(t, err)
+	qw, err = writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	err = qw.AddQuad(quad.Make(quad.IRI("c"), quad.IRI("name"), quad.String("Carol Foo"), nil))
+	require.NoError(t, err)
+
+	names := func(subs ...string) []string {
+		it, ok := qs.(graph.SubstringIndexer).NodesWithSubstrings(ctx, subs...)
+		require.True(t, ok)
+		vals, err := graph.Iterate(ctx, it).AllValues(qs)
+		require.NoError(t, err)
+		var out []string
+		for _, v := range vals {
+			out = append(out, v.String())
+		}
+		sort.Strings(out)
+		return out
+	}
+	require.Equal(t, []string{`"Alice Foobar"`, `"Carol Foo"`}, names("Foo"))
+	require.Equal(t, []string{`"Alice Foobar"`}, names("Foo", "Ali"))
+	require.Empty(t, names("xyz"))
+
+	err = qw.RemoveQuad(quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Alice Foobar"), nil))
+	require.NoError(t, err)
+	require.Equal(t, []string{`"Carol Foo"`}, names("Foo"))
+
+	// the index is still maintained without the option
+	qs, err = kv.New(db, nil)
+	require.NoError(t, err)
+	p := path.StartPath(qs).Out(quad.IRI("name")).Filters(shape.Wildcard{Pattern: "%oo%"})
+	vals, err := p.Iterate(ctx).AllValues(qs)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("Carol Foo")}, vals)
+
+	p = path.StartPath(qs).Out(quad.IRI("name")).Fuzzy("Karol Fo", 2)
+	vals, err = p.Iterate(ctx).AllValues(qs)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("Carol Foo")}, vals)
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// upgradeStep migrates the data from one version of the layout to the next one.
+type upgradeStep func(ctx context.Context, kv BucketKV) error
+
+// upgrades maps a data version to a step that upgrades it to the next version.
+//
+// There is no step for version 1: such databases were written before the current layout
+// and can only be migrated by a dump and a reload.
+var upgrades = map[int64]upgradeStep{
+	2: upgradeDeltaIndexes,
+}
+
+// upgradeBatch is the number of keys rewritten by an upgrade step in one transaction.
+const upgradeBatch = 10000
+
+// metaUpgrade is a meta key with the position of an interrupted upgrade step: a bucket name,
+// a zero byte and the last key rewritten in it.
+var metaUpgrade = []byte("upgrade")
+
+// upgradeDeltaIndexes re-encodes lists of ids in quad indexes and in the value history
+// from plain varints to varint deltas.
+func upgradeDeltaIndexes(ctx context.Context, db BucketKV) error {
+	var names [][]byte
+	for _, ind := range DefaultQuadIndexes {
+		names = append(names, ind.Bucket())
+	}
+	names = append(names, historyValsBucket)
+	return rewriteBuckets(ctx, db, names, func(v []byte) ([]byte, error) {
+		var ids []uint64
+		for len(v) != 0 {
+			x, n := binary.Uvarint(v)
+			if n <= 0 {
+				return nil, errInvalidIndex
+			}
+			ids = append(ids, x)
+			v = v[n:]
+		}
+		return appendIndex(nil, ids), nil
+	})
+}
+
+// rewriteBuckets replaces all values in given buckets. Values are rewritten in batches, and the position
+// is saved with each of them, so an interrupted step continues after the last batch and no value is rewritten twice.
+// Each batch seeks to the key after the previous one instead of scanning the bucket from the start.
+func rewriteBuckets(ctx context.Context, db BucketKV, names [][]byte, rewrite func(v []byte) ([]byte, error)) error {
+	var pos []byte
+	err := View(db, func(tx BucketTx) error {
+		var err error
+		pos, err = GetOne(ctx, tx.Bucket(metaBucket), metaUpgrade)
+		if err == ErrNotFound {
+			err = nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		var last []byte
+		if len(pos) != 0 {
+			i := bytes.IndexByte(pos, 0)
+			if i < 0 {
+				return fmt.Errorf("kv: invalid upgrade position: %q", pos)
+			} else if string(pos[:i]) != string(name) {
+				// the bucket was rewritten before the interruption
+				continue
+			}
+			last, pos = pos[i+1:], nil
+		}
+		for {
+			var keys, vals [][]byte
+			err = View(db, func(tx BucketTx) error {
+				var from []byte
+				if last != nil {
+					// the first key after the last one
+					from = append(append([]byte{}, last...), 0)
+				}
+				it := ScanFrom(tx.Bucket(name), nil, from)
+				defer it.Close()
+				for len(keys) < upgradeBatch && it.Next(ctx) {
+					v, err := rewrite(it.Val())
+					if err != nil {
+						return fmt.Errorf("kv: cannot rewrite %s entry %x: %v", name, it.Key(), err)
+					}
+					keys = append(keys, append([]byte{}, it.Key()...))
+					vals = append(vals, v)
+				}
+				if err := it.Err(); err != nil && err != ErrNoBucket {
+					return err
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			} else if len(keys) == 0 {
+				break
+			}
+			last = keys[len(keys)-1]
+			err = Update(ctx, db, func(tx BucketTx) error {
+				b := tx.Bucket(name)
+				for i, k := range keys {
+					if err := b.Put(k, vals[i]); err != nil {
+						return err
+					}
+				}
+				p := append(append(append([]byte{}, name...), 0), last...)
+				return tx.Bucket(metaBucket).Put(metaUpgrade, p)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return Update(ctx, db, func(tx BucketTx) error {
+		return tx.Bucket(metaBucket).Del(metaUpgrade)
+	})
+}
+
+// DataVersion returns the version of the data layout of the database and the latest version
+// supported by the quad store.
+func DataVersion(kv BucketKV) (cur, latest int64, err error) {
+	qs := newQuadStore(kv)
+	cur, err = qs.getMetadata(context.TODO())
+	if err == ErrNoBucket {
+		return 0, latestDataVersion, graph.ErrNotInitialized
+	} else if err != nil {
+		return 0, latestDataVersion, err
+	}
+	return cur, latestDataVersion, nil
+}
+
+// Upgrade migrates the data to the latest version of the layout in place.
+//
+// Steps are applied in order, and the version is saved after each of them, so an interrupted
+// upgrade continues from the last completed step. The database must not be opened by other
+// processes during the upgrade.
+func Upgrade(ctx context.Context, kv BucketKV) error {
+	vers, latest, err := DataVersion(kv)
+	if err != nil {
+		return err
+	} else if vers > latest {
+		return fmt.Errorf("kv: data version %d is newer than supported (%d); upgrade Cayley instead", vers, latest)
+	}
+	// check all steps first, so the data is not changed if one of them is missing
+	for v := vers; v < latest; v++ {
+		if upgrades[v] == nil {
+			return fmt.Errorf("kv: data version %d cannot be upgraded in place; dump the data with the Cayley version that wrote it and load it again", v)
+		}
+	}
+	for ; vers < latest; vers++ {
+		logger.Info("upgrading data", clog.F("from", vers), clog.F("to", vers+1))
+		if err = upgrades[vers](ctx, kv); err != nil {
+			return err
+		}
+		if err = setVersion(ctx, kv, vers+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package kv_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestUpgrade(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	_, _, err := kv.DataVersion(db)
+	require.Equal(t, graph.ErrNotInitialized, err)
+
+	require.NoError(t, kv.Init(db, nil))
+	cur, latest, err := kv.DataVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, latest, cur)
+	require.NoError(t, kv.Upgrade(ctx, db))
+
+	setVersion := func(v []byte) {
+		err := kv.Update(ctx, db, func(tx kv.BucketTx) error {
+			return tx.Bucket([]byte("meta")).Put([]byte("version"), v)
+		})
+		require.NoError(t, err)
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(latest+1))
+	setVersion(buf[:])
+	require.Error(t, kv.Upgrade(ctx, db))
+	_, err = kv.New(db, nil)
+	require.Error(t, err)
+
+	// the oldest layout has no in-place upgrade
+	setVersion([]byte{})
+	cur, _, err = kv.DataVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), cur)
+	require.Error(t, kv.Upgrade(ctx, db))
+}
+
+func TestUpgradeDeltaIndexes(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	var quads []quad.Quad
+	for i := 0; i < 5; i++ {
+		quads = append(quads, quad.MakeIRI("a", "b", fmt.Sprintf("c%d", i), ""))
+	}
+	for _, q := range quads {
+		require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{}))
+	}
+
+	// rewrite index lists to plain varints of the version 2
+	err = kv.Update(ctx, db, func(tx kv.BucketTx) error {
+		for _, name := range []string{"s", "o"} {
+			b := tx.Bucket([]byte(name))
+			vals := make(map[string][]byte)
+			err := kv.Each(ctx, b, nil, func(k, v []byte) error {
+				var (
+					plain []byte
+					last  uint64
+					buf   [binary.MaxVarintLen64]byte
+				)
+				for len(v) != 0 {
+					x, n := binary.Uvarint(v)
+					last += x
+					plain = append(plain, buf[:binary.PutUvarint(buf[:], last)]...)
+					v = v[n:]
+				}
+				vals[string(k)] = plain
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for k, v := range vals {
+				if err := b.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], 2)
+		return tx.Bucket([]byte("meta")).Put([]byte("version"), buf[:])
+	})
+	require.NoError(t, err)
+	_, err = kv.New(db, nil)
+	require.Error(t, err)
+
+	require.NoError(t, kv.Upgrade(ctx, db))
+	qs, err = kv.New(db, nil)
+	require.NoError(t, err)
+	it := qs.QuadIterator(quad.Subject, qs.ValueOf(quad.IRI("a")))
+	defer it.Close()
+	var got []quad.Quad
+	for it.Next(ctx) {
+		got = append(got, qs.Quad(it.Result()))
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, quads, got)
+}
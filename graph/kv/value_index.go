@@ -0,0 +1,122 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// metaValueIndexes is a bit set of value indexes that are maintained by the quad store
+const metaValueIndexes = "value_indexes"
+
+// valueIndex is an optional secondary index of node values.
+//
+// An index is built when the database is opened with its option for the first time,
+// and is maintained on all writes after that.
+type valueIndex struct {
+	name   string // name of the option that enables the index
+	flag   int64
+	bucket []byte
+	// keys returns keys of the node in the index; values of all keys are empty
+	keys func(id uint64, v quad.Value) [][]byte
+}
+
+var valueIndexes = []valueIndex{
+	{name: OptTrigram, flag: 1 << 0, bucket: trigramBucket, keys: trigramKeys},
+	{name: OptPrefix, flag: 1 << 1, bucket: prefixBucket, keys: prefixKeys},
+	{name: OptGeo, flag: 1 << 2, bucket: geoBucket, keys: geoKeys},
+}
+
+// nodeText returns the text of a value, as seen by regexp, wildcard, fuzzy and prefix filters.
+func nodeText(v quad.Value) (string, bool) {
+	switch v := v.(type) {
+	case quad.String:
+		return string(v), true
+	case quad.TypedString:
+		return string(v.Value), true
+	case quad.LangString:
+		return string(v.Value), true
+	case quad.IRI:
+		return string(v), true
+	case quad.BNode:
+		return string(v), true
+	}
+	return "", false
+}
+
+func (qs *QuadStore) hasValueIndex(flag int64) bool {
+	return qs.valIndexes&flag != 0 && qs.asOf == 0
+}
+
+func (qs *QuadStore) loadValueIndexes(ctx context.Context, opt graph.Options) error {
+	flags, err := qs.getMetaInt(ctx, metaValueIndexes)
+	if err != nil && err != ErrNoBucket {
+		return err
+	}
+	qs.valIndexes = flags
+	for _, ind := range valueIndexes {
+		on, err := opt.BoolKey(ind.name, false)
+		if err != nil {
+			return err
+		} else if on && flags&ind.flag == 0 {
+			if err = qs.rebuildValueIndex(ctx, ind, ReindexOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func putValueIndex(tx BucketTx, ind valueIndex, id uint64, val quad.Value) error {
+	b := tx.Bucket(ind.bucket)
+	for _, k := range ind.keys(id, val) {
+		if err := b.Put(k, []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexValue adds a new node to all value indexes.
+func (qs *QuadStore) indexValue(tx BucketTx, id uint64, val quad.Value) error {
+	for _, ind := range valueIndexes {
+		if qs.valIndexes&ind.flag == 0 {
+			continue
+		}
+		if err := putValueIndex(tx, ind, id, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexValue removes a deleted node from all value indexes.
+func (qs *QuadStore) unindexValue(tx BucketTx, id uint64, val quad.Value) error {
+	for _, ind := range valueIndexes {
+		if qs.valIndexes&ind.flag == 0 {
+			continue
+		}
+		b := tx.Bucket(ind.bucket)
+		for _, k := range ind.keys(id, val) {
+			if err := b.Del(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
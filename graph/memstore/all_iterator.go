@@ -21,7 +21,7 @@ import (
 	"github.com/cayleygraph/cayley/graph/iterator"
 )
 
-var _ graph.Iterator = (*AllIterator)(nil)
+var _ graph.BatchIterator = (*AllIterator)(nil)
 
 type AllIterator struct {
 	uid  uint64
@@ -31,6 +31,8 @@ type AllIterator struct {
 	all   []*primitive
 	maxid int64 // id of last observed insert (prim id)
 	nodes bool
+	size  int64 // number of nodes or quads when the iterator was created
+	exact bool  // set if no primitives were added after maxid
 
 	i    int // index into qs.all
 	cur  *primitive
@@ -38,11 +40,17 @@ type AllIterator struct {
 }
 
 func newAllIterator(qs *QuadStore, nodes bool, maxid int64) *AllIterator {
-	return &AllIterator{
+	it := &AllIterator{
 		uid: iterator.NextUID(),
 		qs:  qs, all: qs.cloneAll(), nodes: nodes,
 		i: -1, maxid: maxid,
 	}
+	it.size = int64(len(qs.quads))
+	if nodes {
+		it.size = int64(len(it.all)) - it.size
+	}
+	it.exact = maxid == qs.last
+	return it
 }
 
 func (it *AllIterator) Clone() graph.Iterator {
@@ -93,6 +101,15 @@ func (it *AllIterator) Next(ctx context.Context) bool {
 	return false
 }
 
+// NextBatch returns up to n next nodes or quads.
+func (it *AllIterator) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	var out []graph.Value
+	for len(out) < n && it.Next(ctx) {
+		out = append(out, it.Result())
+	}
+	return out, nil
+}
+
 func (it *AllIterator) Contains(ctx context.Context, v graph.Value) bool {
 	it.cur = nil
 	if it.done {
@@ -149,8 +166,7 @@ func (it *AllIterator) String() string {
 func (it *AllIterator) NextPath(ctx context.Context) bool { return false }
 
 func (it *AllIterator) Size() (int64, bool) {
-	// TODO: use maxid?
-	return int64(len(it.all)), true
+	return it.size, it.exact
 }
 func (it *AllIterator) Stats() graph.IteratorStats {
 	st := graph.IteratorStats{NextCost: 1, ContainsCost: 1}
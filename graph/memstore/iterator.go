@@ -25,16 +25,17 @@ import (
 	"github.com/cayleygraph/cayley/quad"
 )
 
-var _ graph.Iterator = &Iterator{}
+var _ graph.BatchIterator = &Iterator{}
 
 type Iterator struct {
 	nodes bool
 	uid   uint64
 	qs    *QuadStore
 	tags  graph.Tagger
-	tree  *Tree
+	list  *postings
 
 	iter *Enumerator
+	last int64 // id of the last quad returned by Next
 	cur  *primitive
 	err  error
 
@@ -42,12 +43,12 @@ type Iterator struct {
 	value int64
 }
 
-func NewIterator(tree *Tree, qs *QuadStore, d quad.Direction, value int64) *Iterator {
+func NewIterator(list *postings, qs *QuadStore, d quad.Direction, value int64) *Iterator {
 	return &Iterator{
 		nodes: d == 0,
 		uid:   iterator.NextUID(),
 		qs:    qs,
-		tree:  tree,
+		list:  list,
 		d:     d,
 		value: value,
 	}
@@ -59,6 +60,7 @@ func (it *Iterator) UID() uint64 {
 
 func (it *Iterator) Reset() {
 	it.iter = nil
+	it.last = 0
 	it.err = nil
 	it.cur = nil
 }
@@ -72,7 +74,7 @@ func (it *Iterator) TagResults(dst map[string]graph.Value) {
 }
 
 func (it *Iterator) Clone() graph.Iterator {
-	m := NewIterator(it.tree, it.qs, it.d, it.value)
+	m := NewIterator(it.list, it.qs, it.d, it.value)
 	m.tags.CopyFrom(it)
 	return m
 }
@@ -83,8 +85,8 @@ func (it *Iterator) Close() error {
 
 func (it *Iterator) Next(ctx context.Context) bool {
 	graph.NextLogIn(it)
-	if it.iter == nil {
-		it.iter, it.err = it.tree.SeekFirst()
+	if it.iter == nil && it.last == 0 && it.list.tree != nil {
+		it.iter, it.err = it.list.tree.SeekFirst()
 		if it.err == io.EOF || it.iter == nil {
 			it.err = nil
 			return graph.NextLogOut(it, false)
@@ -92,7 +94,7 @@ func (it *Iterator) Next(ctx context.Context) bool {
 			return graph.NextLogOut(it, false)
 		}
 	}
-	for {
+	if it.iter != nil {
 		_, p, err := it.iter.Next()
 		if err != nil {
 			if err != io.EOF {
@@ -100,9 +102,25 @@ func (it *Iterator) Next(ctx context.Context) bool {
 			}
 			return graph.NextLogOut(it, false)
 		}
-		it.cur = p
+		it.cur, it.last = p, p.ID
 		return graph.NextLogOut(it, true)
 	}
+	// short postings are changed in place, thus the position is kept as an id
+	p, ok := it.list.After(it.last)
+	if !ok {
+		return graph.NextLogOut(it, false)
+	}
+	it.cur, it.last = p, p.ID
+	return graph.NextLogOut(it, true)
+}
+
+// NextBatch returns up to n next quads.
+func (it *Iterator) NextBatch(ctx context.Context, n int) ([]graph.Value, error) {
+	var out []graph.Value
+	for len(out) < n && it.Next(ctx) {
+		out = append(out, qprim{p: it.cur})
+	}
+	return out, it.err
 }
 
 func (it *Iterator) Err() error {
@@ -126,7 +144,7 @@ func (it *Iterator) SubIterators() []graph.Iterator {
 }
 
 func (it *Iterator) Size() (int64, bool) {
-	return int64(it.tree.Len()), true
+	return int64(it.list.Len()), true
 }
 
 func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
@@ -136,7 +154,7 @@ func (it *Iterator) Contains(ctx context.Context, v graph.Value) bool {
 	}
 	switch v := v.(type) {
 	case bnode:
-		if p, ok := it.tree.Get(int64(v)); ok {
+		if p, ok := it.list.Get(int64(v)); ok {
 			it.cur = p
 			return graph.ContainsLogOut(it, v, true)
 		}
@@ -163,9 +181,9 @@ func (it *Iterator) Optimize() (graph.Iterator, bool) {
 
 func (it *Iterator) Stats() graph.IteratorStats {
 	return graph.IteratorStats{
-		ContainsCost: int64(math.Log(float64(it.tree.Len()))) + 1,
+		ContainsCost: int64(math.Log(float64(it.list.Len()))) + 1,
 		NextCost:     1,
-		Size:         int64(it.tree.Len()),
+		Size:         int64(it.list.Len()),
 		ExactSize:    true,
 	}
 }
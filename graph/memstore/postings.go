@@ -0,0 +1,103 @@
+// Copyright 2014 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import "sort"
+
+// maxPostings is the maximal length of postings kept in a slice. It matches a data page of the b-tree.
+const maxPostings = 2 * kd
+
+// postings is a list of quads that have a given node in a given direction, sorted by quad id.
+//
+// Most nodes are used by only a few quads, thus short lists are kept in a slice,
+// and only the ones that outgrow a data page are moved to a b-tree.
+type postings struct {
+	list []*primitive
+	tree *Tree
+}
+
+func (p *postings) Len() int {
+	if p.tree != nil {
+		return p.tree.Len()
+	}
+	return len(p.list)
+}
+
+// search returns the position of the first quad with an id that is not less than a given one.
+func (p *postings) search(id int64) int {
+	return sort.Search(len(p.list), func(i int) bool {
+		return p.list[i].ID >= id
+	})
+}
+
+func (p *postings) Get(id int64) (*primitive, bool) {
+	if p.tree != nil {
+		return p.tree.Get(id)
+	}
+	if i := p.search(id); i < len(p.list) && p.list[i].ID == id {
+		return p.list[i], true
+	}
+	return nil, false
+}
+
+func (p *postings) Set(id int64, pr *primitive) {
+	if p.tree != nil {
+		p.tree.Set(id, pr)
+		return
+	}
+	i := p.search(id)
+	if i < len(p.list) && p.list[i].ID == id {
+		p.list[i] = pr
+		return
+	}
+	if len(p.list) >= maxPostings {
+		p.tree = TreeNew(cmp)
+		for _, pr := range p.list {
+			p.tree.Set(pr.ID, pr)
+		}
+		p.list = nil
+		p.tree.Set(id, pr)
+		return
+	}
+	p.list = append(p.list, nil)
+	copy(p.list[i+1:], p.list[i:])
+	p.list[i] = pr
+}
+
+func (p *postings) Delete(id int64) {
+	if p.tree != nil {
+		p.tree.Delete(id)
+		return
+	}
+	if i := p.search(id); i < len(p.list) && p.list[i].ID == id {
+		copy(p.list[i:], p.list[i+1:])
+		p.list[len(p.list)-1] = nil
+		p.list = p.list[:len(p.list)-1]
+	}
+}
+
+// After returns the first quad with an id greater than a given one.
+func (p *postings) After(id int64) (*primitive, bool) {
+	if p.tree != nil {
+		e, _ := p.tree.Seek(id + 1)
+		defer e.Close()
+		_, pr, err := e.Next()
+		return pr, err == nil
+	}
+	if i := p.search(id + 1); i < len(p.list) {
+		return p.list[i], true
+	}
+	return nil, false
+}
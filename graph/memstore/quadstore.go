@@ -15,9 +15,11 @@
 package memstore
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -54,36 +56,48 @@ func cmp(a, b int64) int {
 }
 
 type QuadDirectionIndex struct {
-	index [4]map[int64]*Tree
+	index [4]map[int64]*postings
 }
 
 func NewQuadDirectionIndex() QuadDirectionIndex {
-	return QuadDirectionIndex{[...]map[int64]*Tree{
-		quad.Subject - 1:   make(map[int64]*Tree),
-		quad.Predicate - 1: make(map[int64]*Tree),
-		quad.Object - 1:    make(map[int64]*Tree),
-		quad.Label - 1:     make(map[int64]*Tree),
+	return QuadDirectionIndex{[...]map[int64]*postings{
+		quad.Subject - 1:   make(map[int64]*postings),
+		quad.Predicate - 1: make(map[int64]*postings),
+		quad.Object - 1:    make(map[int64]*postings),
+		quad.Label - 1:     make(map[int64]*postings),
 	}}
 }
 
-func (qdi QuadDirectionIndex) Tree(d quad.Direction, id int64) *Tree {
+func (qdi QuadDirectionIndex) Postings(d quad.Direction, id int64) *postings {
 	if d < quad.Subject || d > quad.Label {
 		panic("illegal direction")
 	}
-	tree, ok := qdi.index[d-1][id]
+	list, ok := qdi.index[d-1][id]
 	if !ok {
-		tree = TreeNew(cmp)
-		qdi.index[d-1][id] = tree
+		list = &postings{}
+		qdi.index[d-1][id] = list
 	}
-	return tree
+	return list
 }
 
-func (qdi QuadDirectionIndex) Get(d quad.Direction, id int64) (*Tree, bool) {
+func (qdi QuadDirectionIndex) Get(d quad.Direction, id int64) (*postings, bool) {
 	if d < quad.Subject || d > quad.Label {
 		panic("illegal direction")
 	}
-	tree, ok := qdi.index[d-1][id]
-	return tree, ok
+	list, ok := qdi.index[d-1][id]
+	return list, ok
+}
+
+// Remove deletes a quad from the postings of a node, and drops the postings once they are empty.
+func (qdi QuadDirectionIndex) Remove(d quad.Direction, id, qid int64) {
+	list, ok := qdi.Get(d, id)
+	if !ok {
+		return
+	}
+	list.Delete(qid)
+	if list.Len() == 0 {
+		delete(qdi.index[d-1], id)
+	}
 }
 
 type primitive struct {
@@ -132,14 +146,15 @@ func (q internalQuad) Dir(d quad.Direction) int64 {
 
 type QuadStore struct {
 	last int64
-	// TODO: string -> quad.Value once Raw -> typed resolution is unnecessary
-	vals    map[string]int64
+	// vals is a dictionary of interned values, keyed by valueKey
+	vals    map[interface{}]int64
 	quads   map[internalQuad]int64
 	prim    map[int64]*primitive
 	all     []*primitive // might not be sorted by id
 	reading bool         // someone else might be reading "all" slice - next insert/delete should clone it
 	index   QuadDirectionIndex
-	horizon int64 // used only to assign ids to tx
+	horizon int64        // used only to assign ids to tx
+	stats   atomic.Value // *graph.Statistics, kept in memory only
 	// vip_index map[string]map[int64]map[string]map[int64]*b.Tree
 }
 
@@ -154,7 +169,7 @@ func New(quads ...quad.Quad) *QuadStore {
 
 func newQuadStore() *QuadStore {
 	return &QuadStore{
-		vals:  make(map[string]int64),
+		vals:  make(map[interface{}]int64),
 		quads: make(map[internalQuad]int64),
 		prim:  make(map[int64]*primitive),
 		index: NewQuadDirectionIndex(),
@@ -188,6 +203,23 @@ func (qs *QuadStore) appendPrimitive(p *primitive) {
 
 const internalBNodePrefix = "memnode"
 
+// rawKey is a dictionary key of values of unknown types.
+type rawKey string
+
+// valueKey returns a key of the value in the dictionary. Values with the same string
+// representation have the same key, but common types are keyed by the value itself,
+// thus the key shares the memory with the value and no string is built on lookups.
+func valueKey(v quad.Value) interface{} {
+	switch v := v.(type) {
+	case quad.IRI, quad.BNode, quad.String, quad.TypedString, quad.LangString:
+		return v
+	case quad.TypedStringer:
+		// keep native values and the corresponding typed strings the same
+		return v.TypedString()
+	}
+	return rawKey(v.String())
+}
+
 func (qs *QuadStore) resolveVal(v quad.Value, add bool) (int64, bool) {
 	if v == nil {
 		return 0, false
@@ -206,7 +238,7 @@ func (qs *QuadStore) resolveVal(v quad.Value, add bool) (int64, bool) {
 			return id, true
 		}
 	}
-	vs := v.String()
+	vs := valueKey(v)
 	if id, exists := qs.vals[vs]; exists || !add {
 		if exists && add {
 			qs.prim[id].refs++
@@ -267,18 +299,6 @@ func (qs *QuadStore) AddValue(v quad.Value) (int64, bool) {
 	return id, !exists
 }
 
-func (qs *QuadStore) indexesForQuad(q internalQuad) []*Tree {
-	trees := make([]*Tree, 0, 4)
-	for dir := quad.Subject; dir <= quad.Label; dir++ {
-		v := q.Dir(dir)
-		if v == 0 {
-			continue
-		}
-		trees = append(trees, qs.index.Tree(dir, v))
-	}
-	return trees
-}
-
 // AddQuad adds a quad to quad store. It returns an id of the quad.
 // False is returned as a second parameter if quad exists already.
 func (qs *QuadStore) AddQuad(q quad.Quad) (int64, bool) {
@@ -289,8 +309,10 @@ func (qs *QuadStore) AddQuad(q quad.Quad) (int64, bool) {
 	pr := &primitive{Quad: p}
 	id := qs.addPrimitive(pr)
 	qs.quads[p] = id
-	for _, t := range qs.indexesForQuad(p) {
-		t.Set(id, pr)
+	for dir := quad.Subject; dir <= quad.Label; dir++ {
+		if v := p.Dir(dir); v != 0 {
+			qs.index.Postings(dir, v).Set(id, pr)
+		}
 	}
 	// TODO(barakmich): Add VIP indexing
 	return id, true
@@ -327,11 +349,13 @@ func (qs *QuadStore) Delete(id int64) bool {
 	}
 	// remove from value index
 	if p.Value != nil {
-		delete(qs.vals, p.Value.String())
+		delete(qs.vals, valueKey(p.Value))
 	}
 	// remove from quad indexes
-	for _, t := range qs.indexesForQuad(p.Quad) {
-		t.Delete(id)
+	for dir := quad.Subject; dir <= quad.Label; dir++ {
+		if v := p.Quad.Dir(dir); v != 0 {
+			qs.index.Remove(dir, v, id)
+		}
 	}
 	delete(qs.quads, p.Quad)
 	// remove primitive
@@ -368,6 +392,31 @@ func (qs *QuadStore) findQuad(q quad.Quad) (int64, internalQuad, bool) {
 }
 
 func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, ignoreOpts)
+}
+
+var _ graph.StatisticsStore = (*QuadStore)(nil)
+
+// Statistics implements graph.StatisticsStore.
+func (qs *QuadStore) Statistics() *graph.Statistics {
+	st, _ := qs.stats.Load().(*graph.Statistics)
+	return st
+}
+
+// SetStatistics implements graph.StatisticsStore. Statistics are lost when the quad store is closed.
+func (qs *QuadStore) SetStatistics(ctx context.Context, st *graph.Statistics) error {
+	qs.stats.Store(st)
+	return nil
+}
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, ignoreOpts graph.IgnoreOpts) error {
+	for _, c := range conds {
+		if _, _, ok := qs.findQuad(c.Quad); ok != c.Exists {
+			return &graph.PreconditionError{Precondition: c}
+		}
+	}
 	// Precheck the whole transaction (if required)
 	if !ignoreOpts.IgnoreDup || !ignoreOpts.IgnoreMissing {
 		for _, d := range deltas {
@@ -462,7 +511,7 @@ func (qs *QuadStore) ValueOf(name quad.Value) graph.Value {
 	if name == nil {
 		return nil
 	}
-	id := qs.vals[name.String()]
+	id := qs.vals[valueKey(name)]
 	if id == 0 {
 		return nil
 	}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"reflect"
 	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/cayleygraph/cayley/graph"
@@ -245,3 +246,44 @@ func TestTransaction(t *testing.T) {
 		t.Error("Appended a new quad in a failed transaction")
 	}
 }
+
+func TestPostings(t *testing.T) {
+	ctx := context.TODO()
+	qs := New()
+	var quads []quad.Quad
+	for i := 0; i < 3*maxPostings; i++ {
+		q := quad.MakeIRI("a", "follows", "n"+strconv.Itoa(i), "")
+		quads = append(quads, q)
+		qs.AddQuad(q)
+	}
+	next := func() []quad.Quad {
+		it := qs.QuadIterator(quad.Subject, qs.ValueOf(quad.IRI("a")))
+		defer it.Close()
+		var out []quad.Quad
+		for it.Next(ctx) {
+			out = append(out, qs.Quad(it.Result()))
+		}
+		require.NoError(t, it.Err())
+		return out
+	}
+	require.Equal(t, quads, next())
+
+	// quads are deleted while the tree is iterated
+	it := qs.QuadIterator(quad.Subject, qs.ValueOf(quad.IRI("a")))
+	n := 0
+	for it.Next(ctx) {
+		if n++; n%2 == 0 {
+			qs.Delete(it.Result().(qprim).p.ID)
+		}
+	}
+	require.NoError(t, it.Close())
+	require.Equal(t, 3*maxPostings, n)
+	for i := 1; i < len(quads); i++ {
+		quads = append(quads[:i], quads[i+1:]...)
+	}
+	require.Equal(t, quads, next())
+
+	// native values share the node with the same typed string
+	id, _ := qs.AddValue(quad.Int(5))
+	require.Equal(t, bnode(id), qs.ValueOf(quad.Int(5).TypedString()))
+}
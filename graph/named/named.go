@@ -0,0 +1,184 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package named isolates named graphs: quads with the same label form a graph that can be queried on its own.
+//
+// A quad store restricted to a named graph only returns quads with its label, and only lists nodes of these quads,
+// so traversals never reach quads of other graphs. Quads written through it are added to the graph.
+// Selecting quads of another graph by their label is an error, unless the graphs are joined with Join.
+package named
+
+import (
+	"errors"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// All is a name that selects all graphs at once.
+const All = "*"
+
+var (
+	// ErrOtherGraph is returned when a quad with a different label is written to a named graph.
+	ErrOtherGraph = errors.New("named: quad belongs to a different graph")
+	// ErrCrossGraph is returned by traversals that select quads of another graph by their label.
+	// Graphs must be joined explicitly to be traversed together.
+	ErrCrossGraph = errors.New("named: traversal crosses into a graph that is not selected; join the graphs explicitly")
+)
+
+// Select returns a quad store restricted to a graph with a given name. The name is parsed with quad.StringToValue.
+// If the name is empty, the default graph is selected, and if it's nil as well, or if the name is All,
+// the quad store is returned as is.
+func Select(qs graph.QuadStore, name string, def quad.Value) graph.QuadStore {
+	switch name {
+	case All:
+		return qs
+	case "":
+		if def == nil {
+			return qs
+		}
+		return New(qs, def)
+	}
+	return New(qs, quad.StringToValue(name))
+}
+
+// QuadStore is a view of a single named graph, or of a union of joined graphs, of the underlying quad store.
+type QuadStore struct {
+	graph.QuadStore
+	labels []quad.Value
+}
+
+// New restricts a quad store to a graph with a given label.
+func New(qs graph.QuadStore, label quad.Value) *QuadStore {
+	return Join(qs, label)
+}
+
+// Join restricts a quad store to a union of graphs with given labels. Traversals can cross between
+// these graphs, but not into other ones. Quads without a label are written to the first graph.
+// At least one label must be given.
+func Join(qs graph.QuadStore, labels ...quad.Value) *QuadStore {
+	return &QuadStore{QuadStore: qs, labels: labels}
+}
+
+// Base returns the quad store a graph was selected from, or the quad store itself if it is not a named graph.
+// Queries use it to join other graphs on demand.
+func Base(qs graph.QuadStore) graph.QuadStore {
+	if nqs, ok := qs.(*QuadStore); ok {
+		return nqs.QuadStore
+	}
+	return qs
+}
+
+// Label returns the label of the graph, or of the first of joined graphs.
+func (qs *QuadStore) Label() quad.Value {
+	return qs.labels[0]
+}
+
+// Labels returns labels of all joined graphs.
+func (qs *QuadStore) Labels() []quad.Value {
+	return append([]quad.Value(nil), qs.labels...)
+}
+
+// labelRefs resolves labels on each call, since graphs may not exist until the first write.
+func (qs *QuadStore) labelRefs() []graph.Value {
+	var out []graph.Value
+	for _, l := range qs.labels {
+		if ref := qs.QuadStore.ValueOf(l); ref != nil {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+func (qs *QuadStore) quads() graph.Iterator {
+	refs := qs.labelRefs()
+	switch len(refs) {
+	case 0:
+		return iterator.NewNull()
+	case 1:
+		return qs.QuadStore.QuadIterator(quad.Label, refs[0])
+	}
+	its := make([]graph.Iterator, 0, len(refs))
+	for _, ref := range refs {
+		its = append(its, qs.QuadStore.QuadIterator(quad.Label, ref))
+	}
+	return iterator.NewOr(its...)
+}
+
+// isSelected checks if a label is one of the joined graphs.
+func (qs *QuadStore) isSelected(label quad.Value) bool {
+	for _, l := range qs.labels {
+		if label != nil && l.String() == label.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// QuadIterator returns quads of joined graphs. Selecting quads of any other graph by label fails with ErrCrossGraph.
+func (qs *QuadStore) QuadIterator(d quad.Direction, v graph.Value) graph.Iterator {
+	if d == quad.Label {
+		if !qs.isSelected(qs.QuadStore.NameOf(v)) {
+			return iterator.NewError(ErrCrossGraph)
+		}
+		return qs.QuadStore.QuadIterator(d, v)
+	}
+	return iterator.NewAnd(qs, qs.QuadStore.QuadIterator(d, v), qs.quads())
+}
+
+func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
+	return qs.quads()
+}
+
+// NodesAllIterator returns all nodes of quads in joined graphs, including the labels themselves.
+func (qs *QuadStore) NodesAllIterator() graph.Iterator {
+	if len(qs.labelRefs()) == 0 {
+		return iterator.NewNull()
+	}
+	its := make([]graph.Iterator, 0, len(quad.Directions))
+	for _, d := range quad.Directions {
+		its = append(its, iterator.NewHasA(qs, qs.quads(), d))
+	}
+	return iterator.NewUnique(iterator.NewOr(its...))
+}
+
+// Size returns the number of quads in joined graphs, which might be an estimate.
+func (qs *QuadStore) Size() int64 {
+	it := qs.quads()
+	defer it.Close()
+	sz, _ := it.Size()
+	return sz
+}
+
+// OptimizeIterator doesn't let the underlying quad store optimize iterators,
+// since it would replace quad iterators of the graph with its own.
+func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
+	return it, false
+}
+
+// ApplyDeltas adds quads without a label to the graph, or to the first of joined graphs.
+// Quads with a label of any other graph are rejected with ErrOtherGraph.
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	out := make([]graph.Delta, len(deltas))
+	for i, d := range deltas {
+		if d.Quad.Label == nil {
+			d.Quad.Label = qs.labels[0]
+		} else if !qs.isSelected(d.Quad.Label) {
+			return ErrOtherGraph
+		}
+		out[i] = d
+	}
+	return qs.QuadStore.ApplyDeltas(out, opts)
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package named
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func names(t testing.TB, qs graph.QuadStore, p *path.Path) []string {
+	vals, err := p.Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	var out []string
+	for _, v := range vals {
+		out = append(out, v.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestNamedGraph(t *testing.T) {
+	base := memstore.New(
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g2"),
+		quad.MakeIRI("a", "knows", "d", "g2"),
+		quad.MakeIRI("a", "knows", "e", ""),
+	)
+	g1 := New(base, quad.IRI("g1"))
+	require.Equal(t, int64(1), g1.Size())
+	// the traversal stops at the border of the graph
+	require.Equal(t, []string{"<b>"}, names(t, g1, path.StartPath(g1, quad.IRI("a")).Out(quad.IRI("knows"))))
+	require.Empty(t, names(t, g1, path.StartPath(g1, quad.IRI("a")).Out(quad.IRI("knows")).Out(quad.IRI("knows"))))
+	require.Equal(t, []string{"<a>", "<b>", "<g1>", "<knows>"}, names(t, g1, path.StartPath(g1)))
+
+	g2 := Select(base, "<g2>", nil)
+	require.Equal(t, []string{"<c>", "<d>"}, names(t, g2, path.StartPath(g2).Out(quad.IRI("knows"))))
+	require.Equal(t, []string{"<g2>"}, names(t, g2, path.StartPath(g2).Labels()))
+
+	require.Equal(t, graph.QuadStore(base), Select(base, All, quad.IRI("g1")))
+	require.Equal(t, graph.QuadStore(base), Select(base, "", nil))
+	require.Equal(t, quad.IRI("g1"), Select(base, "", quad.IRI("g1")).(*QuadStore).Label())
+
+	// quads are written to the graph, and the graph is created on the first write
+	g3 := New(base, quad.IRI("g3"))
+	require.Empty(t, names(t, g3, path.StartPath(g3)))
+	w, err := graph.NewQuadWriter("single", g3, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuad(quad.MakeIRI("x", "knows", "y", "")))
+	require.Equal(t, ErrOtherGraph, w.AddQuad(quad.MakeIRI("x", "knows", "z", "g1")))
+	require.Equal(t, []string{"<y>"}, names(t, g3, path.StartPath(g3, quad.IRI("x")).Out(quad.IRI("knows"))))
+	require.Equal(t, []string{"<b>"}, names(t, g1, path.StartPath(g1, quad.IRI("a")).Out(quad.IRI("knows"))))
+}
+
+func TestJoin(t *testing.T) {
+	base := memstore.New(
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g2"),
+		quad.MakeIRI("c", "knows", "d", "g3"),
+	)
+	knows := quad.IRI("knows")
+	g1 := New(base, quad.IRI("g1"))
+	// traversals of other graphs by label are rejected
+	_, err := path.StartPath(g1, quad.IRI("b")).LabelContext(quad.IRI("g2")).Out(knows).Iterate(context.TODO()).AllValues(g1)
+	require.Equal(t, ErrCrossGraph, err)
+
+	j := Join(Base(g1), quad.IRI("g1"), quad.IRI("g2"))
+	require.Equal(t, int64(2), j.Size())
+	require.Equal(t, []string{"<c>"}, names(t, j, path.StartPath(j, quad.IRI("a")).Out(knows).Out(knows)))
+	require.Equal(t, []string{"<c>"}, names(t, j, path.StartPath(j, quad.IRI("b")).LabelContext(quad.IRI("g2")).Out(knows)))
+	require.Empty(t, names(t, j, path.StartPath(j, quad.IRI("c")).Out(knows)))
+	require.Equal(t, graph.QuadStore(base), Base(base))
+}
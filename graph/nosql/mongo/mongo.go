@@ -11,6 +11,7 @@ import (
 	"github.com/globalsign/mgo/bson"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/nosql"
 )
 
@@ -25,6 +26,7 @@ func init() {
 		NewFunc:      Open,
 		InitFunc:     Create,
 		IsPersistent: true,
+		Options:      nosql.Options{Geo: true},
 	})
 }
 
@@ -115,8 +117,12 @@ func (db *DB) EnsureIndex(ctx context.Context, col string, primary nosql.Index,
 		}
 	}
 	for _, ind := range secondary {
+		key := []string(ind.Fields)
+		if ind.Type == nosql.GeoSphere {
+			key = []string{"$2dsphere:" + ind.Fields[0]}
+		}
 		err := c.EnsureIndex(mgo.Index{
-			Key:        []string(ind.Fields),
+			Key:        key,
 			Unique:     false,
 			Background: true,
 			Sparse:     true,
@@ -141,6 +147,8 @@ func toBsonValue(v nosql.Value) interface{} {
 		return toBsonDoc(v)
 	case nosql.Strings:
 		return []string(v)
+	case nosql.Floats:
+		return []float64(v)
 	case nosql.String:
 		return string(v)
 	case nosql.Int:
@@ -164,6 +172,19 @@ func fromBsonValue(v interface{}) nosql.Value {
 	case bson.M:
 		return fromBsonDoc(v)
 	case []interface{}:
+		if len(v) != 0 {
+			if _, ok := v[0].(float64); ok {
+				arr := make(nosql.Floats, 0, len(v))
+				for _, f := range v {
+					fv, ok := f.(float64)
+					if !ok {
+						panic(fmt.Errorf("unsupported value in array: %T", f))
+					}
+					arr = append(arr, fv)
+				}
+				return arr
+			}
+		}
 		arr := make(nosql.Strings, 0, len(v))
 		for _, s := range v {
 			sv := fromBsonValue(s)
@@ -334,6 +355,18 @@ func buildFilters(filters []nosql.FieldFilter) bson.M {
 	m := make(bson.M, len(filters))
 	for _, f := range filters {
 		name := strings.Join(f.Path, ".")
+		if f.Filter == nosql.GeoWithin {
+			r, ok := f.Value.(nosql.GeoRegion)
+			if !ok {
+				panic(fmt.Errorf("unsupported geo region: %v", f.Value))
+			}
+			if r.Box {
+				geoBox(m, name, r)
+				continue
+			}
+			m[name] = bson.M{"$geoWithin": geoWithin(r)}
+			continue
+		}
 		v := toBsonValue(f.Value)
 		if f.Filter == nosql.Equal {
 			m[name] = v
@@ -375,6 +408,43 @@ func buildFilters(filters []nosql.FieldFilter) bson.M {
 	return m
 }
 
+// geoWithin returns an argument of the $geoWithin operator.
+func geoWithin(r nosql.GeoRegion) bson.M {
+	if len(r.Points) == 1 {
+		c := r.Points[0]
+		// radius of $centerSphere is in radians
+		return bson.M{"$centerSphere": []interface{}{
+			[]float64{c.Lng, c.Lat}, r.Radius / geo.EarthRadius,
+		}}
+	}
+	ring := make([][]float64, 0, len(r.Points)+1)
+	for _, p := range r.Points {
+		ring = append(ring, []float64{p.Lng, p.Lat})
+	}
+	if n := len(r.Points); n != 0 && r.Points[0] != r.Points[n-1] {
+		// GeoJSON rings must be closed
+		ring = append(ring, ring[0])
+	}
+	return bson.M{"$geometry": bson.M{
+		"type":        "Polygon",
+		"coordinates": [][][]float64{ring},
+	}}
+}
+
+// geoBox adds filters on coordinates of GeoJSON points inside a box. Edges of GeoJSON polygons are geodesic lines,
+// thus $geoWithin cannot be used for a latitude-longitude rectangle.
+func geoBox(m bson.M, name string, r nosql.GeoRegion) {
+	sw, ne := r.Points[0], r.Points[1]
+	// GeoJSON lists the longitude first
+	m[name+".coordinates.1"] = bson.M{"$gte": sw.Lat, "$lte": ne.Lat}
+	if sw.Lng <= ne.Lng {
+		m[name+".coordinates.0"] = bson.M{"$gte": sw.Lng, "$lte": ne.Lng}
+	} else {
+		// the box crosses the antimeridian
+		m[name+".coordinates.0"] = bson.M{"$not": bson.M{"$lt": sw.Lng, "$gt": ne.Lng}}
+	}
+}
+
 func mergeFilters(dst, src bson.M) {
 	for k, v := range src {
 		dst[k] = v
@@ -522,6 +592,15 @@ func (u *Update) Push(field string, v nosql.Value) nosql.Update {
 	u.update["$push"] = push
 	return u
 }
+func (u *Update) Set(field string, v nosql.Value) nosql.Update {
+	set, _ := u.update["$set"].(bson.M)
+	if set == nil {
+		set = make(bson.M)
+	}
+	set[field] = toBsonValue(v)
+	u.update["$set"] = set
+	return u
+}
 func (u *Update) Upsert(d nosql.Document) nosql.Update {
 	u.upsert = toBsonDoc(d)
 	if u.upsert == nil {
@@ -541,6 +620,9 @@ func (u *Update) Do(ctx context.Context) error {
 	} else {
 		err = u.col.c.UpdateId(key, u.update)
 	}
+	if err == mgo.ErrNotFound {
+		err = nosql.ErrNotFound
+	}
 	return err
 }
 
@@ -76,6 +76,10 @@ func (op FilterOp) String() string {
 		name = "LT"
 	case LTE:
 		name = "LTE"
+	case Regexp:
+		name = "Regexp"
+	case GeoWithin:
+		name = "GeoWithin"
 	default:
 		return fmt.Sprintf("FilterOp(%d)", int(op))
 	}
@@ -93,6 +97,9 @@ const (
 	LT
 	LTE
 	Regexp
+	// GeoWithin matches GeoJSON points that are inside a GeoRegion.
+	// It's only used if the database supports geospatial queries (see Options.Geo).
+	GeoWithin
 )
 
 // FieldFilter represents a single field comparison operation.
@@ -159,6 +166,13 @@ func (f FieldFilter) Matches(d Document) bool {
 		}
 		ok, _ = regexp.MatchString(string(pattern), string(s))
 		return ok
+	case GeoWithin:
+		r, ok := f.Value.(GeoRegion)
+		if !ok {
+			return false
+		}
+		p, ok := geoPointOf(val)
+		return ok && r.Region().Contains(p)
 	}
 	panic(fmt.Errorf("unsupported operation: %v", f.Filter))
 }
@@ -189,6 +203,13 @@ type Update interface {
 	Do(ctx context.Context) error
 }
 
+// FieldSetter is an optional interface for updates that can set fields of existing documents.
+type FieldSetter interface {
+	// Set sets a field of the document to a given value. The field may be a path separated by dots.
+	// Do returns ErrNotFound if the document doesn't exist.
+	Set(field string, v Value) Update
+}
+
 // Update is a batch delete request builder.
 type Delete interface {
 	// WithFields adds specified filters to select document for deletion.
@@ -275,6 +296,7 @@ type IndexType int
 const (
 	IndexAny    = IndexType(iota)
 	StringExact // exact match for string values (usually a hash index)
+	GeoSphere   // geospatial index of GeoJSON points on a sphere
 
 	//StringFulltext
 	//IntIndex
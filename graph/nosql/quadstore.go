@@ -22,6 +22,7 @@ import (
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/internal/lru"
 	"github.com/cayleygraph/cayley/quad"
@@ -39,6 +40,9 @@ type Registration struct {
 
 type Options struct {
 	Number32 bool // store is limited to 32 bit precision
+	// Geo is set if the store supports geospatial queries on GeoJSON points. Points of existing nodes are
+	// added to the index when the quad store is opened for the first time, which requires FieldSetter support.
+	Geo bool
 }
 
 type InitFunc func(string, graph.Options) (Database, error)
@@ -83,13 +87,10 @@ func Register(name string, r Registration) {
 }
 
 func Init(db Database, opt graph.Options) error {
-	return ensureIndexes(context.TODO(), db)
+	return ensureIndexes(context.TODO(), db, Options{})
 }
 
 func NewQuadStore(db Database, nopt *Options, opt graph.Options) (*QuadStore, error) {
-	if err := ensureIndexes(context.TODO(), db); err != nil {
-		return nil, err
-	}
 	qs := &QuadStore{
 		db:    db,
 		ids:   lru.New(1 << 16),
@@ -98,9 +99,75 @@ func NewQuadStore(db Database, nopt *Options, opt graph.Options) (*QuadStore, er
 	if nopt != nil {
 		qs.opt = *nopt
 	}
+	if err := ensureIndexes(context.TODO(), db, qs.opt); err != nil {
+		return nil, err
+	}
+	if qs.opt.Geo {
+		if err := qs.buildGeoIndex(context.TODO()); err != nil {
+			return nil, err
+		}
+	}
 	return qs, nil
 }
 
+// buildGeoIndex adds GeoJSON points to existing nodes, unless it was done already. Nodes created after
+// the index is enabled have them from the start.
+//
+// If the database cannot update existing documents, geospatial queries are not pushed to the database.
+func (qs *QuadStore) buildGeoIndex(ctx context.Context) error {
+	if _, err := qs.db.FindByKey(ctx, colMeta, metaGeoIndex); err == nil {
+		return nil
+	} else if err != ErrNotFound {
+		return err
+	}
+	if _, ok := qs.db.Update(colNodes, nil).(FieldSetter); !ok {
+		clog.Warningf("nosql: database cannot update documents; geospatial queries will not use the index")
+		qs.opt.Geo = false
+		return nil
+	}
+	clog.Infof("nosql: adding points of existing nodes to the geospatial index")
+	it := qs.db.Query(colNodes).Iterate()
+	defer it.Close()
+	n := 0
+	for it.Next(ctx) {
+		dv, _ := it.Doc()[fldValue].(Document)
+		if _, ok := dv[fldValGeo]; ok || len(dv) == 0 {
+			continue
+		}
+		v, err := qs.opt.toQuadValue(dv)
+		if err != nil {
+			return err
+		}
+		d := qs.opt.toDocumentValue(v)[fldValue].(Document)
+		pt, ok := d[fldValGeo]
+		if !ok {
+			continue
+		}
+		err = qs.db.Update(colNodes, it.Key()).(FieldSetter).Set(fldValue+"."+fldValGeo, pt).Do(ctx)
+		if err == ErrNotFound {
+			// node was removed in the meantime
+			continue
+		} else if err != nil {
+			return fmt.Errorf("cannot index node: %v", err)
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	clog.Infof("nosql: added %d points to the geospatial index", n)
+	_, err := qs.db.Insert(ctx, colMeta, metaGeoIndex, Document{
+		fldMetaBuilt: Time(time.Now().UTC()),
+	})
+	if err != nil {
+		// another server might have built the index at the same time
+		if _, ferr := qs.db.FindByKey(ctx, colMeta, metaGeoIndex); ferr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
 type NodeHash string
 
 func (NodeHash) IsNode() bool       { return false }
@@ -131,6 +198,7 @@ const (
 	colLog   = "log"
 	colNodes = "nodes"
 	colQuads = "quads"
+	colMeta  = "meta"
 
 	fldLogID = "id"
 
@@ -156,8 +224,15 @@ const (
 	fldValBool   = "bool"
 	fldValTime   = "ts"
 	fldValPb     = "pb"
+	fldValGeo    = "geo"
+
+	fldMetaKey   = "key"
+	fldMetaBuilt = "built"
 )
 
+// metaGeoIndex is the key of the metadata document that marks the geospatial index of nodes as complete.
+var metaGeoIndex = Key{"geo_index"}
+
 type QuadStore struct {
 	db    Database
 	ids   *lru.Cache
@@ -165,7 +240,7 @@ type QuadStore struct {
 	opt   Options
 }
 
-func ensureIndexes(ctx context.Context, db Database) error {
+func ensureIndexes(ctx context.Context, db Database, opt Options) error {
 	err := db.EnsureIndex(ctx, colLog, Index{
 		Fields: []string{fldLogID},
 		Type:   StringExact,
@@ -173,10 +248,21 @@ func ensureIndexes(ctx context.Context, db Database) error {
 	if err != nil {
 		return err
 	}
+	var nodeIndexes []Index
+	if opt.Geo {
+		nodeIndexes = append(nodeIndexes, Index{Fields: []string{fldValue + "." + fldValGeo}, Type: GeoSphere})
+		err = db.EnsureIndex(ctx, colMeta, Index{
+			Fields: []string{fldMetaKey},
+			Type:   StringExact,
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
 	err = db.EnsureIndex(ctx, colNodes, Index{
 		Fields: []string{fldHash},
 		Type:   StringExact,
-	}, nil)
+	}, nodeIndexes)
 	if err != nil {
 		return err
 	}
@@ -447,9 +533,31 @@ func (opt Options) toDocumentValue(v quad.Value) Document {
 	default:
 		encPb()
 	}
+	if opt.Geo {
+		if p, ok := geo.ParsePoint(v); ok {
+			// GeoJSON lists the longitude first
+			doc[fldValGeo] = Document{
+				"type":        String("Point"),
+				"coordinates": Floats{p.Lng, p.Lat},
+			}
+		}
+	}
 	return Document{fldValue: doc}
 }
 
+// geoPointOf returns a point from a GeoJSON document.
+func geoPointOf(v Value) (geo.Point, bool) {
+	d, ok := v.(Document)
+	if !ok || d["type"] != String("Point") {
+		return geo.Point{}, false
+	}
+	c, ok := d["coordinates"].(Floats)
+	if !ok || len(c) != 2 {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: c[1], Lng: c[0]}, true
+}
+
 func asInt(v Value) (Int, error) {
 	var vi Int
 	switch v := v.(type) {
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
@@ -184,6 +185,32 @@ func (qs *QuadStore) optimizeFilter(s shape.Filter) (shape.Shape, bool) {
 				}...)
 			}
 			continue
+		case shape.Near:
+			if qs.opt.Geo && f.Radius >= 0 {
+				filters = append(filters, FieldFilter{
+					Path: fieldPath(fldValGeo), Filter: GeoWithin,
+					Value: GeoRegion{Points: []geo.Point{f.Region().Center}, Radius: f.Radius},
+				})
+				continue
+			}
+		case shape.Within:
+			if qs.opt.Geo && len(f.Polygon) >= 3 {
+				filters = append(filters, FieldFilter{
+					Path: fieldPath(fldValGeo), Filter: GeoWithin,
+					Value: GeoRegion{Points: f.Polygon},
+				})
+				continue
+			}
+		case shape.WithinBox:
+			if b := f.Box; qs.opt.Geo && b.Valid() {
+				filters = append(filters, FieldFilter{
+					Path: fieldPath(fldValGeo), Filter: GeoWithin,
+					Value: GeoRegion{Box: true, Points: []geo.Point{
+						{Lat: b.MinLat, Lng: b.MinLng}, {Lat: b.MaxLat, Lng: b.MaxLng},
+					}},
+				})
+				continue
+			}
 		}
 		left = append(left, f)
 	}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"strings"
 	"time"
+
+	"github.com/cayleygraph/cayley/graph/geo"
 )
 
 // Value is a interface that limits a set of types that nosql database can handle.
@@ -63,6 +65,35 @@ type Strings []string
 
 func (Strings) isValue() {}
 
+// Floats is an array of floating point values. Used to store coordinates of points.
+type Floats []float64
+
+func (Floats) isValue() {}
+
+// GeoRegion is a value of GeoWithin filters. A single point is a center of a circle with a given radius,
+// otherwise points are vertices of a polygon. Coordinates are in degrees, and the radius is in meters.
+//
+// If Box is set, two points are the south-west and the north-east corners of a latitude-longitude rectangle.
+type GeoRegion struct {
+	Points []geo.Point
+	Radius float64
+	Box    bool
+}
+
+func (GeoRegion) isValue() {}
+
+// Region returns a region that matching points must be inside of.
+func (r GeoRegion) Region() geo.Region {
+	if r.Box && len(r.Points) == 2 {
+		sw, ne := r.Points[0], r.Points[1]
+		return geo.Rect{MinLat: sw.Lat, MinLng: sw.Lng, MaxLat: ne.Lat, MaxLng: ne.Lng}
+	}
+	if len(r.Points) == 1 {
+		return geo.Circle{Center: r.Points[0], Radius: r.Radius}
+	}
+	return geo.Polygon(r.Points)
+}
+
 // ValuesEqual returns true if values are strictly equal.
 func ValuesEqual(v1, v2 Value) bool {
 	switch v1 := v2.(type) {
@@ -88,6 +119,19 @@ func ValuesEqual(v1, v2 Value) bool {
 			}
 		}
 		return true
+	case Floats:
+		v2, ok := v2.(Floats)
+		if !ok || len(v1) != len(v2) {
+			return false
+		}
+		for i := range v1 {
+			if v1[i] != v2[i] {
+				return false
+			}
+		}
+		return true
+	case GeoRegion:
+		return false
 	case Bytes:
 		v2, ok := v2.(Bytes)
 		if !ok || len(v1) != len(v2) {
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/geo"
 )
 
 var filterMatch = []struct {
@@ -33,6 +35,36 @@ var filterMatch = []struct {
 		d:   Document{"value1": Document{"str": String("bob")}},
 		exp: true,
 	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoRegion{Points: []geo.Point{{Lat: 37.7749, Lng: -122.4194}}, Radius: 20000}},
+		d:   Options{Geo: true}.toDocumentValue(geo.Point{Lat: 37.8044, Lng: -122.2712}.Value()),
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoRegion{Points: []geo.Point{{Lat: 37.7749, Lng: -122.4194}}, Radius: 1000}},
+		d:   Options{Geo: true}.toDocumentValue(geo.Point{Lat: 37.8044, Lng: -122.2712}.Value()),
+		exp: false,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoRegion{Points: []geo.Point{{Lat: 0, Lng: 0}, {Lat: 0, Lng: 10}, {Lat: 10, Lng: 10}}}},
+		d:   Options{Geo: true}.toDocumentValue(geo.Point{Lat: 1, Lng: 5}.Value()),
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoRegion{Points: []geo.Point{{Lat: 0, Lng: 0}, {Lat: 0, Lng: 10}, {Lat: 10, Lng: 10}}}},
+		d:   Options{}.toDocumentValue(geo.Point{Lat: 1, Lng: 5}.Value()),
+		exp: false,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoRegion{Box: true, Points: []geo.Point{{Lat: -10, Lng: 170}, {Lat: 10, Lng: -170}}}},
+		d:   Options{Geo: true}.toDocumentValue(geo.Point{Lat: 5, Lng: 175}.Value()),
+		exp: true,
+	},
+	{
+		f:   FieldFilter{Path: []string{"value", "geo"}, Filter: GeoWithin, Value: GeoRegion{Box: true, Points: []geo.Point{{Lat: -10, Lng: 170}, {Lat: 10, Lng: -170}}}},
+		d:   Options{Geo: true}.toDocumentValue(geo.Point{Lat: 5, Lng: 0}.Value()),
+		exp: false,
+	},
 }
 
 func TestFilterMatch(t *testing.T) {
@@ -16,10 +16,12 @@ package path
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/graph/temporal"
 	"github.com/cayleygraph/cayley/quad"
 )
 
@@ -152,6 +154,19 @@ func inMorphism(tags []string, via ...interface{}) morphism {
 	}
 }
 
+// propertiesMorphism follows predicates that match a wildcard pattern.
+func propertiesMorphism(pattern string, in bool, tags []string) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
+			return propertiesMorphism(pattern, !in, tags), ctx
+		},
+		Apply: func(from shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return shape.Properties(from, pattern, ctx.labelSet, in, tags...), ctx
+		},
+		tags: tags,
+	}
+}
+
 func bothMorphism(tags []string, via ...interface{}) morphism {
 	return morphism{
 		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return bothMorphism(tags, via...), ctx },
@@ -188,6 +203,22 @@ func labelContextMorphism(tags []string, via ...interface{}) morphism {
 	}
 }
 
+// validTimeMorphism restricts the label context to quads that are valid at a given time.
+func validTimeMorphism(t time.Time) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
+			out := ctx.copy()
+			ctx.labelSet = temporal.ValidAt(ctx.labelSet, t)
+			return validTimeMorphism(t), &out
+		},
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			out := ctx.copy()
+			out.labelSet = temporal.ValidAt(ctx.labelSet, t)
+			return in, &out
+		},
+	}
+}
+
 // labelsMorphism iterates to the uniqified set of labels from
 // the given set of nodes in the path.
 func labelsMorphism() morphism {
@@ -286,10 +317,10 @@ func (s iteratorBuilder) Optimize(r shape.Optimizer) (shape.Shape, bool) {
 	return s, false
 }
 
-func followRecursiveMorphism(p *Path, maxDepth int, depthTags []string) morphism {
+func followRecursiveMorphism(p *Path, maxDepth int, depthTags []string, pathTag string) morphism {
 	return morphism{
 		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
-			return followRecursiveMorphism(p.Reverse(), maxDepth, depthTags), ctx
+			return followRecursiveMorphism(p.Reverse(), maxDepth, depthTags, pathTag), ctx
 		},
 		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
 			return iteratorBuilder(func(qs graph.QuadStore) graph.Iterator {
@@ -298,6 +329,60 @@ func followRecursiveMorphism(p *Path, maxDepth int, depthTags []string) morphism
 				for _, s := range depthTags {
 					it.AddDepthTag(s)
 				}
+				it.SetPathTag(pathTag)
+				return it
+			}), ctx
+		},
+	}
+}
+
+// viaPath returns a morphism for a list of predicates or a single *Path.
+func viaPath(via ...interface{}) *Path {
+	if len(via) == 1 {
+		if p, ok := via[0].(*Path); ok {
+			return p
+		}
+	}
+	return StartMorphism().Out(via...)
+}
+
+func shortestPathMorphism(to, via *Path, maxDepth int, hopTag string) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
+			panic("not implemented: the reverse depends on the nodes the path starts from")
+		},
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return iteratorBuilder(func(qs graph.QuadStore) graph.Iterator {
+				from := in.BuildIterator(qs)
+				it := iterator.NewShortestPath(qs, from, to.BuildIteratorOn(qs), via.Morphism(), via.Reverse().Morphism(), maxDepth)
+				it.SetHopTag(hopTag)
+				return it
+			}), ctx
+		},
+	}
+}
+
+func sequenceMorphism(events *Path, at interface{}, opts SequenceOptions) morphism {
+	var filt []shape.ValueFilter
+	if !opts.From.IsZero() {
+		filt = append(filt, shape.Comparison{Op: iterator.CompareGTE, Val: quad.Time(opts.From)})
+	}
+	if !opts.To.IsZero() {
+		filt = append(filt, shape.Comparison{Op: iterator.CompareLT, Val: quad.Time(opts.To)})
+	}
+	if len(filt) != 0 {
+		events = events.HasFilter(at, false, filt...)
+	}
+	events = events.Save(at, seqTimeTag)
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
+			panic("not implemented: the reverse depends on the nodes the path starts from")
+		},
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return iteratorBuilder(func(qs graph.QuadStore) graph.Iterator {
+				it := iterator.NewSequence(qs, in.BuildIterator(qs), events.Morphism(), seqTimeTag, opts.Order == Desc, opts.Skip, opts.Limit)
+				it.SetTimeTag(opts.TimeTag)
+				it.SetIndexTag(opts.IndexTag)
 				return it
 			}), ctx
 		},
@@ -421,6 +506,26 @@ func limitMorphism(v int64) morphism {
 	}
 }
 
+// orderMorphism will sort values of the current path.
+func orderMorphism(o SortOrder) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return orderMorphism(o), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return shape.Sort{From: in, Desc: o == Desc}, ctx
+		},
+	}
+}
+
+// orderByMorphism will sort values of the current path by values of a tag.
+func orderByMorphism(tag string, o SortOrder) morphism {
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) { return orderByMorphism(tag, o), ctx },
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return shape.Sort{From: in, Desc: o == Desc, Tag: tag}, ctx
+		},
+	}
+}
+
 // countMorphism will return count of values.
 func countMorphism() morphism {
 	return morphism{
@@ -17,10 +17,13 @@ package path
 import (
 	"context"
 	"regexp"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/graph/temporal"
 	"github.com/cayleygraph/cayley/quad"
 )
 
@@ -175,6 +178,49 @@ func (p *Path) RegexWithRefs(pattern *regexp.Regexp) *Path {
 	return p.Filters(shape.Regexp{Re: pattern, Refs: true})
 }
 
+// Text represents string literals that are matching a full-text query.
+// All terms of the query must be present in the value.
+func (p *Path) Text(query string) *Path {
+	return p.Filters(shape.Text{Query: query})
+}
+
+// TextScored is like Text, but also saves a relevance score of each value to a tag, and drops values with
+// a score below min. Scores are only known if the quad store ranks full-text matches.
+func (p *Path) TextScored(query, tag string, min float64) *Path {
+	return p.Filters(shape.Text{Query: query, ScoreTag: tag, MinScore: min})
+}
+
+// Fuzzy represents string literals that are within a given edit distance from the value.
+func (p *Path) Fuzzy(value string, distance int) *Path {
+	return p.Filters(shape.Fuzzy{Value: value, Distance: distance})
+}
+
+// SoundsLike represents string literals that sound like the value, according to a phonetic algorithm.
+// Empty algorithm selects Soundex.
+func (p *Path) SoundsLike(value, algo string) *Path {
+	return p.Filters(shape.SoundsLike{Value: value, Algorithm: algo})
+}
+
+// Prefix represents string literals that start with a given prefix, ignoring case.
+func (p *Path) Prefix(prefix string) *Path {
+	return p.Filters(shape.Prefix{Prefix: prefix})
+}
+
+// Near represents point values within a given distance from a location, in meters.
+func (p *Path) Near(lat, lng, radius float64) *Path {
+	return p.Filters(shape.Near{Lat: lat, Lng: lng, Radius: radius})
+}
+
+// Within represents point values that are inside a polygon.
+func (p *Path) Within(polygon ...geo.Point) *Path {
+	return p.Filters(shape.Within{Polygon: polygon})
+}
+
+// WithinBox represents point values that are inside a latitude-longitude rectangle.
+func (p *Path) WithinBox(box geo.Rect) *Path {
+	return p.Filters(shape.WithinBox{Box: box})
+}
+
 // Filter represents the nodes that are passing comparison with provided value.
 func (p *Path) Filter(op iterator.Operator, node quad.Value) *Path {
 	return p.Filters(shape.Comparison{Op: op, Val: node})
@@ -241,6 +287,37 @@ func (p *Path) OutWithTags(tags []string, via ...interface{}) *Path {
 	return np
 }
 
+// OutRegex is the same as Out, but follows all predicates that match a regular expression.
+// Predicates are matched against all nodes known to the quad store, thus it can be slow on large graphs.
+func (p *Path) OutRegex(pattern *regexp.Regexp) *Path {
+	return p.Out(StartMorphism().RegexWithRefs(pattern))
+}
+
+// InRegex is the same as In, but follows all predicates that match a regular expression.
+func (p *Path) InRegex(pattern *regexp.Regexp) *Path {
+	return p.In(StartMorphism().RegexWithRefs(pattern))
+}
+
+// OutProperties is the same as Out, but follows all predicates with IRIs matching a wildcard pattern
+// (see shape.Wildcard), and tags the predicate of each value. It can be used to search values across properties:
+//
+//	// Returns values of all name-like properties of bob, with predicates saved to "pred".
+//	StartPath(qs, "bob").OutProperties("%name%", "pred")
+//
+// Unlike OutRegex, only predicates used by the current nodes are matched.
+func (p *Path) OutProperties(pattern string, tags ...string) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, propertiesMorphism(pattern, false, tags))
+	return np
+}
+
+// InProperties is the same as OutProperties, but follows predicates in the reverse direction.
+func (p *Path) InProperties(pattern string, tags ...string) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, propertiesMorphism(pattern, true, tags))
+	return np
+}
+
 // Both updates this path following both inbound and outbound predicates.
 //
 // For example:
@@ -377,6 +454,15 @@ func (p *Path) FollowReverse(path *Path) *Path {
 //
 // This is a very expensive operation in practice. Be sure to use it wisely.
 func (p *Path) FollowRecursive(via interface{}, maxDepth int, depthTags []string) *Path {
+	return p.FollowRecursiveWithPath(via, maxDepth, depthTags, "")
+}
+
+// FollowRecursiveWithPath is the same as FollowRecursive, but also tags the chain of nodes each result was
+// reached by. Nodes are tagged with pathTag followed by the step number: the start node is tagged with
+// pathTag+"0", and the result itself with pathTag followed by its depth.
+//
+// Only the chain the result was first seen by is returned.
+func (p *Path) FollowRecursiveWithPath(via interface{}, maxDepth int, depthTags []string, pathTag string) *Path {
 	var path *Path
 	switch v := via.(type) {
 	case string:
@@ -389,7 +475,64 @@ func (p *Path) FollowRecursive(via interface{}, maxDepth int, depthTags []string
 		panic("did not pass a string predicate or a Path to FollowRecursive")
 	}
 	np := p.clone()
-	np.stack = append(p.stack, followRecursiveMorphism(path, maxDepth, depthTags))
+	np.stack = append(p.stack, followRecursiveMorphism(path, maxDepth, depthTags, pathTag))
+	return np
+}
+
+// HopTag is the tag prefix ShortestPath uses for nodes of each path.
+const HopTag = "hop"
+
+// ShortestPath finds the shortest path from each node of one path to each node of another one, by following
+// given predicates in the forward direction. Via can be a single *Path morphism as well.
+// Nodes of each path are tagged with HopTag followed by the step number: "hop0" is the start
+// of the path and the last tag is the result itself.
+//
+// It's the same as from.ShortestPathTo(to, 0, HopTag, via...).
+func ShortestPath(from, to *Path, via ...interface{}) *Path {
+	return from.ShortestPathTo(to, 0, HopTag, via...)
+}
+
+// ShortestPathTo updates the path to represent the nodes of another path that are reachable from current nodes
+// by following given predicates (or a single *Path morphism), once for each current node they are reachable from.
+// Only the shortest path is considered for each pair of nodes; if there are multiple paths of the same
+// length, one of them is picked.
+//
+// The "maxDepth" argument limits the number of steps in the path in the same way as in FollowRecursive.
+// If "hopTag" is not empty, a node at step i of the path is tagged as hopTag followed by i.
+//
+// The search is made from both ends of the path, thus it also follows the reverse of the morphism.
+func (p *Path) ShortestPathTo(to *Path, maxDepth int, hopTag string, via ...interface{}) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, shortestPathMorphism(to, viaPath(via...), maxDepth, hopTag))
+	return np
+}
+
+// SequenceOptions selects and orders events for Sequence.
+type SequenceOptions struct {
+	// From and To limit events to a time window; From is inclusive and To is exclusive.
+	// Zero values leave the window open.
+	From, To time.Time
+	// Order of events in each sequence. Asc returns the earliest events first.
+	Order SortOrder
+	// Skip and Limit select a window of each sequence, after events are ordered. A zero limit means no limit.
+	Skip, Limit int64
+	// TimeTag saves the time of each event, and IndexTag saves its position in the sequence, starting from zero.
+	TimeTag, IndexTag string
+}
+
+// seqTimeTag is used by the events morphism of Sequence to pass the time of each event.
+const seqTimeTag = "__seq_time"
+
+// Sequence follows the events of each current node, given by either a predicate or a *Path morphism,
+// and returns them ordered by their time, which is a value of the "at" predicate on each event.
+// Events without it are skipped.
+//
+// Sequences of nodes are returned one after another, in the order of the current nodes. Events of one node
+// are read in full before the first one is returned, thus it's common to use it for activity streams of
+// a few entities, for example, to get the last 10 actions of a user.
+func (p *Path) Sequence(via, at interface{}, opts SequenceOptions) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, sequenceMorphism(viaPath(via), at, opts))
 	return np
 }
 
@@ -452,6 +595,14 @@ func (p *Path) HasFilter(via interface{}, rev bool, filt ...shape.ValueFilter) *
 	return np
 }
 
+// Interval limits the paths to nodes with intervals that are in a given relation to another interval.
+// The interval of a node is set by time values of the start and end predicates; nodes without either of them
+// are excluded. Relations are compiled to range filters on both predicates, thus they can use time indexes.
+func (p *Path) Interval(start, end interface{}, rel temporal.Relation, iv temporal.Interval) *Path {
+	sf, ef := rel.Filters(iv)
+	return p.HasFilter(start, false, sf...).HasFilter(end, false, ef...)
+}
+
 // LabelContext restricts the following operations (such as In, Out) to only
 // traverse edges that match the given set of labels.
 func (p *Path) LabelContext(via ...interface{}) *Path {
@@ -468,6 +619,16 @@ func (p *Path) LabelContextWithTags(tags []string, via ...interface{}) *Path {
 	return np
 }
 
+// AsOf restricts the following operations (such as In, Out) to only traverse edges that are valid
+// at a given time. The valid time is set on labels of quads, see the temporal package for details.
+//
+// It narrows the current label context, and the next call to LabelContext replaces it.
+func (p *Path) AsOf(t time.Time) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, validTimeMorphism(t))
+	return np
+}
+
 // Back returns to a previously tagged place in the path. Any constraints applied after the Tag will remain in effect, but traversal continues from the tagged point instead, not from the end of the chain.
 //
 // For example:
@@ -525,20 +686,51 @@ func (p *Path) Morphism() graph.ApplyMorphism {
 
 // Skip will omit a number of values from result set.
 func (p *Path) Skip(v int64) *Path {
-	p.stack = append(p.stack, skipMorphism(v))
-	return p
+	np := p.clone()
+	np.stack = append(np.stack, skipMorphism(v))
+	return np
 }
 
 // Limit will limit a number of values in result set.
 func (p *Path) Limit(v int64) *Path {
-	p.stack = append(p.stack, limitMorphism(v))
-	return p
+	np := p.clone()
+	np.stack = append(np.stack, limitMorphism(v))
+	return np
+}
+
+// SortOrder is an order of values returned by Order.
+type SortOrder int
+
+const (
+	// Asc sorts values in an ascending order.
+	Asc SortOrder = iota
+	// Desc sorts values in a descending order.
+	Desc
+)
+
+// Order sorts values in result set. Numbers are compared by value and go first, followed by
+// times and all other values ordered by their string representation.
+//
+// Results are read in full before the first one is returned.
+func (p *Path) Order(o SortOrder) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, orderMorphism(o))
+	return np
+}
+
+// OrderBy sorts values in result set by values saved to a tag, for example, with Save or as a relevance score
+// of TextScored. Values are compared as in Order; values without the tag go last.
+func (p *Path) OrderBy(tag string, o SortOrder) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, orderByMorphism(tag, o))
+	return np
 }
 
 // Count will count a number of results as it's own result set.
 func (p *Path) Count() *Path {
-	p.stack = append(p.stack, countMorphism())
-	return p
+	np := p.clone()
+	np.stack = append(np.stack, countMorphism())
+	return np
 }
 
 // Iterate is an shortcut for graph.Iterate.
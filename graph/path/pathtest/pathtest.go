@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"testing"
 	"time"
 
@@ -166,6 +167,21 @@ func testSet(qs graph.QuadStore) []test {
 			path:    StartPath(qs, vBob).In(vFollows).RegexWithRefs(regexp.MustCompile("ar?li.*e")),
 			expect:  []quad.Value{vAlice, vCharlie},
 		},
+		{
+			message: "out regex",
+			path:    StartPath(qs, vBob).OutRegex(regexp.MustCompile("^sta")),
+			expect:  []quad.Value{vCool},
+		},
+		{
+			message: "in regex",
+			path:    StartPath(qs, vBob).InRegex(regexp.MustCompile("^(follows|status)$")),
+			expect:  []quad.Value{vAlice, vCharlie, vDani},
+		},
+		{
+			message: "in regex (no match)",
+			path:    StartPath(qs, vBob).InRegex(regexp.MustCompile("^status$")),
+			expect:  nil,
+		},
 		{
 			message: "path Out",
 			path:    StartPath(qs, vBob).Out(StartPath(qs, vPredicate).Out(vAre)),
@@ -352,6 +368,27 @@ func testSet(qs graph.QuadStore) []test {
 			expect:  []quad.Value{vFollows, vStatus},
 			tag:     "pred",
 		},
+		{
+			message: "OutProperties",
+			path:    StartPath(qs, vBob).OutProperties("%stat%", "pred"),
+			expect:  []quad.Value{vStatus},
+			tag:     "pred",
+		},
+		{
+			message: "OutProperties (values)",
+			path:    StartPath(qs, vBob, vDani).OutProperties("?o%"),
+			expect:  []quad.Value{vFred, vBob, vGreg},
+		},
+		{
+			message: "InProperties",
+			path:    StartPath(qs, vCool).InProperties("status"),
+			expect:  []quad.Value{vBob, vDani, vGreg},
+		},
+		{
+			message: "OutProperties (no match)",
+			path:    StartPath(qs, vBob).OutProperties("%name%"),
+			expect:  nil,
+		},
 		// Morphism tests
 		{
 			message: "simple morphism",
@@ -416,6 +453,44 @@ func testSet(qs graph.QuadStore) []test {
 			path:    StartPath(qs, vCharlie).FollowRecursive(vFollows, 1, nil),
 			expect:  []quad.Value{vBob, vDani},
 		},
+		{
+			message: "shortest path",
+			path:    ShortestPath(StartPath(qs, vAlice, vCharlie), StartPath(qs, vGreg), vFollows),
+			expect:  []quad.Value{vGreg, vGreg},
+		},
+		{
+			message: "shortest path (hops)",
+			path:    ShortestPath(StartPath(qs, vAlice), StartPath(qs, vGreg), vFollows),
+			tag:     HopTag + "2",
+			expect:  []quad.Value{vFred},
+		},
+		{
+			message: "shortest path (start)",
+			path:    ShortestPath(StartPath(qs, vAlice, vCharlie), StartPath(qs, vGreg), vFollows),
+			tag:     HopTag + "0",
+			expect:  []quad.Value{vAlice, vCharlie},
+		},
+		{
+			message: "shortest path (morphism)",
+			path:    StartPath(qs, vCharlie).ShortestPathTo(StartPath(qs, vGreg), 0, "via", StartMorphism().Out(vFollows)),
+			tag:     "via1",
+			expect:  []quad.Value{vDani},
+		},
+		{
+			message: "shortest path (limit depth)",
+			path:    StartPath(qs, vAlice, vCharlie).ShortestPathTo(StartPath(qs, vGreg), 2, "", vFollows),
+			expect:  []quad.Value{vGreg},
+		},
+		{
+			message: "shortest path (unreachable)",
+			path:    ShortestPath(StartPath(qs, vGreg), StartPath(qs, vAlice), vFollows),
+			expect:  nil,
+		},
+		{
+			message: "shortest path (contains)",
+			path:    StartPath(qs, vBob, vGreg).And(ShortestPath(StartPath(qs, vDani), StartPath(qs, vGreg, vEmily), vFollows)),
+			expect:  []quad.Value{vGreg},
+		},
 		{
 			message: "find non-existent",
 			path:    StartPath(qs, quad.IRI("<not-existing>")),
@@ -427,6 +502,8 @@ func testSet(qs graph.QuadStore) []test {
 func RunTestMorphisms(t *testing.T, fnc testutil.DatabaseFunc) {
 	for _, ftest := range []func(*testing.T, testutil.DatabaseFunc){
 		testFollowRecursive,
+		testWeightedPath,
+		testOrder,
 	} {
 		ftest(t, fnc)
 	}
@@ -521,4 +598,166 @@ func testFollowRecursive(t *testing.T, fnc testutil.DatabaseFunc) {
 			}
 		})
 	}
+
+	t.Run("follows recursive path", func(t *testing.T) {
+		qu := StartPath(qs, quad.IRI("a")).FollowRecursiveWithPath(
+			quad.IRI("parent"), 0, []string{"depth"}, "step",
+		).Is(quad.IRI("d"))
+		var got []map[string]quad.Value
+		err := qu.Iterate(context.TODO()).TagValues(qs, func(m map[string]quad.Value) {
+			got = append(got, m)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []map[string]quad.Value{{
+			"step0": quad.IRI("a"), "step1": quad.IRI("b"), "step2": quad.IRI("c"), "step3": quad.IRI("d"),
+			"depth": quad.Int(3),
+		}}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("unexpected path: %v, expected: %v", got, expect)
+		}
+	})
+}
+
+func testWeightedPath(t *testing.T, fnc testutil.DatabaseFunc) {
+	road := func(name, from, to string, dist quad.Value) []quad.Quad {
+		return []quad.Quad{
+			quad.MakeIRI(name, "from", from, ""),
+			quad.MakeIRI(name, "to", to, ""),
+			quad.Make(quad.IRI(name), quad.IRI("distance"), dist, nil),
+		}
+	}
+	var quads []quad.Quad
+	quads = append(quads, road("r1", "a", "b", quad.Int(1))...)
+	quads = append(quads, road("r2", "b", "c", quad.Float(1.5))...)
+	quads = append(quads, road("r3", "a", "c", quad.String("5"))...)
+	quads = append(quads, road("r4", "c", "d", quad.Int(1))...)
+	quads = append(quads, quad.MakeIRI("e", "from", "a", "")) // no distance
+	quads = append(quads, quad.MakeIRI("e", "to", "d", ""))
+	qs, closer := makeTestStore(t, fnc, quads...)
+	defer closer()
+
+	opts := WeightedPathOptions{
+		Edge:    StartMorphism().In(quad.IRI("from")),
+		Next:    StartMorphism().Out(quad.IRI("to")),
+		Weight:  quad.IRI("distance"),
+		HopTag:  "hop",
+		CostTag: "cost",
+	}
+	for _, c := range []struct {
+		msg  string
+		opts WeightedPathOptions
+		to   quad.Value
+		exp  []quad.Value
+		cost float64
+	}{
+		{msg: "weighted path", opts: opts, to: quad.IRI("d"),
+			exp: []quad.Value{quad.IRI("a"), quad.IRI("b"), quad.IRI("c"), quad.IRI("d")}, cost: 3.5},
+		{msg: "weighted path (func)", opts: WeightedPathOptions{
+			Edge: opts.Edge, Next: opts.Next, HopTag: "hop", CostTag: "cost",
+			WeightFunc: func(from, edge, to quad.Value) (float64, bool) {
+				return 1, edge != nil
+			},
+		}, to: quad.IRI("d"), exp: []quad.Value{quad.IRI("a"), quad.IRI("d")}, cost: 1},
+		{msg: "weighted path (heuristic)", opts: WeightedPathOptions{
+			Edge: opts.Edge, Next: opts.Next, Weight: opts.Weight, HopTag: "hop", CostTag: "cost",
+			Heuristic: func(node, target quad.Value) float64 {
+				return 0.5
+			},
+		}, to: quad.IRI("c"), exp: []quad.Value{quad.IRI("a"), quad.IRI("b"), quad.IRI("c")}, cost: 2.5},
+		{msg: "weighted path (via)", opts: WeightedPathOptions{
+			Via: []interface{}{StartMorphism().In(quad.IRI("from")).Out(quad.IRI("to"))}, HopTag: "hop", CostTag: "cost",
+			WeightFunc: func(from, edge, to quad.Value) (float64, bool) {
+				return 2, true
+			},
+		}, to: quad.IRI("c"), exp: []quad.Value{quad.IRI("a"), quad.IRI("c")}, cost: 2},
+	} {
+		t.Run(c.msg, func(t *testing.T) {
+			p := StartPath(qs, quad.IRI("a")).WeightedPathTo(StartPath(qs, c.to), c.opts)
+			var got []map[string]quad.Value
+			err := p.Iterate(context.TODO()).TagValues(qs, func(m map[string]quad.Value) {
+				got = append(got, m)
+			})
+			if err != nil {
+				t.Fatal(err)
+			} else if len(got) != 1 {
+				t.Fatalf("expected one path, got: %v", got)
+			}
+			var hops []quad.Value
+			for i := 0; ; i++ {
+				v, ok := got[0]["hop"+strconv.Itoa(i)]
+				if !ok {
+					break
+				}
+				hops = append(hops, v)
+			}
+			if !reflect.DeepEqual(hops, c.exp) {
+				t.Errorf("unexpected path: %v, expected: %v", hops, c.exp)
+			}
+			if cost := got[0]["cost"]; cost != quad.Float(c.cost) {
+				t.Errorf("unexpected cost: %v, expected: %v", cost, c.cost)
+			}
+		})
+	}
+}
+
+func testOrder(t *testing.T, fnc testutil.DatabaseFunc) {
+	score := quad.IRI("score")
+	qs, closer := makeTestStore(t, fnc, []quad.Quad{
+		quad.Make(quad.IRI("a"), score, quad.Int(10), nil),
+		quad.Make(quad.IRI("b"), score, quad.Float(2.5), nil),
+		quad.Make(quad.IRI("c"), score, quad.Int(-1), nil),
+		quad.Make(quad.IRI("d"), score, quad.String("x"), nil),
+		quad.Make(quad.IRI("e"), score, quad.Int(3), nil),
+	}...)
+	defer closer()
+
+	all := StartPath(qs).Tag("node").Out(score)
+	for _, c := range []struct {
+		msg    string
+		path   *Path
+		expect []quad.Value
+	}{
+		{msg: "order asc", path: all.Order(Asc),
+			expect: []quad.Value{quad.Int(-1), quad.Float(2.5), quad.Int(3), quad.Int(10), quad.String("x")}},
+		{msg: "order desc", path: all.Order(Desc),
+			expect: []quad.Value{quad.String("x"), quad.Int(10), quad.Int(3), quad.Float(2.5), quad.Int(-1)}},
+		{msg: "order and page", path: all.Order(Asc).Skip(1).Limit(2),
+			expect: []quad.Value{quad.Float(2.5), quad.Int(3)}},
+	} {
+		for _, opt := range []bool{true, false} {
+			name := c.msg
+			if !opt {
+				name += " (unoptimized)"
+			}
+			t.Run(name, func(t *testing.T) {
+				got, err := runTopLevel(qs, c.path, opt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, c.expect) {
+					t.Errorf("unexpected order: %v, expected: %v", got, c.expect)
+				}
+			})
+		}
+	}
+	t.Run("order tags", func(t *testing.T) {
+		got, err := runTag(qs, all.Order(Desc).Limit(1), "node", true)
+		if err != nil {
+			t.Fatal(err)
+		} else if exp := []quad.Value{quad.IRI("d")}; !reflect.DeepEqual(got, exp) {
+			t.Errorf("unexpected tags: %v, expected: %v", got, exp)
+		}
+	})
+	t.Run("limit returns a new path", func(t *testing.T) {
+		p := StartPath(qs).Out(score)
+		_ = p.Limit(1)
+		got, err := runTopLevel(qs, p, true)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(got) != 5 {
+			t.Errorf("expected original path to be unchanged, got: %v", got)
+		}
+	})
 }
@@ -0,0 +1,135 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package path
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// WeightedPathOptions describes how to follow and weight the edges for WeightedPathTo.
+//
+// Edges are usually reified: each edge is a node with links to both ends and with a weight, for example:
+//	<road1> <from> <a> .
+//	<road1> <to> <b> .
+//	<road1> <distance> "12.5" .
+// This graph is described by Edge: StartMorphism().In("from"), Next: StartMorphism().Out("to") and Weight: "distance".
+type WeightedPathOptions struct {
+	// Edge is a morphism from a node to the edges that start at it.
+	Edge *Path
+	// Next is a morphism from an edge to the node it ends at.
+	Next *Path
+	// Via is a list of predicates (or a single *Path) that are followed from each node to its neighbors directly,
+	// if Edge is not set. Such steps have no edge node, thus WeightFunc must be provided.
+	Via []interface{}
+
+	// Weight is a predicate of edges with a numeric weight. Edges without it are not followed.
+	Weight quad.Value
+	// WeightFunc returns the weight of a step from one node to another through an edge.
+	// The edge is nil for steps made with Via. It's used instead of Weight, if set.
+	WeightFunc func(from, edge, to quad.Value) (float64, bool)
+	// Heuristic estimates the cost of the path from a node to the target, making the search A*.
+	// It must never overestimate the cost. Dijkstra's algorithm is used if it's not set.
+	Heuristic func(node, target quad.Value) float64
+
+	// HopTag is a tag prefix for nodes of the path, the same as for ShortestPathTo.
+	HopTag string
+	// CostTag is a tag for the total cost of the path.
+	CostTag string
+}
+
+// WeightedPathTo updates the path to represent the nodes of another path that are reachable from current nodes,
+// once for each current node they are reachable from. For each pair of nodes the path with the least total
+// weight of all steps is found and its cost is saved to opts.CostTag.
+//
+// Weights must not be negative.
+func (p *Path) WeightedPathTo(to *Path, opts WeightedPathOptions) *Path {
+	np := p.clone()
+	np.stack = append(np.stack, weightedPathMorphism(to, opts))
+	return np
+}
+
+func weightedPathMorphism(to *Path, opts WeightedPathOptions) morphism {
+	var step *Path
+	if opts.Edge != nil {
+		step = StartMorphism().Follow(opts.Edge).Tag(iterator.EdgeTag).Follow(opts.Next)
+	} else {
+		step = viaPath(opts.Via...)
+	}
+	return morphism{
+		Reversal: func(ctx *pathContext) (morphism, *pathContext) {
+			panic("not implemented: the reverse depends on the nodes the path starts from")
+		},
+		Apply: func(in shape.Shape, ctx *pathContext) (shape.Shape, *pathContext) {
+			return iteratorBuilder(func(qs graph.QuadStore) graph.Iterator {
+				var h iterator.Heuristic
+				if opts.Heuristic != nil {
+					h = func(node, target graph.Value) float64 {
+						return opts.Heuristic(qs.NameOf(node), qs.NameOf(target))
+					}
+				}
+				it := iterator.NewWeightedPath(qs, in.BuildIterator(qs), to.BuildIteratorOn(qs), step.Morphism(), edgeWeight(qs, opts), h)
+				it.SetHopTag(opts.HopTag)
+				it.SetCostTag(opts.CostTag)
+				return it
+			}), ctx
+		},
+	}
+}
+
+// edgeWeight returns a function that weights steps according to the options.
+func edgeWeight(qs graph.QuadStore, opts WeightedPathOptions) iterator.Weight {
+	if fnc := opts.WeightFunc; fnc != nil {
+		return func(from, edge, to graph.Value) (float64, bool) {
+			var e quad.Value
+			if edge != nil {
+				e = qs.NameOf(edge)
+			}
+			return fnc(qs.NameOf(from), e, qs.NameOf(to))
+		}
+	}
+	return func(_, edge, _ graph.Value) (float64, bool) {
+		if edge == nil || opts.Weight == nil {
+			return 0, false
+		}
+		v, err := StartPathNodes(qs, edge).Out(opts.Weight).Iterate(context.TODO()).FirstValue(qs)
+		if err != nil || v == nil {
+			return 0, false
+		}
+		return toFloat(v)
+	}
+}
+
+// toFloat converts a numeric value, or a string with a number, to float64.
+func toFloat(v quad.Value) (float64, bool) {
+	switch v := v.(type) {
+	case quad.Int:
+		return float64(v), true
+	case quad.Float:
+		return float64(v), true
+	case quad.String:
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil
+	case quad.TypedString:
+		f, err := strconv.ParseFloat(string(v.Value), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
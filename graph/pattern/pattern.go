@@ -0,0 +1,393 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pattern implements subgraph matching: a pattern of edges between variables and fixed nodes
+// is compiled into a single query shape, and each match is returned as a set of variable bindings.
+//
+// Patterns can be defined in Go or decoded from JSON:
+//
+//	{"edges": [
+//		{"subject": "?a", "predicate": "<follows>", "object": "?b"},
+//		{"subject": "?b", "predicate": "<status>", "object": "cool_person"}
+//	]}
+//
+// Strings starting with "?" are variables, other strings are parsed with quad.StringToValue.
+package pattern
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Node is a term of a pattern edge: a variable, a fixed value, or both.
+// A zero Node matches any node.
+type Node struct {
+	// Var is the name of a variable the matched node is bound to.
+	Var string
+	// Value restricts the node to a given value.
+	Value quad.Value
+}
+
+// Var returns a variable node with a given name.
+func Var(name string) Node { return Node{Var: name} }
+
+// Value returns a node that matches a fixed value.
+func Value(v quad.Value) Node { return Node{Value: v} }
+
+// IsAny checks if node matches any value.
+func (n Node) IsAny() bool { return n.Var == "" && n.Value == nil }
+
+func (n Node) String() string {
+	switch {
+	case n.Var != "" && n.Value != nil:
+		return "?" + n.Var + "=" + n.Value.String()
+	case n.Var != "":
+		return "?" + n.Var
+	case n.Value != nil:
+		return n.Value.String()
+	}
+	return "*"
+}
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	switch {
+	case n.Var != "" && n.Value != nil:
+		return nil, fmt.Errorf("pattern: cannot encode variable with a value: %v", n)
+	case n.Var != "":
+		return json.Marshal("?" + n.Var)
+	case n.Value != nil:
+		if s, ok := n.Value.(quad.String); ok {
+			return json.Marshal(string(s))
+		}
+		return json.Marshal(n.Value.String())
+	}
+	return []byte("null"), nil
+}
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*n = Node{}
+	if s == nil || *s == "" {
+		return nil
+	}
+	if strings.HasPrefix(*s, "?") {
+		n.Var = (*s)[1:]
+	} else {
+		n.Value = quad.StringToValue(*s)
+	}
+	return nil
+}
+
+// Edge is a pattern of a single quad. Variables are only supported for subjects and objects.
+type Edge struct {
+	Subject   Node `json:"subject"`
+	Predicate Node `json:"predicate"`
+	Object    Node `json:"object"`
+	Label     Node `json:"label,omitempty"`
+}
+
+func (e Edge) String() string {
+	return fmt.Sprintf("%v -- %v -> %v", e.Subject, e.Predicate, e.Object)
+}
+
+// Pattern is a set of edges that should all exist in the graph for variables to match.
+type Pattern struct {
+	Edges []Edge `json:"edges"`
+	// Distinct requires different variables to be bound to different nodes.
+	Distinct bool `json:"distinct,omitempty"`
+}
+
+var (
+	ErrNoVars       = errors.New("pattern: no variables")
+	ErrDisconnected = errors.New("pattern: variables are not connected")
+)
+
+// Query is a compiled pattern.
+type Query struct {
+	shape    shape.Shape
+	vars     []string
+	check    []Edge // edges closing cycles of the pattern
+	distinct bool
+}
+
+// edge links pattern edge to a node of the tree.
+type edge struct {
+	e     *Edge
+	out   bool // node is a subject of the edge
+	other Node
+}
+
+// Compile builds a query shape for a pattern.
+//
+// The shape joins all edges of a spanning tree of the pattern, starting from the variable with
+// the most edges. Edges that close cycles in the pattern are checked for each result of the shape.
+func Compile(p Pattern) (*Query, error) {
+	edges := make(map[string][]edge)
+	values := make(map[string]quad.Value)
+	addVar := func(n Node) error {
+		if n.Var == "" {
+			return nil
+		}
+		if v, ok := values[n.Var]; !ok || v == nil {
+			values[n.Var] = n.Value
+		} else if n.Value != nil && v.String() != n.Value.String() {
+			return fmt.Errorf("pattern: conflicting values for ?%s: %v and %v", n.Var, v, n.Value)
+		}
+		return nil
+	}
+	for i := range p.Edges {
+		e := &p.Edges[i]
+		if e.Predicate.Var != "" || e.Label.Var != "" {
+			return nil, fmt.Errorf("pattern: variables are only supported for subjects and objects: %v", e)
+		}
+		for _, n := range []Node{e.Subject, e.Object} {
+			if err := addVar(n); err != nil {
+				return nil, err
+			}
+		}
+		if v := e.Subject.Var; v != "" {
+			edges[v] = append(edges[v], edge{e: e, out: true, other: e.Object})
+		}
+		if v := e.Object.Var; v != "" && v != e.Subject.Var {
+			edges[v] = append(edges[v], edge{e: e, out: false, other: e.Subject})
+		}
+	}
+	if len(values) == 0 {
+		return nil, ErrNoVars
+	}
+	vars := make([]string, 0, len(values))
+	for v := range values {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	root := vars[0]
+	for _, v := range vars {
+		if len(edges[v]) > len(edges[root]) {
+			root = v
+		}
+	}
+	c := compiler{edges: edges, values: values, visited: make(map[string]bool), used: make(map[*Edge]bool)}
+	s := c.build(root)
+	for i := range p.Edges {
+		e := &p.Edges[i]
+		if !c.used[e] {
+			if e.Subject.Var == "" && e.Object.Var == "" {
+				// not linked to any variable
+				return nil, ErrDisconnected
+			}
+			c.check = append(c.check, *e)
+		}
+	}
+	if len(c.visited) != len(vars) {
+		return nil, ErrDisconnected
+	}
+	return &Query{shape: s, vars: vars, check: c.check, distinct: p.Distinct}, nil
+}
+
+type compiler struct {
+	edges   map[string][]edge
+	values  map[string]quad.Value
+	visited map[string]bool
+	used    map[*Edge]bool
+	check   []Edge
+}
+
+func lookup(v quad.Value) shape.Shape {
+	if v == nil {
+		return shape.AllNodes{}
+	}
+	return shape.Lookup{v}
+}
+
+// build returns a shape for a variable with all edges in the sub-tree.
+func (c *compiler) build(v string) shape.Shape {
+	c.visited[v] = true
+	var s shape.Shape = lookup(c.values[v])
+	for _, e := range c.edges[v] {
+		if c.used[e.e] {
+			continue
+		}
+		var other shape.Shape
+		switch {
+		case e.other.Var == v:
+			// self-loop; check the result instead
+			continue
+		case e.other.Var != "":
+			if c.visited[e.other.Var] {
+				// closes a cycle
+				continue
+			}
+			c.used[e.e] = true
+			other = c.build(e.other.Var)
+		default:
+			c.used[e.e] = true
+			other = lookup(e.other.Value)
+		}
+		pred := lookup(e.e.Predicate.Value)
+		var labels shape.Shape
+		if e.e.Label.Value != nil {
+			labels = lookup(e.e.Label.Value)
+		}
+		if e.out {
+			s = shape.IntersectShapes(s, shape.In(other, pred, labels))
+		} else {
+			s = shape.IntersectShapes(s, shape.Out(other, pred, labels))
+		}
+	}
+	return shape.Save{From: s, Tags: []string{v}}
+}
+
+// Vars returns sorted names of all variables of the pattern.
+func (q *Query) Vars() []string {
+	return append([]string{}, q.vars...)
+}
+
+// Shape returns a shape that matches the spanning tree of the pattern. Each variable is saved as a tag with the same name.
+//
+// Edges that close cycles and the Distinct constraint are only checked by Iterate.
+func (q *Query) Shape() shape.Shape {
+	return q.shape
+}
+
+// Iterate calls fn for each match of the pattern. Each set of bindings is returned only once.
+//
+// Iteration stops if fn returns an error; it is returned by Iterate.
+func (q *Query) Iterate(ctx context.Context, qs graph.QuadStore, fn func(m map[string]quad.Value) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	seen := make(map[string]struct{})
+	var ferr error
+	err := shape.Iterate(ctx, qs, q.shape).TagEach(func(m map[string]graph.Value) {
+		if ferr != nil || !q.distinctVars(m) {
+			return
+		}
+		ok, err := q.matches(ctx, qs, m)
+		if err != nil {
+			ferr = err
+		}
+		if !ok || err != nil {
+			return
+		}
+		key := q.key(m)
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		res := make(map[string]quad.Value, len(q.vars))
+		for _, v := range q.vars {
+			res[v] = qs.NameOf(m[v])
+		}
+		if err := fn(res); err != nil {
+			ferr = err
+			cancel()
+		}
+	})
+	if ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// All returns all matches of the pattern.
+func (q *Query) All(ctx context.Context, qs graph.QuadStore) ([]map[string]quad.Value, error) {
+	var out []map[string]quad.Value
+	err := q.Iterate(ctx, qs, func(m map[string]quad.Value) error {
+		out = append(out, m)
+		return nil
+	})
+	return out, err
+}
+
+func (q *Query) key(m map[string]graph.Value) string {
+	var buf strings.Builder
+	for _, v := range q.vars {
+		fmt.Fprintf(&buf, "%v\x00", graph.ToKey(m[v]))
+	}
+	return buf.String()
+}
+
+func (q *Query) distinctVars(m map[string]graph.Value) bool {
+	if !q.distinct {
+		return true
+	}
+	seen := make(map[interface{}]struct{}, len(q.vars))
+	for _, v := range q.vars {
+		k := graph.ToKey(m[v])
+		if _, ok := seen[k]; ok {
+			return false
+		}
+		seen[k] = struct{}{}
+	}
+	return true
+}
+
+// resolve returns an id of a node bound in m, or nil if node matches anything.
+func resolve(qs graph.QuadStore, n Node, m map[string]graph.Value) (graph.Value, bool) {
+	if n.Var != "" {
+		return m[n.Var], true
+	} else if n.Value != nil {
+		v := qs.ValueOf(n.Value)
+		return v, v != nil
+	}
+	return nil, true
+}
+
+// matches checks edges that are not a part of the query shape.
+func (q *Query) matches(ctx context.Context, qs graph.QuadStore, m map[string]graph.Value) (bool, error) {
+	for _, e := range q.check {
+		var (
+			ids [4]graph.Value
+			fix = -1
+		)
+		for i, n := range []Node{e.Subject, e.Predicate, e.Object, e.Label} {
+			v, ok := resolve(qs, n, m)
+			if !ok {
+				return false, nil
+			}
+			ids[i] = v
+			if v != nil && fix < 0 {
+				fix = i
+			}
+		}
+		dirs := []quad.Direction{quad.Subject, quad.Predicate, quad.Object, quad.Label}
+		it := qs.QuadIterator(dirs[fix], ids[fix])
+		found := false
+		for !found && it.Next(ctx) {
+			found = true
+			for i, d := range dirs {
+				if ids[i] != nil && graph.ToKey(qs.QuadDirection(it.Result(), d)) != graph.ToKey(ids[i]) {
+					found = false
+					break
+				}
+			}
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil || !found {
+			return false, err
+		}
+	}
+	return true, nil
+}
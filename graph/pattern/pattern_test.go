@@ -0,0 +1,139 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var testQuads = []quad.Quad{
+	quad.MakeIRI("a", "follows", "b", ""),
+	quad.MakeIRI("b", "follows", "c", ""),
+	quad.MakeIRI("c", "follows", "a", ""),
+	quad.MakeIRI("c", "follows", "d", ""),
+	quad.MakeIRI("d", "follows", "d", ""),
+	quad.Make(quad.IRI("b"), quad.IRI("status"), quad.String("cool"), nil),
+	quad.Make(quad.IRI("d"), quad.IRI("status"), quad.String("cool"), nil),
+}
+
+func follows(s, o Node) Edge {
+	return Edge{Subject: s, Predicate: Value(quad.IRI("follows")), Object: o}
+}
+
+// names converts matches to strings, so they can be compared regardless of the order.
+func names(matches []map[string]quad.Value, vars ...string) []string {
+	var out []string
+	for _, m := range matches {
+		s := ""
+		for _, v := range vars {
+			s += string(m[v].(quad.IRI)) + " "
+		}
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestMatch(t *testing.T) {
+	qs := memstore.New(testQuads...)
+	a, b, c := Var("a"), Var("b"), Var("c")
+	for _, tc := range []struct {
+		name   string
+		p      Pattern
+		vars   []string
+		expect []string
+	}{
+		{
+			name: "chain",
+			p: Pattern{Edges: []Edge{
+				follows(a, b),
+				{Subject: b, Predicate: Value(quad.IRI("status")), Object: Value(quad.String("cool"))},
+			}},
+			vars:   []string{"a", "b"},
+			expect: []string{"a b ", "c d ", "d d "},
+		},
+		{
+			name:   "triangle",
+			p:      Pattern{Edges: []Edge{follows(a, b), follows(b, c), follows(c, a)}},
+			vars:   []string{"a", "b", "c"},
+			expect: []string{"a b c ", "b c a ", "c a b ", "d d d "},
+		},
+		{
+			name:   "triangle (distinct)",
+			p:      Pattern{Edges: []Edge{follows(a, b), follows(b, c), follows(c, a)}, Distinct: true},
+			vars:   []string{"a", "b", "c"},
+			expect: []string{"a b c ", "b c a ", "c a b "},
+		},
+		{
+			name:   "self-loop",
+			p:      Pattern{Edges: []Edge{follows(a, a)}},
+			vars:   []string{"a"},
+			expect: []string{"d "},
+		},
+		{
+			name:   "fixed node",
+			p:      Pattern{Edges: []Edge{follows(Value(quad.IRI("c")), a), follows(a, Node{})}},
+			vars:   []string{"a"},
+			expect: []string{"a ", "d "},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := Compile(tc.p)
+			require.NoError(t, err)
+			require.Equal(t, tc.vars, q.Vars())
+			got, err := q.All(context.TODO(), qs)
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, names(got, tc.vars...))
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	_, err := Compile(Pattern{Edges: []Edge{follows(Value(quad.IRI("a")), Value(quad.IRI("b")))}})
+	require.Equal(t, ErrNoVars, err)
+
+	_, err = Compile(Pattern{Edges: []Edge{follows(Var("a"), Var("b")), follows(Var("c"), Var("d"))}})
+	require.Equal(t, ErrDisconnected, err)
+
+	_, err = Compile(Pattern{Edges: []Edge{{Subject: Var("a"), Predicate: Var("p"), Object: Var("b")}}})
+	require.Error(t, err)
+}
+
+func TestJSON(t *testing.T) {
+	const data = `{"edges": [
+		{"subject": "?a", "predicate": "<follows>", "object": "?b"},
+		{"subject": "?b", "predicate": "<status>", "object": "cool"}
+	]}`
+	var p Pattern
+	require.NoError(t, json.Unmarshal([]byte(data), &p))
+	require.Equal(t, Pattern{Edges: []Edge{
+		follows(Var("a"), Var("b")),
+		{Subject: Var("b"), Predicate: Value(quad.IRI("status")), Object: Value(quad.String("cool"))},
+	}}, p)
+
+	out, err := json.Marshal(p)
+	require.NoError(t, err)
+	var p2 Pattern
+	require.NoError(t, json.Unmarshal(out, &p2))
+	require.Equal(t, p, p2)
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxPlanLength is the maximal length of a summary returned by SummarizeIterator.
+const MaxPlanLength = 1024
+
+// Plan is a summary of an optimized iterator tree, set by Iterate when its context carries the plan.
+// A query that runs multiple iterations keeps the plan of the last one.
+type Plan struct {
+	mu sync.Mutex
+	s  string
+}
+
+type planKey struct{}
+
+// NewPlanContext returns a context that records plans of iterations to p.
+func NewPlanContext(ctx context.Context, p *Plan) context.Context {
+	return context.WithValue(ctx, planKey{}, p)
+}
+
+func planFromContext(ctx context.Context) *Plan {
+	p, _ := ctx.Value(planKey{}).(*Plan)
+	return p
+}
+
+func (p *Plan) set(it Iterator) {
+	s := SummarizeIterator(it)
+	p.mu.Lock()
+	p.s = s
+	p.mu.Unlock()
+}
+
+// String returns the last recorded plan, or an empty string if no iteration was started yet.
+func (p *Plan) String() string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.s
+}
+
+// SummarizeIterator returns a one-line summary of the iterator tree with types and sizes of iterators,
+// for example "hasa~10[and~10[fixed=1, linksto~40[fixed=1]]]". Exact sizes are written after "=" and estimates after "~".
+// Long summaries are truncated to MaxPlanLength.
+func SummarizeIterator(it Iterator) string {
+	var b strings.Builder
+	summarize(&b, it)
+	if b.Len() > MaxPlanLength {
+		return b.String()[:MaxPlanLength-3] + "..."
+	}
+	return b.String()
+}
+
+func summarize(b *strings.Builder, it Iterator) {
+	if b.Len() > MaxPlanLength {
+		return
+	}
+	b.WriteString(it.Type().String())
+	sz, exact := it.Size()
+	if exact {
+		b.WriteByte('=')
+	} else {
+		b.WriteByte('~')
+	}
+	b.WriteString(strconv.FormatInt(sz, 10))
+	subs := it.SubIterators()
+	if len(subs) == 0 {
+		return
+	}
+	b.WriteByte('[')
+	for i, sub := range subs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		summarize(b, sub)
+	}
+	b.WriteByte(']')
+}
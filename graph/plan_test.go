@@ -0,0 +1,33 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+)
+
+func TestPlan(t *testing.T) {
+	newIt := func() graph.Iterator {
+		return iterator.NewAnd(nil,
+			iterator.NewFixed(iterator.Int64Node(1), iterator.Int64Node(2)),
+			iterator.NewInt64(1, 10, true),
+		)
+	}
+	if s := graph.SummarizeIterator(newIt()); s != "and=2[fixed=2, all=10]" {
+		t.Fatalf("unexpected summary: %q", s)
+	}
+
+	var p graph.Plan
+	if s := p.String(); s != "" {
+		t.Fatalf("unexpected plan before iteration: %q", s)
+	}
+	ctx := graph.NewPlanContext(context.TODO(), &p)
+	if n, err := graph.Iterate(ctx, newIt()).Count(); err != nil || n != 2 {
+		t.Fatalf("unexpected count: %v, %v", n, err)
+	}
+	if s := p.String(); s == "" {
+		t.Fatal("plan is not recorded")
+	}
+}
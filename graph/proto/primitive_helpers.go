@@ -1,6 +1,11 @@
 package proto
 
-import "github.com/cayleygraph/cayley/quad"
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/cayleygraph/cayley/quad"
+)
 
 //go:generate protoc --proto_path=$GOPATH/src:. --gogo_out=. primitive.proto
 
@@ -42,3 +47,62 @@ func (p Primitive) Key() interface{} {
 func (p *Primitive) IsSameLink(q *Primitive) bool {
 	return p.Subject == q.Subject && p.Predicate == q.Predicate && p.Object == q.Object && p.Label == q.Label
 }
+
+// UnmarshalShallow decodes a primitive the same way as Unmarshal, but the Value of the primitive
+// points into the buffer instead of being copied. The primitive must not be used after the buffer is released.
+func (m *Primitive) UnmarshalShallow(b []byte) error {
+	*m = Primitive{}
+	for len(b) != 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return io.ErrUnexpectedEOF
+		}
+		field, wire := tag>>3, tag&7
+		if field == 8 && wire == 2 {
+			sz, n2 := binary.Uvarint(b[n:])
+			if n2 <= 0 {
+				return io.ErrUnexpectedEOF
+			}
+			b = b[n+n2:]
+			if sz > uint64(len(b)) {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value, b = b[:sz:sz], b[sz:]
+			continue
+		} else if field < 1 || field > 9 || wire != 0 {
+			// fields that are not known to this version
+			skip, err := skipPrimitive(b)
+			if err != nil {
+				return err
+			} else if skip <= 0 || skip > len(b) {
+				return io.ErrUnexpectedEOF
+			}
+			b = b[skip:]
+			continue
+		}
+		v, n2 := binary.Uvarint(b[n:])
+		if n2 <= 0 {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[n+n2:]
+		switch field {
+		case 1:
+			m.ID = v
+		case 2:
+			m.Subject = v
+		case 3:
+			m.Predicate = v
+		case 4:
+			m.Object = v
+		case 5:
+			m.Label = v
+		case 6:
+			m.Replaces = v
+		case 7:
+			m.Timestamp = int64(v)
+		case 9:
+			m.Deleted = v != 0
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalShallow(t *testing.T) {
+	for _, p := range []Primitive{
+		{},
+		{ID: 1, Value: []byte("abc"), Timestamp: 1234567890123},
+		{ID: 1 << 40, Subject: 2, Predicate: 3, Object: 4, Label: 5, Replaces: 6, Timestamp: -1, Deleted: true},
+	} {
+		b, err := p.Marshal()
+		require.NoError(t, err)
+		var exp, got Primitive
+		require.NoError(t, exp.Unmarshal(b))
+		require.NoError(t, got.UnmarshalShallow(b))
+		if len(exp.Value) == 0 {
+			exp.Value = nil
+		}
+		require.Equal(t, exp, got)
+	}
+	var p Primitive
+	require.Error(t, p.UnmarshalShallow([]byte{0x08}))
+	require.Error(t, p.UnmarshalShallow([]byte{0x42, 0x05, 'a'}))
+}
@@ -26,7 +26,9 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/quad"
 )
 
@@ -156,6 +158,7 @@ func (d Options) BoolKey(key string, def bool) (bool, error) {
 var (
 	ErrDatabaseExists = errors.New("quadstore: cannot init; database already exists")
 	ErrNotInitialized = errors.New("quadstore: not initialized")
+	ErrCannotBulkLoad = errors.New("quadstore: cannot bulk load; database is not empty")
 )
 
 type BulkLoader interface {
@@ -164,3 +167,91 @@ type BulkLoader interface {
 	// you cannot load in bulk to a non-empty database, and the db is non-empty.
 	BulkLoad(quad.Reader) error
 }
+
+// Snapshot is a read-only view of the quad store pinned to a consistent state.
+type Snapshot interface {
+	QuadStore
+	// Horizon returns the last ID that was assigned by the quad store when the snapshot was taken.
+	Horizon() int64
+}
+
+// Snapshotter is an optional interface for quad stores that can pin reads to a snapshot.
+type Snapshotter interface {
+	// Snapshot returns a view of the quad store that does not observe writes made after it was taken.
+	// Closing the snapshot releases its resources, but does not close the quad store itself.
+	Snapshot() (Snapshot, error)
+}
+
+// TimeTraveler is an optional interface for quad stores that keep the history of changes
+// and can answer queries against a past state of the graph.
+type TimeTraveler interface {
+	// AsOf returns a read-only view of the graph as it was when a given horizon was reached.
+	AsOf(horizon int64) (Snapshot, error)
+	// HorizonAt returns the horizon of the last write committed at or before a given time.
+	HorizonAt(t time.Time) (int64, error)
+}
+
+// LoggedDelta is a delta recorded in the history of the quad store.
+type LoggedDelta struct {
+	Delta
+	// Horizon is the horizon of the transaction that applied the delta.
+	Horizon int64
+	// Time is the commit time of the transaction. It's zero if it was not recorded.
+	Time time.Time
+}
+
+// ChangeLog is an optional interface for quad stores that can list changes between two horizons.
+type ChangeLog interface {
+	// Changes calls fn for each delta applied after horizon from and at or before horizon to,
+	// in the order they were applied.
+	//
+	// Applying all deltas up to the horizon of any transaction to the state at horizon from
+	// gives the state of the graph at that transaction.
+	Changes(ctx context.Context, from, to int64, fn func(LoggedDelta) error) error
+}
+
+// SubstringIndexer is an optional interface for quad stores that index substrings of node values.
+type SubstringIndexer interface {
+	// NodesWithSubstrings returns an iterator over nodes with string values that contain all given substrings.
+	// The iterator may include other nodes as well, but must include all matching ones.
+	//
+	// It returns false if the index cannot be used for these substrings.
+	NodesWithSubstrings(ctx context.Context, subs ...string) (Iterator, bool)
+}
+
+// PrefixIndexer is an optional interface for quad stores that keep a sorted index of string literals.
+type PrefixIndexer interface {
+	// NodesWithPrefix returns an iterator over string literals that start with a given prefix, ignoring case.
+	// Nodes are returned in the lexical order of their lowercase text, up to a given limit, unless it's zero.
+	// The iterator may include other nodes as well, thus results must still be checked.
+	//
+	// It returns false if the index cannot be used.
+	NodesWithPrefix(ctx context.Context, prefix string, limit int) (Iterator, bool)
+}
+
+// MetadataStore is an optional interface for quad stores that persist arbitrary metadata along with the data.
+type MetadataStore interface {
+	// Metadata returns a value of a metadata key, or nil if it's not set.
+	Metadata(ctx context.Context, key string) ([]byte, error)
+	// SetMetadata sets a value of a metadata key.
+	SetMetadata(ctx context.Context, key string, val []byte) error
+}
+
+// GeoIndexer is an optional interface for quad stores that index point values by their location.
+type GeoIndexer interface {
+	// NodesInRegion returns an iterator over point values that are inside the bounds of the region.
+	// The iterator may include other nodes as well, thus points must still be checked against the region.
+	//
+	// It returns false if the index cannot be used.
+	NodesInRegion(ctx context.Context, r geo.Region) (Iterator, bool)
+}
+
+// TimeIndexer is an optional interface for quad stores that index quads by time values of their objects.
+type TimeIndexer interface {
+	// QuadsInTimeRange returns an iterator over quads with a given predicate and a time object in the range
+	// from..to, inclusive. Zero times leave the range open. The iterator may include other quads as well,
+	// thus objects must still be checked.
+	//
+	// It returns false if the index cannot be used for the predicate.
+	QuadsInTimeRange(ctx context.Context, pred quad.Value, from, to time.Time) (Iterator, bool)
+}
@@ -82,8 +82,30 @@ var (
 	ErrQuadNotExist  = errors.New("quad does not exist")
 	ErrInvalidAction = errors.New("invalid action")
 	ErrNodeNotExists = errors.New("node does not exist")
+
+	ErrPreconditionFailed        = errors.New("precondition failed")
+	ErrPreconditionsNotSupported = errors.New("quad store does not support transaction preconditions")
 )
 
+// PreconditionError records a precondition of the transaction that was not satisfied.
+type PreconditionError struct {
+	Precondition Precondition
+}
+
+func (e *PreconditionError) Error() string {
+	return ErrPreconditionFailed.Error() + ": " + e.Precondition.String()
+}
+
+// IsPreconditionFailed returns whether an error is a PreconditionError
+// or is equal to ErrPreconditionFailed.
+func IsPreconditionFailed(err error) bool {
+	if err == ErrPreconditionFailed {
+		return true
+	}
+	_, ok := err.(*PreconditionError)
+	return ok
+}
+
 // DeltaError records an error and the delta that caused it.
 type DeltaError struct {
 	Delta Delta
@@ -0,0 +1,144 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+var _ graph.Wrapper = (*QuadStore)(nil)
+
+// QuadStore rejects writes to the underlying quad store that exceed the limits.
+type QuadStore struct {
+	graph.QuadStore
+	l *Limits
+
+	mu      sync.Mutex
+	charged map[*limiter]bool // write rate limits already charged by the request
+}
+
+// Wrap returns a quad store that enforces the limits on writes. If limits are nil, the quad store is returned as is.
+//
+// The quad store is meant to be used for a single request: its writes take a single token of each write rate limit,
+// thus a request that is written in multiple batches is not rejected after the first one is accepted.
+func (l *Limits) Wrap(qs graph.QuadStore) graph.QuadStore {
+	if l == nil {
+		return qs
+	}
+	return &QuadStore{QuadStore: qs, l: l, charged: make(map[*limiter]bool)}
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf rejects all deltas with ExceededError if the write rate of the database or of one of the changed
+// graphs is exceeded, or if added quads don't fit into them.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	if err := qs.check(deltas); err != nil {
+		return err
+	}
+	if len(conds) == 0 {
+		return qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		return ca.ApplyDeltasIf(conds, deltas, opts)
+	}
+	return graph.ErrPreconditionsNotSupported
+}
+
+func (qs *QuadStore) check(deltas []graph.Delta) error {
+	// net number of added quads, in total and in each graph with a quota
+	var total int64
+	graphs := make(map[*limiter]int64)
+	for _, d := range deltas {
+		n := int64(1)
+		if d.Action == graph.Delete {
+			n = -1
+		}
+		total += n
+		if g := qs.l.graph(d.Quad.Label); g != nil {
+			graphs[g] += n
+		}
+	}
+	if max := qs.l.db.q.MaxQuads; max > 0 && total > 0 && qs.count(nil)+total > max {
+		return qs.l.db.exceeded("quads", float64(max))
+	}
+	for g, n := range graphs {
+		if max := g.q.MaxQuads; max > 0 && n > 0 && qs.count(g.label)+n > max {
+			return g.exceeded("quads", float64(max))
+		}
+	}
+	return qs.charge(graphs)
+}
+
+// charge takes a token of the write rate of the database and of each changed graph, unless the request
+// already took it. Tokens are returned if any of the limits is exceeded.
+func (qs *QuadStore) charge(graphs map[*limiter]int64) error {
+	now := time.Now()
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	lims := make([]*limiter, 0, len(graphs)+1)
+	lims = append(lims, qs.l.db)
+	for g := range graphs {
+		lims = append(lims, g)
+	}
+	var taken []*limiter
+	for _, l := range lims {
+		if qs.charged[l] {
+			continue
+		}
+		if err := l.write(now); err != nil {
+			for _, t := range taken {
+				t.unwrite()
+			}
+			return err
+		}
+		taken = append(taken, l)
+	}
+	for _, l := range taken {
+		qs.charged[l] = true
+	}
+	return nil
+}
+
+// count returns the number of quads in a graph, or in the whole database if the label is nil.
+func (qs *QuadStore) count(label quad.Value) int64 {
+	var it graph.Iterator
+	if label == nil {
+		it = qs.QuadStore.QuadsAllIterator()
+	} else if ref := qs.QuadStore.ValueOf(label); ref != nil {
+		it = qs.QuadStore.QuadIterator(quad.Label, ref)
+	} else {
+		return 0
+	}
+	defer it.Close()
+	sz, _ := it.Size()
+	return sz
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// WrapSnapshot returns a snapshot of the underlying quad store as is, since limits only apply to writes.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return s, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quota limits the size of graphs, the rate of writes and the number of concurrent queries.
+//
+// Limits are set for the whole database and for specific named graphs. A quad store wrapped with Limits
+// rejects writes that exceed them, and queries acquire a slot with BeginQuery before they run.
+package quota
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Quota is a set of limits. Zero values are not limited.
+type Quota struct {
+	// MaxQuads is the maximal number of quads. It's checked against statistics of the backend, which might be estimates.
+	MaxQuads int64 `json:"max_quads,omitempty"`
+	// MaxWriteQPS is the maximal number of writes per second. Each request is a single write,
+	// even if its quads are written in multiple batches.
+	MaxWriteQPS float64 `json:"max_write_qps,omitempty"`
+	// MaxQueries is the maximal number of concurrent queries.
+	MaxQueries int `json:"max_queries,omitempty"`
+}
+
+// GraphQuota is a quota of a named graph.
+type GraphQuota struct {
+	// Graph is a label of the graph, parsed with quad.StringToValue.
+	Graph string `json:"graph"`
+	Quota
+}
+
+// Config is a quota of the database and quotas of its named graphs.
+type Config struct {
+	Quota
+	Graphs []GraphQuota `json:"graphs,omitempty"`
+}
+
+// ExceededError is returned when a write or a query exceeds a limit.
+type ExceededError struct {
+	// Graph is a label of the graph, or nil if the limit of the database is exceeded.
+	Graph quad.Value
+	// Limit is the name of the exceeded limit: "quads", "write rate" or "queries".
+	Limit string
+	Max   float64
+}
+
+func (e *ExceededError) Error() string {
+	of := "the database"
+	if e.Graph != nil {
+		of = "graph " + e.Graph.String()
+	}
+	return fmt.Sprintf("quota: %s limit of %s exceeded (max %s)", e.Limit, of, strconv.FormatFloat(e.Max, 'f', -1, 64))
+}
+
+// Temporary is set for limits that are not exceeded once other requests complete.
+func (e *ExceededError) Temporary() bool {
+	return e.Limit != "quads"
+}
+
+// IsExceeded checks if an error is caused by an exceeded limit.
+func IsExceeded(err error) bool {
+	_, ok := err.(*ExceededError)
+	return ok
+}
+
+type limiter struct {
+	label quad.Value
+	q     Quota
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	running int
+}
+
+func newLimiter(label quad.Value, q Quota) *limiter {
+	return &limiter{label: label, q: q, tokens: burst(q.MaxWriteQPS)}
+}
+
+// burst is the number of writes allowed at once, after a pause.
+func burst(qps float64) float64 {
+	if qps < 1 {
+		return 1
+	}
+	return qps
+}
+
+func (l *limiter) exceeded(limit string, max float64) error {
+	return &ExceededError{Graph: l.label, Limit: limit, Max: max}
+}
+
+// write takes a token of the write rate limit.
+func (l *limiter) write(now time.Time) error {
+	if l.q.MaxWriteQPS <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.q.MaxWriteQPS
+		if max := burst(l.q.MaxWriteQPS); l.tokens > max {
+			l.tokens = max
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return l.exceeded("write rate", l.q.MaxWriteQPS)
+	}
+	l.tokens--
+	return nil
+}
+
+// unwrite returns a token taken by write.
+func (l *limiter) unwrite() {
+	if l.q.MaxWriteQPS <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+	if max := burst(l.q.MaxWriteQPS); l.tokens > max {
+		l.tokens = max
+	}
+}
+
+func (l *limiter) beginQuery() error {
+	if l.q.MaxQueries <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running >= l.q.MaxQueries {
+		return l.exceeded("queries", float64(l.q.MaxQueries))
+	}
+	l.running++
+	return nil
+}
+
+func (l *limiter) endQuery() {
+	if l.q.MaxQueries <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+}
+
+// Limits enforces quotas of a database. A nil value doesn't limit anything.
+type Limits struct {
+	db     *limiter
+	graphs map[string]*limiter
+}
+
+// New creates limits from a config.
+func New(c Config) (*Limits, error) {
+	l := &Limits{db: newLimiter(nil, c.Quota), graphs: make(map[string]*limiter, len(c.Graphs))}
+	for _, g := range c.Graphs {
+		if g.Graph == "" {
+			return nil, fmt.Errorf("quota: graph is not set")
+		}
+		label := quad.StringToValue(g.Graph)
+		if _, ok := l.graphs[label.String()]; ok {
+			return nil, fmt.Errorf("quota: duplicate quota of graph %v", label)
+		}
+		l.graphs[label.String()] = newLimiter(label, g.Quota)
+	}
+	return l, nil
+}
+
+func (l *Limits) graph(label quad.Value) *limiter {
+	if label == nil {
+		return nil
+	}
+	return l.graphs[label.String()]
+}
+
+// BeginQuery takes a slot of concurrent queries of the database and of a graph, if it's not nil.
+// The returned function must be called when the query completes.
+func (l *Limits) BeginQuery(label quad.Value) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if err := l.db.beginQuery(); err != nil {
+		return nil, err
+	}
+	g := l.graph(label)
+	if g == nil {
+		return l.db.endQuery, nil
+	}
+	if err := g.beginQuery(); err != nil {
+		l.db.endQuery()
+		return nil, err
+	}
+	return func() {
+		g.endQuery()
+		l.db.endQuery()
+	}, nil
+}
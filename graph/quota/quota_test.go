@@ -0,0 +1,130 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func add(qs graph.QuadStore, quads ...quad.Quad) error {
+	deltas := make([]graph.Delta, 0, len(quads))
+	for _, q := range quads {
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+	}
+	return qs.ApplyDeltas(deltas, graph.IgnoreOpts{})
+}
+
+func TestMaxQuads(t *testing.T) {
+	l, err := New(Config{
+		Quota:  Quota{MaxQuads: 4},
+		Graphs: []GraphQuota{{Graph: "<g1>", Quota: Quota{MaxQuads: 2}}},
+	})
+	require.NoError(t, err)
+	base := memstore.New(quad.MakeIRI("a", "knows", "b", "g1"))
+	qs := l.Wrap(base)
+
+	require.NoError(t, add(qs, quad.MakeIRI("a", "knows", "c", "g1")))
+	err = add(qs, quad.MakeIRI("a", "knows", "d", "g1"))
+	require.True(t, IsExceeded(err), "%v", err)
+	require.Equal(t, quad.IRI("g1"), err.(*ExceededError).Graph)
+	require.False(t, err.(*ExceededError).Temporary())
+
+	require.NoError(t, add(qs, quad.MakeIRI("a", "knows", "d", "g2"), quad.MakeIRI("a", "knows", "e", "")))
+	err = add(qs, quad.MakeIRI("a", "knows", "f", "g2"))
+	require.True(t, IsExceeded(err), "%v", err)
+	require.Nil(t, err.(*ExceededError).Graph)
+
+	// deletes are always allowed
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: quad.MakeIRI("a", "knows", "e", ""), Action: graph.Delete}}, graph.IgnoreOpts{}))
+	require.NoError(t, add(qs, quad.MakeIRI("a", "knows", "f", "g2")))
+}
+
+func TestWriteRate(t *testing.T) {
+	l, err := New(Config{Graphs: []GraphQuota{{Graph: "<g1>", Quota: Quota{MaxWriteQPS: 2}}}})
+	require.NoError(t, err)
+	lim := l.graph(quad.IRI("g1"))
+	now := time.Now()
+	require.NoError(t, lim.write(now))
+	require.NoError(t, lim.write(now))
+	err = lim.write(now)
+	require.True(t, IsExceeded(err), "%v", err)
+	require.True(t, err.(*ExceededError).Temporary())
+	require.NoError(t, lim.write(now.Add(time.Second/2)))
+
+	// writes to graphs without a quota are not limited
+	qs := l.Wrap(memstore.New())
+	for i := 0; i < 5; i++ {
+		require.NoError(t, add(qs, quad.Make("a", "b", i, "g2")))
+	}
+}
+
+func TestWriteRateRequest(t *testing.T) {
+	l, err := New(Config{
+		Quota:  Quota{MaxWriteQPS: 0.001},
+		Graphs: []GraphQuota{{Graph: "<g1>", Quota: Quota{MaxWriteQPS: 0.001}}},
+	})
+	require.NoError(t, err)
+	base := memstore.New()
+
+	// exceeded limit of the graph doesn't take the token of the database
+	require.NoError(t, l.graph(quad.IRI("g1")).write(time.Now()))
+	err = add(l.Wrap(base), quad.MakeIRI("a", "b", "c", "g1"))
+	require.True(t, IsExceeded(err), "%v", err)
+	require.Equal(t, quad.IRI("g1"), err.(*ExceededError).Graph)
+
+	// all batches of a request take a single token
+	qs := l.Wrap(base)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, add(qs, quad.Make("a", "b", i, "g2")))
+	}
+	err = add(l.Wrap(base), quad.MakeIRI("a", "b", "c", "g2"))
+	require.True(t, IsExceeded(err), "%v", err)
+	require.Nil(t, err.(*ExceededError).Graph)
+}
+
+func TestQueries(t *testing.T) {
+	l, err := New(Config{
+		Quota:  Quota{MaxQueries: 2},
+		Graphs: []GraphQuota{{Graph: "<g1>", Quota: Quota{MaxQueries: 1}}},
+	})
+	require.NoError(t, err)
+	done1, err := l.BeginQuery(quad.IRI("g1"))
+	require.NoError(t, err)
+	_, err = l.BeginQuery(quad.IRI("g1"))
+	require.True(t, IsExceeded(err), "%v", err)
+	done2, err := l.BeginQuery(nil)
+	require.NoError(t, err)
+	_, err = l.BeginQuery(nil)
+	require.True(t, IsExceeded(err), "%v", err)
+	done1()
+	done2()
+	done, err := l.BeginQuery(quad.IRI("g1"))
+	require.NoError(t, err)
+	done()
+
+	_, err = New(Config{Graphs: []GraphQuota{{Graph: "<g1>"}, {Graph: "<g1>"}}})
+	require.Error(t, err)
+	var none *Limits
+	done, err = none.BeginQuery(nil)
+	require.NoError(t, err)
+	done()
+}
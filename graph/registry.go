@@ -31,9 +31,14 @@ type NewStoreFunc func(string, Options) (QuadStore, error)
 type InitStoreFunc func(string, Options) error
 type UpgradeStoreFunc func(string, Options) error
 
+// VersionStoreFunc returns the version of the data format of a database and the latest version
+// supported by the quad store.
+type VersionStoreFunc func(string, Options) (cur, latest int64, err error)
+
 type QuadStoreRegistration struct {
 	NewFunc      NewStoreFunc
 	UpgradeFunc  UpgradeStoreFunc
+	VersionFunc  VersionStoreFunc
 	InitFunc     InitStoreFunc
 	IsPersistent bool
 }
@@ -81,6 +86,18 @@ func UpgradeQuadStore(name string, dbpath string, opts Options) error {
 	return r.UpgradeFunc(dbpath, opts)
 }
 
+// QuadStoreVersion returns the version of the data format of a database and the latest version
+// supported by the quad store. It returns ErrOperationNotSupported if the quad store does not track versions.
+func QuadStoreVersion(name string, dbpath string, opts Options) (cur, latest int64, err error) {
+	r, registered := storeRegistry[name]
+	if !registered {
+		return 0, 0, ErrQuadStoreNotRegistred
+	} else if r.VersionFunc == nil {
+		return 0, 0, ErrOperationNotSupported
+	}
+	return r.VersionFunc(dbpath, opts)
+}
+
 func IsRegistered(name string) bool {
 	_, ok := storeRegistry[name]
 	return ok
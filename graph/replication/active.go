@@ -0,0 +1,273 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultConflictWindow is the default time the last change of each quad is remembered for conflict resolution.
+const DefaultConflictWindow = time.Hour
+
+// Version is the last known change of a quad.
+type Version struct {
+	Clock HLC
+	// Origin is the id of the feed of the server that made the change.
+	Origin string
+	// Seq is the sequence number of the batch of the change in the origin feed.
+	Seq    uint64
+	Action graph.Procedure
+}
+
+// Conflict is a change of a quad received from the peer, while the last known change
+// of the same quad was made locally and the peer made its change before it applied the local one.
+type Conflict struct {
+	Quad   quad.Quad
+	Local  Version
+	Remote Version
+}
+
+// Resolver decides if the remote change wins a conflict and must be applied.
+//
+// Remote changes made after the local one was replicated to the peer are applied without a resolver.
+// Peers that don't report the position of the local feed they applied (see Batch.Seen) can't be told apart;
+// changes they made after the local one always have a larger clock.
+type Resolver func(c Conflict) bool
+
+// LastWriterWins applies the change with the larger clock. Ties are broken by the origin,
+// so both servers pick the same change.
+func LastWriterWins(c Conflict) bool {
+	if c.Local.Clock == c.Remote.Clock {
+		return c.Remote.Origin > c.Local.Origin
+	}
+	return c.Local.Clock.Before(c.Remote.Clock)
+}
+
+// AddWins resolves concurrent add and remove of a quad in favor of the add,
+// and falls back to LastWriterWins otherwise.
+func AddWins(c Conflict) bool {
+	if c.Local.Action != c.Remote.Action {
+		return c.Remote.Action == graph.Add
+	}
+	return LastWriterWins(c)
+}
+
+// DeleteWins resolves concurrent add and remove of a quad in favor of the remove,
+// and falls back to LastWriterWins otherwise.
+func DeleteWins(c Conflict) bool {
+	if c.Local.Action != c.Remote.Action {
+		return c.Remote.Action == graph.Delete
+	}
+	return LastWriterWins(c)
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = map[string]Resolver{
+		"lww":         LastWriterWins,
+		"add-wins":    AddWins,
+		"delete-wins": DeleteWins,
+	}
+)
+
+// RegisterResolver makes a conflict resolver available by name, for example in the configuration file.
+func RegisterResolver(name string, r Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	if _, found := resolvers[name]; found {
+		panic(fmt.Sprintf("already registered conflict resolver %q", name))
+	}
+	resolvers[name] = r
+}
+
+// GetResolver returns a conflict resolver registered with a given name.
+func GetResolver(name string) (Resolver, error) {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	r, ok := resolvers[name]
+	if !ok {
+		return nil, fmt.Errorf("replication: unknown conflict resolver: %q", name)
+	}
+	return r, nil
+}
+
+// Resolvers returns names of all registered conflict resolvers.
+func Resolvers() []string {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	names := make([]string, 0, len(resolvers))
+	for name := range resolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// versions remembers the last change of recently modified quads.
+type versions struct {
+	window time.Duration
+	last   map[string]Version
+	pruned time.Time
+}
+
+func (v *versions) record(deltas []graph.Delta, ver Version) {
+	for _, d := range deltas {
+		ver.Action = d.Action
+		v.last[d.Quad.NQuad()] = ver
+	}
+	v.prune()
+}
+
+// prune periodically forgets changes older than the window; they are not expected to conflict with anything.
+func (v *versions) prune() {
+	now := time.Now()
+	if now.Sub(v.pruned) < v.window/4 {
+		return
+	}
+	v.pruned = now
+	min := now.Add(-v.window).UnixNano()
+	for k, ver := range v.last {
+		if ver.Clock.Wall < min {
+			delete(v.last, k)
+		}
+	}
+}
+
+// remoteResult counts conflicts in a batch received from the peer.
+type remoteResult struct {
+	conflicts int // resolved conflicts
+	discarded int // remote changes that lost
+}
+
+// applyRemote applies a batch received from the peer feed to the underlying quad store,
+// without recording it in this feed. Conflicting deltas are filtered by the resolver.
+func (f *Feed) applyRemote(origin string, b Batch, resolve Resolver) (remoteResult, error) {
+	var res remoteResult
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	clock := b.Clock
+	if clock.IsZero() {
+		// peers without clocks; order by the time the peer applied the batch
+		clock = HLC{Wall: b.Time.UnixNano()}
+	}
+	f.clock = f.clock.merge(time.Now().UnixNano(), clock)
+	// local changes the peer applied before making this batch
+	seen, reported := b.Seen[f.id]
+	deltas := make([]graph.Delta, 0, len(b.Deltas))
+	for _, d := range b.Deltas {
+		remote := Version{Clock: clock, Origin: origin, Seq: b.Seq, Action: d.Action}
+		key := d.Quad.NQuad()
+		if local, ok := f.versions.last[key]; ok && local.Origin != origin &&
+			!(reported && local.Origin == f.id && local.Seq <= seen) {
+			res.conflicts++
+			if !resolve(Conflict{Quad: d.Quad, Local: local, Remote: remote}) {
+				res.discarded++
+				continue
+			}
+		}
+		f.versions.last[key] = remote
+		deltas = append(deltas, d)
+	}
+	f.versions.prune()
+	if len(deltas) != 0 {
+		// remote changes might be already present; for example, quads copied from a snapshot
+		if err := f.QuadStore.ApplyDeltas(deltas, catchUpOpts); err != nil {
+			return res, err
+		}
+	}
+	if f.seen == nil {
+		f.seen = make(map[string]uint64)
+	}
+	f.seen[origin] = b.Seq
+	return res, nil
+}
+
+// mergeRemote adds quads copied from the peer to the underlying quad store, unless the quad
+// was changed recently. Quads are never removed, thus local changes are preserved.
+func (f *Feed) mergeRemote(quads []quad.Quad) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deltas := make([]graph.Delta, 0, len(quads))
+	for _, q := range quads {
+		if _, ok := f.versions.last[q.NQuad()]; ok {
+			continue
+		}
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	return f.QuadStore.ApplyDeltas(deltas, catchUpOpts)
+}
+
+// mergeWriter adds quads copied from the peer with Feed.mergeRemote.
+type mergeWriter struct {
+	f *Feed
+}
+
+func (w mergeWriter) WriteQuad(q quad.Quad) error {
+	return w.f.mergeRemote([]quad.Quad{q})
+}
+
+func (w mergeWriter) WriteQuads(buf []quad.Quad) (int, error) {
+	if err := w.f.mergeRemote(buf); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+// PeerOptions configures active-active replication.
+type PeerOptions struct {
+	ReplicaOptions
+	// Resolver decides conflicts between local and remote changes. Default is LastWriterWins.
+	Resolver Resolver
+	// Window is the time the last change of each quad is remembered. Conflicting changes
+	// must reach the peer within this time to be detected. Default is DefaultConflictWindow.
+	Window time.Duration
+}
+
+// NewPeer creates a replica that follows the feed of a peer server at a given base URL,
+// while the local server accepts writes as well. The peer is expected to follow the local
+// feed in the same way.
+//
+// Changes received from the peer are applied to the quad store under the local feed,
+// so they are not sent back to the peer. On the first connection (or if the feed of the peer
+// was restarted or has no batches the replica needs) quads of the peer are added locally,
+// and all batches still kept in the feed of the peer are replayed. No other quads are removed:
+// deletions made on the peer before its oldest kept batch are not replicated.
+//
+// Only changes made through the feed after NewPeer is called are considered for conflicts.
+func NewPeer(f *Feed, peer string, opts PeerOptions) *Replica {
+	if opts.Resolver == nil {
+		opts.Resolver = LastWriterWins
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultConflictWindow
+	}
+	f.mu.Lock()
+	if f.versions == nil {
+		f.versions = &versions{window: opts.Window, last: make(map[string]Version), pruned: time.Now()}
+	}
+	f.mu.Unlock()
+	r := NewReplica(f.QuadStore, peer, opts.ReplicaOptions)
+	r.local, r.resolve = f, opts.Resolver
+	return r
+}
@@ -0,0 +1,379 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+// DefaultLeaseTTL is the default time after which a lease of a primary that stopped renewing it expires.
+const DefaultLeaseTTL = 10 * time.Second
+
+// ErrStandby is returned for writes to a standby server.
+var ErrStandby = errors.New("replication: server is a standby; writes are accepted by the primary")
+
+// Holder is a server that holds the lease.
+type Holder struct {
+	ID string `json:"id"`
+	// Addr is the base URL of the server; standbys replicate from it.
+	Addr    string    `json:"addr"`
+	Expires time.Time `json:"expires"`
+}
+
+// Lease elects a single primary among the servers that share it.
+//
+// Implementations backed by a coordination service (etcd, Consul) can be plugged in
+// by implementing this interface.
+type Lease interface {
+	// Acquire takes the lease for a given holder, or renews it if the holder already has it.
+	// It returns the current holder, which is a different server if the lease is not expired yet.
+	Acquire(h Holder, ttl time.Duration) (Holder, error)
+	// Release gives up the lease if it's held by a server with a given id.
+	Release(id string) error
+}
+
+// leaseMargin is the part of the lease TTL before the expiration during which the primary already
+// rejects writes. It allows for a clock drift between servers and for delays in renewing the lease.
+const leaseMargin = 4
+
+var errLockTimeout = errors.New("replication: timeout waiting for the lease lock")
+
+var _ Lease = (*FileLease)(nil)
+
+// FileLease is a lease stored in a file on a filesystem shared by all servers.
+//
+// Updates are serialized with an advisory lock (flock) on a lock file next to the lease,
+// thus the filesystem must support file locks and atomic renames (NFSv3 and later, most cluster filesystems).
+// The lock is released by the system if the process that holds it crashes.
+type FileLease struct {
+	path string
+}
+
+// NewFileLease returns a lease stored in a given file.
+func NewFileLease(path string) *FileLease {
+	return &FileLease{path: path}
+}
+
+func (l *FileLease) read() (Holder, error) {
+	var h Holder
+	data, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return h, err
+	}
+	err = json.Unmarshal(data, &h)
+	return h, err
+}
+
+func (l *FileLease) write(h Holder) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(l.path), "."+filepath.Base(l.path)+".tmp")
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func (l *FileLease) Acquire(h Holder, ttl time.Duration) (Holder, error) {
+	unlock, err := l.lock()
+	if err != nil {
+		return Holder{}, err
+	}
+	defer unlock()
+	cur, err := l.read()
+	if err != nil {
+		return Holder{}, err
+	}
+	now := time.Now()
+	if cur.ID != "" && cur.ID != h.ID && now.Before(cur.Expires) {
+		return cur, nil
+	}
+	h.Expires = now.Add(ttl)
+	if err = l.write(h); err != nil {
+		return Holder{}, err
+	}
+	return h, nil
+}
+
+func (l *FileLease) Release(id string) error {
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	cur, err := l.read()
+	if err != nil || cur.ID != id {
+		return err
+	}
+	return os.Remove(l.path)
+}
+
+// FailoverOptions configures a server that takes part in the election of the primary.
+type FailoverOptions struct {
+	ReplicaOptions
+	// ID is a unique id of the server. A random one is generated by default.
+	ID string
+	// Addr is the base URL other servers can replicate from.
+	Addr string
+	// TTL is the time the primary holds the lease without renewing it. Default is DefaultLeaseTTL.
+	TTL time.Duration
+}
+
+// FailoverStatus is the role of the server.
+type FailoverStatus struct {
+	ID      string         `json:"id"`
+	Primary bool           `json:"primary"`
+	Holder  Holder         `json:"holder"`
+	Replica *ReplicaStatus `json:"replica,omitempty"`
+}
+
+// Failover keeps one writable server among the servers sharing a lease. The server holding
+// the lease is the primary; others are standbys that replicate from it and reject writes.
+// If the primary stops renewing the lease, one of the standbys takes it and becomes the primary.
+//
+// Replication is asynchronous, thus changes that have not reached the standby before
+// it was promoted are lost. A former primary that rejoins copies all quads from the new one.
+// The first server that joins the election while the lease is free becomes the primary,
+// so the server with the most recent data should be started first.
+type Failover struct {
+	qs    graph.QuadStore // written by the replica directly
+	lease Lease
+	opts  FailoverOptions
+
+	mu       sync.Mutex
+	primary  bool
+	holder   Holder
+	deadline time.Time // time after which the lease might be taken by another server
+	replica  *Replica
+	stop     func()
+	done     chan struct{}
+}
+
+// NewFailover creates a member of the election. Changes from the primary are applied to qs;
+// it should be a store below the replication feed, if any. Use Guard to reject writes of
+// clients while the server is a standby.
+func NewFailover(qs graph.QuadStore, l Lease, opts FailoverOptions) (*Failover, error) {
+	if opts.ID == "" {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		opts.ID = hex.EncodeToString(b[:])
+	}
+	if opts.Addr == "" {
+		return nil, errors.New("replication: address of the server must be set for failover")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultLeaseTTL
+	}
+	return &Failover{qs: qs, lease: l, opts: opts}, nil
+}
+
+// IsPrimary reports if the server holds the lease.
+//
+// It returns false as soon as the lease is about to expire, even if the server did not
+// manage to check the lease since then.
+func (f *Failover) IsPrimary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.isPrimary(time.Now())
+}
+
+func (f *Failover) isPrimary(now time.Time) bool {
+	return f.primary && now.Before(f.deadline)
+}
+
+// Status returns the role of the server.
+func (f *Failover) Status() FailoverStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := FailoverStatus{ID: f.opts.ID, Primary: f.isPrimary(time.Now()), Holder: f.holder}
+	if f.replica != nil {
+		rs := f.replica.Status()
+		st.Replica = &rs
+	}
+	return st
+}
+
+// Run takes part in the election until the context is cancelled. The lease is released on exit.
+func (f *Failover) Run(ctx context.Context) error {
+	defer func() {
+		f.mu.Lock()
+		f.primary = false
+		f.mu.Unlock()
+		f.stopReplica()
+		if err := f.lease.Release(f.opts.ID); err != nil {
+			failoverLogger.Warning("cannot release the lease", clog.F("error", err))
+		}
+	}()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		f.elect(ctx)
+		// check the lease three times per TTL, and when it is about to expire
+		wait := f.opts.TTL / 3
+		f.mu.Lock()
+		if f.primary {
+			if d := time.Until(f.deadline); d < wait {
+				wait = d
+			}
+		}
+		f.mu.Unlock()
+		if wait < 0 {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// elect tries to acquire the lease and switches the role of the server accordingly.
+func (f *Failover) elect(ctx context.Context) {
+	self := Holder{ID: f.opts.ID, Addr: f.opts.Addr}
+	// the lease expires no earlier than TTL after the request is sent
+	deadline := time.Now().Add(f.opts.TTL - f.opts.TTL/leaseMargin)
+	h, err := f.lease.Acquire(self, f.opts.TTL)
+	f.mu.Lock()
+	primary := f.primary
+	f.mu.Unlock()
+	if err != nil {
+		failoverLogger.Warning("lease check failed", clog.F("error", err))
+		f.mu.Lock()
+		expired := primary && !f.isPrimary(time.Now())
+		if expired {
+			// the lease might be taken by another server soon; writes are already rejected by isPrimary
+			f.primary = false
+		}
+		f.mu.Unlock()
+		if expired {
+			failoverLogger.Warning("cannot renew the lease; switching to standby")
+		}
+		return
+	}
+	if h.ID == self.ID {
+		f.mu.Lock()
+		f.holder, f.deadline = h, deadline
+		f.mu.Unlock()
+		if !primary {
+			// stop applying changes of the old primary before accepting writes
+			f.stopReplica()
+			f.mu.Lock()
+			f.primary = true
+			f.mu.Unlock()
+			failoverLogger.Info("promoted to primary")
+		}
+		return
+	}
+	f.mu.Lock()
+	f.holder, f.primary = h, false
+	following := f.replica != nil && f.replica.primary == strings.TrimSuffix(h.Addr, "/")
+	f.mu.Unlock()
+	if primary {
+		failoverLogger.Warning("lease is held by another node; switching to standby", clog.F("holder", h.ID))
+	}
+	if !following {
+		f.stopReplica()
+		f.startReplica(ctx, h.Addr)
+	}
+}
+
+func (f *Failover) startReplica(ctx context.Context, addr string) {
+	r := NewReplica(f.qs, addr, f.opts.ReplicaOptions)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	f.mu.Lock()
+	f.replica, f.stop, f.done = r, cancel, done
+	f.mu.Unlock()
+	failoverLogger.Info("standby", clog.F("primary", addr))
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+}
+
+func (f *Failover) stopReplica() {
+	f.mu.Lock()
+	stop, done := f.stop, f.done
+	f.replica, f.stop, f.done = nil, nil, nil
+	f.mu.Unlock()
+	if stop != nil {
+		stop()
+		<-done
+	}
+}
+
+// Guard wraps a quad store to reject writes while the server is a standby.
+func (f *Failover) Guard(qs graph.QuadStore) graph.QuadStore {
+	return &guard{QuadStore: qs, f: f}
+}
+
+var (
+	_ graph.ConditionalApplier = (*guard)(nil)
+	_ graph.Wrapper            = (*guard)(nil)
+)
+
+// guard rejects writes on standby servers.
+// Optional interfaces of the underlying quad store (except for conditional writes) are not exposed.
+type guard struct {
+	graph.QuadStore
+	f *Failover
+}
+
+// Unwrapped returns the underlying quad store.
+func (g *guard) Unwrapped() graph.QuadStore {
+	return g.QuadStore
+}
+
+// WrapSnapshot returns a snapshot of the underlying quad store as is, since the guard only rejects writes.
+func (g *guard) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return s, nil
+}
+
+func (g *guard) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return g.ApplyDeltasIf(nil, deltas, opts)
+}
+
+func (g *guard) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	if !g.f.IsPrimary() {
+		return ErrStandby
+	}
+	if len(conds) == 0 {
+		return g.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := g.QuadStore.(graph.ConditionalApplier); ok {
+		return ca.ApplyDeltasIf(conds, deltas, opts)
+	}
+	return graph.ErrPreconditionsNotSupported
+}
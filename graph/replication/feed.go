@@ -0,0 +1,264 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replication implements asynchronous primary/replica replication.
+//
+// The primary keeps recent batches of deltas in a Feed, and replicas poll the feed over HTTP
+// and apply batches to their local quad stores. A replica that is too far behind (or connects
+// to a restarted primary) copies all quads from the primary and catches up from the feed.
+//
+// In active-active mode two servers follow each other's feeds, and changes of the same quad
+// made concurrently on both servers are resolved by a Resolver; see NewPeer.
+package replication
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad/nquads"
+)
+
+// DefaultFeedSize is the default number of recent batches kept by the feed.
+const DefaultFeedSize = 10000
+
+var (
+	// ErrFeedGone is returned if the feed no longer has batches requested by the replica.
+	ErrFeedGone = errors.New("replication: batches are no longer available in the feed")
+	// ErrUnknownFeed is returned if the replica asks for a feed of a different primary
+	// (or the same primary before a restart).
+	ErrUnknownFeed = errors.New("replication: unknown feed")
+)
+
+// Batch is a set of deltas applied by the primary in a single write.
+type Batch struct {
+	Seq    uint64
+	Time   time.Time
+	Clock  HLC
+	Deltas []graph.Delta
+	// Seen is the sequence number of the last batch applied from each peer feed before this batch was written.
+	// Changes of the peer up to that batch are not concurrent with the ones in this batch.
+	Seen map[string]uint64
+}
+
+type jsonDelta struct {
+	Action string `json:"action"`
+	Quad   string `json:"quad"`
+}
+
+type jsonBatch struct {
+	Seq    uint64            `json:"seq"`
+	Time   time.Time         `json:"time"`
+	Clock  HLC               `json:"clock"`
+	Deltas []jsonDelta       `json:"deltas"`
+	Seen   map[string]uint64 `json:"seen,omitempty"`
+}
+
+// MarshalJSON encodes quads of the batch in N-Quads format.
+func (b Batch) MarshalJSON() ([]byte, error) {
+	jb := jsonBatch{Seq: b.Seq, Time: b.Time, Clock: b.Clock, Seen: b.Seen, Deltas: make([]jsonDelta, 0, len(b.Deltas))}
+	for _, d := range b.Deltas {
+		act := "add"
+		if d.Action == graph.Delete {
+			act = "delete"
+		}
+		jb.Deltas = append(jb.Deltas, jsonDelta{Action: act, Quad: d.Quad.NQuad()})
+	}
+	return json.Marshal(jb)
+}
+
+func (b *Batch) UnmarshalJSON(data []byte) error {
+	var jb jsonBatch
+	if err := json.Unmarshal(data, &jb); err != nil {
+		return err
+	}
+	*b = Batch{Seq: jb.Seq, Time: jb.Time, Clock: jb.Clock, Seen: jb.Seen, Deltas: make([]graph.Delta, 0, len(jb.Deltas))}
+	for _, jd := range jb.Deltas {
+		var d graph.Delta
+		switch jd.Action {
+		case "add":
+			d.Action = graph.Add
+		case "delete":
+			d.Action = graph.Delete
+		default:
+			return fmt.Errorf("replication: unknown action: %q", jd.Action)
+		}
+		q, err := nquads.Parse(jd.Quad)
+		if err != nil {
+			return err
+		}
+		d.Quad = q
+		b.Deltas = append(b.Deltas, d)
+	}
+	return nil
+}
+
+var _ graph.ConditionalApplier = (*Feed)(nil)
+var _ graph.Wrapper = (*Feed)(nil)
+
+// Feed keeps recent batches applied to the quad store; at least the configured number of them.
+//
+// Batches are applied one at a time to keep the same order in the feed and in the quad store.
+// Optional interfaces of the underlying quad store (except for conditional writes) are not exposed,
+// but snapshots and the history can be found with graph.SnapshotOf and graph.AsOf.
+type Feed struct {
+	graph.QuadStore
+	id   string
+	size int
+
+	mu       sync.Mutex
+	seq      uint64 // last assigned sequence number
+	clock    HLC    // last assigned timestamp
+	batches  []Batch
+	changed  chan struct{}
+	versions *versions         // only tracked in active-active mode
+	seen     map[string]uint64 // last batch applied from each peer feed
+}
+
+// NewFeed wraps a quad store to record batches applied to it. The feed gets a random id,
+// so replicas can detect that the primary was restarted.
+func NewFeed(qs graph.QuadStore, size int) (*Feed, error) {
+	if size <= 0 {
+		size = DefaultFeedSize
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	return &Feed{
+		QuadStore: qs,
+		id:        hex.EncodeToString(b[:]),
+		size:      size,
+		changed:   make(chan struct{}),
+	}, nil
+}
+
+// ID returns a unique id of the feed.
+func (f *Feed) ID() string {
+	return f.id
+}
+
+// Seq returns the sequence number of the last batch.
+func (f *Feed) Seq() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seq
+}
+
+func (f *Feed) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return f.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf applies deltas to the underlying quad store and appends them to the feed.
+func (f *Feed) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	if len(conds) == 0 {
+		err = f.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := f.QuadStore.(graph.ConditionalApplier); ok {
+		err = ca.ApplyDeltasIf(conds, deltas, opts)
+	} else {
+		err = graph.ErrPreconditionsNotSupported
+	}
+	if err != nil || len(deltas) == 0 {
+		return err
+	}
+	f.seq++
+	f.clock = f.clock.next(time.Now().UnixNano())
+	if f.versions != nil {
+		f.versions.record(deltas, Version{Clock: f.clock, Origin: f.id, Seq: f.seq})
+	}
+	if len(f.batches) >= 2*f.size {
+		// amortize the cost of removing old batches
+		n := copy(f.batches, f.batches[len(f.batches)-f.size:])
+		for i := n; i < len(f.batches); i++ {
+			f.batches[i] = Batch{}
+		}
+		f.batches = f.batches[:n]
+	}
+	// callers may reuse the slice after the write
+	deltas = append([]graph.Delta(nil), deltas...)
+	var seen map[string]uint64
+	if len(f.seen) != 0 {
+		seen = make(map[string]uint64, len(f.seen))
+		for id, seq := range f.seen {
+			seen[id] = seq
+		}
+	}
+	f.batches = append(f.batches, Batch{Seq: f.seq, Time: time.Now(), Clock: f.clock, Deltas: deltas, Seen: seen})
+	close(f.changed)
+	f.changed = make(chan struct{})
+	return nil
+}
+
+// Oldest returns the sequence number preceding the oldest batch still kept in the feed.
+func (f *Feed) Oldest() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.batches) == 0 {
+		return f.seq
+	}
+	return f.batches[0].Seq - 1
+}
+
+// Since returns batches applied after a given sequence number, up to limit batches.
+//
+// If there are no such batches, it waits for a new batch until the context is done.
+// It returns ErrFeedGone if some of the batches were already removed from the feed.
+func (f *Feed) Since(ctx context.Context, seq uint64, limit int) ([]Batch, error) {
+	for {
+		f.mu.Lock()
+		if seq > f.seq {
+			f.mu.Unlock()
+			return nil, fmt.Errorf("replication: sequence number %d is ahead of the feed (%d)", seq, f.seq)
+		} else if seq < f.seq {
+			if len(f.batches) == 0 || f.batches[0].Seq > seq+1 {
+				f.mu.Unlock()
+				return nil, ErrFeedGone
+			}
+			i := int(seq + 1 - f.batches[0].Seq)
+			out := f.batches[i:]
+			if limit > 0 && len(out) > limit {
+				out = out[:limit]
+			}
+			out = append([]Batch(nil), out...)
+			f.mu.Unlock()
+			return out, nil
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-changed:
+		}
+	}
+}
+
+// Unwrapped returns the underlying quad store.
+func (f *Feed) Unwrapped() graph.QuadStore {
+	return f.QuadStore
+}
+
+// WrapSnapshot returns a snapshot of the underlying quad store as is, since the feed only records writes.
+func (f *Feed) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return s, nil
+}
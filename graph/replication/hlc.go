@@ -0,0 +1,72 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import "fmt"
+
+// HLC is a timestamp of a hybrid logical clock.
+//
+// It follows the wall clock, but never goes backward and is always ahead of all timestamps
+// received from peers. Thus a change made after another change was seen gets a larger timestamp,
+// even if the clocks of the servers are skewed.
+type HLC struct {
+	// Wall is the physical part of the timestamp, in Unix nanoseconds.
+	Wall int64 `json:"wall"`
+	// Logical orders events with the same physical time.
+	Logical uint32 `json:"logical"`
+}
+
+// IsZero reports if the timestamp is not set.
+func (h HLC) IsZero() bool {
+	return h == HLC{}
+}
+
+// Before reports if h is earlier than t.
+func (h HLC) Before(t HLC) bool {
+	if h.Wall != t.Wall {
+		return h.Wall < t.Wall
+	}
+	return h.Logical < t.Logical
+}
+
+func (h HLC) String() string {
+	return fmt.Sprintf("%d.%d", h.Wall, h.Logical)
+}
+
+// next returns a timestamp for a local event at a given physical time.
+func (h HLC) next(now int64) HLC {
+	if now > h.Wall {
+		return HLC{Wall: now}
+	}
+	return HLC{Wall: h.Wall, Logical: h.Logical + 1}
+}
+
+// merge returns a timestamp for receiving an event with timestamp r at a given physical time.
+func (h HLC) merge(now int64, r HLC) HLC {
+	switch {
+	case now > h.Wall && now > r.Wall:
+		return HLC{Wall: now}
+	case h.Wall == r.Wall:
+		l := h.Logical
+		if r.Logical > l {
+			l = r.Logical
+		}
+		return HLC{Wall: h.Wall, Logical: l + 1}
+	case h.Wall > r.Wall:
+		return HLC{Wall: h.Wall, Logical: h.Logical + 1}
+	default:
+		return HLC{Wall: r.Wall, Logical: r.Logical + 1}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package replication
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// lock creates the lock file exclusively on systems without flock.
+//
+// A lock file left by a crashed process is not removed automatically, since it cannot be done
+// without a race with other servers; it must be removed manually.
+func (l *FileLease) lock() (func(), error) {
+	lock := l.path + ".lock"
+	for i := 0; ; i++ {
+		f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		} else if !os.IsExist(err) {
+			return nil, err
+		}
+		if i >= 50 {
+			return nil, fmt.Errorf("%v (remove %q if no server is running)", errLockTimeout, lock)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,431 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+// logger is used for messages of replicas; failover messages use a separate module.
+var (
+	logger         = clog.Module("replication")
+	failoverLogger = clog.Module("failover")
+)
+
+// HTTP API of the primary.
+const (
+	ChangesPath  = "/api/v2/replication/changes"
+	SnapshotPath = "/api/v2/replication/snapshot"
+
+	// HeaderFeedID and HeaderFeedSeq are set on snapshot responses to the position of the feed
+	// the replica must continue from.
+	HeaderFeedID  = "X-Cayley-Feed-Id"
+	HeaderFeedSeq = "X-Cayley-Feed-Seq"
+	// HeaderFeedOldest is set to the position preceding the oldest batch kept in the feed.
+	// Peers in active-active mode replay all kept batches after copying the snapshot.
+	HeaderFeedOldest = "X-Cayley-Feed-Oldest"
+)
+
+// Changes is a response of the primary with the batches since the requested position.
+type Changes struct {
+	Feed    string  `json:"feed"`
+	Seq     uint64  `json:"seq"` // last batch in the feed
+	Batches []Batch `json:"batches"`
+}
+
+// catchUpOpts are used for all batches applied on the replica. Batches applied after the snapshot
+// started are replayed on top of it, thus some of the changes might already be present.
+var catchUpOpts = graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}
+
+const (
+	defaultPollWait = 30 * time.Second
+	retryDelay      = time.Second
+	pollLimit       = 1000
+)
+
+// ReplicaOptions configures the replica.
+type ReplicaOptions struct {
+	// Client is used for requests to the primary. Default is http.DefaultClient.
+	Client *http.Client
+	// Token is sent to the primary as a bearer token. It's required if the primary restricts access to graphs,
+	// unless the client certificate of the replica grants access to all of them.
+	Token string
+	// PollWait is the maximal time the primary holds a request if there are no new batches.
+	PollWait time.Duration
+	// Meta stores the position of the replica in the feed of the primary, so a restarted replica
+	// continues from it instead of copying all quads again. Optional.
+	//
+	// It must not be set if the local quad store may be written by anything else between restarts,
+	// for example by a standby that was promoted to the primary.
+	Meta graph.MetadataStore
+}
+
+// metaPosition is a metadata key of the replica position.
+const metaPosition = "replication_position"
+
+// position is a position of the replica in the feed of the primary.
+type position struct {
+	Feed string `json:"feed"`
+	Seq  uint64 `json:"seq"`
+}
+
+// ReplicaStatus is a replication progress of the replica.
+type ReplicaStatus struct {
+	Primary string `json:"primary"`
+	Feed    string `json:"feed,omitempty"`
+	// Seq is the last batch applied by the replica.
+	Seq uint64 `json:"seq"`
+	// PrimarySeq is the last batch applied by the primary, as seen by the replica.
+	PrimarySeq uint64 `json:"primary_seq"`
+	// LagBatches is the number of batches the replica is behind the primary.
+	LagBatches uint64 `json:"lag_batches"`
+	// LagSeconds is the time since the primary applied the oldest batch not yet applied by the replica.
+	LagSeconds float64 `json:"lag_seconds"`
+	// Synced is the last time the replica was known to be up to date with the primary.
+	Synced    time.Time `json:"synced,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	// Conflicts is the number of remote changes that conflicted with local ones (active-active mode only).
+	Conflicts uint64 `json:"conflicts,omitempty"`
+	// Discarded is the number of conflicting remote changes that were not applied.
+	Discarded uint64 `json:"discarded,omitempty"`
+}
+
+// Replica applies batches from the feed of the primary to the local quad store.
+type Replica struct {
+	qs      graph.QuadStore
+	primary string
+	cli     *http.Client
+	token   string
+	wait    time.Duration
+	meta    graph.MetadataStore
+
+	// set in active-active mode; see NewPeer
+	local   *Feed
+	resolve Resolver
+
+	mu     sync.Mutex
+	st     ReplicaStatus
+	behind time.Time // time of the oldest batch that is not applied yet
+}
+
+// NewReplica creates a replica for the primary at a given base URL.
+// The local quad store should not be modified by anything else.
+func NewReplica(qs graph.QuadStore, primary string, opts ReplicaOptions) *Replica {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.PollWait <= 0 {
+		opts.PollWait = defaultPollWait
+	}
+	primary = strings.TrimSuffix(primary, "/")
+	return &Replica{
+		qs: qs, primary: primary,
+		cli: opts.Client, token: opts.Token, wait: opts.PollWait, meta: opts.Meta,
+		st: ReplicaStatus{Primary: primary},
+	}
+}
+
+// loadPosition restores the persisted position of the replica, if any.
+func (r *Replica) loadPosition(ctx context.Context) error {
+	if r.meta == nil {
+		return nil
+	}
+	data, err := r.meta.Metadata(ctx, metaPosition)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	var pos position
+	if err = json.Unmarshal(data, &pos); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.st.Feed, r.st.Seq = pos.Feed, pos.Seq
+	r.mu.Unlock()
+	return nil
+}
+
+// savePosition persists the position of the replica. Batches applied after the last
+// saved position are applied again after a restart, which is safe with catchUpOpts.
+func (r *Replica) savePosition(ctx context.Context, feed string, seq uint64) error {
+	if r.meta == nil {
+		return nil
+	}
+	data, err := json.Marshal(position{Feed: feed, Seq: seq})
+	if err != nil {
+		return err
+	}
+	return r.meta.SetMetadata(ctx, metaPosition, data)
+}
+
+// Status returns the replication progress.
+func (r *Replica) Status() ReplicaStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.st
+	if !r.behind.IsZero() {
+		st.LagSeconds = time.Since(r.behind).Seconds()
+	}
+	return st
+}
+
+func (r *Replica) setError(err error) {
+	r.mu.Lock()
+	r.st.LastError = err.Error()
+	r.mu.Unlock()
+}
+
+// Run replicates changes from the primary until the context is cancelled.
+// Errors are retried, thus it only returns the error of the context.
+//
+// Replication continues from the persisted position, if it's set in the options.
+func (r *Replica) Run(ctx context.Context) error {
+	if err := r.loadPosition(ctx); err != nil {
+		logger.Warning("failed to load the replica position", clog.F("error", err))
+	}
+	for {
+		err := r.Sync(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			logger.Warning("replication failed", clog.F("error", err))
+			r.setError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// Sync copies the data from the primary if necessary and applies new batches until
+// the context is cancelled or an error occurs.
+func (r *Replica) Sync(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		feed, seq := r.st.Feed, r.st.Seq
+		r.mu.Unlock()
+		if feed == "" {
+			if err := r.bootstrap(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		ch, err := r.changes(ctx, feed, seq)
+		if err == ErrFeedGone || err == ErrUnknownFeed {
+			logger.Warning("copying all quads from the primary", clog.F("error", err))
+			r.mu.Lock()
+			r.st.Feed = ""
+			r.mu.Unlock()
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = r.apply(ch); err != nil {
+			return err
+		}
+		if len(ch.Batches) != 0 {
+			if err = r.savePosition(ctx, feed, ch.Batches[len(ch.Batches)-1].Seq); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *Replica) apply(ch *Changes) error {
+	for i, b := range ch.Batches {
+		var res remoteResult
+		if r.local != nil {
+			var err error
+			if res, err = r.local.applyRemote(ch.Feed, b, r.resolve); err != nil {
+				return err
+			}
+		} else if err := r.qs.ApplyDeltas(b.Deltas, catchUpOpts); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.st.Conflicts += uint64(res.conflicts)
+		r.st.Discarded += uint64(res.discarded)
+		r.st.Seq = b.Seq
+		if i+1 < len(ch.Batches) {
+			r.behind = ch.Batches[i+1].Time
+		} else {
+			r.behind = time.Time{}
+		}
+		r.mu.Unlock()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.st.PrimarySeq = ch.Seq
+	if r.st.Seq >= ch.Seq {
+		r.st.LagBatches = 0
+		r.st.Synced = time.Now()
+		r.st.LastError = ""
+	} else {
+		r.st.LagBatches = ch.Seq - r.st.Seq
+		if r.behind.IsZero() && len(ch.Batches) != 0 {
+			// the next batch is newer than the last one we've seen
+			r.behind = ch.Batches[len(ch.Batches)-1].Time
+		}
+	}
+	return nil
+}
+
+func (r *Replica) get(ctx context.Context, path string, vals url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("GET", r.primary+path+"?"+vals.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.cli.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusGone:
+		resp.Body.Close()
+		return nil, ErrFeedGone
+	case http.StatusConflict:
+		resp.Body.Close()
+		return nil, ErrUnknownFeed
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("replication: unexpected response from the primary: %s: %s", resp.Status, data)
+}
+
+func (r *Replica) changes(ctx context.Context, feed string, seq uint64) (*Changes, error) {
+	resp, err := r.get(ctx, ChangesPath, url.Values{
+		"feed":  {feed},
+		"since": {strconv.FormatUint(seq, 10)},
+		"limit": {strconv.Itoa(pollLimit)},
+		"wait":  {r.wait.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var ch Changes
+	if err = json.NewDecoder(resp.Body).Decode(&ch); err != nil {
+		return nil, err
+	}
+	if ch.Feed != feed {
+		return nil, ErrUnknownFeed
+	}
+	return &ch, nil
+}
+
+// bootstrap replaces all quads in the local quad store with quads from the primary.
+func (r *Replica) bootstrap(ctx context.Context) error {
+	resp, err := r.get(ctx, SnapshotPath, url.Values{"format": {"nquads"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	feed := resp.Header.Get(HeaderFeedID)
+	seq, err := strconv.ParseUint(resp.Header.Get(HeaderFeedSeq), 10, 64)
+	if err != nil || feed == "" {
+		return fmt.Errorf("replication: invalid snapshot position: %q, %q", feed, resp.Header.Get(HeaderFeedSeq))
+	}
+	start := time.Now()
+	qr := nquads.NewReader(resp.Body, false)
+	defer qr.Close()
+	if r.local != nil {
+		if feed == r.local.ID() {
+			return fmt.Errorf("replication: %q is the local server", r.primary)
+		}
+		n, err := quad.CopyBatch(mergeWriter{f: r.local}, qr, quad.DefaultBatch)
+		if err != nil {
+			return err
+		}
+		// batches before the snapshot position may have removed quads or conflict with local changes
+		if old, err := strconv.ParseUint(resp.Header.Get(HeaderFeedOldest), 10, 64); err == nil && old < seq {
+			seq = old
+		}
+		logger.Info("merged quads from the peer", clog.F("quads", n), clog.F("duration", time.Since(start)))
+	} else {
+		// a partial copy must not be resumed after a restart
+		if err = r.savePosition(ctx, "", 0); err != nil {
+			return err
+		}
+		if err = r.clear(ctx); err != nil {
+			return err
+		}
+		qw, err := writer.NewSingle(r.qs, catchUpOpts)
+		if err != nil {
+			return err
+		}
+		w := graph.NewWriter(qw)
+		n, err := quad.CopyBatch(w, qr, quad.DefaultBatch)
+		if err != nil {
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+		logger.Info("copied quads from the primary", clog.F("quads", n), clog.F("duration", time.Since(start)))
+	}
+	if err = r.savePosition(ctx, feed, seq); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.st.Feed, r.st.Seq = feed, seq
+	r.mu.Unlock()
+	return nil
+}
+
+// clear removes all quads from the local quad store, one batch at a time.
+func (r *Replica) clear(ctx context.Context) error {
+	buf := make([]graph.Delta, 0, quad.DefaultBatch)
+	for {
+		buf = buf[:0]
+		// the iterator is closed before the batch is removed, since it might not observe removals correctly
+		it := r.qs.QuadsAllIterator()
+		for len(buf) < cap(buf) && it.Next(ctx) {
+			buf = append(buf, graph.Delta{Quad: r.qs.Quad(it.Result()), Action: graph.Delete})
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return err
+		} else if len(buf) == 0 {
+			return nil
+		}
+		if err = r.qs.ApplyDeltas(buf, catchUpOpts); err != nil {
+			return err
+		}
+	}
+}
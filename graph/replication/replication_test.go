@@ -0,0 +1,472 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	. "github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+var (
+	q1 = quad.Make("a", "follows", "b", nil)
+	q2 = quad.Make("b", "follows", "c", "g")
+	q3 = quad.Make("c", "age", quad.Int(3), nil)
+)
+
+func add(q quad.Quad) []graph.Delta {
+	return []graph.Delta{{Quad: q, Action: graph.Add}}
+}
+
+func TestFeed(t *testing.T) {
+	f, err := NewFeed(memstore.New(), 2)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, f.ApplyDeltas(add(q1), graph.IgnoreOpts{}))
+	require.Error(t, f.ApplyDeltas(add(q1), graph.IgnoreOpts{}))
+	require.Equal(t, uint64(1), f.Seq())
+
+	b, err := f.Since(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, b, 1)
+	require.Equal(t, add(q1), b[0].Deltas)
+
+	wctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	b, err = f.Since(wctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, b, 0)
+
+	for _, q := range []quad.Quad{q2, q3, quad.Make("d", "e", "f", nil)} {
+		require.NoError(t, f.ApplyDeltas(add(q), graph.IgnoreOpts{}))
+	}
+	b, err = f.Since(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, b, 1)
+	require.Equal(t, uint64(2), b[0].Seq)
+	for _, q := range []quad.Quad{quad.Make("e", "f", "g", nil), quad.Make("f", "g", "h", nil)} {
+		require.NoError(t, f.ApplyDeltas(add(q), graph.IgnoreOpts{}))
+	}
+	_, err = f.Since(ctx, 1, 0)
+	require.Equal(t, ErrFeedGone, err)
+
+	// the feed keeps a copy of deltas
+	deltas := add(q1)
+	require.NoError(t, f.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true}))
+	deltas[0].Quad = q2
+	b, err = f.Since(ctx, f.Seq()-1, 0)
+	require.NoError(t, err)
+	require.Equal(t, add(q1), b[0].Deltas)
+}
+
+func TestReplica(t *testing.T) {
+	feed, err := NewFeed(memstore.New(q1), 0)
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(feed, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	api := cayleyhttp.NewAPIv2(&graph.Handle{QuadStore: feed, QuadWriter: qw})
+	api.SetReplicationFeed(feed)
+	// anonymous clients cannot read any graphs
+	api.SetACL(&acl.ACL{})
+	api.SetAdminToken("secret")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + SnapshotPath)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// stale data on the replica is removed
+	local := memstore.New(q3)
+	r := NewReplica(local, srv.URL, ReplicaOptions{PollWait: 20 * time.Millisecond, Token: "secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	require.NoError(t, qw.AddQuad(q2))
+	require.NoError(t, qw.RemoveQuad(q1))
+	require.NoError(t, qw.AddQuad(q3))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Status().Seq < feed.Seq() {
+		require.True(t, time.Now().Before(deadline), "replica is not synced: %+v", r.Status())
+		time.Sleep(5 * time.Millisecond)
+	}
+	graphtest.ExpectIteratedQuads(t, local, local.QuadsAllIterator(), []quad.Quad{q2, q3}, true)
+	st := r.Status()
+	require.Equal(t, feed.ID(), st.Feed)
+	require.Equal(t, uint64(0), st.LagBatches)
+}
+
+func TestReplicaResume(t *testing.T) {
+	feed, err := NewFeed(memstore.New(q1), 0)
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(feed, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	api := cayleyhttp.NewAPIv2(&graph.Handle{QuadStore: feed, QuadWriter: qw})
+	api.SetReplicationFeed(feed)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	// stale quads are removed in multiple batches
+	defer func(n int) { quad.DefaultBatch = n }(quad.DefaultBatch)
+	quad.DefaultBatch = 2
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	local, err := kv.New(db, nil)
+	require.NoError(t, err)
+	defer local.Close()
+	lw, err := writer.NewSingle(local, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, lw.AddQuad(quad.Make("x", "n", quad.Int(i), nil)))
+	}
+	opts := ReplicaOptions{PollWait: 20 * time.Millisecond, Meta: local.(graph.MetadataStore)}
+
+	run := func() *Replica {
+		r := NewReplica(local, srv.URL, opts)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.Run(ctx)
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for r.Status().Seq < feed.Seq() || r.Status().Feed == "" {
+			require.True(t, time.Now().Before(deadline), "replica is not synced: %+v", r.Status())
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		<-done
+		return r
+	}
+	require.NoError(t, qw.AddQuad(q2))
+	run()
+	graphtest.ExpectIteratedQuads(t, local, local.QuadsAllIterator(), []quad.Quad{q1, q2}, true)
+
+	// the restarted replica continues from the saved position instead of copying all quads
+	marker := quad.Make("local", "only", "quad", nil)
+	require.NoError(t, lw.AddQuad(marker))
+	require.NoError(t, qw.AddQuad(q3))
+	r := run()
+	require.Equal(t, feed.ID(), r.Status().Feed)
+	graphtest.ExpectIteratedQuads(t, local, local.QuadsAllIterator(), []quad.Quad{q1, q2, q3, marker}, true)
+}
+
+func TestHLC(t *testing.T) {
+	var c HLC
+	require.True(t, c.IsZero())
+	a := HLC{Wall: 10}
+	b := HLC{Wall: 10, Logical: 1}
+	require.True(t, a.Before(b))
+	require.False(t, b.Before(a))
+	require.True(t, b.Before(HLC{Wall: 11}))
+}
+
+func TestResolvers(t *testing.T) {
+	local := Version{Clock: HLC{Wall: 10}, Origin: "a", Action: graph.Add}
+	remote := Version{Clock: HLC{Wall: 5}, Origin: "b", Action: graph.Delete}
+	c := Conflict{Quad: q1, Local: local, Remote: remote}
+	require.False(t, LastWriterWins(c))
+	require.False(t, AddWins(c))
+	require.True(t, DeleteWins(c))
+
+	// ties are broken by the origin; the peer makes the opposite decision
+	c.Remote.Clock = c.Local.Clock
+	require.True(t, LastWriterWins(c))
+	require.False(t, LastWriterWins(Conflict{Quad: q1, Local: c.Remote, Remote: c.Local}))
+
+	r, err := GetResolver("lww")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	_, err = GetResolver("unknown")
+	require.Error(t, err)
+	require.Contains(t, Resolvers(), "delete-wins")
+}
+
+type peerServer struct {
+	qs   graph.QuadStore
+	feed *Feed
+	qw   graph.QuadWriter
+	srv  *httptest.Server
+}
+
+func newPeerServer(t *testing.T, quads ...quad.Quad) *peerServer {
+	qs := memstore.New(quads...)
+	feed, err := NewFeed(qs, 0)
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(feed, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	api := cayleyhttp.NewAPIv2(&graph.Handle{QuadStore: feed, QuadWriter: qw})
+	api.SetReplicationFeed(feed)
+	return &peerServer{qs: qs, feed: feed, qw: qw, srv: httptest.NewServer(api)}
+}
+
+func TestPeers(t *testing.T) {
+	a, b := newPeerServer(t, q1), newPeerServer(t, q1)
+	defer a.srv.Close()
+	defer b.srv.Close()
+
+	opts := PeerOptions{ReplicaOptions: ReplicaOptions{PollWait: 20 * time.Millisecond}}
+	ra := NewPeer(a.feed, b.srv.URL, opts)
+	rb := NewPeer(b.feed, a.srv.URL, opts)
+
+	q4 := quad.Make("d", "follows", "a", nil)
+	// q3 is added on both servers before they connect, but b removes it later
+	require.NoError(t, a.qw.AddQuad(q2))
+	require.NoError(t, a.qw.AddQuad(q3))
+	require.NoError(t, b.qw.AddQuad(q3))
+	require.NoError(t, b.qw.RemoveQuad(q3))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ra.Run(ctx)
+	go rb.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rb.Status().Feed == "" || ra.Status().Feed == "" {
+		require.True(t, time.Now().Before(deadline), "peers are not connected")
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.NoError(t, a.qw.AddQuad(q4))
+
+	expect := []quad.Quad{q1, q2, q4}
+	for _, p := range []struct {
+		s *peerServer
+		r *Replica
+		o *peerServer
+	}{{a, ra, b}, {b, rb, a}} {
+		for p.r.Status().Seq < p.o.feed.Seq() {
+			require.True(t, time.Now().Before(deadline), "peer is not synced: %+v", p.r.Status())
+			time.Sleep(5 * time.Millisecond)
+		}
+		graphtest.ExpectIteratedQuads(t, p.s.qs, p.s.qs.QuadsAllIterator(), expect, true)
+	}
+	require.Equal(t, uint64(1), ra.Status().Conflicts)
+	require.Equal(t, uint64(0), ra.Status().Discarded)
+	require.Equal(t, uint64(1), rb.Status().Discarded)
+	// changes received from the peer are not sent back
+	require.Equal(t, uint64(3), a.feed.Seq())
+}
+
+func TestPeersSequential(t *testing.T) {
+	for _, name := range []string{"add-wins", "delete-wins"} {
+		t.Run(name, func(t *testing.T) {
+			resolve, err := GetResolver(name)
+			require.NoError(t, err)
+			a, b := newPeerServer(t, q1), newPeerServer(t, q1)
+			defer a.srv.Close()
+			defer b.srv.Close()
+
+			opts := PeerOptions{ReplicaOptions: ReplicaOptions{PollWait: 20 * time.Millisecond}, Resolver: resolve}
+			ra := NewPeer(a.feed, b.srv.URL, opts)
+			rb := NewPeer(b.feed, a.srv.URL, opts)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go ra.Run(ctx)
+			go rb.Run(ctx)
+
+			deadline := time.Now().Add(5 * time.Second)
+			sync := func(r *Replica, o *peerServer) {
+				for st := r.Status(); st.Feed != o.feed.ID() || st.Seq < o.feed.Seq(); st = r.Status() {
+					require.True(t, time.Now().Before(deadline), "peer is not synced: %+v", st)
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+			sync(ra, b)
+			sync(rb, a)
+
+			// the peer changes a quad again after it received the local change
+			require.NoError(t, a.qw.RemoveQuad(q1))
+			sync(rb, a)
+			require.NoError(t, b.qw.AddQuad(q1))
+			sync(ra, b)
+
+			require.NoError(t, a.qw.AddQuad(q2))
+			sync(rb, a)
+			require.NoError(t, b.qw.RemoveQuad(q2))
+			sync(ra, b)
+
+			for _, s := range []*peerServer{a, b} {
+				graphtest.ExpectIteratedQuads(t, s.qs, s.qs.QuadsAllIterator(), []quad.Quad{q1}, true)
+			}
+			require.Equal(t, uint64(0), ra.Status().Conflicts)
+			require.Equal(t, uint64(0), rb.Status().Conflicts)
+		})
+	}
+}
+
+type failoverServer struct {
+	qs  graph.QuadStore
+	qw  graph.QuadWriter
+	f   *Failover
+	srv *httptest.Server
+}
+
+func newFailoverServer(t *testing.T, lease Lease) *failoverServer {
+	qs := memstore.New()
+	feed, err := NewFeed(qs, 0)
+	require.NoError(t, err)
+	s := &failoverServer{qs: qs}
+	// the address must be known before the API is created
+	var api *cayleyhttp.APIv2
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.ServeHTTP(w, r)
+	}))
+	s.f, err = NewFailover(qs, lease, FailoverOptions{
+		ReplicaOptions: ReplicaOptions{PollWait: 20 * time.Millisecond},
+		Addr:           s.srv.URL, TTL: 150 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	guarded := s.f.Guard(feed)
+	s.qw, err = writer.NewSingle(guarded, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	api = cayleyhttp.NewAPIv2(&graph.Handle{QuadStore: guarded, QuadWriter: s.qw})
+	api.SetReplicationFeed(feed)
+	return s
+}
+
+func waitFor(t *testing.T, msg string, fn func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !fn() {
+		require.True(t, time.Now().Before(deadline), msg)
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-failover-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	lease := NewFileLease(filepath.Join(dir, "lease"))
+
+	a, b := newFailoverServer(t, lease), newFailoverServer(t, lease)
+	defer a.srv.Close()
+	defer b.srv.Close()
+
+	ctxA, stopA := context.WithCancel(context.Background())
+	defer stopA()
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		a.f.Run(ctxA)
+	}()
+	waitFor(t, "a is not promoted", a.f.IsPrimary)
+
+	ctxB, stopB := context.WithCancel(context.Background())
+	defer stopB()
+	go b.f.Run(ctxB)
+	waitFor(t, "b is not a standby of a", func() bool {
+		st := b.f.Status()
+		return st.Replica != nil && st.Replica.Feed != ""
+	})
+	require.False(t, b.f.IsPrimary())
+	require.Equal(t, ErrStandby, b.qw.AddQuad(q2))
+
+	require.NoError(t, a.qw.AddQuad(q1))
+	waitFor(t, "change is not replicated", func() bool {
+		st := b.f.Status()
+		return st.Replica != nil && st.Replica.Seq == 1
+	})
+
+	// the primary disappears
+	stopA()
+	<-doneA
+	require.False(t, a.f.IsPrimary())
+	waitFor(t, "b is not promoted", b.f.IsPrimary)
+	graphtest.ExpectIteratedQuads(t, b.qs, b.qs.QuadsAllIterator(), []quad.Quad{q1}, true)
+	require.NoError(t, b.qw.AddQuad(q2))
+	graphtest.ExpectIteratedQuads(t, b.qs, b.qs.QuadsAllIterator(), []quad.Quad{q1, q2}, true)
+}
+
+// failingLease grants the lease once and fails all later requests.
+type failingLease struct {
+	granted bool
+}
+
+func (l *failingLease) Acquire(h Holder, ttl time.Duration) (Holder, error) {
+	if l.granted {
+		return Holder{}, errors.New("lease is not available")
+	}
+	l.granted = true
+	h.Expires = time.Now().Add(ttl)
+	return h, nil
+}
+
+func (l *failingLease) Release(id string) error { return nil }
+
+func TestFailoverLeaseDeadline(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	qs := memstore.New()
+	f, err := NewFailover(qs, &failingLease{}, FailoverOptions{Addr: "http://localhost", TTL: ttl})
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(f.Guard(qs), graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	go f.Run(ctx)
+	waitFor(t, "not promoted", f.IsPrimary)
+	require.NoError(t, qw.AddQuad(q1))
+
+	// writes are rejected before the lease expires for other servers
+	waitFor(t, "not demoted", func() bool { return !f.IsPrimary() })
+	require.True(t, time.Since(start) < ttl)
+	require.Equal(t, ErrStandby, qw.AddQuad(q2))
+}
+
+func TestGuardWrapper(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, graph.Options{kv.OptHistory: true})
+	require.NoError(t, err)
+	require.NoError(t, qs.ApplyDeltas(add(q1), graph.IgnoreOpts{}))
+	f, err := NewFailover(qs, &failingLease{}, FailoverOptions{Addr: "http://localhost"})
+	require.NoError(t, err)
+	guarded := f.Guard(qs)
+
+	h, ok := graph.HorizonOf(guarded)
+	require.True(t, ok)
+	require.NotZero(t, h)
+	snap, err := graph.SnapshotOf(guarded)
+	require.NoError(t, err)
+	require.Equal(t, h, snap.Horizon())
+	require.NoError(t, snap.Close())
+	snap, err = graph.AsOf(guarded, h)
+	require.NoError(t, err)
+	require.NoError(t, snap.Close())
+}
@@ -0,0 +1,264 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sameas
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+var _ graph.Wrapper = (*QuadStore)(nil)
+
+// QuadStore handles owl:sameAs links in a given mode.
+//
+// In Expand mode, all aliases are replaced with canonical nodes when reading: quads of all aliases are returned
+// for the canonical node, and aliases are not listed as separate nodes. The data is written as is.
+//
+// In Merge mode, existing quads are rewritten to canonical nodes when the store is wrapped, and new quads
+// are rewritten on write. Writing owl:sameAs links merges nodes again.
+//
+// Aliases are cached in memory, thus all writes must go through the wrapper.
+type QuadStore struct {
+	graph.QuadStore
+	mode Mode
+
+	mu sync.RWMutex
+	st *state
+}
+
+// state is a set of aliases, resolved to references of the underlying quad store.
+type state struct {
+	aliases *Aliases
+	// canon maps keys of aliases to a reference of their canonical node
+	canon map[interface{}]graph.Value
+	// refs maps keys of canonical nodes to references of all their aliases, including themselves
+	refs map[interface{}][]graph.Value
+	// hidden are references of all aliases that are not canonical
+	hidden []graph.Value
+	// horizon of the underlying quad store before aliases were loaded; 0 if it's not reported
+	horizon int64
+}
+
+// NewQuadStore wraps a quad store to handle owl:sameAs links in a given mode.
+func NewQuadStore(ctx context.Context, qs graph.QuadStore, mode Mode) (*QuadStore, error) {
+	s := &QuadStore{QuadStore: qs, mode: mode}
+	if mode == ModeMerge {
+		if _, err := Merge(ctx, qs); err != nil {
+			return nil, err
+		}
+	} else if mode != ModeExpand {
+		return nil, fmt.Errorf("sameas: unknown mode: %v", mode)
+	}
+	if err := s.reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (qs *QuadStore) reload(ctx context.Context) error {
+	horizon, _ := graph.HorizonOf(qs.QuadStore)
+	a, err := LoadAliases(ctx, qs.QuadStore)
+	if err != nil {
+		return err
+	}
+	st := &state{
+		aliases: a,
+		canon:   make(map[interface{}]graph.Value),
+		refs:    make(map[interface{}][]graph.Value),
+		horizon: horizon,
+	}
+	for _, g := range a.groups {
+		cref := qs.QuadStore.ValueOf(g[0])
+		if cref == nil {
+			continue
+		}
+		ckey := graph.ToKey(cref)
+		st.refs[ckey] = append(st.refs[ckey], cref)
+		for _, v := range g[1:] {
+			if ref := qs.QuadStore.ValueOf(v); ref != nil {
+				st.canon[graph.ToKey(ref)] = cref
+				st.refs[ckey] = append(st.refs[ckey], ref)
+				st.hidden = append(st.hidden, ref)
+			}
+		}
+	}
+	qs.mu.Lock()
+	qs.st = st
+	qs.mu.Unlock()
+	return nil
+}
+
+func (qs *QuadStore) state() *state {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	return qs.st
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// snapshot is a snapshot of the underlying quad store with aliases resolved.
+type snapshot struct {
+	*QuadStore
+	snap graph.Snapshot
+}
+
+func (s *snapshot) Horizon() int64 {
+	return s.snap.Horizon()
+}
+
+// WrapSnapshot resolves aliases in a snapshot of the underlying quad store.
+// Current aliases are reused, unless the snapshot is older than them.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	ss := &QuadStore{QuadStore: s, mode: qs.mode}
+	if st := qs.state(); s.Horizon() >= st.horizon {
+		ss.st = st
+	} else if err := ss.reload(context.TODO()); err != nil {
+		return nil, err
+	}
+	return &snapshot{QuadStore: ss, snap: s}, nil
+}
+
+// Aliases returns current groups of aliases.
+func (qs *QuadStore) Aliases() *Aliases {
+	return qs.state().aliases
+}
+
+func (qs *QuadStore) expand() bool {
+	return qs.mode == ModeExpand
+}
+
+func (qs *QuadStore) canonical(st *state, ref graph.Value) graph.Value {
+	if c, ok := st.canon[graph.ToKey(ref)]; ok {
+		return c
+	}
+	return ref
+}
+
+func (qs *QuadStore) ValueOf(v quad.Value) graph.Value {
+	if !qs.expand() {
+		return qs.QuadStore.ValueOf(v)
+	}
+	return qs.QuadStore.ValueOf(qs.state().aliases.Canonical(v))
+}
+
+func (qs *QuadStore) Quad(ref graph.Value) quad.Quad {
+	q := qs.QuadStore.Quad(ref)
+	if !qs.expand() {
+		return q
+	}
+	a := qs.state().aliases
+	for _, d := range quad.Directions {
+		q.Set(d, a.Canonical(q.Get(d)))
+	}
+	return q
+}
+
+func (qs *QuadStore) QuadDirection(ref graph.Value, d quad.Direction) graph.Value {
+	v := qs.QuadStore.QuadDirection(ref, d)
+	if !qs.expand() || v == nil {
+		return v
+	}
+	return qs.canonical(qs.state(), v)
+}
+
+func (qs *QuadStore) QuadIterator(d quad.Direction, ref graph.Value) graph.Iterator {
+	if !qs.expand() {
+		return qs.QuadStore.QuadIterator(d, ref)
+	}
+	refs := qs.state().refs[graph.ToKey(ref)]
+	if len(refs) == 0 {
+		return qs.QuadStore.QuadIterator(d, ref)
+	}
+	its := make([]graph.Iterator, 0, len(refs))
+	for _, r := range refs {
+		its = append(its, qs.QuadStore.QuadIterator(d, r))
+	}
+	return iterator.NewOr(its...)
+}
+
+// OptimizeIterator doesn't let the underlying quad store optimize iterators in Expand mode,
+// since it would replace quad iterators of the wrapper with its own.
+func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
+	if qs.expand() {
+		return it, false
+	}
+	return qs.QuadStore.OptimizeIterator(it)
+}
+
+func (qs *QuadStore) NodesAllIterator() graph.Iterator {
+	st := qs.state()
+	if !qs.expand() || len(st.hidden) == 0 {
+		return qs.QuadStore.NodesAllIterator()
+	}
+	return iterator.NewNot(iterator.NewFixed(st.hidden...), qs.QuadStore.NodesAllIterator())
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf applies deltas to the underlying quad store. In Merge mode, quads are rewritten to canonical nodes first.
+//
+// Aliases are reloaded if any of the deltas is an owl:sameAs link.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	ctx := context.TODO()
+	if st := qs.state(); qs.mode == ModeMerge && st.aliases.Len() != 0 {
+		rewritten := make([]graph.Delta, len(deltas))
+		for i, d := range deltas {
+			d.Quad = st.aliases.Rewrite(d.Quad)
+			rewritten[i] = d
+		}
+		deltas = rewritten
+	}
+	var err error
+	if len(conds) == 0 {
+		err = qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		err = ca.ApplyDeltasIf(conds, deltas, opts)
+	} else {
+		err = graph.ErrPreconditionsNotSupported
+	}
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, d := range deltas {
+		if IsSameAs(d.Quad) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if qs.mode == ModeMerge {
+		if _, err = Merge(ctx, qs.QuadStore); err != nil {
+			return fmt.Errorf("sameas: failed to merge aliases: %v", err)
+		}
+	}
+	if err = qs.reload(ctx); err != nil {
+		return fmt.Errorf("sameas: failed to reload aliases: %v", err)
+	}
+	return nil
+}
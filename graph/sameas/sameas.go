@@ -0,0 +1,239 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sameas treats nodes linked with owl:sameAs as a single resource.
+//
+// All nodes that are linked with owl:sameAs, directly or transitively, form a group of aliases.
+// One of them is selected as a canonical node: IRIs are preferred over blank nodes, and the smallest value wins.
+//
+// Aliases can either be expanded at query time (Expand mode), or quads can be rewritten to use canonical nodes
+// (Merge mode, also known as smushing). The IRI of owl:sameAs must be in the full form.
+package sameas
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/owl"
+)
+
+var sameAs = quad.IRI(owl.SameAs).Full()
+
+// IsSameAs checks if a quad links two aliases.
+func IsSameAs(q quad.Quad) bool {
+	return q.Predicate == sameAs
+}
+
+// Mode selects how aliases are handled.
+type Mode int
+
+const (
+	// ModeExpand makes the quad store look as if all aliases were replaced with a canonical node, without changing the data.
+	ModeExpand = Mode(iota)
+	// ModeMerge rewrites stored quads to use canonical nodes.
+	ModeMerge
+)
+
+// ParseMode parses a mode name: "expand" or "merge".
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "expand":
+		return ModeExpand, nil
+	case "merge":
+		return ModeMerge, nil
+	}
+	return 0, fmt.Errorf("sameas: unknown mode: %q", s)
+}
+
+func (m Mode) String() string {
+	switch m {
+	case ModeExpand:
+		return "expand"
+	case ModeMerge:
+		return "merge"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// rank orders kinds of nodes, so IRIs are selected as canonical nodes first.
+func rank(v quad.Value) int {
+	switch v.(type) {
+	case quad.IRI:
+		return 0
+	case quad.BNode:
+		return 1
+	}
+	return 2
+}
+
+func less(a, b quad.Value) bool {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra < rb
+	}
+	return a.String() < b.String()
+}
+
+// Aliases is a set of groups of nodes linked with owl:sameAs.
+type Aliases struct {
+	// canon maps each alias (including canonical nodes) to the canonical node
+	canon map[string]quad.Value
+	// groups maps canonical nodes to all aliases, including themselves
+	groups map[string][]quad.Value
+}
+
+// LoadAliases reads all owl:sameAs links from the quad store.
+func LoadAliases(ctx context.Context, qs graph.QuadStore) (*Aliases, error) {
+	nodes := make(map[string]quad.Value)
+	parent := make(map[string]quad.Value)
+	var find func(v quad.Value) quad.Value
+	find = func(v quad.Value) quad.Value {
+		p, ok := parent[v.String()]
+		if !ok {
+			nodes[v.String()] = v
+			parent[v.String()] = v
+			return v
+		} else if p.String() == v.String() {
+			return v
+		}
+		r := find(p)
+		parent[v.String()] = r
+		return r
+	}
+	if ref := qs.ValueOf(sameAs); ref != nil {
+		it := qs.QuadIterator(quad.Predicate, ref)
+		for it.Next(ctx) {
+			q := qs.Quad(it.Result())
+			a, b := find(q.Subject), find(q.Object)
+			if a.String() == b.String() {
+				continue
+			}
+			if less(b, a) {
+				a, b = b, a
+			}
+			// roots are always the smallest values, thus they are canonical nodes
+			parent[b.String()] = a
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	a := &Aliases{canon: make(map[string]quad.Value), groups: make(map[string][]quad.Value)}
+	for k, v := range nodes {
+		c := find(v)
+		if c.String() == k {
+			continue
+		}
+		a.canon[k] = c
+		if _, ok := a.groups[c.String()]; !ok {
+			a.canon[c.String()] = c
+			a.groups[c.String()] = []quad.Value{c}
+		}
+		a.groups[c.String()] = append(a.groups[c.String()], v)
+	}
+	return a, nil
+}
+
+// Len returns the number of groups of aliases.
+func (a *Aliases) Len() int {
+	return len(a.groups)
+}
+
+// Canonical returns a canonical node for a given node. The node itself is returned if it has no aliases.
+func (a *Aliases) Canonical(v quad.Value) quad.Value {
+	if v == nil {
+		return nil
+	} else if c, ok := a.canon[v.String()]; ok {
+		return c
+	}
+	return v
+}
+
+// Of returns all aliases of a node, including the node itself.
+func (a *Aliases) Of(v quad.Value) []quad.Value {
+	if g := a.groups[a.Canonical(v).String()]; len(g) != 0 {
+		return g
+	}
+	return []quad.Value{v}
+}
+
+// IsAlias checks if the node has a different canonical node.
+func (a *Aliases) IsAlias(v quad.Value) bool {
+	c, ok := a.canon[v.String()]
+	return ok && c.String() != v.String()
+}
+
+// Rewrite replaces all nodes of a quad with canonical nodes. Links between aliases are not changed.
+func (a *Aliases) Rewrite(q quad.Quad) quad.Quad {
+	if IsSameAs(q) {
+		return q
+	}
+	for _, d := range quad.Directions {
+		q.Set(d, a.Canonical(q.Get(d)))
+	}
+	return q
+}
+
+// Merge rewrites all quads in the quad store to use canonical nodes and returns the number of changed quads.
+//
+// Links between aliases are replaced with links from each alias to its canonical node,
+// so all aliases can still be resolved. Merged nodes cannot be separated by removing the links later.
+func Merge(ctx context.Context, qs graph.QuadStore) (int, error) {
+	a, err := LoadAliases(ctx, qs)
+	if err != nil {
+		return 0, err
+	} else if a.Len() == 0 {
+		return 0, nil
+	}
+	var deltas []graph.Delta
+	n := 0
+	it := qs.QuadsAllIterator()
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		if IsSameAs(q) {
+			// only alias -> canonical links are kept; they are added below
+			if !a.IsAlias(q.Subject) || a.Canonical(q.Subject) != q.Object {
+				deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Delete})
+				n++
+			}
+			continue
+		}
+		nq := a.Rewrite(q)
+		if nq == q {
+			continue
+		}
+		deltas = append(deltas,
+			graph.Delta{Quad: q, Action: graph.Delete},
+			graph.Delta{Quad: nq, Action: graph.Add},
+		)
+		n++
+	}
+	err = it.Err()
+	it.Close()
+	if err != nil {
+		return 0, err
+	}
+	for _, g := range a.groups {
+		for _, v := range g[1:] {
+			deltas = append(deltas, graph.Delta{Quad: quad.Make(v, sameAs, g[0], nil), Action: graph.Add})
+		}
+	}
+	if err = qs.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
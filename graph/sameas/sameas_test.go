@@ -0,0 +1,92 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sameas
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func newStore() *memstore.QuadStore {
+	return memstore.New(
+		quad.Make(quad.IRI("b"), sameAs, quad.IRI("a"), nil),
+		quad.Make(quad.BNode("x"), sameAs, quad.IRI("b"), nil),
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Alice"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("age"), quad.Int(30), nil),
+		quad.Make(quad.BNode("x"), quad.IRI("knows"), quad.IRI("c"), nil),
+		quad.Make(quad.IRI("c"), quad.IRI("knows"), quad.IRI("b"), nil),
+	)
+}
+
+func names(t testing.TB, qs graph.QuadStore, p *path.Path) []string {
+	vals, err := p.Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	var out []string
+	for _, v := range vals {
+		out = append(out, v.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestAliases(t *testing.T) {
+	a, err := LoadAliases(context.TODO(), newStore())
+	require.NoError(t, err)
+	require.Equal(t, 1, a.Len())
+	for _, v := range []quad.Value{quad.IRI("a"), quad.IRI("b"), quad.BNode("x")} {
+		require.Equal(t, quad.IRI("a"), a.Canonical(v))
+	}
+	require.Equal(t, quad.IRI("c"), a.Canonical(quad.IRI("c")))
+	require.Len(t, a.Of(quad.IRI("b")), 3)
+}
+
+func TestExpand(t *testing.T) {
+	qs, err := NewQuadStore(context.TODO(), newStore(), ModeExpand)
+	require.NoError(t, err)
+	// all aliases resolve to the same node
+	require.Equal(t, []string{`"Alice"`}, names(t, qs, path.StartPath(qs, quad.BNode("x")).Out(quad.IRI("name"))))
+	require.Equal(t, []string{`<c>`}, names(t, qs, path.StartPath(qs, quad.IRI("a")).Out(quad.IRI("knows"))))
+	require.Equal(t, []string{`<a>`}, names(t, qs, path.StartPath(qs, quad.IRI("c")).Out(quad.IRI("knows"))))
+	require.Equal(t, []string{`<a>`}, names(t, qs,
+		path.StartPath(qs).Has(quad.IRI("name")).Has(quad.IRI("age")),
+	))
+
+	// new links are picked up on write
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: quad.Make(quad.IRI("c"), sameAs, quad.IRI("d"), nil), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.Equal(t, []string{`<a>`}, names(t, qs, path.StartPath(qs, quad.IRI("d")).Out(quad.IRI("knows"))))
+}
+
+func TestMerge(t *testing.T) {
+	base := newStore()
+	qs, err := NewQuadStore(context.TODO(), base, ModeMerge)
+	require.NoError(t, err)
+	require.Equal(t, []string{`"Alice"`}, names(t, base, path.StartPath(base, quad.IRI("a")).Has(quad.IRI("age")).Out(quad.IRI("name"))))
+	require.Equal(t, []string{`<a>`}, names(t, base, path.StartPath(base, quad.IRI("c")).Out(quad.IRI("knows"))))
+	// aliases are linked to the canonical node
+	require.Equal(t, []string{`<b>`, `_:x`}, names(t, base, path.StartPath(base, quad.IRI("a")).In(sameAs)))
+
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: quad.Make(quad.IRI("b"), quad.IRI("likes"), quad.IRI("c"), nil), Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.Equal(t, []string{`<c>`}, names(t, base, path.StartPath(base, quad.IRI("a")).Out(quad.IRI("likes"))))
+}
@@ -76,14 +76,49 @@ func buildOut(from, via, labels Shape, tags []string, in bool) Shape {
 			Dir: quad.Predicate, Values: via,
 		})
 	}
-	if labels != nil {
-		if _, ok := labels.(AllNodes); !ok {
-			quads = append(quads, QuadFilter{
-				Dir: quad.Label, Values: labels,
-			})
+	return NodesFrom{Quads: withLabels(quads, labels), Dir: goal}
+}
+
+// ExceptLabels is a set of labels for Out, In and other traversals that matches quads with any label or without one,
+// except quads with excluded labels.
+type ExceptLabels struct {
+	// From is a set of labels to match; nil means any label, or no label.
+	From    Shape
+	Exclude Shape
+}
+
+func (s ExceptLabels) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	return Except{From: s.From, Exclude: s.Exclude}.BuildIterator(qs)
+}
+func (s ExceptLabels) Optimize(r Optimizer) (Shape, bool) {
+	var opt bool
+	s.Exclude, opt = s.Exclude.Optimize(r)
+	if s.From != nil {
+		var opta bool
+		s.From, opta = s.From.Optimize(r)
+		opt = opt || opta
+	}
+	return s, opt
+}
+
+// withLabels restricts quads to a given set of labels. Nil and AllNodes match quads with any label.
+func withLabels(quads Quads, labels Shape) Shape {
+	switch labels := labels.(type) {
+	case nil, AllNodes:
+		return quads
+	case ExceptLabels:
+		var from Shape = quads
+		if labels.From != nil {
+			from = withLabels(quads, labels.From)
+		}
+		return Except{
+			From:    from,
+			Exclude: Quads{{Dir: quad.Label, Values: labels.Exclude}},
 		}
 	}
-	return NodesFrom{Quads: quads, Dir: goal}
+	return append(quads, QuadFilter{
+		Dir: quad.Label, Values: labels,
+	})
 }
 
 func Out(from, via, labels Shape, tags ...string) Shape {
@@ -109,6 +144,17 @@ func Predicates(from Shape, in bool) Shape {
 	}}
 }
 
+// Properties follows predicates with IRIs matching a wildcard pattern (see Wildcard). Predicates used by
+// nodes of the set are matched against the pattern first, thus each of them is compared only once, and matching
+// ones are followed with the predicate index. Tags are applied to predicates.
+func Properties(from Shape, pattern string, labels Shape, in bool, tags ...string) Shape {
+	preds := Filter{
+		From:    Predicates(from, in),
+		Filters: []ValueFilter{Wildcard{Pattern: pattern}},
+	}
+	return buildOut(from, preds, labels, tags, in)
+}
+
 func SavePredicates(from Shape, in bool, tag string) Shape {
 	preds := Save{
 		From: AllNodes{},
@@ -160,16 +206,9 @@ func SaveViaLabels(from, via, labels Shape, tag string, rev, opt bool) Shape {
 		{Dir: goal, Values: nodes},
 		{Dir: quad.Predicate, Values: via},
 	}
-	if labels != nil {
-		if _, ok := labels.(AllNodes); !ok {
-			quads = append(quads, QuadFilter{
-				Dir: quad.Label, Values: labels,
-			})
-		}
-	}
 
 	var save Shape = NodesFrom{
-		Quads: quads,
+		Quads: withLabels(quads, labels),
 		Dir:   start,
 	}
 	if opt {
@@ -199,18 +238,11 @@ func HasLabels(from, via, nodes, labels Shape, rev bool) Shape {
 			Dir: quad.Predicate, Values: via,
 		})
 	}
-	if labels != nil {
-		if _, ok := labels.(AllNodes); !ok {
-			quads = append(quads, QuadFilter{
-				Dir: quad.Label, Values: labels,
-			})
-		}
-	}
-	if len(quads) == 0 {
+	if _, ok := labels.(ExceptLabels); len(quads) == 0 && !ok {
 		panic("empty has")
 	}
 	return IntersectShapes(from, NodesFrom{
-		Quads: quads, Dir: start,
+		Quads: withLabels(quads, labels), Dir: start,
 	})
 }
 
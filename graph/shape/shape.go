@@ -1,14 +1,18 @@
 package shape
 
 import (
+	"context"
 	"os"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/text"
 	"github.com/cayleygraph/cayley/quad"
 )
 
@@ -278,6 +282,9 @@ func (s Except) Optimize(r Optimizer) (Shape, bool) {
 		return ns, opt || nopt
 	}
 	if IsNull(s.Exclude) {
+		if s.From != nil {
+			return s.From, true
+		}
 		return AllNodes{}, true
 	} else if _, ok := s.Exclude.(AllNodes); ok {
 		return nil, true
@@ -386,6 +393,41 @@ func (f Wildcard) Regexp() string {
 	return pattern
 }
 
+// substringIndexerOf and the functions below find an index of the quad store through all the wrappers.
+// Candidates from the index are always intersected with the input iterator, thus reads restricted
+// by the wrappers stay restricted.
+func substringIndexerOf(qs graph.QuadStore) (graph.SubstringIndexer, bool) {
+	si, ok := graph.Find(qs, func(qs graph.QuadStore) bool {
+		_, ok := qs.(graph.SubstringIndexer)
+		return ok
+	}).(graph.SubstringIndexer)
+	return si, ok
+}
+
+func prefixIndexerOf(qs graph.QuadStore) (graph.PrefixIndexer, bool) {
+	pi, ok := graph.Find(qs, func(qs graph.QuadStore) bool {
+		_, ok := qs.(graph.PrefixIndexer)
+		return ok
+	}).(graph.PrefixIndexer)
+	return pi, ok
+}
+
+func geoIndexerOf(qs graph.QuadStore) (graph.GeoIndexer, bool) {
+	gi, ok := graph.Find(qs, func(qs graph.QuadStore) bool {
+		_, ok := qs.(graph.GeoIndexer)
+		return ok
+	}).(graph.GeoIndexer)
+	return gi, ok
+}
+
+func timeIndexerOf(qs graph.QuadStore) (graph.TimeIndexer, bool) {
+	ti, ok := graph.Find(qs, func(qs graph.QuadStore) bool {
+		_, ok := qs.(graph.TimeIndexer)
+		return ok
+	}).(graph.TimeIndexer)
+	return ti, ok
+}
+
 func (f Wildcard) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
 	if f.Pattern == "" {
 		return iterator.NewNull()
@@ -396,11 +438,233 @@ func (f Wildcard) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Ite
 	if err != nil {
 		return iterator.NewError(err)
 	}
+	if si, ok := substringIndexerOf(qs); ok {
+		// the index only narrows down candidates, the pattern must still be checked
+		if nodes, ok := si.NodesWithSubstrings(context.TODO(), f.Substrings()...); ok {
+			it = iterator.NewAnd(qs, nodes, it)
+		}
+	}
 	rit := iterator.NewRegex(it, re, qs)
 	rit.AllowRefs(true)
 	return rit
 }
 
+// Substrings returns all parts of the pattern without wildcards.
+func (f Wildcard) Substrings() []string {
+	return strings.FieldsFunc(f.Pattern, func(r rune) bool {
+		return r == '%' || r == '?'
+	})
+}
+
+var _ ValueFilter = Text{}
+
+// Text is a full-text filter for string literals. All terms of the query must be present in the value.
+//
+// If the quad store, or a quad store it wraps, maintains a full-text index, it will be used to find matching values. Otherwise, values
+// are analyzed and matched one by one.
+//
+// Relevance scores are only known if the index ranks the matches (see text.ScoredSearcher). In this case
+// the score of each value can be saved to ScoreTag, and values with a score below MinScore are dropped.
+type Text struct {
+	Query    string
+	ScoreTag string
+	MinScore float64
+}
+
+func (f Text) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if ss, ok := text.ScoredSearcherOf(qs); ok && (f.ScoreTag != "" || f.MinScore > 0) {
+		hits, err := ss.SearchScored(context.TODO(), f.Query)
+		if err != nil {
+			return iterator.NewError(err)
+		}
+		scored := iterator.NewScored(f.ScoreTag)
+		for _, h := range hits {
+			if h.Score < f.MinScore {
+				continue
+			}
+			if ref := qs.ValueOf(h.Value); ref != nil {
+				scored.AddScored(ref, h.Score)
+			}
+		}
+		return iterator.NewAnd(qs, scored, it)
+	}
+	s, ok := text.SearcherOf(qs)
+	if !ok {
+		q := text.ParseQuery(nil, f.Query)
+		return iterator.NewValueFilter(qs, it, "text", func(v quad.Value) (bool, error) {
+			return q.Match(nil, v), nil
+		})
+	}
+	vals, err := s.SearchText(context.TODO(), f.Query)
+	if err != nil {
+		return iterator.NewError(err)
+	} else if len(vals) == 0 {
+		return iterator.NewNull()
+	}
+	fixed := iterator.NewFixed()
+	for _, v := range vals {
+		if ref := qs.ValueOf(v); ref != nil {
+			fixed.Add(ref)
+		}
+	}
+	return iterator.NewAnd(qs, fixed, it)
+}
+
+var _ ValueFilter = Fuzzy{}
+
+// Fuzzy filters string literals that are within a given edit (Levenshtein) distance from a value.
+//
+// If the quad store indexes substrings of values, the value is split into parts, and only nodes that contain
+// one of these parts are checked.
+type Fuzzy struct {
+	Value    string
+	Distance int
+}
+
+func (f Fuzzy) candidates(si graph.SubstringIndexer) (graph.Iterator, bool) {
+	pieces := text.FuzzyPieces(f.Value, f.Distance)
+	if len(pieces) == 0 {
+		return nil, false
+	}
+	its := make([]graph.Iterator, 0, len(pieces))
+	for _, p := range pieces {
+		it, ok := si.NodesWithSubstrings(context.TODO(), p)
+		if !ok {
+			for _, it := range its {
+				it.Close()
+			}
+			return nil, false
+		}
+		its = append(its, it)
+	}
+	return iterator.NewOr(its...), true
+}
+
+func (f Fuzzy) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if f.Distance < 0 {
+		return iterator.NewNull()
+	}
+	if si, ok := substringIndexerOf(qs); ok {
+		if nodes, ok := f.candidates(si); ok {
+			it = iterator.NewAnd(qs, nodes, it)
+		}
+	}
+	return iterator.NewValueFilter(qs, it, "fuzzy", func(v quad.Value) (bool, error) {
+		s, ok := text.TextOf(v)
+		return ok && text.Distance(s, f.Value, f.Distance) <= f.Distance, nil
+	})
+}
+
+var _ ValueFilter = SoundsLike{}
+
+// SoundsLike filters string literals where each word of the value sounds like some word of the literal.
+//
+// Algorithm is a name of a phonetic algorithm, as accepted by text.PhoneticFunc. Soundex is used by default.
+type SoundsLike struct {
+	Value     string
+	Algorithm string
+}
+
+func (f SoundsLike) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if _, err := text.PhoneticFunc(f.Algorithm); err != nil {
+		return iterator.NewError(err)
+	}
+	return iterator.NewValueFilter(qs, it, "sounds_like", func(v quad.Value) (bool, error) {
+		s, ok := text.TextOf(v)
+		if !ok {
+			return false, nil
+		}
+		return text.SoundsLike(f.Algorithm, f.Value, s)
+	})
+}
+
+var _ ValueFilter = Prefix{}
+
+// Prefix filters string literals that start with a given prefix, ignoring case.
+//
+// If the quad store keeps a sorted index of string literals, only nodes from the index are checked.
+type Prefix struct {
+	Prefix string
+}
+
+func (f Prefix) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if pi, ok := prefixIndexerOf(qs); ok && f.Prefix != "" {
+		if nodes, ok := pi.NodesWithPrefix(context.TODO(), f.Prefix, 0); ok {
+			it = iterator.NewAnd(qs, nodes, it)
+		}
+	}
+	return iterator.NewValueFilter(qs, it, "prefix", func(v quad.Value) (bool, error) {
+		return text.HasPrefix(v, f.Prefix), nil
+	})
+}
+
+var _ ValueFilter = Near{}
+
+// Near filters point values (see geo.ParsePoint) within a given distance from a location, in meters.
+//
+// If the quad store indexes locations of points, only points from the index are checked.
+type Near struct {
+	Lat, Lng float64
+	Radius   float64
+}
+
+// Region returns a circle that point values must be inside of.
+func (f Near) Region() geo.Circle {
+	return geo.Circle{Center: geo.Point{Lat: f.Lat, Lng: f.Lng}, Radius: f.Radius}
+}
+
+func (f Near) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if f.Radius < 0 || !f.Region().Center.Valid() {
+		return iterator.NewNull()
+	}
+	return buildGeoFilter(qs, it, "near", f.Region())
+}
+
+var _ ValueFilter = Within{}
+
+// Within filters point values (see geo.ParsePoint) that are inside a polygon.
+//
+// If the quad store indexes locations of points, only points from the index are checked.
+type Within struct {
+	Polygon geo.Polygon
+}
+
+func (f Within) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if len(f.Polygon) < 3 {
+		return iterator.NewNull()
+	}
+	return buildGeoFilter(qs, it, "within", f.Polygon)
+}
+
+var _ ValueFilter = WithinBox{}
+
+// WithinBox filters point values (see geo.ParsePoint) that are inside a latitude-longitude rectangle,
+// including its edges. The box may cross the antimeridian, see geo.Rect.
+//
+// If the quad store indexes locations of points, only points from the index are checked.
+type WithinBox struct {
+	Box geo.Rect
+}
+
+func (f WithinBox) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	if !f.Box.Valid() {
+		return iterator.NewNull()
+	}
+	return buildGeoFilter(qs, it, "box", f.Box)
+}
+
+func buildGeoFilter(qs graph.QuadStore, it graph.Iterator, name string, r geo.Region) graph.Iterator {
+	if gi, ok := geoIndexerOf(qs); ok {
+		if nodes, ok := gi.NodesInRegion(context.TODO(), r); ok {
+			it = iterator.NewAnd(qs, nodes, it)
+		}
+	}
+	return iterator.NewValueFilter(qs, it, name, func(v quad.Value) (bool, error) {
+		p, ok := geo.ParsePoint(v)
+		return ok && r.Contains(p), nil
+	})
+}
+
 // Count returns a count of objects in source as a single value. It always returns exactly one value.
 type Count struct {
 	Values Shape
@@ -464,15 +728,76 @@ func (s Quads) BuildIterator(qs graph.QuadStore) graph.Iterator {
 	if len(s) == 0 {
 		return qs.QuadsAllIterator()
 	}
-	its := make([]graph.Iterator, 0, len(s))
+	its := make([]graph.Iterator, 0, len(s)+1)
 	for _, f := range s {
 		its = append(its, f.buildIterator(qs))
 	}
+	if it, ok := s.timeCandidates(qs); ok {
+		// the index only narrows down candidates, objects must still be checked
+		its = append(its, it)
+	}
 	if len(its) == 1 {
 		return its[0]
 	}
 	return iterator.NewAnd(qs, its...)
 }
+
+// timeCandidates returns quads from the time index of the quad store, if quads have a single predicate
+// and objects are compared with time values.
+func (s Quads) timeCandidates(qs graph.QuadStore) (graph.Iterator, bool) {
+	ti, ok := timeIndexerOf(qs)
+	if !ok {
+		return nil, false
+	}
+	var (
+		pred     quad.Value
+		from, to time.Time
+	)
+	for _, f := range s {
+		switch f.Dir {
+		case quad.Predicate:
+			switch v := f.Values.(type) {
+			case Lookup:
+				if len(v) == 1 {
+					pred = v[0]
+				}
+			case Fixed:
+				if len(v) == 1 {
+					pred = qs.NameOf(v[0])
+				}
+			}
+		case quad.Object:
+			flt, ok := f.Values.(Filter)
+			if !ok {
+				continue
+			}
+			for _, vf := range flt.Filters {
+				c, ok := vf.(Comparison)
+				if !ok {
+					continue
+				}
+				t, ok := c.Val.(quad.Time)
+				if !ok {
+					continue
+				}
+				switch c.Op {
+				case iterator.CompareGT, iterator.CompareGTE:
+					if from.IsZero() || time.Time(t).After(from) {
+						from = time.Time(t)
+					}
+				case iterator.CompareLT, iterator.CompareLTE:
+					if to.IsZero() || time.Time(t).Before(to) {
+						to = time.Time(t)
+					}
+				}
+			}
+		}
+	}
+	if pred == nil || (from.IsZero() && to.IsZero()) {
+		return nil, false
+	}
+	return ti.QuadsInTimeRange(context.TODO(), pred, from, to)
+}
 func (s Quads) Optimize(r Optimizer) (Shape, bool) {
 	var opt bool
 	sw := 0
@@ -1361,6 +1686,39 @@ func (s Unique) Optimize(r Optimizer) (Shape, bool) {
 	return s, opt
 }
 
+// Sort orders query results by their values, or by values of a tag.
+type Sort struct {
+	From Shape
+	Desc bool   // sort in a descending order
+	Tag  string // sort by values of this tag, if set
+}
+
+func (s Sort) BuildIterator(qs graph.QuadStore) graph.Iterator {
+	if IsNull(s.From) {
+		return iterator.NewNull()
+	}
+	it := s.From.BuildIterator(qs)
+	if s.Tag != "" {
+		return iterator.NewSortByTag(qs, it, s.Tag, s.Desc)
+	}
+	return iterator.NewSort(qs, it, s.Desc)
+}
+func (s Sort) Optimize(r Optimizer) (Shape, bool) {
+	if IsNull(s.From) {
+		return nil, true
+	}
+	var opt bool
+	s.From, opt = s.From.Optimize(r)
+	if IsNull(s.From) {
+		return nil, true
+	}
+	if r != nil {
+		ns, nopt := r.OptimizeShape(s)
+		return ns, opt || nopt
+	}
+	return s, opt
+}
+
 // Save tags a results of query with provided tags.
 type Save struct {
 	Tags []string
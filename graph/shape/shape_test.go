@@ -15,11 +15,13 @@
 package shape_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/graphmock"
+	"github.com/cayleygraph/cayley/graph/memstore"
 	. "github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/stretchr/testify/assert"
@@ -387,3 +389,37 @@ func TestWalk(t *testing.T) {
 		"shape.QuadsAction",
 	}, types)
 }
+
+// indexedStore records substrings that were looked up in its index.
+type indexedStore struct {
+	graph.QuadStore
+	subs []string
+}
+
+func (qs *indexedStore) NodesWithSubstrings(ctx context.Context, subs ...string) (graph.Iterator, bool) {
+	qs.subs = subs
+	return qs.NodesAllIterator(), true
+}
+
+// wrappedStore is a wrapper that hides optional interfaces of the quad store.
+type wrappedStore struct {
+	graph.QuadStore
+}
+
+func (qs wrappedStore) Unwrapped() graph.QuadStore { return qs.QuadStore }
+func (qs wrappedStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return s, nil
+}
+
+func TestIndexThroughWrapper(t *testing.T) {
+	ind := &indexedStore{QuadStore: memstore.New(
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Alice"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("Bob"), nil),
+	)}
+	qs := wrappedStore{QuadStore: ind}
+	it := Wildcard{Pattern: "%lic%"}.BuildIterator(qs, qs.NodesAllIterator())
+	vals, err := graph.Iterate(context.TODO(), it).AllValues(qs)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("Alice")}, vals)
+	require.Equal(t, []string{"lic"}, ind.subs)
+}
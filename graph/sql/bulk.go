@@ -0,0 +1,252 @@
+package sql
+
+import (
+	"database/sql"
+	"io"
+	"strings"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/lru"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	// bulkParams is the maximal number of parameters in a single statement of BulkLoad.
+	bulkParams = 900
+
+	nodesBulkTable = "nodes_bulk"
+	quadsBulkTable = "quads_bulk"
+)
+
+var _ graph.BulkLoader = (*QuadStore)(nil)
+
+type bulkNode struct {
+	val  quad.Value
+	refs int
+}
+
+// BulkLoad loads quads into an empty database, bypassing transactions used by ApplyDeltas.
+// It returns graph.ErrCannotBulkLoad if the database is not empty.
+//
+// All quads are read and deduplicated in memory first. They are inserted into new tables without indexes
+// with multi-row statements, and the tables replace empty tables of the database. Indexes are created afterwards.
+// Databases that support schema changes in transactions swap the tables and create indexes atomically.
+func (qs *QuadStore) BulkLoad(qr quad.Reader) error {
+	var exists int
+	err := qs.db.QueryRow(`SELECT 1 FROM quads LIMIT 1;`).Scan(&exists)
+	if err == nil {
+		return graph.ErrCannotBulkLoad
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	var (
+		nodes = make(map[graph.ValueHash]*bulkNode)
+		// nodes are inserted in the order of the first occurrence
+		order []graph.ValueHash
+		seen  = make(map[[4]graph.ValueHash]struct{})
+		quads [][4]graph.ValueHash
+	)
+	for {
+		q, err := qr.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if !q.IsValid() {
+			continue
+		}
+		var hq [4]graph.ValueHash
+		for i, dir := range quad.Directions {
+			if v := q.Get(dir); v != nil {
+				hq[i] = graph.HashOf(v)
+			}
+		}
+		if _, ok := seen[hq]; ok {
+			continue
+		}
+		seen[hq] = struct{}{}
+		quads = append(quads, hq)
+		for i, dir := range quad.Directions {
+			v := q.Get(dir)
+			if v == nil {
+				continue
+			}
+			n := nodes[hq[i]]
+			if n == nil {
+				n = &bulkNode{val: v}
+				nodes[hq[i]] = n
+				order = append(order, hq[i])
+			}
+			n.refs++
+		}
+	}
+	seen = nil
+	clog.Infof("sql: bulk loading %d quads with %d nodes", len(quads), len(nodes))
+
+	for _, t := range []string{quadsBulkTable, nodesBulkTable} {
+		if _, err := qs.db.Exec(`DROP TABLE IF EXISTS ` + t + `;`); err != nil {
+			return qs.flavor.Error(err)
+		}
+	}
+	for _, stmt := range []string{qs.flavor.nodesTable(nodesBulkTable), qs.flavor.quadsTable(quadsBulkTable)} {
+		if _, err := qs.db.Exec(stmt); err != nil {
+			return qs.flavor.Error(err)
+		}
+	}
+	// staging tables are not used by queries, so they are filled in a single transaction
+	tx, err := qs.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = qs.bulkNodes(tx, nodes, order); err != nil {
+		tx.Rollback()
+		return err
+	}
+	nodes, order = nil, nil
+	if err = qs.bulkQuads(tx, quads); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	if err := qs.bulkSwap(); err != nil {
+		return err
+	}
+	qs.mu.Lock()
+	qs.size = int64(len(quads))
+	qs.sizes = lru.New(1024)
+	qs.mu.Unlock()
+	return nil
+}
+
+// bulkInsert inserts rows into a table with multi-row statements. All rows must have the same number of values.
+// Each row is followed by a given SQL expression, if it's not empty.
+func (qs *QuadStore) bulkInsert(tx *sql.Tx, table string, cols []string, extra string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	per := bulkParams / len(rows[0])
+	if per < 1 {
+		per = 1
+	}
+	for len(rows) > 0 {
+		batch := rows
+		if len(batch) > per {
+			batch = batch[:per]
+		}
+		rows = rows[len(batch):]
+		var (
+			sb   strings.Builder
+			args = make([]interface{}, 0, len(batch)*len(batch[0]))
+		)
+		sb.WriteString(`INSERT INTO ` + table + `(` + strings.Join(cols, ", ") + `) VALUES `)
+		for i, row := range batch {
+			if i != 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(")
+			for j, v := range row {
+				if j != 0 {
+					sb.WriteString(", ")
+				}
+				args = append(args, v)
+				sb.WriteString(qs.flavor.Placeholder(len(args)))
+			}
+			if extra != "" {
+				sb.WriteString(", " + extra)
+			}
+			sb.WriteString(")")
+		}
+		sb.WriteString(";")
+		if _, err := tx.Exec(sb.String(), args...); err != nil {
+			return qs.flavor.Error(err)
+		}
+	}
+	return nil
+}
+
+// bulkNodes inserts nodes into the staging table. Nodes of each value type are inserted together,
+// since they fill different columns.
+func (qs *QuadStore) bulkNodes(tx *sql.Tx, nodes map[graph.ValueHash]*bulkNode, order []graph.ValueHash) error {
+	var (
+		types []ValueType
+		rows  = make(map[ValueType][][]interface{})
+	)
+	for _, h := range order {
+		n := nodes[h]
+		typ, values, err := NodeValues(NodeHash{h}, n.val)
+		if err != nil {
+			return err
+		}
+		if _, ok := rows[typ]; !ok {
+			types = append(types, typ)
+		}
+		rows[typ] = append(rows[typ], append([]interface{}{n.refs}, values...))
+	}
+	for _, typ := range types {
+		cols := append([]string{"refs", "hash"}, typ.Columns()...)
+		if err := qs.bulkInsert(tx, nodesBulkTable, cols, "", rows[typ]); err != nil {
+			return err
+		}
+		delete(rows, typ)
+	}
+	return nil
+}
+
+// bulkQuads inserts quads into the staging table.
+func (qs *QuadStore) bulkQuads(tx *sql.Tx, quads [][4]graph.ValueHash) error {
+	cols := []string{"subject_hash", "predicate_hash", "object_hash", "label_hash", "ts"}
+	const step = 10000
+	for len(quads) > 0 {
+		batch := quads
+		if len(batch) > step {
+			batch = batch[:step]
+		}
+		quads = quads[len(batch):]
+		rows := make([][]interface{}, 0, len(batch))
+		for _, hq := range batch {
+			row := make([]interface{}, 0, len(hq))
+			for _, h := range hq {
+				row = append(row, NodeHash{h}.SQLValue())
+			}
+			rows = append(rows, row)
+		}
+		if err := qs.bulkInsert(tx, quadsBulkTable, cols, "CURRENT_TIMESTAMP", rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bulkSwap replaces empty tables of the database with staging tables and creates indexes.
+func (qs *QuadStore) bulkSwap() error {
+	stmts := []string{
+		`DROP TABLE quads;`,
+		`DROP TABLE nodes;`,
+		`ALTER TABLE ` + nodesBulkTable + ` RENAME TO nodes;`,
+		`ALTER TABLE ` + quadsBulkTable + ` RENAME TO quads;`,
+	}
+	stmts = append(stmts, qs.flavor.quadIndexes(qs.options)...)
+	if qs.flavor.NoSchemaChangesInTx {
+		for _, s := range stmts {
+			if _, err := qs.db.Exec(s); err != nil {
+				return qs.flavor.Error(err)
+			}
+		}
+		return nil
+	}
+	tx, err := qs.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, s := range stmts {
+		if _, err = tx.Exec(s); err != nil {
+			tx.Rollback()
+			return qs.flavor.Error(err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,114 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.Checker = (*QuadStore)(nil)
+
+var hashColumns = []string{"subject_hash", "predicate_hash", "object_hash", "label_hash"}
+
+type wrongRefs struct {
+	hash NodeHash
+	refs int64
+}
+
+// Check verifies that all quads refer to existing nodes, and that reference counters of nodes match
+// the number of quads that use them. Indexes are maintained by the database itself and are not checked.
+//
+// Counters are fixed by the repair, and nodes that are not used by any quad are removed.
+// Quads that refer to missing nodes cannot be repaired and are only reported.
+func (qs *QuadStore) Check(ctx context.Context, opts graph.CheckOptions) (*graph.CheckStats, error) {
+	st := &graph.CheckStats{}
+	if err := qs.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quads;`).Scan(&st.Quads); err != nil {
+		return nil, err
+	}
+	if err := qs.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM nodes;`).Scan(&st.Nodes); err != nil {
+		return nil, err
+	}
+	for _, col := range hashColumns {
+		rows, err := qs.db.QueryContext(ctx, `SELECT horizon, `+col+` FROM quads WHERE `+col+` IS NOT NULL
+	AND NOT EXISTS (SELECT 1 FROM nodes WHERE nodes.hash = quads.`+col+`);`)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				id int64
+				h  NodeHash
+			)
+			if err = rows.Scan(&id, &h); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			opts.Report(st, graph.Problem{Kind: graph.DanglingRef, Desc: fmt.Sprintf("quad %d refers to node %x", id, h.ValueHash[:])})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	query := `SELECT hash, refs`
+	for i, col := range hashColumns {
+		if i == 0 {
+			query += `, `
+		} else {
+			query += ` + `
+		}
+		query += `(SELECT COUNT(*) FROM quads WHERE ` + col + ` = nodes.hash)`
+	}
+	rows, err := qs.db.QueryContext(ctx, query+` FROM nodes;`)
+	if err != nil {
+		return nil, err
+	}
+	var fix []wrongRefs
+	for rows.Next() {
+		var (
+			h         NodeHash
+			refs, exp int64
+		)
+		if err = rows.Scan(&h, &refs, &exp); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if refs != exp {
+			opts.Report(st, graph.Problem{Kind: graph.WrongRefs, Repaired: opts.Repair,
+				Desc: fmt.Sprintf("node %x has %d references, expected %d", h.ValueHash[:], refs, exp)})
+			fix = append(fix, wrongRefs{hash: h, refs: exp})
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Repair || len(fix) == 0 {
+		return st, nil
+	}
+	tx, err := qs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `UPDATE nodes SET refs = `+qs.flavor.Placeholder(1)+` WHERE hash = `+qs.flavor.Placeholder(2)+`;`)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fix {
+		if _, err = stmt.ExecContext(ctx, f.refs, f.hash.SQLValue()); err != nil {
+			return nil, err
+		}
+	}
+	// same as in ApplyDeltas
+	if _, err = tx.ExecContext(ctx, `DELETE FROM nodes WHERE refs <= 0;`); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
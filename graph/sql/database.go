@@ -31,6 +31,7 @@ type Registration struct {
 	ConditionalIndexes bool   // database supports conditional indexes
 	FillFactor         bool   // database supports fill percent on indexes
 	NoForeignKeys      bool   // database has no support for FKs
+	PostGIS            bool   // database may have the PostGIS extension, enabled by the "postgis" option
 
 	QueryDialect
 	NoOffsetWithoutLimit bool // SELECT ... OFFSET can be used only with LIMIT
@@ -42,7 +43,8 @@ type Registration struct {
 	NoSchemaChangesInTx bool
 }
 
-func (r Registration) nodesTable() string {
+// nodesTable returns a statement that creates a table of nodes with a given name.
+func (r Registration) nodesTable(name string) string {
 	htyp := r.HashType
 	if htyp == "" {
 		htyp = "BYTEA"
@@ -59,7 +61,7 @@ func (r Registration) nodesTable() string {
 	if r.NodesTableExtra != "" {
 		end = ",\n" + r.NodesTableExtra + end
 	}
-	return `CREATE TABLE nodes (
+	return `CREATE TABLE ` + name + ` (
 	hash ` + htyp + ` PRIMARY KEY,
 	refs INT NOT NULL,
 	value ` + btyp + `,
@@ -75,7 +77,8 @@ func (r Registration) nodesTable() string {
 		end
 }
 
-func (r Registration) quadsTable() string {
+// quadsTable returns a statement that creates a table of quads with a given name, without indexes.
+func (r Registration) quadsTable(name string) string {
 	htyp := r.HashType
 	if htyp == "" {
 		htyp = "BYTEA"
@@ -84,7 +87,7 @@ func (r Registration) quadsTable() string {
 	if hztyp == "" {
 		hztyp = "SERIAL"
 	}
-	return `CREATE TABLE quads (
+	return `CREATE TABLE ` + name + ` (
 	horizon ` + hztyp + ` PRIMARY KEY,
 	subject_hash ` + htyp + ` NOT NULL,
 	predicate_hash ` + htyp + ` NOT NULL,
@@ -17,8 +17,10 @@ package sql
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
@@ -33,12 +35,18 @@ type Optimizer struct {
 
 	regexpOp             CmpOp
 	noOffsetWithoutLimit bool // blame mysql
+	postGIS              bool
 }
 
 func (opt *Optimizer) SetRegexpOp(op CmpOp) {
 	opt.regexpOp = op
 }
 
+// UsePostGIS enables the use of PostGIS functions for geospatial filters.
+func (opt *Optimizer) UsePostGIS() {
+	opt.postGIS = true
+}
+
 func (opt *Optimizer) NoOffsetWithoutLimit() {
 	opt.noOffsetWithoutLimit = true
 }
@@ -261,10 +269,77 @@ func (opt *Optimizer) optimizeFilter(from shape.Shape, f shape.ValueFilter) ([]W
 		return where, []Value{
 			StringVal(convRegexp(f.Re.String())),
 		}, true
+	case shape.Near:
+		if !opt.postGIS || f.Radius < 0 || !f.Region().Center.Valid() {
+			return nil, nil, false
+		}
+		fnc := fmt.Sprintf(`ST_Distance(%s, ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography)`,
+			wktGeography, ftoa(f.Lng), ftoa(f.Lat))
+		return geoWhere(
+			Where{Field: "value_string", Func: wktCase(fnc), Op: OpLTE, Value: Placeholder{}},
+		), []Value{
+			FloatVal(f.Radius),
+		}, true
+	case shape.Within:
+		if !opt.postGIS || len(f.Polygon) < 3 {
+			return nil, nil, false
+		}
+		// the polygon only has numbers, thus it's safe to inline it
+		fnc := fmt.Sprintf(`ST_Covers(ST_GeogFromText('SRID=4326;%s'), %s)`, f.Polygon.String(), wktGeography)
+		return geoWhere(
+			Where{Field: "value_string", Func: wktCase(fnc), Op: OpIsTrue},
+		), nil, true
+	case shape.WithinBox:
+		if !opt.postGIS || !f.Box.Valid() {
+			return nil, nil, false
+		}
+		// a box has straight edges in latitude-longitude coordinates, thus it's checked on geometries
+		boxes := []geo.Rect{f.Box}
+		if b := f.Box; b.MinLng > b.MaxLng {
+			// split the box at the antimeridian
+			west, east := b, b
+			west.MaxLng, east.MinLng = 180, -180
+			boxes = []geo.Rect{west, east}
+		}
+		var parts []string
+		for _, b := range boxes {
+			parts = append(parts, fmt.Sprintf(`ST_Covers(ST_MakeEnvelope(%s, %s, %s, %s, 4326), %s)`,
+				ftoa(b.MinLng), ftoa(b.MinLat), ftoa(b.MaxLng), ftoa(b.MaxLat), wktGeometry))
+		}
+		return geoWhere(
+			Where{Field: "value_string", Func: wktCase("(" + strings.Join(parts, " OR ") + ")"), Op: OpIsTrue},
+		), nil, true
 	default:
 		return nil, nil, false
 	}
 }
+
+const (
+	// wktPoint matches strings accepted by geo.ParseWKT, so PostGIS never fails to parse them.
+	wktPoint = `^\s*(<[^>]*>\s*)?POINT\s*\(\s*[-+]?[0-9]*\.?[0-9]+\s+[-+]?[0-9]*\.?[0-9]+\s*\)\s*$`
+	// wktGeography converts a WKT point to PostGIS geography, dropping the CRS prefix.
+	wktGeography = `ST_GeogFromText(regexp_replace(%[1]s, '^\s*<[^>]*>\s*', ''))`
+	// wktGeometry is the same as wktGeography, but converts the point to a PostGIS geometry.
+	wktGeometry = `ST_GeomFromText(regexp_replace(%[1]s, '^\s*<[^>]*>\s*', ''), 4326)`
+)
+
+// wktCase returns an SQL format string that evaluates the expression only for node values that are WKT points.
+func wktCase(expr string) string {
+	return `CASE WHEN (datatype IS NULL OR datatype = '` + string(geo.WKTLiteral) + `') AND %[1]s ~* '` + wktPoint + `' THEN ` + expr + ` END`
+}
+
+func geoWhere(w Where) []Where {
+	return []Where{
+		{Field: "iri", Op: OpIsNull},
+		{Field: "bnode", Op: OpIsNull},
+		{Field: "language", Op: OpIsNull},
+		w,
+	}
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
 func (opt *Optimizer) optimizeFilters(s shape.Filter) (shape.Shape, bool) {
 	switch from := s.From.(type) {
 	case shape.AllNodes:
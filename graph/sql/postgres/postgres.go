@@ -34,6 +34,7 @@ func init() {
 		QueryDialect:       QueryDialect,
 		ConditionalIndexes: true,
 		FillFactor:         true,
+		PostGIS:            true,
 		Error:              ConvError,
 		Estimated: func(table string) string {
 			return "SELECT reltuples::BIGINT AS estimate FROM pg_class WHERE relname='" + table + "';"
@@ -117,6 +117,8 @@ type QuadStore struct {
 	sizes        *lru.Cache
 	noSizes      bool
 	useEstimates bool
+	// options of the database; used to create indexes by BulkLoad
+	options graph.Options
 
 	mu   sync.RWMutex
 	size int64
@@ -224,8 +226,8 @@ func Init(typ string, addr string, options graph.Options) error {
 	}
 	defer conn.Close()
 
-	nodesSql := fl.nodesTable()
-	quadsSql := fl.quadsTable()
+	nodesSql := fl.nodesTable("nodes")
+	quadsSql := fl.quadsTable("quads")
 	indexes := fl.quadIndexes(options)
 
 	if fl.NoSchemaChangesInTx {
@@ -300,6 +302,7 @@ func New(typ string, addr string, options graph.Options) (graph.QuadStore, error
 		sizes:   lru.New(1024),
 		ids:     lru.New(1024),
 		noSizes: true, // Skip size checking by default.
+		options: options,
 	}
 	qs.opt.SetRegexpOp(qs.flavor.RegexpOp)
 	if qs.flavor.NoOffsetWithoutLimit {
@@ -314,6 +317,13 @@ func New(typ string, addr string, options graph.Options) (graph.QuadStore, error
 	if qs.useEstimates, err = options.BoolKey("use_estimates", false); err != nil {
 		return nil, err
 	}
+	if fl.PostGIS {
+		if on, err := options.BoolKey("postgis", false); err != nil {
+			return nil, err
+		} else if on {
+			qs.opt.UsePostGIS()
+		}
+	}
 	return qs, nil
 }
 
@@ -240,6 +240,8 @@ func (Placeholder) SQL(b *Builder) string {
 type Where struct {
 	Field string
 	Table string
+	// Func is an optional format string that is applied to the field name, for example, to compare a result of a function.
+	Func  string
 	Op    CmpOp
 	Value Expr
 }
@@ -249,6 +251,9 @@ func (w Where) SQL(b *Builder) string {
 	if w.Table != "" {
 		name = w.Table + "." + b.EscapeField(name)
 	}
+	if w.Func != "" {
+		name = fmt.Sprintf(w.Func, name)
+	}
 	parts := []string{name, string(w.Op)}
 	if w.Value != nil {
 		parts = append(parts, w.Value.SQL(b))
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/shape"
 	"github.com/cayleygraph/cayley/quad"
@@ -34,11 +35,12 @@ func sVals(arr ...string) []Value {
 }
 
 var shapeCases = []struct {
-	skip bool
-	name string
-	s    shape.Shape
-	qu   string
-	args []Value
+	skip    bool
+	postgis bool
+	name    string
+	s       shape.Shape
+	qu      string
+	args    []Value
 }{
 	{
 		name: "all nodes",
@@ -62,6 +64,47 @@ var shapeCases = []struct {
 		qu:   `SELECT hash AS ` + tagNode + ` FROM nodes WHERE value_string > $1 AND iri IS true`,
 		args: []Value{StringVal("a")},
 	},
+	{
+		name:    "near",
+		postgis: true,
+		s: shape.Filter{
+			From: shape.AllNodes{},
+			Filters: []shape.ValueFilter{
+				shape.Near{Lat: 37.7749, Lng: -122.4194, Radius: 5000},
+			},
+		},
+		qu: `SELECT hash AS ` + tagNode + ` FROM nodes WHERE iri IS NULL AND bnode IS NULL AND language IS NULL AND ` +
+			`CASE WHEN (datatype IS NULL OR datatype = 'http://www.opengis.net/ont/geosparql#wktLiteral') AND value_string ~* '` + wktPoint + `' ` +
+			`THEN ST_Distance(ST_GeogFromText(regexp_replace(value_string, '^\s*<[^>]*>\s*', '')), ST_SetSRID(ST_MakePoint(-122.4194, 37.7749), 4326)::geography) END <= $1`,
+		args: []Value{FloatVal(5000)},
+	},
+	{
+		name:    "within",
+		postgis: true,
+		s: shape.Filter{
+			From: shape.AllNodes{},
+			Filters: []shape.ValueFilter{
+				shape.Within{Polygon: geo.Polygon{{Lat: 0, Lng: 0}, {Lat: 0, Lng: 10}, {Lat: 10, Lng: 10}}},
+			},
+		},
+		qu: `SELECT hash AS ` + tagNode + ` FROM nodes WHERE iri IS NULL AND bnode IS NULL AND language IS NULL AND ` +
+			`CASE WHEN (datatype IS NULL OR datatype = 'http://www.opengis.net/ont/geosparql#wktLiteral') AND value_string ~* '` + wktPoint + `' ` +
+			`THEN ST_Covers(ST_GeogFromText('SRID=4326;POLYGON((0 0, 10 0, 10 10, 0 0))'), ST_GeogFromText(regexp_replace(value_string, '^\s*<[^>]*>\s*', ''))) END IS true`,
+	},
+	{
+		name:    "within box",
+		postgis: true,
+		s: shape.Filter{
+			From: shape.AllNodes{},
+			Filters: []shape.ValueFilter{
+				shape.WithinBox{Box: geo.Rect{MinLat: -10, MinLng: 170, MaxLat: 10, MaxLng: -170}},
+			},
+		},
+		qu: `SELECT hash AS ` + tagNode + ` FROM nodes WHERE iri IS NULL AND bnode IS NULL AND language IS NULL AND ` +
+			`CASE WHEN (datatype IS NULL OR datatype = 'http://www.opengis.net/ont/geosparql#wktLiteral') AND value_string ~* '` + wktPoint + `' ` +
+			`THEN (ST_Covers(ST_MakeEnvelope(170, -10, 180, 10, 4326), ST_GeomFromText(regexp_replace(value_string, '^\s*<[^>]*>\s*', ''), 4326)) OR ` +
+			`ST_Covers(ST_MakeEnvelope(-180, -10, -170, 10, 4326), ST_GeomFromText(regexp_replace(value_string, '^\s*<[^>]*>\s*', ''), 4326))) END IS true`,
+	},
 	{
 		name: "gt string",
 		s: shape.Filter{
@@ -364,6 +407,9 @@ func TestSQLShapes(t *testing.T) {
 	for _, c := range shapeCases {
 		t.Run(c.name, func(t *testing.T) {
 			opt := NewOptimizer()
+			if c.postgis {
+				opt.UsePostGIS()
+			}
 			s, ok := c.s.Optimize(opt)
 			if c.skip {
 				t.Skipf("%#v", s)
@@ -1,6 +1,8 @@
 package sqltest
 
 import (
+	"fmt"
+	"sort"
 	"testing"
 	"unicode/utf8"
 
@@ -39,6 +41,10 @@ func TestAll(t *testing.T, typ string, fnc DatabaseFunc, c *Config) {
 		t.Parallel()
 		testZeroRune(t, create)
 	})
+	t.Run("bulk load", func(t *testing.T) {
+		t.Parallel()
+		testBulkLoad(t, create)
+	})
 }
 
 func BenchmarkAll(t *testing.B, typ string, fnc DatabaseFunc, c *Config) {
@@ -91,3 +97,38 @@ func testZeroRune(t testing.TB, create testutil.DatabaseFunc) {
 	require.NoError(t, err)
 	require.Equal(t, obj, qs.NameOf(qs.ValueOf(quad.Raw(obj.String()))))
 }
+
+func testBulkLoad(t testing.TB, create testutil.DatabaseFunc) {
+	qs, opts, closer := create(t)
+	defer closer()
+
+	var quads []quad.Quad
+	for i := 0; i < 300; i++ {
+		n := quad.IRI(fmt.Sprintf("n%d", i))
+		quads = append(quads,
+			quad.Make(n, quad.IRI("name"), quad.String(fmt.Sprintf("name %d", i)), nil),
+			quad.Make(n, quad.IRI("age"), quad.Int(i), quad.IRI("g")),
+		)
+	}
+	quads = append(quads, quads[5])
+
+	bl := qs.(graph.BulkLoader)
+	require.NoError(t, bl.BulkLoad(quad.NewReader(quads)))
+	require.Equal(t, int64(600), qs.Size())
+	require.Equal(t, graph.ErrCannotBulkLoad, bl.BulkLoad(quad.NewReader(quads[:1])))
+
+	got, err := quad.ReadAll(graph.NewQuadStoreReader(qs))
+	require.NoError(t, err)
+	exp := append([]quad.Quad{}, quads[:600]...)
+	sort.Sort(quad.ByQuadString(exp))
+	sort.Sort(quad.ByQuadString(got))
+	require.Equal(t, exp, got)
+
+	// indexes are created, so duplicates are rejected and quads can be removed
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: quads[0], Action: graph.Add}}, graph.IgnoreOpts{})
+	require.Error(t, err)
+	w := testutil.MakeWriter(t, qs, opts)
+	require.NoError(t, w.RemoveQuad(quads[0]))
+	require.NoError(t, w.AddQuad(quad.MakeIRI("n1", "follows", "n2", "")))
+	require.Equal(t, int64(600), qs.Size())
+}
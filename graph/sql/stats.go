@@ -0,0 +1,23 @@
+package sql
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var _ graph.IndexStatser = (*QuadStore)(nil)
+
+// IndexStats implements graph.IndexStatser. It reports the number of rows in each table; sizes are not reported,
+// since the storage is managed by the database.
+func (qs *QuadStore) IndexStats(ctx context.Context) ([]graph.IndexStats, error) {
+	var out []graph.IndexStats
+	for _, table := range []string{"quads", "nodes"} {
+		st := graph.IndexStats{Name: table}
+		if err := qs.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+`;`).Scan(&st.Entries); err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// StatisticsKey is a metadata key used by quad stores that persist statistics in their metadata.
+const StatisticsKey = "statistics"
+
+// Statistics summarize contents of a quad store for the optimizer. They are computed by a scan
+// of all quads and are not updated by writes, thus they may be out of date.
+type Statistics struct {
+	// Time of the analysis.
+	Time time.Time `json:"time"`
+	// Quads is the number of quads at the time of the analysis.
+	Quads int64 `json:"quads"`
+	// Horizon of the quad store at the time of the analysis, or zero if the quad store doesn't report it.
+	Horizon int64 `json:"horizon,omitempty"`
+	// Directions describe values in each direction of quads: subject, predicate, object and label.
+	Directions [4]ValueStatistics `json:"directions"`
+	// Predicates are the most frequent predicates, sorted by the number of quads in descending order.
+	Predicates []PredicateStatistics `json:"predicates,omitempty"`
+}
+
+// ValueStatistics describe values in one direction of quads.
+type ValueStatistics struct {
+	// Values is the number of distinct values.
+	Values int64 `json:"values"`
+	// Histogram is a distribution of the number of quads per value: Histogram[i] is the number of values
+	// with 2^i to 2^(i+1)-1 quads.
+	Histogram []int64 `json:"histogram,omitempty"`
+}
+
+// Add counts a value with a given number of quads.
+func (s *ValueStatistics) Add(quads int64) {
+	if quads <= 0 {
+		return
+	}
+	s.Values++
+	i := bits.Len64(uint64(quads)) - 1
+	for len(s.Histogram) <= i {
+		s.Histogram = append(s.Histogram, 0)
+	}
+	s.Histogram[i]++
+}
+
+// PredicateStatistics is the number of quads with a predicate.
+type PredicateStatistics struct {
+	// Predicate is a value in N-Quads format.
+	Predicate string `json:"predicate"`
+	Quads     int64  `json:"quads"`
+}
+
+// Dir returns statistics of values in a given direction.
+func (s *Statistics) Dir(d quad.Direction) ValueStatistics {
+	if d < quad.Subject || d > quad.Label {
+		return ValueStatistics{}
+	}
+	return s.Directions[d-quad.Subject]
+}
+
+// Fanout returns the average number of quads per value in a given direction, rounded up.
+// It returns zero if the direction has no values.
+func (s *Statistics) Fanout(d quad.Direction) int64 {
+	v := s.Dir(d).Values
+	if v == 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(s.Quads) / float64(v)))
+}
+
+// StatisticsStore is an optional interface for quad stores that keep statistics for the optimizer.
+type StatisticsStore interface {
+	// Statistics returns the last saved statistics, or nil if the quad store was not analyzed.
+	Statistics() *Statistics
+	// SetStatistics replaces statistics of the quad store.
+	SetStatistics(ctx context.Context, st *Statistics) error
+}
+
+// StatisticsOf returns statistics of a quad store, if it keeps them.
+func StatisticsOf(qs QuadStore) *Statistics {
+	if s, ok := qs.(StatisticsStore); ok {
+		return s.Statistics()
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import "context"
+
+// IndexStats describes the size of a single index of a quad store.
+type IndexStats struct {
+	Name string
+	// Entries is the number of entries in the index.
+	Entries int64
+	// Bytes is the size of keys and values of the index, or zero if it's unknown.
+	Bytes int64
+}
+
+// IndexStatser is an optional interface for quad stores that can report sizes of their indexes.
+type IndexStatser interface {
+	// IndexStats returns sizes of all indexes of the quad store.
+	IndexStats(ctx context.Context) ([]IndexStats, error)
+}
+
+// Versioner is an optional interface for quad stores with a versioned data format.
+type Versioner interface {
+	// DataVersion returns the version of the data format of the quad store.
+	DataVersion() int64
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal
+
+import (
+	"context"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// ExpiresAt is a predicate that sets the time after which all quads with a given label are deleted by Sweep.
+//
+//	<cache1> <cayley:expiresAt> "2019-06-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+const ExpiresAt = quad.IRI("cayley:expiresAt")
+
+// SweepBatch is the maximal number of quads deleted by Sweep in one transaction.
+const SweepBatch = 10000
+
+// Expiry returns a quad that sets the expiration time of quads with a given label.
+func Expiry(label quad.Value, at time.Time) quad.Quad {
+	return quad.Quad{Subject: label, Predicate: ExpiresAt, Object: quad.Time(at)}
+}
+
+// ExpiredAt returns a set of labels that expire at or before a given time.
+func ExpiredAt(t time.Time) shape.Shape {
+	return bound(ExpiresAt, iterator.CompareLTE, t)
+}
+
+// Sweep deletes all quads with labels that expired at or before a given time, as well as expiration quads
+// of these labels. It returns the number of deleted quads.
+//
+// Large labels are deleted in multiple transactions. If the sweep is interrupted, the expiration quads
+// are kept and the next sweep deletes the rest.
+func Sweep(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, now time.Time) (int, error) {
+	labels, err := expiredLabels(ctx, qs, now)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, l := range labels {
+		name := qs.NameOf(l)
+		quads, err := collectQuads(ctx, qs, quad.Label, l, nil)
+		if err != nil {
+			return n, err
+		}
+		// expiration quads go last, in case a batch fails
+		meta, err := collectQuads(ctx, qs, quad.Subject, l, func(q quad.Quad) bool {
+			return q.Predicate == ExpiresAt && q.Label != name
+		})
+		if err != nil {
+			return n, err
+		}
+		quads = append(quads, meta...)
+		for len(quads) > 0 {
+			batch := quads
+			if len(batch) > SweepBatch {
+				batch = batch[:SweepBatch]
+			}
+			tx := graph.NewTransactionN(len(batch))
+			for _, q := range batch {
+				tx.RemoveQuad(q)
+			}
+			if err = qw.ApplyTransaction(tx); err != nil {
+				return n, err
+			}
+			n += len(batch)
+			quads = quads[len(batch):]
+		}
+		clog.Infof("temporal: deleted expired label %v", name)
+	}
+	return n, nil
+}
+
+// RunSweeper calls Sweep with the current time once per interval, until the context is cancelled.
+// Errors are logged and the sweep is retried on the next tick.
+func RunSweeper(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := Sweep(ctx, qs, qw, time.Now()); err != nil && ctx.Err() == nil {
+			clog.Errorf("temporal: sweep failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func expiredLabels(ctx context.Context, qs graph.QuadStore, now time.Time) ([]graph.Value, error) {
+	it := shape.BuildIterator(qs, ExpiredAt(now))
+	defer it.Close()
+	var out []graph.Value
+	for it.Next(ctx) {
+		out = append(out, it.Result())
+	}
+	return out, it.Err()
+}
+
+func collectQuads(ctx context.Context, qs graph.QuadStore, d quad.Direction, v graph.Value, keep func(quad.Quad) bool) ([]quad.Quad, error) {
+	it := qs.QuadIterator(d, v)
+	defer it.Close()
+	var out []quad.Quad
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		if keep == nil || keep(q) {
+			out = append(out, q)
+		}
+	}
+	return out, it.Err()
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestSweep(t *testing.T) {
+	ctx := context.TODO()
+	var (
+		alice = quad.IRI("alice")
+		name  = quad.IRI("name")
+		knows = quad.IRI("knows")
+	)
+	qs := memstore.New(
+		quad.Make(alice, name, quad.String("Alice"), nil),
+		quad.Make(alice, knows, quad.IRI("bob"), quad.IRI("cache1")),
+		quad.Make(alice, knows, quad.IRI("carol"), quad.IRI("cache1")),
+		quad.Make(alice, knows, quad.IRI("dave"), quad.IRI("cache2")),
+		temporal.Expiry(quad.IRI("cache1"), date(2019, 1, 1)),
+		temporal.Expiry(quad.IRI("cache2"), date(2019, 6, 1)),
+	)
+	all := func() []quad.Quad {
+		var out []quad.Quad
+		it := qs.QuadsAllIterator()
+		defer it.Close()
+		for it.Next(ctx) {
+			out = append(out, qs.Quad(it.Result()))
+		}
+		require.NoError(t, it.Err())
+		return out
+	}
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	n, err := temporal.Sweep(ctx, qs, qw, date(2018, 12, 31))
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.Len(t, all(), 6)
+
+	// the expiration time is inclusive
+	n, err = temporal.Sweep(ctx, qs, qw, date(2019, 1, 1))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Len(t, all(), 3)
+
+	require.ElementsMatch(t, []quad.Quad{
+		quad.Make(alice, name, quad.String("Alice"), nil),
+		quad.Make(alice, knows, quad.IRI("dave"), quad.IRI("cache2")),
+		temporal.Expiry(quad.IRI("cache2"), date(2019, 6, 1)),
+	}, all())
+
+	n, err = temporal.Sweep(ctx, qs, qw, date(2020, 1, 1))
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, all(), 1)
+}
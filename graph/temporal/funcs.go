@@ -0,0 +1,276 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Unit is a unit of calendar time.
+type Unit int
+
+const (
+	Second Unit = iota
+	Minute
+	Hour
+	Day
+	Week
+	Month
+	Year
+)
+
+// unitNames are names accepted by ParseUnit. The first name of each unit is used by Math.
+var unitNames = [...][]string{
+	Second: {"s", "second", "seconds"},
+	Minute: {"m", "minute", "minutes"},
+	Hour:   {"h", "hour", "hours"},
+	Day:    {"d", "day", "days"},
+	Week:   {"w", "week", "weeks"},
+	Month:  {"M", "month", "months"},
+	Year:   {"y", "year", "years"},
+}
+
+func (u Unit) String() string {
+	if u < 0 || int(u) >= len(unitNames) {
+		return "Unit(" + strconv.Itoa(int(u)) + ")"
+	}
+	return unitNames[u][1]
+}
+
+// ParseUnit returns a unit by its name, for example "day", "days" or "d". Note that "m" is a minute
+// and "M" is a month.
+func ParseUnit(s string) (Unit, error) {
+	for u, names := range unitNames {
+		for i, name := range names {
+			// only the short name of a month is case-sensitive
+			if s == name || (i != 0 && strings.EqualFold(s, name)) {
+				return Unit(u), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("temporal: unknown time unit %q", s)
+}
+
+// Truncate rounds the time down to the start of the unit, in the location of the time.
+// Weeks start on Monday.
+func Truncate(t time.Time, u Unit) time.Time {
+	y, m, d := t.Date()
+	switch u {
+	case Year:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location())
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case Week:
+		days := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-days, 0, 0, 0, 0, t.Location())
+	case Day:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case Hour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case Minute:
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+}
+
+// Add adds n units to the time. Days, weeks, months and years follow the calendar, thus adding a day
+// keeps the time of the day across daylight saving changes, and months are normalized as in time.AddDate.
+func Add(t time.Time, n int, u Unit) time.Time {
+	switch u {
+	case Year:
+		return t.AddDate(n, 0, 0)
+	case Month:
+		return t.AddDate(0, n, 0)
+	case Week:
+		return t.AddDate(0, 0, 7*n)
+	case Day:
+		return t.AddDate(0, 0, n)
+	case Hour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case Minute:
+		return t.Add(time.Duration(n) * time.Minute)
+	}
+	return t.Add(time.Duration(n) * time.Second)
+}
+
+// Part is a component of a time returned by Extract.
+type Part int
+
+const (
+	PartYear Part = iota
+	PartMonth
+	PartDay
+	PartHour
+	PartMinute
+	PartSecond
+	// PartWeekday is a day of the week, from 0 for Sunday to 6 for Saturday.
+	PartWeekday
+	// PartYearDay is a day of the year, starting from 1.
+	PartYearDay
+)
+
+var partNames = [...]string{
+	PartYear:    "year",
+	PartMonth:   "month",
+	PartDay:     "day",
+	PartHour:    "hour",
+	PartMinute:  "minute",
+	PartSecond:  "second",
+	PartWeekday: "weekday",
+	PartYearDay: "yearday",
+}
+
+func (p Part) String() string {
+	if p < 0 || int(p) >= len(partNames) {
+		return "Part(" + strconv.Itoa(int(p)) + ")"
+	}
+	return partNames[p]
+}
+
+// ParsePart returns a part of a time by its name, as returned by String.
+func ParsePart(s string) (Part, error) {
+	for p, name := range partNames {
+		if strings.EqualFold(s, name) {
+			return Part(p), nil
+		}
+	}
+	return 0, fmt.Errorf("temporal: unknown time part %q", s)
+}
+
+// Extract returns a part of the time, in the location of the time. Months start from 1.
+func Extract(t time.Time, p Part) int {
+	switch p {
+	case PartYear:
+		return t.Year()
+	case PartMonth:
+		return int(t.Month())
+	case PartDay:
+		return t.Day()
+	case PartHour:
+		return t.Hour()
+	case PartMinute:
+		return t.Minute()
+	case PartSecond:
+		return t.Second()
+	case PartWeekday:
+		return int(t.Weekday())
+	case PartYearDay:
+		return t.YearDay()
+	}
+	return 0
+}
+
+var _ shape.ValueFilter = PartFilter{}
+
+// PartFilter filters time values with a given part, for example, all times in 2019.
+// Times are checked in UTC, unless Location is set.
+type PartFilter struct {
+	Part     Part
+	Value    int
+	Location *time.Location
+}
+
+func (f PartFilter) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return iterator.NewValueFilter(qs, it, f.Part.String(), func(v quad.Value) (bool, error) {
+		t, ok := v.(quad.Time)
+		return ok && Extract(time.Time(t).In(loc), f.Part) == f.Value, nil
+	})
+}
+
+// Math evaluates a date math expression: an anchor time followed by any number of operations.
+// The anchor is either "now", or a time in RFC 3339 format followed by "||". Operations are applied in order:
+//
+//	+1d, -2h   add or subtract a number of units
+//	/d         round down to the start of the unit
+//
+// Units are the short names accepted by ParseUnit: y, M, w, d, h, m and s. For example, "now-1d/d" is
+// the start of yesterday, and "2019-01-15T10:00:00Z||/M+1M" is the start of February 2019.
+func Math(expr string, now time.Time) (time.Time, error) {
+	var (
+		t    time.Time
+		rest string
+	)
+	if strings.HasPrefix(expr, "now") {
+		t, rest = now, expr[3:]
+	} else if i := strings.Index(expr, "||"); i >= 0 {
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, expr[:i]); err != nil {
+			return time.Time{}, fmt.Errorf("temporal: invalid anchor of %q: %v", expr, err)
+		}
+		rest = expr[i+2:]
+	} else {
+		return time.Time{}, fmt.Errorf("temporal: expected \"now\" or \"||\" in %q", expr)
+	}
+	for rest != "" {
+		op := rest[0]
+		rest = rest[1:]
+		n := 1
+		if op == '+' || op == '-' {
+			i := 0
+			for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+				i++
+			}
+			if i != 0 {
+				n, _ = strconv.Atoi(rest[:i])
+				rest = rest[i:]
+			}
+			if op == '-' {
+				n = -n
+			}
+		} else if op != '/' {
+			return time.Time{}, fmt.Errorf("temporal: unexpected %q in %q", op, expr)
+		}
+		if rest == "" {
+			return time.Time{}, fmt.Errorf("temporal: expected a unit at the end of %q", expr)
+		}
+		u, err := parseShortUnit(rest[0])
+		if err != nil {
+			return time.Time{}, err
+		}
+		rest = rest[1:]
+		if op == '/' {
+			t = Truncate(t, u)
+		} else {
+			t = Add(t, n, u)
+		}
+	}
+	return t, nil
+}
+
+// IsMath checks if the string looks like a date math expression, as accepted by Math.
+func IsMath(s string) bool {
+	return strings.HasPrefix(s, "now") || strings.Contains(s, "||")
+}
+
+func parseShortUnit(c byte) (Unit, error) {
+	for u, names := range unitNames {
+		if names[0][0] == c {
+			return Unit(u), nil
+		}
+	}
+	return 0, fmt.Errorf("temporal: unknown time unit %q", c)
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func TestTruncate(t *testing.T) {
+	// a Wednesday
+	ts := time.Date(2019, 1, 2, 15, 4, 5, 6, time.UTC)
+	for _, c := range []struct {
+		unit temporal.Unit
+		exp  time.Time
+	}{
+		{temporal.Year, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{temporal.Month, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{temporal.Week, time.Date(2018, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{temporal.Day, time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{temporal.Hour, time.Date(2019, 1, 2, 15, 0, 0, 0, time.UTC)},
+		{temporal.Minute, time.Date(2019, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{temporal.Second, time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC)},
+	} {
+		require.Equal(t, c.exp, temporal.Truncate(ts, c.unit), "%v", c.unit)
+	}
+}
+
+func TestMath(t *testing.T) {
+	now := time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC)
+	for _, c := range []struct {
+		expr string
+		exp  time.Time
+	}{
+		{"now", now},
+		{"now-1d/d", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"now+2h", now.Add(2 * time.Hour)},
+		{"now/M-1M", time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{"now-30m", now.Add(-30 * time.Minute)},
+		{"2019-01-15T10:00:00Z||/M+1M", time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"2019-01-15T10:00:00Z||", time.Date(2019, 1, 15, 10, 0, 0, 0, time.UTC)},
+	} {
+		got, err := temporal.Math(c.expr, now)
+		require.NoError(t, err, c.expr)
+		require.Equal(t, c.exp, got, c.expr)
+	}
+	for _, expr := range []string{"yesterday", "now-1", "now*2d", "now-1x", "2019-01-15||+1d"} {
+		_, err := temporal.Math(expr, now)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestPartFilter(t *testing.T) {
+	ctx := context.TODO()
+	at := quad.IRI("at")
+	qs := memstore.New(
+		quad.Make(quad.IRI("a"), at, quad.Time(time.Date(2018, 12, 31, 23, 0, 0, 0, time.UTC)), nil),
+		quad.Make(quad.IRI("b"), at, quad.Time(time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)), nil),
+		quad.Make(quad.IRI("c"), at, quad.String("2019"), nil),
+	)
+	find := func(f temporal.PartFilter) []quad.Value {
+		vals, err := path.StartPath(qs).HasFilter(at, false, f).Iterate(ctx).AllValues(qs)
+		require.NoError(t, err)
+		return vals
+	}
+	require.Equal(t, []quad.Value{quad.IRI("b")}, find(temporal.PartFilter{Part: temporal.PartYear, Value: 2019}))
+	// in UTC+2, the first event is in 2019 as well
+	loc := time.FixedZone("UTC+2", 2*3600)
+	require.Len(t, find(temporal.PartFilter{Part: temporal.PartYear, Value: 2019, Location: loc}), 2)
+	require.Equal(t, []quad.Value{quad.IRI("b")}, find(temporal.PartFilter{Part: temporal.PartWeekday, Value: int(time.Wednesday)}))
+}
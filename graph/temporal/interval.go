@@ -0,0 +1,158 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Interval is a period of time between two moments.
+type Interval struct {
+	Start, End time.Time
+}
+
+// Relation is one of the thirteen relations of Allen's interval algebra.
+type Relation int
+
+const (
+	Before       Relation = iota // ends before the other one starts
+	After                        // starts after the other one ends
+	Meets                        // ends when the other one starts
+	MetBy                        // starts when the other one ends
+	Overlaps                     // starts first and ends during the other one
+	OverlappedBy                 // starts during the other one and ends last
+	During                       // starts after and ends before the other one
+	Contains                     // starts before and ends after the other one
+	Starts                       // starts at the same time and ends first
+	StartedBy                    // starts at the same time and ends last
+	Finishes                     // ends at the same time and starts last
+	FinishedBy                   // ends at the same time and starts first
+	Equals                       // starts and ends at the same time
+)
+
+var relationNames = []string{
+	Before:       "before",
+	After:        "after",
+	Meets:        "meets",
+	MetBy:        "metBy",
+	Overlaps:     "overlaps",
+	OverlappedBy: "overlappedBy",
+	During:       "during",
+	Contains:     "contains",
+	Starts:       "starts",
+	StartedBy:    "startedBy",
+	Finishes:     "finishes",
+	FinishedBy:   "finishedBy",
+	Equals:       "equals",
+}
+
+func (r Relation) String() string {
+	if r >= 0 && int(r) < len(relationNames) {
+		return relationNames[r]
+	}
+	return fmt.Sprintf("relation(%d)", int(r))
+}
+
+// ParseRelation returns a relation by its name, as returned by String.
+func ParseRelation(s string) (Relation, error) {
+	for i, name := range relationNames {
+		if name == s {
+			return Relation(i), nil
+		}
+	}
+	return 0, fmt.Errorf("temporal: unknown interval relation: %q", s)
+}
+
+// limit is a constraint on the start or end of an interval.
+type limit struct {
+	end bool
+	op  iterator.Operator
+	t   time.Time
+}
+
+// bounds returns constraints on intervals that are in the relation to a given interval.
+func (r Relation) bounds(iv Interval) []limit {
+	s, e := iv.Start, iv.End
+	var (
+		startLT = func(t time.Time) limit { return limit{false, iterator.CompareLT, t} }
+		startGT = func(t time.Time) limit { return limit{false, iterator.CompareGT, t} }
+		endLT   = func(t time.Time) limit { return limit{true, iterator.CompareLT, t} }
+		endGT   = func(t time.Time) limit { return limit{true, iterator.CompareGT, t} }
+		startEq = []limit{{false, iterator.CompareGTE, s}, {false, iterator.CompareLTE, s}}
+		endEq   = []limit{{true, iterator.CompareGTE, e}, {true, iterator.CompareLTE, e}}
+	)
+	switch r {
+	case Before:
+		return []limit{endLT(s)}
+	case After:
+		return []limit{startGT(e)}
+	case Meets:
+		return []limit{{true, iterator.CompareGTE, s}, {true, iterator.CompareLTE, s}}
+	case MetBy:
+		return []limit{{false, iterator.CompareGTE, e}, {false, iterator.CompareLTE, e}}
+	case Overlaps:
+		return []limit{startLT(s), endGT(s), endLT(e)}
+	case OverlappedBy:
+		return []limit{startGT(s), startLT(e), endGT(e)}
+	case During:
+		return []limit{startGT(s), endLT(e)}
+	case Contains:
+		return []limit{startLT(s), endGT(e)}
+	case Starts:
+		return append(startEq, endLT(e))
+	case StartedBy:
+		return append(startEq, endGT(e))
+	case Finishes:
+		return append(endEq, startGT(s))
+	case FinishedBy:
+		return append(endEq, startLT(s))
+	case Equals:
+		return append(startEq, endEq...)
+	}
+	return nil
+}
+
+// Filters returns filters for values of start and end predicates of intervals that are in the relation
+// to a given interval. Both lists compare values with the time bounds, thus they can use time indexes.
+func (r Relation) Filters(iv Interval) (start, end []shape.ValueFilter) {
+	for _, b := range r.bounds(iv) {
+		f := shape.Comparison{Op: b.op, Val: quad.Time(b.t)}
+		if b.end {
+			end = append(end, f)
+		} else {
+			start = append(start, f)
+		}
+	}
+	return start, end
+}
+
+// Holds checks if the relation holds between two intervals.
+func (r Relation) Holds(a, b Interval) bool {
+	for _, c := range r.bounds(b) {
+		t := a.Start
+		if c.end {
+			t = a.End
+		}
+		if !iterator.RunTimeOp(t, c.op, c.t) {
+			return false
+		}
+	}
+	return len(r.bounds(b)) != 0
+}
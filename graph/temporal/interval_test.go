@@ -0,0 +1,102 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func hour(h int) time.Time {
+	return time.Date(2019, 1, 2, h, 0, 0, 0, time.UTC)
+}
+
+func between(from, to int) temporal.Interval {
+	return temporal.Interval{Start: hour(from), End: hour(to)}
+}
+
+func TestRelations(t *testing.T) {
+	ref := between(10, 14)
+	cases := []struct {
+		rel temporal.Relation
+		iv  temporal.Interval
+	}{
+		{temporal.Before, between(7, 9)},
+		{temporal.After, between(15, 16)},
+		{temporal.Meets, between(8, 10)},
+		{temporal.MetBy, between(14, 16)},
+		{temporal.Overlaps, between(8, 12)},
+		{temporal.OverlappedBy, between(12, 16)},
+		{temporal.During, between(11, 13)},
+		{temporal.Contains, between(8, 16)},
+		{temporal.Starts, between(10, 12)},
+		{temporal.StartedBy, between(10, 16)},
+		{temporal.Finishes, between(12, 14)},
+		{temporal.FinishedBy, between(8, 14)},
+		{temporal.Equals, between(10, 14)},
+	}
+	for _, c := range cases {
+		r, err := temporal.ParseRelation(c.rel.String())
+		require.NoError(t, err)
+		require.Equal(t, c.rel, r)
+		// exactly one relation holds between two intervals
+		for _, c2 := range cases {
+			require.Equal(t, c.rel == c2.rel, c2.rel.Holds(c.iv, ref), "%v %v", c.rel, c2.rel)
+		}
+	}
+	_, err := temporal.ParseRelation("intersects")
+	require.Error(t, err)
+}
+
+func TestInterval(t *testing.T) {
+	ctx := context.TODO()
+	start, end := quad.IRI("start"), quad.IRI("end")
+	meeting := func(name string, from, to int) []quad.Quad {
+		return []quad.Quad{
+			quad.Make(quad.IRI(name), start, quad.Time(hour(from)), nil),
+			quad.Make(quad.IRI(name), end, quad.Time(hour(to)), nil),
+		}
+	}
+	var quads []quad.Quad
+	quads = append(quads, meeting("standup", 9, 10)...)
+	quads = append(quads, meeting("review", 11, 13)...)
+	quads = append(quads, meeting("planning", 13, 15)...)
+	quads = append(quads, quad.Make(quad.IRI("open"), start, quad.Time(hour(12)), nil))
+	qs := memstore.New(quads...)
+
+	lunch := between(12, 13)
+	run := func(rel temporal.Relation) []string {
+		var got []string
+		err := path.StartPath(qs).Interval(start, end, rel, lunch).Iterate(ctx).EachValue(nil, func(v quad.Value) {
+			got = append(got, string(v.(quad.IRI)))
+		})
+		require.NoError(t, err)
+		sort.Strings(got)
+		return got
+	}
+	require.Equal(t, []string{"standup"}, run(temporal.Before))
+	require.Equal(t, []string{"review"}, run(temporal.FinishedBy))
+	require.Equal(t, []string{"planning"}, run(temporal.MetBy))
+	require.Equal(t, []string(nil), run(temporal.Overlaps))
+}
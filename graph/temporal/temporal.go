@@ -0,0 +1,80 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package temporal implements the valid time of quads: a period when facts were true in the modeled world,
+// independent of when they were written to the database.
+//
+// The valid time is set on labels of quads. All quads with a given label are valid from the time set by ValidFrom,
+// inclusive, to the time set by ValidTo, exclusive:
+//
+//	<alice> <worksFor> <acme> <job1> .
+//	<job1> <cayley:validFrom> "2015-03-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+//	<job1> <cayley:validTo> "2019-06-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+//
+// Either of the bounds may be omitted for an open period. Quads without a label, or with a label that has
+// no bounds, are always valid.
+package temporal
+
+import (
+	"time"
+
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	// ValidFrom is a predicate that sets the start of the valid time of quads with a given label.
+	ValidFrom = quad.IRI("cayley:validFrom")
+	// ValidTo is a predicate that sets the end of the valid time of quads with a given label.
+	ValidTo = quad.IRI("cayley:validTo")
+)
+
+// Validity returns quads that set the valid time of quads with a given label. Zero times are not written,
+// leaving the period open.
+func Validity(label quad.Value, from, to time.Time) []quad.Quad {
+	var out []quad.Quad
+	if !from.IsZero() {
+		out = append(out, quad.Quad{Subject: label, Predicate: ValidFrom, Object: quad.Time(from)})
+	}
+	if !to.IsZero() {
+		out = append(out, quad.Quad{Subject: label, Predicate: ValidTo, Object: quad.Time(to)})
+	}
+	return out
+}
+
+// InvalidAt returns a set of labels of quads that are not valid at a given time.
+func InvalidAt(t time.Time) shape.Shape {
+	return shape.Union{
+		bound(ValidFrom, iterator.CompareGT, t),
+		bound(ValidTo, iterator.CompareLTE, t),
+	}
+}
+
+// ValidAt returns a set of labels for traversals that only follow quads valid at a given time.
+// Labels is an optional set of labels to restrict the traversals further.
+func ValidAt(labels shape.Shape, t time.Time) shape.Shape {
+	invalid := InvalidAt(t)
+	if l, ok := labels.(shape.ExceptLabels); ok {
+		return shape.ExceptLabels{From: l.From, Exclude: shape.UnionShapes(l.Exclude, invalid)}
+	}
+	return shape.ExceptLabels{From: labels, Exclude: invalid}
+}
+
+func bound(pred quad.IRI, op iterator.Operator, t time.Time) shape.Shape {
+	return shape.HasLabels(shape.AllNodes{}, shape.Lookup{pred}, shape.Filter{
+		From:    shape.AllNodes{},
+		Filters: []shape.ValueFilter{shape.Comparison{Op: op, Val: quad.Time(t)}},
+	}, nil, false)
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package temporal_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAsOf(t *testing.T) {
+	ctx := context.TODO()
+	var (
+		alice = quad.IRI("alice")
+		works = quad.IRI("worksFor")
+		name  = quad.IRI("name")
+	)
+	quads := []quad.Quad{
+		quad.Make(alice, works, quad.IRI("acme"), quad.IRI("job1")),
+		quad.Make(alice, works, quad.IRI("globex"), quad.IRI("job2")),
+		quad.Make(alice, name, quad.String("Alice"), nil),
+	}
+	quads = append(quads, temporal.Validity(quad.IRI("job1"), date(2015, 3, 1), date(2019, 6, 1))...)
+	quads = append(quads, temporal.Validity(quad.IRI("job2"), date(2019, 6, 1), time.Time{})...)
+
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	kqs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	defer kqs.Close()
+	qw, err := writer.NewSingle(kqs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.NoError(t, qw.AddQuadSet(quads))
+
+	for _, c := range []struct {
+		name string
+		qs   graph.QuadStore
+	}{
+		{"memstore", memstore.New(quads...)},
+		{"kv", kqs},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			run := func(p *path.Path) []string {
+				var got []string
+				err := p.Iterate(ctx).EachValue(nil, func(v quad.Value) {
+					got = append(got, v.String())
+				})
+				require.NoError(t, err)
+				sort.Strings(got)
+				return got
+			}
+			start := func() *path.Path { return path.StartPath(c.qs, alice) }
+
+			require.Equal(t, []string{"<acme>", "<globex>"}, run(start().Out(works)))
+			require.Equal(t, []string(nil), run(start().AsOf(date(2010, 1, 1)).Out(works)))
+			require.Equal(t, []string{"<acme>"}, run(start().AsOf(date(2016, 1, 1)).Out(works)))
+			// the end of the period is exclusive
+			require.Equal(t, []string{"<globex>"}, run(start().AsOf(date(2019, 6, 1)).Out(works)))
+			require.Equal(t, []string{"<globex>"}, run(start().AsOf(date(2030, 1, 1)).Out(works)))
+			// quads without a label are always valid
+			require.Equal(t, []string{`"Alice"`}, run(start().AsOf(date(2010, 1, 1)).Out(name)))
+
+			require.Equal(t, []string{"<alice>"}, run(path.StartPath(c.qs).AsOf(date(2016, 1, 1)).Has(works, quad.IRI("acme"))))
+			require.Equal(t, []string(nil), run(path.StartPath(c.qs).AsOf(date(2020, 1, 1)).Has(works, quad.IRI("acme"))))
+
+			// AsOf narrows the label context
+			require.Equal(t, []string(nil), run(start().LabelContext(quad.IRI("job1")).AsOf(date(2020, 1, 1)).Out(works)))
+			require.Equal(t, []string{"<acme>"}, run(start().LabelContext(quad.IRI("job1")).AsOf(date(2016, 1, 1)).Out(works)))
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Analyzer splits text into normalized terms.
+type Analyzer interface {
+	Terms(s string) []string
+}
+
+// DefaultAnalyzer is used when no analyzer is specified.
+var DefaultAnalyzer Analyzer = SimpleAnalyzer{}
+
+// SimpleAnalyzer splits text on all characters except letters and digits, and converts terms to lower case.
+type SimpleAnalyzer struct{}
+
+func (SimpleAnalyzer) Terms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// AnalyzerConfig describes an analyzer built by NewAnalyzer. A zero value is equivalent to SimpleAnalyzer.
+type AnalyzerConfig struct {
+	// Preds is a list of predicates that use this analyzer. It's only used in Analyzers.Predicates.
+	Preds []quad.IRI `json:"preds,omitempty"`
+	// Lang enables stemming for a given language. Only "en" is supported.
+	Lang string `json:"lang,omitempty"`
+	// Stopwords are removed from the text before indexing and from queries.
+	Stopwords []string `json:"stopwords,omitempty"`
+	// KeepCase disables case folding.
+	KeepCase bool `json:"keep_case,omitempty"`
+	// Phonetic replaces terms with their phonetic codes: "soundex" or "metaphone".
+	Phonetic string `json:"phonetic,omitempty"`
+	// NGram splits each term into all its substrings of this length. Shorter terms are kept as is.
+	NGram int `json:"ngram,omitempty"`
+}
+
+// Analyzers configures analyzers of the full-text index of a quad store.
+type Analyzers struct {
+	// Default is used for objects of all predicates that are not listed in Predicates.
+	Default AnalyzerConfig `json:"default"`
+	// Predicates sets analyzers for specific predicates. Each one is kept in a separate index.
+	Predicates []AnalyzerConfig `json:"predicates,omitempty"`
+}
+
+func (a Analyzers) isZero() bool {
+	return len(a.Predicates) == 0 && a.Default.Lang == "" && len(a.Default.Stopwords) == 0 &&
+		!a.Default.KeepCase && a.Default.Phonetic == "" && a.Default.NGram == 0
+}
+
+var stemmers = map[string]func(string) string{
+	"en": stemEnglish,
+}
+
+// NewAnalyzer creates an analyzer from a configuration. Text is split into terms as in SimpleAnalyzer,
+// and terms are then case folded, filtered from stopwords, stemmed, phonetically encoded and split into n-grams,
+// in this order.
+func NewAnalyzer(c AnalyzerConfig) (Analyzer, error) {
+	if c.Lang == "" && len(c.Stopwords) == 0 && !c.KeepCase && c.Phonetic == "" && c.NGram == 0 {
+		return SimpleAnalyzer{}, nil
+	}
+	a := &configAnalyzer{fold: !c.KeepCase, ngram: c.NGram}
+	if c.NGram < 0 {
+		return nil, fmt.Errorf("text: invalid n-gram size: %d", c.NGram)
+	}
+	if c.Lang != "" {
+		a.stem = stemmers[c.Lang]
+		if a.stem == nil {
+			return nil, fmt.Errorf("text: stemming is not supported for language %q", c.Lang)
+		}
+	}
+	if c.Phonetic != "" {
+		var err error
+		if a.phonetic, err = PhoneticFunc(c.Phonetic); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.Stopwords) != 0 {
+		a.stop = make(map[string]struct{}, len(c.Stopwords))
+		for _, w := range c.Stopwords {
+			if a.fold {
+				w = strings.ToLower(w)
+			}
+			a.stop[w] = struct{}{}
+		}
+	}
+	return a, nil
+}
+
+type configAnalyzer struct {
+	fold     bool
+	stop     map[string]struct{}
+	stem     func(string) string
+	phonetic func(string) string
+	ngram    int
+}
+
+func (a *configAnalyzer) Terms(s string) []string {
+	if a.fold {
+		s = strings.ToLower(s)
+	}
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := words[:0]
+	for _, w := range words {
+		if _, ok := a.stop[w]; ok {
+			continue
+		}
+		if a.stem != nil {
+			w = a.stem(w)
+		}
+		if a.phonetic != nil {
+			if w = a.phonetic(w); w == "" {
+				continue
+			}
+		}
+		out = append(out, w)
+	}
+	if a.ngram == 0 {
+		return out
+	}
+	var grams []string
+	for _, w := range out {
+		r := []rune(w)
+		if len(r) <= a.ngram {
+			grams = append(grams, w)
+			continue
+		}
+		for i := 0; i+a.ngram <= len(r); i++ {
+			grams = append(grams, string(r[i:i+a.ngram]))
+		}
+	}
+	return grams
+}
+
+// stemEnglish is a light stemmer that removes common inflectional suffixes of English words.
+// It is not a full Porter stemmer, but is enough to match plural forms and simple verb tenses.
+func stemEnglish(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us") && len(w) > 3:
+		w = w[:len(w)-1]
+	}
+	for _, suf := range []string{"ing", "ed"} {
+		if !strings.HasSuffix(w, suf) || len(w)-len(suf) < 3 {
+			continue
+		}
+		w = w[:len(w)-len(suf)]
+		// running -> runn -> run
+		if n := len(w); w[n-1] == w[n-2] && !strings.ContainsRune("aeiouls", rune(w[n-1])) {
+			w = w[:n-1]
+		}
+		break
+	}
+	if strings.HasSuffix(w, "ly") && len(w) > 4 {
+		w = w[:len(w)-2]
+	}
+	return w
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultCompletions is the number of completions returned by Complete if no limit is set.
+const DefaultCompletions = 10
+
+// HasPrefix checks if the text of a string literal starts with a given prefix, ignoring case.
+func HasPrefix(v quad.Value, prefix string) bool {
+	s, ok := TextOf(v)
+	return ok && strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
+
+// Complete returns string literals that start with a given prefix, ignoring case, in lexical order.
+// It can be limited to objects of a given set of predicates. If limit is zero, DefaultCompletions is used.
+//
+// The sorted index of the quad store is used if available; otherwise all nodes are scanned.
+func Complete(ctx context.Context, qs graph.QuadStore, prefix string, limit int, preds ...quad.IRI) ([]quad.Value, error) {
+	if prefix == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = DefaultCompletions
+	}
+	var predRefs []graph.Value
+	for _, p := range preds {
+		if ref := qs.ValueOf(p); ref != nil {
+			predRefs = append(predRefs, ref)
+		}
+	}
+	if len(preds) != 0 && len(predRefs) == 0 {
+		return nil, nil
+	}
+	var (
+		it     graph.Iterator
+		sorted bool
+	)
+	if pi, ok := qs.(graph.PrefixIndexer); ok {
+		n := limit
+		if len(predRefs) != 0 {
+			// some candidates will be dropped, thus the limit cannot be applied to the index
+			n = 0
+		}
+		it, sorted = pi.NodesWithPrefix(ctx, prefix, n)
+	}
+	if !sorted {
+		it = qs.NodesAllIterator()
+	}
+	defer it.Close()
+	var out []quad.Value
+	for it.Next(ctx) {
+		if sorted && len(out) >= limit {
+			break
+		}
+		ref := it.Result()
+		v := qs.NameOf(ref)
+		if !HasPrefix(v, prefix) {
+			continue
+		}
+		if len(predRefs) != 0 {
+			ok, err := usedWith(ctx, qs, ref, predRefs)
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+		}
+		out = append(out, v)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := completionKey(out[i]), completionKey(out[j])
+		if a != b {
+			return a < b
+		}
+		return !sorted && out[i].String() < out[j].String()
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func completionKey(v quad.Value) string {
+	s, _ := TextOf(v)
+	return strings.ToLower(s)
+}
+
+// usedWith checks if the node is an object of any quad with one of the predicates.
+func usedWith(ctx context.Context, qs graph.QuadStore, ref graph.Value, preds []graph.Value) (bool, error) {
+	it := qs.QuadIterator(quad.Object, ref)
+	defer it.Close()
+	for it.Next(ctx) {
+		p := qs.QuadDirection(it.Result(), quad.Predicate)
+		for _, pr := range preds {
+			if graph.ToKey(p) == graph.ToKey(pr) {
+				return true, nil
+			}
+		}
+	}
+	return false, it.Err()
+}
@@ -0,0 +1,331 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elastic mirrors string literals of selected predicates into an external Elasticsearch (or OpenSearch) index.
+//
+// Each indexed quad is stored as a separate document with the subject, the predicate and the text of the literal.
+// The index follows the replication feed of the quad store, and search results are resolved back to subject nodes.
+package elastic
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+)
+
+const (
+	// DefaultIndex is the name of the index used if none is set in options.
+	DefaultIndex = "cayley_text"
+	// DefaultLimit is the maximal number of hits returned by a search if no limit is set.
+	DefaultLimit = 1000
+
+	docType   = "literal"
+	batchSize = 1000
+)
+
+// Options configures the external index.
+type Options struct {
+	// Index is the name of the index. If not set, DefaultIndex is used.
+	Index string
+	// Predicates limits the index to literals of these predicates. All predicates are indexed if it's empty.
+	Predicates []quad.IRI
+}
+
+// Index is an external full-text index of string literals.
+type Index struct {
+	cli   *elastic.Client
+	ind   string
+	preds map[quad.IRI]struct{}
+}
+
+type document struct {
+	Node string `json:"node"`
+	Pred string `json:"pred"`
+	Text string `json:"text"`
+	Quad string `json:"quad"`
+}
+
+const mapping = `{
+	"mappings": {
+		"literal": {
+			"properties": {
+				"node": {"type": "keyword"},
+				"pred": {"type": "keyword"},
+				"text": {"type": "text"},
+				"quad": {"type": "keyword", "index": false}
+			}
+		}
+	}
+}`
+
+// Dial connects to an index at a given address.
+func Dial(addr string, opts Options) (*Index, error) {
+	cli, err := elastic.NewClient(elastic.SetURL(addr), elastic.SetSniff(false))
+	if err != nil {
+		return nil, err
+	}
+	return New(cli, opts), nil
+}
+
+// New creates an index using an existing client.
+func New(cli *elastic.Client, opts Options) *Index {
+	idx := &Index{cli: cli, ind: opts.Index}
+	if idx.ind == "" {
+		idx.ind = DefaultIndex
+	}
+	if len(opts.Predicates) != 0 {
+		idx.preds = make(map[quad.IRI]struct{}, len(opts.Predicates))
+		for _, p := range opts.Predicates {
+			idx.preds[p.Full()] = struct{}{}
+		}
+	}
+	return idx
+}
+
+// Init creates the index, if it doesn't exist yet.
+func (idx *Index) Init(ctx context.Context) error {
+	ok, err := idx.cli.IndexExists(idx.ind).Do(ctx)
+	if err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+	_, err = idx.cli.CreateIndex(idx.ind).BodyString(mapping).Do(ctx)
+	return err
+}
+
+// accepts checks if the quad must be mirrored to the index.
+func (idx *Index) accepts(q quad.Quad) (string, bool) {
+	if idx.preds != nil {
+		p, ok := q.Predicate.(quad.IRI)
+		if !ok {
+			return "", false
+		} else if _, ok = idx.preds[p.Full()]; !ok {
+			return "", false
+		}
+	}
+	return text.TextOf(q.Object)
+}
+
+func predKey(p quad.Value) string {
+	if iri, ok := p.(quad.IRI); ok {
+		p = iri.Full()
+	}
+	return quad.StringOf(p)
+}
+
+func docID(q quad.Quad) string {
+	h := sha1.Sum([]byte(q.NQuad()))
+	return hex.EncodeToString(h[:])
+}
+
+func (idx *Index) request(d graph.Delta) elastic.BulkableRequest {
+	s, ok := idx.accepts(d.Quad)
+	if !ok {
+		return nil
+	}
+	id := docID(d.Quad)
+	if d.Action == graph.Delete {
+		return elastic.NewBulkDeleteRequest().Type(docType).Id(id)
+	}
+	return elastic.NewBulkIndexRequest().Type(docType).Id(id).Doc(document{
+		Node: d.Quad.Subject.String(),
+		Pred: predKey(d.Quad.Predicate),
+		Text: s,
+		Quad: d.Quad.NQuad(),
+	})
+}
+
+func (idx *Index) bulk(ctx context.Context, reqs []elastic.BulkableRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	resp, err := idx.cli.Bulk().Index(idx.ind).Add(reqs...).Do(ctx)
+	if err != nil {
+		return err
+	}
+	for _, it := range resp.Failed() {
+		// deleting a document that was never indexed is not an error
+		if it.Status == 404 {
+			continue
+		}
+		if it.Error != nil {
+			return fmt.Errorf("elastic: %s: %s", it.Error.Type, it.Error.Reason)
+		}
+		return fmt.Errorf("elastic: bulk request failed with status %d", it.Status)
+	}
+	return nil
+}
+
+// Apply mirrors deltas to the index.
+func (idx *Index) Apply(ctx context.Context, deltas []graph.Delta) error {
+	var reqs []elastic.BulkableRequest
+	for _, d := range deltas {
+		if r := idx.request(d); r != nil {
+			reqs = append(reqs, r)
+		}
+		if len(reqs) >= batchSize {
+			if err := idx.bulk(ctx, reqs); err != nil {
+				return err
+			}
+			reqs = reqs[:0]
+		}
+	}
+	return idx.bulk(ctx, reqs)
+}
+
+// Reindex removes all documents from the index and indexes all quads of the quad store.
+func (idx *Index) Reindex(ctx context.Context, qs graph.QuadStore) error {
+	_, err := idx.cli.DeleteByQuery(idx.ind).Type(docType).
+		Query(elastic.NewMatchAllQuery()).ProceedOnVersionConflict().Do(ctx)
+	if err != nil {
+		return err
+	}
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	var deltas []graph.Delta
+	for it.Next(ctx) {
+		deltas = append(deltas, graph.Delta{Quad: qs.Quad(it.Result()), Action: graph.Add})
+		if len(deltas) >= batchSize {
+			if err = idx.Apply(ctx, deltas); err != nil {
+				return err
+			}
+			deltas = deltas[:0]
+		}
+	}
+	if err = it.Err(); err != nil {
+		return err
+	}
+	return idx.Apply(ctx, deltas)
+}
+
+// Follow mirrors batches of the feed to the index, starting after a given sequence number.
+// It blocks until the context is done and returns the sequence number of the last mirrored batch.
+//
+// If the feed no longer holds the requested batches, the index is rebuilt from the quad store.
+func (idx *Index) Follow(ctx context.Context, f *replication.Feed, seq uint64) (uint64, error) {
+	for ctx.Err() == nil {
+		batches, err := f.Since(ctx, seq, 0)
+		if err == replication.ErrFeedGone {
+			clog.Warningf("elastic: feed is too far ahead, reindexing")
+			// batches applied during reindex are mirrored again, which is harmless
+			last := f.Seq()
+			if err = idx.Reindex(ctx, f.QuadStore); err != nil {
+				return seq, err
+			}
+			seq = last
+			continue
+		} else if err != nil {
+			return seq, err
+		}
+		for _, b := range batches {
+			if err = idx.Apply(ctx, b.Deltas); err != nil {
+				return seq, err
+			}
+			seq = b.Seq
+		}
+	}
+	return seq, nil
+}
+
+// Search returns subjects of quads with literals matching a full-text query, ordered by relevance.
+// All terms of the query must be present in the literal. The search can be limited to a set of predicates.
+//
+// If limit is zero, DefaultLimit is used.
+func (idx *Index) Search(ctx context.Context, query string, limit int, preds ...quad.IRI) ([]quad.Value, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	q := elastic.NewBoolQuery().Must(elastic.NewMatchQuery("text", query).Operator("and"))
+	if len(preds) != 0 {
+		vals := make([]interface{}, 0, len(preds))
+		for _, p := range preds {
+			vals = append(vals, predKey(p))
+		}
+		q = q.Filter(elastic.NewTermsQuery("pred", vals...))
+	}
+	resp, err := idx.cli.Search(idx.ind).Type(docType).Query(q).Size(limit).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		out  []quad.Value
+		seen = make(map[string]struct{})
+	)
+	for _, h := range resp.Hits.Hits {
+		if h.Source == nil {
+			continue
+		}
+		var d document
+		if err = json.Unmarshal(*h.Source, &d); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[d.Node]; ok {
+			continue
+		}
+		seen[d.Node] = struct{}{}
+		qd, err := nquads.Parse(d.Quad)
+		if err != nil {
+			return nil, fmt.Errorf("elastic: cannot parse quad of document %s: %v", h.Id, err)
+		}
+		out = append(out, qd.Subject)
+	}
+	return out, nil
+}
+
+// Iterator returns an iterator over subjects matching a full-text query. See Search for details.
+func (idx *Index) Iterator(ctx context.Context, qs graph.QuadStore, query string, limit int, preds ...quad.IRI) graph.Iterator {
+	vals, err := idx.Search(ctx, query, limit, preds...)
+	if err != nil {
+		return iterator.NewError(err)
+	}
+	it := iterator.NewFixed()
+	for _, v := range vals {
+		if ref := qs.ValueOf(v); ref != nil {
+			it.Add(ref)
+		}
+	}
+	return it
+}
+
+var _ shape.ValueFilter = Filter{}
+
+// Filter is a path filter that passes nodes that are subjects of literals matching a full-text query.
+//
+// Unlike text.Text, it filters subjects instead of literals:
+//
+//	p.Filters(elastic.Filter{Index: idx, Query: "graph database", Preds: []quad.IRI{"bio"}})
+type Filter struct {
+	Index *Index
+	Query string
+	Limit int
+	Preds []quad.IRI
+}
+
+func (f Filter) BuildIterator(qs graph.QuadStore, it graph.Iterator) graph.Iterator {
+	return iterator.NewAnd(qs, f.Index.Iterator(context.TODO(), qs, f.Query, f.Limit, f.Preds...), it)
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// fakeServer is a minimal in-memory imitation of bulk and search APIs.
+type fakeServer struct {
+	mu   sync.Mutex
+	docs map[string]document
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/_bulk"):
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var act map[string]struct {
+				ID string `json:"_id"`
+			}
+			if err := json.Unmarshal(sc.Bytes(), &act); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if a, ok := act["delete"]; ok {
+				delete(s.docs, a.ID)
+				continue
+			}
+			sc.Scan()
+			var d document
+			if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.docs[act["index"].ID] = d
+		}
+		w.Write([]byte(`{"items":[]}`))
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		data, _ := ioutil.ReadAll(r.Body)
+		var req struct {
+			Query struct {
+				Bool struct {
+					Must struct {
+						Match struct {
+							Text struct {
+								Query string `json:"query"`
+							} `json:"text"`
+						} `json:"match"`
+					} `json:"must"`
+				} `json:"bool"`
+			} `json:"query"`
+		}
+		json.Unmarshal(data, &req)
+		type hit struct {
+			ID     string   `json:"_id"`
+			Source document `json:"_source"`
+		}
+		var hits []hit
+		terms := strings.Fields(strings.ToLower(req.Query.Bool.Must.Match.Text.Query))
+	next:
+		for id, d := range s.docs {
+			for _, t := range terms {
+				if !strings.Contains(strings.ToLower(d.Text), t) {
+					continue next
+				}
+			}
+			hits = append(hits, hit{ID: id, Source: d})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"hits": map[string]interface{}{"total": len(hits), "hits": hits},
+		})
+	default:
+		w.Write([]byte(`{}`))
+	}
+}
+
+func (s *fakeServer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.docs)
+}
+
+func newIndex(t testing.TB, opts Options) (*Index, *fakeServer) {
+	fs := &fakeServer{docs: make(map[string]document)}
+	srv := httptest.NewServer(fs)
+	t.Cleanup(srv.Close)
+	idx, err := Dial(srv.URL, opts)
+	require.NoError(t, err)
+	return idx, fs
+}
+
+func TestFollow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	idx, fs := newIndex(t, Options{Predicates: []quad.IRI{"bio"}})
+
+	feed, err := replication.NewFeed(memstore.New(), 1)
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		_, err := idx.Follow(ctx, feed, 0)
+		done <- err
+	}()
+
+	bio := quad.Make(quad.IRI("a"), quad.IRI("bio"), quad.String("Graph databases"), nil)
+	err = feed.ApplyDeltas([]graph.Delta{
+		{Quad: bio, Action: graph.Add},
+		{Quad: quad.Make(quad.IRI("b"), quad.IRI("bio"), quad.String("Relational databases"), nil), Action: graph.Add},
+		{Quad: quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("Bob"), nil), Action: graph.Add},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	waitDocs(t, fs, 2)
+
+	p := path.StartPath(feed).Filters(Filter{Index: idx, Query: "graph"})
+	vals, err := p.Iterate(ctx).AllValues(feed)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.IRI("a")}, vals)
+
+	err = feed.ApplyDeltas([]graph.Delta{{Quad: bio, Action: graph.Delete}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	waitDocs(t, fs, 1)
+
+	vals, err = idx.Search(ctx, "databases", 0)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.IRI("b")}, vals)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func waitDocs(t testing.TB, fs *fakeServer, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for fs.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d documents, got %d", n, fs.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
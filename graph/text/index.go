@@ -0,0 +1,215 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	_ Backend        = (*Index)(nil)
+	_ ScoredSearcher = (*Index)(nil)
+)
+
+// parameters of BM25 ranking
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type document struct {
+	val   quad.Value
+	terms map[string]int // term frequencies
+	size  int            // number of terms
+}
+
+// Index is an in-memory inverted index of string literals.
+type Index struct {
+	an Analyzer
+
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]struct{}
+	size     int // total number of terms in all documents
+}
+
+// NewIndex creates an empty index. If analyzer is nil, DefaultAnalyzer is used.
+func NewIndex(an Analyzer) *Index {
+	if an == nil {
+		an = DefaultAnalyzer
+	}
+	return &Index{
+		an:       an,
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+// Analyzer returns an analyzer used by the index.
+func (idx *Index) Analyzer() Analyzer {
+	return idx.an
+}
+
+// Len returns the number of indexed values.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+func (idx *Index) Index(ctx context.Context, vals []quad.Value) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, v := range vals {
+		s, ok := TextOf(v)
+		if !ok {
+			continue
+		}
+		key := v.String()
+		if _, ok := idx.docs[key]; ok {
+			continue
+		}
+		d := &document{val: v, terms: make(map[string]int)}
+		for _, t := range idx.an.Terms(s) {
+			d.terms[t]++
+			d.size++
+		}
+		idx.docs[key] = d
+		idx.size += d.size
+		for t := range d.terms {
+			p := idx.postings[t]
+			if p == nil {
+				p = make(map[string]struct{})
+				idx.postings[t] = p
+			}
+			p[key] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (idx *Index) Delete(ctx context.Context, vals []quad.Value) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, v := range vals {
+		key := v.String()
+		d, ok := idx.docs[key]
+		if !ok {
+			continue
+		}
+		delete(idx.docs, key)
+		idx.size -= d.size
+		for t := range d.terms {
+			p := idx.postings[t]
+			delete(p, key)
+			if len(p) == 0 {
+				delete(idx.postings, t)
+			}
+		}
+	}
+	return nil
+}
+
+func (idx *Index) SearchText(ctx context.Context, query string) ([]quad.Value, error) {
+	q := ParseQuery(idx.an, query)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	keys := idx.match(q)
+	sort.Strings(keys)
+	out := make([]quad.Value, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, idx.docs[key].val)
+	}
+	return out, nil
+}
+
+// SearchScored returns values matching a query, ranked with BM25. Values with equal scores are ordered by key.
+func (idx *Index) SearchScored(ctx context.Context, query string) ([]Hit, error) {
+	q := ParseQuery(idx.an, query)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	keys := idx.match(q)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	terms := make(map[string]struct{}, len(q.Terms))
+	for _, t := range q.Terms {
+		terms[t] = struct{}{}
+	}
+	n := float64(len(idx.docs))
+	avg := float64(idx.size) / n
+	type scored struct {
+		key string
+		Hit
+	}
+	hits := make([]scored, 0, len(keys))
+	for _, key := range keys {
+		d := idx.docs[key]
+		var score float64
+		for t := range terms {
+			df := float64(len(idx.postings[t]))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			tf := float64(d.terms[t])
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(d.size)/avg))
+		}
+		hits = append(hits, scored{key: key, Hit: Hit{Value: d.val, Score: score}})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].key < hits[j].key
+	})
+	out := make([]Hit, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, h.Hit)
+	}
+	return out, nil
+}
+
+// match returns keys of all documents that contain all terms of the query. Read lock must be held.
+func (idx *Index) match(q *Query) []string {
+	if len(q.Terms) == 0 {
+		return nil
+	}
+	// start from the rarest term
+	lists := make([]map[string]struct{}, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		p := idx.postings[t]
+		if len(p) == 0 {
+			return nil
+		}
+		lists = append(lists, p)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+	var keys []string
+next:
+	for key := range lists[0] {
+		for _, p := range lists[1:] {
+			if _, ok := p[key]; !ok {
+				continue next
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
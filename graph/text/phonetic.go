@@ -0,0 +1,239 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// PhoneticSoundex is the American Soundex algorithm.
+	PhoneticSoundex = "soundex"
+	// PhoneticMetaphone is the original Metaphone algorithm by Lawrence Philips.
+	PhoneticMetaphone = "metaphone"
+)
+
+var phonetics = map[string]func(string) string{
+	PhoneticSoundex:   Soundex,
+	PhoneticMetaphone: Metaphone,
+}
+
+// PhoneticFunc returns a phonetic encoding by its name. Empty name selects Soundex.
+func PhoneticFunc(name string) (func(string) string, error) {
+	if name == "" {
+		name = PhoneticSoundex
+	}
+	fnc := phonetics[name]
+	if fnc == nil {
+		return nil, fmt.Errorf("text: unknown phonetic algorithm %q", name)
+	}
+	return fnc, nil
+}
+
+// upperLetters returns ASCII letters of a word in upper case. Other characters are dropped.
+func upperLetters(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c >= 'A' && c <= 'Z' {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// soundexCodes maps letters to digits; zero separates codes, and H and W are ignored.
+const soundexCodes = "01230120022455012623010202"
+
+// Soundex returns an American Soundex code of a word: the first letter followed by three digits.
+// It returns an empty string if the word has no ASCII letters.
+func Soundex(s string) string {
+	w := upperLetters(s)
+	if len(w) == 0 {
+		return ""
+	}
+	out := []byte{w[0], '0', '0', '0'}
+	n := 1
+	last := soundexCodes[w[0]-'A']
+	for _, c := range w[1:] {
+		if n == len(out) {
+			break
+		}
+		if c == 'H' || c == 'W' {
+			continue
+		}
+		code := soundexCodes[c-'A']
+		if code != '0' && code != last {
+			out[n] = code
+			n++
+		}
+		last = code
+	}
+	return string(out)
+}
+
+func isVowel(c byte) bool {
+	return c == 'A' || c == 'E' || c == 'I' || c == 'O' || c == 'U'
+}
+
+// Metaphone returns a Metaphone key of a word. It returns an empty string if the word has no ASCII letters.
+func Metaphone(s string) string {
+	w := upperLetters(s)
+	if len(w) == 0 {
+		return ""
+	}
+	// initial exceptions
+	switch {
+	case len(w) > 1 && (string(w[:2]) == "AE" || string(w[:2]) == "GN" || string(w[:2]) == "KN" ||
+		string(w[:2]) == "PN" || string(w[:2]) == "WR"):
+		w = w[1:]
+	case w[0] == 'X':
+		w[0] = 'S'
+	case len(w) > 1 && string(w[:2]) == "WH":
+		w = append([]byte{'W'}, w[2:]...)
+	}
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+	next := func(i int, s string) bool {
+		return strings.HasPrefix(string(w[i+1:]), s)
+	}
+	var out []byte
+	for i, c := range w {
+		if c == at(i-1) && c != 'C' {
+			continue
+		}
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				out = append(out, c)
+			}
+		case 'B':
+			if !(i == len(w)-1 && at(i-1) == 'M') {
+				out = append(out, 'B')
+			}
+		case 'C':
+			switch {
+			case next(i, "IA") || (next(i, "H") && at(i-1) != 'S'):
+				out = append(out, 'X')
+			case next(i, "I") || next(i, "E") || next(i, "Y"):
+				if at(i-1) != 'S' {
+					out = append(out, 'S')
+				}
+			default:
+				out = append(out, 'K')
+			}
+		case 'D':
+			if next(i, "GE") || next(i, "GY") || next(i, "GI") {
+				out = append(out, 'J')
+			} else {
+				out = append(out, 'T')
+			}
+		case 'G':
+			switch {
+			case next(i, "H") && !isVowel(at(i+2)):
+				// silent, as in "night"
+			case next(i, "N") && (i+2 == len(w) || next(i, "NED") && i+4 == len(w)):
+				// silent, as in "sign"
+			case (next(i, "I") || next(i, "E") || next(i, "Y")) && at(i-1) != 'G':
+				out = append(out, 'J')
+			default:
+				out = append(out, 'K')
+			}
+		case 'H':
+			p := at(i - 1)
+			if isVowel(at(i+1)) && p != 'C' && p != 'S' && p != 'P' && p != 'T' && p != 'G' {
+				out = append(out, 'H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				out = append(out, 'K')
+			}
+		case 'P':
+			if next(i, "H") {
+				out = append(out, 'F')
+			} else {
+				out = append(out, 'P')
+			}
+		case 'Q':
+			out = append(out, 'K')
+		case 'S':
+			if next(i, "H") || next(i, "IO") || next(i, "IA") {
+				out = append(out, 'X')
+			} else {
+				out = append(out, 'S')
+			}
+		case 'T':
+			switch {
+			case next(i, "IA") || next(i, "IO"):
+				out = append(out, 'X')
+			case next(i, "H"):
+				out = append(out, '0')
+			case next(i, "CH"):
+				// silent
+			default:
+				out = append(out, 'T')
+			}
+		case 'V':
+			out = append(out, 'F')
+		case 'W', 'Y':
+			if isVowel(at(i + 1)) {
+				out = append(out, c)
+			}
+		case 'X':
+			out = append(out, 'K', 'S')
+		case 'Z':
+			out = append(out, 'S')
+		default: // F, J, L, M, N, R
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
+
+// SoundsLike checks if each word of the query sounds like some word of the text, according to
+// a given phonetic algorithm. An empty query matches nothing.
+func SoundsLike(algo, query, text string) (bool, error) {
+	fnc, err := PhoneticFunc(algo)
+	if err != nil {
+		return false, err
+	}
+	var an SimpleAnalyzer
+	codes := make(map[string]struct{})
+	for _, w := range an.Terms(text) {
+		if c := fnc(w); c != "" {
+			codes[c] = struct{}{}
+		}
+	}
+	n := 0
+	for _, w := range an.Terms(query) {
+		c := fnc(w)
+		if c == "" {
+			continue
+		}
+		if _, ok := codes[c]; !ok {
+			return false, nil
+		}
+		n++
+	}
+	return n != 0, nil
+}
@@ -0,0 +1,392 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	_ graph.ConditionalApplier = (*QuadStore)(nil)
+	_ graph.Wrapper            = (*QuadStore)(nil)
+	_ Searcher                 = (*QuadStore)(nil)
+	_ ScoredSearcher           = (*QuadStore)(nil)
+)
+
+// QuadStore maintains a full-text index of string literals that are used as objects of quads.
+//
+// The index is updated on each write, thus all writes must go through the wrapper. It should be installed
+// below wrappers that replicate changes, so that changes applied by replicas are indexed as well. The Text
+// step finds the index through other wrappers (see SearcherOf).
+type QuadStore struct {
+	graph.QuadStore
+	// idx is a list of indexes; the first one is used for predicates without a specific analyzer
+	idx []Backend
+	// preds maps predicates to indexes with their analyzers
+	preds map[quad.IRI]int
+}
+
+// MetaAnalyzers is the metadata key that stores the analyzer configuration of the index.
+const MetaAnalyzers = "text_analyzers"
+
+// Options configures the full-text index of a quad store.
+type Options struct {
+	// Analyzers sets the default analyzer and analyzers of specific predicates.
+	//
+	// If it's not set, the configuration saved in the metadata of the quad store is used.
+	// Otherwise, it replaces the saved one.
+	Analyzers Analyzers
+	// NewBackend creates an index with a given analyzer. If not set, in-memory Index is used.
+	NewBackend func(an Analyzer) Backend
+	// Meta stores the analyzer configuration. If nil, the quad store is used, if it implements graph.MetadataStore.
+	Meta graph.MetadataStore
+}
+
+// NewQuadStore wraps a quad store and indexes all existing string literals. If backend is nil, an in-memory index
+// with DefaultAnalyzer is used.
+func NewQuadStore(ctx context.Context, qs graph.QuadStore, backend Backend) (*QuadStore, error) {
+	if backend == nil {
+		backend = NewIndex(nil)
+	}
+	s := &QuadStore{QuadStore: qs, idx: []Backend{backend}}
+	if err := s.Reindex(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewQuadStoreWith wraps a quad store and indexes all existing string literals with the configured analyzers.
+func NewQuadStoreWith(ctx context.Context, qs graph.QuadStore, opts Options) (*QuadStore, error) {
+	conf, err := loadAnalyzers(ctx, qs, opts)
+	if err != nil {
+		return nil, err
+	}
+	newBackend := opts.NewBackend
+	if newBackend == nil {
+		newBackend = func(an Analyzer) Backend { return NewIndex(an) }
+	}
+	an, err := NewAnalyzer(conf.Default)
+	if err != nil {
+		return nil, err
+	}
+	s := &QuadStore{QuadStore: qs, idx: []Backend{newBackend(an)}, preds: make(map[quad.IRI]int)}
+	for _, c := range conf.Predicates {
+		if len(c.Preds) == 0 {
+			return nil, fmt.Errorf("text: no predicates are set for an analyzer")
+		}
+		an, err := NewAnalyzer(c)
+		if err != nil {
+			return nil, err
+		}
+		for _, pred := range c.Preds {
+			if _, ok := s.preds[pred]; ok {
+				return nil, fmt.Errorf("text: more than one analyzer is set for %v", pred)
+			}
+			s.preds[pred] = len(s.idx)
+		}
+		s.idx = append(s.idx, newBackend(an))
+	}
+	if err := s.Reindex(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadAnalyzers returns the configuration from options, or the one saved in the metadata of the quad store.
+// A new configuration is saved so that later queries and reindexing use the same analyzers.
+func loadAnalyzers(ctx context.Context, qs graph.QuadStore, opts Options) (Analyzers, error) {
+	meta := opts.Meta
+	if meta == nil {
+		meta, _ = qs.(graph.MetadataStore)
+	}
+	if meta == nil {
+		return opts.Analyzers, nil
+	}
+	data, err := meta.Metadata(ctx, MetaAnalyzers)
+	if err != nil {
+		return Analyzers{}, err
+	}
+	if opts.Analyzers.isZero() {
+		var conf Analyzers
+		if len(data) == 0 {
+			return conf, nil
+		}
+		if err = json.Unmarshal(data, &conf); err != nil {
+			return Analyzers{}, fmt.Errorf("text: cannot decode saved analyzers: %v", err)
+		}
+		return conf, nil
+	}
+	cur, err := json.Marshal(opts.Analyzers)
+	if err != nil {
+		return Analyzers{}, err
+	}
+	if !bytes.Equal(cur, data) {
+		if len(data) != 0 {
+			clog.Infof("text: analyzers have changed, replacing the saved configuration")
+		}
+		if err = meta.SetMetadata(ctx, MetaAnalyzers, cur); err != nil {
+			return Analyzers{}, err
+		}
+	}
+	return opts.Analyzers, nil
+}
+
+// Backend returns the full-text index used by the quad store for predicates without a specific analyzer.
+func (qs *QuadStore) Backend() Backend {
+	return qs.idx[0]
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// snapshot is a snapshot of the underlying quad store that can be searched.
+type snapshot struct {
+	*QuadStore
+	snap graph.Snapshot
+}
+
+func (s *snapshot) Horizon() int64 {
+	return s.snap.Horizon()
+}
+
+// WrapSnapshot allows searching a snapshot of the underlying quad store. The full-text index is not
+// pinned to the snapshot: searches return current matches, which are then looked up in the snapshot.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return &snapshot{QuadStore: &QuadStore{QuadStore: s, idx: qs.idx, preds: qs.preds}, snap: s}, nil
+}
+
+// indexOf returns an index used for objects of a given predicate.
+func (qs *QuadStore) indexOf(p quad.Value) int {
+	if iri, ok := p.(quad.IRI); ok {
+		if i, ok := qs.preds[iri]; ok {
+			return i
+		}
+	}
+	return 0
+}
+
+// Reindex adds all string literals of the underlying quad store to the index.
+func (qs *QuadStore) Reindex(ctx context.Context) error {
+	it := qs.QuadStore.QuadsAllIterator()
+	defer it.Close()
+	bufs := make([][]quad.Value, len(qs.idx))
+	for it.Next(ctx) {
+		q := qs.QuadStore.Quad(it.Result())
+		if _, ok := TextOf(q.Object); !ok {
+			continue
+		}
+		i := qs.indexOf(q.Predicate)
+		bufs[i] = append(bufs[i], q.Object)
+		if len(bufs[i]) >= quad.DefaultBatch {
+			if err := qs.idx[i].Index(ctx, bufs[i]); err != nil {
+				return fmt.Errorf("text: cannot index values: %v", err)
+			}
+			bufs[i] = bufs[i][:0]
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	for i, buf := range bufs {
+		if err := qs.idx[i].Index(ctx, buf); err != nil {
+			return fmt.Errorf("text: cannot index values: %v", err)
+		}
+	}
+	return nil
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf applies deltas to the underlying quad store and updates the index.
+//
+// Added string literals are indexed, and removed ones are deleted from the index once they are no longer used
+// as an object of any quad. Only removed literals are looked up in the underlying quad store.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	ctx := context.TODO()
+	var err error
+	if len(conds) == 0 {
+		err = qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		err = ca.ApplyDeltasIf(conds, deltas, opts)
+	} else {
+		err = graph.ErrPreconditionsNotSupported
+	}
+	if err != nil {
+		return err
+	}
+	type literal struct {
+		val     quad.Value
+		idx     []bool // indexes of added quads
+		removed bool
+	}
+	var (
+		add, del = make([][]quad.Value, len(qs.idx)), make([][]quad.Value, len(qs.idx))
+		lits     []*literal
+		seen     = make(map[string]*literal)
+	)
+	for _, d := range deltas {
+		v := d.Quad.Object
+		if _, ok := TextOf(v); !ok {
+			continue
+		}
+		key := v.String()
+		l := seen[key]
+		if l == nil {
+			l = &literal{val: v, idx: make([]bool, len(qs.idx))}
+			seen[key] = l
+			lits = append(lits, l)
+		}
+		if d.Action == graph.Add {
+			l.idx[qs.indexOf(d.Quad.Predicate)] = true
+		} else {
+			l.removed = true
+		}
+	}
+	for _, l := range lits {
+		used := l.idx
+		if l.removed {
+			// the value might still be used by other quads
+			used = qs.indexesOf(ctx, l.val)
+		}
+		for i := range qs.idx {
+			if used[i] {
+				add[i] = append(add[i], l.val)
+			} else if l.removed {
+				del[i] = append(del[i], l.val)
+			}
+		}
+	}
+	for i, idx := range qs.idx {
+		if len(add[i]) != 0 {
+			if err = idx.Index(ctx, add[i]); err != nil {
+				return fmt.Errorf("text: cannot index values: %v", err)
+			}
+		}
+		if len(del[i]) != 0 {
+			if err = idx.Delete(ctx, del[i]); err != nil {
+				return fmt.Errorf("text: cannot remove values from index: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// indexesOf returns indexes that must contain the value, depending on predicates it's used with.
+func (qs *QuadStore) indexesOf(ctx context.Context, v quad.Value) []bool {
+	out := make([]bool, len(qs.idx))
+	ref := qs.QuadStore.ValueOf(v)
+	if ref == nil {
+		return out
+	}
+	it := qs.QuadStore.QuadIterator(quad.Object, ref)
+	defer it.Close()
+	for it.Next(ctx) {
+		if len(qs.idx) == 1 {
+			out[0] = true
+			break
+		}
+		p := qs.QuadStore.NameOf(qs.QuadStore.QuadDirection(it.Result(), quad.Predicate))
+		out[qs.indexOf(p)] = true
+	}
+	return out
+}
+
+// SearchText returns all string literals that match a full-text query.
+//
+// The query is analyzed separately for each predicate-specific index, the same way as values in that index.
+func (qs *QuadStore) SearchText(ctx context.Context, query string) ([]quad.Value, error) {
+	if len(qs.idx) == 1 {
+		return qs.idx[0].SearchText(ctx, query)
+	}
+	var (
+		out  []quad.Value
+		seen = make(map[string]struct{})
+	)
+	for _, idx := range qs.idx {
+		vals, err := idx.SearchText(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vals {
+			key := v.String()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+	return out, nil
+}
+
+// SearchScored returns all string literals that match a full-text query, ranked by relevance.
+//
+// Indexes that don't rank matches give a score of 1 to each of them. If a value is found in multiple
+// indexes, the highest score is used.
+func (qs *QuadStore) SearchScored(ctx context.Context, query string) ([]Hit, error) {
+	var (
+		out  []Hit
+		seen = make(map[string]int)
+	)
+	for _, idx := range qs.idx {
+		var hits []Hit
+		if ss, ok := idx.(ScoredSearcher); ok {
+			var err error
+			if hits, err = ss.SearchScored(ctx, query); err != nil {
+				return nil, err
+			}
+		} else {
+			vals, err := idx.SearchText(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range vals {
+				hits = append(hits, Hit{Value: v, Score: 1})
+			}
+		}
+		for _, h := range hits {
+			key := h.Value.String()
+			if i, ok := seen[key]; ok {
+				if h.Score > out[i].Score {
+					out[i].Score = h.Score
+				}
+				continue
+			}
+			seen[key] = len(out)
+			out = append(out, h)
+		}
+	}
+	if len(qs.idx) > 1 {
+		sort.SliceStable(out, func(i, j int) bool {
+			return out[i].Score > out[j].Score
+		})
+	}
+	return out, nil
+}
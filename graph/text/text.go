@@ -0,0 +1,128 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package text implements a full-text index over string literals.
+//
+// The index is maintained by a quad store wrapper on each write, and is used by the Text path step
+// instead of scanning and matching all values. The index itself is pluggable: an in-memory inverted
+// index is provided, and other search engines can be used by implementing the Backend interface.
+package text
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const xsdString = quad.IRI("http://www.w3.org/2001/XMLSchema#string")
+
+// TextOf returns a text of a string literal. Other values are not indexed.
+func TextOf(v quad.Value) (string, bool) {
+	switch v := v.(type) {
+	case quad.String:
+		return string(v), true
+	case quad.LangString:
+		return string(v.Value), true
+	case quad.TypedString:
+		if v.Type == xsdString || v.Type == "xsd:string" {
+			return string(v.Value), true
+		}
+	}
+	return "", false
+}
+
+// Searcher is implemented by quad stores that maintain a full-text index.
+type Searcher interface {
+	// SearchText returns all indexed values that match a query.
+	SearchText(ctx context.Context, query string) ([]quad.Value, error)
+}
+
+// SearcherOf returns the full-text index of the quad store, or of the quad store it wraps.
+// It returns false if none of them maintain an index.
+func SearcherOf(qs graph.QuadStore) (Searcher, bool) {
+	s, ok := graph.Find(qs, func(qs graph.QuadStore) bool {
+		_, ok := qs.(Searcher)
+		return ok
+	}).(Searcher)
+	return s, ok
+}
+
+// ScoredSearcherOf returns the full-text index that ranks matches, in the same way as SearcherOf.
+func ScoredSearcherOf(qs graph.QuadStore) (ScoredSearcher, bool) {
+	s, ok := graph.Find(qs, func(qs graph.QuadStore) bool {
+		_, ok := qs.(ScoredSearcher)
+		return ok
+	}).(ScoredSearcher)
+	return s, ok
+}
+
+// ScoreTag is the reserved tag that query languages use for relevance scores of full-text matches.
+const ScoreTag = "_score"
+
+// Hit is a value that matches a full-text query, with a relevance score of the match.
+type Hit struct {
+	Value quad.Value
+	Score float64
+}
+
+// ScoredSearcher is implemented by indexes and quad stores that rank full-text matches by relevance.
+type ScoredSearcher interface {
+	// SearchScored returns all indexed values that match a query, in the order of decreasing scores.
+	SearchScored(ctx context.Context, query string) ([]Hit, error)
+}
+
+// Backend stores a full-text index of values.
+type Backend interface {
+	Searcher
+	// Index adds values to the index. Values that are already indexed must be ignored.
+	Index(ctx context.Context, vals []quad.Value) error
+	// Delete removes values from the index. Values that are not indexed must be ignored.
+	Delete(ctx context.Context, vals []quad.Value) error
+}
+
+// Query is a parsed full-text query. All terms of a query must be present in the text.
+type Query struct {
+	Terms []string
+}
+
+// ParseQuery splits a query into terms with a given analyzer.
+func ParseQuery(an Analyzer, s string) *Query {
+	if an == nil {
+		an = DefaultAnalyzer
+	}
+	return &Query{Terms: an.Terms(s)}
+}
+
+// Match checks if a text of the value matches the query, using the same analyzer as the query.
+// Empty queries match nothing.
+func (q *Query) Match(an Analyzer, v quad.Value) bool {
+	s, ok := TextOf(v)
+	if !ok || len(q.Terms) == 0 {
+		return false
+	}
+	if an == nil {
+		an = DefaultAnalyzer
+	}
+	terms := make(map[string]struct{})
+	for _, t := range an.Terms(s) {
+		terms[t] = struct{}{}
+	}
+	for _, t := range q.Terms {
+		if _, ok := terms[t]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,270 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/sameas"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func names(t testing.TB, qs graph.QuadStore, p *path.Path) []string {
+	vals, err := p.Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	var out []string
+	for _, v := range vals {
+		out = append(out, v.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestAnalyzer(t *testing.T) {
+	require.Equal(t, []string{"hello", "world", "42"}, text.DefaultAnalyzer.Terms("Hello, World_42!"))
+	require.Empty(t, text.DefaultAnalyzer.Terms(" - "))
+
+	terms := func(c text.AnalyzerConfig, s string) []string {
+		an, err := text.NewAnalyzer(c)
+		require.NoError(t, err)
+		return an.Terms(s)
+	}
+	require.Equal(t, []string{"graph", "database", "run", "query", "play"},
+		terms(text.AnalyzerConfig{Lang: "en"}, "Graphs databases running queries played"))
+	require.Equal(t, []string{"quick", "fox"}, terms(text.AnalyzerConfig{Stopwords: []string{"The", "a"}}, "The quick, a fox"))
+	require.Equal(t, []string{"Hello", "world"}, terms(text.AnalyzerConfig{KeepCase: true}, "Hello world"))
+	require.Equal(t, []string{"gra", "rap", "aph", "db"}, terms(text.AnalyzerConfig{NGram: 3}, "Graph DB"))
+
+	require.Equal(t, []string{"R163", "R163"}, terms(text.AnalyzerConfig{Phonetic: "soundex"}, "Robert, Rupert"))
+
+	_, err := text.NewAnalyzer(text.AnalyzerConfig{Lang: "xx"})
+	require.Error(t, err)
+	_, err = text.NewAnalyzer(text.AnalyzerConfig{Phonetic: "xx"})
+	require.Error(t, err)
+}
+
+func TestPhonetic(t *testing.T) {
+	for s, exp := range map[string]string{
+		"Robert": "R163", "Rupert": "R163", "Tymczak": "T522", "Pfister": "P236",
+		"Honeyman": "H555", "Ashcraft": "A261", "Lee": "L000", "42": "",
+	} {
+		require.Equal(t, exp, text.Soundex(s), s)
+	}
+	for s, exp := range map[string]string{
+		"Knight": "NT", "Philip": "FLP", "Smith": "SM0", "Smyth": "SM0",
+		"Thumb": "0M", "Xavier": "SFR", "Church": "XRX", "science": "SNS",
+	} {
+		require.Equal(t, exp, text.Metaphone(s), s)
+	}
+	for _, algo := range []string{text.PhoneticSoundex, text.PhoneticMetaphone} {
+		ok, err := text.SoundsLike(algo, "Jon Smyth", "John Smith")
+		require.NoError(t, err)
+		require.True(t, ok, algo)
+		ok, err = text.SoundsLike(algo, "Jon Brown", "John Smith")
+		require.NoError(t, err)
+		require.False(t, ok, algo)
+	}
+	_, err := text.SoundsLike("xx", "a", "a")
+	require.Error(t, err)
+}
+
+func TestDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		max  int
+		exp  int
+	}{
+		{"kitten", "sitting", -1, 3},
+		{"", "abc", -1, 3},
+		{"Zürich", "Zurich", -1, 1},
+		{"same", "same", 0, 0},
+		{"kitten", "sitting", 1, 2},
+		{"a", "abcdef", 2, 3},
+	} {
+		require.Equal(t, c.exp, text.Distance(c.a, c.b, c.max), "%q vs %q", c.a, c.b)
+	}
+	require.Equal(t, []string{"Mic", "hael"}, text.FuzzyPieces("Michael", 1))
+	require.Nil(t, text.FuzzyPieces("ab", 2))
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.TODO()
+	idx := text.NewIndex(nil)
+	err := idx.Index(ctx, []quad.Value{
+		quad.String("The quick brown fox"),
+		quad.LangString{Value: "The lazy dog", Lang: "en"},
+		quad.IRI("brown"),
+		quad.Int(1),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, idx.Len())
+
+	vals, err := idx.SearchText(ctx, "BROWN fox")
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("The quick brown fox")}, vals)
+
+	vals, err = idx.SearchText(ctx, "the")
+	require.NoError(t, err)
+	require.Len(t, vals, 2)
+
+	vals, err = idx.SearchText(ctx, "brown dog")
+	require.NoError(t, err)
+	require.Empty(t, vals)
+
+	require.NoError(t, idx.Delete(ctx, []quad.Value{quad.String("The quick brown fox")}))
+	vals, err = idx.SearchText(ctx, "the")
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.LangString{Value: "The lazy dog", Lang: "en"}}, vals)
+}
+
+func TestQuadStore(t *testing.T) {
+	ctx := context.TODO()
+	mem := memstore.New(
+		quad.Make(quad.IRI("a"), quad.IRI("bio"), quad.String("Graph databases are fun"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("bio"), quad.String("Relational databases"), nil),
+	)
+	qs, err := text.NewQuadStore(ctx, mem, nil)
+	require.NoError(t, err)
+
+	search := func(q string) []string {
+		return names(t, qs, path.StartPath(qs).Has(quad.IRI("bio")).Out(quad.IRI("bio")).Text(q).In(quad.IRI("bio")))
+	}
+	require.Equal(t, []string{"<a>", "<b>"}, search("databases"))
+	require.Equal(t, []string{"<a>"}, search("fun graph"))
+
+	q := quad.Make(quad.IRI("c"), quad.IRI("bio"), quad.String("Fun with graphs"), nil)
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{}))
+	require.Equal(t, []string{"<a>", "<c>"}, search("fun"))
+
+	tx := graph.NewTransaction()
+	tx.RemoveQuad(q)
+	tx.RemoveQuad(quad.Make(quad.IRI("a"), quad.IRI("bio"), quad.String("Graph databases are fun"), nil))
+	require.NoError(t, qs.ApplyDeltas(tx.Deltas, graph.IgnoreOpts{}))
+	require.Empty(t, search("fun"))
+	require.Equal(t, 1, qs.Backend().(*text.Index).Len())
+
+	// without an index, values are matched one by one
+	require.Equal(t, []string{"<b>"}, names(t, mem, path.StartPath(mem).Out(quad.IRI("bio")).Text("RELATIONAL").In(quad.IRI("bio"))))
+
+	// the index is found below other wrappers
+	top, err := sameas.NewQuadStore(ctx, qs, sameas.ModeExpand)
+	require.NoError(t, err)
+	require.NoError(t, top.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{}))
+	_, ok := graph.QuadStore(top).(text.Searcher)
+	require.False(t, ok)
+	require.Equal(t, []string{"<c>"}, names(t, top, path.StartPath(top).Out(quad.IRI("bio")).Text("fun").In(quad.IRI("bio"))))
+}
+
+func TestQuadStoreAnalyzers(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	base, err := kv.New(db, nil)
+	require.NoError(t, err)
+	err = base.ApplyDeltas([]graph.Delta{
+		{Quad: quad.Make(quad.IRI("a"), quad.IRI("bio"), quad.String("Loves graphs"), nil), Action: graph.Add},
+		{Quad: quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("Graphs"), nil), Action: graph.Add},
+		{Quad: quad.Make(quad.IRI("c"), quad.IRI("bio"), quad.String("The graph"), nil), Action: graph.Add},
+	}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	qs, err := text.NewQuadStoreWith(ctx, base, text.Options{Analyzers: text.Analyzers{
+		Predicates: []text.AnalyzerConfig{{Preds: []quad.IRI{"bio"}, Lang: "en", Stopwords: []string{"the"}}},
+	}})
+	require.NoError(t, err)
+	search := func(qs *text.QuadStore, q string) []string {
+		return names(t, qs, path.StartPath(qs).Out(quad.IRI("bio"), quad.IRI("name")).Text(q))
+	}
+	// stemming only applies to bios, but the query is analyzed for each predicate
+	require.Equal(t, []string{`"Loves graphs"`, `"The graph"`}, search(qs, "graph"))
+	require.Equal(t, []string{`"Graphs"`, `"Loves graphs"`, `"The graph"`}, search(qs, "graphs"))
+	require.Empty(t, search(qs, "the"))
+
+	// the value is moved to another index once it's no longer used with bio
+	q := quad.Make(quad.IRI("d"), quad.IRI("name"), quad.String("The graph"), nil)
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{Quad: q, Action: graph.Add}}, graph.IgnoreOpts{}))
+	require.NoError(t, qs.ApplyDeltas([]graph.Delta{{
+		Quad: quad.Make(quad.IRI("c"), quad.IRI("bio"), quad.String("The graph"), nil), Action: graph.Delete,
+	}}, graph.IgnoreOpts{}))
+	require.Equal(t, []string{`"The graph"`}, search(qs, "the"))
+
+	// the configuration is loaded from metadata
+	qs, err = text.NewQuadStoreWith(ctx, base, text.Options{})
+	require.NoError(t, err)
+	require.Equal(t, []string{`"Graphs"`, `"Loves graphs"`}, search(qs, "graphs"))
+	require.Equal(t, []string{`"Loves graphs"`}, search(qs, "graph loves"))
+}
+
+func TestComplete(t *testing.T) {
+	ctx := context.TODO()
+	qs := memstore.New(
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Alice"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("albert"), nil),
+		quad.Make(quad.IRI("c"), quad.IRI("city"), quad.String("Albany"), nil),
+		quad.Make(quad.IRI("alex"), quad.IRI("name"), quad.String("Bob"), nil),
+	)
+	vals, err := text.Complete(ctx, qs, "AL", 0)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("Albany"), quad.String("albert"), quad.String("Alice")}, vals)
+
+	vals, err = text.Complete(ctx, qs, "al", 1, quad.IRI("name"))
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.String("albert")}, vals)
+
+	vals, err = text.Complete(ctx, qs, "al", 0, quad.IRI("unknown"))
+	require.NoError(t, err)
+	require.Empty(t, vals)
+}
+
+func TestScores(t *testing.T) {
+	ctx := context.TODO()
+	mem := memstore.New(
+		quad.Make(quad.IRI("a"), quad.IRI("bio"), quad.String("Graph, graph databases"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("bio"), quad.String("Graph theory"), nil),
+		quad.Make(quad.IRI("c"), quad.IRI("bio"), quad.String("Relational databases"), nil),
+	)
+	qs, err := text.NewQuadStore(ctx, mem, nil)
+	require.NoError(t, err)
+
+	hits, err := qs.SearchScored(ctx, "graph")
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	// repeated terms raise the score, longer values lower it
+	require.Equal(t, quad.String("Graph, graph databases"), hits[0].Value)
+	require.True(t, hits[0].Score > hits[1].Score && hits[1].Score > 0, "%v", hits)
+
+	p := path.StartPath(qs).Out(quad.IRI("bio")).TextScored("databases", text.ScoreTag, 0).
+		In(quad.IRI("bio")).Tag("node").OrderBy(text.ScoreTag, path.Desc)
+	var got []quad.Value
+	err = p.Iterate(ctx).TagValues(qs, func(m map[string]quad.Value) {
+		require.IsType(t, quad.Float(0), m[text.ScoreTag])
+		got = append(got, m["node"])
+	})
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.IRI("c"), quad.IRI("a")}, got)
+
+	p = path.StartPath(qs).Out(quad.IRI("bio")).TextScored("databases", "", 100)
+	require.Empty(t, names(t, qs, p))
+}
@@ -22,6 +22,30 @@ type Transaction struct {
 	Deltas []Delta
 	// deltas stores the deltas in a map to avoid duplications
 	deltas map[Delta]struct{}
+	// Preconditions must hold for the transaction to be applied.
+	// They are checked against the state of the database before any deltas are applied.
+	Preconditions []Precondition
+}
+
+// Precondition is a requirement for the quad to exist (or not) in the database.
+type Precondition struct {
+	Quad   quad.Quad
+	Exists bool
+}
+
+func (p Precondition) String() string {
+	if p.Exists {
+		return "exists " + p.Quad.String()
+	}
+	return "not exists " + p.Quad.String()
+}
+
+// ConditionalApplier is an optional interface for quad stores that can check preconditions
+// and apply deltas in a single atomic step.
+type ConditionalApplier interface {
+	// ApplyDeltasIf applies deltas only if all preconditions are satisfied.
+	// It returns PreconditionError for the first precondition that failed.
+	ApplyDeltasIf(conds []Precondition, deltas []Delta, opts IgnoreOpts) error
 }
 
 // NewTransaction initialize a new transaction.
@@ -64,6 +88,16 @@ func (t *Transaction) RemoveQuad(q quad.Quad) {
 	}
 }
 
+// RequireQuad adds a precondition for the quad to exist in the database at the moment of commit.
+func (t *Transaction) RequireQuad(q quad.Quad) {
+	t.Preconditions = append(t.Preconditions, Precondition{Quad: q, Exists: true})
+}
+
+// RequireNoQuad adds a precondition for the quad to be absent from the database at the moment of commit.
+func (t *Transaction) RequireNoQuad(q quad.Quad) {
+	t.Preconditions = append(t.Preconditions, Precondition{Quad: q, Exists: false})
+}
+
 func createDeltas(q quad.Quad) (ad, rd Delta) {
 	ad = Delta{
 		Quad:   q,
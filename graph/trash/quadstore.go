@@ -0,0 +1,174 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trash
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+var _ graph.Wrapper = (*QuadStore)(nil)
+
+// QuadStore is a view of the underlying quad store without quads of deleted graphs.
+type QuadStore struct {
+	graph.QuadStore
+	deleted map[string]quad.Value
+}
+
+// Hide returns a view of a quad store without deleted graphs. The graphs are listed once, when the view is created.
+// If no graphs are deleted, the quad store is returned as is.
+func Hide(ctx context.Context, qs graph.QuadStore) (graph.QuadStore, error) {
+	list, err := List(ctx, qs)
+	if err != nil {
+		return nil, err
+	} else if len(list) == 0 {
+		return qs, nil
+	}
+	deleted := make(map[string]quad.Value, len(list))
+	for _, e := range list {
+		deleted[e.Label.String()] = e.Label
+	}
+	return &QuadStore{QuadStore: qs, deleted: deleted}, nil
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// snapshot is a view of a snapshot of the underlying quad store without deleted graphs.
+type snapshot struct {
+	*QuadStore
+	snap graph.Snapshot
+}
+
+func (s *snapshot) Horizon() int64 {
+	return s.snap.Horizon()
+}
+
+// WrapSnapshot hides graphs that were deleted at the time of a snapshot of the underlying quad store.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	hs, err := Hide(context.TODO(), s)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := hs.(*QuadStore)
+	if !ok {
+		return s, nil
+	}
+	return &snapshot{QuadStore: h, snap: s}, nil
+}
+
+// IsDeleted checks if a graph is hidden by the view.
+func (qs *QuadStore) IsDeleted(label quad.Value) bool {
+	if label == nil {
+		return false
+	}
+	_, ok := qs.deleted[label.String()]
+	return ok
+}
+
+// hidden returns quads of all deleted graphs.
+func (qs *QuadStore) hidden() graph.Iterator {
+	keys := make([]string, 0, len(qs.deleted))
+	for k := range qs.deleted {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var its []graph.Iterator
+	for _, k := range keys {
+		if ref := qs.QuadStore.ValueOf(qs.deleted[k]); ref != nil {
+			its = append(its, qs.QuadStore.QuadIterator(quad.Label, ref))
+		}
+	}
+	switch len(its) {
+	case 0:
+		return iterator.NewNull()
+	case 1:
+		return its[0]
+	}
+	return iterator.NewOr(its...)
+}
+
+func (qs *QuadStore) QuadIterator(d quad.Direction, v graph.Value) graph.Iterator {
+	if d == quad.Label {
+		if qs.IsDeleted(qs.QuadStore.NameOf(v)) {
+			return iterator.NewNull()
+		}
+		return qs.QuadStore.QuadIterator(d, v)
+	}
+	return iterator.NewAnd(qs, qs.QuadStore.QuadIterator(d, v), qs.QuadsAllIterator())
+}
+
+func (qs *QuadStore) QuadsAllIterator() graph.Iterator {
+	return iterator.NewNot(qs.hidden(), qs.QuadStore.QuadsAllIterator())
+}
+
+// NodesAllIterator returns all nodes of visible quads, including their labels.
+func (qs *QuadStore) NodesAllIterator() graph.Iterator {
+	its := make([]graph.Iterator, 0, len(quad.Directions))
+	for _, d := range quad.Directions {
+		its = append(its, iterator.NewHasA(qs, qs.QuadsAllIterator(), d))
+	}
+	return iterator.NewUnique(iterator.NewOr(its...))
+}
+
+// Size returns the number of visible quads, which might be an estimate.
+func (qs *QuadStore) Size() int64 {
+	it := qs.hidden()
+	defer it.Close()
+	sz, _ := it.Size()
+	n := qs.QuadStore.Size() - sz
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// OptimizeIterator doesn't let the underlying quad store optimize iterators,
+// since it would replace quad iterators of the view with its own.
+func (qs *QuadStore) OptimizeIterator(it graph.Iterator) (graph.Iterator, bool) {
+	return it, false
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf rejects all deltas with ErrDeleted if any of them adds quads to a deleted graph, or if any of
+// preconditions checks it. Quads of deleted graphs can still be removed, which purges or restores the graph.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	for _, d := range deltas {
+		if d.Action == graph.Add && qs.IsDeleted(d.Quad.Label) {
+			return ErrDeleted
+		}
+	}
+	for _, c := range conds {
+		if qs.IsDeleted(c.Quad.Label) {
+			return ErrDeleted
+		}
+	}
+	if len(conds) == 0 {
+		return qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		return ca.ApplyDeltasIf(conds, deltas, opts)
+	}
+	return graph.ErrPreconditionsNotSupported
+}
@@ -0,0 +1,259 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trash implements soft deletion of named graphs.
+//
+// A deleted graph is marked with a quad in the graph itself, so access lists and quotas of the graph apply to it:
+//
+//	<g1> <cayley:deletedAt> "2019-06-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> <g1> .
+//
+// Quads of deleted graphs are hidden by Hide and are removed by Purge, usually after a retention window.
+// Until then, Restore removes the mark and makes the graph visible again.
+package trash
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DeletedAt is a predicate that marks a graph as deleted at a given time.
+const DeletedAt = quad.IRI("cayley:deletedAt")
+
+// PurgeBatch is the maximal number of quads deleted by Purge in one transaction.
+const PurgeBatch = 10000
+
+var (
+	// ErrNotFound is returned when a graph doesn't exist, or is not in the trash.
+	ErrNotFound = errors.New("trash: graph not found")
+	// ErrDeleted is returned when a deleted graph is written to, or deleted again.
+	ErrDeleted = errors.New("trash: graph is deleted")
+	// ErrNoLabel is returned when quads without a label are deleted.
+	ErrNoLabel = errors.New("trash: only named graphs can be deleted")
+)
+
+// Mark returns a quad that marks a graph with a given label as deleted.
+func Mark(label quad.Value, at time.Time) quad.Quad {
+	return quad.Quad{Subject: label, Predicate: DeletedAt, Object: quad.Time(at), Label: label}
+}
+
+// isMark checks if a quad marks its graph as deleted.
+func isMark(q quad.Quad) bool {
+	return q.Label != nil && q.Predicate == DeletedAt && q.Subject == q.Label
+}
+
+// markTime returns the deletion time of a mark. Backends may return times as typed strings.
+func markTime(v quad.Value) time.Time {
+	if ts, ok := v.(quad.TypedString); ok {
+		if pv, err := ts.ParseValue(); err == nil {
+			v = pv
+		}
+	}
+	t, _ := v.(quad.Time)
+	return time.Time(t)
+}
+
+// Entry is a deleted graph.
+type Entry struct {
+	Label     quad.Value
+	DeletedAt time.Time
+}
+
+// List returns all deleted graphs, sorted by label.
+func List(ctx context.Context, qs graph.QuadStore) ([]Entry, error) {
+	ref := qs.ValueOf(DeletedAt)
+	if ref == nil {
+		return nil, nil
+	}
+	it := qs.QuadIterator(quad.Predicate, ref)
+	defer it.Close()
+	seen := make(map[string]int)
+	var out []Entry
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		if !isMark(q) {
+			continue
+		}
+		t := markTime(q.Object)
+		key := q.Label.String()
+		if i, ok := seen[key]; ok {
+			// the earliest mark wins, if a graph was deleted concurrently
+			if t.Before(out[i].DeletedAt) {
+				out[i].DeletedAt = t
+			}
+			continue
+		}
+		seen[key] = len(out)
+		out = append(out, Entry{Label: q.Label, DeletedAt: t})
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Label.String() < out[j].Label.String() })
+	return out, nil
+}
+
+// collectQuads returns quads of a graph, split into its marks and all other quads.
+// If the limit is not zero, it stops after reading the limit of other quads.
+func collectQuads(ctx context.Context, qs graph.QuadStore, label quad.Value, limit int) (marks, quads []quad.Quad, _ error) {
+	ref := qs.ValueOf(label)
+	if ref == nil {
+		return nil, nil, nil
+	}
+	it := qs.QuadIterator(quad.Label, ref)
+	defer it.Close()
+	for (limit <= 0 || len(quads) < limit) && it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		if isMark(q) {
+			marks = append(marks, q)
+		} else {
+			quads = append(quads, q)
+		}
+	}
+	return marks, quads, it.Err()
+}
+
+// isDeleted checks if a graph is marked as deleted, without reading its quads.
+func isDeleted(ctx context.Context, qs graph.QuadStore, label quad.Value) (bool, error) {
+	ref := qs.ValueOf(label)
+	if ref == nil {
+		return false, nil
+	}
+	it := qs.QuadIterator(quad.Subject, ref)
+	defer it.Close()
+	for it.Next(ctx) {
+		if isMark(qs.Quad(it.Result())) {
+			return true, nil
+		}
+	}
+	return false, it.Err()
+}
+
+// Delete moves a graph to the trash. The graph must exist and must not be deleted already.
+func Delete(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, label quad.Value, at time.Time) error {
+	if label == nil {
+		return ErrNoLabel
+	}
+	if ok, err := isDeleted(ctx, qs, label); err != nil {
+		return err
+	} else if ok {
+		return ErrDeleted
+	}
+	ref := qs.ValueOf(label)
+	if ref == nil {
+		return ErrNotFound
+	}
+	it := qs.QuadIterator(quad.Label, ref)
+	defer it.Close()
+	if !it.Next(ctx) {
+		if err := it.Err(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	return qw.AddQuad(Mark(label, at))
+}
+
+// Restore removes a graph from the trash, making all its quads visible again.
+func Restore(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, label quad.Value) error {
+	marks, _, err := collectQuads(ctx, qs, label, 0)
+	if err != nil {
+		return err
+	} else if len(marks) == 0 {
+		return ErrNotFound
+	}
+	tx := graph.NewTransactionN(len(marks))
+	for _, q := range marks {
+		tx.RemoveQuad(q)
+	}
+	return qw.ApplyTransaction(tx)
+}
+
+// Purge deletes all quads of a graph in the trash and returns the number of deleted quads.
+//
+// Quads are read and deleted in batches of PurgeBatch, one transaction per batch. The marks go last,
+// so an interrupted purge leaves the rest of the graph in the trash.
+func Purge(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, label quad.Value) (int, error) {
+	if ok, err := isDeleted(ctx, qs, label); err != nil {
+		return 0, err
+	} else if !ok {
+		return 0, ErrNotFound
+	}
+	n := 0
+	for {
+		// deleted quads are gone from the graph, so each batch starts from the beginning
+		marks, batch, err := collectQuads(ctx, qs, label, PurgeBatch)
+		if err != nil {
+			return n, err
+		}
+		last := len(batch) == 0
+		if last {
+			batch = marks
+		}
+		tx := graph.NewTransactionN(len(batch))
+		for _, q := range batch {
+			tx.RemoveQuad(q)
+		}
+		if err = qw.ApplyTransaction(tx); err != nil {
+			return n, err
+		}
+		n += len(batch)
+		if last {
+			return n, nil
+		}
+	}
+}
+
+// PurgeDeleted purges all graphs deleted at or before a given time. It returns the number of deleted quads.
+func PurgeDeleted(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, before time.Time) (int, error) {
+	list, err := List(ctx, qs)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range list {
+		if e.DeletedAt.After(before) {
+			continue
+		}
+		m, err := Purge(ctx, qs, qw, e.Label)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		clog.Infof("trash: purged graph %v", e.Label)
+	}
+	return n, nil
+}
+
+// RunPurger purges graphs that were deleted longer than the retention ago once per interval,
+// until the context is cancelled. Errors are logged and the purge is retried on the next tick.
+func RunPurger(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, retention, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := PurgeDeleted(ctx, qs, qw, time.Now().Add(-retention)); err != nil && ctx.Err() == nil {
+			clog.Errorf("trash: purge failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
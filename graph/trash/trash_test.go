@@ -0,0 +1,114 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trash
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func quadsOf(t testing.TB, qs graph.QuadStore) []string {
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	var out []string
+	for it.Next(context.TODO()) {
+		out = append(out, qs.Quad(it.Result()).NQuad())
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(out)
+	return out
+}
+
+func TestTrash(t *testing.T) {
+	ctx := context.TODO()
+	qs := memstore.New(
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g1"),
+		quad.MakeIRI("c", "knows", "d", "g2"),
+	)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+
+	at := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, Delete(ctx, qs, qw, quad.IRI("g1"), at))
+	require.Equal(t, ErrDeleted, Delete(ctx, qs, qw, quad.IRI("g1"), at))
+	require.Equal(t, ErrNotFound, Delete(ctx, qs, qw, quad.IRI("g3"), at))
+	require.Equal(t, ErrNoLabel, Delete(ctx, qs, qw, nil, at))
+
+	list, err := List(ctx, qs)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, quad.IRI("g1"), list[0].Label)
+	require.True(t, at.Equal(list[0].DeletedAt))
+
+	hs, err := Hide(ctx, qs)
+	require.NoError(t, err)
+	require.Equal(t, []string{"<c> <knows> <d> <g2> ."}, quadsOf(t, hs))
+	it := hs.QuadIterator(quad.Subject, hs.ValueOf(quad.IRI("a")))
+	require.False(t, it.Next(ctx))
+	it.Close()
+	hw, err := writer.NewSingle(hs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.Equal(t, ErrDeleted, hw.AddQuad(quad.MakeIRI("x", "knows", "y", "g1")))
+	require.NoError(t, hw.AddQuad(quad.MakeIRI("x", "knows", "y", "g2")))
+
+	require.NoError(t, Restore(ctx, qs, hw, quad.IRI("g1")))
+	require.Equal(t, ErrNotFound, Restore(ctx, qs, qw, quad.IRI("g1")))
+	hs, err = Hide(ctx, qs)
+	require.NoError(t, err)
+	require.Len(t, quadsOf(t, hs), 4)
+
+	require.NoError(t, Delete(ctx, qs, qw, quad.IRI("g1"), at))
+	require.NoError(t, Delete(ctx, qs, qw, quad.IRI("g2"), at.Add(time.Hour)))
+	n, err := PurgeDeleted(ctx, qs, qw, at.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	// only the mark of <g2> remains, besides its quads
+	require.Len(t, quadsOf(t, qs), 3)
+	list, err = List(ctx, qs)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, quad.IRI("g2"), list[0].Label)
+}
+
+func TestPurgeBatches(t *testing.T) {
+	ctx := context.TODO()
+	qs := memstore.New()
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	var quads []quad.Quad
+	for i := 0; i < PurgeBatch*2+1; i++ {
+		quads = append(quads, quad.Make(quad.IRI("a"), quad.IRI("knows"), i, quad.IRI("g1")))
+	}
+	require.NoError(t, qw.AddQuadSet(quads))
+	require.NoError(t, qw.AddQuad(quad.MakeIRI("a", "knows", "b", "g2")))
+
+	_, err = Purge(ctx, qs, qw, quad.IRI("g1"))
+	require.Equal(t, ErrNotFound, err)
+	require.NoError(t, Delete(ctx, qs, qw, quad.IRI("g1"), time.Now()))
+	n, err := Purge(ctx, qs, qw, quad.IRI("g1"))
+	require.NoError(t, err)
+	require.Equal(t, len(quads)+1, n)
+	require.Equal(t, []string{"<a> <knows> <b> <g2> ."}, quadsOf(t, qs))
+}
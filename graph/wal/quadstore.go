@@ -0,0 +1,186 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+var _ graph.Wrapper = (*QuadStore)(nil)
+
+// QuadStore writes all deltas to the log before applying them to the underlying quad store.
+//
+// Optional interfaces of the underlying quad store (except for conditional writes) are not exposed,
+// but snapshots and the history can be found with graph.SnapshotOf and graph.AsOf.
+type QuadStore struct {
+	graph.QuadStore
+	mu  sync.Mutex
+	log *Log
+}
+
+// New wraps a quad store with a write-ahead log. All batches that were not applied before
+// are replayed on the quad store before it's returned.
+//
+// The quad store takes the ownership of the log and closes it with the quad store.
+func New(qs graph.QuadStore, l *Log) (*QuadStore, error) {
+	s := &QuadStore{QuadStore: qs, log: l}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (qs *QuadStore) replay() error {
+	pending := qs.log.Pending()
+	if len(pending) == 0 {
+		return nil
+	}
+	clog.Infof("wal: replaying %d batches", len(pending))
+	for _, b := range pending {
+		err := qs.replayBatch(b)
+		if graph.IsPreconditionFailed(err) {
+			// batch was not applied, or conflicts with the database state
+			clog.Warningf("wal: skipping batch %d: %v", b.Seq, err)
+		} else if err != nil {
+			return err
+		}
+		if err = qs.log.Done(b.Seq); err != nil {
+			return err
+		}
+	}
+	return qs.log.Truncate()
+}
+
+// replayBatch applies the batch again with its options. The batch could be applied partially before the crash,
+// thus if it fails because of duplicate or missing quads, deltas that are already in effect are skipped,
+// and the rest of them are applied with the options of the batch.
+func (qs *QuadStore) replayBatch(b Batch) error {
+	err := qs.apply(b.Preconditions, b.Deltas, b.Opts)
+	if !graph.IsQuadExist(err) && !graph.IsQuadNotExist(err) {
+		return err
+	}
+	n, aerr := qs.applied(context.TODO(), b.Deltas)
+	if aerr != nil {
+		return aerr
+	} else if n == 0 {
+		return err
+	} else if n == len(b.Deltas) {
+		return nil
+	}
+	return qs.apply(b.Preconditions, b.Deltas[n:], b.Opts)
+}
+
+// applied returns the number of leading deltas that are in effect in the quad store.
+func (qs *QuadStore) applied(ctx context.Context, deltas []graph.Delta) (int, error) {
+	for i, d := range deltas {
+		ok, err := hasQuad(ctx, qs.QuadStore, d.Quad)
+		if err != nil {
+			return 0, err
+		} else if ok != (d.Action == graph.Add) {
+			return i, nil
+		}
+	}
+	return len(deltas), nil
+}
+
+// hasQuad checks if a quad exists in the quad store.
+func hasQuad(ctx context.Context, qs graph.QuadStore, q quad.Quad) (bool, error) {
+	ref := qs.ValueOf(q.Subject)
+	if ref == nil {
+		return false, nil
+	}
+	it := qs.QuadIterator(quad.Subject, ref)
+	defer it.Close()
+	for it.Next(ctx) {
+		if qs.Quad(it.Result()) == q {
+			return true, nil
+		}
+	}
+	return false, it.Err()
+}
+
+func (qs *QuadStore) apply(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	if len(conds) == 0 {
+		return qs.QuadStore.ApplyDeltas(deltas, opts)
+	}
+	ca, ok := qs.QuadStore.(graph.ConditionalApplier)
+	if !ok {
+		return graph.ErrPreconditionsNotSupported
+	}
+	return ca.ApplyDeltasIf(conds, deltas, opts)
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf logs the batch with its preconditions and applies it to the underlying quad store.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	if len(deltas) == 0 {
+		return nil
+	} else if len(conds) != 0 {
+		if _, ok := qs.QuadStore.(graph.ConditionalApplier); !ok {
+			return graph.ErrPreconditionsNotSupported
+		}
+	}
+	// batches must be applied in the same order they are written to the log
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	seq, err := qs.log.Append(deltas, conds, opts)
+	if err != nil {
+		return err
+	}
+	err = qs.apply(conds, deltas, opts)
+	if derr := qs.log.Done(seq); err == nil {
+		err = derr
+	}
+	return err
+}
+
+// Truncate removes applied batches from the log.
+func (qs *QuadStore) Truncate() error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	return qs.log.Truncate()
+}
+
+// Log returns the write-ahead log of the quad store.
+func (qs *QuadStore) Log() *Log {
+	return qs.log
+}
+
+func (qs *QuadStore) Close() error {
+	err := qs.QuadStore.Close()
+	if lerr := qs.log.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// Unwrapped returns the underlying quad store.
+func (qs *QuadStore) Unwrapped() graph.QuadStore {
+	return qs.QuadStore
+}
+
+// WrapSnapshot returns a snapshot of the underlying quad store as is, since the log only changes writes.
+func (qs *QuadStore) WrapSnapshot(s graph.Snapshot) (graph.Snapshot, error) {
+	return s, nil
+}
@@ -0,0 +1,394 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wal implements a write-ahead log for quad stores.
+//
+// Each batch of deltas is written to the log and synced to disk before it is applied to the
+// quad store, and is marked as done after the quad store returns. Batches that were not marked
+// as done (because of a crash) are applied again when the log is opened.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/proto"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+// Log file consists of frames: payload length (uint32), CRC-32C of the payload (uint32) and the payload.
+// First byte of the payload is a record type. A batch record continues with the sequence number (uvarint),
+// a byte of ignore flags and the deltas.
+const (
+	recBatch = 'B'
+	recDone  = 'D'
+
+	frameHeader = 8
+	// maxFrame protects from allocating huge buffers if the length is corrupted
+	maxFrame = 1 << 30
+)
+
+// Ignore flags of the batch.
+const (
+	flagIgnoreDup     = 1 << 0
+	flagIgnoreMissing = 1 << 1
+)
+
+// Preconditions are stored in the batch as log deltas with special actions.
+const (
+	actExists  = 2
+	actMissing = -2
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var ErrClosed = errors.New("wal: log is closed")
+
+// Batch is a single write recorded in the log.
+type Batch struct {
+	Seq           uint64
+	Deltas        []graph.Delta
+	Preconditions []graph.Precondition
+	// Opts are the options the batch was applied with.
+	Opts graph.IgnoreOpts
+}
+
+// Log is a file-based write-ahead log.
+type Log struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	seq     uint64
+	pending []Batch
+	nosync  bool
+}
+
+// Open opens or creates a log file. Torn writes at the end of the file are discarded.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{path: path, f: f}
+	end, err := l.read()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err = f.Truncate(end); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err = f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetNoSync disables syncing the log file after each batch. Batches may be lost
+// on power failure, but not when the process crashes.
+func (l *Log) SetNoSync(v bool) {
+	l.mu.Lock()
+	l.nosync = v
+	l.mu.Unlock()
+}
+
+// read loads all batches that are not done and returns an offset of the last valid frame.
+func (l *Log) read() (int64, error) {
+	r := bufio.NewReader(l.f)
+	var (
+		off  int64
+		hdr  [frameHeader]byte
+		done = make(map[uint64]struct{})
+	)
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			// EOF or a torn header
+			break
+		}
+		n := binary.LittleEndian.Uint32(hdr[:4])
+		if n == 0 || n > maxFrame {
+			break
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			break
+		} else if crc32.Checksum(buf, crcTable) != binary.LittleEndian.Uint32(hdr[4:]) {
+			break
+		}
+		switch buf[0] {
+		case recBatch:
+			b, err := decodeBatch(buf[1:])
+			if err != nil {
+				return 0, err
+			}
+			l.pending = append(l.pending, b)
+			if b.Seq > l.seq {
+				l.seq = b.Seq
+			}
+		case recDone:
+			seq, n := binary.Uvarint(buf[1:])
+			if n <= 0 {
+				return 0, errors.New("wal: invalid done record")
+			}
+			done[seq] = struct{}{}
+		default:
+			return 0, fmt.Errorf("wal: unknown record type: %q", buf[0])
+		}
+		off += frameHeader + int64(n)
+	}
+	pending := l.pending[:0]
+	for _, b := range l.pending {
+		if _, ok := done[b.Seq]; !ok {
+			pending = append(pending, b)
+		}
+	}
+	l.pending = pending
+	return off, nil
+}
+
+func encodeBatch(b Batch) ([]byte, error) {
+	buf := []byte{recBatch}
+	var tmp [binary.MaxVarintLen64]byte
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], b.Seq)]...)
+	var flags byte
+	if b.Opts.IgnoreDup {
+		flags |= flagIgnoreDup
+	}
+	if b.Opts.IgnoreMissing {
+		flags |= flagIgnoreMissing
+	}
+	buf = append(buf, flags)
+	ts := time.Now().UnixNano()
+	add := func(d *proto.LogDelta) error {
+		data, err := d.Marshal()
+		if err != nil {
+			return err
+		}
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(data)))]...)
+		buf = append(buf, data...)
+		return nil
+	}
+	for _, c := range b.Preconditions {
+		act := int32(actMissing)
+		if c.Exists {
+			act = actExists
+		}
+		if err := add(&proto.LogDelta{ID: b.Seq, Quad: pquads.MakeQuad(c.Quad), Action: act, Timestamp: ts}); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range b.Deltas {
+		if err := add(&proto.LogDelta{ID: b.Seq, Quad: pquads.MakeQuad(d.Quad), Action: int32(d.Action), Timestamp: ts}); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func decodeBatch(buf []byte) (Batch, error) {
+	var b Batch
+	seq, n := binary.Uvarint(buf)
+	if n <= 0 || len(buf) == n {
+		return b, errors.New("wal: invalid batch record")
+	}
+	b.Seq = seq
+	flags := buf[n]
+	b.Opts = graph.IgnoreOpts{
+		IgnoreDup:     flags&flagIgnoreDup != 0,
+		IgnoreMissing: flags&flagIgnoreMissing != 0,
+	}
+	buf = buf[n+1:]
+	for len(buf) != 0 {
+		sz, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < sz {
+			return b, errors.New("wal: invalid batch record")
+		}
+		var d proto.LogDelta
+		if err := d.Unmarshal(buf[n : n+int(sz)]); err != nil {
+			return b, err
+		}
+		buf = buf[n+int(sz):]
+		q := d.Quad.ToNative()
+		switch d.Action {
+		case actExists, actMissing:
+			b.Preconditions = append(b.Preconditions, graph.Precondition{Quad: q, Exists: d.Action == actExists})
+		case int32(graph.Add), int32(graph.Delete):
+			b.Deltas = append(b.Deltas, graph.Delta{Quad: q, Action: graph.Procedure(d.Action)})
+		default:
+			return b, fmt.Errorf("wal: unknown action: %d", d.Action)
+		}
+	}
+	return b, nil
+}
+
+func writeFrame(w io.Writer, payload []byte) error {
+	var hdr [frameHeader]byte
+	binary.LittleEndian.PutUint32(hdr[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(hdr[4:], crc32.Checksum(payload, crcTable))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// Pending returns batches that were written to the log, but were not marked as done.
+func (l *Log) Pending() []Batch {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Batch{}, l.pending...)
+}
+
+// Append writes a batch with the options it's applied with to the log and returns its sequence number.
+// The batch is on the disk when the function returns.
+func (l *Log) Append(deltas []graph.Delta, conds []graph.Precondition, opts graph.IgnoreOpts) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, ErrClosed
+	}
+	b := Batch{Seq: l.seq + 1, Deltas: deltas, Preconditions: conds, Opts: opts}
+	payload, err := encodeBatch(b)
+	if err != nil {
+		return 0, err
+	}
+	if err = writeFrame(l.f, payload); err != nil {
+		return 0, err
+	}
+	if !l.nosync {
+		if err = l.f.Sync(); err != nil {
+			return 0, err
+		}
+	}
+	l.seq = b.Seq
+	l.pending = append(l.pending, b)
+	return b.Seq, nil
+}
+
+// Done marks a batch as applied.
+//
+// Done records are not synced; in the worst case the batch will be applied again.
+func (l *Log) Done(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return ErrClosed
+	}
+	var tmp [1 + binary.MaxVarintLen64]byte
+	tmp[0] = recDone
+	n := binary.PutUvarint(tmp[1:], seq)
+	if err := writeFrame(l.f, tmp[:1+n]); err != nil {
+		return err
+	}
+	for i, b := range l.pending {
+		if b.Seq == seq {
+			l.pending = append(l.pending[:i], l.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Truncate removes all batches that are marked as done from the log file.
+func (l *Log) Truncate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return ErrClosed
+	}
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, b := range l.pending {
+		payload, err := encodeBatch(b)
+		if err == nil {
+			err = writeFrame(w, payload)
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	l.f.Close()
+	l.f = f
+	if _, err = f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	// the rename is durable only when the directory is synced
+	return syncDir(filepath.Dir(l.path))
+}
+
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Size returns the size of the log file.
+func (l *Log) Size() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, ErrClosed
+	}
+	st, err := l.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
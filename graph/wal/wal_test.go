@@ -0,0 +1,156 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func tempLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cayley-wal-")
+	require.NoError(t, err)
+	return filepath.Join(dir, "wal.log"), func() { os.RemoveAll(dir) }
+}
+
+func addDeltas(quads ...quad.Quad) []graph.Delta {
+	tx := graph.NewTransaction()
+	for _, q := range quads {
+		tx.AddQuad(q)
+	}
+	return tx.Deltas
+}
+
+var (
+	q1 = quad.Make("a", "follows", "b", nil)
+	q2 = quad.Make("b", "follows", "c", "g")
+	q3 = quad.Make("c", "follows", "a", nil)
+)
+
+func TestLogReplay(t *testing.T) {
+	path, closer := tempLog(t)
+	defer closer()
+
+	l, err := Open(path)
+	require.NoError(t, err)
+	seq, err := l.Append(addDeltas(q1), nil, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.NoError(t, l.Done(seq))
+	// the process crashes while applying the following batches
+	_, err = l.Append(addDeltas(q2), nil, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	_, err = l.Append(addDeltas(q3), []graph.Precondition{{Quad: q1, Exists: false}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	// torn write
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{10, 0, 0, 0, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	l, err = Open(path)
+	require.NoError(t, err)
+	pending := l.Pending()
+	require.Len(t, pending, 2)
+	require.Equal(t, addDeltas(q2), pending[0].Deltas)
+	require.Equal(t, []graph.Precondition{{Quad: q1, Exists: false}}, pending[1].Preconditions)
+
+	mem := memstore.New(q1)
+	qs, err := New(mem, l)
+	require.NoError(t, err)
+	defer qs.Close()
+	// the last batch is skipped because its precondition fails
+	graphtest.ExpectIteratedQuads(t, mem, mem.QuadsAllIterator(), []quad.Quad{q1, q2}, true)
+	require.Len(t, l.Pending(), 0)
+	sz, err := l.Size()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), sz)
+}
+
+func TestLogReplayOpts(t *testing.T) {
+	path, closer := tempLog(t)
+	defer closer()
+
+	l, err := Open(path)
+	require.NoError(t, err)
+	// the first quad of the batch was applied before the crash
+	_, err = l.Append(addDeltas(q1, q2), nil, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	_, err = l.Append(addDeltas(q3), nil, graph.IgnoreOpts{IgnoreDup: true})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	l, err = Open(path)
+	require.NoError(t, err)
+	pending := l.Pending()
+	require.Len(t, pending, 2)
+	require.Equal(t, graph.IgnoreOpts{}, pending[0].Opts)
+	require.Equal(t, graph.IgnoreOpts{IgnoreDup: true}, pending[1].Opts)
+
+	mem := memstore.New(q1, q3)
+	qs, err := New(mem, l)
+	require.NoError(t, err)
+	graphtest.ExpectIteratedQuads(t, mem, mem.QuadsAllIterator(), []quad.Quad{q1, q2, q3}, true)
+	require.NoError(t, qs.Close())
+
+	// duplicates that were not applied by the batch itself are still rejected
+	l, err = Open(path)
+	require.NoError(t, err)
+	_, err = l.Append(addDeltas(q2, q1), nil, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	_, err = New(memstore.New(q1), l)
+	require.True(t, graph.IsQuadExist(err), "%v", err)
+	require.NoError(t, l.Close())
+}
+
+func TestQuadStore(t *testing.T) {
+	path, closer := tempLog(t)
+	defer closer()
+
+	l, err := Open(path)
+	require.NoError(t, err)
+	qs, err := New(memstore.New(), l)
+	require.NoError(t, err)
+	defer qs.Close()
+
+	require.NoError(t, qs.ApplyDeltas(addDeltas(q1, q2), graph.IgnoreOpts{}))
+	err = qs.ApplyDeltas(addDeltas(q1), graph.IgnoreOpts{})
+	require.True(t, graph.IsQuadExist(err), "%v", err)
+	err = qs.ApplyDeltasIf([]graph.Precondition{{Quad: q3, Exists: true}}, addDeltas(q3), graph.IgnoreOpts{})
+	require.True(t, graph.IsPreconditionFailed(err), "%v", err)
+	require.Len(t, l.Pending(), 0)
+
+	sz, err := l.Size()
+	require.NoError(t, err)
+	require.True(t, sz > 0)
+	require.NoError(t, qs.Truncate())
+	sz, err = l.Size()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), sz)
+
+	require.NoError(t, qs.ApplyDeltas(addDeltas(q3), graph.IgnoreOpts{}))
+	graphtest.ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), []quad.Quad{q1, q2, q3}, true)
+}
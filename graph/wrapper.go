@@ -0,0 +1,136 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNoSnapshots is returned by SnapshotOf if neither the quad store nor the quad stores it wraps support snapshots.
+	ErrNoSnapshots = errors.New("quad store does not support snapshots")
+	// ErrNoTimeTravel is returned by AsOf and HorizonAt if neither the quad store nor the quad stores it wraps
+	// keep the history of changes.
+	ErrNoTimeTravel = errors.New("quad store does not support as of queries")
+)
+
+// Wrapper is implemented by quad stores that wrap another quad store, for example to log writes or to restrict reads.
+//
+// Wrappers don't expose optional interfaces of the underlying quad store directly. Instead, HorizonOf, SnapshotOf,
+// AsOf, HorizonAt and Find find them through all the wrappers.
+type Wrapper interface {
+	// Unwrapped returns the wrapped quad store.
+	Unwrapped() QuadStore
+	// WrapSnapshot applies the wrapper to a snapshot of the wrapped quad store.
+	// Wrappers that don't change reads return the snapshot as is.
+	WrapSnapshot(s Snapshot) (Snapshot, error)
+}
+
+// HorizonOf returns the last ID assigned by the quad store, or by the quad store it wraps.
+// It returns false if none of them report the horizon.
+func HorizonOf(qs QuadStore) (int64, bool) {
+	for {
+		qs = Unwrap(qs)
+		if h, ok := qs.(interface{ Horizon() int64 }); ok {
+			return h.Horizon(), true
+		}
+		w, ok := qs.(Wrapper)
+		if !ok {
+			return 0, false
+		}
+		qs = w.Unwrapped()
+	}
+}
+
+// SnapshotOf returns a snapshot of the quad store. If it wraps another quad store that supports snapshots,
+// the snapshot of the underlying quad store is returned with all the wrappers applied to it.
+func SnapshotOf(qs QuadStore) (Snapshot, error) {
+	return snapshotOf(qs, ErrNoSnapshots, func(qs QuadStore) (Snapshot, bool, error) {
+		s, ok := qs.(Snapshotter)
+		if !ok {
+			return nil, false, nil
+		}
+		snap, err := s.Snapshot()
+		return snap, true, err
+	})
+}
+
+// AsOf returns a view of the graph as it was when a given horizon was reached, in the same way as SnapshotOf.
+func AsOf(qs QuadStore, horizon int64) (Snapshot, error) {
+	return snapshotOf(qs, ErrNoTimeTravel, func(qs QuadStore) (Snapshot, bool, error) {
+		tt, ok := qs.(TimeTraveler)
+		if !ok {
+			return nil, false, nil
+		}
+		snap, err := tt.AsOf(horizon)
+		return snap, true, err
+	})
+}
+
+// HorizonAt returns the horizon of the last write committed at or before a given time,
+// by the quad store or by the quad store it wraps.
+func HorizonAt(qs QuadStore, t time.Time) (int64, error) {
+	for {
+		qs = Unwrap(qs)
+		if tt, ok := qs.(TimeTraveler); ok {
+			return tt.HorizonAt(t)
+		}
+		w, ok := qs.(Wrapper)
+		if !ok {
+			return 0, ErrNoTimeTravel
+		}
+		qs = w.Unwrapped()
+	}
+}
+
+// Find returns the first quad store that matches, looking through all the wrappers, or nil if none of them match.
+//
+// Wrappers may restrict reads, thus results of the quad store that is found must be intersected with the iterators
+// of the wrapper, like the candidates from the indexes of the quad store.
+func Find(qs QuadStore, match func(qs QuadStore) bool) QuadStore {
+	for {
+		qs = Unwrap(qs)
+		if match(qs) {
+			return qs
+		}
+		w, ok := qs.(Wrapper)
+		if !ok {
+			return nil
+		}
+		qs = w.Unwrapped()
+	}
+}
+
+func snapshotOf(qs QuadStore, errNo error, take func(qs QuadStore) (Snapshot, bool, error)) (Snapshot, error) {
+	qs = Unwrap(qs)
+	if snap, ok, err := take(qs); ok {
+		return snap, err
+	}
+	w, ok := qs.(Wrapper)
+	if !ok {
+		return nil, errNo
+	}
+	snap, err := snapshotOf(w.Unwrapped(), errNo, take)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := w.WrapSnapshot(snap)
+	if err != nil {
+		snap.Close()
+		return nil, err
+	}
+	return ws, nil
+}
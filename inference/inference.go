@@ -0,0 +1,195 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inference implements RDFS entailment rules for rdfs:subClassOf, rdfs:subPropertyOf,
+// rdfs:domain and rdfs:range.
+//
+// Inferred quads can either be materialized in the quad store under a separate label (see Materialize and QuadStore),
+// or matched at query time by rewriting paths (see SubClasses, SubProperties and Instances).
+//
+// All IRIs of the RDF and RDFS vocabularies are expected to be in the full form,
+// as they are written by standard RDF formats.
+package inference
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	"github.com/cayleygraph/cayley/voc/rdfs"
+)
+
+var (
+	rdfType       = quad.IRI(rdf.Type).Full()
+	subClassOf    = quad.IRI(rdfs.SubClassOf).Full()
+	subPropertyOf = quad.IRI(rdfs.SubPropertyOf).Full()
+	domain        = quad.IRI(rdfs.Domain).Full()
+	rangeOf       = quad.IRI(rdfs.Range).Full()
+)
+
+// IsSchema checks if a quad changes the schema, thus may affect inferences made from other quads.
+func IsSchema(q quad.Quad) bool {
+	switch q.Predicate {
+	case subClassOf, subPropertyOf, domain, rangeOf:
+		return true
+	}
+	return false
+}
+
+// Schema is a set of RDFS statements about classes and properties.
+type Schema struct {
+	// superClasses and superProps are transitive; values doesn't include the key itself
+	superClasses map[string][]quad.Value
+	superProps   map[string][]quad.Value
+	domains      map[string][]quad.Value
+	ranges       map[string][]quad.Value
+}
+
+// LoadSchema reads RDFS statements from the quad store.
+func LoadSchema(ctx context.Context, qs graph.QuadStore) (*Schema, error) {
+	return loadSchema(ctx, qs, nil)
+}
+
+// loadSchema reads RDFS statements, ignoring quads with a given label.
+// Materialized quads must be ignored, since they may be inferred from statements that are already removed.
+func loadSchema(ctx context.Context, qs graph.QuadStore, skip quad.Value) (*Schema, error) {
+	s := &Schema{}
+	sub, err := objectsOf(ctx, qs, subClassOf, skip)
+	if err != nil {
+		return nil, err
+	}
+	s.superClasses = closure(sub)
+	sub, err = objectsOf(ctx, qs, subPropertyOf, skip)
+	if err != nil {
+		return nil, err
+	}
+	s.superProps = closure(sub)
+	if s.domains, err = objectsOf(ctx, qs, domain, skip); err != nil {
+		return nil, err
+	}
+	if s.ranges, err = objectsOf(ctx, qs, rangeOf, skip); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// objectsOf returns all objects of a predicate, grouped by subjects.
+func objectsOf(ctx context.Context, qs graph.QuadStore, pred, skip quad.Value) (map[string][]quad.Value, error) {
+	out := make(map[string][]quad.Value)
+	ref := qs.ValueOf(pred)
+	if ref == nil {
+		return out, nil
+	}
+	it := qs.QuadIterator(quad.Predicate, ref)
+	defer it.Close()
+	seen := make(map[[2]string]bool)
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		if skip != nil && q.Label == skip {
+			continue
+		}
+		k := [2]string{q.Subject.String(), q.Object.String()}
+		if !seen[k] {
+			seen[k] = true
+			out[k[0]] = append(out[k[0]], q.Object)
+		}
+	}
+	return out, it.Err()
+}
+
+// closure computes a transitive closure of a relation.
+func closure(direct map[string][]quad.Value) map[string][]quad.Value {
+	out := make(map[string][]quad.Value, len(direct))
+	for k := range direct {
+		seen := map[string]bool{k: true}
+		var all []quad.Value
+		next := direct[k]
+		for len(next) != 0 {
+			v := next[0]
+			next = next[1:]
+			if seen[v.String()] {
+				continue
+			}
+			seen[v.String()] = true
+			all = append(all, v)
+			next = append(next, direct[v.String()]...)
+		}
+		out[k] = all
+	}
+	return out
+}
+
+// Infer returns quads that are entailed by a given quad. All inferred quads have a given label.
+//
+// The result doesn't include the quad itself, but may include quads that are already stored.
+func (s *Schema) Infer(q quad.Quad, label quad.Value) []quad.Quad {
+	var out []quad.Quad
+	seen := map[[3]string]bool{tripleKey(q): true}
+	add := func(sub, pred, obj quad.Value) {
+		iq := quad.Quad{Subject: sub, Predicate: pred, Object: obj, Label: label}
+		if k := tripleKey(iq); !seen[k] {
+			seen[k] = true
+			out = append(out, iq)
+		}
+	}
+	addType := func(n, class quad.Value) {
+		if !isNode(n) {
+			return
+		}
+		add(n, rdfType, class)
+		for _, c := range s.superClasses[class.String()] {
+			add(n, rdfType, c)
+		}
+	}
+	switch q.Predicate {
+	case rdfType:
+		addType(q.Subject, q.Object)
+	case subClassOf:
+		for _, c := range s.superClasses[q.Object.String()] {
+			add(q.Subject, subClassOf, c)
+		}
+	case subPropertyOf:
+		for _, p := range s.superProps[q.Object.String()] {
+			add(q.Subject, subPropertyOf, p)
+		}
+	}
+	props := append([]quad.Value{q.Predicate}, s.superProps[q.Predicate.String()]...)
+	for i, p := range props {
+		if i != 0 {
+			add(q.Subject, p, q.Object)
+		}
+		for _, c := range s.domains[p.String()] {
+			addType(q.Subject, c)
+		}
+		for _, c := range s.ranges[p.String()] {
+			addType(q.Object, c)
+		}
+	}
+	return out
+}
+
+// isNode checks if a value can be a subject of a quad.
+func isNode(v quad.Value) bool {
+	switch v.(type) {
+	case quad.IRI, quad.BNode:
+		return true
+	}
+	return false
+}
+
+// tripleKey returns a key of a quad that ignores its label.
+func tripleKey(q quad.Quad) [3]string {
+	return [3]string{q.Subject.String(), q.Predicate.String(), q.Object.String()}
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	student = quad.IRI("ex:Student")
+	person  = quad.IRI("ex:Person")
+	agent   = quad.IRI("ex:Agent")
+	knows   = quad.IRI("ex:knows")
+	friend  = quad.IRI("ex:friendOf")
+)
+
+var testSchema = []quad.Quad{
+	quad.Make(student, subClassOf, person, nil),
+	quad.Make(person, subClassOf, agent, nil),
+	quad.Make(friend, subPropertyOf, knows, nil),
+	quad.Make(knows, domain, person, nil),
+	quad.Make(knows, rangeOf, person, nil),
+}
+
+func newStore() *memstore.QuadStore {
+	return memstore.New(append(testSchema,
+		quad.Make(quad.IRI("ex:alice"), rdfType, student, nil),
+		quad.Make(quad.IRI("ex:bob"), friend, quad.IRI("ex:carol"), nil),
+	)...)
+}
+
+// inferred returns all quads with a given label as strings.
+func inferred(t testing.TB, qs graph.QuadStore, label quad.Value) []string {
+	var out []string
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	for it.Next(context.TODO()) {
+		if q := qs.Quad(it.Result()); q.Label == label {
+			out = append(out, quad.Quad{Subject: q.Subject, Predicate: q.Predicate, Object: q.Object}.String())
+		}
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(out)
+	return out
+}
+
+func triples(quads ...quad.Quad) []string {
+	var out []string
+	for _, q := range quads {
+		out = append(out, q.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestMaterialize(t *testing.T) {
+	qs := newStore()
+	ctx := context.TODO()
+	added, removed, err := Materialize(ctx, qs, nil)
+	require.NoError(t, err)
+	expect := triples(
+		quad.Make(student, subClassOf, agent, nil),
+		quad.Make(quad.IRI("ex:alice"), rdfType, person, nil),
+		quad.Make(quad.IRI("ex:alice"), rdfType, agent, nil),
+		quad.Make(quad.IRI("ex:bob"), knows, quad.IRI("ex:carol"), nil),
+		quad.Make(quad.IRI("ex:bob"), rdfType, person, nil),
+		quad.Make(quad.IRI("ex:bob"), rdfType, agent, nil),
+		quad.Make(quad.IRI("ex:carol"), rdfType, person, nil),
+		quad.Make(quad.IRI("ex:carol"), rdfType, agent, nil),
+	)
+	require.Equal(t, expect, inferred(t, qs, DefaultLabel))
+	require.Equal(t, len(expect), added)
+	require.Equal(t, 0, removed)
+
+	// second pass should not change anything
+	added, removed, err = Materialize(ctx, qs, nil)
+	require.NoError(t, err)
+	require.Equal(t, [2]int{0, 0}, [2]int{added, removed})
+}
+
+func TestQuadStore(t *testing.T) {
+	ctx := context.TODO()
+	base := newStore()
+	qs, err := NewQuadStore(ctx, base, nil)
+	require.NoError(t, err)
+
+	dave := quad.Make(quad.IRI("ex:dave"), rdfType, person, nil)
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: dave, Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	ok, err := hasTriple(ctx, base, quad.Make(quad.IRI("ex:dave"), rdfType, agent, nil))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// removing the schema quad must remove all quads inferred from it
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: testSchema[1], Action: graph.Delete}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	for _, s := range inferred(t, base, DefaultLabel) {
+		require.NotContains(t, s, string(agent))
+	}
+}
+
+func TestInstances(t *testing.T) {
+	qs := newStore()
+	vals, err := Instances(qs, agent).Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	var names []string
+	for _, v := range vals {
+		names = append(names, string(v.(quad.IRI)))
+	}
+	sort.Strings(names)
+	require.Equal(t, []string{"ex:alice", "ex:bob", "ex:carol"}, names)
+
+	vals, err = path.StartPath(qs, quad.IRI("ex:bob")).Out(SubProperties(qs, knows)).Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	require.Equal(t, []quad.Value{quad.IRI("ex:carol")}, vals)
+}
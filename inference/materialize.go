@@ -0,0 +1,214 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultLabel is a label of materialized quads, used if no label is specified.
+const DefaultLabel = quad.IRI("cayley:inferred")
+
+// Materialize adds all quads that can be inferred from the quad store with a given label,
+// and removes quads with this label that can no longer be inferred.
+//
+// Quads that are already stored with other labels are not duplicated.
+func Materialize(ctx context.Context, qs graph.QuadStore, label quad.Value) (added, removed int, _ error) {
+	if label == nil {
+		label = DefaultLabel
+	}
+	s, err := loadSchema(ctx, qs, label)
+	if err != nil {
+		return 0, 0, err
+	}
+	var (
+		have     = make(map[[3]string]quad.Quad)
+		asserted = make(map[[3]string]bool)
+		want     = make(map[[3]string]quad.Quad)
+	)
+	it := qs.QuadsAllIterator()
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		if q.Label == label {
+			have[tripleKey(q)] = q
+			continue
+		}
+		asserted[tripleKey(q)] = true
+		for _, iq := range s.Infer(q, label) {
+			want[tripleKey(iq)] = iq
+		}
+	}
+	err = it.Err()
+	it.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+	var deltas []graph.Delta
+	for k, q := range have {
+		if _, ok := want[k]; !ok || asserted[k] {
+			deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Delete})
+			removed++
+		}
+	}
+	for k, q := range want {
+		if _, ok := have[k]; !ok && !asserted[k] {
+			deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+			added++
+		}
+	}
+	if len(deltas) == 0 {
+		return 0, 0, nil
+	}
+	if err = qs.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}); err != nil {
+		return 0, 0, err
+	}
+	return added, removed, nil
+}
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+
+// QuadStore keeps materialized inferences up to date with writes made through it.
+//
+// New quads that don't change the schema are handled incrementally. Deletions and changes to the schema
+// cause a full Materialize pass, which reads the whole quad store.
+type QuadStore struct {
+	graph.QuadStore
+	label quad.Value
+
+	mu     sync.Mutex
+	schema *Schema
+}
+
+// NewQuadStore wraps a quad store to materialize inferences with a given label on write.
+// Inferences for existing data are materialized first.
+func NewQuadStore(ctx context.Context, qs graph.QuadStore, label quad.Value) (*QuadStore, error) {
+	if label == nil {
+		label = DefaultLabel
+	}
+	if _, _, err := Materialize(ctx, qs, label); err != nil {
+		return nil, err
+	}
+	s, err := loadSchema(ctx, qs, label)
+	if err != nil {
+		return nil, err
+	}
+	return &QuadStore{QuadStore: qs, label: label, schema: s}, nil
+}
+
+// Schema returns the schema used for inference.
+func (qs *QuadStore) Schema() *Schema {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	return qs.schema
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf applies deltas to the underlying quad store and updates inferred quads.
+//
+// An error is returned if the deltas were applied, but inferences were not updated.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	ctx := context.TODO()
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	var err error
+	if len(conds) == 0 {
+		err = qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		err = ca.ApplyDeltasIf(conds, deltas, opts)
+	} else {
+		err = graph.ErrPreconditionsNotSupported
+	}
+	if err != nil || len(deltas) == 0 {
+		return err
+	}
+	full := false
+	for _, d := range deltas {
+		if d.Action == graph.Delete || IsSchema(d.Quad) || d.Quad.Label == qs.label {
+			full = true
+			break
+		}
+	}
+	if full {
+		err = qs.rematerialize(ctx)
+	} else {
+		err = qs.infer(ctx, deltas)
+	}
+	if err != nil {
+		return fmt.Errorf("inference: failed to update inferred quads: %v", err)
+	}
+	return nil
+}
+
+func (qs *QuadStore) rematerialize(ctx context.Context) error {
+	if _, _, err := Materialize(ctx, qs.QuadStore, qs.label); err != nil {
+		return err
+	}
+	s, err := loadSchema(ctx, qs.QuadStore, qs.label)
+	if err != nil {
+		return err
+	}
+	qs.schema = s
+	return nil
+}
+
+// infer adds quads inferred from new quads.
+func (qs *QuadStore) infer(ctx context.Context, deltas []graph.Delta) error {
+	var add []graph.Delta
+	seen := make(map[[3]string]bool)
+	for _, d := range deltas {
+		for _, iq := range qs.schema.Infer(d.Quad, qs.label) {
+			k := tripleKey(iq)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			ok, err := hasTriple(ctx, qs.QuadStore, iq)
+			if err != nil {
+				return err
+			} else if !ok {
+				add = append(add, graph.Delta{Quad: iq, Action: graph.Add})
+			}
+		}
+	}
+	if len(add) == 0 {
+		return nil
+	}
+	return qs.QuadStore.ApplyDeltas(add, graph.IgnoreOpts{IgnoreDup: true})
+}
+
+// hasTriple checks if a quad with the same subject, predicate and object exists, regardless of its label.
+func hasTriple(ctx context.Context, qs graph.QuadStore, q quad.Quad) (bool, error) {
+	ref := qs.ValueOf(q.Subject)
+	if ref == nil {
+		return false, nil
+	}
+	it := qs.QuadIterator(quad.Subject, ref)
+	defer it.Close()
+	k := tripleKey(q)
+	for it.Next(ctx) {
+		if tripleKey(qs.Quad(it.Result())) == k {
+			return true, nil
+		}
+	}
+	return false, it.Err()
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Following functions rewrite queries to match inferred quads without materializing them.
+
+// withSubjects adds all nodes that are linked to nodes of a path by a given predicate, recursively.
+func withSubjects(p *path.Path, pred quad.Value) *path.Path {
+	return p.Or(p.FollowRecursive(path.StartMorphism().In(pred), -1, nil)).Unique()
+}
+
+// SubClasses returns a path to given classes and all their sub-classes.
+func SubClasses(qs graph.QuadStore, classes ...quad.Value) *path.Path {
+	return withSubjects(path.StartPath(qs, classes...), subClassOf)
+}
+
+// SubProperties returns a path to given properties and all their sub-properties.
+//
+// It can be used to follow a property with all its sub-properties:
+//
+//  p.Out(inference.SubProperties(qs, quad.IRI("knows")))
+func SubProperties(qs graph.QuadStore, props ...quad.Value) *path.Path {
+	return withSubjects(path.StartPath(qs, props...), subPropertyOf)
+}
+
+// Instances returns a path to all instances of given classes.
+//
+// Instances are nodes with rdf:type of the class or any of its sub-classes, subjects of properties with a domain
+// of these classes and objects of properties with such a range, including sub-properties of both.
+func Instances(qs graph.QuadStore, classes ...quad.Value) *path.Path {
+	cl := SubClasses(qs, classes...)
+	withDomain := withSubjects(cl.In(domain), subPropertyOf)
+	withRange := withSubjects(cl.In(rangeOf), subPropertyOf)
+	return cl.In(rdfType).
+		Or(path.StartPath(qs).Has(withDomain)).
+		Or(path.StartPath(qs).HasReverse(withRange)).
+		Unique()
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backup implements online backups of quad stores.
+//
+// A full backup is a directory with a metadata file and all quads in pquads format.
+// An incremental backup contains deltas applied since the horizon of a previous backup instead.
+// The metadata file is written last, so an interrupted backup is never mistaken for a complete one.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/pquads"
+)
+
+const (
+	// Version is the current version of the backup layout.
+	Version = 1
+
+	MetaFile   = "meta.json"
+	QuadsFile  = "quads.pq.gz"
+	DeltasFile = "deltas.json.gz"
+
+	// hdrHorizon is set by the HTTP API to the horizon of the snapshot the data was read from.
+	hdrHorizon = "X-Cayley-Horizon"
+)
+
+var ErrExists = errors.New("backup: directory is not empty")
+
+// Meta describes a backup.
+type Meta struct {
+	Version int       `json:"version"`
+	Backend string    `json:"backend,omitempty"`
+	Created time.Time `json:"created"`
+	// Horizon is the last ID assigned by the quad store when the backup was taken.
+	// It is only set if the quad store supports snapshots.
+	Horizon int64 `json:"horizon,omitempty"`
+	// Consistent is set if all quads were read from a single snapshot of the quad store.
+	Consistent bool  `json:"consistent"`
+	Quads      int64 `json:"quads"`
+	// Incremental is set for backups that contain deltas applied after the Base horizon.
+	Incremental bool  `json:"incremental,omitempty"`
+	Base        int64 `json:"base,omitempty"`
+	Deltas      int64 `json:"deltas,omitempty"`
+}
+
+// ReadMeta reads metadata of a backup in a given directory.
+func ReadMeta(dir string) (*Meta, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, MetaFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Meta
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	} else if m.Version != Version {
+		return nil, fmt.Errorf("backup: unsupported version: %d", m.Version)
+	}
+	return &m, nil
+}
+
+func writeMeta(dir string, m *Meta) error {
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "."+MetaFile+".tmp")
+	if err = ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, MetaFile))
+}
+
+// createDir creates a backup directory, or checks that it's empty.
+func createDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	names, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	} else if len(names) != 0 {
+		return ErrExists
+	}
+	return nil
+}
+
+// writeQuads writes all quads from the reader to a compressed pquads file.
+func writeQuads(path string, qr quad.Reader) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	zw := gzip.NewWriter(bw)
+	qw := pquads.NewWriter(zw, &pquads.Options{Full: false, Strict: false})
+	n, err := quad.Copy(qw, qr)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range []func() error{qw.Close, zw.Close, bw.Flush, f.Sync} {
+		if err = c(); err != nil {
+			return 0, err
+		}
+	}
+	return int64(n), f.Close()
+}
+
+// Create writes all quads of the quad store to a new backup in a given directory.
+// Writes to the quad store can continue while the backup is taken.
+//
+// If the quad store supports snapshots, all quads are read from a single snapshot.
+// Otherwise the backup might include only a part of writes made while it was taken.
+func Create(dir string, qs graph.QuadStore, backend string) (*Meta, error) {
+	if err := createDir(dir); err != nil {
+		return nil, err
+	}
+	m := &Meta{Version: Version, Backend: backend, Created: time.Now().UTC()}
+	if s, ok := qs.(graph.Snapshotter); ok {
+		snap, err := s.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		defer snap.Close()
+		qs = snap
+		m.Horizon = snap.Horizon()
+		m.Consistent = true
+	} else {
+		clog.Warningf("backup: backend does not support snapshots; concurrent writes may be partially included")
+	}
+	qr := graph.NewQuadStoreReader(qs)
+	defer qr.Close()
+	n, err := writeQuads(filepath.Join(dir, QuadsFile), qr)
+	if err != nil {
+		return nil, err
+	}
+	m.Quads = n
+	if err = writeMeta(dir, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Download writes all quads served by a Cayley HTTP server at a given base URL to a new backup.
+// The server pins the read to a snapshot if its backend supports it.
+func Download(dir, addr string, cli *http.Client) (*Meta, error) {
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	if err := createDir(dir); err != nil {
+		return nil, err
+	}
+	resp, err := cli.Get(strings.TrimSuffix(addr, "/") + "/api/v2/read?format=pquads")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("backup: unexpected response: %s: %s", resp.Status, data)
+	}
+	m := &Meta{Version: Version, Created: time.Now().UTC()}
+	if h := resp.Header.Get(hdrHorizon); h != "" {
+		if m.Horizon, err = strconv.ParseInt(h, 10, 64); err != nil {
+			return nil, fmt.Errorf("backup: invalid horizon: %q", h)
+		}
+		m.Consistent = true
+	}
+	qr := pquads.NewReader(resp.Body, pquads.DefaultMaxSize)
+	defer qr.Close()
+	if m.Quads, err = writeQuads(filepath.Join(dir, QuadsFile), qr); err != nil {
+		return nil, err
+	}
+	if err = writeMeta(dir, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OpenQuads returns a reader for quads stored in the backup.
+func OpenQuads(dir string) (quad.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(dir, QuadsFile))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	qr := pquads.NewReader(zr, pquads.DefaultMaxSize)
+	qr.SetCloser(f)
+	return qr, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/server/http"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cayley-backup-")
+	require.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func readBackup(t *testing.T, dir string) []quad.Quad {
+	qr, err := OpenQuads(dir)
+	require.NoError(t, err)
+	defer qr.Close()
+	quads, err := quad.ReadAll(qr)
+	require.NoError(t, err)
+	return quads
+}
+
+func newKV(t *testing.T) (graph.QuadStore, graph.QuadWriter) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, graph.Options{kv.OptHistory: true})
+	require.NoError(t, err)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	return qs, qw
+}
+
+var quads = []quad.Quad{
+	quad.Make("a", "follows", "b", nil),
+	quad.Make("b", "age", quad.Int(42), "g"),
+}
+
+func TestCreate(t *testing.T) {
+	dir, closer := tempDir(t)
+	defer closer()
+
+	qs, qw := newKV(t)
+	defer qs.Close()
+	require.NoError(t, qw.AddQuadSet(quads))
+
+	m, err := Create(dir, qs, "btree")
+	require.NoError(t, err)
+	require.True(t, m.Consistent)
+	require.Equal(t, qs.(graph.Snapshot).Horizon(), m.Horizon)
+	require.Equal(t, int64(2), m.Quads)
+
+	m2, err := ReadMeta(dir)
+	require.NoError(t, err)
+	require.Equal(t, m.Horizon, m2.Horizon)
+	require.Equal(t, "btree", m2.Backend)
+	require.Equal(t, quads, readBackup(t, dir))
+
+	_, err = Create(dir, qs, "btree")
+	require.Equal(t, ErrExists, err)
+
+	// stores without snapshots can still be backed up
+	dir2 := filepath.Join(dir, "mem")
+	m, err = Create(dir2, memstore.New(quads...), "memstore")
+	require.NoError(t, err)
+	require.False(t, m.Consistent)
+	require.Equal(t, quads, readBackup(t, dir2))
+}
+
+func TestDownload(t *testing.T) {
+	dir, closer := tempDir(t)
+	defer closer()
+
+	qs, qw := newKV(t)
+	defer qs.Close()
+	require.NoError(t, qw.AddQuadSet(quads))
+	srv := httptest.NewServer(cayleyhttp.NewAPIv2(&graph.Handle{QuadStore: qs, QuadWriter: qw}))
+	defer srv.Close()
+
+	m, err := Download(dir, srv.URL, nil)
+	require.NoError(t, err)
+	require.True(t, m.Consistent)
+	require.Equal(t, qs.(graph.Snapshot).Horizon(), m.Horizon)
+	require.Equal(t, int64(2), m.Quads)
+	require.Equal(t, quads, readBackup(t, dir))
+}
+
+func TestIncrementalRestore(t *testing.T) {
+	dir, closer := tempDir(t)
+	defer closer()
+	full, inc1, inc2 := filepath.Join(dir, "full"), filepath.Join(dir, "inc1"), filepath.Join(dir, "inc2")
+
+	qs, qw := newKV(t)
+	defer qs.Close()
+	horizon := func() int64 { return qs.(graph.Snapshot).Horizon() }
+	require.NoError(t, qw.AddQuadSet(quads))
+	base, err := Create(full, qs, "btree")
+	require.NoError(t, err)
+
+	extra := quad.Make("c", "follows", "a", nil)
+	require.NoError(t, qw.AddQuad(extra))
+	h1 := horizon()
+	require.NoError(t, qw.RemoveQuad(quads[0]))
+	h2 := horizon()
+
+	m1, err := CreateIncremental(inc1, qs, "btree", base)
+	require.NoError(t, err)
+	require.True(t, m1.Incremental)
+	require.Equal(t, base.Horizon, m1.Base)
+	require.Equal(t, h2, m1.Horizon)
+	require.Equal(t, int64(2), m1.Deltas)
+
+	require.NoError(t, qw.AddQuad(quads[0]))
+	h3 := horizon()
+	m2, err := CreateIncremental(inc2, qs, "btree", m1)
+	require.NoError(t, err)
+	require.Equal(t, h2, m2.Base)
+
+	restore := func(dirs []string, target Target) ([]quad.Quad, *Restored) {
+		dst, _ := newKV(t)
+		defer dst.Close()
+		res, err := Restore(dst, dirs, target)
+		require.NoError(t, err)
+		qr := graph.NewQuadStoreReader(dst)
+		defer qr.Close()
+		got, err := quad.ReadAll(qr)
+		require.NoError(t, err)
+		return got, res
+	}
+	all := []string{full, inc1, inc2}
+
+	got, res := restore(all, Target{})
+	require.ElementsMatch(t, []quad.Quad{quads[0], quads[1], extra}, got)
+	require.Equal(t, h3, res.Horizon)
+
+	got, res = restore(all, Target{Horizon: h1})
+	require.ElementsMatch(t, []quad.Quad{quads[0], quads[1], extra}, got)
+	require.Equal(t, h1, res.Horizon)
+	require.Equal(t, int64(1), res.Deltas)
+
+	got, res = restore(all, Target{Horizon: h2})
+	require.ElementsMatch(t, []quad.Quad{quads[1], extra}, got)
+	require.Equal(t, h2, res.Horizon)
+
+	got, _ = restore(all[:1], Target{})
+	require.ElementsMatch(t, quads, got)
+
+	dst, _ := newKV(t)
+	defer dst.Close()
+	_, err = Restore(dst, []string{full, inc2}, Target{})
+	require.Error(t, err, "gap between the backups")
+	_, err = Restore(dst, []string{inc1}, Target{})
+	require.Error(t, err, "no full backup")
+	_, err = Restore(dst, all, Target{Horizon: base.Horizon - 1})
+	require.Error(t, err, "target before the full backup")
+
+	_, err = CreateIncremental(filepath.Join(dir, "mem"), memstore.New(quads...), "memstore", base)
+	require.Equal(t, ErrNoChangeLog, err)
+}
@@ -0,0 +1,273 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+var ErrNoChangeLog = errors.New("backup: backend does not support incremental backups")
+
+type jsonDelta struct {
+	Horizon int64     `json:"horizon"`
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Quad    string    `json:"quad"`
+}
+
+// CreateIncremental writes deltas applied to the quad store after the horizon of a base backup
+// to a new backup in a given directory. The base might be a full or an incremental backup.
+//
+// The quad store must support snapshots and must implement graph.ChangeLog.
+func CreateIncremental(dir string, qs graph.QuadStore, backend string, base *Meta) (*Meta, error) {
+	cl, ok := qs.(graph.ChangeLog)
+	if !ok {
+		return nil, ErrNoChangeLog
+	}
+	s, ok := qs.(graph.Snapshotter)
+	if !ok {
+		return nil, ErrNoChangeLog
+	}
+	if !base.Consistent {
+		return nil, errors.New("backup: base backup is not pinned to a horizon")
+	}
+	snap, err := s.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	to := snap.Horizon()
+	snap.Close()
+	if to < base.Horizon {
+		return nil, fmt.Errorf("backup: base backup at horizon %d is ahead of the database (%d)", base.Horizon, to)
+	}
+	if err := createDir(dir); err != nil {
+		return nil, err
+	}
+	m := &Meta{
+		Version: Version, Backend: backend, Created: time.Now().UTC(),
+		Horizon: to, Consistent: true, Incremental: true, Base: base.Horizon,
+	}
+	f, err := os.Create(filepath.Join(dir, DeltasFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	zw := gzip.NewWriter(bw)
+	enc := json.NewEncoder(zw)
+	err = cl.Changes(context.TODO(), base.Horizon, to, func(d graph.LoggedDelta) error {
+		m.Deltas++
+		jd := jsonDelta{Horizon: d.Horizon, Time: d.Time, Action: "add", Quad: d.Quad.NQuad()}
+		if d.Action == graph.Delete {
+			jd.Action = "delete"
+		}
+		return enc.Encode(jd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range []func() error{zw.Close, bw.Flush, f.Sync, f.Close} {
+		if err = c(); err != nil {
+			return nil, err
+		}
+	}
+	if err = writeMeta(dir, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadDeltas calls fn for each delta stored in an incremental backup, in the order they were applied.
+func ReadDeltas(dir string, fn func(graph.LoggedDelta) error) error {
+	f, err := os.Open(filepath.Join(dir, DeltasFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(zr)
+	for {
+		var jd jsonDelta
+		if err := dec.Decode(&jd); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		d := graph.LoggedDelta{Horizon: jd.Horizon, Time: jd.Time}
+		switch jd.Action {
+		case "add":
+			d.Action = graph.Add
+		case "delete":
+			d.Action = graph.Delete
+		default:
+			return fmt.Errorf("backup: unknown action: %q", jd.Action)
+		}
+		if d.Quad, err = nquads.Parse(jd.Quad); err != nil {
+			return err
+		}
+		if err = fn(d); err != nil {
+			return err
+		}
+	}
+}
+
+// Target is a point in time to restore the database to.
+// If neither field is set, all backups are restored completely.
+type Target struct {
+	// Horizon restores all transactions committed at or before a given horizon.
+	Horizon int64
+	// Time restores all transactions committed at or before a given time.
+	Time time.Time
+}
+
+func (t Target) excludes(d graph.LoggedDelta) bool {
+	if t.Horizon > 0 && d.Horizon > t.Horizon {
+		return true
+	}
+	// deltas without a commit time were written before it was recorded; they are older than any commit with it
+	return !t.Time.IsZero() && !d.Time.IsZero() && d.Time.After(t.Time)
+}
+
+// Restored describes the state the database was restored to.
+type Restored struct {
+	// Horizon is the horizon of the last restored transaction in the original database.
+	Horizon int64
+	// Time is the commit time of the last restored transaction, if known.
+	Time   time.Time
+	Quads  int64
+	Deltas int64
+}
+
+// Restore loads a full backup into an empty quad store and replays incremental backups on top of it,
+// stopping at a given target. Each backup in dirs must start at the horizon of the previous one.
+//
+// Deltas are applied one transaction at a time, thus the result is equal to the state of the original
+// database at the horizon of the last restored transaction.
+func Restore(qs graph.QuadStore, dirs []string, target Target) (*Restored, error) {
+	if len(dirs) == 0 {
+		return nil, errors.New("backup: no backups to restore")
+	}
+	metas := make([]*Meta, 0, len(dirs))
+	for i, dir := range dirs {
+		m, err := ReadMeta(dir)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case i == 0 && m.Incremental:
+			return nil, fmt.Errorf("backup: %q is incremental; restore must start from a full backup", dir)
+		case i != 0 && !m.Incremental:
+			return nil, fmt.Errorf("backup: %q is a full backup; only incremental backups can follow the first one", dir)
+		case i == 0 && len(dirs) > 1 && !m.Consistent:
+			return nil, fmt.Errorf("backup: %q is not pinned to a horizon; incremental backups cannot be applied to it", dir)
+		case i != 0 && m.Base != metas[i-1].Horizon:
+			return nil, fmt.Errorf("backup: %q starts at horizon %d, expected %d", dir, m.Base, metas[i-1].Horizon)
+		}
+		metas = append(metas, m)
+	}
+	if target.Horizon > 0 && metas[0].Consistent && target.Horizon < metas[0].Horizon {
+		return nil, fmt.Errorf("backup: horizon %d is before the full backup (%d)", target.Horizon, metas[0].Horizon)
+	} else if !target.Time.IsZero() && target.Time.Before(metas[0].Created) {
+		return nil, fmt.Errorf("backup: time %v is before the full backup (%v)", target.Time, metas[0].Created)
+	}
+	res := &Restored{Horizon: metas[0].Horizon, Time: metas[0].Created}
+
+	qr, err := OpenQuads(dirs[0])
+	if err != nil {
+		return nil, err
+	}
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{IgnoreDup: true})
+	if err != nil {
+		qr.Close()
+		return nil, err
+	}
+	w := graph.NewWriter(qw)
+	n, err := quad.CopyBatch(w, qr, quad.DefaultBatch)
+	qr.Close()
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Quads = int64(n)
+
+	opts := graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}
+	var (
+		tx   []graph.Delta
+		last graph.LoggedDelta
+		done bool
+	)
+	flush := func() error {
+		if len(tx) == 0 {
+			return nil
+		}
+		if err := qs.ApplyDeltas(tx, opts); err != nil {
+			return err
+		}
+		res.Deltas += int64(len(tx))
+		res.Horizon, res.Time = last.Horizon, last.Time
+		tx = tx[:0]
+		return nil
+	}
+	errStop := errors.New("stop")
+	for _, dir := range dirs[1:] {
+		err = ReadDeltas(dir, func(d graph.LoggedDelta) error {
+			if target.excludes(d) {
+				done = true
+				return errStop
+			}
+			if len(tx) != 0 && d.Horizon != last.Horizon {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			tx = append(tx, d.Delta)
+			last = d
+			return nil
+		})
+		if err != nil && err != errStop {
+			return nil, err
+		}
+		if err = flush(); err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	if !done {
+		// no transactions were excluded; the database is at the horizon of the last backup
+		res.Horizon = metas[len(metas)-1].Horizon
+	}
+	return res, nil
+}
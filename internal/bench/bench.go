@@ -0,0 +1,253 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench runs a standard mix of queries against a quad store and measures their latency.
+//
+// The mix is built from a sample of quads that link two nodes: lookups follow the predicate of a sampled quad
+// from its subject, joins follow it and then any predicate, recursive traversals follow it up to a fixed depth,
+// and writes add and remove a quad next to the subject. The same seed gives the same sequence of queries.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	// Follows is a predicate of edges in generated datasets.
+	Follows = quad.IRI("bench:follows")
+	// Name is a predicate of names in generated datasets.
+	Name = quad.IRI("bench:name")
+	// Written is a predicate of quads added by writes.
+	Written = quad.IRI("bench:written")
+
+	// RecursiveDepth is the maximal depth of recursive traversals.
+	RecursiveDepth = 3
+
+	// DefaultDuration is the default duration of each query in the mix.
+	DefaultDuration = 5 * time.Second
+)
+
+// Generate returns a synthetic social graph with a given number of people, each following
+// a given number of random people and having a name.
+func Generate(people, degree int, seed int64) []quad.Quad {
+	rnd := rand.New(rand.NewSource(seed))
+	node := func(i int) quad.IRI {
+		return quad.IRI(fmt.Sprintf("bench:person/%d", i))
+	}
+	out := make([]quad.Quad, 0, people*(degree+1))
+	for i := 0; i < people; i++ {
+		out = append(out, quad.Make(node(i), Name, quad.String(fmt.Sprintf("Person %d", i)), nil))
+		seen := map[int]struct{}{i: {}}
+		for j := 0; j < degree && len(seen) < people; j++ {
+			k := rnd.Intn(people)
+			if _, ok := seen[k]; ok {
+				j--
+				continue
+			}
+			seen[k] = struct{}{}
+			out = append(out, quad.Make(node(i), Follows, node(k), nil))
+		}
+	}
+	return out
+}
+
+// Workload is a sample of edges the queries start from.
+type Workload struct {
+	Edges []quad.Quad
+}
+
+// Sample returns a workload with up to a given number of quads that link two nodes, evenly spread over the quad store.
+func Sample(ctx context.Context, qs graph.QuadStore, size int) (*Workload, error) {
+	// size estimation is enough to spread the sample
+	step := qs.Size() / int64(size)
+	if step < 1 {
+		step = 1
+	}
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	w := &Workload{}
+	var i int64
+	for len(w.Edges) < size && it.Next(ctx) {
+		if i++; i%step != 0 {
+			continue
+		}
+		q := qs.Quad(it.Result())
+		switch q.Object.(type) {
+		case quad.IRI, quad.BNode:
+			w.Edges = append(w.Edges, q)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	} else if len(w.Edges) == 0 {
+		return nil, fmt.Errorf("bench: no quads link two nodes")
+	}
+	return w, nil
+}
+
+// Query is a kind of operation in the mix.
+type Query struct {
+	Name string
+	// Write is set for queries that change the quad store. They are run by a single worker.
+	Write bool
+	Run   func(ctx context.Context, h *graph.Handle, e quad.Quad, n int) error
+}
+
+func each(ctx context.Context, p *path.Path) error {
+	return p.Iterate(ctx).EachValue(nil, func(quad.Value) {})
+}
+
+// Mix is the standard mix of queries.
+var Mix = []Query{
+	{Name: "lookup", Run: func(ctx context.Context, h *graph.Handle, e quad.Quad, _ int) error {
+		return each(ctx, path.StartPath(h, e.Subject).Out(e.Predicate))
+	}},
+	{Name: "2-hop", Run: func(ctx context.Context, h *graph.Handle, e quad.Quad, _ int) error {
+		return each(ctx, path.StartPath(h, e.Subject).Out(e.Predicate).Out())
+	}},
+	{Name: "recursive", Run: func(ctx context.Context, h *graph.Handle, e quad.Quad, _ int) error {
+		return each(ctx, path.StartPath(h, e.Subject).FollowRecursive(e.Predicate, RecursiveDepth, nil))
+	}},
+	{Name: "write", Write: true, Run: func(ctx context.Context, h *graph.Handle, e quad.Quad, n int) error {
+		q := quad.Make(e.Subject, Written, quad.Int(n), nil)
+		if err := h.AddQuad(q); err != nil {
+			return err
+		}
+		return h.RemoveQuad(q)
+	}},
+}
+
+// Options configures a benchmark.
+type Options struct {
+	// Duration of each query in the mix. Defaults to DefaultDuration.
+	Duration time.Duration
+	// Concurrency is the number of workers that run read queries.
+	Concurrency int
+	// Seed of the random sequence of edges.
+	Seed int64
+}
+
+// Result is a latency distribution of a single query.
+type Result struct {
+	Name    string
+	Ops     int
+	Errors  int
+	Elapsed time.Duration
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// Throughput returns the number of operations per second.
+func (r Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Elapsed.Seconds()
+}
+
+// Run runs each query of the mix for the duration, in order, and returns their results.
+func Run(ctx context.Context, h *graph.Handle, w *Workload, mix []Query, opts Options) ([]Result, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = DefaultDuration
+	}
+	out := make([]Result, 0, len(mix))
+	for _, q := range mix {
+		r, err := run(ctx, h, w, q, opts)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func run(ctx context.Context, h *graph.Handle, w *Workload, q Query, opts Options) (Result, error) {
+	workers := opts.Concurrency
+	if q.Write {
+		workers = 1
+	}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		lat   []time.Duration
+		errs  int
+		first error
+		start = time.Now()
+	)
+	cctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(opts.Seed + int64(i)))
+			var local []time.Duration
+			for n := i; cctx.Err() == nil; n += workers {
+				e := w.Edges[rnd.Intn(len(w.Edges))]
+				t := time.Now()
+				// queries are not interrupted, so latencies are not cut by the deadline
+				err := q.Run(ctx, h, e, n)
+				d := time.Since(t)
+				if err != nil {
+					mu.Lock()
+					errs++
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+					continue
+				}
+				local = append(local, d)
+			}
+			mu.Lock()
+			lat = append(lat, local...)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	r := Result{Name: q.Name, Ops: len(lat), Errors: errs, Elapsed: time.Since(start)}
+	if err := ctx.Err(); err != nil {
+		return r, err
+	} else if len(lat) == 0 && first != nil {
+		return r, fmt.Errorf("bench: %s: %v", q.Name, first)
+	}
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	r.P50, r.P90, r.P99 = percentile(lat, 0.5), percentile(lat, 0.9), percentile(lat, 0.99)
+	if len(lat) != 0 {
+		r.Max = lat[len(lat)-1]
+	}
+	return r, nil
+}
+
+// percentile returns a given percentile of sorted latencies.
+func percentile(lat []time.Duration, p float64) time.Duration {
+	if len(lat) == 0 {
+		return 0
+	}
+	return lat[int(p*float64(len(lat)-1))]
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestGenerate(t *testing.T) {
+	quads := Generate(10, 3, 1)
+	require.Len(t, quads, 40)
+	require.Equal(t, quads, Generate(10, 3, 1))
+	// the degree is limited by the number of people
+	require.Len(t, Generate(3, 5, 1), 9)
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.TODO()
+	qs := memstore.New(Generate(100, 5, 1)...)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: qw}
+
+	w, err := Sample(ctx, qs, 50)
+	require.NoError(t, err)
+	require.NotEmpty(t, w.Edges)
+	require.True(t, len(w.Edges) <= 50)
+	for _, e := range w.Edges {
+		require.Equal(t, Follows, e.Predicate)
+	}
+
+	size := qs.Size()
+	res, err := Run(ctx, h, w, Mix, Options{Duration: 20 * time.Millisecond, Concurrency: 2, Seed: 1})
+	require.NoError(t, err)
+	require.Len(t, res, len(Mix))
+	for i, r := range res {
+		require.Equal(t, Mix[i].Name, r.Name)
+		require.Zero(t, r.Errors)
+		require.True(t, r.Ops > 0, r.Name)
+		require.True(t, r.P50 <= r.P90 && r.P90 <= r.P99 && r.P99 <= r.Max, r.Name)
+		require.True(t, r.Throughput() > 0, r.Name)
+	}
+	// writes are reverted
+	require.Equal(t, size, qs.Size())
+}
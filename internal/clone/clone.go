@@ -0,0 +1,128 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clone copies quads of a named graph, or of all graphs, to another graph or quad store.
+//
+// Quads are streamed from the source to the destination in batches, and their labels can be replaced
+// or remapped on the way. If the source supports snapshots, quads are read from a snapshot,
+// so a graph can be copied within the same quad store. Otherwise, all quads are read before the first write.
+package clone
+
+import (
+	"context"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Options configures a copy.
+type Options struct {
+	// Graph is a label of the copied graph. If it's nil, all quads are copied.
+	Graph quad.Value
+	// Label replaces labels of all copied quads, if it's set.
+	Label quad.Value
+	// Labels remap labels of copied quads, unless Label is set. Keys are labels in the N-Quads format,
+	// or an empty string for quads without a label. Quads with other labels keep them.
+	Labels map[string]quad.Value
+	// Batch is the number of quads in one write. Defaults to quad.DefaultBatch.
+	Batch int
+	// Pinned is set if the source is already a view of a snapshot, thus it's not changed by the copy.
+	Pinned bool
+	// Progress is called after each write with the number of copied quads.
+	Progress func(n int64)
+}
+
+// label returns a label of a copied quad.
+func (o *Options) label(l quad.Value) quad.Value {
+	if o.Label != nil {
+		return o.Label
+	} else if o.Labels == nil {
+		return l
+	}
+	key := ""
+	if l != nil {
+		key = l.String()
+	}
+	if v, ok := o.Labels[key]; ok {
+		return v
+	}
+	return l
+}
+
+// Copy writes quads of the source to the destination and returns the number of copied quads.
+// Quads that already exist in the destination fail the copy, unless the writer ignores duplicates.
+func Copy(ctx context.Context, dst graph.QuadWriter, src graph.QuadStore, opts Options) (int64, error) {
+	if opts.Batch <= 0 {
+		opts.Batch = quad.DefaultBatch
+	}
+	// without a snapshot, writes to the same quad store would change the iterated indexes
+	batch := -1
+	if opts.Pinned {
+		batch = opts.Batch
+	} else if s, ok := src.(graph.Snapshotter); ok {
+		snap, err := s.Snapshot()
+		if err != nil {
+			return 0, err
+		}
+		defer snap.Close()
+		src = snap
+		batch = opts.Batch
+	}
+	if opts.Graph != nil {
+		src = named.New(src, opts.Graph)
+	}
+	it := src.QuadsAllIterator()
+	defer it.Close()
+	var (
+		n   int64
+		buf []quad.Quad
+	)
+	flush := func() error {
+		for len(buf) > 0 {
+			b := buf
+			if len(b) > opts.Batch {
+				b = b[:opts.Batch]
+			}
+			if err := dst.AddQuadSet(b); err != nil {
+				return err
+			}
+			n += int64(len(b))
+			buf = buf[len(b):]
+			if opts.Progress != nil {
+				opts.Progress(n)
+			}
+		}
+		buf = nil
+		return nil
+	}
+	for it.Next(ctx) {
+		q := src.Quad(it.Result())
+		if !q.IsValid() {
+			continue
+		}
+		q.Label = opts.label(q.Label)
+		if buf = append(buf, q); len(buf) == batch {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return n, err
+	} else if err = ctx.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clone
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func allQuads(t testing.TB, qs graph.QuadStore) []string {
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	var out []string
+	for it.Next(context.TODO()) {
+		out = append(out, qs.Quad(it.Result()).NQuad())
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(out)
+	return out
+}
+
+func TestCopyGraph(t *testing.T) {
+	var quads []quad.Quad
+	for i := 0; i < 10; i++ {
+		quads = append(quads, quad.MakeIRI(fmt.Sprint("a", i), "knows", "b", "g1"))
+	}
+	quads = append(quads, quad.MakeIRI("c", "knows", "d", ""))
+	for _, c := range []struct {
+		name string
+		open func(t testing.TB) graph.QuadStore
+	}{
+		{"memstore", func(t testing.TB) graph.QuadStore {
+			return memstore.New(quads...)
+		}},
+		{"kv", func(t testing.TB) graph.QuadStore {
+			db := btree.New()
+			require.NoError(t, kv.Init(db, nil))
+			qs, err := kv.New(db, nil)
+			require.NoError(t, err)
+			qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+			require.NoError(t, err)
+			require.NoError(t, qw.AddQuadSet(quads))
+			return qs
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			qs := c.open(t)
+			qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+			require.NoError(t, err)
+			var progress []int64
+			n, err := Copy(context.TODO(), qw, qs, Options{
+				Graph: quad.IRI("g1"), Label: quad.IRI("g2"), Batch: 3,
+				Progress: func(n int64) { progress = append(progress, n) },
+			})
+			require.NoError(t, err)
+			require.Equal(t, int64(10), n)
+			require.Equal(t, []int64{3, 6, 9, 10}, progress)
+			got := allQuads(t, qs)
+			require.Len(t, got, 21)
+			require.Contains(t, got, "<a0> <knows> <b> <g2> .")
+		})
+	}
+}
+
+func TestCopyRemap(t *testing.T) {
+	src := memstore.New(
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g2"),
+		quad.MakeIRI("c", "knows", "d", ""),
+	)
+	dst := memstore.New()
+	qw, err := writer.NewSingle(dst, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	n, err := Copy(context.TODO(), qw, src, Options{Labels: map[string]quad.Value{
+		"<g1>": quad.IRI("t1"),
+		"":     quad.IRI("default"),
+	}})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+	require.Equal(t, []string{
+		"<a> <knows> <b> <t1> .",
+		"<b> <knows> <c> <g2> .",
+		"<c> <knows> <d> <default> .",
+	}, allQuads(t, dst))
+}
@@ -27,6 +27,11 @@ const (
 	b2zipMagic = "BZh"
 )
 
+// IsCompressed checks if the data starts with a magic number of one of the supported compression formats.
+func IsCompressed(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte(gzipMagic)) || bytes.HasPrefix(magic, []byte(b2zipMagic))
+}
+
 // New detects the file type of an io.Reader between
 // bzip, gzip, or raw quad file.
 func New(r io.Reader) (io.Reader, error) {
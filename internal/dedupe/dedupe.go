@@ -0,0 +1,173 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedupe finds and removes quads that are logically equal, but stored as distinct quads.
+//
+// Values are compared in a canonical form: IRIs are expanded with registered namespaces, language tags
+// are compared case-insensitively, strings typed as xsd:string are equal to plain strings, and typed strings
+// with known types are compared as native values (for example, "1"^^xsd:integer and "01"^^xsd:long are equal).
+// Rules can additionally normalize string literals to find near-duplicates.
+package dedupe
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const xsdString = quad.IRI("http://www.w3.org/2001/XMLSchema#string")
+
+// Rule normalizes the text of a string literal.
+type Rule func(string) string
+
+// Rules are the known normalization rules for literals.
+var Rules = map[string]Rule{
+	// space trims the text and collapses all whitespace to a single space
+	"space": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	// case compares text case-insensitively
+	"case": strings.ToLower,
+}
+
+// RuleNames returns rules for given names.
+func RuleNames(names []string) ([]Rule, error) {
+	out := make([]Rule, 0, len(names))
+	for _, name := range names {
+		r, ok := Rules[name]
+		if !ok {
+			return nil, fmt.Errorf("dedupe: unknown normalization rule: %q", name)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Canonical returns a canonical form of a value. Values with the same canonical form are logically equal.
+func Canonical(v quad.Value, rules []Rule) quad.Value {
+	text := func(s quad.String) quad.String {
+		for _, r := range rules {
+			s = quad.String(r(string(s)))
+		}
+		return s
+	}
+	switch v := v.(type) {
+	case quad.IRI:
+		return v.Full()
+	case quad.String:
+		return text(v)
+	case quad.LangString:
+		return quad.LangString{Value: text(v.Value), Lang: strings.ToLower(v.Lang)}
+	case quad.TypedString:
+		v.Type = v.Type.Full()
+		if v.Type == xsdString {
+			return text(v.Value)
+		}
+		if nv, err := v.ParseValue(); err == nil && nv != quad.Value(v) {
+			return Canonical(nv, rules)
+		}
+		return quad.TypedString{Value: text(v.Value), Type: v.Type}
+	case quad.Time:
+		return quad.Time(time.Time(v).UTC())
+	}
+	return v
+}
+
+// Options configures a deduplication.
+type Options struct {
+	// Rules are applied to string literals before comparing them.
+	Rules []Rule
+	// DryRun only reports duplicates, without removing them.
+	DryRun bool
+	// Batch is the number of quads removed in one transaction. Defaults to quad.DefaultBatch.
+	Batch int
+	// Duplicate is called for each duplicate with a quad that is kept.
+	Duplicate func(keep, dup quad.Quad)
+}
+
+// Stats describes a completed deduplication.
+type Stats struct {
+	// Quads is the number of scanned quads.
+	Quads int64
+	// Duplicates is the number of found duplicates.
+	Duplicates int64
+	// Removed is the number of removed duplicates.
+	Removed int64
+}
+
+type key [sha1.Size]byte
+
+// Dedupe scans all quads and removes all but the first quad of each set of logically equal quads.
+//
+// A hash of each distinct quad is kept in memory during the scan.
+func Dedupe(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, opts Options) (*Stats, error) {
+	if opts.Batch <= 0 {
+		opts.Batch = quad.DefaultBatch
+	}
+	st := &Stats{}
+	seen := make(map[key]graph.Value)
+	var dups []quad.Quad
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	h := sha1.New()
+	for it.Next(ctx) {
+		st.Quads++
+		q := qs.Quad(it.Result())
+		h.Reset()
+		for _, d := range quad.Directions {
+			if v := q.Get(d); v != nil {
+				fmt.Fprint(h, Canonical(v, opts.Rules))
+			}
+			h.Write([]byte{0})
+		}
+		var k key
+		h.Sum(k[:0])
+		first, ok := seen[k]
+		if !ok {
+			seen[k] = it.Result()
+			continue
+		}
+		st.Duplicates++
+		if opts.Duplicate != nil {
+			opts.Duplicate(qs.Quad(first), q)
+		}
+		if !opts.DryRun {
+			dups = append(dups, q)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return st, err
+	}
+	it.Close()
+	// quads are removed after the scan, since not all backends allow to write during iteration
+	for len(dups) > 0 {
+		batch := dups
+		if len(batch) > opts.Batch {
+			batch = batch[:opts.Batch]
+		}
+		tx := graph.NewTransactionN(len(batch))
+		for _, q := range batch {
+			tx.RemoveQuad(q)
+		}
+		if err := qw.ApplyTransaction(tx); err != nil {
+			return st, err
+		}
+		st.Removed += int64(len(batch))
+		dups = dups[len(batch):]
+	}
+	return st, nil
+}
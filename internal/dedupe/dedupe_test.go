@@ -0,0 +1,73 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedupe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const xsdInteger = quad.IRI("http://www.w3.org/2001/XMLSchema#integer")
+
+func TestDedupe(t *testing.T) {
+	ctx := context.TODO()
+	unique := []quad.Quad{
+		quad.MakeIRI("http://schema.org/a", "p", "b", ""),
+		quad.Make(quad.IRI("a"), quad.IRI("age"), quad.Int(1), nil),
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Bob  Smith"), nil),
+		quad.Make(quad.IRI("a"), quad.IRI("label"), quad.LangString{Value: "x", Lang: "en"}, nil),
+	}
+	dups := []quad.Quad{
+		quad.MakeIRI("schema:a", "p", "b", ""),
+		quad.Make(quad.IRI("a"), quad.IRI("age"), quad.TypedString{Value: "01", Type: xsdInteger}, nil),
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.TypedString{Value: "Bob  Smith", Type: xsdString}, nil),
+		quad.Make(quad.IRI("a"), quad.IRI("label"), quad.LangString{Value: "x", Lang: "EN"}, nil),
+	}
+	near := []quad.Quad{
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String(" bob smith"), nil),
+	}
+	var all []quad.Quad
+	all = append(all, unique...)
+	all = append(all, dups...)
+	all = append(all, near...)
+	qs := memstore.New(all...)
+	w, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+
+	var found []quad.Quad
+	st, err := Dedupe(ctx, qs, w, Options{DryRun: true, Duplicate: func(_, dup quad.Quad) {
+		found = append(found, dup)
+	}})
+	require.NoError(t, err)
+	require.Equal(t, &Stats{Quads: 9, Duplicates: 4}, st)
+	require.ElementsMatch(t, dups, found)
+
+	rules, err := RuleNames([]string{"space", "case"})
+	require.NoError(t, err)
+	st, err = Dedupe(ctx, qs, w, Options{Rules: rules, Batch: 2})
+	require.NoError(t, err)
+	require.Equal(t, &Stats{Quads: 9, Duplicates: 5, Removed: 5}, st)
+	require.Equal(t, 4, len(graphtest.IteratedQuads(t, qs, qs.QuadsAllIterator())))
+
+	_, err = RuleNames([]string{"nope"})
+	require.Error(t, err)
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff computes differences between two sets of quads.
+//
+// Sets are compared by hashes of quads, so only the hashes are kept in memory,
+// and each set is read more than once.
+package diff
+
+import (
+	"crypto/sha1"
+	"io"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Source is a set of quads that can be read multiple times.
+type Source interface {
+	Open() (quad.ReadCloser, error)
+}
+
+type fileSource struct {
+	path, typ string
+}
+
+func (s fileSource) Open() (quad.ReadCloser, error) {
+	return internal.QuadReaderFor(s.path, s.typ)
+}
+
+// File returns a source that reads quads from a file in a given format.
+// Format is detected from the file extension if it's not set.
+func File(path, typ string) Source {
+	return fileSource{path: path, typ: typ}
+}
+
+type storeSource struct {
+	qs graph.QuadStore
+}
+
+func (s storeSource) Open() (quad.ReadCloser, error) {
+	return graph.NewQuadStoreReader(s.qs), nil
+}
+
+// Store returns a source that reads all quads from the quad store.
+// The quad store should not be modified while the diff is computed.
+func Store(qs graph.QuadStore) Source {
+	return storeSource{qs: qs}
+}
+
+// Stats is a number of quads that differ between the sets.
+type Stats struct {
+	Added   int64
+	Removed int64
+}
+
+type key [sha1.Size]byte
+
+func keyOf(q quad.Quad) key {
+	return sha1.Sum([]byte(q.NQuad()))
+}
+
+// scan calls fn for each quad of the source.
+func scan(s Source, fn func(q quad.Quad, k key) error) error {
+	qr, err := s.Open()
+	if err != nil {
+		return err
+	}
+	defer qr.Close()
+	for {
+		q, err := qr.ReadQuad()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = fn(q, keyOf(q)); err != nil {
+			return err
+		}
+	}
+}
+
+// Diff calls fn with deltas that turn the set of quads in a into the set of quads in b.
+// All removals are reported before additions.
+func Diff(a, b Source, fn func(graph.Delta) error) (Stats, error) {
+	var st Stats
+	inB := make(map[key]struct{})
+	err := scan(b, func(_ quad.Quad, k key) error {
+		inB[k] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	inA := make(map[key]struct{}, len(inB))
+	err = scan(a, func(q quad.Quad, k key) error {
+		if _, ok := inA[k]; ok {
+			return nil // duplicate
+		}
+		inA[k] = struct{}{}
+		if _, ok := inB[k]; ok {
+			return nil
+		}
+		st.Removed++
+		return fn(graph.Delta{Quad: q, Action: graph.Delete})
+	})
+	if err != nil {
+		return st, err
+	}
+	inB = nil
+	err = scan(b, func(q quad.Quad, k key) error {
+		if _, ok := inA[k]; ok {
+			return nil
+		}
+		inA[k] = struct{}{} // skip duplicates
+		st.Added++
+		return fn(graph.Delta{Quad: q, Action: graph.Add})
+	})
+	return st, err
+}
+
+// SyncOptions configures Sync.
+type SyncOptions struct {
+	// NoDelete only adds missing quads to the destination.
+	NoDelete bool
+	// DryRun computes the difference without changing the destination.
+	DryRun bool
+}
+
+// Sync applies the difference between the destination quad store and the source,
+// so the destination contains the same quads as the source.
+//
+// The difference is kept in memory and is applied after it's computed, in batches.
+func Sync(dst graph.QuadStore, src Source, opts SyncOptions) (Stats, error) {
+	var deltas []graph.Delta
+	st, err := Diff(Store(dst), src, func(d graph.Delta) error {
+		if d.Action == graph.Delete && opts.NoDelete {
+			return nil
+		}
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	if opts.NoDelete {
+		st.Removed = 0
+	}
+	if opts.DryRun {
+		return st, nil
+	}
+	for len(deltas) > 0 {
+		n := quad.DefaultBatch
+		if n > len(deltas) {
+			n = len(deltas)
+		}
+		if err = dst.ApplyDeltas(deltas[:n], graph.IgnoreOpts{}); err != nil {
+			return st, err
+		}
+		deltas = deltas[n:]
+	}
+	return st, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var (
+	q1 = quad.Make("a", "follows", "b", nil)
+	q2 = quad.Make("b", "follows", "c", "g")
+	q3 = quad.Make("c", "age", quad.Int(3), nil)
+)
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-diff-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "b.nq")
+	// duplicates are reported once
+	data := q2.NQuad() + "\n" + q3.NQuad() + "\n" + q3.NQuad() + "\n"
+	require.NoError(t, ioutil.WriteFile(file, []byte(data), 0644))
+
+	var got []graph.Delta
+	st, err := Diff(Store(memstore.New(q1, q2)), File(file, ""), func(d graph.Delta) error {
+		got = append(got, d)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, Stats{Added: 1, Removed: 1}, st)
+	require.Equal(t, []graph.Delta{
+		{Quad: q1, Action: graph.Delete},
+		{Quad: q3, Action: graph.Add},
+	}, got)
+
+	_, err = Diff(Store(memstore.New()), File(filepath.Join(dir, "missing.nq"), ""), func(graph.Delta) error { return nil })
+	require.Error(t, err)
+}
+
+func TestSync(t *testing.T) {
+	src := Store(memstore.New(q2, q3))
+
+	dst := memstore.New(q1, q2)
+	st, err := Sync(dst, src, SyncOptions{DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, Stats{Added: 1, Removed: 1}, st)
+	graphtest.ExpectIteratedQuads(t, dst, dst.QuadsAllIterator(), []quad.Quad{q1, q2}, true)
+
+	st, err = Sync(dst, src, SyncOptions{NoDelete: true})
+	require.NoError(t, err)
+	require.Equal(t, Stats{Added: 1}, st)
+	graphtest.ExpectIteratedQuads(t, dst, dst.QuadsAllIterator(), []quad.Quad{q1, q2, q3}, true)
+
+	st, err = Sync(dst, src, SyncOptions{})
+	require.NoError(t, err)
+	require.Equal(t, Stats{Removed: 1}, st)
+	graphtest.ExpectIteratedQuads(t, dst, dst.QuadsAllIterator(), []quad.Quad{q2, q3}, true)
+}
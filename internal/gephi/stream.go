@@ -46,6 +46,9 @@ var defaultInline = []quad.IRI{
 
 type GraphStreamHandler struct {
 	QS graph.QuadStore
+	// Store returns a quad store for a given request, for example one restricted to graphs accessible by the client.
+	// If it returns false, the response was already written. If not set, QS is used for all requests.
+	Store func(w http.ResponseWriter, r *http.Request) (graph.QuadStore, func(), bool)
 }
 
 type valHash [quad.HashSize]byte
@@ -362,6 +365,14 @@ func valuesFromString(s string) []quad.Value {
 }
 
 func (s *GraphStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if s.Store != nil {
+		qs, done, ok := s.Store(w, r)
+		if !ok {
+			return
+		}
+		defer done()
+		s = &GraphStreamHandler{QS: qs}
+	}
 	ctx := context.TODO()
 	var limit int
 	if s := r.FormValue("limit"); s != "" {
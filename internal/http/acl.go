@@ -0,0 +1,43 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/graph/trash"
+	cayleyhttp "github.com/cayleygraph/cayley/server/http"
+)
+
+// scope returns graphs accessible by the client, or nil if access is not restricted.
+func (api *API) scope(r *http.Request) *acl.Scope {
+	if api.config.ACL == nil {
+		return nil
+	}
+	return api.config.ACL.Scope(cayleyhttp.RequestPrincipal(r))
+}
+
+// queryStore restricts a quad store to graphs accessible by the client and hides deleted graphs.
+// V1 queries cannot select a graph, thus they always use the default one.
+func (api *API) queryStore(r *http.Request, qs graph.QuadStore) (graph.QuadStore, error) {
+	qs, err := trash.Hide(r.Context(), acl.Restrict(qs, api.scope(r)))
+	if err != nil {
+		return nil, err
+	}
+	return named.Select(qs, "", api.config.DefaultGraph), nil
+}
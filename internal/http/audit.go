@@ -0,0 +1,116 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/audit"
+	"github.com/cayleygraph/cayley/graph/trash"
+	"github.com/cayleygraph/cayley/quad"
+	cayleyhttp "github.com/cayleygraph/cayley/server/http"
+)
+
+const (
+	auditHistoryPath = "/api/v2/admin/audit/history"
+
+	// defaultHistoryLimit is the number of the most recent changes returned by the history endpoint by default.
+	defaultHistoryLimit = 100
+)
+
+// writeHandleForRequest is like GetHandleForRequest, but only writes graphs accessible by the client,
+// within quotas, and records all writes to the audit log, if it's enabled. Quads can't be added to deleted graphs.
+func (api *API) writeHandleForRequest(w http.ResponseWriter, r *http.Request) (*graph.Handle, error) {
+	h, err := api.GetHandleForRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	qs := h.QuadStore
+	if api.config.Audit != nil {
+		qs = audit.New(qs, api.config.Audit, cayleyhttp.AuditInfo(w, r, api.config.AdminToken))
+	}
+	if qs, err = trash.Hide(r.Context(), api.config.Quota.Wrap(qs)); err != nil {
+		return nil, err
+	}
+	if qs = acl.Restrict(qs, api.scope(r)); qs == h.QuadStore {
+		return h, nil
+	}
+	qw, err := graph.NewQuadWriter("single", qs, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &graph.Handle{QuadStore: h.QuadStore, QuadWriter: qw}, nil
+}
+
+func timeParam(r *http.Request, name string) (time.Time, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
+
+// ServeAuditHistory returns changes of a given node recorded to the audit log: who made them and when.
+func (api *API) ServeAuditHistory(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	node := quad.StringToValue(r.FormValue("node"))
+	if node == nil {
+		jsonResponse(w, http.StatusBadRequest, "node is not set")
+		return
+	}
+	since, err := timeParam(r, "since")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	until, err := timeParam(r, "until")
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := defaultHistoryLimit
+	if v := r.FormValue("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			jsonResponse(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+	out := make([]audit.Entry, 0)
+	err = api.config.Audit.History(node, since, until, func(e audit.Entry) error {
+		// keep only the most recent entries
+		if len(out) == 2*limit {
+			out = append(out[:0], out[limit:]...)
+		}
+		out = append(out, e)
+		return nil
+	})
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Result []audit.Entry `json:"result"`
+	}{out})
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	defaultCORSMethods = []string{"POST", "GET", "OPTIONS", "PUT", "DELETE"}
+	defaultCORSHeaders = []string{
+		"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization",
+		DatabaseHeader,
+	}
+)
+
+// CORSConfig is a policy for cross-origin requests.
+//
+// Zero value allows requests from any origin.
+type CORSConfig struct {
+	// Disabled turns off CORS headers completely.
+	Disabled bool
+	// AllowedOrigins is a list of origins that are allowed to make cross-origin requests.
+	// Origin may contain a single "*" wildcard, for example "https://*.example.com".
+	// Empty list or "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods overrides the list of methods allowed for cross-origin requests.
+	AllowedMethods []string
+	// AllowedHeaders overrides the list of headers allowed for cross-origin requests.
+	AllowedHeaders []string
+	// ExposedHeaders is a list of response headers that browsers are allowed to access.
+	ExposedHeaders []string
+	// AllowCredentials allows to send cookies and auth headers with cross-origin requests.
+	// It requires an explicit list of allowed origins.
+	AllowCredentials bool
+	// MaxAge sets how long the results of a preflight request can be cached.
+	MaxAge time.Duration
+}
+
+type corsPolicy struct {
+	disabled    bool
+	any         bool
+	origins     []string
+	methods     string
+	headers     string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+func newCORSPolicy(c CORSConfig) (*corsPolicy, error) {
+	p := &corsPolicy{
+		disabled:    c.Disabled,
+		any:         len(c.AllowedOrigins) == 0,
+		methods:     strings.Join(defaultCORSMethods, ", "),
+		headers:     strings.Join(defaultCORSHeaders, ", "),
+		expose:      strings.Join(c.ExposedHeaders, ", "),
+		credentials: c.AllowCredentials,
+	}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			p.any = true
+			continue
+		}
+		p.origins = append(p.origins, strings.ToLower(strings.TrimSuffix(o, "/")))
+	}
+	if len(c.AllowedMethods) != 0 {
+		p.methods = strings.Join(c.AllowedMethods, ", ")
+	}
+	if len(c.AllowedHeaders) != 0 {
+		p.headers = strings.Join(c.AllowedHeaders, ", ")
+	}
+	if c.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(c.MaxAge / time.Second))
+	}
+	if p.credentials && p.any && !p.disabled {
+		// any site would be able to make requests on behalf of the user
+		return nil, errors.New("cors: credentials require an explicit list of allowed origins")
+	}
+	return p, nil
+}
+
+var defaultCORS, _ = newCORSPolicy(CORSConfig{})
+
+func matchOrigin(pattern, origin string) bool {
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return pattern == origin
+	}
+	pref, suff := pattern[:i], pattern[i+1:]
+	return len(origin) >= len(pref)+len(suff) &&
+		strings.HasPrefix(origin, pref) && strings.HasSuffix(origin, suff)
+}
+
+func (p *corsPolicy) allowed(origin string) bool {
+	if p.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, o := range p.origins {
+		if matchOrigin(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) setHeaders(w http.ResponseWriter, req *http.Request) {
+	if p.disabled {
+		return
+	}
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	h := w.Header()
+	if !p.any {
+		h.Add("Vary", "Origin")
+	}
+	if !p.allowed(origin) {
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Set("Access-Control-Allow-Methods", p.methods)
+	h.Set("Access-Control-Allow-Headers", p.headers)
+	if p.expose != "" {
+		h.Set("Access-Control-Expose-Headers", p.expose)
+	}
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if p.maxAge != "" && req.Method == "OPTIONS" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+}
+
+// Preflight handles CORS preflight requests.
+func (p *corsPolicy) Preflight(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	p.setHeaders(w, req)
+}
+
+// Wrap adds CORS headers to responses of a given handler.
+func (p *corsPolicy) Wrap(h httprouter.Handle) httprouter.Handle {
+	if p.disabled {
+		return h
+	}
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		p.setHeaders(w, req)
+		h(w, req, params)
+	}
+}
+
+// CORSFunc sets CORS headers allowing requests from any origin.
+func CORSFunc(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	defaultCORS.Preflight(w, req, params)
+}
+
+// CORS wraps a handler to allow requests from any origin.
+func CORS(h httprouter.Handle) httprouter.Handle {
+	return defaultCORS.Wrap(h)
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/quota"
+	"github.com/cayleygraph/cayley/internal/system"
+)
+
+// DatabaseHeader selects one of the additional databases by its name, as an alternative to the /db/<name> path prefix.
+const DatabaseHeader = "X-Cayley-Database"
+
+// databasesPath is a path prefix of additional databases; it's followed by the name of a database.
+const databasesPath = "/db/"
+
+var validDatabaseName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Database is a database served next to the main one, with its own API under /db/<name>.
+type Database struct {
+	Name    string
+	Handle  *graph.Handle
+	Backend string
+	// ReadOnly rejects writes to this database, even if the main one accepts them.
+	ReadOnly bool
+	// Quota limits this database and its graphs. Quotas of the main database are not applied to it.
+	Quota *quota.Limits
+}
+
+// setupDatabases registers routes of additional databases. They share limits, CORS policy, admin token
+// and running queries with the main database, and have separate query statistics and system graphs.
+// Audit, replication, views and debug endpoints are only served for the main database, and only graphs
+// deleted from it are purged automatically.
+func setupDatabases(base string, cfg *Config) (map[string]*Server, error) {
+	dbs := make(map[string]*Server, len(cfg.Databases))
+	for _, db := range cfg.Databases {
+		dcfg := *cfg
+		dcfg.BasePath = base + databasesPath + db.Name
+		dcfg.ReadOnly = cfg.ReadOnly || db.ReadOnly
+		dcfg.Databases = nil
+		dcfg.Audit, dcfg.Feed, dcfg.Views = nil, nil, nil
+		dcfg.TrashRetention = 0
+		dcfg.Reload, dcfg.Debug = nil, false
+		dcfg.Quota = db.Quota
+		dcfg.SystemSources = []system.Source{system.NewStore(db.Handle.QuadStore, db.Backend)}
+		if cfg.QueryStats != nil {
+			dcfg.QueryStats = system.NewQueryStats(0)
+		}
+		srv, err := SetupRoutes(db.Handle, &dcfg)
+		if err != nil {
+			return nil, err
+		}
+		srv.ro = db.ReadOnly
+		dbs[db.Name] = srv
+	}
+	return dbs, nil
+}
+
+// checkDatabases validates names of additional databases.
+func checkDatabases(dbs []Database) error {
+	seen := make(map[string]struct{}, len(dbs))
+	for _, db := range dbs {
+		if !validDatabaseName.MatchString(db.Name) {
+			return fmt.Errorf("invalid database name: %q", db.Name)
+		} else if _, ok := seen[db.Name]; ok {
+			return fmt.Errorf("duplicate database name: %q", db.Name)
+		}
+		seen[db.Name] = struct{}{}
+	}
+	return nil
+}
+
+// selectDatabase passes requests with DatabaseHeader to the router of the selected database.
+func (s *Server) selectDatabase(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get(DatabaseHeader)
+		if name == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		db, ok := s.dbs[name]
+		if !ok {
+			jsonResponse(w, http.StatusNotFound, fmt.Sprintf("unknown database: %q", name))
+			return
+		}
+		db.router.ServeHTTP(w, r)
+	})
+}
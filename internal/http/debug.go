@@ -0,0 +1,216 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	cayleyhttp "github.com/cayleygraph/cayley/server/http"
+)
+
+// Debug handlers are implemented with runtime/pprof directly: net/http/pprof registers
+// its handlers on the default mux that serves the API, where they can't be protected.
+const (
+	debugPprofPath      = "/debug/pprof/*name"
+	debugGoroutinesPath = "/debug/goroutines"
+	debugGCPath         = "/debug/gc"
+
+	// maxProfileDuration limits CPU profiles and traces requested with the seconds parameter.
+	maxProfileDuration = 5 * time.Minute
+	// maxPauses is the number of recent GC pauses returned by the GC endpoint.
+	maxPauses = 16
+)
+
+// AdminOnly allows requests with the admin token in the Authorization header ("Bearer <token>").
+// All requests are allowed if the token is not set.
+func (api *API) AdminOnly(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if tok := api.config.AdminToken; tok != "" {
+			if !cayleyhttp.HasBearerToken(req, tok) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cayley"`)
+				jsonResponse(w, http.StatusUnauthorized, "admin token is required")
+				return
+			}
+		}
+		handler(w, req, params)
+	}
+}
+
+func (api *API) registerDebug(r *httprouter.Router) {
+	wrap := func(h httprouter.Handle) httprouter.Handle {
+		return api.AdminOnly(LogRequest(h))
+	}
+	r.GET(debugPprofPath, wrap(ServeDebugPprof))
+	r.GET(debugGoroutinesPath, wrap(ServeDebugGoroutines))
+	r.GET(debugGCPath, wrap(ServeDebugGC))
+	r.POST(debugGCPath, wrap(ServeDebugGC))
+}
+
+func durationParam(r *http.Request, def time.Duration) (time.Duration, error) {
+	v := r.FormValue("seconds")
+	if v == "" {
+		return def, nil
+	}
+	sec, err := strconv.ParseFloat(v, 64)
+	if err != nil || sec <= 0 {
+		return 0, fmt.Errorf("invalid duration: %q", v)
+	}
+	dt := time.Duration(sec * float64(time.Second))
+	if dt > maxProfileDuration {
+		return 0, fmt.Errorf("duration is longer than %v", maxProfileDuration)
+	}
+	return dt, nil
+}
+
+// ServeDebugPprof serves profiles in the format of net/http/pprof, so they can be read by go tool pprof.
+func ServeDebugPprof(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := strings.Trim(params.ByName("name"), "/")
+	switch name {
+	case "":
+		serveProfileIndex(w)
+	case "profile", "trace":
+		def := 30 * time.Second
+		if name == "trace" {
+			def = time.Second
+		}
+		dt, err := durationParam(r, def)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		if name == "profile" {
+			err = pprof.StartCPUProfile(w)
+		} else {
+			err = trace.Start(w)
+		}
+		if err != nil {
+			w.Header().Del("Content-Disposition")
+			jsonResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+		select {
+		case <-time.After(dt):
+		case <-r.Context().Done():
+		}
+		if name == "profile" {
+			pprof.StopCPUProfile()
+		} else {
+			trace.Stop()
+		}
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			jsonResponse(w, http.StatusNotFound, fmt.Sprintf("unknown profile: %q", name))
+			return
+		}
+		dbg, _ := strconv.Atoi(r.FormValue("debug"))
+		if r.FormValue("gc") != "" && name == "heap" {
+			runtime.GC()
+		}
+		if dbg != 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		}
+		p.WriteTo(w, dbg)
+	}
+}
+
+func serveProfileIndex(w http.ResponseWriter) {
+	profiles := pprof.Profiles()
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name() < profiles[j].Name() })
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, p := range profiles {
+		fmt.Fprintf(w, "%d\t%s\n", p.Count(), p.Name())
+	}
+	fmt.Fprint(w, "\tprofile\n\ttrace\n")
+}
+
+// ServeDebugGoroutines writes stack traces of all goroutines.
+func ServeDebugGoroutines(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// GCStats is a summary of the memory usage and garbage collections.
+type GCStats struct {
+	Goroutines int `json:"goroutines"`
+
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapSys     uint64 `json:"heap_sys"`
+	HeapObjects uint64 `json:"heap_objects"`
+	HeapIdle    uint64 `json:"heap_idle"`
+	HeapRelease uint64 `json:"heap_released"`
+	Sys         uint64 `json:"sys"`
+	NextGC      uint64 `json:"next_gc"`
+
+	NumGC      int64     `json:"num_gc"`
+	LastGC     time.Time `json:"last_gc"`
+	PauseTotal string    `json:"pause_total"`
+	// Pauses are durations of the recent collections, the last one first.
+	Pauses []string `json:"pauses"`
+}
+
+// ServeDebugGC returns memory and GC statistics. A POST request runs a collection first,
+// and also returns as much memory to the OS as possible if the "free" parameter is set.
+func ServeDebugGC(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if r.Method == "POST" {
+		if r.FormValue("free") != "" {
+			debug.FreeOSMemory()
+		} else {
+			runtime.GC()
+		}
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	var gs debug.GCStats
+	debug.ReadGCStats(&gs)
+	if len(gs.Pause) > maxPauses {
+		gs.Pause = gs.Pause[:maxPauses]
+	}
+	st := GCStats{
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   ms.HeapAlloc,
+		HeapSys:     ms.HeapSys,
+		HeapObjects: ms.HeapObjects,
+		HeapIdle:    ms.HeapIdle,
+		HeapRelease: ms.HeapReleased,
+		Sys:         ms.Sys,
+		NextGC:      ms.NextGC,
+		NumGC:       gs.NumGC,
+		LastGC:      gs.LastGC,
+		PauseTotal:  gs.PauseTotal.String(),
+	}
+	for _, p := range gs.Pause {
+		st.Pauses = append(st.Pauses, p.String())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
@@ -27,9 +27,14 @@ import (
 
 type DocRequestHandler struct {
 	assets string
+	css    string
 }
 
 func MarkdownWithCSS(input []byte, title string) []byte {
+	return markdownWithCSS(input, title, markdownCSS)
+}
+
+func markdownWithCSS(input []byte, title, css string) []byte {
 	// set up the HTML renderer
 	htmlFlags := 0
 	htmlFlags |= blackfriday.HTML_USE_XHTML
@@ -37,7 +42,7 @@ func MarkdownWithCSS(input []byte, title string) []byte {
 	htmlFlags |= blackfriday.HTML_SMARTYPANTS_FRACTIONS
 	htmlFlags |= blackfriday.HTML_SMARTYPANTS_LATEX_DASHES
 	htmlFlags |= blackfriday.HTML_COMPLETE_PAGE
-	renderer := blackfriday.HtmlRenderer(htmlFlags, title, markdownCSS)
+	renderer := blackfriday.HtmlRenderer(htmlFlags, title, css)
 
 	// set up the parser
 	extensions := 0
@@ -71,7 +76,11 @@ func (h *DocRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, pa
 		http.Error(w, err.Error(), http.StatusNoContent)
 		return
 	}
-	output := MarkdownWithCSS(data, fmt.Sprintf("Cayley Docs - %s", docpage))
+	css := h.css
+	if css == "" {
+		css = markdownCSS
+	}
+	output := markdownWithCSS(data, fmt.Sprintf("Cayley Docs - %s", docpage), css)
 	fmt.Fprint(w, string(output))
 }
 
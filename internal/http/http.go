@@ -16,21 +16,36 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/audit"
+	"github.com/cayleygraph/cayley/graph/quota"
+	"github.com/cayleygraph/cayley/graph/replication"
 	"github.com/cayleygraph/cayley/internal/gephi"
+	"github.com/cayleygraph/cayley/internal/memory"
+	"github.com/cayleygraph/cayley/internal/system"
+	"github.com/cayleygraph/cayley/internal/view"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
 	"github.com/cayleygraph/cayley/server/http"
 )
 
+// logger is used for messages of the HTTP server.
+var logger = clog.Module("http")
+
 var AssetsPath string
 var defaultAssetPaths = []string{
 	".", "..", "./assets",
@@ -83,23 +98,26 @@ type statusWriter struct {
 
 func (w *statusWriter) WriteHeader(code int) {
 	*(w.code) = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
 func LogRequest(handler httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 		start := time.Now()
-		addr := req.Header.Get("X-Real-IP")
-		if addr == "" {
-			addr = req.Header.Get("X-Forwarded-For")
-			if addr == "" {
-				addr = req.RemoteAddr
-			}
-		}
+		// proxy headers are resolved by trustedProxies handler
+		addr := req.RemoteAddr
 		code := 200
 		rw := &statusWriter{ResponseWriter: w, code: &code}
-		clog.Infof("started %s %s for %s", req.Method, req.URL.Path, addr)
+		l := logger.With(clog.F("method", req.Method), clog.F("path", req.URL.Path), clog.F("remote", addr))
+		l.Info("started")
 		handler(rw, req, params)
-		clog.Infof("completed %v %s %s in %v", code, http.StatusText(code), req.URL.Path, time.Since(start))
+		l.Info("completed", clog.F("status", code), clog.F("duration", time.Since(start)))
 	}
 }
 
@@ -114,6 +132,7 @@ func jsonResponse(w http.ResponseWriter, code int, err interface{}) {
 
 type TemplateRequestHandler struct {
 	templates *template.Template
+	BasePath  string
 }
 
 func (h *TemplateRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
@@ -130,6 +149,17 @@ func (h *TemplateRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 type API struct {
 	config *Config
 	handle *graph.Handle
+	cors   *corsPolicy
+
+	mu       sync.RWMutex
+	settings Settings
+}
+
+// current returns the settings for the following request.
+func (api *API) current() Settings {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.settings
 }
 
 func (api *API) GetHandleForRequest(r *http.Request) (*graph.Handle, error) {
@@ -137,75 +167,253 @@ func (api *API) GetHandleForRequest(r *http.Request) (*graph.Handle, error) {
 }
 
 func (api *API) RWOnly(handler httprouter.Handle) httprouter.Handle {
-	if api.config.ReadOnly {
-		return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if api.current().ReadOnly {
 			jsonResponse(w, http.StatusForbidden, "Database is read-only.")
+			return
 		}
-	}
-	return handler
-}
-
-func CORSFunc(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
-	if origin := req.Header.Get("Origin"); origin != "" {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers",
-			"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-	}
-}
-
-func CORS(h httprouter.Handle) httprouter.Handle {
-	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
-		CORSFunc(w, req, params)
-		h(w, req, params)
+		handler(w, req, params)
 	}
 }
 
 func (api *API) APIv1(r *httprouter.Router) {
-	r.POST("/api/v1/query/:query_lang", CORS(LogRequest(api.ServeV1Query)))
-	r.POST("/api/v1/shape/:query_lang", CORS(LogRequest(api.ServeV1Shape)))
-	r.POST("/api/v1/write", CORS(api.RWOnly(LogRequest(api.ServeV1Write))))
-	r.POST("/api/v1/write/file/nquad", CORS(api.RWOnly(LogRequest(api.ServeV1WriteNQuad))))
-	r.POST("/api/v1/delete", CORS(api.RWOnly(LogRequest(api.ServeV1Delete))))
+	cors := api.cors.Wrap
+	r.POST("/api/v1/query/:query_lang", cors(LogRequest(api.ServeV1Query)))
+	r.POST("/api/v1/shape/:query_lang", cors(LogRequest(api.ServeV1Shape)))
+	r.POST("/api/v1/write", cors(api.RWOnly(LogRequest(api.ServeV1Write))))
+	r.POST("/api/v1/write/file/nquad", cors(api.RWOnly(LogRequest(api.ServeV1WriteNQuad))))
+	r.POST("/api/v1/delete", cors(api.RWOnly(LogRequest(api.ServeV1Delete))))
 }
 
 type Config struct {
 	ReadOnly bool
 	Timeout  time.Duration
 	Batch    int
+	// TxTimeout is the time after which an idle multi-request transaction is rolled back.
+	TxTimeout time.Duration
+	// WriteIDTTL is the time the server remembers client-provided ids of applied writes.
+	WriteIDTTL time.Duration
+	// SessionWait is the maximal time a read waits for the database to catch up with a session token.
+	SessionWait time.Duration
+	// QuerySessionTimeout is the time after which an idle query session is removed.
+	QuerySessionTimeout time.Duration
+	// Audit is a log that records all changes made through the API.
+	Audit *audit.Log
+	// Feed allows replicas to follow changes made on this server.
+	Feed *replication.Feed
+	// QueryStats records statistics of queries. If it's set, they can be queried in the system graph.
+	QueryStats *system.QueryStats
+	// SystemSources are added to the system graph, along with query statistics and configured limits.
+	SystemSources []system.Source
+	// Active tracks running queries. If it's set, they can be listed and cancelled through admin endpoints.
+	Active *query.Active
+	// MemoryBudget is the memory shared by all queries. Queries are rejected while it's exhausted.
+	MemoryBudget *memory.Budget
+	// QueryMemory is the memory limit of a single query, in bytes.
+	QueryMemory int64
+	// QueryParallelism is the number of independent branches a single query can run concurrently.
+	// Branches run one by one if it's less than 2.
+	QueryParallelism int
+	// DefaultGraph is a named graph used by queries that don't select one. If it's nil, queries use all graphs.
+	DefaultGraph quad.Value
+	// ACL restricts graphs each principal can read and write. If it's nil, access is not restricted.
+	ACL *acl.ACL
+	// Quota limits the size of graphs, the rate of writes and the number of concurrent queries. If it's nil, nothing is limited.
+	Quota *quota.Limits
+	// Views are virtual graphs that can be selected by queries with the graph parameter.
+	Views *view.Views
+	// TrashRetention is the time deleted graphs are kept before they are purged. It's zero if they are kept until purged manually.
+	TrashRetention time.Duration
+
+	// BasePath is a path prefix to mount all handlers to. Useful when running behind a reverse proxy.
+	BasePath string
+	// CORS is a policy for cross-origin requests.
+	CORS CORSConfig
+	// TrustedProxies is a list of IP addresses or CIDR ranges of reverse proxies
+	// that are allowed to set X-Forwarded-For and X-Real-IP headers.
+	TrustedProxies []string
+	// Reload is called on requests to the reload endpoint. The endpoint is registered only if it's set.
+	Reload func() error
+	// AdminToken is a bearer token required by admin endpoints: reload, running queries, audit history and debug.
+	// Running queries, audit history and debug endpoints are only served if it's set.
+	AdminToken string
+	// Debug enables profiling and runtime debug endpoints under /debug. It requires AdminToken.
+	Debug bool
+	// Databases are served next to the main database, under /db/<name> or with DatabaseHeader.
+	Databases []Database
+}
+
+// Settings is a part of the configuration that can be changed while the server is running.
+type Settings struct {
+	ReadOnly bool
+	Timeout  time.Duration
 }
 
-func SetupRoutes(handle *graph.Handle, cfg *Config) error {
+// Server is a set of handlers registered by SetupRoutes.
+type Server struct {
+	api    *API
+	api2   *cayleyhttp.APIv2
+	router http.Handler
+	// additional databases by their names
+	dbs map[string]*Server
+	// ro is set for additional databases that are read-only regardless of settings
+	ro bool
+}
+
+// Reload applies new settings to all following requests. Requests that are in progress are not affected.
+func (s *Server) Reload(st Settings) {
+	s.api.mu.Lock()
+	s.api.settings = st
+	s.api.mu.Unlock()
+	s.api2.SetReadOnly(st.ReadOnly)
+	s.api2.SetQueryTimeout(st.Timeout)
+	for _, db := range s.dbs {
+		db.Reload(Settings{ReadOnly: st.ReadOnly || db.ro, Timeout: st.Timeout})
+	}
+}
+
+const reloadPath = "/api/v2/admin/reload"
+
+func (api *API) ServeReload(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := api.config.Reload(); err != nil {
+		logger.Error("reload failed", clog.F("error", err))
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"result": "Configuration reloaded."}`)
+}
+
+func cleanBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+func SetupRoutes(handle *graph.Handle, cfg *Config) (*Server, error) {
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkDatabases(cfg.Databases); err != nil {
+		return nil, err
+	}
+	base := cleanBasePath(cfg.BasePath)
+
+	cors, err := newCORSPolicy(cfg.CORS)
+	if err != nil {
+		return nil, err
+	}
+
 	r := httprouter.New()
-	api := &API{config: cfg, handle: handle}
-	r.OPTIONS("/*path", CORSFunc)
+	api := &API{config: cfg, handle: handle, cors: cors}
+	api.settings = Settings{ReadOnly: cfg.ReadOnly, Timeout: cfg.Timeout}
+	r.OPTIONS("/*path", api.cors.Preflight)
 	api.APIv1(r)
+	if cfg.Reload != nil {
+		r.POST(reloadPath, api.cors.Wrap(api.AdminOnly(LogRequest(api.ServeReload))))
+	}
+	// running queries and the audit history expose queries and changes of other clients,
+	// thus they are not served without the token
+	if cfg.Active != nil && cfg.AdminToken != "" {
+		r.GET(queriesPath, api.cors.Wrap(api.AdminOnly(LogRequest(api.ServeQueries))))
+		r.DELETE(queriesPath+"/:id", api.cors.Wrap(api.AdminOnly(LogRequest(api.ServeCancelQuery))))
+	}
+	if cfg.Audit != nil && cfg.AdminToken != "" {
+		r.GET(auditHistoryPath, api.cors.Wrap(api.AdminOnly(LogRequest(api.ServeAuditHistory))))
+	}
+	if cfg.Debug {
+		if cfg.AdminToken == "" {
+			return nil, errors.New("debug endpoints require an admin token")
+		}
+		api.registerDebug(r)
+	}
 
 	api2 := cayleyhttp.NewAPIv2(handle)
 	api2.SetReadOnly(cfg.ReadOnly)
 	api2.SetBatchSize(cfg.Batch)
 	api2.SetQueryTimeout(cfg.Timeout)
-	api2.RegisterOn(r, CORS, LogRequest)
+	api2.SetTxTimeout(cfg.TxTimeout)
+	api2.SetWriteIDTTL(cfg.WriteIDTTL)
+	api2.SetSessionWait(cfg.SessionWait)
+	api2.SetQuerySessionTimeout(cfg.QuerySessionTimeout)
+	api2.SetAdminToken(cfg.AdminToken)
+	if cfg.Audit != nil {
+		api2.SetAuditLog(cfg.Audit)
+	}
+	if cfg.Feed != nil {
+		api2.SetReplicationFeed(cfg.Feed)
+	}
+	if cfg.Active != nil {
+		api2.SetActiveQueries(cfg.Active)
+	}
+	api2.SetQueryMemory(cfg.MemoryBudget, cfg.QueryMemory)
+	api2.SetQueryParallelism(cfg.QueryParallelism)
+	api2.SetDefaultGraph(cfg.DefaultGraph)
+	if cfg.ACL != nil {
+		api2.SetACL(cfg.ACL)
+	}
+	if cfg.Quota != nil {
+		api2.SetQuota(cfg.Quota)
+	}
+	if cfg.Views != nil {
+		api2.SetViews(cfg.Views)
+	}
+	api2.SetTrashRetention(cfg.TrashRetention)
+	sys := system.New(cfg.SystemSources...)
+	if cfg.QueryStats != nil {
+		api2.SetQueryStats(cfg.QueryStats)
+		sys.Register(cfg.QueryStats)
+	}
+	sys.Register(system.SourceFunc(func() []quad.Quad {
+		st := api.current()
+		return system.Limits{
+			ReadOnly:     st.ReadOnly,
+			QueryTimeout: st.Timeout,
+			TxTimeout:    cfg.TxTimeout,
+			QueryMemory:  cfg.QueryMemory,
+			MemoryBudget: cfg.MemoryBudget.Limit(),
+			BatchSize:    cfg.Batch,
+		}.Quads()
+	}))
+	api2.SetSystemGraph(sys)
+	api2.RegisterOn(r, api.cors.Wrap, LogRequest)
 
-	gs := &gephi.GraphStreamHandler{QS: handle.QuadStore}
+	// the stream is read with the same restrictions as queries of the client
+	gs := &gephi.GraphStreamHandler{QS: handle.QuadStore, Store: api2.QueryStore}
 	const gephiPath = "/gephi/gs"
-	r.GET(gephiPath, CORS(gs.ServeHTTP))
+	r.GET(gephiPath, api.cors.Wrap(gs.ServeHTTP))
 
 	if assets, err := findAssetsPath(); err != nil {
-		return err
+		return nil, err
 	} else if assets != "" {
-		clog.Infof("using assets from %q", assets)
-		docs := &DocRequestHandler{assets: assets}
+		logger.Info("using assets", clog.F("path", assets))
+		docs := &DocRequestHandler{assets: assets, css: base + markdownCSS}
 		r.GET("/docs/:docpage", docs.ServeHTTP)
 
 		var templates = template.Must(template.ParseGlob(fmt.Sprint(assets, "/templates/*.tmpl")))
 		templates.ParseGlob(fmt.Sprint(assets, "/templates/*.html"))
-		root := &TemplateRequestHandler{templates: templates}
+		root := &TemplateRequestHandler{templates: templates, BasePath: base}
 		r.GET("/ui/:ui_type", root.ServeHTTP)
 		r.GET("/", root.ServeHTTP)
-		http.Handle("/static/", http.StripPrefix("/static", http.FileServer(http.Dir(fmt.Sprint(assets, "/static/")))))
+		http.Handle(base+"/static/", http.StripPrefix(base+"/static", http.FileServer(http.Dir(fmt.Sprint(assets, "/static/")))))
 	}
 
-	http.Handle("/", r)
-	return nil
+	srv := &Server{api: api, api2: api2, router: r}
+	var h http.Handler = r
+	if len(cfg.Databases) != 0 {
+		if srv.dbs, err = setupDatabases(base, cfg); err != nil {
+			return nil, err
+		}
+		h = srv.selectDatabase(h)
+	}
+	if base != "" {
+		logger.Info("mounting handlers", clog.F("base", base))
+		h = http.StripPrefix(base, h)
+		http.Handle(base, http.RedirectHandler(base+"/", http.StatusMovedPermanently))
+	}
+	http.Handle(base+"/", proxies.Handler(h))
+	return srv, nil
 }
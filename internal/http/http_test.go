@@ -15,11 +15,38 @@
 package http
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"html/template"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/audit"
+	"github.com/cayleygraph/cayley/graph/memstore"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	cayleyhttp "github.com/cayleygraph/cayley/server/http"
+	"github.com/cayleygraph/cayley/writer"
 )
 
 var parseTests = []struct {
@@ -71,3 +98,528 @@ func TestParseJSON(t *testing.T) {
 		}
 	}
 }
+
+var clientAddrTests = []struct {
+	message string
+	proxies []string
+	remote  string
+	headers map[string]string
+	expect  string
+}{
+	{
+		message: "ignore headers from untrusted proxy",
+		remote:  "10.0.0.1:1234",
+		headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+		expect:  "10.0.0.1",
+	},
+	{
+		message: "use forwarded address from trusted proxy",
+		proxies: []string{"10.0.0.0/8"},
+		remote:  "10.0.0.1:1234",
+		headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+		expect:  "1.2.3.4",
+	},
+	{
+		message: "skip trusted hops and spoofed addresses",
+		proxies: []string{"10.0.0.0/8", "192.168.1.1"},
+		remote:  "10.0.0.1:1234",
+		headers: map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 192.168.1.1"},
+		expect:  "1.2.3.4",
+	},
+	{
+		message: "use real ip header",
+		proxies: []string{"10.0.0.1"},
+		remote:  "10.0.0.1:1234",
+		headers: map[string]string{"X-Real-IP": "1.2.3.4"},
+		expect:  "1.2.3.4",
+	},
+}
+
+func TestClientAddr(t *testing.T) {
+	for _, test := range clientAddrTests {
+		p, err := parseTrustedProxies(test.proxies)
+		if err != nil {
+			t.Fatalf("Failed to %s: %v", test.message, err)
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		if got := p.ClientAddr(r); got != test.expect {
+			t.Errorf("Failed to %s, got: %q expected: %q", test.message, got, test.expect)
+		}
+	}
+}
+
+var corsTests = []struct {
+	message string
+	origins []string
+	origin  string
+	allowed bool
+}{
+	{message: "allow any origin by default", origin: "http://example.com", allowed: true},
+	{message: "allow exact origin", origins: []string{"http://example.com"}, origin: "http://example.com", allowed: true},
+	{message: "reject other origin", origins: []string{"http://example.com"}, origin: "http://example.org", allowed: false},
+	{message: "allow subdomain wildcard", origins: []string{"https://*.example.com"}, origin: "https://app.example.com", allowed: true},
+	{message: "reject wildcard with other scheme", origins: []string{"https://*.example.com"}, origin: "http://app.example.com", allowed: false},
+}
+
+func TestCORS(t *testing.T) {
+	for _, test := range corsTests {
+		p, err := newCORSPolicy(CORSConfig{AllowedOrigins: test.origins, MaxAge: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("OPTIONS", "/", nil)
+		r.Header.Set("Origin", test.origin)
+		p.Preflight(w, r, nil)
+		got := w.Header().Get("Access-Control-Allow-Origin") == test.origin
+		if got != test.allowed {
+			t.Errorf("Failed to %s, got: %v expected: %v", test.message, got, test.allowed)
+		}
+		if got && w.Header().Get("Access-Control-Max-Age") != "60" {
+			t.Errorf("Failed to %s, max age is not set", test.message)
+		}
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	for _, origins := range [][]string{nil, {"*"}, {"http://example.com", "*"}} {
+		if _, err := newCORSPolicy(CORSConfig{AllowedOrigins: origins, AllowCredentials: true}); err == nil {
+			t.Errorf("Expected credentials to be rejected for origins %q", origins)
+		}
+	}
+	p, err := newCORSPolicy(CORSConfig{AllowedOrigins: []string{"http://example.com"}, AllowCredentials: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("OPTIONS", "/", nil)
+	r.Header.Set("Origin", "http://example.com")
+	p.Preflight(w, r, nil)
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("Failed to allow credentials for a listed origin")
+	}
+}
+
+func TestTemplatesBasePath(t *testing.T) {
+	templates := template.Must(template.ParseGlob("../../templates/*.tmpl"))
+	template.Must(templates.ParseGlob("../../templates/*.html"))
+	h := &TemplateRequestHandler{templates: templates, BasePath: "/cayley"}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil), nil)
+	body := w.Body.String()
+	if !strings.Contains(body, `src="/cayley/static/js/cayley_main.js"`) {
+		t.Errorf("base path is not applied to the template:\n%s", body)
+	}
+}
+
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cayley"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err == nil {
+		err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert, key := writeTestCert(t, dir)
+
+	var tlsTests = []struct {
+		message string
+		conf    TLSConfig
+		auth    tls.ClientAuthType
+		err     bool
+	}{
+		{message: "load static certificate", conf: TLSConfig{CertFile: cert, KeyFile: key}, auth: tls.NoClientCert},
+		{message: "reject missing key", conf: TLSConfig{CertFile: cert}, err: true},
+		{message: "reject certificate with ACME", conf: TLSConfig{CertFile: cert, KeyFile: key, ACMEHosts: []string{"example.com"}}, err: true},
+		{message: "require client certificates", conf: TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: cert}, auth: tls.RequireAndVerifyClientCert},
+		{message: "verify optional client certificates", conf: TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: cert, ClientCertOptional: true}, auth: tls.VerifyClientCertIfGiven},
+		{message: "reject invalid client CA", conf: TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: key}, err: true},
+		{message: "configure ACME", conf: TLSConfig{ACMEHosts: []string{"example.com"}, ACMECacheDir: dir}, auth: tls.NoClientCert},
+	}
+	for _, test := range tlsTests {
+		if !test.conf.Enabled() {
+			t.Errorf("Failed to %s, TLS is not enabled", test.message)
+			continue
+		}
+		conf, _, err := test.conf.serverConfig()
+		if test.err {
+			if err == nil {
+				t.Errorf("Failed to %s, expected an error", test.message)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("Failed to %s, got error: %v", test.message, err)
+			continue
+		}
+		if conf.ClientAuth != test.auth {
+			t.Errorf("Failed to %s, got: %v expected: %v", test.message, conf.ClientAuth, test.auth)
+		}
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert, key := writeTestCert(t, dir)
+	tc := &TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: cert}
+	conf, _, err := tc.serverConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := conf.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestCert(t, dir)
+	if err = tc.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	second, err := conf.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(first.Certificate, second.Certificate) {
+		t.Error("certificate was not reloaded")
+	}
+	cc, err := conf.GetConfigForClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.ClientCAs == conf.ClientCAs {
+		t.Error("client CAs were not reloaded")
+	}
+	if cc.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("unexpected client auth: %v", cc.ClientAuth)
+	}
+
+	// a broken file keeps the current certificate
+	if err = ioutil.WriteFile(cert, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.Reload(); err == nil {
+		t.Error("expected an error")
+	}
+	if c, _ := conf.GetCertificate(nil); c != second {
+		t.Error("certificate was replaced after a failed reload")
+	}
+}
+
+func TestReloadReadOnly(t *testing.T) {
+	api := &API{config: &Config{}}
+	s := &Server{api: api, api2: cayleyhttp.NewAPIv2(nil)}
+	h := api.RWOnly(func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, ro := range []bool{true, false} {
+		s.Reload(Settings{ReadOnly: ro})
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("POST", "/api/v1/write", nil), nil)
+		exp := http.StatusNoContent
+		if ro {
+			exp = http.StatusForbidden
+		}
+		if w.Code != exp {
+			t.Errorf("read-only %v: got status %d, expected %d", ro, w.Code, exp)
+		}
+	}
+}
+
+func TestDebugAdminOnly(t *testing.T) {
+	api := &API{config: &Config{AdminToken: "secret", Debug: true}}
+	r := httprouter.New()
+	api.registerDebug(r)
+	for _, c := range []struct {
+		method, path, token string
+		code                int
+	}{
+		{"GET", "/debug/gc", "", http.StatusUnauthorized},
+		{"GET", "/debug/gc", "wrong", http.StatusUnauthorized},
+		{"GET", "/debug/gc", "secret", http.StatusOK},
+		{"POST", "/debug/gc", "secret", http.StatusOK},
+		{"GET", "/debug/goroutines", "secret", http.StatusOK},
+		{"GET", "/debug/pprof/", "secret", http.StatusOK},
+		{"GET", "/debug/pprof/heap", "secret", http.StatusOK},
+		{"GET", "/debug/pprof/profile?seconds=0.01", "secret", http.StatusOK},
+		{"GET", "/debug/pprof/profile?seconds=x", "secret", http.StatusBadRequest},
+		{"GET", "/debug/pprof/unknown", "secret", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("%s %s: got status %d, expected %d", c.method, c.path, w.Code, c.code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/debug/gc", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	r.ServeHTTP(w, req)
+	var st GCStats
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	} else if st.Goroutines == 0 || st.HeapAlloc == 0 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	if _, err := SetupRoutes(nil, &Config{Debug: true}); err == nil {
+		t.Error("debug endpoints must require a token")
+	}
+}
+
+func TestAuditHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-http-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := audit.Open(filepath.Join(dir, "audit.log"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	qs := memstore.New()
+	qw, err := writer.NewSingleReplication(qs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &API{config: &Config{Audit: l, AdminToken: "secret"}, handle: &graph.Handle{QuadStore: qs, QuadWriter: qw}}
+
+	// the principal is taken from the client certificate
+	req := httptest.NewRequest("POST", "/api/v1/write", strings.NewReader(
+		`[{"subject": "<alice>", "predicate": "<follows>", "object": "<bob>"}]`))
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "carol"}}}}
+	w := httptest.NewRecorder()
+	api.ServeV1Write(w, req, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("write failed: %d %s", w.Code, w.Body.String())
+	}
+
+	r := httprouter.New()
+	r.GET(auditHistoryPath, api.AdminOnly(api.ServeAuditHistory))
+	history := func(token, params string) (int, []audit.Entry) {
+		req := httptest.NewRequest("GET", auditHistoryPath+"?"+params, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out struct {
+			Result []audit.Entry `json:"result"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, out.Result
+	}
+	if code, _ := history("", "node=%3Cbob%3E"); code != http.StatusUnauthorized {
+		t.Errorf("got status %d without a token", code)
+	}
+	if code, _ := history("secret", ""); code != http.StatusBadRequest {
+		t.Errorf("got status %d without a node", code)
+	}
+	code, res := history("secret", "node=%3Cbob%3E")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	} else if len(res) != 1 || res[0].Principal != "carol" || len(res[0].Deltas) != 1 {
+		t.Errorf("unexpected history: %+v", res)
+	}
+	if _, res = history("secret", "node=%3Ccarol%3E"); len(res) != 0 {
+		t.Errorf("unexpected history: %+v", res)
+	}
+}
+
+func TestQueriesAdmin(t *testing.T) {
+	active := query.NewActive()
+	api := &API{config: &Config{Active: active, AdminToken: "secret"}}
+	r := httprouter.New()
+	r.GET(queriesPath, api.AdminOnly(api.ServeQueries))
+	r.DELETE(queriesPath+"/:id", api.AdminOnly(api.ServeCancelQuery))
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	ctx, e := active.Start(context.TODO(), "gizmo", "g.V().All()")
+	defer e.Done()
+
+	w := do("GET", queriesPath)
+	var out struct {
+		Result []query.ExecutionInfo `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	} else if len(out.Result) != 1 || out.Result[0].Query != "g.V().All()" {
+		t.Fatalf("unexpected queries: %+v", out.Result)
+	}
+	if w = do("DELETE", queriesPath+"/"+out.Result[0].ID); w.Code != http.StatusOK {
+		t.Errorf("got status %d", w.Code)
+	} else if ctx.Err() == nil {
+		t.Error("query is not cancelled")
+	}
+	if w = do("DELETE", queriesPath+"/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for unknown query", w.Code)
+	}
+}
+
+func TestAdminRoutesToken(t *testing.T) {
+	qs := memstore.New()
+	qw, err := writer.NewSingleReplication(qs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = SetupRoutes(&graph.Handle{QuadStore: qs, QuadWriter: qw}, &Config{
+		BasePath: "/notoken", Active: query.NewActive(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ method, path string }{
+		{"GET", "/notoken" + queriesPath},
+		{"DELETE", "/notoken" + queriesPath + "/1"},
+	} {
+		w := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s without the admin token: got status %d", c.method, c.path, w.Code)
+		}
+	}
+}
+
+func TestGephiACL(t *testing.T) {
+	qs := memstore.New(
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("a", "knows", "c", "g2"),
+	)
+	qw, err := writer.NewSingleReplication(qs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = SetupRoutes(&graph.Handle{QuadStore: qs, QuadWriter: qw}, &Config{
+		BasePath: "/gephiacl",
+		ACL: &acl.ACL{Rules: []acl.Rule{
+			{Principals: []string{"alice"}, Graphs: []string{"<g1>"}, Read: true},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := func(principal string) string {
+		req := httptest.NewRequest("GET", "/gephiacl/gephi/gs?mode=raw", nil)
+		if principal != "" {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: principal}}}}
+		}
+		w := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+	if out := stream(""); out != "" {
+		t.Errorf("anonymous client got quads: %q", out)
+	}
+	if out := stream("alice"); !strings.Contains(out, `"label":"b"`) || strings.Contains(out, `"label":"c"`) {
+		t.Errorf("unexpected quads for alice: %q", out)
+	}
+}
+
+func TestDatabases(t *testing.T) {
+	newHandle := func(quads ...quad.Quad) *graph.Handle {
+		qs := memstore.New(quads...)
+		qw, err := writer.NewSingleReplication(qs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &graph.Handle{QuadStore: qs, QuadWriter: qw}
+	}
+	if _, err := SetupRoutes(newHandle(), &Config{BasePath: "/invalid", Databases: []Database{{Name: "a/b", Handle: newHandle()}}}); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+	_, err := SetupRoutes(newHandle(quad.MakeIRI("main", "p", "o", "")), &Config{
+		BasePath: "/multi",
+		Databases: []Database{
+			{Name: "other", Handle: newHandle(quad.MakeIRI("other", "p", "o", "")), ReadOnly: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, path, db string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader("<s> <p> <o> .\n"))
+		if db != "" {
+			req.Header.Set(DatabaseHeader, db)
+		}
+		w := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(w, req)
+		return w
+	}
+	for _, c := range []struct {
+		path, db string
+		code     int
+		subject  string
+	}{
+		{path: "/multi/api/v2/read?format=nquads", code: http.StatusOK, subject: "<main>"},
+		{path: "/multi/db/other/api/v2/read?format=nquads", code: http.StatusOK, subject: "<other>"},
+		{path: "/multi/api/v2/read?format=nquads", db: "other", code: http.StatusOK, subject: "<other>"},
+		{path: "/multi/api/v2/read?format=nquads", db: "unknown", code: http.StatusNotFound},
+	} {
+		w := do("GET", c.path, c.db)
+		if w.Code != c.code {
+			t.Errorf("%s (%q): got status %d, expected %d", c.path, c.db, w.Code, c.code)
+		} else if c.subject != "" && !strings.HasPrefix(w.Body.String(), c.subject+" ") {
+			t.Errorf("%s (%q): unexpected quads: %q", c.path, c.db, w.Body.String())
+		}
+	}
+	if w := do("POST", "/multi/db/other/api/v2/write", ""); w.Code != http.StatusForbidden {
+		t.Errorf("write to a read-only database: got status %d", w.Code)
+	}
+	if w := do("POST", "/multi/api/v2/write", ""); w.Code != http.StatusOK {
+		t.Errorf("write to the main database: got status %d: %s", w.Code, w.Body.String())
+	}
+}
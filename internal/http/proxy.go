@@ -0,0 +1,120 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies resolves an address of the client that made a request through
+// one or more reverse proxies.
+type trustedProxies []*net.IPNet
+
+func parseTrustedProxies(list []string) (trustedProxies, error) {
+	var out trustedProxies
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address: %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address range: %v", err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func (p trustedProxies) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// ClientAddr returns the IP address of the client, as reported by trusted proxies.
+//
+// Forwarding headers are only considered if the request came from a trusted proxy.
+// X-Forwarded-For is scanned from the right, and the first untrusted address is returned.
+func (p trustedProxies) ClientAddr(r *http.Request) string {
+	addr := splitHost(r.RemoteAddr)
+	if !p.trusted(addr) {
+		return addr
+	}
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		hops := strings.Split(fwd, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := splitHost(strings.TrimSpace(hops[i]))
+			if hop == "" {
+				continue
+			}
+			addr = hop
+			if !p.trusted(hop) {
+				break
+			}
+		}
+		return addr
+	}
+	if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); real != "" {
+		return real
+	}
+	return addr
+}
+
+// Handler rewrites RemoteAddr of the request to the address of the client,
+// so the following handlers (logging, limits) can rely on it.
+func (p trustedProxies) Handler(h http.Handler) http.Handler {
+	if len(p) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr := p.ClientAddr(r); addr != splitHost(r.RemoteAddr) {
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.RemoteAddr = net.JoinHostPort(addr, "0")
+			r = r2
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cayleygraph/cayley/query"
+)
+
+const queriesPath = "/api/v2/admin/queries"
+
+// ServeQueries lists running queries.
+func (api *API) ServeQueries(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Result []query.ExecutionInfo `json:"result"`
+	}{api.config.Active.List()})
+}
+
+// ServeCancelQuery cancels a running query by its id.
+func (api *API) ServeCancelQuery(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if !api.config.Active.Cancel(params.ByName("id")) {
+		jsonResponse(w, http.StatusNotFound, "query is not running")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"result": "Query cancelled."}`))
+}
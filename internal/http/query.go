@@ -26,6 +26,8 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/internal/memory"
 	"github.com/cayleygraph/cayley/query"
 )
 
@@ -60,8 +62,11 @@ func GetQueryShape(q string, ses query.HTTP) ([]byte, error) {
 func (api *API) contextForRequest(r *http.Request) (context.Context, func()) {
 	ctx := context.TODO() // TODO(dennwc): get from request
 	cancel := func() {}
-	if api.config.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, api.config.Timeout)
+	if timeout := api.current().Timeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	if n := api.config.QueryParallelism; n > 1 {
+		ctx = iterator.NewParallelContext(ctx, n)
 	}
 	return ctx, cancel
 }
@@ -98,9 +103,20 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 		errFunc(w, err)
 		return
 	}
+	qs, err := api.queryStore(r, h.QuadStore)
+	if err != nil {
+		errFunc(w, err)
+		return
+	}
+	done, err := api.config.Quota.BeginQuery(api.config.DefaultGraph)
+	if err != nil {
+		jsonResponse(w, http.StatusTooManyRequests, err)
+		return
+	}
+	defer done()
 	if l.HTTPQuery != nil {
 		defer r.Body.Close()
-		l.HTTPQuery(ctx, h.QuadStore, w, r.Body)
+		l.HTTPQuery(ctx, qs, w, r.Body)
 		return
 	}
 	if l.HTTP == nil {
@@ -114,13 +130,22 @@ func (api *API) ServeV1Query(w http.ResponseWriter, r *http.Request, params http
 		limit = 100
 	}
 
-	ses := l.HTTP(h.QuadStore)
+	ses := l.HTTP(qs)
 	bodyBytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		errFunc(w, err)
 		return
 	}
 	code := string(bodyBytes)
+	if api.config.MemoryBudget.Exhausted() {
+		jsonResponse(w, http.StatusServiceUnavailable, memory.ErrBudget)
+		return
+	}
+	acc := api.config.MemoryBudget.NewAccount(api.config.QueryMemory)
+	defer acc.Close()
+	ctx = memory.NewContext(ctx, acc)
+	ctx, exec := api.config.Active.Start(ctx, params.ByName("query_lang"), code)
+	defer exec.Done()
 
 	c := make(chan query.Result, 5)
 	go ses.Execute(ctx, code, c, limit)
@@ -165,7 +190,12 @@ func (api *API) ServeV1Shape(w http.ResponseWriter, r *http.Request, params http
 		jsonResponse(w, http.StatusBadRequest, "HTTP interface is not supported for this query language.")
 		return
 	}
-	ses := l.HTTP(h.QuadStore)
+	qs, err := api.queryStore(r, h.QuadStore)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	ses := l.HTTP(qs)
 	bodyBytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		jsonResponse(w, http.StatusBadRequest, err)
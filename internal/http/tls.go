@@ -0,0 +1,202 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/cayleygraph/cayley/clog"
+)
+
+// TLSConfig describes how the HTTP server should serve TLS.
+//
+// Either a static certificate (CertFile and KeyFile) or a list of ACME hosts must be set.
+type TLSConfig struct {
+	// CertFile and KeyFile are paths to a PEM-encoded certificate and a private key.
+	CertFile string
+	KeyFile  string
+
+	// ACMEHosts is a list of host names to request certificates for from Let's Encrypt.
+	ACMEHosts []string
+	// ACMECacheDir is a directory to store issued certificates in.
+	ACMECacheDir string
+	// ACMEEmail is a contact address for the ACME account.
+	ACMEEmail string
+	// ACMEHTTPAddr is an optional address to serve http-01 challenges on (usually ":80").
+	// If not set, only tls-alpn-01 challenges will be used.
+	ACMEHTTPAddr string
+
+	// ClientCAFile is a path to PEM-encoded CA certificates used to verify client certificates.
+	// If set, clients must present a valid certificate signed by one of these CAs.
+	ClientCAFile string
+	// ClientCertOptional allows clients without a certificate to connect, but certificates
+	// that were presented are still verified.
+	ClientCertOptional bool
+
+	// current certificate and client CAs, replaced by Reload
+	cert      atomic.Value // *tls.Certificate
+	clientCAs atomic.Value // *x509.CertPool
+}
+
+// Reload reads the certificate, the key and client CAs from their files again. New connections use them
+// after it returns, and established connections are not affected. Certificates issued by ACME are renewed
+// automatically and are not reloaded.
+func (c *TLSConfig) Reload() error {
+	var (
+		cert *tls.Certificate
+		pool *x509.CertPool
+		err  error
+	)
+	if c.CertFile != "" && len(c.ACMEHosts) == 0 {
+		if cert, err = c.loadCert(); err != nil {
+			return err
+		}
+	}
+	if c.ClientCAFile != "" {
+		if pool, err = c.loadClientCAs(); err != nil {
+			return err
+		}
+	}
+	if cert != nil {
+		c.cert.Store(cert)
+	}
+	if pool != nil {
+		c.clientCAs.Store(pool)
+	}
+	return nil
+}
+
+func (c *TLSConfig) loadCert() (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: cannot load certificate: %v", err)
+	}
+	return &cert, nil
+}
+
+func (c *TLSConfig) loadClientCAs() (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: cannot read client CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("tls: no certificates found in %q", c.ClientCAFile)
+	}
+	return pool, nil
+}
+
+func (c *TLSConfig) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, _ := c.cert.Load().(*tls.Certificate)
+	return cert, nil
+}
+
+// Enabled checks if TLS should be used.
+func (c *TLSConfig) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.KeyFile != "" || len(c.ACMEHosts) != 0)
+}
+
+const defaultACMECache = "./acme-cache"
+
+// serverConfig builds TLS config for the server. It returns an optional handler that
+// should be served on ACMEHTTPAddr to answer http-01 challenges.
+func (c *TLSConfig) serverConfig() (*tls.Config, http.Handler, error) {
+	var (
+		conf *tls.Config
+		acme http.Handler
+	)
+	switch {
+	case len(c.ACMEHosts) != 0 && c.CertFile != "":
+		return nil, nil, errors.New("tls: static certificate and ACME cannot be used together")
+	case len(c.ACMEHosts) != 0:
+		dir := c.ACMECacheDir
+		if dir == "" {
+			dir = defaultACMECache
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.ACMEHosts...),
+			Cache:      autocert.DirCache(dir),
+			Email:      c.ACMEEmail,
+		}
+		conf = m.TLSConfig()
+		if c.ACMEHTTPAddr != "" {
+			acme = m.HTTPHandler(nil)
+		}
+	case c.CertFile == "" || c.KeyFile == "":
+		return nil, nil, errors.New("tls: both certificate and key files must be set")
+	default:
+		cert, err := c.loadCert()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.cert.Store(cert)
+		// the certificate is looked up on each handshake, so it can be reloaded
+		conf = &tls.Config{GetCertificate: c.getCertificate}
+	}
+	conf.MinVersion = tls.VersionTLS12
+	if c.ClientCAFile != "" {
+		pool, err := c.loadClientCAs()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.clientCAs.Store(pool)
+		conf.ClientCAs = pool
+		if c.ClientCertOptional {
+			conf.ClientAuth = tls.VerifyClientCertIfGiven
+		} else {
+			conf.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		// client CAs can be reloaded, so they are set for each handshake
+		base := conf.Clone()
+		conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cc := base.Clone()
+			cc.ClientCAs = c.clientCAs.Load().(*x509.CertPool)
+			return cc, nil
+		}
+	}
+	return conf, acme, nil
+}
+
+// ListenAndServe serves HTTP requests on a given address using handlers registered by
+// SetupRoutes. If TLS config is enabled, the server will only accept TLS connections.
+func ListenAndServe(addr string, tc *TLSConfig) error {
+	if !tc.Enabled() {
+		return http.ListenAndServe(addr, nil)
+	}
+	conf, acme, err := tc.serverConfig()
+	if err != nil {
+		return err
+	}
+	if acme != nil {
+		go func() {
+			logger.Info("serving ACME challenges", clog.F("addr", tc.ACMEHTTPAddr))
+			if err := http.ListenAndServe(tc.ACMEHTTPAddr, acme); err != nil {
+				logger.Error("ACME challenge server failed", clog.F("error", err))
+			}
+		}()
+	}
+	srv := &http.Server{Addr: addr, TLSConfig: conf}
+	// certificates are already set in the config
+	return srv.ListenAndServeTLS("", "")
+}
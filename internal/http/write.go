@@ -70,7 +70,7 @@ func readLimit(r io.Reader) ([]byte, error) {
 }
 
 func (api *API) ServeV1Write(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	if api.config.ReadOnly {
+	if api.current().ReadOnly {
 		jsonResponse(w, 400, "Database is read-only.")
 		return
 	}
@@ -85,7 +85,7 @@ func (api *API) ServeV1Write(w http.ResponseWriter, r *http.Request, _ httproute
 		jsonResponse(w, 400, err)
 		return
 	}
-	h, err := api.GetHandleForRequest(r)
+	h, err := api.writeHandleForRequest(w, r)
 	if err != nil {
 		jsonResponse(w, 400, err)
 		return
@@ -98,14 +98,14 @@ func (api *API) ServeV1Write(w http.ResponseWriter, r *http.Request, _ httproute
 }
 
 func (api *API) ServeV1WriteNQuad(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	if api.config.ReadOnly {
+	if api.current().ReadOnly {
 		jsonResponse(w, 400, "Database is read-only.")
 		return
 	}
 
 	formFile, _, err := r.FormFile("NQuadFile")
 	if err != nil {
-		clog.Errorf("%v", err)
+		logger.Error("cannot read the uploaded file", clog.F("error", err))
 		jsonResponse(w, 500, "Couldn't read file: "+err.Error())
 		return
 	}
@@ -120,7 +120,7 @@ func (api *API) ServeV1WriteNQuad(w http.ResponseWriter, r *http.Request, params
 	// TODO(kortschak) Make this configurable from the web UI.
 	dec := nquads.NewReader(quadReader, false)
 
-	h, err := api.GetHandleForRequest(r)
+	h, err := api.writeHandleForRequest(w, r)
 	if err != nil {
 		jsonResponse(w, 400, err)
 		return
@@ -140,7 +140,7 @@ func (api *API) ServeV1WriteNQuad(w http.ResponseWriter, r *http.Request, params
 }
 
 func (api *API) ServeV1Delete(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	if api.config.ReadOnly {
+	if api.current().ReadOnly {
 		jsonResponse(w, 400, "Database is read-only.")
 		return
 	}
@@ -154,7 +154,7 @@ func (api *API) ServeV1Delete(w http.ResponseWriter, r *http.Request, params htt
 		jsonResponse(w, 400, err)
 		return
 	}
-	h, err := api.GetHandleForRequest(r)
+	h, err := api.writeHandleForRequest(w, r)
 	if err != nil {
 		jsonResponse(w, 400, err)
 		return
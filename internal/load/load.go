@@ -0,0 +1,386 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package load writes quads from a file to a quad store with multiple workers, and resumes interrupted loads.
+//
+// Uncompressed N-Quads files are split into chunks of lines that are decoded and written in parallel,
+// and the progress is saved as a byte offset, so a resumed load seeks directly to it. Other inputs are decoded
+// by a single reader, written in parallel, and a resumed load skips the number of quads that were written.
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal"
+	"github.com/cayleygraph/cayley/internal/decompressor"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+)
+
+// Options configures a load.
+type Options struct {
+	// Format of the file. It's detected from the file extension if not set.
+	Format string
+	// Workers is the number of goroutines that decode and write batches of quads. Defaults to GOMAXPROCS.
+	// The quad writer must allow concurrent writes if it's more than one.
+	Workers int
+	// Batch is the number of quads in one write. Defaults to quad.DefaultBatch.
+	Batch int
+	// Checkpoint is a path of a file to save the progress to. The load resumes from it if the file exists,
+	// and the file is removed when the load completes. The quad writer must ignore duplicates,
+	// since quads written after the last checkpoint are written again.
+	Checkpoint string
+	// Progress is called after each saved step with the number of loaded quads.
+	Progress func(done int64)
+}
+
+// Stats describes a completed load.
+type Stats struct {
+	// Quads is the number of quads in the file.
+	Quads int64
+	// Resumed is the number of quads that were loaded before the last checkpoint.
+	Resumed int64
+}
+
+type checkpoint struct {
+	Path string `json:"path"`
+	// Size of the file, to detect changes. It's zero for streams.
+	Size int64 `json:"size,omitempty"`
+	// Offset is the number of bytes of the file that were loaded, if the file is split by lines.
+	Offset int64 `json:"offset,omitempty"`
+	// Done is the number of quads that were loaded.
+	Done int64 `json:"done"`
+}
+
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("load: invalid checkpoint: %v", err)
+	}
+	return &cp, nil
+}
+
+func writeCheckpoint(path string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// job is a batch of quads, or a chunk of N-Quads lines that ends at a given offset.
+type job struct {
+	seq   int
+	quads []quad.Quad
+	lines []byte
+	end   int64
+}
+
+type result struct {
+	seq int
+	n   int
+	end int64
+}
+
+// Load writes all quads from a file to a quad writer. The path may be "-" for stdin, or an URL.
+func Load(ctx context.Context, qw graph.QuadWriter, path string, opts Options) (*Stats, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = quad.DefaultBatch
+	}
+	cp := checkpoint{Path: path}
+	if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+		cp.Size = fi.Size()
+	}
+	st := &Stats{}
+	if opts.Checkpoint != "" {
+		prev, err := readCheckpoint(opts.Checkpoint)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		} else if prev != nil {
+			if prev.Path != cp.Path || prev.Size != cp.Size {
+				return nil, fmt.Errorf("load: checkpoint is for a different file; remove it to start over")
+			}
+			cp = *prev
+			st.Resumed = cp.Done
+			clog.Infof("load: resuming after %d quads", cp.Done)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		jobs    = make(chan job, opts.Workers)
+		results = make(chan result, opts.Workers)
+		errs    = make(chan error, opts.Workers+2)
+	)
+	send := func(j job) bool {
+		select {
+		case jobs <- j:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	lines, err := splitsByLines(path, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Offset != 0 && !lines {
+		return nil, fmt.Errorf("load: checkpoint has an offset, but the file can't be split by lines")
+	}
+	if lines {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if _, err = f.Seek(cp.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		go func() {
+			defer close(jobs)
+			if err := readLines(f, cp.Offset, opts.Batch, send); err != nil {
+				errs <- err
+			}
+		}()
+	} else {
+		qr, err := internal.QuadReaderFor(path, opts.Format)
+		if err != nil {
+			return nil, err
+		}
+		defer qr.Close()
+		go func() {
+			defer close(jobs)
+			if err := readQuads(qr, cp.Done, opts.Batch, send); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				quads := j.quads
+				if j.lines != nil {
+					var err error
+					if quads, err = decodeLines(j.lines); err != nil {
+						errs <- err
+						cancel()
+						return
+					}
+				}
+				if err := qw.AddQuadSet(quads); err != nil {
+					errs <- fmt.Errorf("load: failed to write quads: %v", err)
+					cancel()
+					return
+				}
+				select {
+				case results <- result{seq: j.seq, n: len(quads), end: j.end}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// batches complete out of order; the checkpoint only includes the ones without gaps before them
+	var (
+		next    int
+		pending = make(map[int]result)
+	)
+	for r := range results {
+		pending[r.seq] = r
+		advanced := false
+		for p, ok := pending[next]; ok; p, ok = pending[next] {
+			delete(pending, next)
+			cp.Done += int64(p.n)
+			if lines {
+				cp.Offset = p.end
+			}
+			next++
+			advanced = true
+		}
+		if !advanced {
+			continue
+		}
+		if opts.Checkpoint != "" {
+			if err := writeCheckpoint(opts.Checkpoint, cp); err != nil {
+				cancel()
+				errs <- err
+				break
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(cp.Done)
+		}
+	}
+	for range results {
+	}
+	select {
+	case err := <-errs:
+		return st, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return st, err
+	}
+	st.Quads = cp.Done
+	if opts.Checkpoint != "" {
+		if err := os.Remove(opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// splitsByLines checks if the file is an uncompressed local N-Quads file, which can be split by lines.
+func splitsByLines(path, format string) (bool, error) {
+	if format == "" {
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".bz2")
+		if f := quad.FormatByExt(filepath.Ext(name)); f != nil {
+			format = f.Name
+		} else {
+			format = "nquads"
+		}
+	}
+	if format != "nquads" {
+		return false, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		// not a local file; the reader will report an error, if any
+		return false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, 3)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return !decompressor.IsCompressed(magic[:n]), nil
+}
+
+// readLines sends chunks of complete lines, each with up to a given number of lines.
+func readLines(r io.Reader, offset int64, batch int, send func(job) bool) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	seq := 0
+	for {
+		var (
+			buf bytes.Buffer
+			n   int
+			err error
+		)
+		for n < batch {
+			var line []byte
+			line, err = br.ReadBytes('\n')
+			buf.Write(line)
+			n++
+			if err != nil {
+				break
+			}
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if buf.Len() != 0 {
+			offset += int64(buf.Len())
+			if !send(job{seq: seq, lines: buf.Bytes(), end: offset}) {
+				return nil
+			}
+			seq++
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// readQuads sends batches of quads, after skipping a given number of quads.
+func readQuads(qr quad.Reader, skip int64, batch int, send func(job) bool) error {
+	seq := 0
+	var quads []quad.Quad
+	for {
+		q, err := qr.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("load: failed to read quads: %v", err)
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		quads = append(quads, q)
+		if len(quads) >= batch {
+			if !send(job{seq: seq, quads: quads}) {
+				return nil
+			}
+			seq++
+			quads = nil
+		}
+	}
+	if len(quads) != 0 {
+		send(job{seq: seq, quads: quads})
+	}
+	return nil
+}
+
+func decodeLines(data []byte) ([]quad.Quad, error) {
+	qr := nquads.NewReader(bytes.NewReader(data), false)
+	var out []quad.Quad
+	for {
+		q, err := qr.ReadQuad()
+		if err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("load: failed to read quads: %v", err)
+		}
+		out = append(out, q)
+	}
+}
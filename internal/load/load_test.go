@@ -0,0 +1,149 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func newKV(t testing.TB) (graph.QuadStore, graph.QuadWriter) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	qw, err := graph.NewQuadWriter("single", qs, graph.Options{"ignore_duplicate": true})
+	require.NoError(t, err)
+	return qs, qw
+}
+
+func testQuads(n int) []quad.Quad {
+	var quads []quad.Quad
+	for i := 0; i < n; i++ {
+		quads = append(quads, quad.Make(quad.IRI(fmt.Sprintf("n%d", i%7)), quad.IRI("p"), quad.Int(i), nil))
+	}
+	return quads
+}
+
+func writeFile(t testing.TB, path string, quads []quad.Quad, gz bool) {
+	buf := bytes.NewBuffer(nil)
+	w := nquads.NewWriter(buf)
+	_, err := quad.Copy(w, quad.NewReader(quads))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	data := buf.Bytes()
+	if gz {
+		var zb bytes.Buffer
+		zw := gzip.NewWriter(&zb)
+		zw.Write(data)
+		require.NoError(t, zw.Close())
+		data = zb.Bytes()
+	}
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-load")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	quads := testQuads(50)
+
+	for _, gz := range []bool{false, true} {
+		t.Run(fmt.Sprintf("gzip=%v", gz), func(t *testing.T) {
+			path := filepath.Join(dir, "data.nq")
+			if gz {
+				path += ".gz"
+			}
+			writeFile(t, path, quads, gz)
+			lines, err := splitsByLines(path, "")
+			require.NoError(t, err)
+			require.Equal(t, !gz, lines)
+
+			qs, qw := newKV(t)
+			defer qs.Close()
+			cpath := filepath.Join(dir, "checkpoint.json")
+			var last int64
+			st, err := Load(context.TODO(), qw, path, Options{Workers: 3, Batch: 4, Checkpoint: cpath, Progress: func(n int64) { last = n }})
+			require.NoError(t, err)
+			require.Equal(t, &Stats{Quads: 50}, st)
+			require.Equal(t, int64(50), last)
+			graphtest.ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), quads, true)
+			_, err = os.Stat(cpath)
+			require.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestLoadResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-load")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	quads := testQuads(50)
+	cpath := filepath.Join(dir, "checkpoint.json")
+
+	for _, gz := range []bool{false, true} {
+		t.Run(fmt.Sprintf("gzip=%v", gz), func(t *testing.T) {
+			path := filepath.Join(dir, "data.nq")
+			if gz {
+				path += ".gz"
+			}
+			writeFile(t, path, quads[:20], gz)
+			fi, err := os.Stat(path)
+			require.NoError(t, err)
+
+			// load the first part of the file, and pretend it was interrupted
+			qs, qw := newKV(t)
+			defer qs.Close()
+			_, err = Load(context.TODO(), qw, path, Options{Workers: 2, Batch: 4})
+			require.NoError(t, err)
+			cp := checkpoint{Path: path, Done: 20}
+			if !gz {
+				cp.Offset = fi.Size()
+			}
+			writeFile(t, path, quads, gz)
+			fi, err = os.Stat(path)
+			require.NoError(t, err)
+			cp.Size = fi.Size()
+			require.NoError(t, writeCheckpoint(cpath, cp))
+
+			st, err := Load(context.TODO(), qw, path, Options{Workers: 2, Batch: 4, Checkpoint: cpath})
+			require.NoError(t, err)
+			require.Equal(t, &Stats{Quads: 50, Resumed: 20}, st)
+			graphtest.ExpectIteratedQuads(t, qs, qs.QuadsAllIterator(), quads, true)
+
+			// a checkpoint of a different file is rejected
+			require.NoError(t, writeCheckpoint(cpath, checkpoint{Path: path, Size: 1, Done: 1}))
+			_, err = Load(context.TODO(), qw, path, Options{Checkpoint: cpath})
+			require.Error(t, err)
+			os.Remove(cpath)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory accounts for memory used by queries.
+//
+// Each query gets an account with an optional limit, and all accounts draw from an optional global budget,
+// so a server rejects queries when the budget is exhausted instead of running out of memory. Sizes are estimates:
+// iterators and query languages reserve a fixed size per value they keep, not the bytes actually allocated.
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	// ErrQueryLimit is returned when a query exceeds its memory limit.
+	ErrQueryLimit = errors.New("memory: query exceeded its memory limit")
+	// ErrBudget is returned when the global memory budget is exhausted.
+	ErrBudget = errors.New("memory: server memory budget for queries is exhausted")
+)
+
+// ValueSize is the estimated size of a single value kept in memory, including the overhead of maps and slices.
+const ValueSize = 64
+
+// Budget is the amount of memory shared by all queries.
+type Budget struct {
+	used  int64 // atomic
+	limit int64
+}
+
+// NewBudget creates a budget of a given size in bytes.
+func NewBudget(limit int64) *Budget {
+	return &Budget{limit: limit}
+}
+
+// Limit returns the size of the budget in bytes.
+func (b *Budget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// Used returns the number of bytes reserved by running queries.
+func (b *Budget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.used)
+}
+
+// Exhausted reports whether the whole budget is reserved. New queries should be rejected in this case.
+func (b *Budget) Exhausted() bool {
+	return b != nil && b.Used() >= b.limit
+}
+
+func (b *Budget) reserve(n int64) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		used := atomic.LoadInt64(&b.used)
+		if used+n > b.limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.used, used, used+n) {
+			return true
+		}
+	}
+}
+
+func (b *Budget) release(n int64) {
+	if b != nil {
+		atomic.AddInt64(&b.used, -n)
+	}
+}
+
+// Account tracks memory of a single query. Methods of a nil account are no-op.
+type Account struct {
+	used   int64 // atomic
+	limit  int64
+	budget *Budget
+}
+
+// NewAccount creates an account for a query with a given limit in bytes, drawing from the budget.
+// Zero limit only applies the budget. If the budget is nil, only the limit is applied.
+// It returns nil if there is neither a limit nor a budget.
+func (b *Budget) NewAccount(limit int64) *Account {
+	if b == nil && limit <= 0 {
+		return nil
+	}
+	return &Account{limit: limit, budget: b}
+}
+
+// Reserve reserves n bytes. It returns ErrQueryLimit or ErrBudget if there is not enough memory.
+func (a *Account) Reserve(n int64) error {
+	if a == nil {
+		return nil
+	}
+	if used := atomic.AddInt64(&a.used, n); a.limit > 0 && used > a.limit {
+		atomic.AddInt64(&a.used, -n)
+		return ErrQueryLimit
+	}
+	if !a.budget.reserve(n) {
+		atomic.AddInt64(&a.used, -n)
+		return ErrBudget
+	}
+	return nil
+}
+
+// Release returns n reserved bytes.
+func (a *Account) Release(n int64) {
+	if a == nil {
+		return
+	}
+	atomic.AddInt64(&a.used, -n)
+	a.budget.release(n)
+}
+
+// Used returns the number of reserved bytes.
+func (a *Account) Used() int64 {
+	if a == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&a.used)
+}
+
+// Close returns all reserved bytes to the budget. It must be called when the query completes.
+func (a *Account) Close() {
+	if a == nil {
+		return
+	}
+	a.budget.release(atomic.SwapInt64(&a.used, 0))
+}
+
+type contextKey struct{}
+
+// NewContext returns a context that carries an account.
+func NewContext(ctx context.Context, a *Account) context.Context {
+	if a == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// FromContext returns an account of the query, or nil if memory is not accounted.
+func FromContext(ctx context.Context) *Account {
+	if ctx == nil {
+		return nil
+	}
+	a, _ := ctx.Value(contextKey{}).(*Account)
+	return a
+}
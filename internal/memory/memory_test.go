@@ -0,0 +1,59 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccount(t *testing.T) {
+	b := NewBudget(100)
+	a := b.NewAccount(60)
+	require.NoError(t, a.Reserve(50))
+	require.Equal(t, ErrQueryLimit, a.Reserve(20))
+	require.Equal(t, int64(50), a.Used())
+
+	c := b.NewAccount(0)
+	require.NoError(t, c.Reserve(50))
+	require.Equal(t, ErrBudget, c.Reserve(1))
+	require.True(t, b.Exhausted())
+
+	a.Release(10)
+	require.False(t, b.Exhausted())
+	require.NoError(t, c.Reserve(10))
+
+	a.Close()
+	c.Close()
+	require.Equal(t, int64(0), b.Used())
+}
+
+func TestNilAccount(t *testing.T) {
+	var b *Budget
+	require.False(t, b.Exhausted())
+	a := b.NewAccount(0)
+	require.Nil(t, a)
+	require.NoError(t, a.Reserve(1<<30))
+	a.Close()
+
+	ctx := NewContext(context.Background(), a)
+	require.Nil(t, FromContext(ctx))
+
+	a = b.NewAccount(10)
+	require.Equal(t, ErrQueryLimit, a.Reserve(20))
+	require.Equal(t, a, FromContext(NewContext(ctx, a)))
+}
@@ -0,0 +1,301 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate copies all quads from one quad store to another.
+//
+// Quads are read from a single scan of the source and are resolved and written to the destination
+// by multiple workers. The progress is saved to a checkpoint file, so an interrupted migration
+// continues where it stopped. When all quads are written, the number of quads in the destination is verified.
+//
+// Namespaces and schema metadata are stored as quads, thus they are migrated with the rest of the graph.
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Options configures a migration.
+type Options struct {
+	// Workers is the number of goroutines that read and write batches of quads. Defaults to GOMAXPROCS.
+	Workers int
+	// Batch is the number of quads in one write. Defaults to quad.DefaultBatch.
+	Batch int
+	// Checkpoint is a path of a file to save the progress to. The migration resumes from it if the file exists,
+	// and the file is removed when the migration completes.
+	Checkpoint string
+	// Progress is called after each saved step with the number of migrated quads.
+	Progress func(done int64)
+}
+
+// Stats describes a completed migration.
+type Stats struct {
+	// Quads is the number of quads in the source.
+	Quads int64
+	// Resumed is the number of quads that were migrated before the last checkpoint.
+	Resumed int64
+}
+
+type checkpoint struct {
+	// Horizon of the source snapshot, if the source supports them.
+	Horizon int64 `json:"horizon,omitempty"`
+	// Done is the number of quads at the start of the scan that are written to the destination.
+	Done int64 `json:"done"`
+}
+
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("migrate: invalid checkpoint: %v", err)
+	}
+	return &cp, nil
+}
+
+func writeCheckpoint(path string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// openSource pins the source to a snapshot, if possible. If the migration is resumed, the snapshot
+// must have the same horizon as the checkpoint.
+func openSource(src graph.QuadStore, cp *checkpoint) (graph.QuadStore, int64, func(), error) {
+	s, ok := src.(graph.Snapshotter)
+	if !ok {
+		clog.Warningf("migrate: source does not support snapshots; it must not be written to during the migration")
+		return src, 0, func() {}, nil
+	}
+	snap, err := s.Snapshot()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if cp == nil || cp.Horizon == 0 || snap.Horizon() == cp.Horizon {
+		return snap, snap.Horizon(), func() { snap.Close() }, nil
+	}
+	snap.Close()
+	if tt, ok := src.(graph.TimeTraveler); ok {
+		if snap, err = tt.AsOf(cp.Horizon); err == nil {
+			return snap, cp.Horizon, func() { snap.Close() }, nil
+		}
+	}
+	return nil, 0, nil, fmt.Errorf("migrate: source was changed since the checkpoint; remove it to start over")
+}
+
+type job struct {
+	seq  int
+	refs []graph.Value
+}
+
+type result struct {
+	seq int
+	n   int
+}
+
+// Migrate copies all quads from the source to the destination. The destination must be empty,
+// unless the migration is resumed from a checkpoint.
+func Migrate(ctx context.Context, dst, src graph.QuadStore, opts Options) (*Stats, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = quad.DefaultBatch
+	}
+	var cp *checkpoint
+	if opts.Checkpoint != "" {
+		var err error
+		if cp, err = readCheckpoint(opts.Checkpoint); os.IsNotExist(err) {
+			cp = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if cp == nil {
+		n, err := countQuads(ctx, dst, 1)
+		if err != nil {
+			return nil, err
+		} else if n != 0 {
+			return nil, fmt.Errorf("migrate: destination is not empty")
+		}
+	}
+	src, horizon, closeSrc, err := openSource(src, cp)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSrc()
+	st := &Stats{}
+	if cp != nil {
+		st.Resumed = cp.Done
+		clog.Infof("migrate: resuming after %d quads", cp.Done)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		jobs    = make(chan job, opts.Workers)
+		results = make(chan result, opts.Workers)
+		errs    = make(chan error, opts.Workers+2)
+		scanned int64
+	)
+	// scan the source and skip quads migrated before the checkpoint
+	go func() {
+		defer close(jobs)
+		it := src.QuadsAllIterator()
+		defer it.Close()
+		var (
+			seq  int
+			refs []graph.Value
+		)
+		send := func() bool {
+			select {
+			case jobs <- job{seq: seq, refs: refs}:
+			case <-ctx.Done():
+				return false
+			}
+			seq++
+			refs = nil
+			return true
+		}
+		for it.Next(ctx) {
+			if scanned++; scanned <= st.Resumed {
+				continue
+			}
+			refs = append(refs, it.Result())
+			if len(refs) >= opts.Batch && !send() {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			errs <- err
+			return
+		}
+		if len(refs) > 0 {
+			send()
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				deltas := make([]graph.Delta, 0, len(j.refs))
+				for _, r := range j.refs {
+					q := src.Quad(r)
+					if !q.IsValid() {
+						continue
+					}
+					deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+				}
+				// quads written before an interruption may be written again
+				if err := dst.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true}); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				select {
+				case results <- result{seq: j.seq, n: len(j.refs)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// batches complete out of order; the checkpoint only includes the ones without gaps before them
+	var (
+		next    int
+		pending = make(map[int]int)
+	)
+	done := st.Resumed
+	for r := range results {
+		pending[r.seq] = r.n
+		advanced := false
+		for n, ok := pending[next]; ok; n, ok = pending[next] {
+			delete(pending, next)
+			done += int64(n)
+			next++
+			advanced = true
+		}
+		if !advanced {
+			continue
+		}
+		if opts.Checkpoint != "" {
+			if err := writeCheckpoint(opts.Checkpoint, checkpoint{Horizon: horizon, Done: done}); err != nil {
+				cancel()
+				errs <- err
+				break
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(done)
+		}
+	}
+	for range results {
+	}
+	select {
+	case err := <-errs:
+		return st, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return st, err
+	}
+	st.Quads = scanned
+	n, err := countQuads(ctx, dst, -1)
+	if err != nil {
+		return st, err
+	} else if n != scanned {
+		return st, fmt.Errorf("migrate: destination has %d quads, expected %d", n, scanned)
+	}
+	if opts.Checkpoint != "" {
+		if err = os.Remove(opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// countQuads counts quads in a quad store, up to a given limit, if it's positive.
+func countQuads(ctx context.Context, qs graph.QuadStore, limit int64) (int64, error) {
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	var n int64
+	for (limit <= 0 || n < limit) && it.Next(ctx) {
+		n++
+	}
+	return n, it.Err()
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+func newKV(t testing.TB) graph.QuadStore {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	return qs
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.TODO()
+	var quads []quad.Quad
+	for i := 0; i < 50; i++ {
+		quads = append(quads, quad.Make(quad.IRI("n"), quad.IRI("p"), quad.Int(i), nil))
+	}
+	quads = append(quads, quad.Make("a", "follows", "b", "g"))
+	src := memstore.New(quads...)
+
+	dst := newKV(t)
+	defer dst.Close()
+	var last int64
+	st, err := Migrate(ctx, dst, src, Options{Workers: 3, Batch: 4, Progress: func(n int64) { last = n }})
+	require.NoError(t, err)
+	require.Equal(t, &Stats{Quads: 51}, st)
+	require.Equal(t, int64(51), last)
+	graphtest.ExpectIteratedQuads(t, dst, dst.QuadsAllIterator(), quads, true)
+
+	// the destination must be empty
+	_, err = Migrate(ctx, dst, src, Options{})
+	require.Error(t, err)
+}
+
+func TestMigrateResume(t *testing.T) {
+	ctx := context.TODO()
+	dir, err := ioutil.TempDir("", "cayley-migrate-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cpath := filepath.Join(dir, "checkpoint.json")
+
+	quads := []quad.Quad{
+		quad.Make("a", "follows", "b", nil),
+		quad.Make("b", "follows", "c", nil),
+		quad.Make("c", "follows", "a", nil),
+	}
+	src := memstore.New(quads...)
+	dst := newKV(t)
+	defer dst.Close()
+	// the first quad was written before an interruption
+	require.NoError(t, dst.ApplyDeltas([]graph.Delta{{Quad: quads[0], Action: graph.Add}}, graph.IgnoreOpts{}))
+	require.NoError(t, writeCheckpoint(cpath, checkpoint{Done: 1}))
+
+	st, err := Migrate(ctx, dst, src, Options{Batch: 1, Checkpoint: cpath})
+	require.NoError(t, err)
+	require.Equal(t, &Stats{Quads: 3, Resumed: 1}, st)
+	graphtest.ExpectIteratedQuads(t, dst, dst.QuadsAllIterator(), quads, true)
+	_, err = os.Stat(cpath)
+	require.True(t, os.IsNotExist(err))
+}
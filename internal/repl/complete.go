@@ -0,0 +1,177 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+// predicateScan is the maximal number of quads scanned to find predicates for completion.
+const predicateScan = 10000
+
+// commands are the names of REPL commands for completion.
+var commands = []string{":a", ":d", ":debug", ":lang", "exit", "help"}
+
+type completer struct {
+	qs graph.QuadStore
+
+	globals, methods []string
+
+	once  sync.Once
+	preds []string
+}
+
+// setSession sets the names that are completed outside of strings.
+func (c *completer) setSession(ses query.Session) {
+	c.globals, c.methods = nil, nil
+	if cs, ok := ses.(query.Completer); ok {
+		c.globals, c.methods = cs.Completions()
+	}
+}
+
+// predicates returns names of predicates, as they are written in a query string.
+// They are loaded once from the first quads of the store.
+func (c *completer) predicates() []string {
+	c.once.Do(func() {
+		if c.qs == nil {
+			return
+		}
+		it := c.qs.QuadsAllIterator()
+		defer it.Close()
+		ctx := context.TODO()
+		seen := make(map[string]struct{})
+		for n := 0; n < predicateScan && it.Next(ctx); n++ {
+			var s string
+			switch v := c.qs.NameOf(c.qs.QuadDirection(it.Result(), quad.Predicate)).(type) {
+			case nil:
+				continue
+			case quad.String:
+				s = string(v)
+			default:
+				s = v.String()
+			}
+			if _, ok := seen[s]; !ok {
+				seen[s] = struct{}{}
+				c.preds = append(c.preds, s)
+			}
+		}
+		sort.Strings(c.preds)
+	})
+	return c.preds
+}
+
+// complete implements liner.WordCompleter. It completes commands at the start of the line,
+// predicates inside strings, methods after a dot and global names otherwise.
+func (c *completer) complete(line string, pos int) (head string, completions []string, tail string) {
+	if pos > len(line) {
+		pos = len(line)
+	}
+	before, tail := line[:pos], line[pos:]
+	if q := openQuote(before); q >= 0 {
+		return before[:q+1], withPrefix(c.predicates(), before[q+1:]), tail
+	}
+	start := pos
+	for start > 0 && isIdent(before[start-1]) {
+		start--
+	}
+	head, word := before[:start], before[start:]
+	if strings.TrimSpace(head) == ":" {
+		return head[:len(head)-1], withPrefix(commands, ":"+word), tail
+	}
+	var names []string
+	switch {
+	case strings.HasSuffix(head, "."):
+		names = c.methods
+	case strings.TrimSpace(head) == "":
+		names = append(append([]string{}, commands...), c.globals...)
+	default:
+		names = c.globals
+	}
+	return head, withPrefix(names, word), tail
+}
+
+// openQuote returns the position of a quote that starts an unterminated string, or -1.
+func openQuote(s string) int {
+	q := -1
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case q >= 0 && ch == '\\':
+			i++
+		case q >= 0 && ch == quote:
+			q = -1
+		case q < 0 && (ch == '"' || ch == '\''):
+			q, quote = i, ch
+		}
+	}
+	return q
+}
+
+func isIdent(ch byte) bool {
+	return ch == '_' || ch == '$' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
+
+func withPrefix(names []string, prefix string) []string {
+	var out []string
+	for _, s := range names {
+		if strings.HasPrefix(s, prefix) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// incomplete checks if the code has unclosed brackets or strings, so more input is required.
+func incomplete(code string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(code); i++ {
+		ch := code[i]
+		if quote != 0 {
+			switch ch {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+		switch ch {
+		case '"', '\'', '`':
+			quote = ch
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '/':
+			// skip line comments
+			if i+1 < len(code) && code[i+1] == '/' {
+				if j := strings.IndexByte(code[i:], '\n'); j >= 0 {
+					i += j
+				} else {
+					i = len(code)
+				}
+			}
+		}
+	}
+	return depth > 0 || quote == '`'
+}
@@ -22,6 +22,7 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -81,140 +82,241 @@ const (
 	history = ".cayley_history"
 )
 
-func Repl(ctx context.Context, h *graph.Handle, queryLanguage string, timeout time.Duration) error {
-	if queryLanguage == "" {
-		queryLanguage = defaultLanguage
+// Options configures a REPL.
+type Options struct {
+	// Language is the initial query language. Defaults to Gizmo.
+	Language string
+	// Timeout of a single query. Queries have no timeout if it's not set.
+	Timeout time.Duration
+	// History is a path of the history file. Defaults to .cayley_history in the home directory.
+	// The history is not saved if it's set to "-".
+	History string
+}
+
+// DefaultHistory returns the default path of the history file.
+func DefaultHistory() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, history)
 	}
-	l := query.GetLanguage(queryLanguage)
+	return history
+}
+
+func newSession(h *graph.Handle, lang string) (query.REPLSession, error) {
+	l := query.GetLanguage(lang)
 	if l == nil || l.REPL == nil {
-		return fmt.Errorf("unsupported query language: %q", queryLanguage)
+		return nil, fmt.Errorf("unsupported query language: %q", lang)
 	}
-	ses := l.REPL(h.QuadStore)
+	return l.REPL(h.QuadStore), nil
+}
 
-	term, err := terminal(history)
-	if os.IsNotExist(err) {
-		fmt.Printf("creating new history file: %q\n", history)
+// Repl runs an interactive session on the terminal until the input ends or the context is cancelled.
+//
+// A query continues on the next line if it has unclosed brackets, if the line ends with a backslash,
+// or if the language requests more input. Ctrl-C discards the current input or interrupts a running query.
+func Repl(ctx context.Context, h *graph.Handle, opts Options) error {
+	if opts.Language == "" {
+		opts.Language = defaultLanguage
+	}
+	ses, err := newSession(h, opts.Language)
+	if err != nil {
+		return err
+	}
+	if opts.History == "" {
+		opts.History = DefaultHistory()
 	}
-	defer persist(term, history)
-
-	var (
-		prompt = ps1
-
-		code string
-	)
 
-	newCtx := func() (context.Context, func()) { return ctx, func() {} }
-	if timeout > 0 {
-		newCtx = func() (context.Context, func()) { return context.WithTimeout(ctx, timeout) }
+	term := liner.NewLiner()
+	defer term.Close()
+	term.SetCtrlCAborts(true)
+	if opts.History != "-" {
+		if err := readHistory(term, opts.History); os.IsNotExist(err) {
+			fmt.Printf("creating new history file: %q\n", opts.History)
+		} else if err != nil {
+			clog.Warningf("could not read history: %v", err)
+		}
+		defer func() {
+			if err := writeHistory(term, opts.History); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
 	}
+	comp := &completer{qs: h.QuadStore}
+	comp.setSession(ses)
+	term.SetWordCompleter(comp.complete)
 
+	var lines []string
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
-		if len(code) == 0 {
-			prompt = ps1
-		} else {
+		prompt := ps1
+		if len(lines) != 0 {
 			prompt = ps2
 		}
 		line, err := term.Prompt(prompt)
-		if err != nil {
-			if err == io.EOF {
-				fmt.Println()
-				return nil
-			}
-			return err
-		}
-
-		term.AppendHistory(line)
-
-		line = strings.TrimSpace(line)
-		if len(line) == 0 || line[0] == '#' {
+		if err == liner.ErrPromptAborted {
+			lines = nil
 			continue
+		} else if err == io.EOF {
+			fmt.Println()
+			return nil
+		} else if err != nil {
+			return err
 		}
 
-		if code == "" {
+		if len(lines) == 0 {
+			line = strings.TrimSpace(line)
+			if len(line) == 0 || line[0] == '#' {
+				continue
+			}
 			cmd, args := splitLine(line)
-
-			switch cmd {
-			case ":debug":
-				args = strings.TrimSpace(args)
-				var debug bool
-				switch args {
-				case "t":
-					debug = true
-				case "f":
-					// Do nothing.
-				default:
-					debug, err = strconv.ParseBool(args)
-					if err != nil {
-						fmt.Printf("Error: cannot parse %q as a valid boolean - acceptable values: 't'|'true' or 'f'|'false'\n", args)
-						continue
-					}
-				}
-				if debug {
-					clog.SetV(2)
+			if cmd == ":lang" {
+				term.AppendHistory(line)
+				if lang := strings.TrimSpace(args); lang == "" {
+					fmt.Printf("Language: %s\nAvailable: %s\n", opts.Language, strings.Join(replLanguages(), ", "))
+				} else if nses, err := newSession(h, lang); err != nil {
+					fmt.Println("Error: ", err)
 				} else {
-					clog.SetV(0)
-				}
-				fmt.Printf("Debug set to %t\n", debug)
-				continue
-
-			case ":a":
-				quad, err := nquads.Parse(args)
-				if err == nil {
-					err = h.QuadWriter.AddQuad(quad)
-				}
-				if err != nil {
-					fmt.Printf("Error: not a valid quad: %v\n", err)
-					continue
-				}
-				continue
-
-			case ":d":
-				quad, err := nquads.Parse(args)
-				if err != nil {
-					fmt.Printf("Error: not a valid quad: %v\n", err)
-					continue
-				}
-				err = h.QuadWriter.RemoveQuad(quad)
-				if err != nil {
-					fmt.Printf("error deleting: %v\n", err)
+					ses, opts.Language = nses, lang
+					comp.setSession(ses)
+					fmt.Printf("Language set to %s\n", lang)
 				}
 				continue
-
-			case "help":
-				fmt.Printf("Help\n\texit // Exit\n\thelp // this help\n\td: <quad> // delete quad\n\ta: <quad> // add quad\n\t:debug [t|f]\n")
+			} else if cmd == "exit" {
+				return nil
+			} else if command(h, cmd, args) {
+				term.AppendHistory(line)
 				continue
-
-			case "exit":
-				term.Close()
-				os.Exit(0)
-
-			default:
-				if cmd[0] == ':' {
-					fmt.Printf("Unknown command: %q\n", cmd)
-					continue
-				}
 			}
 		}
 
-		code += line
+		if strings.HasSuffix(line, "\\") {
+			lines = append(lines, strings.TrimSuffix(line, "\\"))
+			continue
+		}
+		lines = append(lines, line)
+		code := strings.Join(lines, "\n")
+		if incomplete(code) {
+			continue
+		}
 
-		nctx, cancel := newCtx()
-		err = Run(nctx, code, ses)
-		cancel()
+		err = runInterruptible(ctx, code, ses, opts.Timeout)
 		if err == query.ErrParseMore {
 			// collect more input
-		} else if err != nil {
+			continue
+		}
+		// the history file is line-based, so multi-line queries are saved as a single line
+		term.AppendHistory(historyEntry(lines))
+		lines = nil
+		if err != nil {
 			fmt.Println("Error: ", err)
-			code = ""
+		}
+	}
+}
+
+// command runs a REPL command. It returns false if the line is not a command.
+func command(h *graph.Handle, cmd, args string) bool {
+	switch cmd {
+	case ":debug":
+		args = strings.TrimSpace(args)
+		var debug bool
+		switch args {
+		case "t":
+			debug = true
+		case "f":
+			// Do nothing.
+		default:
+			var err error
+			debug, err = strconv.ParseBool(args)
+			if err != nil {
+				fmt.Printf("Error: cannot parse %q as a valid boolean - acceptable values: 't'|'true' or 'f'|'false'\n", args)
+				return true
+			}
+		}
+		if debug {
+			clog.SetV(2)
 		} else {
-			code = ""
+			clog.SetV(0)
+		}
+		fmt.Printf("Debug set to %t\n", debug)
+
+	case ":a":
+		quad, err := nquads.Parse(args)
+		if err == nil {
+			err = h.QuadWriter.AddQuad(quad)
 		}
+		if err != nil {
+			fmt.Printf("Error: not a valid quad: %v\n", err)
+		}
+
+	case ":d":
+		quad, err := nquads.Parse(args)
+		if err != nil {
+			fmt.Printf("Error: not a valid quad: %v\n", err)
+			return true
+		}
+		err = h.QuadWriter.RemoveQuad(quad)
+		if err != nil {
+			fmt.Printf("error deleting: %v\n", err)
+		}
+
+	case "help":
+		fmt.Printf("Help\n\texit // Exit\n\thelp // this help\n\t:d <quad> // delete quad\n\t:a <quad> // add quad\n" +
+			"\t:debug [t|f]\n\t:lang [name] // show or switch the query language\n" +
+			"\tEnd a line with \\ to continue the query on the next line, press Tab to complete names\n")
+
+	default:
+		if cmd[0] == ':' {
+			fmt.Printf("Unknown command: %q\n", cmd)
+			return true
+		}
+		return false
 	}
+	return true
+}
+
+// runInterruptible runs a query with a timeout and cancels it on interrupt.
+func runInterruptible(ctx context.Context, code string, ses query.REPLSession, timeout time.Duration) error {
+	var cancel func()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return Run(ctx, code, ses)
+}
+
+func replLanguages() []string {
+	var out []string
+	for _, name := range query.Languages() {
+		if l := query.GetLanguage(name); l != nil && l.REPL != nil {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func historyEntry(lines []string) string {
+	parts := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			parts = append(parts, l)
+		}
+	}
+	return strings.Join(parts, " ")
 }
 
 // Splits a line into a command and its arguments
@@ -237,41 +339,28 @@ func splitLine(line string) (string, string) {
 	return command, arguments
 }
 
-func terminal(path string) (*liner.State, error) {
-	term := liner.NewLiner()
-
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, os.Kill)
-		<-c
-
-		err := persist(term, history)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to properly clean up terminal: %v\n", err)
-			os.Exit(1)
-		}
-
-		os.Exit(0)
-	}()
-
+func readHistory(term *liner.State, path string) error {
 	f, err := os.Open(path)
 	if err != nil {
-		return term, err
+		return err
 	}
 	defer f.Close()
 	_, err = term.ReadHistory(f)
-	return term, err
+	return err
 }
 
-func persist(term *liner.State, path string) error {
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+// writeHistory replaces the history file with the history of the terminal, which includes the previous one.
+func writeHistory(term *liner.State, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
 	if err != nil {
-		return fmt.Errorf("could not open %q to append history: %v", path, err)
+		return fmt.Errorf("could not open %q to write history: %v", path, err)
 	}
-	defer f.Close()
 	_, err = term.WriteHistory(f)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
 	if err != nil {
 		return fmt.Errorf("could not write history to %q: %v", path, err)
 	}
-	return term.Close()
+	return nil
 }
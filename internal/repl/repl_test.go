@@ -15,6 +15,7 @@
 package repl
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -64,3 +65,57 @@ func TestSplitLines(t *testing.T) {
 		}
 	}
 }
+
+func TestComplete(t *testing.T) {
+	c := &completer{
+		globals: []string{"g", "graph", "geo"},
+		methods: []string{"Has", "In", "Out", "OutPredicates"},
+		preds:   []string{"<follows>", "<status>", "name"},
+	}
+	c.once.Do(func() {})
+	for _, tc := range []struct {
+		line  string
+		pos   int
+		head  string
+		words []string
+		tail  string
+	}{
+		{line: "g", head: "", words: []string{"g", "graph", "geo"}},
+		{line: "gr", head: "", words: []string{"graph"}},
+		{line: ":d", head: "", words: []string{":d", ":debug"}},
+		{line: "g.V().Ou", head: "g.V().", words: []string{"Out", "OutPredicates"}},
+		{line: `g.V().Out("<f`, head: `g.V().Out("`, words: []string{"<follows>"}},
+		{line: `g.V().Out('n`, head: `g.V().Out('`, words: []string{"name"}},
+		{line: `g.V("<a>").Out(`, head: `g.V("<a>").Out(`, words: []string{"g", "graph", "geo"}},
+		{line: `g.V().I().All()`, pos: 7, head: "g.V().", words: []string{"In"}, tail: "().All()"},
+	} {
+		pos := tc.pos
+		if pos == 0 {
+			pos = len(tc.line)
+		}
+		head, words, tail := c.complete(tc.line, pos)
+		if head != tc.head || tail != tc.tail || !reflect.DeepEqual(words, tc.words) {
+			t.Errorf("%q: got %q %q %q, expected %q %q %q", tc.line, head, words, tail, tc.head, tc.words, tc.tail)
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	for _, tc := range []struct {
+		code string
+		more bool
+	}{
+		{code: "g.V().All()"},
+		{code: "g.V(", more: true},
+		{code: "g.V()\n.Out(\"<follows>\")\n.All()"},
+		{code: "g.V().Out(\")\")"},
+		{code: "g.V() // not closed (", more: false},
+		{code: "var x = {\n a: 1,", more: true},
+		{code: "`multi\nline", more: true},
+		{code: "(? a <b> c)"},
+	} {
+		if got := incomplete(tc.code); got != tc.more {
+			t.Errorf("%q: got %v, expected %v", tc.code, got, tc.more)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2019 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// DefaultTopPredicates is the default number of predicates kept in statistics for the optimizer.
+const DefaultTopPredicates = 100
+
+// Analyze computes statistics of a quad store for the optimizer with a single scan of all quads.
+// It keeps up to a given number of the most frequent predicates.
+func Analyze(ctx context.Context, qs graph.QuadStore, top int) (*graph.Statistics, error) {
+	st := &graph.Statistics{Time: time.Now()}
+	if h, ok := qs.(horizoner); ok {
+		// writes made during the scan are counted as changes since the analysis
+		st.Horizon = h.Horizon()
+	}
+	var counts [4]map[interface{}]int64
+	for i := range counts {
+		counts[i] = make(map[interface{}]int64)
+	}
+	preds := make(map[interface{}]graph.Value)
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	for it.Next(ctx) {
+		st.Quads++
+		q := it.Result()
+		for i, d := range quad.Directions {
+			v := qs.QuadDirection(q, d)
+			if v == nil {
+				continue
+			}
+			k := graph.ToKey(v)
+			counts[i][k]++
+			if d == quad.Predicate && counts[i][k] == 1 {
+				preds[k] = v
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	for i, m := range counts {
+		for _, n := range m {
+			st.Directions[i].Add(n)
+		}
+	}
+	pm := counts[quad.Predicate-quad.Subject]
+	st.Predicates = make([]graph.PredicateStatistics, 0, len(preds))
+	for k, v := range preds {
+		st.Predicates = append(st.Predicates, graph.PredicateStatistics{
+			Predicate: quad.StringOf(qs.NameOf(v)), Quads: pm[k],
+		})
+	}
+	sort.Slice(st.Predicates, func(i, j int) bool {
+		a, b := st.Predicates[i], st.Predicates[j]
+		if a.Quads != b.Quads {
+			return a.Quads > b.Quads
+		}
+		return a.Predicate < b.Predicate
+	})
+	if top > 0 && len(st.Predicates) > top {
+		st.Predicates = st.Predicates[:top]
+	}
+	return st, nil
+}
+
+type horizoner interface {
+	Horizon() int64
+}
+
+// Changed estimates the number of quads changed since statistics were computed. Quad stores that report
+// their horizon count all added quads and nodes, others only count the difference in size.
+func Changed(qs graph.QuadStore, st *graph.Statistics) int64 {
+	if h, ok := qs.(horizoner); ok && st.Horizon > 0 {
+		return h.Horizon() - st.Horizon
+	}
+	n := qs.Size() - st.Quads
+	if n < 0 {
+		n = -n
+	}
+	return n
+}
+
+// stale checks if a quad store must be analyzed again, because more than a given fraction of quads changed.
+func stale(qs graph.QuadStore, st *graph.Statistics, ratio float64) bool {
+	if st == nil {
+		return qs.Size() > 0
+	}
+	quads := st.Quads
+	if quads < 1 {
+		quads = 1
+	}
+	return float64(Changed(qs, st)) >= ratio*float64(quads)
+}
+
+// RunAnalyzer checks a quad store once per interval and analyzes it again when a given fraction of quads
+// changed since the last analysis, until the context is cancelled. Errors are logged and the check is
+// retried on the next tick.
+func RunAnalyzer(ctx context.Context, qs graph.QuadStore, s graph.StatisticsStore, ratio float64, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if stale(qs, s.Statistics(), ratio) {
+			start := time.Now()
+			st, err := Analyze(ctx, qs, DefaultTopPredicates)
+			if err == nil {
+				err = s.SetStatistics(ctx, st)
+			}
+			if err != nil && ctx.Err() == nil {
+				clog.Errorf("stats: analyze failed: %v", err)
+			} else if err == nil {
+				clog.Infof("stats: analyzed %d quads in %v", st.Quads, time.Since(start))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
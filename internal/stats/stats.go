@@ -0,0 +1,206 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stats computes statistics of a quad store.
+//
+// The number of quads per predicate requires a scan of all quads. Degrees of nodes are computed from sizes
+// of quad iterators, which most backends read from their indexes without loading the quads.
+package stats
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Options configures statistics.
+type Options struct {
+	// Degrees enables the distribution of node degrees. It requires a lookup per node.
+	Degrees bool
+}
+
+// Predicate is the number of quads with a given predicate.
+type Predicate struct {
+	Predicate quad.Value
+	Quads     int64
+}
+
+// Graph is the number of quads with a given label. Quads without a label have a nil label.
+type Graph struct {
+	Label quad.Value
+	Quads int64
+}
+
+// Degrees is a summary of a distribution of node degrees.
+type Degrees struct {
+	Min, Max      int64
+	Mean          float64
+	P50, P90, P99 int64
+}
+
+// Stats describes a quad store.
+type Stats struct {
+	// Size is the number of quads reported by the quad store; it might be an estimate.
+	Size  int64
+	Quads int64
+	Nodes int64
+	// Predicates are sorted by the number of quads, in descending order.
+	Predicates []Predicate
+	// Graphs are sorted by the number of quads, in descending order, like predicates.
+	Graphs []Graph
+	// Out and In are distributions of outgoing and incoming links of nodes. They are set only if requested.
+	Out, In *Degrees
+	// Indexes are set if the quad store implements graph.IndexStatser.
+	Indexes []graph.IndexStats
+}
+
+// Compute returns statistics of a quad store.
+func Compute(ctx context.Context, qs graph.QuadStore, opts Options) (*Stats, error) {
+	st := &Stats{Size: qs.Size()}
+	if err := st.scanQuads(ctx, qs); err != nil {
+		return nil, err
+	}
+	if err := st.scanNodes(ctx, qs, opts.Degrees); err != nil {
+		return nil, err
+	}
+	if s, ok := qs.(graph.IndexStatser); ok {
+		ind, err := s.IndexStats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		st.Indexes = ind
+	}
+	return st, nil
+}
+
+func (st *Stats) scanQuads(ctx context.Context, qs graph.QuadStore) error {
+	type pred struct {
+		ref graph.Value
+		n   int64
+	}
+	preds := make(map[interface{}]*pred)
+	labels := make(map[interface{}]*pred)
+	count := func(m map[interface{}]*pred, ref graph.Value) {
+		var k interface{}
+		if ref != nil {
+			k = graph.ToKey(ref)
+		}
+		p := m[k]
+		if p == nil {
+			p = &pred{ref: ref}
+			m[k] = p
+		}
+		p.n++
+	}
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	for it.Next(ctx) {
+		st.Quads++
+		count(preds, qs.QuadDirection(it.Result(), quad.Predicate))
+		count(labels, qs.QuadDirection(it.Result(), quad.Label))
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	st.Predicates = make([]Predicate, 0, len(preds))
+	for _, p := range preds {
+		st.Predicates = append(st.Predicates, Predicate{Predicate: qs.NameOf(p.ref), Quads: p.n})
+	}
+	sort.Slice(st.Predicates, func(i, j int) bool {
+		a, b := st.Predicates[i], st.Predicates[j]
+		if a.Quads != b.Quads {
+			return a.Quads > b.Quads
+		}
+		return quad.StringOf(a.Predicate) < quad.StringOf(b.Predicate)
+	})
+	st.Graphs = make([]Graph, 0, len(labels))
+	for _, p := range labels {
+		g := Graph{Quads: p.n}
+		if p.ref != nil {
+			g.Label = qs.NameOf(p.ref)
+		}
+		st.Graphs = append(st.Graphs, g)
+	}
+	sort.Slice(st.Graphs, func(i, j int) bool {
+		a, b := st.Graphs[i], st.Graphs[j]
+		if a.Quads != b.Quads {
+			return a.Quads > b.Quads
+		}
+		return quad.StringOf(a.Label) < quad.StringOf(b.Label)
+	})
+	return nil
+}
+
+func (st *Stats) scanNodes(ctx context.Context, qs graph.QuadStore, degrees bool) error {
+	var out, in []int64
+	it := qs.NodesAllIterator()
+	defer it.Close()
+	for it.Next(ctx) {
+		st.Nodes++
+		if !degrees {
+			continue
+		}
+		n, err := degree(ctx, qs, quad.Subject, it.Result())
+		if err != nil {
+			return err
+		}
+		out = append(out, n)
+		if n, err = degree(ctx, qs, quad.Object, it.Result()); err != nil {
+			return err
+		}
+		in = append(in, n)
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if degrees {
+		st.Out, st.In = summary(out), summary(in)
+	}
+	return nil
+}
+
+// degree returns the number of quads with a node in a given direction.
+func degree(ctx context.Context, qs graph.QuadStore, d quad.Direction, v graph.Value) (int64, error) {
+	it := qs.QuadIterator(d, v)
+	defer it.Close()
+	if n, exact := it.Size(); exact {
+		return n, nil
+	}
+	var n int64
+	for it.Next(ctx) {
+		n++
+	}
+	return n, it.Err()
+}
+
+func summary(vals []int64) *Degrees {
+	d := &Degrees{}
+	if len(vals) == 0 {
+		return d
+	}
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	var sum int64
+	for _, v := range vals {
+		sum += v
+	}
+	at := func(p float64) int64 {
+		return vals[int(p*float64(len(vals)-1))]
+	}
+	d.Min, d.Max = vals[0], vals[len(vals)-1]
+	d.Mean = float64(sum) / float64(len(vals))
+	d.P50, d.P90, d.P99 = at(0.5), at(0.9), at(0.99)
+	return d
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func TestCompute(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	w, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("a", "follows", "b", ""),
+		quad.MakeIRI("a", "follows", "c", ""),
+		quad.MakeIRI("b", "follows", "c", ""),
+		quad.MakeIRI("a", "status", "cool", ""),
+	}))
+
+	st, err := Compute(ctx, qs, Options{Degrees: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), st.Quads)
+	require.Equal(t, int64(4), st.Size)
+	// a, b, c, cool, follows, status
+	require.Equal(t, int64(6), st.Nodes)
+	require.Equal(t, []Predicate{
+		{Predicate: quad.IRI("follows"), Quads: 3},
+		{Predicate: quad.IRI("status"), Quads: 1},
+	}, st.Predicates)
+	require.Equal(t, []Graph{{Quads: 4}}, st.Graphs)
+	require.Equal(t, int64(3), st.Out.Max)
+	require.Equal(t, int64(0), st.Out.Min)
+	require.Equal(t, int64(2), st.In.Max)
+	require.InDelta(t, 4.0/6, st.In.Mean, 1e-9)
+
+	names := make(map[string]int64)
+	for _, ind := range st.Indexes {
+		names[ind.Name] = ind.Entries
+	}
+	// the log has an entry per node and per quad
+	require.Equal(t, int64(10), names["log"])
+	require.Equal(t, int64(6), names["nodes"])
+	// there is an index entry per distinct subject
+	require.Equal(t, int64(2), names["s"])
+
+	st, err = Compute(ctx, qs, Options{})
+	require.NoError(t, err)
+	require.Nil(t, st.Out)
+}
+
+func TestComputeGraphs(t *testing.T) {
+	qs := memstore.New(
+		quad.MakeIRI("a", "follows", "b", "g1"),
+		quad.MakeIRI("b", "follows", "c", "g1"),
+		quad.MakeIRI("a", "status", "cool", "g2"),
+		quad.MakeIRI("c", "status", "cool", ""),
+	)
+	st, err := Compute(context.TODO(), qs, Options{})
+	require.NoError(t, err)
+	require.Equal(t, []Graph{
+		{Label: quad.IRI("g1"), Quads: 2},
+		{Quads: 1},
+		{Label: quad.IRI("g2"), Quads: 1},
+	}, st.Graphs)
+
+	st, err = Compute(context.TODO(), named.New(qs, quad.IRI("g1")), Options{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), st.Quads)
+	require.Equal(t, []Predicate{{Predicate: quad.IRI("follows"), Quads: 2}}, st.Predicates)
+}
+
+func TestAnalyze(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	w, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("a", "follows", "b", ""),
+		quad.MakeIRI("a", "follows", "c", ""),
+		quad.MakeIRI("b", "follows", "c", ""),
+		quad.MakeIRI("a", "status", "cool", "g"),
+	}))
+	require.True(t, stale(qs, nil, 0.1))
+
+	st, err := Analyze(ctx, qs, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), st.Quads)
+	// a has 3 quads, b has 1
+	require.Equal(t, graph.ValueStatistics{Values: 2, Histogram: []int64{1, 1}}, st.Dir(quad.Subject))
+	require.Equal(t, int64(2), st.Fanout(quad.Subject))
+	require.Equal(t, int64(1), st.Dir(quad.Label).Values)
+	require.Equal(t, []graph.PredicateStatistics{{Predicate: "<follows>", Quads: 3}}, st.Predicates)
+
+	s := qs.(graph.StatisticsStore)
+	require.NoError(t, s.SetStatistics(ctx, st))
+	require.False(t, stale(qs, st, 0.5))
+	require.NoError(t, w.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("c", "follows", "d", ""),
+		quad.MakeIRI("d", "follows", "a", ""),
+	}))
+	require.True(t, stale(qs, st, 0.5))
+	require.NoError(t, qs.Close())
+
+	// statistics are loaded when the database is opened
+	qs, err = kv.New(db, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	got := graph.StatisticsOf(qs)
+	require.NotNil(t, got)
+	require.Equal(t, st.Directions, got.Directions)
+	require.Equal(t, st.Horizon, got.Horizon)
+
+	// sizes of iterators are estimated from statistics
+	it := iterator.NewLinksTo(qs, qs.NodesAllIterator(), quad.Subject)
+	defer it.Close()
+	n, _ := qs.NodesAllIterator().Size()
+	sz, exact := it.Size()
+	require.False(t, exact)
+	require.Equal(t, n*st.Fanout(quad.Subject), sz)
+}
@@ -0,0 +1,197 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+)
+
+// Vocabulary of query statistics.
+const (
+	QueryClass = quad.IRI("cayley:QueryClass")
+	// QueryClassPrefix is a prefix of IRIs of query classes; it's followed by the class id.
+	QueryClassPrefix = "cayley:query/"
+
+	Lang        = quad.IRI("cayley:lang")
+	Text        = quad.IRI("cayley:text")
+	Count       = quad.IRI("cayley:count")
+	Errors      = quad.IRI("cayley:errors")
+	Rows        = quad.IRI("cayley:rows")
+	MeanRows    = quad.IRI("cayley:meanRows")
+	MeanLatency = quad.IRI("cayley:meanLatencyMs")
+	MaxLatency  = quad.IRI("cayley:maxLatencyMs")
+	LastRun     = quad.IRI("cayley:lastRun")
+)
+
+// OtherClass is the id of a class that counts queries after the limit of classes is reached.
+const OtherClass = "other"
+
+// DefaultMaxClasses is the default number of query classes kept by QueryStats.
+const DefaultMaxClasses = 1000
+
+type queryClass struct {
+	lang, text string
+	count      int64
+	errors     int64
+	rows       int64
+	total      time.Duration
+	max        time.Duration
+	last       time.Time
+}
+
+// QueryStats counts executions, latency and rows of queries by their class.
+//
+// Queries belong to the same class if they are equal after replacing all literals with "?"
+// and collapsing whitespace, so the statistics don't depend on parameters of a query.
+type QueryStats struct {
+	max     int
+	mu      sync.Mutex
+	classes map[string]*queryClass
+}
+
+var _ Source = (*QueryStats)(nil)
+
+// NewQueryStats creates statistics that keep up to a given number of query classes.
+// Queries of other classes are counted together as OtherClass. Zero means DefaultMaxClasses.
+func NewQueryStats(max int) *QueryStats {
+	if max <= 0 {
+		max = DefaultMaxClasses
+	}
+	return &QueryStats{max: max, classes: make(map[string]*queryClass)}
+}
+
+// Record adds a single execution of a query. Failed queries are counted,
+// but their latency and rows are not.
+func (s *QueryStats) Record(lang, query string, dt time.Duration, rows int, err error) {
+	id, text := Classify(lang, query)
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.classes[id]
+	if c == nil {
+		if len(s.classes) >= s.max {
+			id, lang, text = OtherClass, "", ""
+			c = s.classes[id]
+		}
+		if c == nil {
+			c = &queryClass{lang: lang, text: text}
+			s.classes[id] = c
+		}
+	}
+	c.count++
+	c.last = now
+	if err != nil {
+		c.errors++
+		return
+	}
+	c.rows += int64(rows)
+	c.total += dt
+	if dt > c.max {
+		c.max = dt
+	}
+}
+
+func ms(d time.Duration) quad.Float {
+	return quad.Float(float64(d) / float64(time.Millisecond))
+}
+
+// SystemQuads returns a node for each query class.
+func (s *QueryStats) SystemQuads() []quad.Quad {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]quad.Quad, 0, len(s.classes)*11)
+	for id, c := range s.classes {
+		n := quad.IRI(QueryClassPrefix + id)
+		add := func(p quad.IRI, v quad.Value) {
+			out = append(out, quad.Quad{Subject: n, Predicate: p, Object: v})
+		}
+		add(quad.IRI(rdf.Type), QueryClass)
+		if c.lang != "" {
+			add(Lang, quad.String(c.lang))
+			add(Text, quad.String(c.text))
+		}
+		add(Count, quad.Int(c.count))
+		add(Errors, quad.Int(c.errors))
+		add(Rows, quad.Int(c.rows))
+		add(LastRun, quad.Time(c.last))
+		if ok := c.count - c.errors; ok > 0 {
+			add(MeanRows, quad.Float(float64(c.rows)/float64(ok)))
+			add(MeanLatency, ms(c.total/time.Duration(ok)))
+			add(MaxLatency, ms(c.max))
+		}
+	}
+	return out
+}
+
+// Classify returns the id of a class of the query and its normalized text.
+func Classify(lang, query string) (id, text string) {
+	text = normalize(query)
+	h := sha1.Sum([]byte(lang + "\x00" + text))
+	return hex.EncodeToString(h[:8]), text
+}
+
+// normalize replaces quoted strings and numbers with "?" and collapses whitespace.
+func normalize(s string) string {
+	var (
+		buf   strings.Builder
+		space bool
+		prev  rune
+	)
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case r == '"' || r == '\'' || r == '`':
+			// skip to the closing quote, respecting escapes
+			for i++; i < len(rs) && rs[i] != r; i++ {
+				if rs[i] == '\\' {
+					i++
+				}
+			}
+			r = '?'
+		case unicode.IsDigit(r) && !isIdent(prev):
+			for i+1 < len(rs) && (unicode.IsDigit(rs[i+1]) || rs[i+1] == '.') {
+				i++
+			}
+			r = '?'
+		}
+		if space && buf.Len() > 0 && !isPunct(prev) && !isPunct(r) {
+			buf.WriteByte(' ')
+		}
+		space = false
+		buf.WriteRune(r)
+		prev = r
+	}
+	return buf.String()
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isPunct(r rune) bool {
+	return r != '?' && !isIdent(r)
+}
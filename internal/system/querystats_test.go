@@ -0,0 +1,75 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	a, text := Classify("gizmo", `g.V("alice").Out( "follows" ).Limit(10).All()`)
+	require.Equal(t, `g.V(?).Out(?).Limit(?).All()`, text)
+	b, _ := Classify("gizmo", "g.V('bob').Out('likes')\n\t.Limit(2).All()")
+	require.Equal(t, a, b)
+	c, _ := Classify("graphql", `g.V("alice").Out("follows").Limit(10).All()`)
+	require.NotEqual(t, a, c)
+
+	_, text = Classify("graphql", `{ nodes(id: "<alice>") { id name: <name> } }`)
+	require.Equal(t, `{nodes(id:?){id name:<name>}}`, text)
+	_, text = Classify("gizmo", `g.V("a\"b").Has("v2", 3.5)`)
+	require.Equal(t, `g.V(?).Has(?,?)`, text)
+}
+
+func TestQueryStats(t *testing.T) {
+	st := NewQueryStats(2)
+	st.Record("gizmo", `g.V("a").All()`, 10*time.Millisecond, 2, nil)
+	st.Record("gizmo", `g.V("b").All()`, 30*time.Millisecond, 4, nil)
+	st.Record("gizmo", `g.V("b").All()`, time.Second, 0, errors.New("failed"))
+	st.Record("gizmo", `g.V().All()`, time.Millisecond, 1, nil)
+	st.Record("gizmo", `g.V().Count()`, time.Millisecond, 1, nil)
+
+	qs := New(st).QuadStore()
+	id, _ := Classify("gizmo", `g.V("a").All()`)
+	n := quad.IRI(QueryClassPrefix + id)
+	get := func(n quad.Value, p quad.IRI) quad.Value {
+		vals, err := path.StartPath(qs, n).Out(p).Iterate(context.TODO()).AllValues(qs)
+		require.NoError(t, err)
+		require.Len(t, vals, 1)
+		return vals[0]
+	}
+	require.Equal(t, quad.Int(3), get(n, Count))
+	require.Equal(t, quad.Int(1), get(n, Errors))
+	require.Equal(t, quad.Int(6), get(n, Rows))
+	require.Equal(t, quad.Float(3), get(n, MeanRows))
+	require.Equal(t, quad.Float(20), get(n, MeanLatency))
+	require.Equal(t, quad.Float(30), get(n, MaxLatency))
+	require.Equal(t, quad.String(`g.V(?).All()`), get(n, Text))
+
+	// the second class reaches the limit and the last one is counted as other
+	other := quad.IRI(QueryClassPrefix + OtherClass)
+	require.Equal(t, quad.Int(1), get(other, Count))
+
+	classes, err := path.StartPath(qs).Has(quad.IRI(rdf.Type), QueryClass).Iterate(context.TODO()).AllValues(qs)
+	require.NoError(t, err)
+	require.Len(t, classes, 3)
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/version"
+	"github.com/cayleygraph/cayley/voc"
+	"github.com/cayleygraph/cayley/voc/rdf"
+)
+
+// Vocabulary of store metadata. Properties of the store and configured limits belong to the Server node.
+const (
+	Server      = quad.IRI("cayley:server")
+	ServerClass = quad.IRI("cayley:Server")
+
+	Version     = quad.IRI("cayley:version")
+	Backend     = quad.IRI("cayley:backend")
+	Size        = quad.IRI("cayley:size")
+	Horizon     = quad.IRI("cayley:horizon")
+	DataVersion = quad.IRI("cayley:dataVersion")
+
+	// HasNamespace links the server to the full IRI of each namespace.
+	HasNamespace = quad.IRI("cayley:namespace")
+	Prefix       = quad.IRI("cayley:prefix")
+
+	ReadOnly     = quad.IRI("cayley:readOnly")
+	QueryTimeout = quad.IRI("cayley:queryTimeoutMs")
+	QueryMemory  = quad.IRI("cayley:queryMemoryBytes")
+	MemoryBudget = quad.IRI("cayley:memoryBudgetBytes")
+	BatchSize    = quad.IRI("cayley:batchSize")
+	TxTimeout    = quad.IRI("cayley:txTimeoutMs")
+)
+
+// SourceFunc is a function that implements Source.
+type SourceFunc func() []quad.Quad
+
+// SystemQuads calls the function.
+func (f SourceFunc) SystemQuads() []quad.Quad { return f() }
+
+func serverQuad(p quad.IRI, v quad.Value) quad.Quad {
+	return quad.Quad{Subject: Server, Predicate: p, Object: v}
+}
+
+// Store describes a quad store: its backend, size, horizon, data version and namespaces.
+//
+// Namespaces include both the ones registered in the server and the ones stored in the graph.
+type Store struct {
+	qs      graph.QuadStore
+	backend string
+}
+
+var _ Source = (*Store)(nil)
+
+// NewStore creates a source that describes a quad store with a given backend name.
+func NewStore(qs graph.QuadStore, backend string) *Store {
+	return &Store{qs: qs, backend: backend}
+}
+
+// SystemQuads returns properties of the Server node and a node for each namespace.
+// Properties that cannot be read from the quad store are omitted.
+func (s *Store) SystemQuads() []quad.Quad {
+	out := []quad.Quad{
+		serverQuad(quad.IRI(rdf.Type), ServerClass),
+		serverQuad(Version, quad.String(version.Version)),
+		serverQuad(Backend, quad.String(s.backend)),
+		serverQuad(Size, quad.Int(s.qs.Size())),
+	}
+	if sn, ok := s.qs.(graph.Snapshotter); ok {
+		if snap, err := sn.Snapshot(); err != nil {
+			clog.Warningf("system: cannot read the horizon: %v", err)
+		} else {
+			out = append(out, serverQuad(Horizon, quad.Int(snap.Horizon())))
+			snap.Close()
+		}
+	}
+	if v, ok := s.qs.(graph.Versioner); ok {
+		out = append(out, serverQuad(DataVersion, quad.Int(v.DataVersion())))
+	}
+	ns := voc.Clone()
+	if err := schema.LoadNamespaces(context.TODO(), s.qs, ns); err != nil {
+		clog.Warningf("system: cannot load namespaces: %v", err)
+	}
+	list := ns.List()
+	sort.Sort(voc.ByFullName(list))
+	for _, n := range list {
+		out = append(out,
+			serverQuad(HasNamespace, quad.IRI(n.Full)),
+			quad.Quad{Subject: quad.IRI(n.Full), Predicate: Prefix, Object: quad.String(n.Prefix)},
+		)
+	}
+	return out
+}
+
+// Limits are limits configured for queries and writes.
+type Limits struct {
+	ReadOnly     bool
+	QueryTimeout time.Duration
+	TxTimeout    time.Duration
+	QueryMemory  int64
+	MemoryBudget int64
+	BatchSize    int
+}
+
+// Quads returns the limits as properties of the Server node. Limits that are not set are omitted.
+func (l Limits) Quads() []quad.Quad {
+	out := []quad.Quad{serverQuad(ReadOnly, quad.Bool(l.ReadOnly))}
+	if l.QueryTimeout > 0 {
+		out = append(out, serverQuad(QueryTimeout, ms(l.QueryTimeout)))
+	}
+	if l.TxTimeout > 0 {
+		out = append(out, serverQuad(TxTimeout, ms(l.TxTimeout)))
+	}
+	if l.QueryMemory > 0 {
+		out = append(out, serverQuad(QueryMemory, quad.Int(l.QueryMemory)))
+	}
+	if l.MemoryBudget > 0 {
+		out = append(out, serverQuad(MemoryBudget, quad.Int(l.MemoryBudget)))
+	}
+	if l.BatchSize > 0 {
+		out = append(out, serverQuad(BatchSize, quad.Int(l.BatchSize)))
+	}
+	return out
+}
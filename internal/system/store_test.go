@@ -0,0 +1,69 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/voc"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func TestStore(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	w, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuad(quad.MakeIRI("a", "follows", "b", "")))
+	var ns voc.Namespaces
+	ns.Register(voc.Namespace{Prefix: "ex:", Full: "http://example.com/"})
+	bw := graph.NewWriter(w)
+	require.NoError(t, schema.WriteNamespaces(bw, &ns))
+	require.NoError(t, bw.Flush())
+
+	limits := SourceFunc(func() []quad.Quad {
+		return Limits{ReadOnly: true, QueryTimeout: time.Second}.Quads()
+	})
+	sys := New(NewStore(qs, "btree"), limits).QuadStore()
+	get := func(n quad.Value, p quad.IRI) []quad.Value {
+		vals, err := path.StartPath(sys, n).Out(p).Iterate(context.TODO()).AllValues(sys)
+		require.NoError(t, err)
+		return vals
+	}
+	require.Equal(t, []quad.Value{quad.String("btree")}, get(Server, Backend))
+	require.Equal(t, []quad.Value{quad.Int(qs.Size())}, get(Server, Size))
+	require.Len(t, get(Server, Horizon), 1)
+	require.Len(t, get(Server, DataVersion), 1)
+	require.Equal(t, []quad.Value{quad.Bool(true)}, get(Server, ReadOnly))
+	require.Equal(t, []quad.Value{quad.Float(1000)}, get(Server, QueryTimeout))
+	require.Empty(t, get(Server, QueryMemory))
+
+	// stored namespaces are listed with registered ones
+	require.Contains(t, get(Server, HasNamespace), quad.IRI("http://example.com/"))
+	require.Equal(t, []quad.Value{quad.String("ex:")}, get(quad.IRI("http://example.com/"), Prefix))
+}
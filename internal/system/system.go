@@ -0,0 +1,64 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package system builds the system graph: a read-only graph that describes the running server
+// and can be queried like any other graph.
+//
+// The graph is assembled from sources, such as query statistics, each time it's requested,
+// so queries always observe a consistent and current state.
+package system
+
+import (
+	"sync"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Source adds quads to the system graph.
+type Source interface {
+	// SystemQuads returns the current state of the source as quads.
+	SystemQuads() []quad.Quad
+}
+
+// Graph is a set of sources of the system graph.
+type Graph struct {
+	mu      sync.RWMutex
+	sources []Source
+}
+
+// New creates a system graph with given sources.
+func New(sources ...Source) *Graph {
+	return &Graph{sources: sources}
+}
+
+// Register adds a source to the graph.
+func (g *Graph) Register(s Source) {
+	g.mu.Lock()
+	g.sources = append(g.sources, s)
+	g.mu.Unlock()
+}
+
+// QuadStore returns a snapshot of the system graph.
+func (g *Graph) QuadStore() graph.QuadStore {
+	g.mu.RLock()
+	sources := append([]Source{}, g.sources...)
+	g.mu.RUnlock()
+	var quads []quad.Quad
+	for _, s := range sources {
+		quads = append(quads, s.SystemQuads()...)
+	}
+	return memstore.New(quads...)
+}
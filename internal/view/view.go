@@ -0,0 +1,254 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package view defines virtual graphs from saved queries.
+//
+// A view is a named graph that contains the subgraph matched by a query: all quads that have one of the nodes
+// in its results as a subject, relabeled with the name of the view. It's either evaluated on demand, each time it's
+// queried, or materialized: stored in the database as a normal named graph and refreshed on a schedule
+// or after the database changes.
+package view
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+// DefaultPoll is the default interval of checks for scheduled refreshes and changes of the database.
+const DefaultPoll = time.Second
+
+// Definition describes a view.
+type Definition struct {
+	// Name is a label of the view, parsed with quad.StringToValue.
+	Name  string
+	Lang  string
+	Query string
+	// Limit is the maximal number of query results the view is built from. Zero means no limit.
+	Limit int
+	// Materialized views are stored in the database. Other views are evaluated on demand.
+	Materialized bool
+	// Interval is the time between scheduled refreshes of a materialized view.
+	Interval time.Duration
+	// OnChange refreshes a materialized view after the database changes.
+	OnChange bool
+}
+
+// View is a virtual graph.
+type View struct {
+	Definition
+	label quad.Value
+	vs    *Views
+}
+
+// Label returns the label of the view.
+func (v *View) Label() quad.Value {
+	return v.label
+}
+
+// Views is a set of views of a database.
+type Views struct {
+	h     *graph.Handle
+	views map[string]*View
+	list  []*View
+}
+
+// New validates definitions of views of a database.
+func New(h *graph.Handle, defs []Definition) (*Views, error) {
+	vs := &Views{h: h, views: make(map[string]*View, len(defs))}
+	for _, d := range defs {
+		switch {
+		case d.Name == "":
+			return nil, fmt.Errorf("view: name is not set")
+		case query.GetLanguage(d.Lang) == nil:
+			return nil, fmt.Errorf("view %s: unknown query language: %q", d.Name, d.Lang)
+		case d.Query == "":
+			return nil, fmt.Errorf("view %s: query is not set", d.Name)
+		case !d.Materialized && (d.Interval != 0 || d.OnChange):
+			return nil, fmt.Errorf("view %s: only materialized views are refreshed", d.Name)
+		}
+		v := &View{Definition: d, label: quad.StringToValue(d.Name), vs: vs}
+		if _, ok := vs.views[v.label.String()]; ok {
+			return nil, fmt.Errorf("view %s: duplicate name", d.Name)
+		}
+		vs.views[v.label.String()] = v
+		vs.list = append(vs.list, v)
+	}
+	return vs, nil
+}
+
+// Get returns a view with a given label, or nil if it's not defined.
+func (vs *Views) Get(label quad.Value) *View {
+	if vs == nil || label == nil {
+		return nil
+	}
+	return vs.views[label.String()]
+}
+
+// Views returns all views in the order of their definitions.
+func (vs *Views) Views() []*View {
+	return vs.list
+}
+
+// quads runs the query of the view and returns quads of the matched subgraph with the label of the view.
+// Quads of materialized views are skipped, so views are never built from themselves or from each other.
+// Each quad is returned once.
+func (v *View) quads(ctx context.Context, qs graph.QuadStore) ([]quad.Quad, error) {
+	limit := v.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	qr, err := query.Subgraph(ctx, qs, v.Lang, v.Query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer qr.Close()
+	var out []quad.Quad
+	seen := make(map[string]struct{})
+	for {
+		q, err := qr.ReadQuad()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if w := v.vs.Get(q.Label); w != nil && w.Materialized {
+			continue
+		}
+		// the same quad might be found in different graphs
+		q.Label = v.label
+		if _, ok := seen[q.NQuad()]; ok {
+			continue
+		}
+		seen[q.NQuad()] = struct{}{}
+		out = append(out, q)
+	}
+	return out, nil
+}
+
+// Evaluate builds the view from a given quad store and returns a quad store with its quads.
+// Other graphs are not included in the result.
+func (v *View) Evaluate(ctx context.Context, qs graph.QuadStore) (graph.QuadStore, error) {
+	quads, err := v.quads(ctx, qs)
+	if err != nil {
+		return nil, err
+	}
+	return memstore.New(quads...), nil
+}
+
+// Refresh replaces quads of a materialized view in the database with a new result of its query, in a single
+// transaction. It returns the number of added and removed quads.
+func (v *View) Refresh(ctx context.Context) (int, error) {
+	if !v.Materialized {
+		return 0, fmt.Errorf("view %s is not materialized", v.Name)
+	}
+	qs := v.vs.h.QuadStore
+	quads, err := v.quads(ctx, qs)
+	if err != nil {
+		return 0, err
+	}
+	want := make(map[string]quad.Quad, len(quads))
+	for _, q := range quads {
+		want[q.NQuad()] = q
+	}
+	tx := graph.NewTransaction()
+	if ref := qs.ValueOf(v.label); ref != nil {
+		it := qs.QuadIterator(quad.Label, ref)
+		for it.Next(ctx) {
+			q := qs.Quad(it.Result())
+			if _, ok := want[q.NQuad()]; ok {
+				delete(want, q.NQuad())
+			} else {
+				tx.RemoveQuad(q)
+			}
+		}
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, q := range quads {
+		if _, ok := want[q.NQuad()]; ok {
+			tx.AddQuad(q)
+		}
+	}
+	if len(tx.Deltas) == 0 {
+		return 0, nil
+	}
+	if err = v.vs.h.QuadWriter.ApplyTransaction(tx); err != nil {
+		return 0, err
+	}
+	return len(tx.Deltas), nil
+}
+
+// changeToken returns a value that changes after writes to the database: the horizon of the backend,
+// if it's known, or the size of the database.
+func (vs *Views) changeToken() int64 {
+	if h, ok := graph.HorizonOf(vs.h.QuadStore); ok {
+		return h
+	}
+	return vs.h.QuadStore.Size()
+}
+
+// Run refreshes all materialized views, and then refreshes them on their schedules or after the database changes,
+// checking both once per poll interval. Failed refreshes are logged and retried on the next schedule.
+// It returns when the context is cancelled.
+func (vs *Views) Run(ctx context.Context, poll time.Duration) {
+	if poll <= 0 {
+		poll = DefaultPoll
+	}
+	last := make(map[*View]time.Time)
+	refresh := func(v *View) {
+		last[v] = time.Now()
+		if n, err := v.Refresh(ctx); err != nil && ctx.Err() == nil {
+			clog.Errorf("view %s: refresh failed: %v", v.Name, err)
+		} else if n != 0 {
+			clog.Infof("view %s: refreshed, %d quads changed", v.Name, n)
+		}
+	}
+	for _, v := range vs.list {
+		if v.Materialized {
+			refresh(v)
+		}
+	}
+	// changes made by refreshes don't trigger other refreshes
+	token := vs.changeToken()
+	t := time.NewTicker(poll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		changed := vs.changeToken() != token
+		for _, v := range vs.list {
+			if !v.Materialized {
+				continue
+			}
+			if (v.OnChange && changed) || (v.Interval > 0 && time.Since(last[v]) >= v.Interval) {
+				refresh(v)
+			}
+		}
+		token = vs.changeToken()
+	}
+}
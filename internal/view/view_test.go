@@ -0,0 +1,118 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package view
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func makeHandle(t testing.TB, quads ...quad.Quad) *graph.Handle {
+	qs := memstore.New(quads...)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	return &graph.Handle{QuadStore: qs, QuadWriter: qw}
+}
+
+func labelQuads(t testing.TB, qs graph.QuadStore, label quad.Value) []string {
+	var out []string
+	ref := qs.ValueOf(label)
+	if ref == nil {
+		return nil
+	}
+	it := qs.QuadIterator(quad.Label, ref)
+	defer it.Close()
+	for it.Next(context.TODO()) {
+		out = append(out, qs.Quad(it.Result()).NQuad())
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(out)
+	return out
+}
+
+const adults = `g.V().Has("<age>", "<adult>").All()`
+
+func TestEvaluate(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "age", "adult", ""),
+		quad.MakeIRI("alice", "knows", "bob", "g1"),
+		quad.MakeIRI("bob", "age", "child", ""),
+	)
+	vs, err := New(h, []Definition{{Name: "<adults>", Lang: "gizmo", Query: adults}})
+	require.NoError(t, err)
+	v := vs.Get(quad.IRI("adults"))
+	require.NotNil(t, v)
+	require.Nil(t, vs.Get(quad.IRI("g1")))
+
+	qs, err := v.Evaluate(context.TODO(), h.QuadStore)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"<alice> <age> <adult> <adults> .",
+		"<alice> <knows> <bob> <adults> .",
+	}, labelQuads(t, qs, quad.IRI("adults")))
+	_, err = v.Refresh(context.TODO())
+	require.Error(t, err)
+
+	_, err = New(h, []Definition{{Name: "<v>", Lang: "gizmo", Query: adults, OnChange: true}})
+	require.Error(t, err)
+	_, err = New(h, []Definition{{Name: "<v>", Lang: "unknown", Query: adults}})
+	require.Error(t, err)
+}
+
+func TestMaterialized(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("alice", "age", "adult", ""),
+		quad.MakeIRI("bob", "age", "child", ""),
+	)
+	vs, err := New(h, []Definition{{Name: "<adults>", Lang: "gizmo", Query: adults, Materialized: true, OnChange: true}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		vs.Run(ctx, 10*time.Millisecond)
+	}()
+	waitFor := func(exp []string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got := labelQuads(t, h.QuadStore, quad.IRI("adults"))
+			if len(got) == len(exp) || time.Now().After(deadline) {
+				require.Equal(t, exp, got)
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor([]string{"<alice> <age> <adult> <adults> ."})
+
+	require.NoError(t, h.QuadWriter.AddQuad(quad.MakeIRI("bob", "age", "adult", "")))
+	waitFor([]string{"<alice> <age> <adult> <adults> .", "<bob> <age> <adult> <adults> .", "<bob> <age> <child> <adults> ."})
+
+	require.NoError(t, h.QuadWriter.RemoveQuad(quad.MakeIRI("alice", "age", "adult", "")))
+	waitFor([]string{"<bob> <age> <adult> <adults> .", "<bob> <age> <child> <adults> ."})
+	cancel()
+	<-done
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package warmup primes caches of a quad store before a server starts accepting queries.
+//
+// The most frequent predicates are found from a sample of quads. Their values are resolved and the first quads
+// of each predicate are read, which loads value caches of the backend and pages of its indexes.
+// Configured queries are then run to completion and their results are discarded.
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+)
+
+const (
+	// DefaultSample is the default number of quads scanned to find the most frequent predicates.
+	DefaultSample = 100000
+	// DefaultQuads is the default number of quads read for each predicate.
+	DefaultQuads = 1000
+)
+
+// Query is a query run during the warm-up.
+type Query struct {
+	Lang  string `json:"lang"`
+	Query string `json:"query"`
+}
+
+// Options configures a warm-up.
+type Options struct {
+	// Top is the number of the most frequent predicates to prime.
+	Top int
+	// Sample is the number of quads scanned to find the most frequent predicates. Defaults to DefaultSample.
+	Sample int
+	// Predicates are primed in addition to the most frequent ones.
+	Predicates []quad.Value
+	// Quads is the number of quads read for each predicate. Defaults to DefaultQuads.
+	Quads int
+	// Queries are run after predicates are primed.
+	Queries []Query
+}
+
+// Stats describes a completed warm-up.
+type Stats struct {
+	Predicates int
+	Quads      int64
+	Queries    int
+	Results    int64
+}
+
+// Run primes caches of the quad store. A failed query is returned as an error after other queries are run.
+// Stats are returned with the error, unless the quad store cannot be read.
+func Run(ctx context.Context, qs graph.QuadStore, opts Options) (*Stats, error) {
+	if opts.Sample <= 0 {
+		opts.Sample = DefaultSample
+	}
+	if opts.Quads <= 0 {
+		opts.Quads = DefaultQuads
+	}
+	st := &Stats{}
+	preds, err := topPredicates(ctx, qs, opts.Top, opts.Sample)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range opts.Predicates {
+		if ref := qs.ValueOf(p); ref != nil {
+			preds = append(preds, ref)
+		}
+	}
+	seen := make(map[interface{}]struct{}, len(preds))
+	for _, p := range preds {
+		k := graph.ToKey(p)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		n, err := prime(ctx, qs, p, opts.Quads)
+		st.Quads += n
+		if err != nil {
+			return nil, err
+		}
+		st.Predicates++
+	}
+	var first error
+	for _, q := range opts.Queries {
+		n, err := runQuery(ctx, qs, q)
+		st.Results += n
+		if err != nil {
+			if ctx.Err() != nil {
+				return st, ctx.Err()
+			} else if first == nil {
+				first = fmt.Errorf("warmup: %s query %q: %v", q.Lang, q.Query, err)
+			}
+			continue
+		}
+		st.Queries++
+	}
+	return st, first
+}
+
+// topPredicates returns up to n most frequent predicates in the first quads of the quad store.
+func topPredicates(ctx context.Context, qs graph.QuadStore, n, sample int) ([]graph.Value, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	type pred struct {
+		ref graph.Value
+		n   int
+	}
+	preds := make(map[interface{}]*pred)
+	it := qs.QuadsAllIterator()
+	defer it.Close()
+	for i := 0; i < sample && it.Next(ctx); i++ {
+		ref := qs.QuadDirection(it.Result(), quad.Predicate)
+		k := graph.ToKey(ref)
+		p := preds[k]
+		if p == nil {
+			p = &pred{ref: ref}
+			preds[k] = p
+		}
+		p.n++
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	list := make([]*pred, 0, len(preds))
+	for _, p := range preds {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].n > list[j].n })
+	if len(list) > n {
+		list = list[:n]
+	}
+	out := make([]graph.Value, 0, len(list))
+	for _, p := range list {
+		out = append(out, p.ref)
+	}
+	return out, nil
+}
+
+// prime reads up to n quads with a given predicate and resolves their values.
+func prime(ctx context.Context, qs graph.QuadStore, p graph.Value, n int) (int64, error) {
+	// the name of the predicate is cached by the backend on the first lookup
+	if v := qs.NameOf(p); v != nil {
+		qs.ValueOf(v)
+	}
+	it := qs.QuadIterator(quad.Predicate, p)
+	defer it.Close()
+	it.Size()
+	var cnt int64
+	for cnt < int64(n) && it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		qs.ValueOf(q.Subject)
+		qs.ValueOf(q.Object)
+		cnt++
+	}
+	return cnt, it.Err()
+}
+
+func runQuery(ctx context.Context, qs graph.QuadStore, q Query) (int64, error) {
+	ses := query.NewSession(qs, q.Lang)
+	if ses == nil {
+		return 0, fmt.Errorf("unknown query language")
+	}
+	c := make(chan query.Result, 5)
+	go ses.Execute(ctx, q.Query, c, -1)
+	var (
+		n   int64
+		err error
+	)
+	for r := range c {
+		if e := r.Err(); e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		n++
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return n, err
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
+	"github.com/cayleygraph/cayley/quad"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	_ "github.com/cayleygraph/cayley/writer"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.TODO()
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	defer qs.Close()
+	w, err := graph.NewQuadWriter("single", qs, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.AddQuadSet([]quad.Quad{
+		quad.MakeIRI("a", "follows", "b", ""),
+		quad.MakeIRI("a", "follows", "c", ""),
+		quad.MakeIRI("b", "follows", "c", ""),
+		quad.MakeIRI("a", "status", "cool", ""),
+		quad.MakeIRI("b", "name", "bob", ""),
+	}))
+
+	st, err := Run(ctx, qs, Options{
+		Top:        1,
+		Quads:      2,
+		Predicates: []quad.Value{quad.IRI("status"), quad.IRI("follows"), quad.IRI("missing")},
+		Queries: []Query{
+			{Lang: "gizmo", Query: `g.V("<a>").Out("<follows>").All()`},
+		},
+	})
+	require.NoError(t, err)
+	// follows is the most frequent, and it's not primed twice
+	require.Equal(t, &Stats{Predicates: 2, Quads: 3, Queries: 1, Results: 2}, st)
+
+	st, err = Run(ctx, qs, Options{Queries: []Query{
+		{Lang: "gizmo", Query: `g.V(`},
+		{Lang: "unknown", Query: `x`},
+		{Lang: "gizmo", Query: `g.V("<b>").All()`},
+	}})
+	require.Error(t, err)
+	require.Equal(t, &Stats{Queries: 1, Results: 1}, st)
+}
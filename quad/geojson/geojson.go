@@ -0,0 +1,375 @@
+// Package geojson provides an encoder/decoder for GeoJSON feature collections.
+//
+// Each feature becomes a node of type geo:Feature. The geometry is stored as a WKT literal
+// in the geo:asWKT property, and each feature property becomes a predicate with the same name:
+//
+//	{"type": "Feature", "id": "sf", "geometry": {"type": "Point", "coordinates": [-122.4194, 37.7749]}, "properties": {"name": "San Francisco"}}
+//
+//	<sf> <rdf:type> <geo:Feature> .
+//	<sf> <geo:asWKT> "POINT(-122.4194 37.7749)"^^<geo:wktLiteral> .
+//	<sf> <name> "San Francisco" .
+//
+// Features without an id become blank nodes. Arrays in properties become multiple values,
+// and nested objects become blank nodes with their own properties. The writer does the opposite,
+// thus it can export any node that has a geometry.
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph/geo"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+)
+
+const (
+	// Feature is the type of feature nodes.
+	Feature = quad.IRI(geo.NS + "Feature")
+	// AsWKT is a predicate that stores the geometry of a feature as a WKT literal.
+	AsWKT = quad.IRI(geo.NS + "asWKT")
+
+	rdfType = quad.IRI(rdf.NS + "type")
+)
+
+func init() {
+	quad.RegisterFormat(quad.Format{
+		Name:   "geojson",
+		Ext:    []string{".geojson"},
+		Mime:   []string{"application/geo+json"},
+		Writer: func(w io.Writer) quad.WriteCloser { return NewWriter(w) },
+		Reader: func(r io.Reader) quad.ReadCloser { return NewReader(r) },
+	})
+}
+
+// Options configures conversion of GeoJSON to quads and back.
+type Options struct {
+	// Prefix is prepended to feature ids and property names to make IRIs of nodes and predicates.
+	// The writer removes it from IRIs.
+	Prefix string
+}
+
+type feature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   json.RawMessage        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type collection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+// NewReader returns a quad reader for a GeoJSON feature collection, or a single feature.
+func NewReader(r io.Reader) *Reader {
+	return NewReaderWith(r, Options{})
+}
+
+// NewReaderWith is the same as NewReader, but allows to set conversion options.
+func NewReaderWith(r io.Reader, opt Options) *Reader {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return &Reader{err: err}
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return &Reader{err: err}
+	}
+	var features []feature
+	switch head.Type {
+	case "FeatureCollection":
+		var c collection
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return &Reader{err: err}
+		}
+		features = c.Features
+	case "Feature":
+		var f feature
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return &Reader{err: err}
+		}
+		features = []feature{f}
+	default:
+		return &Reader{err: fmt.Errorf("geojson: expected a Feature or a FeatureCollection, got %q", head.Type)}
+	}
+	rd := &Reader{opt: opt}
+	for i, f := range features {
+		if err := rd.convert(f); err != nil {
+			return &Reader{err: fmt.Errorf("geojson: feature %d: %v", i, err)}
+		}
+	}
+	return rd
+}
+
+// Reader is a quad reader for GeoJSON. Features are converted to quads when the reader is created.
+type Reader struct {
+	opt   Options
+	quads []quad.Quad
+	n     int
+	err   error
+}
+
+func (r *Reader) convert(f feature) error {
+	if f.Type != "Feature" {
+		return fmt.Errorf("expected a Feature, got %q", f.Type)
+	}
+	var id quad.Value
+	switch v := f.ID.(type) {
+	case nil:
+		id = quad.RandomBlankNode()
+	case string:
+		id = quad.IRI(r.opt.Prefix + v)
+	case float64:
+		id = quad.IRI(r.opt.Prefix + strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("unsupported id: %v", f.ID)
+	}
+	r.add(id, rdfType, Feature)
+	g, err := decodeGeometry(f.Geometry)
+	if err != nil {
+		return err
+	}
+	if g != nil {
+		s, err := g.WKT()
+		if err != nil {
+			return err
+		}
+		r.add(id, AsWKT, quad.TypedString{Value: quad.String(s), Type: geo.WKTLiteral})
+	}
+	return r.addProperties(id, f.Properties)
+}
+
+func (r *Reader) add(s, p, o quad.Value) {
+	r.quads = append(r.quads, quad.Quad{Subject: s, Predicate: p, Object: o})
+}
+
+func (r *Reader) addProperties(id quad.Value, props map[string]interface{}) error {
+	// sort keys, so the output is stable
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := r.addProperty(id, quad.IRI(r.opt.Prefix+k), props[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) addProperty(id, pred quad.Value, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+	case string:
+		r.add(id, pred, quad.String(v))
+	case bool:
+		r.add(id, pred, quad.Bool(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			r.add(id, pred, quad.Int(v))
+		} else {
+			r.add(id, pred, quad.Float(v))
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := r.addProperty(id, pred, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		obj := quad.RandomBlankNode()
+		r.add(id, pred, obj)
+		return r.addProperties(obj, v)
+	default:
+		return fmt.Errorf("unsupported value of %v: %T", pred, v)
+	}
+	return nil
+}
+
+func (r *Reader) ReadQuad() (quad.Quad, error) {
+	if r.err != nil {
+		return quad.Quad{}, r.err
+	}
+	if r.n >= len(r.quads) {
+		return quad.Quad{}, io.EOF
+	}
+	q := r.quads[r.n]
+	r.n++
+	return q, nil
+}
+
+func (r *Reader) Close() error {
+	r.quads = nil
+	return nil
+}
+
+// NewWriter returns a quad writer that encodes nodes with geometries as a GeoJSON feature collection.
+// The collection is written when the writer is closed.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterWith(w, Options{})
+}
+
+// NewWriterWith is the same as NewWriter, but allows to set conversion options.
+func NewWriterWith(w io.Writer, opt Options) *Writer {
+	return &Writer{w: w, opt: opt, nodes: make(map[quad.Value]*node)}
+}
+
+type node struct {
+	preds []quad.Value
+	vals  map[quad.Value][]quad.Value
+	// feature is set if the node has a geometry or the Feature type
+	feature bool
+}
+
+// Writer is a quad writer for GeoJSON.
+type Writer struct {
+	w     io.Writer
+	opt   Options
+	order []quad.Value
+	nodes map[quad.Value]*node
+}
+
+func (w *Writer) node(v quad.Value) *node {
+	n := w.nodes[v]
+	if n == nil {
+		n = &node{vals: make(map[quad.Value][]quad.Value)}
+		w.nodes[v] = n
+		w.order = append(w.order, v)
+	}
+	return n
+}
+
+func (w *Writer) WriteQuad(q quad.Quad) error {
+	n := w.node(q.Subject)
+	pred, _ := q.Predicate.(quad.IRI)
+	obj, _ := q.Object.(quad.IRI)
+	switch pred = pred.Full(); {
+	case pred == rdfType && obj.Full() == Feature:
+		n.feature = true
+		return nil
+	case pred == AsWKT:
+		n.feature = true
+		q.Predicate = AsWKT
+	}
+	if _, ok := n.vals[q.Predicate]; !ok {
+		n.preds = append(n.preds, q.Predicate)
+	}
+	n.vals[q.Predicate] = append(n.vals[q.Predicate], q.Object)
+	return nil
+}
+
+func (w *Writer) WriteQuads(buf []quad.Quad) (int, error) {
+	for i, q := range buf {
+		if err := w.WriteQuad(q); err != nil {
+			return i, err
+		}
+	}
+	return len(buf), nil
+}
+
+func (w *Writer) trim(v quad.Value) string {
+	if iri, ok := v.(quad.IRI); ok {
+		return strings.TrimPrefix(string(iri), w.opt.Prefix)
+	}
+	return quad.StringOf(v)
+}
+
+type outFeature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+func (w *Writer) Close() error {
+	out := struct {
+		Type     string       `json:"type"`
+		Features []outFeature `json:"features"`
+	}{Type: "FeatureCollection", Features: []outFeature{}}
+	for _, id := range w.order {
+		n := w.nodes[id]
+		if !n.feature {
+			continue
+		}
+		f := outFeature{Type: "Feature"}
+		if _, ok := id.(quad.BNode); !ok {
+			f.ID = w.trim(id)
+		}
+		for _, v := range n.vals[AsWKT] {
+			var s string
+			switch v := v.(type) {
+			case quad.TypedString:
+				s = string(v.Value)
+			case quad.String:
+				s = string(v)
+			default:
+				continue
+			}
+			g, err := ParseWKT(s)
+			if err != nil {
+				return fmt.Errorf("geojson: geometry of %v: %v", id, err)
+			}
+			f.Geometry = &g
+			break
+		}
+		f.Properties = w.properties(n, AsWKT, make(map[*node]bool))
+		out.Features = append(out.Features, f)
+	}
+	enc := json.NewEncoder(w.w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(out)
+}
+
+func (w *Writer) properties(n *node, skip quad.Value, seen map[*node]bool) map[string]interface{} {
+	seen[n] = true
+	props := make(map[string]interface{}, len(n.preds))
+	for _, p := range n.preds {
+		if p == skip {
+			continue
+		}
+		vals := n.vals[p]
+		arr := make([]interface{}, 0, len(vals))
+		for _, v := range vals {
+			arr = append(arr, w.value(v, seen))
+		}
+		if len(arr) == 1 {
+			props[w.trim(p)] = arr[0]
+		} else {
+			props[w.trim(p)] = arr
+		}
+	}
+	return props
+}
+
+func (w *Writer) value(v quad.Value, seen map[*node]bool) interface{} {
+	switch v := v.(type) {
+	case quad.BNode:
+		if n := w.nodes[v]; n != nil && !n.feature && !seen[n] {
+			return w.properties(n, nil, seen)
+		}
+		return v.String()
+	case quad.IRI:
+		return w.trim(v)
+	case quad.String:
+		return string(v)
+	case quad.Int:
+		return int64(v)
+	case quad.Float:
+		return float64(v)
+	case quad.Bool:
+		return bool(v)
+	case quad.Time:
+		return time.Time(v).Format(time.RFC3339Nano)
+	}
+	return quad.StringOf(v)
+}
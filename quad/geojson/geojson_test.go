@@ -0,0 +1,127 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/geo"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const testCollection = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature", "id": "sf",
+			"geometry": {"type": "Point", "coordinates": [-122.4194, 37.7749]},
+			"properties": {"name": "San Francisco", "population": 883305, "area": 121.4, "capital": false, "tags": ["city", "bay"]}
+		},
+		{
+			"type": "Feature", "id": 2,
+			"geometry": {"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [10, 10], [0, 0]]]},
+			"properties": {"name": "Triangle", "meta": {"source": "test"}}
+		},
+		{
+			"type": "Feature",
+			"geometry": null,
+			"properties": null
+		}
+	]
+}`
+
+func TestRoundTrip(t *testing.T) {
+	r := NewReaderWith(strings.NewReader(testCollection), Options{Prefix: "ex:"})
+	var quads []quad.Quad
+	for {
+		q, err := r.ReadQuad()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		quads = append(quads, q)
+	}
+	sf := quad.IRI("ex:sf")
+	require.Contains(t, quads, quad.Make(sf, rdfType, Feature, nil))
+	require.Contains(t, quads, quad.Make(sf, AsWKT, geo.Point{Lat: 37.7749, Lng: -122.4194}.Value(), nil))
+	require.Contains(t, quads, quad.Make(sf, quad.IRI("ex:population"), quad.Int(883305), nil))
+	require.Contains(t, quads, quad.Make(sf, quad.IRI("ex:area"), quad.Float(121.4), nil))
+	require.Contains(t, quads, quad.Make(sf, quad.IRI("ex:tags"), quad.String("bay"), nil))
+	require.Contains(t, quads, quad.Make(quad.IRI("ex:2"), AsWKT, quad.TypedString{
+		Value: "POLYGON((0 0, 10 0, 10 10, 0 0))", Type: geo.WKTLiteral,
+	}, nil))
+
+	buf := bytes.NewBuffer(nil)
+	w := NewWriterWith(buf, Options{Prefix: "ex:"})
+	_, err := w.WriteQuads(quads)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var exp, got interface{}
+	require.NoError(t, json.Unmarshal([]byte(testCollection), &exp))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	// ids are always strings, and null properties are written as empty objects
+	features := exp.(map[string]interface{})["features"].([]interface{})
+	features[1].(map[string]interface{})["id"] = "2"
+	features[2].(map[string]interface{})["properties"] = map[string]interface{}{}
+	require.Equal(t, exp, got)
+}
+
+func TestWKT(t *testing.T) {
+	for _, s := range []string{
+		"POINT(1 2)",
+		"POINT(1 2 3)",
+		"MULTIPOINT(1 2, 3 4)",
+		"LINESTRING(1 2, 3 4.5)",
+		"POLYGON((0 0, 10 0, 10 10, 0 0), (1 1, 2 1, 2 2, 1 1))",
+		"MULTILINESTRING((1 2, 3 4), (5 6, 7 8))",
+		"MULTIPOLYGON(((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))",
+		"GEOMETRYCOLLECTION(POINT(1 2), LINESTRING(1 2, 3 4))",
+		"POINT EMPTY",
+	} {
+		g, err := ParseWKT(s)
+		require.NoError(t, err, s)
+		out, err := g.WKT()
+		require.NoError(t, err, s)
+		require.Equal(t, s, out)
+	}
+	g, err := ParseWKT("<http://www.opengis.net/def/crs/OGC/1.3/CRS84> multipoint Z ((1 2 3), (4 5 6))")
+	require.NoError(t, err)
+	out, err := g.WKT()
+	require.NoError(t, err)
+	require.Equal(t, "MULTIPOINT(1 2 3, 4 5 6)", out)
+
+	for _, s := range []string{
+		"POINT(1)",
+		"POINT((1 2))",
+		"POLYGON(1 2, 3 4)",
+		"CIRCLE(1 2)",
+		"POINT(1 2",
+		"POINT(1 2) x",
+	} {
+		_, err := ParseWKT(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestPolygon(t *testing.T) {
+	g, err := ParseWKT("POLYGON((0 0, 10 0, 10 10, 0 0))")
+	require.NoError(t, err)
+	p, err := g.Polygon()
+	require.NoError(t, err)
+	require.Equal(t, geo.Polygon{{Lat: 0, Lng: 0}, {Lat: 0, Lng: 10}, {Lat: 10, Lng: 10}}, p)
+
+	for _, s := range []string{
+		"POINT(1 2)",
+		"POLYGON((0 0, 10 0, 10 10, 0 0), (1 1, 2 1, 2 2, 1 1))",
+	} {
+		g, err := ParseWKT(s)
+		require.NoError(t, err, s)
+		_, err = g.Polygon()
+		require.Error(t, err, s)
+	}
+}
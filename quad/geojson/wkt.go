@@ -0,0 +1,358 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph/geo"
+)
+
+// Geometry is a GeoJSON geometry object.
+type Geometry struct {
+	Type string `json:"type"`
+	// Coordinates are nested arrays of positions. A position is an array of numbers.
+	Coordinates interface{} `json:"coordinates,omitempty"`
+	// Geometries are set for GeometryCollection.
+	Geometries []Geometry `json:"geometries,omitempty"`
+}
+
+// depth is the number of arrays around positions in coordinates of each geometry type.
+var depth = map[string]int{
+	"Point":           0,
+	"MultiPoint":      1,
+	"LineString":      1,
+	"MultiLineString": 2,
+	"Polygon":         2,
+	"MultiPolygon":    3,
+}
+
+// wktNames maps WKT geometry names to GeoJSON types.
+var wktNames = map[string]string{
+	"POINT":              "Point",
+	"MULTIPOINT":         "MultiPoint",
+	"LINESTRING":         "LineString",
+	"MULTILINESTRING":    "MultiLineString",
+	"POLYGON":            "Polygon",
+	"MULTIPOLYGON":       "MultiPolygon",
+	"GEOMETRYCOLLECTION": "GeometryCollection",
+}
+
+// WKT returns the geometry in WKT format.
+func (g Geometry) WKT() (string, error) {
+	name := strings.ToUpper(g.Type)
+	if _, ok := wktNames[name]; !ok {
+		return "", fmt.Errorf("geojson: unsupported geometry type: %q", g.Type)
+	}
+	if g.Type == "GeometryCollection" {
+		if len(g.Geometries) == 0 {
+			return name + " EMPTY", nil
+		}
+		parts := make([]string, 0, len(g.Geometries))
+		for _, sg := range g.Geometries {
+			s, err := sg.WKT()
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return name + "(" + strings.Join(parts, ", ") + ")", nil
+	}
+	if arr, ok := g.Coordinates.([]interface{}); g.Coordinates == nil || (ok && len(arr) == 0) {
+		return name + " EMPTY", nil
+	}
+	var buf strings.Builder
+	buf.WriteString(name)
+	if depth[g.Type] == 0 {
+		buf.WriteString("(")
+	}
+	if err := writeCoords(&buf, g.Coordinates, depth[g.Type]); err != nil {
+		return "", fmt.Errorf("geojson: invalid coordinates of %s: %v", g.Type, err)
+	}
+	if depth[g.Type] == 0 {
+		buf.WriteString(")")
+	}
+	return buf.String(), nil
+}
+
+// Polygon returns the ring of a Polygon geometry. Polygons with holes are not supported.
+func (g Geometry) Polygon() (geo.Polygon, error) {
+	if g.Type != "Polygon" {
+		return nil, fmt.Errorf("geojson: expected a Polygon, got %q", g.Type)
+	}
+	if err := checkDepth(g.Coordinates, depth[g.Type]); err != nil {
+		return nil, fmt.Errorf("geojson: invalid coordinates of %s: %v", g.Type, err)
+	}
+	rings := g.Coordinates.([]interface{})
+	if len(rings) != 1 {
+		return nil, fmt.Errorf("geojson: polygons with holes are not supported")
+	}
+	ring := rings[0].([]interface{})
+	out := make(geo.Polygon, 0, len(ring))
+	for _, c := range ring {
+		pos := c.([]interface{})
+		if len(pos) < 2 {
+			return nil, fmt.Errorf("geojson: invalid position of %s: %v", g.Type, pos)
+		}
+		lng, ok1 := pos[0].(float64)
+		lat, ok2 := pos[1].(float64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("geojson: invalid position of %s: %v", g.Type, pos)
+		}
+		out = append(out, geo.Point{Lat: lat, Lng: lng})
+	}
+	if n := len(out); n > 1 && out[0] == out[n-1] {
+		// the ring is closed, but geo.Polygon connects the last point to the first one
+		out = out[:n-1]
+	}
+	return out, nil
+}
+
+func writeCoords(buf *strings.Builder, v interface{}, depth int) error {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("expected an array, got %T", v)
+	}
+	if depth == 0 {
+		if len(arr) < 2 {
+			return fmt.Errorf("expected at least 2 numbers in a position, got %d", len(arr))
+		}
+		for i, c := range arr {
+			f, ok := c.(float64)
+			if !ok {
+				return fmt.Errorf("expected a number, got %T", c)
+			}
+			if i != 0 {
+				buf.WriteString(" ")
+			}
+			buf.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+		}
+		return nil
+	}
+	buf.WriteString("(")
+	for i, c := range arr {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		if err := writeCoords(buf, c, depth-1); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(")")
+	return nil
+}
+
+// ParseWKT parses a geometry in WKT format. An optional coordinate reference system prefix is ignored.
+func ParseWKT(s string) (Geometry, error) {
+	p := &wktParser{s: strings.TrimSpace(s)}
+	if strings.HasPrefix(p.s, "<") {
+		// <crs> GEOMETRY(...)
+		if i := strings.Index(p.s, ">"); i > 0 {
+			p.s = strings.TrimSpace(p.s[i+1:])
+		}
+	}
+	g, err := p.geometry()
+	if err == nil && p.peek() != 0 {
+		err = fmt.Errorf("unexpected %q", p.s[p.i:])
+	}
+	if err != nil {
+		return Geometry{}, fmt.Errorf("geojson: cannot parse WKT: %v", err)
+	}
+	return g, nil
+}
+
+type wktParser struct {
+	s string
+	i int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			break
+		}
+		p.i++
+	}
+	return strings.ToUpper(p.s[start:p.i])
+}
+
+func (p *wktParser) peek() byte {
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *wktParser) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("expected %q at %d", c, p.i)
+	}
+	p.i++
+	return nil
+}
+
+func (p *wktParser) geometry() (Geometry, error) {
+	name := p.word()
+	typ, ok := wktNames[name]
+	if !ok {
+		return Geometry{}, fmt.Errorf("unsupported geometry type: %q", name)
+	}
+	g := Geometry{Type: typ}
+	// skip dimensions: POINT Z (1 2 3)
+	save := p.i
+	switch p.word() {
+	case "EMPTY":
+		if typ != "GeometryCollection" {
+			g.Coordinates = []interface{}{}
+		}
+		return g, nil
+	case "Z", "M", "ZM":
+	default:
+		p.i = save
+	}
+	if typ == "GeometryCollection" {
+		if err := p.expect('('); err != nil {
+			return g, err
+		}
+		for {
+			sg, err := p.geometry()
+			if err != nil {
+				return g, err
+			}
+			g.Geometries = append(g.Geometries, sg)
+			if p.peek() != ',' {
+				break
+			}
+			p.i++
+		}
+		return g, p.expect(')')
+	}
+	c, err := p.list()
+	if err != nil {
+		return g, err
+	}
+	switch typ {
+	case "Point":
+		// (x y) is parsed as a list of one position
+		if len(c) != 1 {
+			return g, fmt.Errorf("expected one position in a point")
+		}
+		g.Coordinates = c[0]
+	case "MultiPoint":
+		// MULTIPOINT((1 2), (3 4)) is the same as MULTIPOINT(1 2, 3 4)
+		for i, v := range c {
+			if arr, ok := v.([]interface{}); ok && len(arr) == 1 {
+				if _, ok := arr[0].([]interface{}); ok {
+					c[i] = arr[0]
+				}
+			}
+		}
+		g.Coordinates = c
+	default:
+		g.Coordinates = c
+	}
+	if err := checkDepth(g.Coordinates, depth[typ]); err != nil {
+		return g, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return g, nil
+}
+
+// list parses a parenthesized list of positions or nested lists.
+func (p *wktParser) list() ([]interface{}, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for {
+		if p.peek() == '(' {
+			sub, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, sub)
+		} else {
+			pos, err := p.position()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, pos)
+		}
+		if p.peek() != ',' {
+			break
+		}
+		p.i++
+	}
+	return out, p.expect(')')
+}
+
+func (p *wktParser) position() ([]interface{}, error) {
+	var pos []interface{}
+	for {
+		p.skipSpace()
+		start := p.i
+		for p.i < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.i]) >= 0 {
+			p.i++
+		}
+		if start == p.i {
+			break
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.i], 64)
+		if err != nil {
+			return nil, err
+		}
+		pos = append(pos, f)
+	}
+	if len(pos) < 2 {
+		return nil, fmt.Errorf("expected a position at %d", p.i)
+	}
+	return pos, nil
+}
+
+// checkDepth checks that coordinates have positions at a given depth.
+func checkDepth(v interface{}, depth int) error {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("expected an array")
+	}
+	if len(arr) == 0 {
+		return fmt.Errorf("empty array")
+	}
+	_, isNum := arr[0].(float64)
+	if depth == 0 {
+		if !isNum {
+			return fmt.Errorf("expected a position")
+		}
+		return nil
+	} else if isNum {
+		return fmt.Errorf("unexpected position")
+	}
+	for _, c := range arr {
+		if err := checkDepth(c, depth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeGeometry decodes a geometry of a feature, which may be null.
+func decodeGeometry(data json.RawMessage) (*Geometry, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var g Geometry
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+)
+
+var logger = clog.Module("query")
+
+// Execution is a query that is currently running.
+type Execution struct {
+	rows int64 // atomic; first for alignment
+
+	id      string
+	lang    string
+	text    string
+	started time.Time
+	cancel  func()
+	active  *Active
+	plan    graph.Plan
+	slow    bool // guarded by active.mu
+}
+
+// AddRows adds to the number of results produced by the query.
+func (e *Execution) AddRows(n int) {
+	if e == nil {
+		return
+	}
+	atomic.AddInt64(&e.rows, int64(n))
+}
+
+// Done removes the query from the set of active queries and releases its context.
+func (e *Execution) Done() {
+	if e == nil {
+		return
+	}
+	e.active.mu.Lock()
+	delete(e.active.running, e.id)
+	e.active.mu.Unlock()
+	e.cancel()
+}
+
+// ExecutionInfo describes a running query.
+type ExecutionInfo struct {
+	ID      string    `json:"id"`
+	Lang    string    `json:"lang"`
+	Query   string    `json:"query"`
+	Started time.Time `json:"started"`
+	// Elapsed is the time since the start of the query, in milliseconds.
+	Elapsed float64 `json:"elapsed_ms"`
+	Rows    int64   `json:"rows"`
+	// Plan is a summary of the iterator tree of the query, as returned by graph.SummarizeIterator.
+	// Queries that run multiple iterations report the last one.
+	Plan string `json:"plan,omitempty"`
+}
+
+func (e *Execution) info(now time.Time) ExecutionInfo {
+	return ExecutionInfo{
+		ID: e.id, Lang: e.lang, Query: e.text,
+		Started: e.started, Elapsed: float64(now.Sub(e.started)) / float64(time.Millisecond),
+		Rows: atomic.LoadInt64(&e.rows), Plan: e.plan.String(),
+	}
+}
+
+// Active is a set of running queries. Queries can be listed and cancelled by their id.
+type Active struct {
+	mu      sync.Mutex
+	last    uint64
+	running map[string]*Execution
+}
+
+// NewActive creates an empty set of running queries.
+func NewActive() *Active {
+	return &Active{running: make(map[string]*Execution)}
+}
+
+// Start registers a query and returns a context that is cancelled when the query is cancelled.
+// The query must be executed with this context, and Done must be called when it completes.
+// The context also records the plan of the query.
+//
+// If the set is nil, the query is not registered, and methods of the returned nil execution are no-op.
+func (a *Active) Start(ctx context.Context, lang, text string) (context.Context, *Execution) {
+	if a == nil {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	e := &Execution{lang: lang, text: text, started: time.Now(), cancel: cancel, active: a}
+	a.mu.Lock()
+	a.last++
+	e.id = strconv.FormatUint(a.last, 10)
+	a.running[e.id] = e
+	a.mu.Unlock()
+	return graph.NewPlanContext(ctx, &e.plan), e
+}
+
+// List returns all running queries, from the oldest one.
+func (a *Active) List() []ExecutionInfo {
+	now := time.Now()
+	a.mu.Lock()
+	out := make([]ExecutionInfo, 0, len(a.running))
+	for _, e := range a.running {
+		out = append(out, e.info(now))
+	}
+	a.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out
+}
+
+// Cancel cancels a running query. It returns false if there is no running query with this id.
+func (a *Active) Cancel(id string) bool {
+	a.mu.Lock()
+	e, ok := a.running[id]
+	a.mu.Unlock()
+	if !ok {
+		return false
+	}
+	logger.Info("query cancelled", clog.F("id", id), clog.F("lang", e.lang))
+	e.cancel()
+	return true
+}
+
+// Watch logs a warning for each query that runs longer than a given threshold, until the context is cancelled.
+// Each query is logged once.
+func (a *Active) Watch(ctx context.Context, threshold time.Duration) {
+	interval := threshold / 2
+	if interval > time.Second {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			var slow []ExecutionInfo
+			a.mu.Lock()
+			for _, e := range a.running {
+				if !e.slow && now.Sub(e.started) >= threshold {
+					e.slow = true
+					slow = append(slow, e.info(now))
+				}
+			}
+			a.mu.Unlock()
+			for _, e := range slow {
+				logger.Warning("long-running query",
+					clog.F("id", e.ID), clog.F("lang", e.Lang), clog.F("elapsed", now.Sub(e.Started)),
+					clog.F("rows", e.Rows), clog.F("query", e.Query))
+			}
+		}
+	}
+}
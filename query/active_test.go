@@ -0,0 +1,47 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/query"
+)
+
+func TestActive(t *testing.T) {
+	a := query.NewActive()
+	ctx1, e1 := a.Start(context.TODO(), "gizmo", "g.V().All()")
+	_, e2 := a.Start(context.TODO(), "graphql", "{nodes{id}}")
+	e1.AddRows(3)
+	_, err := graph.Iterate(ctx1, iterator.NewFixed(iterator.Int64Node(1))).Count()
+	require.NoError(t, err)
+
+	list := a.List()
+	require.Len(t, list, 2)
+	require.Equal(t, "gizmo", list[0].Lang)
+	require.Equal(t, "g.V().All()", list[0].Query)
+	require.Equal(t, int64(3), list[0].Rows)
+	require.Equal(t, "fixed=1", list[0].Plan)
+	require.Equal(t, "graphql", list[1].Lang)
+
+	require.True(t, a.Cancel(list[0].ID))
+	select {
+	case <-ctx1.Done():
+	default:
+		t.Fatal("context is not cancelled")
+	}
+	e1.Done()
+	e2.Done()
+	require.Empty(t, a.List())
+	require.False(t, a.Cancel(list[1].ID))
+
+	// queries are not tracked without a set
+	var none *query.Active
+	ctx, e := none.Start(context.TODO(), "gizmo", "g.V().All()")
+	require.NotNil(t, ctx)
+	e.AddRows(1)
+	e.Done()
+}
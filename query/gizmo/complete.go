@@ -0,0 +1,56 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gizmo
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/cayleygraph/cayley/query"
+)
+
+var _ query.Completer = (*Session)(nil)
+
+// Completions implements query.Completer. Methods include all methods of graph and path objects,
+// as well as functions of geo and time objects.
+func (s *Session) Completions() (globals, methods []string) {
+	globals = []string{"graph", "g", "geo", "time"}
+	for name := range defaultEnv {
+		globals = append(globals, name)
+	}
+	seen := make(map[string]struct{})
+	add := func(name string) {
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			methods = append(methods, name)
+		}
+	}
+	// goja exposes exported methods by their Go names
+	for _, v := range []interface{}{&graphObject{}, &pathObject{}} {
+		t := reflect.TypeOf(v)
+		for i := 0; i < t.NumMethod(); i++ {
+			add(t.Method(i).Name)
+		}
+	}
+	for name := range geoEnv {
+		add(name)
+	}
+	for name := range timeEnv {
+		add(name)
+	}
+	sort.Strings(globals)
+	sort.Strings(methods)
+	return globals, methods
+}
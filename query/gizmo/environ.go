@@ -17,16 +17,23 @@ package gizmo
 // Builds a new Gizmo environment pointing at a session.
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"time"
 
 	"github.com/dop251/goja"
 
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/named"
 	"github.com/cayleygraph/cayley/graph/path"
 	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/graph/text"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geojson"
 	"github.com/cayleygraph/cayley/voc"
 )
 
@@ -38,6 +45,8 @@ import (
 // Under the hood, they're simple objects that get compiled to a Go iterator tree when executed.
 type graphObject struct {
 	s *Session
+	// graphs joined with Graph; nil for the quad store of the session
+	qs graph.QuadStore
 }
 
 // Uri creates an IRI values from a given string.
@@ -82,6 +91,7 @@ func (g *graphObject) Vertex(call goja.FunctionCall) goja.Value {
 		s:      g.s,
 		finals: true,
 		path:   path.StartMorphism(qv...),
+		qs:     g.qs,
 	})
 }
 
@@ -101,9 +111,33 @@ func (g *graphObject) Morphism() *pathObject {
 	return &pathObject{
 		s:    g.s,
 		path: path.StartMorphism(),
+		qs:   g.qs,
 	}
 }
 
+// Graph returns a graph object that queries a union of named graphs. Paths started from it can traverse
+// quads of all these graphs, even if the query is restricted to a single graph. Other graphs stay invisible.
+// Signature: (label, [label]...)
+//
+// Arguments:
+//
+// * `label`: A string for a label of a named graph. Can be repeated or a list of strings.
+//
+// Example:
+//
+//	// javascript
+//	// Find friends of alice in <g2> of people she follows in <g1>.
+//	g.Graph("<g1>", "<g2>").V("<alice>").Out("<follows>").Out("<friend>").All()
+func (g *graphObject) Graph(call goja.FunctionCall) goja.Value {
+	labels, err := toQuadValues(exportArgs(call.Arguments))
+	if err != nil {
+		return throwErr(g.s.vm, err)
+	} else if len(labels) == 0 {
+		return throwErr(g.s.vm, errArgCount2{Expected: 1, Got: 0})
+	}
+	return g.s.vm.ToValue(&graphObject{s: g.s, qs: named.Join(named.Base(g.s.qs), labels...)})
+}
+
 // Emit adds data programmatically to the JSON result list. Can be any JSON type.
 //
 //	// javascript
@@ -165,6 +199,68 @@ func cmpWildcard(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
 	return vm.ToValue(valFilter{f: shape.Wildcard{Pattern: pattern}})
 }
 
+func cmpText(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 {
+		return throwErr(vm, errArgCount2{Expected: 1, Got: len(args)})
+	}
+	query, ok := args[0].(string)
+	if !ok {
+		return throwErr(vm, fmt.Errorf("text: unsupported type: %T", args[0]))
+	}
+	return vm.ToValue(valFilter{f: shape.Text{Query: query, ScoreTag: text.ScoreTag}})
+}
+
+func cmpFuzzy(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 && len(args) != 2 {
+		return throwErr(vm, errArgCount2{Expected: 2, Got: len(args)})
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return throwErr(vm, fmt.Errorf("fuzzy: unsupported type: %T", args[0]))
+	}
+	dist := 1
+	if len(args) > 1 {
+		d, ok := toInt(args[1])
+		if !ok {
+			return throwErr(vm, fmt.Errorf("fuzzy: expected integer distance, got: %T", args[1]))
+		}
+		dist = d
+	}
+	return vm.ToValue(valFilter{f: shape.Fuzzy{Value: s, Distance: dist}})
+}
+
+func cmpPrefix(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 {
+		return throwErr(vm, errArgCount2{Expected: 1, Got: len(args)})
+	}
+	pref, ok := args[0].(string)
+	if !ok {
+		return throwErr(vm, fmt.Errorf("prefix: unsupported type: %T", args[0]))
+	}
+	return vm.ToValue(valFilter{f: shape.Prefix{Prefix: pref}})
+}
+
+func cmpSoundsLike(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 1 && len(args) != 2 {
+		return throwErr(vm, errArgCount2{Expected: 2, Got: len(args)})
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return throwErr(vm, fmt.Errorf("soundsLike: unsupported type: %T", args[0]))
+	}
+	var algo string
+	if len(args) > 1 {
+		if algo, ok = args[1].(string); !ok {
+			return throwErr(vm, fmt.Errorf("soundsLike: expected algorithm name, got: %T", args[1]))
+		}
+	}
+	return vm.ToValue(valFilter{f: shape.SoundsLike{Value: s, Algorithm: algo}})
+}
+
 func cmpRegexp(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
 	args := exportArgs(call.Arguments)
 	if len(args) != 1 && len(args) != 2 {
@@ -236,12 +332,156 @@ var defaultEnv = map[string]func(vm *goja.Runtime, call goja.FunctionCall) goja.
 		return quad.TypedString{Value: quad.String(s), Type: quad.IRI(typ)}
 	}),
 
-	"lt":    cmpOpType(iterator.CompareLT),
-	"lte":   cmpOpType(iterator.CompareLTE),
-	"gt":    cmpOpType(iterator.CompareGT),
-	"gte":   cmpOpType(iterator.CompareGTE),
-	"regex": cmpRegexp,
-	"like":  cmpWildcard,
+	"lt":     cmpOpType(iterator.CompareLT),
+	"lte":    cmpOpType(iterator.CompareLTE),
+	"gt":     cmpOpType(iterator.CompareGT),
+	"gte":    cmpOpType(iterator.CompareGTE),
+	"regex":  cmpRegexp,
+	"like":   cmpWildcard,
+	"text":   cmpText,
+	"fuzzy":  cmpFuzzy,
+	"prefix": cmpPrefix,
+
+	"soundsLike": cmpSoundsLike,
+	"datePart":   cmpDatePart,
+}
+
+// geoEnv contains functions of the geo object.
+var geoEnv = map[string]func(vm *goja.Runtime, call goja.FunctionCall) goja.Value{
+	"distance": geoDistance,
+}
+
+// geoDistance returns the great-circle distance between two points in meters.
+func geoDistance(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 2 {
+		return throwErr(vm, errArgCount2{Expected: 2, Got: len(args)})
+	}
+	a, err := toPoint(args[0])
+	if err != nil {
+		return throwErr(vm, fmt.Errorf("distance: %v", err))
+	}
+	b, err := toPoint(args[1])
+	if err != nil {
+		return throwErr(vm, fmt.Errorf("distance: %v", err))
+	}
+	return vm.ToValue(geo.Distance(a, b))
+}
+
+// timeEnv contains functions of the time object.
+var timeEnv = map[string]func(s *Session, call goja.FunctionCall) goja.Value{
+	"now": func(s *Session, call goja.FunctionCall) goja.Value {
+		return s.toDate(time.Now())
+	},
+	"parse": func(s *Session, call goja.FunctionCall) goja.Value {
+		args := exportArgs(call.Arguments)
+		if len(args) != 1 {
+			return throwErr(s.vm, errArgCount2{Expected: 1, Got: len(args)})
+		}
+		expr, ok := args[0].(string)
+		if !ok {
+			return throwErr(s.vm, fmt.Errorf("parse: expected a string, got %T", args[0]))
+		}
+		var (
+			t   time.Time
+			err error
+		)
+		if temporal.IsMath(expr) {
+			t, err = temporal.Math(expr, time.Now())
+		} else {
+			t, err = time.Parse(time.RFC3339Nano, expr)
+		}
+		if err != nil {
+			return throwErr(s.vm, err)
+		}
+		return s.toDate(t)
+	},
+	"add": func(s *Session, call goja.FunctionCall) goja.Value {
+		args := exportArgs(call.Arguments)
+		if len(args) != 3 {
+			return throwErr(s.vm, errArgCount2{Expected: 3, Got: len(args)})
+		}
+		t, err := toTime(args[0])
+		if err != nil {
+			return throwErr(s.vm, fmt.Errorf("add: %v", err))
+		}
+		n, ok := toInt(args[1])
+		if !ok {
+			return throwErr(s.vm, fmt.Errorf("add: expected a number, got %T", args[1]))
+		}
+		u, err := toUnit(args[2])
+		if err != nil {
+			return throwErr(s.vm, err)
+		}
+		return s.toDate(temporal.Add(t, n, u))
+	},
+	"truncate": func(s *Session, call goja.FunctionCall) goja.Value {
+		args := exportArgs(call.Arguments)
+		if len(args) != 2 {
+			return throwErr(s.vm, errArgCount2{Expected: 2, Got: len(args)})
+		}
+		t, err := toTime(args[0])
+		if err != nil {
+			return throwErr(s.vm, fmt.Errorf("truncate: %v", err))
+		}
+		u, err := toUnit(args[1])
+		if err != nil {
+			return throwErr(s.vm, err)
+		}
+		return s.toDate(temporal.Truncate(t.UTC(), u))
+	},
+	"year":    timePart(temporal.PartYear),
+	"month":   timePart(temporal.PartMonth),
+	"day":     timePart(temporal.PartDay),
+	"hour":    timePart(temporal.PartHour),
+	"minute":  timePart(temporal.PartMinute),
+	"second":  timePart(temporal.PartSecond),
+	"weekday": timePart(temporal.PartWeekday),
+	"yearday": timePart(temporal.PartYearDay),
+}
+
+// timePart returns a function that extracts a part of a time, in UTC.
+func timePart(p temporal.Part) func(s *Session, call goja.FunctionCall) goja.Value {
+	return func(s *Session, call goja.FunctionCall) goja.Value {
+		args := exportArgs(call.Arguments)
+		if len(args) != 1 {
+			return throwErr(s.vm, errArgCount2{Expected: 1, Got: len(args)})
+		}
+		t, err := toTime(args[0])
+		if err != nil {
+			return throwErr(s.vm, fmt.Errorf("%v: %v", p, err))
+		}
+		return s.vm.ToValue(temporal.Extract(t.UTC(), p))
+	}
+}
+
+// cmpDatePart returns a filter of time values with a given part, in UTC.
+func cmpDatePart(vm *goja.Runtime, call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) != 2 {
+		return throwErr(vm, errArgCount2{Expected: 2, Got: len(args)})
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return throwErr(vm, fmt.Errorf("datePart: expected a string, got %T", args[0]))
+	}
+	p, err := temporal.ParsePart(name)
+	if err != nil {
+		return throwErr(vm, err)
+	}
+	n, ok := toInt(args[1])
+	if !ok {
+		return throwErr(vm, fmt.Errorf("datePart: expected a number, got %T", args[1]))
+	}
+	return vm.ToValue(valFilter{f: temporal.PartFilter{Part: p, Value: n}})
+}
+
+func toUnit(o interface{}) (temporal.Unit, error) {
+	s, ok := o.(string)
+	if !ok {
+		return 0, fmt.Errorf("expected a time unit, got %T", o)
+	}
+	return temporal.ParseUnit(s)
 }
 
 func unwrap(o interface{}) interface{} {
@@ -312,6 +552,156 @@ func toQuadValue(o interface{}) (quad.Value, error) {
 	return qv, nil
 }
 
+// toPredicate converts a predicate argument to a path or a value, as accepted by Has.
+func toPredicate(o interface{}) (interface{}, error) {
+	if vp, ok := o.(*pathObject); ok {
+		return vp.path, nil
+	}
+	return toQuadValue(o)
+}
+
+// toTime converts a Date or a string in RFC 3339 format to time.
+func toTime(o interface{}) (time.Time, error) {
+	switch v := o.(type) {
+	case time.Time:
+		return v, nil
+	case quad.Time:
+		return time.Time(v), nil
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	}
+	return time.Time{}, fmt.Errorf("expected a Date or a string, got %T", o)
+}
+
+func toFloat(o interface{}) (float64, bool) {
+	switch v := o.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// toPoint converts a [lat, lng] pair, an object with lat and lng fields, or a WKT literal to a point.
+func toPoint(o interface{}) (geo.Point, error) {
+	var lat, lng interface{}
+	switch v := o.(type) {
+	case geo.Point:
+		return v, nil
+	case []interface{}:
+		if len(v) != 2 {
+			return geo.Point{}, fmt.Errorf("expected a [lat, lng] pair, got %d values", len(v))
+		}
+		lat, lng = v[0], v[1]
+	case map[string]interface{}:
+		lat, lng = v["lat"], v["lng"]
+	default:
+		qv, err := toQuadValue(o)
+		if err != nil {
+			return geo.Point{}, err
+		}
+		if pt, ok := geo.ParsePoint(qv); ok {
+			return pt, nil
+		}
+		return geo.Point{}, fmt.Errorf("expected a point, got %v", qv)
+	}
+	var (
+		pt  geo.Point
+		ok1 bool
+		ok2 bool
+	)
+	pt.Lat, ok1 = toFloat(lat)
+	pt.Lng, ok2 = toFloat(lng)
+	if !ok1 || !ok2 {
+		return geo.Point{}, fmt.Errorf("expected numbers for lat and lng, got %T and %T", lat, lng)
+	}
+	return pt, nil
+}
+
+// toPolygon converts an array of [lat, lng] pairs, a WKT polygon or a GeoJSON Polygon geometry to a polygon.
+func toPolygon(o interface{}) (geo.Polygon, error) {
+	var g geojson.Geometry
+	switch v := o.(type) {
+	case []interface{}:
+		poly := make(geo.Polygon, 0, len(v))
+		for _, pt := range v {
+			p, err := toPoint(pt)
+			if err != nil {
+				return nil, err
+			}
+			poly = append(poly, p)
+		}
+		return poly, nil
+	case string:
+		var err error
+		if g, err = geojson.ParseWKT(v); err != nil {
+			return nil, err
+		}
+	case map[string]interface{}:
+		// GeoJSON geometry; convert it the same way as geometries of GeoJSON files
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &g); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected an array of points or a polygon, got %T", o)
+	}
+	return g.Polygon()
+}
+
+// toSequenceOptions converts an options object of Sequence.
+func toSequenceOptions(m map[string]interface{}) (path.SequenceOptions, error) {
+	var (
+		opts path.SequenceOptions
+		err  error
+	)
+	for k, v := range m {
+		switch k {
+		case "from":
+			opts.From, err = toTime(v)
+		case "to":
+			opts.To, err = toTime(v)
+		case "desc":
+			if b, ok := v.(bool); !ok {
+				err = fmt.Errorf("expected a boolean for %q, got %T", k, v)
+			} else if b {
+				opts.Order = path.Desc
+			}
+		case "skip", "limit":
+			n, ok := toInt(v)
+			if !ok {
+				err = fmt.Errorf("expected a number for %q, got %T", k, v)
+			} else if k == "skip" {
+				opts.Skip = int64(n)
+			} else {
+				opts.Limit = int64(n)
+			}
+		case "timeTag", "indexTag":
+			tag, ok := v.(string)
+			if !ok {
+				err = fmt.Errorf("expected a string for %q, got %T", k, v)
+			} else if k == "timeTag" {
+				opts.TimeTag = tag
+			} else {
+				opts.IndexTag = tag
+			}
+		default:
+			err = fmt.Errorf("unknown option %q", k)
+		}
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
 func toQuadValues(objs []interface{}) ([]quad.Value, error) {
 	if len(objs) == 0 {
 		return nil, nil
@@ -18,13 +18,18 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/dop251/goja"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/internal/memory"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geojson"
 	"github.com/cayleygraph/cayley/query"
 	"github.com/cayleygraph/cayley/schema"
 	"github.com/cayleygraph/cayley/voc"
@@ -65,8 +70,9 @@ type Session struct {
 	ns  voc.Namespaces
 	sch *schema.Config
 
-	last string
-	p    *goja.Program
+	last    string
+	p       *goja.Program
+	newDate goja.Callable // creates a JavaScript Date from milliseconds
 
 	out   chan query.Result
 	ctx   context.Context
@@ -79,6 +85,20 @@ type Session struct {
 	shape      map[string]interface{}
 }
 
+// AddNamespace associates a prefix with a given IRI namespace, as graph.addNamespace does.
+func (s *Session) AddNamespace(prefix, full string) {
+	s.ns.Register(voc.Namespace{Prefix: prefix + ":", Full: full})
+}
+
+// SetVariable defines a global variable. Names of built-in objects and functions cannot be redefined.
+func (s *Session) SetVariable(name string, v interface{}) error {
+	if s.vm.Get(name) != nil {
+		return fmt.Errorf("gizmo: cannot redefine %q", name)
+	}
+	s.vm.Set(name, v)
+	return nil
+}
+
 func (s *Session) context() context.Context {
 	return s.ctx
 }
@@ -96,9 +116,58 @@ func (s *Session) buildEnv() error {
 			return fnc(s.vm, call)
 		})
 	}
+	geoObj := s.vm.NewObject()
+	for name, val := range geoEnv {
+		fnc := val
+		geoObj.Set(name, func(call goja.FunctionCall) goja.Value {
+			return fnc(s.vm, call)
+		})
+	}
+	s.vm.Set("geo", geoObj)
+	ctor, err := s.vm.RunString(`(function(ms) { return new Date(ms) })`)
+	if err != nil {
+		return err
+	}
+	s.newDate, _ = goja.AssertFunction(ctor)
+	timeObj := s.vm.NewObject()
+	for name, val := range timeEnv {
+		fnc := val
+		timeObj.Set(name, func(call goja.FunctionCall) goja.Value {
+			return fnc(s, call)
+		})
+	}
+	s.vm.Set("time", timeObj)
 	return nil
 }
 
+// locationOf returns the first point in the geo:asWKT property of a node.
+func (s *Session) locationOf(node quad.Value) (geo.Point, error) {
+	var (
+		pt    geo.Point
+		found bool
+	)
+	err := path.StartPath(s.qs, node).Out(geojson.AsWKT).Iterate(s.context()).EachValue(s.qs, func(v quad.Value) {
+		if !found {
+			pt, found = geo.ParsePoint(v)
+		}
+	})
+	if err != nil {
+		return geo.Point{}, err
+	} else if !found {
+		return geo.Point{}, fmt.Errorf("%v has no location", node)
+	}
+	return pt, nil
+}
+
+// toDate converts the time to a JavaScript Date.
+func (s *Session) toDate(t time.Time) goja.Value {
+	v, err := s.newDate(goja.Undefined(), s.vm.ToValue(t.UnixNano()/int64(time.Millisecond)))
+	if err != nil {
+		return throwErr(s.vm, err)
+	}
+	return v
+}
+
 func (s *Session) tagsToValueMap(m map[string]graph.Value) map[string]interface{} {
 	outputMap := make(map[string]interface{})
 	for k, v := range m {
@@ -111,33 +180,57 @@ func (s *Session) tagsToValueMap(m map[string]graph.Value) map[string]interface{
 	}
 	return outputMap
 }
+
+// runIteratorToArray collects results for the VM. Arrays are kept by the VM until the query completes,
+// thus their memory is reserved from the account of the query and is released with it.
 func (s *Session) runIteratorToArray(it graph.Iterator, limit int) ([]map[string]interface{}, error) {
-	ctx := s.context()
+	ctx, cancel := context.WithCancel(s.context())
+	defer cancel()
+	acc := memory.FromContext(ctx)
 
+	var merr error
 	output := make([]map[string]interface{}, 0)
 	err := graph.Iterate(ctx, it).Limit(limit).TagEach(func(tags map[string]graph.Value) {
 		tm := s.tagsToValueMap(tags)
-		if tm == nil {
+		if tm == nil || merr != nil {
+			return
+		}
+		if merr = acc.Reserve(int64(len(tm)+1) * memory.ValueSize); merr != nil {
+			cancel()
 			return
 		}
 		output = append(output, tm)
 	})
-	if err != nil {
+	if merr != nil {
+		return nil, merr
+	} else if err != nil {
 		return nil, err
 	}
 	return output, nil
 }
 
 func (s *Session) runIteratorToArrayNoTags(it graph.Iterator, limit int) ([]interface{}, error) {
-	ctx := s.context()
+	ctx, cancel := context.WithCancel(s.context())
+	defer cancel()
+	acc := memory.FromContext(ctx)
 
+	var merr error
 	output := make([]interface{}, 0)
 	err := graph.Iterate(ctx, it).Paths(false).Limit(limit).EachValue(s.qs, func(v quad.Value) {
+		if merr != nil {
+			return
+		}
 		if o := quadValueToNative(v); o != nil {
+			if merr = acc.Reserve(memory.ValueSize); merr != nil {
+				cancel()
+				return
+			}
 			output = append(output, o)
 		}
 	})
-	if err != nil {
+	if merr != nil {
+		return nil, merr
+	} else if err != nil {
 		return nil, err
 	}
 	return output, nil
@@ -20,13 +20,19 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/graphtest/testutil"
-	_ "github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/graph/text"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/geojson"
 	"github.com/cayleygraph/cayley/query"
 	_ "github.com/cayleygraph/cayley/writer"
 
@@ -158,6 +164,56 @@ var testQueries = []struct {
 		`,
 		expect: []string{"<alice>"},
 	},
+	{
+		message: "use .Text()",
+		query: `
+			g.V("<emily>", "<bob>").Out("<status>").Text("person COOL").All()
+		`,
+		expect: []string{"cool_person"},
+	},
+	{
+		message: "use .Has() with text filter",
+		query: `
+			g.V().Has("<status>", text("smart")).All()
+		`,
+		expect: []string{"<emily>", "<greg>"},
+	},
+	{
+		message: "use .Has() with fuzzy filter",
+		query: `
+			g.V().Has("<status>", fuzzy("smrt_persn", 2)).All()
+		`,
+		expect: []string{"<emily>", "<greg>"},
+	},
+	{
+		message: "use .Out() with soundsLike filter",
+		query: `
+			g.V("<emily>", "<bob>").Out("<status>").Filter(soundsLike("kool", "metaphone")).All()
+		`,
+		expect: []string{"cool_person"},
+	},
+	{
+		message: "use .Out() with prefix filter",
+		query: `
+			g.V("<greg>").Out("<status>").Filter(prefix("SMA")).All()
+		`,
+		expect: []string{"smart_person"},
+	},
+	{
+		message: "use .OutProperties()",
+		query: `
+			g.V("<bob>").OutProperties("%stat%", "pred").All()
+		`,
+		tag:    "pred",
+		expect: []string{"<status>"},
+	},
+	{
+		message: "use .InProperties()",
+		query: `
+			g.V("<fred>").InProperties("fol%").All()
+		`,
+		expect: []string{"<bob>", "<emily>"},
+	},
 	{
 		message: "use .In() with .Filter(regex with IRIs)",
 		query: `
@@ -584,6 +640,45 @@ var testQueries = []struct {
 		`,
 		expect: []string{"<bob>", "<dani>", "<fred>", "<greg>"},
 	},
+	{
+		message: "recursive follow with path",
+		query: `
+			g.V("<charlie>").FollowRecursivePath("<follows>").Is("<greg>").All();
+		`,
+		tag:    "hop1",
+		expect: []string{"<dani>"},
+	},
+	{
+		message: "recursive follow with path tag",
+		query: `
+			g.V("<charlie>").FollowRecursivePath(g.M().Out("<follows>"), 2, "step").Is("<fred>").All();
+		`,
+		tag:    "step0",
+		expect: []string{"<charlie>"},
+	},
+	{
+		message: "shortest path",
+		query: `
+			g.V("<alice>", "<charlie>").ShortestPathTo("<greg>", "<follows>").All();
+		`,
+		expect: []string{"<greg>", "<greg>"},
+	},
+	{
+		message: "shortest path hops",
+		query: `
+			g.V("<alice>").ShortestPathTo(g.V("<greg>"), "<follows>").All();
+		`,
+		tag:    "hop2",
+		expect: []string{"<fred>"},
+	},
+	{
+		message: "shortest path tag",
+		query: `
+			g.V("<charlie>").ShortestPathTo("<greg>", g.M().Out("<follows>"), 3, "step").All();
+		`,
+		tag:    "step1",
+		expect: []string{"<dani>"},
+	},
 	{
 		message: "find non-existent",
 		query: `
@@ -744,6 +839,316 @@ g.Emit({id: x.id})
 	require.NoError(t, err)
 }
 
+func TestTextScore(t *testing.T) {
+	ctx := context.TODO()
+	qs, err := text.NewQuadStore(ctx, memstore.New(
+		quad.Make(quad.IRI("a"), quad.IRI("bio"), quad.String("Graph databases and friends"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("bio"), quad.String("Graph databases"), nil),
+		quad.Make(quad.IRI("c"), quad.IRI("bio"), quad.String("Documents"), nil),
+	), nil)
+	require.NoError(t, err)
+	ses := NewSession(qs)
+
+	const qu = `g.V().Out("<bio>").Text("databases").In("<bio>").OrderBy("_score", true).All()`
+	c := make(chan query.Result, 5)
+	go ses.Execute(ctx, qu, c, -1)
+	var got []quad.Value
+	for res := range c {
+		require.NoError(t, res.Err())
+		tags := res.(*Result).Tags
+		require.IsType(t, quad.Float(0), qs.NameOf(tags[text.ScoreTag]))
+		got = append(got, qs.NameOf(tags[TopResultTag]))
+	}
+	// shorter values are more relevant
+	require.Equal(t, []quad.Value{quad.IRI("b"), quad.IRI("a")}, got)
+}
+
+func TestGeo(t *testing.T) {
+	ctx := context.TODO()
+	loc := quad.IRI("location")
+	qs := memstore.New(
+		quad.Make(quad.IRI("sf"), loc, geo.Point{Lat: 37.7749, Lng: -122.4194}.Value(), nil),
+		quad.Make(quad.IRI("oakland"), loc, geo.Point{Lat: 37.8044, Lng: -122.2712}.Value(), nil),
+		quad.Make(quad.IRI("la"), loc, geo.Point{Lat: 34.0522, Lng: -118.2437}.Value(), nil),
+	)
+	ses := NewSession(qs)
+	run := func(qu string) []string {
+		c := make(chan query.Result, 5)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []string
+		for res := range c {
+			require.NoError(t, res.Err())
+			got = append(got, qs.NameOf(res.(*Result).Tags[TopResultTag]).String())
+		}
+		sort.Strings(got)
+		return got
+	}
+	require.Equal(t, []string{"<oakland>", "<sf>"},
+		run(`g.V().Out("<location>").Near(37.7749, -122.4194, 20000).In("<location>").All()`))
+	require.Equal(t, []string{"<la>"},
+		run(`g.V().Out("<location>").Within([[33, -119], [35, -119], [35, -118], [33, -118]]).In("<location>").All()`))
+	require.Equal(t, []string{"<la>"},
+		run(`g.V().Out("<location>").Within("POLYGON((-119 33, -119 35, -118 35, -118 33, -119 33))").In("<location>").All()`))
+	require.Equal(t, []string{"<la>"},
+		run(`g.V().Out("<location>").Within({type: "Polygon", coordinates: [[[-119, 33], [-119, 35], [-118, 35], [-118, 33], [-119, 33]]]}).In("<location>").All()`))
+	require.Equal(t, []string{"<oakland>", "<sf>"},
+		run(`g.V().Out("<location>").WithinBox(37, -123, 38.5, -121.5).In("<location>").All()`))
+}
+
+func TestGeoDistance(t *testing.T) {
+	ctx := context.TODO()
+	loc := quad.IRI("location")
+	sf := geo.Point{Lat: 37.7749, Lng: -122.4194}
+	qs := memstore.New(
+		quad.Make(quad.IRI("sf"), loc, sf.Value(), nil),
+		quad.Make(quad.IRI("oakland"), loc, geo.Point{Lat: 37.8044, Lng: -122.2712}.Value(), nil),
+		quad.Make(quad.IRI("la"), loc, geo.Point{Lat: 34.0522, Lng: -118.2437}.Value(), nil),
+		quad.Make(quad.IRI("office"), geojson.AsWKT, geo.Point{Lat: 37.79, Lng: -122.4}.Value(), nil),
+	)
+	ses := NewSession(qs)
+	run := func(qu string) ([]interface{}, error) {
+		c := make(chan query.Result, 5)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []interface{}
+		for res := range c {
+			if err := res.Err(); err != nil {
+				return nil, err
+			}
+			r := res.(*Result)
+			if r.Val != nil {
+				got = append(got, r.Val)
+			} else {
+				got = append(got, qs.NameOf(r.Tags[TopResultTag]).String())
+			}
+		}
+		return got, nil
+	}
+	got, err := run(`g.Emit(geo.distance([37.7749, -122.4194], {lat: 34.0522, lng: -118.2437}))`)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.InDelta(t, 559000, got[0], 1000)
+
+	got, err = run(`g.Emit(geo.distance("POINT(-122.4194 37.7749)", [37.7749, -122.4194]))`)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.InDelta(t, 0, got[0], 1e-6)
+
+	got, err = run(`g.V("<sf>").Save("<location>", "loc").ForEach(function(d) { g.Emit(geo.distance(d.loc, [37.7749, -122.4194])) })`)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.InDelta(t, 0, got[0], 1e-6)
+
+	got, err = run(`g.V().Out("<location>").WithinDistanceOf([37.7749, -122.4194], 20000).In("<location>").All()`)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []interface{}{"<sf>", "<oakland>"}, got)
+
+	got, err = run(`g.V().Out("<location>").WithinDistanceOf("<office>", 5000).In("<location>").All()`)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"<sf>"}, got)
+
+	_, err = run(`g.V().Out("<location>").WithinDistanceOf("<la>", 5000).All()`)
+	require.Error(t, err)
+}
+
+func TestAsOf(t *testing.T) {
+	ctx := context.TODO()
+	alice, works := quad.IRI("alice"), quad.IRI("worksFor")
+	quads := []quad.Quad{
+		quad.Make(alice, works, quad.IRI("acme"), quad.IRI("job1")),
+		quad.Make(alice, works, quad.IRI("globex"), quad.IRI("job2")),
+	}
+	quads = append(quads, temporal.Validity(quad.IRI("job1"), time.Date(2015, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC))...)
+	quads = append(quads, temporal.Validity(quad.IRI("job2"), time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC), time.Time{})...)
+	qs := memstore.New(quads...)
+	ses := NewSession(qs)
+	run := func(qu string) []string {
+		c := make(chan query.Result, 5)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []string
+		for res := range c {
+			require.NoError(t, res.Err())
+			got = append(got, qs.NameOf(res.(*Result).Tags[TopResultTag]).String())
+		}
+		return got
+	}
+	require.Equal(t, []string{"<acme>"},
+		run(`g.V("<alice>").AsOf(new Date("2016-01-01")).Out("<worksFor>").All()`))
+	require.Equal(t, []string{"<globex>"},
+		run(`g.V("<alice>").AsOf("2020-01-01T00:00:00Z").Out("<worksFor>").All()`))
+}
+
+func TestInterval(t *testing.T) {
+	ctx := context.TODO()
+	at := func(h int) quad.Time {
+		return quad.Time(time.Date(2019, 1, 2, h, 0, 0, 0, time.UTC))
+	}
+	start, end := quad.IRI("start"), quad.IRI("end")
+	qs := memstore.New(
+		quad.Make(quad.IRI("standup"), start, at(9), nil),
+		quad.Make(quad.IRI("standup"), end, at(10), nil),
+		quad.Make(quad.IRI("review"), start, at(11), nil),
+		quad.Make(quad.IRI("review"), end, at(13), nil),
+	)
+	ses := NewSession(qs)
+	run := func(qu string) ([]string, error) {
+		c := make(chan query.Result, 5)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []string
+		for res := range c {
+			if err := res.Err(); err != nil {
+				return nil, err
+			}
+			got = append(got, qs.NameOf(res.(*Result).Tags[TopResultTag]).String())
+		}
+		return got, nil
+	}
+	got, err := run(`g.V().Interval("<start>", "<end>", "overlaps", "2019-01-02T12:00:00Z", "2019-01-02T14:00:00Z").All()`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"<review>"}, got)
+	got, err = run(`g.V().Interval("<start>", "<end>", "before", new Date("2019-01-02T12:00:00Z"), new Date("2019-01-02T14:00:00Z")).All()`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"<standup>"}, got)
+	_, err = run(`g.V().Interval("<start>", "<end>", "intersects", "2019-01-02T12:00:00Z", "2019-01-02T14:00:00Z").All()`)
+	require.Error(t, err)
+}
+
+func TestSequence(t *testing.T) {
+	ctx := context.TODO()
+	at := func(h int) quad.Time {
+		return quad.Time(time.Date(2019, 1, 2, h, 0, 0, 0, time.UTC))
+	}
+	did, when := quad.IRI("did"), quad.IRI("at")
+	qs := memstore.New(
+		quad.Make(quad.IRI("alice"), did, quad.IRI("login"), nil),
+		quad.Make(quad.IRI("login"), when, at(9), nil),
+		quad.Make(quad.IRI("alice"), did, quad.IRI("logout"), nil),
+		quad.Make(quad.IRI("logout"), when, at(17), nil),
+		quad.Make(quad.IRI("alice"), did, quad.IRI("edit"), nil),
+		quad.Make(quad.IRI("edit"), when, at(11), nil),
+		quad.Make(quad.IRI("alice"), did, quad.IRI("draft"), nil),
+		quad.Make(quad.IRI("bob"), did, quad.IRI("view"), nil),
+		quad.Make(quad.IRI("view"), when, at(10), nil),
+	)
+	ses := NewSession(qs)
+	run := func(qu string, tags ...string) ([]string, error) {
+		c := make(chan query.Result, 5)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []string
+		for res := range c {
+			if err := res.Err(); err != nil {
+				return nil, err
+			}
+			r := res.(*Result).Tags
+			s := qs.NameOf(r[TopResultTag]).String()
+			for _, tag := range tags {
+				s += fmt.Sprintf(" %v", qs.NameOf(r[tag]).Native())
+			}
+			got = append(got, s)
+		}
+		return got, nil
+	}
+	got, err := run(`g.V("<alice>", "<bob>").Sequence("<did>", "<at>").All()`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"<login>", "<edit>", "<logout>", "<view>"}, got)
+
+	got, err = run(`g.V("<alice>").Sequence("<did>", "<at>", {desc: true, limit: 2, indexTag: "i"}).All()`, "i")
+	require.NoError(t, err)
+	require.Equal(t, []string{"<logout> 0", "<edit> 1"}, got)
+
+	got, err = run(`g.V("<alice>").Sequence("<did>", "<at>", {from: "2019-01-02T10:00:00Z", to: new Date("2019-01-02T17:00:00Z"), timeTag: "at"}).All()`, "at")
+	require.NoError(t, err)
+	require.Equal(t, []string{"<edit> 2019-01-02 11:00:00 +0000 UTC"}, got)
+
+	_, err = run(`g.V("<alice>").Sequence("<did>", "<at>", {window: 2}).All()`)
+	require.Error(t, err)
+}
+
+func TestTimeFunctions(t *testing.T) {
+	ctx := context.TODO()
+	at := quad.IRI("at")
+	qs := memstore.New(
+		quad.Make(quad.IRI("a"), at, quad.Time(time.Date(2018, 12, 31, 23, 0, 0, 0, time.UTC)), nil),
+		quad.Make(quad.IRI("b"), at, quad.Time(time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)), nil),
+		quad.Make(quad.IRI("c"), at, quad.Time(time.Now().Add(-time.Hour)), nil),
+	)
+	ses := NewSession(qs)
+	run := func(qu string) ([]interface{}, error) {
+		c := make(chan query.Result, 5)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []interface{}
+		for res := range c {
+			if err := res.Err(); err != nil {
+				return nil, err
+			}
+			r := res.(*Result)
+			if r.Val != nil {
+				got = append(got, r.Val)
+			} else {
+				got = append(got, qs.NameOf(r.Tags[TopResultTag]).String())
+			}
+		}
+		return got, nil
+	}
+	for _, c := range []struct {
+		qu  string
+		exp []interface{}
+	}{
+		{`g.V().Has("<at>", datePart("year", 2019)).All()`, []interface{}{"<b>"}},
+		{`g.V().Has("<at>", gt(time.parse("now-1d"))).All()`, []interface{}{"<c>"}},
+		{`g.V().Has("<at>", gte(time.truncate(new Date("2019-01-02T15:00:00Z"), "day")), lt(time.add("2019-01-02T00:00:00Z", 1, "day"))).All()`, []interface{}{"<b>"}},
+		{`g.Emit(time.year("2019-01-02T10:00:00Z"))`, []interface{}{int64(2019)}},
+		{`g.Emit(time.truncate(time.parse("2019-01-15T10:00:00Z||+1M"), "month").toISOString())`, []interface{}{"2019-02-01T00:00:00.000Z"}},
+		{`g.Emit(time.weekday(new Date("2019-01-02T10:00:00Z")))`, []interface{}{int64(3)}},
+		{`g.Emit(time.now() > new Date("2019-01-01"))`, []interface{}{true}},
+	} {
+		got, err := run(c.qu)
+		require.NoError(t, err, c.qu)
+		require.Equal(t, c.exp, got, c.qu)
+	}
+	_, err := run(`g.Emit(time.add(time.now(), 1, "fortnight"))`)
+	require.Error(t, err)
+}
+
+func TestGraph(t *testing.T) {
+	ctx := context.TODO()
+	base := memstore.New(
+		quad.MakeIRI("alice", "follows", "bob", "g1"),
+		quad.MakeIRI("bob", "friend", "carol", "g2"),
+		quad.MakeIRI("carol", "friend", "dave", "g3"),
+	)
+	qs := named.New(base, quad.IRI("g1"))
+	ses := NewSession(qs)
+	run := func(qu string) ([]string, error) {
+		c := make(chan query.Result, 5)
+		go ses.Execute(ctx, qu, c, -1)
+		var got []string
+		for res := range c {
+			if err := res.Err(); err != nil {
+				return nil, err
+			}
+			got = append(got, qs.NameOf(res.(*Result).Tags[TopResultTag]).String())
+		}
+		return got, nil
+	}
+	got, err := run(`g.V("<alice>").Out("<follows>").Out("<friend>").All()`)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	got, err = run(`g.Graph("<g1>", "<g2>").V("<alice>").Out("<follows>").Out("<friend>").All()`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"<carol>"}, got)
+	got, err = run(`g.Graph("<g1>", "<g2>").V("<alice>").Out("<follows>").Out("<friend>").Out("<friend>").All()`)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	// traversals into another graph must be joined explicitly
+	_, err = run(`g.V("<bob>").LabelContext("<g2>").Out("<friend>").All()`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), named.ErrCrossGraph.Error())
+	_, err = run(`g.Graph().V().All()`)
+	require.Error(t, err)
+}
+
 const issue718Limit = 5
 
 func issue718Graph() []quad.Quad {
@@ -767,3 +1172,13 @@ func issue718Nodes() []string {
 
 
 
+
+func TestCompletions(t *testing.T) {
+	globals, methods := NewSession(nil).Completions()
+	require.Contains(t, globals, "g")
+	require.Contains(t, globals, "graph")
+	require.Contains(t, methods, "V")
+	require.Contains(t, methods, "Out")
+	require.Contains(t, methods, "All")
+	require.NotContains(t, methods, "new")
+}
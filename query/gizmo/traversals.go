@@ -22,9 +22,12 @@ import (
 	"github.com/dop251/goja"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/geo"
 	"github.com/cayleygraph/cayley/graph/iterator"
 	"github.com/cayleygraph/cayley/graph/path"
 	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/graph/temporal"
+	"github.com/cayleygraph/cayley/graph/text"
 )
 
 // pathObject is a Path object in Gizmo.
@@ -57,6 +60,8 @@ type pathObject struct {
 	s      *Session
 	finals bool
 	path   *path.Path
+	// graphs joined with graph.Graph; nil for the quad store of the session
+	qs graph.QuadStore
 }
 
 func (p *pathObject) new(np *path.Path) *pathObject {
@@ -64,6 +69,7 @@ func (p *pathObject) new(np *path.Path) *pathObject {
 		s:      p.s,
 		finals: p.finals,
 		path:   np,
+		qs:     p.qs,
 	}
 }
 
@@ -81,7 +87,11 @@ func (p *pathObject) buildIteratorTree() graph.Iterator {
 	if p.path == nil {
 		return iterator.NewNull()
 	}
-	return p.path.BuildIteratorOn(p.s.qs)
+	qs := p.qs
+	if qs == nil {
+		qs = p.s.qs
+	}
+	return p.path.BuildIteratorOn(qs)
 }
 
 // Filter all paths to ones which, at this point, are on the given node.
@@ -262,6 +272,81 @@ func (p *pathObject) FollowRecursive(call goja.FunctionCall) goja.Value {
 	return p.newVal(np)
 }
 
+// FollowRecursivePath is the same as FollowRecursive, but also tags the chain of nodes each result was reached by.
+// Signature: (path, [maxDepth], [tag])
+//
+// Arguments:
+//
+// * `path`: A predicate or a morphism path to follow.
+// * `maxDepth` (Optional): A maximal number of recursive steps, the same as in FollowRecursive.
+// * `tag` (Optional): A tag prefix for the nodes of the chain. The node at step N is tagged as prefix followed by N; "hop" is used by default.
+//
+// Example:
+// 	// javascript:
+//	// Returns greg, with charlie as "hop0", dani as "hop1" and greg as "hop2".
+//	g.V("<charlie>").FollowRecursivePath("<follows>").Is("<greg>").All()
+func (p *pathObject) FollowRecursivePath(call goja.FunctionCall) goja.Value {
+	preds, maxDepth, tags, ok := toViaDepthData(exportArgs(call.Arguments))
+	if !ok || len(preds) == 0 {
+		return throwErr(p.s.vm, errNoVia)
+	} else if len(preds) != 1 {
+		return throwErr(p.s.vm, fmt.Errorf("expected one predicate or path for recursive follow"))
+	}
+	tag := path.HopTag
+	if len(tags) > 1 {
+		return throwErr(p.s.vm, fmt.Errorf("expected one tag prefix for a recursive path"))
+	} else if len(tags) == 1 {
+		tag = tags[0]
+	}
+	np := p.clonePath().FollowRecursiveWithPath(preds[0], maxDepth, nil, tag)
+	return p.newVal(np)
+}
+
+// ShortestPathTo finds the shortest path from each node of the current path to each of the target nodes.
+// Signature: (target, [predicate or path], [maxDepth], [tag])
+//
+// Arguments:
+//
+// * `target`: A query path or a node string (or a list of strings) the path should end at.
+// * `predicate or path` (Optional): A predicate to follow, a list of them, or a morphism path. All predicates are followed if it's not set.
+// * `maxDepth` (Optional): A maximal number of steps in the path, the same as in FollowRecursive.
+// * `tag` (Optional): A tag prefix for the nodes of the path. The node at step N is tagged as prefix followed by N; "hop" is used by default.
+//
+// Returns the target nodes, once for each node they are reachable from.
+//
+// Example:
+// 	// javascript:
+//	// Returns greg, with charlie as "hop0", dani as "hop1" and greg as "hop2".
+//	g.V("<charlie>").ShortestPathTo("<greg>", "<follows>").All()
+func (p *pathObject) ShortestPathTo(call goja.FunctionCall) goja.Value {
+	args := exportArgs(call.Arguments)
+	if len(args) == 0 {
+		return throwErr(p.s.vm, errArgCount{Got: len(args)})
+	}
+	var to *path.Path
+	switch t := args[0].(type) {
+	case *path.Path:
+		to = t
+	case *pathObject:
+		to = t.path
+	default:
+		nodes, err := toQuadValues(toVia([]interface{}{t}))
+		if err != nil {
+			return throwErr(p.s.vm, err)
+		}
+		to = path.StartMorphism(nodes...)
+	}
+	preds, maxDepth, tags, _ := toViaDepthData(args[1:])
+	tag := path.HopTag
+	if len(tags) > 1 {
+		return throwErr(p.s.vm, fmt.Errorf("expected one tag prefix for a shortest path"))
+	} else if len(tags) == 1 {
+		tag = tags[0]
+	}
+	np := p.clonePath().ShortestPathTo(to, maxDepth, tag, preds...)
+	return p.newVal(np)
+}
+
 // And is an alias for Intersect.
 func (p *pathObject) And(path *pathObject) *pathObject {
 	return p.Intersect(path)
@@ -580,6 +665,36 @@ func (p *pathObject) OutPredicates() *pathObject {
 	return p.new(np)
 }
 
+// OutProperties follows all outgoing predicates with IRIs matching a wildcard pattern, as in the like filter.
+// It can be used to search values across many properties at once.
+// Signature: (pattern, [tags])
+//
+// Arguments:
+//
+// * `pattern`: A pattern of predicate IRIs: `%` matches zero or more characters, `?` matches exactly one.
+// * `tags` (Optional): Tags to save the predicate of each value to.
+//
+// Example:
+// 	// javascript
+//	// returns {"id": "cool_person", "pred": "<status>"}
+//	g.V("<bob>").OutProperties("%stat%", "pred").All()
+func (p *pathObject) OutProperties(pattern string, tags ...string) *pathObject {
+	np := p.clonePath().OutProperties(pattern, tags...)
+	return p.new(np)
+}
+
+// InProperties is the same as OutProperties, but follows incoming predicates.
+// Signature: (pattern, [tags])
+//
+// Example:
+// 	// javascript
+//	// returns bob, dani and greg
+//	g.V("cool_person").InProperties("status").All()
+func (p *pathObject) InProperties(pattern string, tags ...string) *pathObject {
+	np := p.clonePath().InProperties(pattern, tags...)
+	return p.new(np)
+}
+
 // SaveInPredicates tags the list of predicates that are pointing in to a node.
 //
 // Example:
@@ -637,6 +752,106 @@ func (p *pathObject) LabelContext(call goja.FunctionCall) goja.Value {
 	return p.newVal(np)
 }
 
+// AsOf restricts the following traversals to edges that are valid at a given time.
+// The valid time is set on labels of quads with <cayley:validFrom> and <cayley:validTo> predicates;
+// edges without a label, or with a label that has no valid time, are always valid.
+// It narrows the current LabelContext, and the next LabelContext call replaces it.
+//
+// Arguments:
+//
+// * `time`: A Date, or a string in RFC 3339 format.
+//
+// Example:
+// 	// javascript
+//	// Find where Alice worked at the beginning of 2016.
+//	g.V("<alice>").AsOf(new Date("2016-01-01")).Out("<worksFor>").All()
+func (p *pathObject) AsOf(v interface{}) (*pathObject, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return nil, fmt.Errorf("asOf: %v", err)
+	}
+	np := p.clonePath().AsOf(t)
+	return p.new(np), nil
+}
+
+// Interval filters nodes with intervals that are in a given relation to another interval.
+// The interval of a node is set by time values of the start and end predicates; nodes without either of them
+// are excluded.
+//
+// Arguments:
+//
+// * `start`: A predicate of the start time.
+// * `end`: A predicate of the end time.
+// * `relation`: One of Allen's interval relations: "before", "after", "meets", "metBy", "overlaps", "overlappedBy",
+// "during", "contains", "starts", "startedBy", "finishes", "finishedBy" or "equals".
+// * `from`, `to`: The other interval, as Dates or strings in RFC 3339 format.
+//
+// Example:
+// 	// javascript
+//	// Find meetings that overlap with the lunch break.
+//	g.V().Interval("<start>", "<end>", "overlaps", "2019-01-02T12:00:00Z", "2019-01-02T13:00:00Z").All()
+func (p *pathObject) Interval(start, end interface{}, relation string, from, to interface{}) (*pathObject, error) {
+	rel, err := temporal.ParseRelation(relation)
+	if err != nil {
+		return nil, err
+	}
+	var iv temporal.Interval
+	if iv.Start, err = toTime(from); err != nil {
+		return nil, fmt.Errorf("interval: %v", err)
+	}
+	if iv.End, err = toTime(to); err != nil {
+		return nil, fmt.Errorf("interval: %v", err)
+	}
+	if start, err = toPredicate(start); err != nil {
+		return nil, err
+	}
+	if end, err = toPredicate(end); err != nil {
+		return nil, err
+	}
+	np := p.clonePath().Interval(start, end, rel, iv)
+	return p.new(np), nil
+}
+
+// Sequence follows events of each node and returns them ordered by time, for example, to get an activity stream of a user.
+// Signature: (predicate or path, timePredicate, [options])
+//
+// Sequences of nodes are returned one after another; events without the time predicate are skipped.
+//
+// Arguments:
+//
+// * `predicate or path`: A predicate from nodes to their events, or a morphism path.
+// * `timePredicate`: A predicate of the event time.
+// * `options` (Optional): An object with the following fields, all of them are optional:
+//   * `from`, `to`: A time window of events, as Dates or strings in RFC 3339 format. The end is exclusive.
+//   * `desc`: Return the latest events first.
+//   * `skip`, `limit`: Select a window of each sequence.
+//   * `timeTag`: A tag for the time of each event.
+//   * `indexTag`: A tag for the position of each event in its sequence, starting from zero.
+//
+// Example:
+// 	// javascript
+//	// Find the last 10 actions of Alice.
+//	g.V("<alice>").Sequence("<did>", "<at>", {desc: true, limit: 10, timeTag: "at"}).All()
+func (p *pathObject) Sequence(via, at interface{}, options ...map[string]interface{}) (*pathObject, error) {
+	var err error
+	if via, err = toPredicate(via); err != nil {
+		return nil, err
+	}
+	if at, err = toPredicate(at); err != nil {
+		return nil, err
+	}
+	var opts path.SequenceOptions
+	if len(options) > 1 {
+		return nil, errArgCount{Got: len(options) + 2}
+	} else if len(options) == 1 {
+		if opts, err = toSequenceOptions(options[0]); err != nil {
+			return nil, fmt.Errorf("sequence: %v", err)
+		}
+	}
+	np := p.clonePath().Sequence(via, at, opts)
+	return p.new(np), nil
+}
+
 // Filter applies constraints to a set of nodes. Can be used to filter values by range or match strings.
 func (p *pathObject) Filter(args ...valFilter) (*pathObject, error) {
 	if len(args) == 0 {
@@ -650,6 +865,133 @@ func (p *pathObject) Filter(args ...valFilter) (*pathObject, error) {
 	return p.new(np), nil
 }
 
+// Text filters string literals by a full-text query. All terms of the query must be present in the value.
+// Signature: (query, [minScore])
+//
+// If the database maintains a full-text index, a relevance score of each match is saved to the "_score" tag.
+//
+// Arguments:
+//
+// * `query`: A text to search for.
+// * `minScore` (Optional): Drop matches with a lower relevance score.
+//
+// Example:
+// 	// javascript
+//	// Find all nodes with a status that mentions "smart" and "person".
+//	g.V().Out("<status>").Text("smart person").All()
+func (p *pathObject) Text(query string, minScore ...float64) *pathObject {
+	var min float64
+	if len(minScore) != 0 {
+		min = minScore[0]
+	}
+	np := p.clonePath().TextScored(query, text.ScoreTag, min)
+	return p.new(np)
+}
+
+// Near filters point values within a given distance from a location.
+// Points are stored as WKT literals, for example, "POINT(-122.4194 37.7749)"^^<http://www.opengis.net/ont/geosparql#wktLiteral>.
+//
+// Arguments:
+//
+// * `lat`: A latitude of the location, in degrees.
+// * `lng`: A longitude of the location, in degrees.
+// * `radius`: A distance from the location, in meters.
+//
+// Example:
+// 	// javascript
+//	// Find places within 5 km from the center of San Francisco.
+//	g.V().Out("<location>").Near(37.7749, -122.4194, 5000).In("<location>").All()
+func (p *pathObject) Near(lat, lng, radius float64) *pathObject {
+	np := p.clonePath().Near(lat, lng, radius)
+	return p.new(np)
+}
+
+// Within filters point values that are inside a polygon.
+//
+// Arguments:
+//
+// * `polygon`: An array of polygon vertices, each as a [lat, lng] pair, a WKT polygon, or a GeoJSON Polygon geometry.
+// Polygons with holes are not supported.
+//
+// Example:
+// 	// javascript
+//	// Find places inside a triangle.
+//	g.V().Out("<location>").Within([[37.7, -122.5], [37.8, -122.5], [37.8, -122.3]]).In("<location>").All()
+//	// The same, as a WKT polygon, which lists the longitude first.
+//	g.V().Out("<location>").Within("POLYGON((-122.5 37.7, -122.5 37.8, -122.3 37.8))").In("<location>").All()
+func (p *pathObject) Within(polygon interface{}) (*pathObject, error) {
+	poly, err := toPolygon(polygon)
+	if err != nil {
+		return nil, fmt.Errorf("within: %v", err)
+	}
+	np := p.clonePath().Within(poly...)
+	return p.new(np), nil
+}
+
+// WithinBox filters point values that are inside a latitude-longitude rectangle, including its edges.
+//
+// Arguments:
+//
+// * `minLat`, `minLng`: The south-west corner of the box.
+// * `maxLat`, `maxLng`: The north-east corner of the box. If maxLng is less than minLng, the box crosses the antimeridian.
+//
+// Example:
+// 	// javascript
+//	// Find places in the Bay Area.
+//	g.V().Out("<location>").WithinBox(37, -123, 38.5, -121.5).In("<location>").All()
+func (p *pathObject) WithinBox(minLat, minLng, maxLat, maxLng float64) *pathObject {
+	np := p.clonePath().WithinBox(geo.Rect{MinLat: minLat, MinLng: minLng, MaxLat: maxLat, MaxLng: maxLng})
+	return p.new(np)
+}
+
+// WithinDistanceOf filters point values within a given distance from a point or from the location of a node.
+// The location of a node is a WKT literal in its geo:asWKT property, as in GeoJSON imports.
+//
+// Arguments:
+//
+// * `node`: A point, either as a [lat, lng] pair, an object with lat and lng fields or a WKT literal, or a node with a location.
+// * `meters`: A distance from the point.
+//
+// Example:
+// 	// javascript
+//	// Find places within 5 km from the office.
+//	g.V().Out("<location>").WithinDistanceOf("<office>", 5000).In("<location>").All()
+func (p *pathObject) WithinDistanceOf(node interface{}, meters float64) (*pathObject, error) {
+	pt, err := toPoint(node)
+	if err != nil {
+		qv, err2 := toQuadValue(node)
+		if err2 != nil {
+			return nil, fmt.Errorf("withinDistanceOf: %v", err)
+		}
+		if pt, err = p.s.locationOf(qv); err != nil {
+			return nil, fmt.Errorf("withinDistanceOf: %v", err)
+		}
+	}
+	np := p.clonePath().Near(pt.Lat, pt.Lng, meters)
+	return p.new(np), nil
+}
+
+// OrderBy sorts nodes by values of a tag, for example, by a relevance score of Text.
+// Signature: (tag, [desc])
+//
+// Arguments:
+//
+// * `tag`: A tag to sort by. Nodes without it go last.
+// * `desc` (Optional): Sort in a descending order.
+//
+// Example:
+// 	// javascript
+//	// Find statuses that mention "person", the most relevant first.
+//	g.V().Out("<status>").Text("person").OrderBy("_score", true).All()
+func (p *pathObject) OrderBy(tag string, desc ...bool) *pathObject {
+	o := path.Asc
+	if len(desc) != 0 && desc[0] {
+		o = path.Desc
+	}
+	np := p.clonePath().OrderBy(tag, o)
+	return p.new(np)
+}
+
 // Limit limits a number of nodes for current path.
 //
 // Arguments:
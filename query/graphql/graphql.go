@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/dennwc/graphql/language/ast"
@@ -15,7 +16,11 @@ import (
 	"github.com/dennwc/graphql/language/parser"
 
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/named"
 	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/graph/shape"
+	"github.com/cayleygraph/cayley/graph/temporal"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/cayleygraph/cayley/query"
 )
@@ -95,10 +100,16 @@ var (
 	LimitKey = "first"
 	SkipKey  = "offset"
 	AnyKey   = "*"
+	ScoreKey = "_score"
+
+	// MinScoreKey is an argument of the text directive that drops matches with a lower relevance score.
+	MinScoreKey = "minScore"
 )
 
 type Query struct {
 	fields []field
+	// named graphs joined with the @graph directive
+	graphs []quad.Value
 }
 
 type has struct {
@@ -108,6 +119,23 @@ type has struct {
 	Labels []quad.Value
 }
 
+// filter is a constraint on values of a property, given by an object argument.
+type filter struct {
+	Via     quad.IRI
+	Rev     bool
+	Filters []shape.ValueFilter
+	Labels  []quad.Value
+}
+
+// text is a full-text filter on a property.
+type text struct {
+	Via      quad.IRI
+	Rev      bool
+	Query    string
+	MinScore float64
+	Labels   []quad.Value
+}
+
 type field struct {
 	Via       quad.IRI
 	Alias     string
@@ -115,12 +143,16 @@ type field struct {
 	Opt       bool
 	Labels    []quad.Value
 	Has       []has
+	Filter    []filter
+	Text      []text
 	Fields    []field
 	AllFields bool // fetch all fields
 	UnNest    bool // all fields will be saved to parent object
 }
 
-func (f field) isSave() bool { return len(f.Has)+len(f.Fields) == 0 && !f.AllFields }
+func (f field) isSave() bool {
+	return len(f.Has)+len(f.Filter)+len(f.Text)+len(f.Fields) == 0 && !f.AllFields
+}
 
 type object struct {
 	id     graph.Value
@@ -178,7 +210,41 @@ func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Pa
 			}
 		}
 	}
+	for _, h := range f.Filter {
+		if h.Via == quad.IRI(ValueKey) {
+			p = p.Filters(h.Filters...)
+			continue
+		}
+		if len(h.Labels) != 0 {
+			p = p.LabelContext(h.Labels)
+		}
+		p = p.HasFilter(h.Via, h.Rev, h.Filters...)
+		if len(h.Labels) != 0 {
+			p = p.LabelContext()
+		}
+	}
+	// relevance score is only known for nodes matched by a full-text filter
+	var score string
+	if len(f.Text) != 0 {
+		for _, f2 := range f.Fields {
+			if f2.Via == quad.IRI(ScoreKey) && f2.isSave() {
+				score = f2.Alias
+			}
+		}
+	}
+	for _, t := range f.Text {
+		if len(t.Labels) != 0 {
+			p = p.LabelContext(t.Labels)
+		}
+		p = p.HasFilter(t.Via, t.Rev, shape.Text{Query: t.Query, ScoreTag: score, MinScore: t.MinScore})
+		if len(t.Labels) != 0 {
+			p = p.LabelContext()
+		}
+	}
 	tail := func() {
+		if score != "" {
+			p = p.OrderBy(score, path.Desc)
+		}
 		if skip > 0 {
 			p = p.Skip(int64(skip))
 		}
@@ -233,6 +299,8 @@ func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Pa
 		if f2.Via == quad.IRI(ValueKey) {
 			p = p.Tag(f2.Alias)
 			continue
+		} else if f2.Via == quad.IRI(ScoreKey) {
+			continue // tagged by text filters
 		}
 		if len(f2.Labels) != 0 {
 			p = p.LabelContext(f2.Labels)
@@ -370,6 +438,9 @@ func iterateObject(ctx context.Context, qs graph.QuadStore, f *field, p *path.Pa
 }
 
 func (q *Query) Execute(ctx context.Context, qs graph.QuadStore) (map[string]interface{}, error) {
+	if len(q.graphs) != 0 {
+		qs = named.Join(named.Base(qs), q.graphs...)
+	}
 	out := make(map[string]interface{})
 	for _, f := range q.fields {
 		arr, err := iterateObject(ctx, qs, &f, path.StartPath(qs))
@@ -405,13 +476,38 @@ func Parse(r io.Reader) (*Query, error) {
 	} else if def.Operation != "query" {
 		return nil, fmt.Errorf("unsupported operation: %s", def.Operation)
 	}
+	graphs, err := convGraphs(def.Directives)
+	if err != nil {
+		return nil, err
+	}
 	fields, all, err := setToFields(def.SelectionSet, nil)
 	if err != nil {
 		return nil, err
 	} else if all {
 		return nil, fmt.Errorf("expand all is not supported at top level")
 	}
-	return &Query{fields: fields}, nil
+	return &Query{fields: fields, graphs: graphs}, nil
+}
+
+// convGraphs returns labels of named graphs joined by the query with the @graph directive.
+func convGraphs(dirs []*ast.Directive) ([]quad.Value, error) {
+	var out []quad.Value
+	for _, d := range dirs {
+		if d.Name == nil {
+			continue
+		} else if d.Name.Value != "graph" {
+			return nil, fmt.Errorf("unknown query directive: %q", d.Name.Value)
+		}
+		if len(d.Arguments) != 1 || d.Arguments[0].Name == nil || d.Arguments[0].Name.Value != "v" {
+			return nil, fmt.Errorf("graph directive should have 'v' argument")
+		}
+		vals, err := convValue(d.Arguments[0].Value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing graph: %v", err)
+		}
+		out = append(out, vals...)
+	}
+	return out, nil
 }
 
 func setToFields(set *ast.SelectionSet, labels []quad.Value) (out []field, all bool, _ error) {
@@ -428,7 +524,7 @@ func setToFields(set *ast.SelectionSet, labels []quad.Value) (out []field, all b
 			if fld.Via == quad.IRI(AnyKey) {
 				if len(set.Selections) != 1 {
 					return nil, false, fmt.Errorf("expand all cannot be used with other fields")
-				} else if len(fld.Has) != 0 || len(fld.Fields) != 0 {
+				} else if len(fld.Has) != 0 || len(fld.Filter) != 0 || len(fld.Text) != 0 || len(fld.Fields) != 0 {
 					return nil, false, fmt.Errorf("filters inside expand all are not supported")
 				}
 				return nil, true, nil
@@ -452,22 +548,65 @@ func stringToVia(s string) (_ quad.IRI, rev bool) {
 	return quad.IRI(s), rev
 }
 
-func argsToHas(dst []has, args []*ast.Argument, rev bool, labels []quad.Value) (out []has, err error) {
-	out = dst
+func argsToHas(dst []has, fdst []filter, args []*ast.Argument, rev bool, labels []quad.Value) (out []has, fout []filter, err error) {
+	out, fout = dst, fdst
 	for _, arg := range args {
+		via, hrev := stringToVia(arg.Name.Value)
+		hrev = hrev != rev
+		if obj, ok := arg.Value.(*ast.ObjectValue); ok {
+			var filt []shape.ValueFilter
+			filt, err = convFilters(obj)
+			if err != nil {
+				return
+			}
+			fout = append(fout, filter{Via: via, Rev: hrev, Filters: filt, Labels: labels})
+			continue
+		}
 		var vals []quad.Value
 		vals, err = convValue(arg.Value)
 		if err != nil {
 			return
 		}
-		h := has{Values: vals, Labels: labels}
-		h.Via, h.Rev = stringToVia(arg.Name.Value)
-		h.Rev = h.Rev != rev
-		out = append(out, h)
+		out = append(out, has{Via: via, Rev: hrev, Values: vals, Labels: labels})
 	}
 	return
 }
 
+func argsToText(dst []text, args []*ast.Argument, labels []quad.Value) ([]text, error) {
+	first := len(dst)
+	var min float64
+	for _, arg := range args {
+		if arg.Name.Value == MinScoreKey {
+			vals, err := convValue(arg.Value)
+			if err != nil {
+				return dst, err
+			} else if len(vals) != 1 {
+				return dst, fmt.Errorf("%s expects a single number", MinScoreKey)
+			}
+			switch v := vals[0].(type) {
+			case quad.Int:
+				min = float64(v)
+			case quad.Float:
+				min = float64(v)
+			default:
+				return dst, fmt.Errorf("%s expects a number, got: %T", MinScoreKey, v)
+			}
+			continue
+		}
+		sv, ok := arg.Value.(*ast.StringValue)
+		if !ok {
+			return dst, fmt.Errorf("text directive expects string values, got: %T", arg.Value)
+		}
+		t := text{Query: sv.Value, Labels: labels}
+		t.Via, t.Rev = stringToVia(arg.Name.Value)
+		dst = append(dst, t)
+	}
+	for i := first; i < len(dst); i++ {
+		dst[i].MinScore = min
+	}
+	return dst, nil
+}
+
 func convField(fld *ast.Field, labels []quad.Value) (out field, err error) {
 	out.Labels = labels
 	name := fld.Name.Value
@@ -508,11 +647,16 @@ func convField(fld *ast.Field, labels []quad.Value) (out field, err error) {
 			if len(d.Arguments) == 0 {
 				out.Rev = out.Rev != true
 			} else {
-				out.Has, err = argsToHas(out.Has, d.Arguments, true, out.Labels)
+				out.Has, out.Filter, err = argsToHas(out.Has, out.Filter, d.Arguments, true, out.Labels)
 				if err != nil {
 					return
 				}
 			}
+		case "text":
+			out.Text, err = argsToText(out.Text, d.Arguments, out.Labels)
+			if err != nil {
+				return
+			}
 		case "opt", "optional":
 			out.Opt = true
 		case "label":
@@ -527,13 +671,77 @@ func convField(fld *ast.Field, labels []quad.Value) (out field, err error) {
 	if err != nil {
 		return
 	}
-	out.Has, err = argsToHas(out.Has, fld.Arguments, false, out.Labels)
+	out.Has, out.Filter, err = argsToHas(out.Has, out.Filter, fld.Arguments, false, out.Labels)
 	if err != nil {
 		return
 	}
 	return
 }
 
+// comparisons are the keys of filter objects that compare values.
+var comparisons = map[string]iterator.Operator{
+	"gt":  iterator.CompareGT,
+	"gte": iterator.CompareGTE,
+	"lt":  iterator.CompareLT,
+	"lte": iterator.CompareLTE,
+}
+
+// convFilters converts an object argument to value filters. Keys are either comparisons, or
+// parts of time values, as accepted by temporal.ParsePart. Strings in RFC 3339 or date math format are converted to times.
+func convFilters(obj *ast.ObjectValue) ([]shape.ValueFilter, error) {
+	now := time.Now()
+	var out []shape.ValueFilter
+	for _, f := range obj.Fields {
+		name := f.Name.Value
+		if op, ok := comparisons[name]; ok {
+			v, err := convTime(f.Value, now)
+			if err != nil {
+				return nil, err
+			} else if v == nil {
+				vals, err := convValue(f.Value)
+				if err != nil {
+					return nil, err
+				} else if len(vals) != 1 {
+					return nil, fmt.Errorf("%s expects a single value", name)
+				}
+				v = vals[0]
+			}
+			out = append(out, shape.Comparison{Op: op, Val: v})
+			continue
+		}
+		part, err := temporal.ParsePart(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown filter: %q", name)
+		}
+		iv, ok := f.Value.(*ast.IntValue)
+		if !ok {
+			return nil, fmt.Errorf("%s expects an integer, got: %T", name, f.Value)
+		}
+		n, _ := strconv.Atoi(iv.Value)
+		out = append(out, temporal.PartFilter{Part: part, Value: n})
+	}
+	return out, nil
+}
+
+// convTime converts strings in RFC 3339 or date math format to times. It returns nil for other values.
+func convTime(v ast.Value, now time.Time) (quad.Value, error) {
+	sv, ok := v.(*ast.StringValue)
+	if !ok {
+		return nil, nil
+	}
+	if temporal.IsMath(sv.Value) {
+		t, err := temporal.Math(sv.Value, now)
+		if err != nil {
+			return nil, err
+		}
+		return quad.Time(t), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, sv.Value); err == nil {
+		return quad.Time(t), nil
+	}
+	return nil, nil
+}
+
 func convValue(v ast.Value) (out []quad.Value, _ error) {
 	switch v := v.(type) {
 	case *ast.EnumValue:
@@ -7,11 +7,14 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/cayleygraph/cayley/graph/graphtest/testutil"
 	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/named"
+	textidx "github.com/cayleygraph/cayley/graph/text"
 	"github.com/cayleygraph/cayley/quad"
 	"github.com/cayleygraph/cayley/voc/rdf"
 )
@@ -297,6 +300,20 @@ var casesExecute = []struct {
 			},
 		},
 	},
+	{
+		"full-text filter",
+		`{
+  nodes @text(status: "SMART") {
+    id
+  }
+}`,
+		M{
+			"nodes": []M{
+				{"id": quad.IRI("emily")},
+				{"id": quad.IRI("greg")},
+			},
+		},
+	},
 }
 
 func toJson(o interface{}) string {
@@ -324,3 +341,124 @@ func TestExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteScore(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New()
+	qw := testutil.MakeWriter(t, mem, nil)
+	err := qw.AddQuadSet(testutil.LoadGraph(t, "../../data/testdata.nq"))
+	require.NoError(t, err)
+	qs, err := textidx.NewQuadStore(ctx, mem, nil)
+	require.NoError(t, err)
+
+	exec := func(qu string) map[string]interface{} {
+		q, err := Parse(strings.NewReader(qu))
+		require.NoError(t, err)
+		out, err := q.Execute(ctx, qs)
+		require.NoError(t, err)
+		return out
+	}
+
+	out := exec(`{
+  nodes @text(status: "smart") {
+    id, score: _score
+  }
+}`)
+	nodes, ok := out["nodes"].([]M)
+	require.True(t, ok, "%v", out)
+	var ids []quad.Value
+	for _, n := range nodes {
+		require.IsType(t, quad.Float(0), n["score"])
+		require.True(t, n["score"].(quad.Float) > 0)
+		ids = append(ids, n["id"].(quad.Value))
+	}
+	require.ElementsMatch(t, iris("emily", "greg"), ids)
+
+	out = exec(`{
+  nodes @text(status: "smart", minScore: 100) {
+    id
+  }
+}`)
+	require.Nil(t, out["nodes"])
+}
+
+func TestExecuteTimeFilters(t *testing.T) {
+	ctx := context.Background()
+	at := quad.IRI("at")
+	qs := memstore.New(
+		quad.Make(quad.IRI("a"), at, quad.Time(time.Date(2018, 12, 31, 23, 0, 0, 0, time.UTC)), nil),
+		quad.Make(quad.IRI("b"), at, quad.Time(time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)), nil),
+		quad.Make(quad.IRI("c"), at, quad.Time(time.Now().Add(-time.Hour)), nil),
+	)
+	exec := func(qu string) ([]quad.Value, error) {
+		q, err := Parse(strings.NewReader(qu))
+		if err != nil {
+			return nil, err
+		}
+		out, err := q.Execute(ctx, qs)
+		if err != nil {
+			return nil, err
+		}
+		var ids []quad.Value
+		switch v := out["nodes"].(type) {
+		case M:
+			ids = append(ids, v["id"].(quad.Value))
+		case []M:
+			for _, n := range v {
+				ids = append(ids, n["id"].(quad.Value))
+			}
+		}
+		return ids, nil
+	}
+	ids, err := exec(`{ nodes(<at>: {year: 2019, lt: "2019-06-01T00:00:00Z||"}) { id } }`)
+	require.NoError(t, err)
+	require.Equal(t, iris("b"), ids)
+
+	ids, err = exec(`{ nodes(<at>: {gte: "now-1d/h"}) { id } }`)
+	require.NoError(t, err)
+	require.Equal(t, iris("c"), ids)
+
+	ids, err = exec(`{ nodes(<at>: {gte: "2018-12-31T00:00:00Z", lt: "2018-12-31T00:00:00Z||+1d"}) { id } }`)
+	require.NoError(t, err)
+	require.Equal(t, iris("a"), ids)
+
+	ids, err = exec(`{ nodes(<at>: {gt: 5}) { id } }`)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	_, err = exec(`{ nodes(<at>: {century: 21}) { id } }`)
+	require.Error(t, err)
+}
+
+func TestExecuteGraph(t *testing.T) {
+	ctx := context.Background()
+	base := memstore.New(
+		quad.MakeIRI("alice", "follows", "bob", "g1"),
+		quad.MakeIRI("bob", "friend", "carol", "g2"),
+	)
+	qs := named.New(base, quad.IRI("g1"))
+	exec := func(qu string) (interface{}, error) {
+		q, err := Parse(strings.NewReader(qu))
+		if err != nil {
+			return nil, err
+		}
+		out, err := q.Execute(ctx, qs)
+		if err != nil {
+			return nil, err
+		}
+		return out["nodes"], nil
+	}
+	const friends = `{ nodes(id: <alice>) { follows { friend { id } } } }`
+	out, err := exec(`query ` + friends)
+	require.NoError(t, err)
+	require.Equal(t, M{"follows": M{"friend": nil}}, out)
+
+	out, err = exec(`query @graph(v: ["<g1>", "<g2>"]) ` + friends)
+	require.NoError(t, err)
+	require.Equal(t, M{"follows": M{"friend": M{"id": quad.IRI("carol")}}}, out)
+
+	_, err = exec(`{ nodes(id: <bob>) { friend @label(v: <g2>) { id } } }`)
+	require.Equal(t, named.ErrCrossGraph, err)
+	_, err = exec(`query @other ` + friends)
+	require.Error(t, err)
+}
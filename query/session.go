@@ -73,6 +73,21 @@ type REPLSession interface {
 	FormatREPL(Result) string
 }
 
+// Completer is an optional interface for sessions that can suggest names for completion in the REPL.
+type Completer interface {
+	// Completions returns names of global objects and names of methods that can follow a dot.
+	Completions() (globals, methods []string)
+}
+
+// Environment is an optional interface for sessions that accept namespaces and variables defined
+// outside of a query, for example by a server-side query session.
+type Environment interface {
+	// AddNamespace associates a prefix with a given IRI namespace.
+	AddNamespace(prefix, full string)
+	// SetVariable defines a global variable available to queries.
+	SetVariable(name string, v interface{}) error
+}
+
 // ResponseWriter is a subset of http.ResponseWriter
 type ResponseWriter interface {
 	Write([]byte) (int, error)
@@ -0,0 +1,102 @@
+// Copyright 2014 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Subgraph runs a query and returns a reader for the subgraph it matched: all quads that have
+// one of the nodes in the results as a subject. Nodes are collected from tags and from values
+// returned by the query. The limit applies to the number of query results, as in Execute.
+//
+// The query runs to completion before the first quad is read.
+func Subgraph(ctx context.Context, qs graph.QuadStore, lang, qu string, limit int) (quad.ReadSkipCloser, error) {
+	ses := NewSession(qs, lang)
+	if ses == nil {
+		return nil, fmt.Errorf("unknown query language: %q", lang)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	out := make(chan Result, 5)
+	go ses.Execute(ctx, qu, out, limit)
+	defer func() {
+		cancel()
+		for range out {
+		}
+	}()
+	var (
+		nodes []graph.Value
+		seen  = make(map[interface{}]struct{})
+	)
+	add := func(v graph.Value) {
+		if v == nil {
+			return
+		}
+		k := graph.ToKey(v)
+		if _, ok := seen[k]; ok {
+			return
+		}
+		seen[k] = struct{}{}
+		nodes = append(nodes, v)
+	}
+	for r := range out {
+		if err := r.Err(); err != nil {
+			return nil, err
+		}
+		collectNodes(qs, r.Result(), add)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	it := iterator.NewLinksTo(qs, iterator.NewFixed(nodes...), quad.Subject)
+	return graph.NewResultReader(qs, it), nil
+}
+
+// collectNodes calls fn for each node found in a query result.
+func collectNodes(qs graph.QuadStore, v interface{}, fn func(graph.Value)) {
+	switch v := v.(type) {
+	case nil:
+	case quad.Value:
+		fn(qs.ValueOf(v))
+	case graph.Value:
+		fn(v)
+	case map[string]graph.Value:
+		for _, r := range v {
+			fn(r)
+		}
+	case map[string]interface{}:
+		for _, r := range v {
+			collectNodes(qs, r, fn)
+		}
+	case []interface{}:
+		for _, r := range v {
+			collectNodes(qs, r, fn)
+		}
+	case []map[string]interface{}:
+		for _, r := range v {
+			collectNodes(qs, r, fn)
+		}
+	case []quad.Value:
+		for _, r := range v {
+			fn(qs.ValueOf(r))
+		}
+	}
+}
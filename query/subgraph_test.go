@@ -0,0 +1,52 @@
+package query_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
+	_ "github.com/cayleygraph/cayley/query/graphql"
+)
+
+func TestSubgraph(t *testing.T) {
+	quads := []quad.Quad{
+		quad.MakeIRI("alice", "tenant", "acme", ""),
+		quad.MakeIRI("alice", "follows", "bob", ""),
+		quad.Make(quad.IRI("alice"), quad.IRI("name"), "Alice", nil),
+		quad.MakeIRI("bob", "tenant", "acme", ""),
+		quad.MakeIRI("bob", "follows", "carol", ""),
+		quad.MakeIRI("carol", "tenant", "other", ""),
+		quad.MakeIRI("carol", "follows", "alice", ""),
+	}
+	expect := []quad.Quad{quads[0], quads[1], quads[2], quads[3], quads[4]}
+	sort.Sort(quad.ByQuadString(expect))
+	qs := memstore.New(quads...)
+
+	for _, c := range []struct {
+		lang, query string
+	}{
+		{"gizmo", `g.V().Has("<tenant>", "<acme>").All()`},
+		{"graphql", `{ nodes(<tenant>: <acme>) { id } }`},
+	} {
+		t.Run(c.lang, func(t *testing.T) {
+			qr, err := query.Subgraph(context.TODO(), qs, c.lang, c.query, -1)
+			require.NoError(t, err)
+			defer qr.Close()
+			got, err := quad.ReadAll(qr)
+			require.NoError(t, err)
+			sort.Sort(quad.ByQuadString(got))
+			require.Equal(t, expect, got)
+		})
+	}
+
+	_, err := query.Subgraph(context.TODO(), qs, "gizmo", `g.V(`, -1)
+	require.Error(t, err)
+	_, err = query.Subgraph(context.TODO(), qs, "unknown", `g.V()`, -1)
+	require.Error(t, err)
+}
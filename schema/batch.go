@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"reflect"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+type quadBuffer []quad.Quad
+
+func (b *quadBuffer) WriteQuad(q quad.Quad) error {
+	*b = append(*b, q)
+	return nil
+}
+
+// SaveAll adds quads of all objects to a single transaction and returns their identifiers.
+//
+// Quads of nested objects are added before quads of objects that refer to them. Objects that are
+// referenced multiple times by pointer are written only once.
+// The transaction can then be applied atomically with graph.QuadWriter.ApplyTransaction.
+//
+// See WriteAsQuads for details.
+func (c *Config) SaveAll(tx *graph.Transaction, objs ...interface{}) ([]quad.Value, error) {
+	wr := c.newWriter(graph.NewTxWriter(tx, graph.Add))
+	wr.ordered = true
+	ids := make([]quad.Value, 0, len(objs))
+	for _, o := range objs {
+		id, err := wr.writeAsQuads(reflect.ValueOf(o))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// DeleteAll adds removal of all quads of objects to a single transaction and returns their identifiers.
+//
+// Quads of objects are removed before quads of nested objects they refer to, in the reverse order of SaveAll.
+// All objects, including nested ones, must have an ID field set, since generated IDs cannot match stored ones.
+func (c *Config) DeleteAll(tx *graph.Transaction, objs ...interface{}) ([]quad.Value, error) {
+	var buf quadBuffer
+	wr := c.newWriter(&buf)
+	wr.ordered = true
+	wr.requireID = true
+	ids := make([]quad.Value, 0, len(objs))
+	for _, o := range objs {
+		id, err := wr.writeAsQuads(reflect.ValueOf(o))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	for i := len(buf) - 1; i >= 0; i-- {
+		tx.RemoveQuad(buf[i])
+	}
+	return ids, nil
+}
@@ -0,0 +1,79 @@
+package schema_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+func TestSaveDeleteAll(t *testing.T) {
+	sch := schema.NewConfig()
+	objs := []interface{}{
+		treeItem{ID: "n1", Name: "Node 1", Children: []treeItem{
+			{ID: "n2", Name: "Node 2"},
+		}},
+		treeItem{ID: "n3", Name: "Node 3"},
+	}
+	tx := graph.NewTransaction()
+	ids, err := sch.SaveAll(tx, objs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []quad.Value{iri("n1"), iri("n3")}; !reflect.DeepEqual(ids, exp) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	got := make([]quad.Quad, 0, len(tx.Deltas))
+	for _, d := range tx.Deltas {
+		got = append(got, d.Quad)
+	}
+	// nested objects are written first
+	exp := []quad.Quad{
+		{iri("n2"), iri("name"), quad.String("Node 2"), nil},
+		{iri("n1"), iri("name"), quad.String("Node 1"), nil},
+		{iri("n1"), iri("child"), iri("n2"), nil},
+		{iri("n3"), iri("name"), quad.String("Node 3"), nil},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected quads:\n%v\n%v", got, exp)
+	}
+
+	qs := memstore.New()
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = qw.ApplyTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	var out []treeItem
+	if err = sch.LoadTo(context.TODO(), qs, &out, iri("n1")); err != nil {
+		t.Fatal(err)
+	} else if len(out) != 1 || len(out[0].Children) != 1 {
+		t.Fatalf("unexpected objects: %v", out)
+	}
+
+	tx = graph.NewTransaction()
+	if _, err = sch.DeleteAll(tx, objs...); err != nil {
+		t.Fatal(err)
+	}
+	if first := tx.Deltas[0].Quad; first != exp[len(exp)-1] {
+		t.Fatalf("unexpected first quad: %v", first)
+	}
+	if err = qw.ApplyTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	if n := qs.Size(); n != 0 {
+		t.Fatalf("expected no quads, got %d", n)
+	}
+
+	_, err = sch.DeleteAll(graph.NewTransaction(), item2{Name: "a", Spec: "b"})
+	if err == nil {
+		t.Fatal("expected an error for an object without ID")
+	}
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Converter converts values of a single field, set with a "conv=name" tag option:
+//
+//	type Event struct{
+//		ID   quad.IRI  `quad:"@id"`
+//		Time time.Time `quad:"time,conv=unix"`
+//	}
+//	// "unix" converter that stores time as a number of seconds is registered by the application
+//	schema.RegisterConverter("unix", conv)
+type Converter interface {
+	// FromValue converts a loaded value. The result is assigned to the field with default conversion rules.
+	FromValue(v quad.Value) (interface{}, error)
+	// ToValue converts a field value before writing it. Zero values are not written.
+	ToValue(v interface{}) (quad.Value, error)
+}
+
+// ConverterFuncs implements Converter with a pair of functions.
+type ConverterFuncs struct {
+	From func(v quad.Value) (interface{}, error)
+	To   func(v interface{}) (quad.Value, error)
+}
+
+func (c ConverterFuncs) FromValue(v quad.Value) (interface{}, error) { return c.From(v) }
+func (c ConverterFuncs) ToValue(v interface{}) (quad.Value, error)   { return c.To(v) }
+
+var (
+	convMu     sync.RWMutex
+	converters = make(map[string]Converter)
+)
+
+// RegisterConverter associates a name with a converter, so it can be used in field tags.
+// Passing nil converter removes the registration.
+func RegisterConverter(name string, c Converter) {
+	convMu.Lock()
+	defer convMu.Unlock()
+	if c == nil {
+		delete(converters, name)
+		return
+	}
+	if _, exists := converters[name]; exists {
+		panic(fmt.Errorf("converter %q is already registered", name))
+	}
+	converters[name] = c
+}
+
+func getConverter(name string) Converter {
+	convMu.RLock()
+	defer convMu.RUnlock()
+	return converters[name]
+}
+
+var reflTime = reflect.TypeOf(time.Time{})
+
+// parseDefault parses a default value of a field from a tag.
+func parseDefault(s string, rt reflect.Type) (reflect.Value, error) {
+	et := rt
+	for et.Kind() == reflect.Ptr || et.Kind() == reflect.Slice {
+		et = et.Elem()
+	}
+	var (
+		v   interface{}
+		err error
+	)
+	switch {
+	case et == reflTime:
+		v, err = time.Parse(time.RFC3339, s)
+	case et.Implements(reflQuadValue) || et == reflQuadValue:
+		v = quad.StringToValue(s)
+	default:
+		switch et.Kind() {
+		case reflect.String:
+			v = s
+		case reflect.Bool:
+			v, err = strconv.ParseBool(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v, err = strconv.ParseInt(s, 10, et.Bits())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err = strconv.ParseUint(s, 10, et.Bits())
+		case reflect.Float32, reflect.Float64:
+			v, err = strconv.ParseFloat(s, et.Bits())
+		default:
+			return reflect.Value{}, fmt.Errorf("default values are not supported for %v", et)
+		}
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	out := reflect.New(rt).Elem()
+	if err = DefaultConverter.SetValue(out, reflect.ValueOf(v)); err != nil {
+		return reflect.Value{}, err
+	}
+	return out, nil
+}
@@ -0,0 +1,500 @@
+// Package gen generates Go types for the schema package from an ontology.
+//
+// Classes are read from RDFS, OWL and schema.org definitions, and from SHACL node shapes with sh:targetClass.
+// Each class becomes a struct with an ID field and a field for each property that has the class as a domain,
+// including properties of all super-classes. Property ranges are mapped to Go types, and functional properties
+// (or properties with sh:maxCount 1) become single-valued fields. SHACL shapes take precedence over RDFS definitions.
+//
+// All IRIs of the standard vocabularies are expected to be in the full form, as they are written by standard RDF formats.
+package gen
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/owl"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	"github.com/cayleygraph/cayley/voc/rdfs"
+	"github.com/cayleygraph/cayley/voc/schema"
+	"github.com/cayleygraph/cayley/voc/sh"
+)
+
+const xsd = "http://www.w3.org/2001/XMLSchema#"
+
+func iri(s string) quad.IRI {
+	return quad.IRI(s).Full()
+}
+
+var (
+	schemaDomain = iri("schema:domainIncludes")
+	schemaRange  = iri("schema:rangeIncludes")
+)
+
+// nativeTypes maps datatypes to Go types.
+var nativeTypes = map[quad.IRI]string{
+	xsd + "string":             "string",
+	xsd + "normalizedString":   "string",
+	xsd + "token":              "string",
+	xsd + "anyURI":             "string",
+	iri(rdf.LangString):        "string",
+	iri(schema.Text):           "string",
+	iri(schema.URL):            "string",
+	xsd + "boolean":            "bool",
+	iri(schema.Boolean):        "bool",
+	xsd + "integer":            "int64",
+	xsd + "long":               "int64",
+	xsd + "int":                "int64",
+	xsd + "short":              "int64",
+	xsd + "byte":               "int64",
+	xsd + "nonNegativeInteger": "int64",
+	xsd + "positiveInteger":    "int64",
+	iri(schema.Integer):        "int64",
+	xsd + "decimal":            "float64",
+	xsd + "double":             "float64",
+	xsd + "float":              "float64",
+	iri(schema.Float):          "float64",
+	iri(schema.Number):         "float64",
+	xsd + "dateTime":           "time.Time",
+	xsd + "date":               "time.Time",
+	iri(schema.DateTime):       "time.Time",
+	iri(schema.Date):           "time.Time",
+	iri(rdfs.Literal):          "quad.Value",
+}
+
+// Field is a field of a generated struct.
+type Field struct {
+	Name string
+	Pred quad.IRI
+	// Type is a Go type of a single value.
+	Type string
+	// Multi is set if the property can have more than one value. The field will be a slice.
+	Multi bool
+	// Required is set if the property must have at least one value.
+	Required bool
+	Doc      string
+}
+
+// Tag returns a struct tag for the field.
+func (f *Field) Tag() string {
+	tag := string(f.Pred)
+	if f.Required && f.Multi {
+		tag += ",required"
+	} else if !f.Required && !f.Multi {
+		tag += ",optional"
+	}
+	return "`quad:" + strconv.Quote(tag) + "`"
+}
+
+// GoType returns a Go type of the field.
+func (f *Field) GoType() string {
+	if f.Multi {
+		return "[]" + f.Type
+	}
+	return f.Type
+}
+
+// Struct is a Go struct generated for a class.
+type Struct struct {
+	Name   string
+	Class  quad.IRI
+	Doc    string
+	Fields []*Field
+
+	supers []quad.IRI
+	preds  map[quad.IRI]*Field
+}
+
+func (s *Struct) field(pred quad.IRI) *Field {
+	f, ok := s.preds[pred]
+	if !ok {
+		f = &Field{Pred: pred, Type: "quad.Value", Multi: true}
+		s.preds[pred] = f
+		s.Fields = append(s.Fields, f)
+	}
+	return f
+}
+
+// Load reads all classes and properties from a quad store and builds structs for them.
+// Structs are sorted by name.
+func Load(ctx context.Context, qs graph.QuadStore) ([]*Struct, error) {
+	l := &loader{ctx: ctx, qs: qs, classes: make(map[quad.IRI]*Struct)}
+	if err := l.loadClasses(); err != nil {
+		return nil, err
+	}
+	if err := l.loadProperties(); err != nil {
+		return nil, err
+	}
+	if err := l.loadShapes(); err != nil {
+		return nil, err
+	}
+	return l.build(), nil
+}
+
+type loader struct {
+	ctx     context.Context
+	qs      graph.QuadStore
+	classes map[quad.IRI]*Struct
+}
+
+func (l *loader) all(p *path.Path) ([]quad.Value, error) {
+	return p.Unique().Iterate(l.ctx).AllValues(l.qs)
+}
+
+func (l *loader) out(node quad.Value, pred quad.IRI) ([]quad.Value, error) {
+	return l.all(path.StartPath(l.qs, node).Out(pred))
+}
+
+func (l *loader) text(node quad.Value, pred string) (string, error) {
+	vals, err := l.out(node, iri(pred))
+	if err != nil {
+		return "", err
+	}
+	for _, v := range vals {
+		if s, ok := quad.NativeOf(v).(string); ok {
+			return s, nil
+		}
+	}
+	return "", nil
+}
+
+func (l *loader) doc(node quad.Value) (string, error) {
+	if s, err := l.text(node, rdfs.Comment); err != nil || s != "" {
+		return s, err
+	}
+	return l.text(node, sh.Description)
+}
+
+func (l *loader) class(id quad.IRI) (*Struct, error) {
+	if s, ok := l.classes[id]; ok {
+		return s, nil
+	}
+	s := &Struct{Class: id, preds: make(map[quad.IRI]*Field)}
+	var err error
+	if s.Doc, err = l.doc(id); err != nil {
+		return nil, err
+	}
+	l.classes[id] = s
+	return s, nil
+}
+
+func (l *loader) loadClasses() error {
+	qs := l.qs
+	p := path.StartPath(qs).Has(iri(rdf.Type), iri(rdfs.Class), iri(owl.Class), iri(schema.Class)).Or(
+		path.StartPath(qs).Has(iri(rdfs.SubClassOf)),
+	).Or(
+		path.StartPath(qs).Out(iri(sh.TargetClass)),
+	)
+	ids, err := l.all(p)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		// blank nodes are usually OWL restrictions
+		c, ok := id.(quad.IRI)
+		if !ok {
+			continue
+		}
+		if _, err = l.class(c); err != nil {
+			return err
+		}
+	}
+	for id, s := range l.classes {
+		supers, err := l.out(id, iri(rdfs.SubClassOf))
+		if err != nil {
+			return err
+		}
+		for _, v := range supers {
+			if c, ok := v.(quad.IRI); ok && c != id {
+				s.supers = append(s.supers, c)
+			}
+		}
+	}
+	return nil
+}
+
+// rangeType maps the range of a property to a Go type.
+func (l *loader) rangeType(ranges []quad.Value) string {
+	typ := ""
+	for _, r := range ranges {
+		t, ok := nativeTypes[toIRI(r)]
+		if !ok {
+			if _, isIRI := r.(quad.IRI); !isIRI {
+				continue
+			}
+			// values of other classes are referenced by IRIs
+			t = "quad.IRI"
+		}
+		if typ != "" && typ != t {
+			// property accepts values of different types
+			return "quad.Value"
+		}
+		typ = t
+	}
+	if typ == "" {
+		return "quad.Value"
+	}
+	return typ
+}
+
+func toIRI(v quad.Value) quad.IRI {
+	s, _ := v.(quad.IRI)
+	return s
+}
+
+func (l *loader) loadProperties() error {
+	qs := l.qs
+	p := path.StartPath(qs).Has(iri(rdf.Type),
+		iri(rdf.Property), iri(owl.ObjectProperty), iri(owl.DatatypeProperty), iri(owl.FunctionalProperty),
+	).Or(
+		path.StartPath(qs).Has(iri(rdfs.Domain)),
+	).Or(
+		path.StartPath(qs).Has(schemaDomain),
+	)
+	ids, err := l.all(p)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		pred, ok := id.(quad.IRI)
+		if !ok {
+			continue
+		}
+		domains, err := l.all(path.StartPath(qs, pred).Out(iri(rdfs.Domain), schemaDomain))
+		if err != nil {
+			return err
+		}
+		if len(domains) == 0 {
+			continue
+		}
+		ranges, err := l.all(path.StartPath(qs, pred).Out(iri(rdfs.Range), schemaRange))
+		if err != nil {
+			return err
+		}
+		types, err := l.out(pred, iri(rdf.Type))
+		if err != nil {
+			return err
+		}
+		functional := false
+		for _, t := range types {
+			if t == iri(owl.FunctionalProperty) {
+				functional = true
+			}
+		}
+		doc, err := l.doc(pred)
+		if err != nil {
+			return err
+		}
+		typ := l.rangeType(ranges)
+		for _, d := range domains {
+			c, ok := d.(quad.IRI)
+			if !ok {
+				continue
+			}
+			s, err := l.class(c)
+			if err != nil {
+				return err
+			}
+			f := s.field(pred)
+			f.Type, f.Multi, f.Doc = typ, !functional, doc
+		}
+	}
+	return nil
+}
+
+func toInt(v quad.Value) (int64, bool) {
+	if ts, ok := v.(quad.TypedString); ok {
+		pv, err := ts.ParseValue()
+		if err != nil {
+			return 0, false
+		}
+		v = pv
+	}
+	n, ok := v.(quad.Int)
+	return int64(n), ok
+}
+
+// loadShapes reads property shapes of node shapes that target classes, or that are classes themselves.
+func (l *loader) loadShapes() error {
+	qs := l.qs
+	ids, err := l.all(path.StartPath(qs).Has(iri(sh.Property)))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		targets, err := l.out(id, iri(sh.TargetClass))
+		if err != nil {
+			return err
+		}
+		if c, ok := id.(quad.IRI); ok {
+			if _, isClass := l.classes[c]; isClass {
+				targets = append(targets, c)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		props, err := l.out(id, iri(sh.Property))
+		if err != nil {
+			return err
+		}
+		for _, ps := range props {
+			pv, err := l.out(ps, iri(sh.Path))
+			if err != nil {
+				return err
+			} else if len(pv) != 1 {
+				continue
+			}
+			// complex paths are not supported
+			pred, ok := pv[0].(quad.IRI)
+			if !ok {
+				continue
+			}
+			ranges, err := l.all(path.StartPath(qs, ps).Out(iri(sh.Datatype), iri(sh.Class)))
+			if err != nil {
+				return err
+			}
+			minCount, err := l.out(ps, iri(sh.MinCount))
+			if err != nil {
+				return err
+			}
+			maxCount, err := l.out(ps, iri(sh.MaxCount))
+			if err != nil {
+				return err
+			}
+			doc, err := l.doc(ps)
+			if err != nil {
+				return err
+			}
+			for _, t := range targets {
+				c, ok := t.(quad.IRI)
+				if !ok {
+					continue
+				}
+				s, err := l.class(c)
+				if err != nil {
+					return err
+				}
+				f := s.field(pred)
+				if len(ranges) != 0 {
+					f.Type = l.rangeType(ranges)
+				}
+				for _, v := range minCount {
+					if n, ok := toInt(v); ok && n > 0 {
+						f.Required = true
+					}
+				}
+				for _, v := range maxCount {
+					if n, ok := toInt(v); ok && n <= 1 {
+						f.Multi = false
+					}
+				}
+				if doc != "" {
+					f.Doc = doc
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// build copies fields of super-classes, and assigns names to all structs and fields.
+func (l *loader) build() []*Struct {
+	out := make([]*Struct, 0, len(l.classes))
+	for _, s := range l.classes {
+		// copy, because fields of super-classes will be added
+		all := make(map[quad.IRI]*Field, len(s.preds))
+		fields := append([]*Field{}, s.Fields...)
+		for p, f := range s.preds {
+			all[p] = f
+		}
+		seen := map[quad.IRI]bool{s.Class: true}
+		queue := append([]quad.IRI{}, s.supers...)
+		for len(queue) != 0 {
+			c := queue[0]
+			queue = queue[1:]
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			sup, ok := l.classes[c]
+			if !ok {
+				continue
+			}
+			for _, f := range sup.Fields {
+				if _, ok := all[f.Pred]; !ok {
+					cp := *f
+					all[f.Pred] = &cp
+					fields = append(fields, &cp)
+				}
+			}
+			queue = append(queue, sup.supers...)
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Pred < fields[j].Pred
+		})
+		s.Fields = fields
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Class < out[j].Class
+	})
+	names := make(map[string]bool)
+	for _, s := range out {
+		s.Name = uniqueName(names, goName(s.Class))
+		fnames := map[string]bool{"ID": true}
+		for _, f := range s.Fields {
+			f.Name = uniqueName(fnames, goName(f.Pred))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// localName returns the last segment of an IRI.
+func localName(s quad.IRI) string {
+	str := strings.TrimRight(string(s), "/#")
+	if i := strings.LastIndexAny(str, "#/:"); i >= 0 {
+		str = str[i+1:]
+	}
+	return str
+}
+
+// goName converts a local name of an IRI to an exported Go identifier.
+func goName(s quad.IRI) string {
+	parts := strings.FieldsFunc(localName(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, p := range parts {
+		r := []rune(p)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+func uniqueName(names map[string]bool, name string) string {
+	if !names[name] {
+		names[name] = true
+		return name
+	}
+	for i := 2; ; i++ {
+		n := name + strconv.Itoa(i)
+		if !names[n] {
+			names[n] = true
+			return n
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package gen
+
+import (
+	"bytes"
+	"context"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+)
+
+const ontology = `
+<http://example.org/Agent> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#Class> .
+<http://example.org/Person> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example.org/Agent> .
+<http://example.org/Person> <http://www.w3.org/2000/01/rdf-schema#comment> "A human being." .
+<http://example.org/name> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#FunctionalProperty> .
+<http://example.org/name> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example.org/Agent> .
+<http://example.org/name> <http://www.w3.org/2000/01/rdf-schema#range> <http://www.w3.org/2001/XMLSchema#string> .
+<http://example.org/knows> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example.org/Person> .
+<http://example.org/knows> <http://www.w3.org/2000/01/rdf-schema#range> <http://example.org/Person> .
+<http://example.org/birth-date> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example.org/Person> .
+<http://example.org/birth-date> <http://www.w3.org/2000/01/rdf-schema#range> <http://www.w3.org/2001/XMLSchema#dateTime> .
+<http://example.org/PersonShape> <http://www.w3.org/ns/shacl#targetClass> <http://example.org/Person> .
+<http://example.org/PersonShape> <http://www.w3.org/ns/shacl#property> _:age .
+_:age <http://www.w3.org/ns/shacl#path> <http://example.org/age> .
+_:age <http://www.w3.org/ns/shacl#datatype> <http://www.w3.org/2001/XMLSchema#integer> .
+_:age <http://www.w3.org/ns/shacl#minCount> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:age <http://www.w3.org/ns/shacl#maxCount> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+`
+
+func TestGenerate(t *testing.T) {
+	quads, err := quad.ReadAll(nquads.NewReader(strings.NewReader(ontology), false))
+	require.NoError(t, err)
+	qs := memstore.New(quads...)
+
+	structs, err := Load(context.TODO(), qs)
+	require.NoError(t, err)
+	require.Len(t, structs, 2)
+
+	agent, person := structs[0], structs[1]
+	require.Equal(t, "Agent", agent.Name)
+	require.Equal(t, quad.IRI("http://example.org/Agent"), agent.Class)
+	require.Len(t, agent.Fields, 1)
+	require.Equal(t, "Name", agent.Fields[0].Name)
+	require.Equal(t, "string", agent.Fields[0].GoType())
+
+	require.Equal(t, "Person", person.Name)
+	require.Equal(t, "A human being.", person.Doc)
+	got := make(map[string]string)
+	for _, f := range person.Fields {
+		got[f.Name] = f.GoType() + " " + f.Tag()
+	}
+	require.Equal(t, map[string]string{
+		"Age":       "int64 `quad:\"http://example.org/age\"`",
+		"BirthDate": "[]time.Time `quad:\"http://example.org/birth-date\"`",
+		"Knows":     "[]quad.IRI `quad:\"http://example.org/knows\"`",
+		"Name":      "string `quad:\"http://example.org/name,optional\"`",
+	}, got)
+
+	var buf bytes.Buffer
+	err = Write(&buf, "model", structs)
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "model.go", buf.Bytes(), 0)
+	require.NoError(t, err, "%s", buf.String())
+	require.Contains(t, buf.String(), `schema.RegisterType(quad.IRI("http://example.org/Person"), Person{})`)
+}
@@ -0,0 +1,64 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Write generates a Go source file with given structs. Each struct is registered as a type of its class
+// with schema.RegisterType.
+func Write(w io.Writer, pkg string, structs []*Struct) error {
+	var buf bytes.Buffer
+	needTime := false
+	for _, s := range structs {
+		for _, f := range s.Fields {
+			if f.Type == "time.Time" {
+				needTime = true
+			}
+		}
+	}
+	fmt.Fprintf(&buf, "// Code generated by cayley genschema. DO NOT EDIT.\n\npackage %s\n", pkg)
+	if len(structs) != 0 {
+		buf.WriteString("\nimport (\n")
+		if needTime {
+			buf.WriteString("\t\"time\"\n\n")
+		}
+		buf.WriteString("\t\"github.com/cayleygraph/cayley/quad\"\n\t\"github.com/cayleygraph/cayley/schema\"\n)\n\n")
+		buf.WriteString("func init() {\n")
+		for _, s := range structs {
+			fmt.Fprintf(&buf, "\tschema.RegisterType(quad.IRI(%s), %s{})\n", strconv.Quote(string(s.Class)), s.Name)
+		}
+		buf.WriteString("}\n")
+	}
+	for _, s := range structs {
+		fmt.Fprintf(&buf, "\n// %s is generated from %s.\n", s.Name, s.Class)
+		if s.Doc != "" {
+			buf.WriteString("//\n")
+			writeDoc(&buf, "", s.Doc)
+		}
+		fmt.Fprintf(&buf, "type %s struct {\n\tID quad.IRI `quad:\"@id\"`\n", s.Name)
+		for _, f := range s.Fields {
+			if f.Doc != "" {
+				writeDoc(&buf, "\t", f.Doc)
+			}
+			fmt.Fprintf(&buf, "\t%s %s %s\n", f.Name, f.GoType(), f.Tag())
+		}
+		buf.WriteString("}\n")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("gen: cannot format the source: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func writeDoc(buf *bytes.Buffer, indent, doc string) {
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		buf.WriteString(indent + "// " + strings.TrimSpace(line) + "\n")
+	}
+}
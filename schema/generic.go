@@ -0,0 +1,152 @@
+//go:build go1.18
+// +build go1.18
+
+package schema
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Type is a typed view of a config for a given struct type T.
+// It provides the same functions as Config, but checks types of destination objects at compile time.
+type Type[T any] struct {
+	c *Config
+}
+
+// For returns a typed view of a config for objects of type T. Global config is used if c is nil.
+func For[T any](c *Config) Type[T] {
+	if c == nil {
+		c = global
+	}
+	return Type[T]{c: c}
+}
+
+// Path returns a morphism that selects nodes that can be loaded as T.
+func (t Type[T]) Path() (*path.Path, error) {
+	var zero T
+	return t.c.PathForType(reflect.TypeOf(zero))
+}
+
+// Load loads a single object with a given id.
+//
+// An error checked by IsNotFound is returned if there is no such node, or if it doesn't have fields required by T.
+func (t Type[T]) Load(ctx context.Context, qs graph.QuadStore, id quad.Value) (T, error) {
+	var out T
+	err := t.c.LoadTo(ctx, qs, &out, id)
+	return out, err
+}
+
+// LoadAll loads all objects of type T starting from given ids, or from all nodes if no ids are given.
+// Nodes that can't be loaded as T are skipped.
+func (t Type[T]) LoadAll(ctx context.Context, qs graph.QuadStore, ids ...quad.Value) ([]T, error) {
+	var out []T
+	err := t.c.LoadTo(ctx, qs, &out, ids...)
+	return out, err
+}
+
+// LoadPath is the same as LoadAll, but starts loading objects from a given path.
+func (t Type[T]) LoadPath(ctx context.Context, qs graph.QuadStore, p *path.Path) ([]T, error) {
+	var out []T
+	err := t.c.LoadIteratorTo(ctx, qs, reflect.ValueOf(&out), p.BuildIteratorOn(qs))
+	return out, err
+}
+
+// Write writes an object in a form of quads and returns its id.
+func (t Type[T]) Write(w quad.Writer, o T) (quad.Value, error) {
+	return t.c.WriteAsQuads(w, o)
+}
+
+// Iterate returns an iterator that loads objects of type T one by one, starting from nodes of a given path.
+// All nodes are used if the path is nil.
+func (t Type[T]) Iterate(qs graph.QuadStore, p *path.Path) *Iterator[T] {
+	tp, err := t.Path()
+	if err != nil {
+		return &Iterator[T]{err: err}
+	}
+	if p == nil {
+		p = path.StartPath(qs)
+	}
+	return &Iterator[T]{
+		c: t.c, qs: qs,
+		it: p.Follow(tp).Unique().BuildIteratorOn(qs),
+	}
+}
+
+// Iterator loads objects of type T one by one.
+type Iterator[T any] struct {
+	c   *Config
+	qs  graph.QuadStore
+	it  graph.Iterator
+	cur T
+	err error
+}
+
+// Next loads the next object. It returns false if there are no more objects or if an error occurs.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil || it.it == nil {
+		return false
+	}
+	for it.it.Next(ctx) {
+		var v T
+		err := it.c.LoadIteratorTo(ctx, it.qs, reflect.ValueOf(&v), iterator.NewFixed(it.it.Result()))
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			it.err = err
+			return false
+		}
+		it.cur = v
+		return true
+	}
+	it.err = it.it.Err()
+	return false
+}
+
+// Result returns the current object.
+func (it *Iterator[T]) Result() T {
+	return it.cur
+}
+
+// Err returns an error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Close closes the iterator.
+func (it *Iterator[T]) Close() error {
+	if it.it == nil {
+		return nil
+	}
+	return it.it.Close()
+}
+
+// All reads all remaining objects from the iterator and closes it.
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	defer it.Close()
+	var out []T
+	for it.Next(ctx) {
+		out = append(out, it.cur)
+	}
+	return out, it.err
+}
+
+// Load loads a single object of type T with a given id, using the global config.
+func Load[T any](ctx context.Context, qs graph.QuadStore, id quad.Value) (T, error) {
+	return For[T](nil).Load(ctx, qs, id)
+}
+
+// LoadAll loads all objects of type T from the graph, using the global config.
+func LoadAll[T any](ctx context.Context, qs graph.QuadStore) ([]T, error) {
+	return For[T](nil).LoadAll(ctx, qs)
+}
+
+// Iterate returns an iterator over objects of type T, starting from nodes of a given path, using the global config.
+func Iterate[T any](qs graph.QuadStore, p *path.Path) *Iterator[T] {
+	return For[T](nil).Iterate(qs, p)
+}
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+package schema_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+)
+
+func TestGenericLoad(t *testing.T) {
+	ctx := context.TODO()
+	qs := memstore.New([]quad.Quad{
+		{iri("sub1"), typeIRI, iri("some:item"), nil},
+		{iri("sub1"), iri("name"), quad.String("Sub 1"), nil},
+		{iri("sub2"), typeIRI, iri("some:item"), nil},
+		{iri("sub2"), iri("name"), quad.String("Sub 2"), nil},
+		{iri("sub2"), iri("spec"), quad.String("special"), nil},
+		{iri("sub3"), typeIRI, iri("some:item"), nil}, // no name
+		{iri("other"), iri("name"), quad.String("Other"), nil},
+	}...)
+	byID := func(items []item) {
+		sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	}
+	expect := []item{
+		{ID: iri("sub1"), Name: "Sub 1"},
+		{ID: iri("sub2"), Name: "Sub 2", Spec: "special"},
+	}
+
+	items, err := schema.LoadAll[item](ctx, qs)
+	require.NoError(t, err)
+	byID(items)
+	require.Equal(t, expect, items)
+
+	one, err := schema.Load[item](ctx, qs, iri("sub2"))
+	require.NoError(t, err)
+	require.Equal(t, expect[1], one)
+
+	_, err = schema.Load[item](ctx, qs, iri("sub3"))
+	require.True(t, schema.IsNotFound(err), "unexpected error: %v", err)
+
+	items, err = schema.Iterate[item](qs, nil).All(ctx)
+	require.NoError(t, err)
+	byID(items)
+	require.Equal(t, expect, items)
+
+	it := schema.For[item](schema.NewConfig()).Iterate(qs, path.StartPath(qs, iri("sub2"), iri("sub3"), iri("other")))
+	defer it.Close()
+	require.True(t, it.Next(ctx))
+	require.Equal(t, expect[1], it.Result())
+	require.False(t, it.Next(ctx))
+	require.NoError(t, it.Err())
+}
@@ -87,3 +87,17 @@ func LoadTo(ctx context.Context, qs graph.QuadStore, dst interface{}, ids ...qua
 func LoadToDepth(ctx context.Context, qs graph.QuadStore, dst interface{}, depth int, ids ...quad.Value) error {
 	return global.LoadToDepth(ctx, qs, dst, depth, ids...)
 }
+
+// SaveAll adds quads of all objects to a single transaction and returns their identifiers.
+//
+// Deprecated: see Config.SaveAll
+func SaveAll(tx *graph.Transaction, objs ...interface{}) ([]quad.Value, error) {
+	return global.SaveAll(tx, objs...)
+}
+
+// DeleteAll adds removal of all quads of objects to a single transaction and returns their identifiers.
+//
+// Deprecated: see Config.DeleteAll
+func DeleteAll(tx *graph.Transaction, objs ...interface{}) ([]quad.Value, error) {
+	return global.DeleteAll(tx, objs...)
+}
@@ -89,6 +89,25 @@ func IsNotFound(err error) bool {
 //		ThirdName string `quad:"thirdName,optional"` // can be empty
 //		FollowedBy []quad.IRI `quad:"follows"`
 // 	}
+//
+// A "default=value" option makes a field optional and sets it to a given value if it's missing in the graph.
+// Values are parsed according to the field type; quad values are parsed with quad.StringToValue.
+// Values with commas are not supported.
+//
+//	type Person struct{
+//		ID quad.IRI `json:"@id"`
+//		Status string `quad:"status,default=active"`
+//		Rating int `quad:"rating,default=5"`
+// 	}
+//
+// A "conv=name" option converts field values with a converter registered by RegisterConverter.
+//
+// Fields (or destinations) of interface types are loaded as one of the types registered with RegisterType:
+// the first rdf:type of a node that was registered for a type implementing the interface is used.
+// Nodes that have no such types are skipped. Interfaces implemented by quad.Value are loaded as quad values.
+//
+// Fields of type Ref are not loaded recursively: only an ID of the linked node is read, and the object
+// is loaded on the first call to Ref.Get.
 func (c *Config) LoadTo(ctx context.Context, qs graph.QuadStore, dst interface{}, ids ...quad.Value) error {
 	return c.LoadToDepth(ctx, qs, dst, -1, ids...)
 }
@@ -340,8 +359,15 @@ func (l *loader) loadToValue(ctx context.Context, dst reflect.Value, depth int,
 		}
 		arr, ok := m[tagPref+name]
 		if !ok || len(arr) == 0 {
+			if r, ok := rules.(saveRule); ok && r.Default.IsValid() {
+				df.Set(r.Default)
+			}
 			continue
 		}
+		var conv Converter
+		if r, ok := rules.(saveRule); ok {
+			conv = r.Conv
+		}
 		ft := f.Type
 		native := isNative(ft)
 		ptr := ft.Kind() == reflect.Ptr
@@ -355,10 +381,22 @@ func (l *loader) loadToValue(ctx context.Context, dst reflect.Value, depth int,
 				ptr = false
 			}
 		}
-		recursive := !native && ft.Kind() == reflect.Struct
+		lazy := reflect.PtrTo(ft).Implements(reflLazyRef)
+		recursive := !native && !lazy && conv == nil && ft.Kind() == reflect.Struct
+		poly := conv == nil && isPolymorphic(ft)
 		for _, fv := range arr {
 			var sv reflect.Value
-			if recursive {
+			if poly {
+				sv = reflect.New(ft)
+				err := l.loadIteratorToDepth(ctx, sv, depth-1, iterator.NewFixed(fv))
+				if err == errNotFound {
+					// no registered type implements the interface
+					continue
+				} else if err != nil {
+					return err
+				}
+				sv = sv.Elem()
+			} else if recursive {
 				if ptr {
 					fv := l.qs.NameOf(fv)
 					var ok bool
@@ -380,7 +418,17 @@ func (l *loader) loadToValue(ctx context.Context, dst reflect.Value, depth int,
 				if fv == nil {
 					continue
 				}
-				sv = reflect.ValueOf(fv)
+				if lazy {
+					sv = reflect.New(ft)
+					sv.Interface().(lazyRef).bindRef(l.c, l.qs, fv)
+					sv = sv.Elem()
+				} else if conv == nil {
+					sv = reflect.ValueOf(fv)
+				} else if v, err := conv.FromValue(fv); err != nil {
+					return fmt.Errorf("field %s: %v", f.Name, err)
+				} else {
+					sv = reflect.ValueOf(v)
+				}
 			}
 			if err := DefaultConverter.SetValue(df, sv); err != nil {
 				return fmt.Errorf("field %s: %v", f.Name, err)
@@ -426,6 +474,11 @@ func (l *loader) loadIteratorToDepth(ctx context.Context, dst reflect.Value, dep
 	} else if dst.Kind() == reflect.Chan {
 		et = et.Elem()
 		chanl = true
+	}
+	if isPolymorphic(et) {
+		return l.loadPolymorphicToDepth(ctx, dst, depth, list)
+	}
+	if chanl {
 		defer dst.Close()
 	}
 	fields, err := l.c.rulesFor(et)
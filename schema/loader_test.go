@@ -1,8 +1,10 @@
 package schema_test
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/cayleygraph/cayley/graph"
 	"github.com/cayleygraph/cayley/graph/iterator"
@@ -408,4 +410,57 @@ var testFillValueCases = []struct {
 			{iri("h3"), iri("two"), quad.String("D"), nil},
 		},
 	},
+	{
+		name: "default values",
+		expect: []DefaultFields{
+			{ID: "d1", Name: "A", Status: "active", Rating: 5, Tags: []string{"none"}},
+			{ID: "d2", Name: "B", Status: "banned", Rating: 1, Tags: []string{"x"}},
+		},
+		quads: []quad.Quad{
+			{iri("d1"), iri("name"), quad.String("A"), nil},
+			{iri("d2"), iri("name"), quad.String("B"), nil},
+			{iri("d2"), iri("status"), quad.String("banned"), nil},
+			{iri("d2"), iri("rating"), quad.Int(1), nil},
+			{iri("d2"), iri("tag"), quad.String("x"), nil},
+		},
+	},
+}
+
+type unixTime struct {
+	ID   quad.IRI  `quad:"@id"`
+	Time time.Time `quad:"time,conv=test-unix"`
+}
+
+func TestConverter(t *testing.T) {
+	schema.RegisterConverter("test-unix", schema.ConverterFuncs{
+		From: func(v quad.Value) (interface{}, error) {
+			n, ok := v.(quad.Int)
+			if !ok {
+				return nil, fmt.Errorf("unexpected value: %v", v)
+			}
+			return time.Unix(int64(n), 0).UTC(), nil
+		},
+		To: func(v interface{}) (quad.Value, error) {
+			return quad.Int(v.(time.Time).Unix()), nil
+		},
+	})
+	defer schema.RegisterConverter("test-unix", nil)
+
+	sch := schema.NewConfig()
+	obj := unixTime{ID: "e1", Time: time.Unix(1500000000, 0).UTC()}
+	var out quadSlice
+	if _, err := sch.WriteAsQuads(&out, obj); err != nil {
+		t.Fatal(err)
+	}
+	expect := []quad.Quad{{iri("e1"), iri("time"), quad.Int(1500000000), nil}}
+	if !reflect.DeepEqual([]quad.Quad(out), expect) {
+		t.Fatalf("unexpected quads: %v", out)
+	}
+	var got unixTime
+	if err := sch.LoadTo(nil, memstore.New(out...), &got, iri("e1")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, obj) {
+		t.Fatalf("objects are different\n%#v\n%#v", got, obj)
+	}
 }
@@ -0,0 +1,270 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// MigrationRule changes quads when the schema of the data evolves.
+type MigrationRule interface {
+	// Rewrite returns a new version of the quad. Unaffected quads must be returned as is,
+	// and an invalid (zero) quad means that the quad must be removed.
+	Rewrite(q quad.Quad) (quad.Quad, error)
+}
+
+func sameIRI(v quad.Value, iri quad.IRI) bool {
+	s, ok := v.(quad.IRI)
+	return ok && s.Full() == iri.Full()
+}
+
+// RenamePredicate replaces a predicate of all quads.
+type RenamePredicate struct {
+	From, To quad.IRI
+}
+
+func (r RenamePredicate) Rewrite(q quad.Quad) (quad.Quad, error) {
+	if sameIRI(q.Predicate, r.From) {
+		q.Predicate = r.To
+	}
+	return q, nil
+}
+
+// RenameType replaces an rdf:type of all nodes.
+type RenameType struct {
+	From, To quad.IRI
+}
+
+func (r RenameType) Rewrite(q quad.Quad) (quad.Quad, error) {
+	if sameIRI(q.Predicate, iriType) && sameIRI(q.Object, r.From) {
+		q.Object = r.To
+	}
+	return q, nil
+}
+
+// RemovePredicate removes all quads with a given predicate.
+type RemovePredicate struct {
+	Pred quad.IRI
+}
+
+func (r RemovePredicate) Rewrite(q quad.Quad) (quad.Quad, error) {
+	if sameIRI(q.Predicate, r.Pred) {
+		return quad.Quad{}, nil
+	}
+	return q, nil
+}
+
+// ConvertValues changes objects of all quads with a given predicate.
+type ConvertValues struct {
+	Pred quad.IRI
+	Conv func(v quad.Value) (quad.Value, error)
+}
+
+func (r ConvertValues) Rewrite(q quad.Quad) (quad.Quad, error) {
+	if !sameIRI(q.Predicate, r.Pred) {
+		return q, nil
+	}
+	v, err := r.Conv(q.Object)
+	if err != nil {
+		return q, fmt.Errorf("cannot convert %v: %v", q.Object, err)
+	}
+	q.Object = v
+	return q, nil
+}
+
+// MigrationFor compares two versions of a struct type and returns rules to migrate the data from the old one to the new one.
+//
+// Fields are matched by names. Changes of predicates and of the registered type IRI are migrated by renaming,
+// and values of fields with a different native type are converted through their string representation.
+// Fields that were removed or added are not migrated.
+func (c *Config) MigrationFor(from, to interface{}) ([]MigrationRule, error) {
+	ot, nt := typeOf(from), typeOf(to)
+	orules, err := c.rulesFor(ot)
+	if err != nil {
+		return nil, err
+	}
+	nrules, err := c.rulesFor(nt)
+	if err != nil {
+		return nil, err
+	}
+	var out []MigrationRule
+	if oi, ni := getTypeIRI(ot), getTypeIRI(nt); oi != "" && ni != "" && oi.Full() != ni.Full() {
+		out = append(out, RenameType{From: c.iri(oi), To: c.iri(ni)})
+	}
+	ofields, nfields := fieldTypes(ot, ""), fieldTypes(nt, "")
+	for name, r := range orules {
+		os, ok := r.(saveRule)
+		if !ok {
+			continue
+		}
+		ns, ok := nrules[name].(saveRule)
+		if !ok {
+			continue
+		}
+		if os.Rev != ns.Rev {
+			return nil, fmt.Errorf("cannot migrate field %s: direction changed", name)
+		}
+		if os.Pred.Full() != ns.Pred.Full() {
+			out = append(out, RenamePredicate{From: os.Pred, To: ns.Pred})
+		}
+		oft, nft := elemType(ofields[name]), elemType(nfields[name])
+		if oft == nft || os.Rev || os.Conv != nil || ns.Conv != nil || !isNative(oft) || !isNative(nft) {
+			continue
+		}
+		out = append(out, ConvertValues{Pred: ns.Pred, Conv: c.convertValue(nft)})
+	}
+	return out, nil
+}
+
+func typeOf(o interface{}) reflect.Type {
+	rt, ok := o.(reflect.Type)
+	if !ok {
+		rt = reflect.TypeOf(o)
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt
+}
+
+// fieldTypes returns types of all fields, using the same naming as field rules.
+func fieldTypes(rt reflect.Type, pref string) map[string]reflect.Type {
+	out := make(map[string]reflect.Type)
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.Anonymous {
+			if ft, ok := anonFieldType(f); ok {
+				for k, v := range fieldTypes(ft, pref+f.Name+".") {
+					out[k] = v
+				}
+			}
+			continue
+		}
+		out[pref+f.Name] = f.Type
+	}
+	return out
+}
+
+func elemType(rt reflect.Type) reflect.Type {
+	for rt != nil && (rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice) {
+		rt = rt.Elem()
+	}
+	return rt
+}
+
+// convertValue returns a function that converts quad values to a given native type.
+func (c *Config) convertValue(rt reflect.Type) func(v quad.Value) (quad.Value, error) {
+	return func(v quad.Value) (quad.Value, error) {
+		var s string
+		switch nv := quad.NativeOf(v).(type) {
+		case string:
+			s = nv
+		case time.Time:
+			s = nv.Format(time.RFC3339)
+		case nil:
+			s = v.String()
+		default:
+			s = fmt.Sprint(nv)
+		}
+		rv, err := parseDefault(s, rt)
+		if err != nil {
+			return nil, err
+		}
+		return c.newWriter(nil).writeAsQuads(rv)
+	}
+}
+
+// MigrateOptions controls the migration.
+type MigrateOptions struct {
+	// BatchSize is a number of changed quads that are written in a single transaction.
+	// If not set, quad.DefaultBatch is used.
+	BatchSize int
+	// Progress is called after each batch.
+	Progress func(MigrateProgress)
+}
+
+// MigrateProgress reports the state of the migration.
+type MigrateProgress struct {
+	Scanned  int // number of quads read from the store
+	Affected int // number of quads that must be changed
+	Written  int // number of quads that were already changed
+}
+
+type quadChange struct {
+	old, new quad.Quad
+}
+
+// Migrate rewrites all quads affected by the rules. Rules are applied to each quad in order.
+//
+// All quads are scanned first, and only affected ones are kept in memory. Changes are then written in batches,
+// so the migration is not atomic. Running it again on partially migrated data will finish the migration,
+// as long as rules only affect old versions of quads. If rules may produce quads that already exist,
+// the quad writer should ignore duplicates.
+func Migrate(ctx context.Context, qs graph.QuadStore, qw graph.QuadWriter, rules []MigrationRule, opts *MigrateOptions) (MigrateProgress, error) {
+	var o MigrateOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = quad.DefaultBatch
+	}
+	var (
+		prog    MigrateProgress
+		changes []quadChange
+	)
+	it := qs.QuadsAllIterator()
+	for it.Next(ctx) {
+		q := qs.Quad(it.Result())
+		prog.Scanned++
+		nq := q
+		for _, r := range rules {
+			var err error
+			if nq, err = r.Rewrite(nq); err != nil {
+				it.Close()
+				return prog, err
+			} else if !nq.IsValid() {
+				break
+			}
+		}
+		if nq != q {
+			changes = append(changes, quadChange{old: q, new: nq})
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return prog, err
+	}
+	prog.Affected = len(changes)
+	for len(changes) != 0 {
+		select {
+		case <-ctx.Done():
+			return prog, ctx.Err()
+		default:
+		}
+		batch := changes
+		if len(batch) > o.BatchSize {
+			batch = batch[:o.BatchSize]
+		}
+		changes = changes[len(batch):]
+		tx := graph.NewTransactionN(2 * len(batch))
+		for _, ch := range batch {
+			tx.RemoveQuad(ch.old)
+			if ch.new.IsValid() {
+				tx.AddQuad(ch.new)
+			}
+		}
+		if err = qw.ApplyTransaction(tx); err != nil {
+			return prog, err
+		}
+		prog.Written += len(batch)
+		if o.Progress != nil {
+			o.Progress(prog)
+		}
+	}
+	return prog, nil
+}
@@ -0,0 +1,74 @@
+package schema_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+	"github.com/cayleygraph/cayley/writer"
+)
+
+type userV1 struct {
+	ID    quad.IRI `quad:"@id"`
+	Name  string   `quad:"name"`
+	Age   string   `quad:"age"`
+	Email string   `quad:"email,optional"`
+}
+
+type userV2 struct {
+	ID    quad.IRI `quad:"@id"`
+	Name  string   `quad:"fullName"`
+	Age   int      `quad:"age"`
+	Email string   `quad:"email,optional"`
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.TODO()
+	sch := schema.NewConfig()
+	qs := memstore.New(
+		quad.Quad{iri("u1"), iri("name"), quad.String("Bob"), nil},
+		quad.Quad{iri("u1"), iri("age"), quad.String("42"), nil},
+		quad.Quad{iri("u1"), iri("email"), quad.String("bob@example.org"), nil},
+		quad.Quad{iri("u2"), iri("name"), quad.String("Alice"), nil},
+		quad.Quad{iri("u2"), iri("age"), quad.String("37"), nil},
+		quad.Quad{iri("u2"), iri("legacy"), quad.String("x"), nil},
+	)
+	qw, err := writer.NewSingle(qs, graph.IgnoreOpts{IgnoreDup: true})
+	require.NoError(t, err)
+
+	rules, err := sch.MigrationFor(userV1{}, userV2{})
+	require.NoError(t, err)
+	rules = append(rules, schema.RemovePredicate{Pred: iri("legacy")})
+
+	var reports []schema.MigrateProgress
+	prog, err := schema.Migrate(ctx, qs, qw, rules, &schema.MigrateOptions{
+		BatchSize: 2,
+		Progress: func(p schema.MigrateProgress) {
+			reports = append(reports, p)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, schema.MigrateProgress{Scanned: 6, Affected: 5, Written: 5}, prog)
+	require.Len(t, reports, 3)
+
+	var users []userV2
+	err = sch.LoadTo(ctx, qs, &users, iri("u1"), iri("u2"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []userV2{
+		{ID: "u1", Name: "Bob", Age: 42, Email: "bob@example.org"},
+		{ID: "u2", Name: "Alice", Age: 37},
+	}, users)
+	all, err := quad.ReadAll(graph.NewQuadStoreReader(qs))
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+
+	// nothing left to migrate
+	prog, err = schema.Migrate(ctx, qs, qw, rules, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, prog.Affected)
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// isPolymorphic checks if values of a given type are loaded as one of registered types that implement it.
+//
+// Interfaces that are implemented by quad.Value (including an empty interface) are loaded as quad values.
+func isPolymorphic(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Interface && !reflQuadValue.Implements(rt)
+}
+
+// typeForNode finds a registered type for one of rdf:type values of a node that implements a given interface.
+// Types are checked in the order they are returned by the quad store.
+//
+// If only a pointer to registered type implements the interface, a pointer type is returned.
+func (l *loader) typeForNode(ctx context.Context, ref graph.Value, it reflect.Type) (reflect.Type, error) {
+	types, err := path.StartPathNodes(l.qs, ref).Out(l.c.iri(iriType)).Iterate(ctx).AllValues(l.qs)
+	if err != nil {
+		return nil, err
+	}
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	for _, t := range types {
+		tiri, ok := t.(quad.IRI)
+		if !ok {
+			continue
+		}
+		rt, ok := iriToType[tiri.Full()]
+		if !ok {
+			continue
+		}
+		if rt.Implements(it) {
+			return rt, nil
+		} else if reflect.PtrTo(rt).Implements(it) {
+			return reflect.PtrTo(rt), nil
+		}
+	}
+	return nil, nil
+}
+
+// loadPolymorphicToDepth loads objects from the list to a value (or a slice, or a channel) of an interface type.
+// Each object is loaded as a registered type that matches its rdf:type and implements the interface.
+// Nodes without such a type are skipped.
+func (l *loader) loadPolymorphicToDepth(ctx context.Context, dst reflect.Value, depth int, list graph.Iterator) error {
+	et := dst.Type()
+	slice, chanl := false, false
+	if dst.Kind() == reflect.Slice {
+		et = et.Elem()
+		slice = true
+	} else if dst.Kind() == reflect.Chan {
+		et = et.Elem()
+		chanl = true
+		defer dst.Close()
+	}
+	if list == nil {
+		list = path.StartPath(l.qs).Has(l.c.iri(iriType)).Unique().BuildIterator()
+	}
+	defer list.Close()
+	for list.Next(ctx) {
+		ref := list.Result()
+		rt, err := l.typeForNode(ctx, ref, et)
+		if err != nil {
+			return err
+		} else if rt == nil {
+			continue
+		}
+		ptr := rt.Kind() == reflect.Ptr
+		if ptr {
+			rt = rt.Elem()
+		}
+		sv := reflect.New(rt)
+		err = l.loadIteratorToDepth(ctx, sv, depth, iterator.NewFixed(ref))
+		if err == errRequiredFieldIsMissing || err == errNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !ptr {
+			sv = sv.Elem()
+		}
+		if slice {
+			dst.Set(reflect.Append(dst, sv))
+		} else if chanl {
+			dst.Send(sv)
+		} else {
+			dst.Set(sv)
+			return nil
+		}
+	}
+	if err := list.Err(); err != nil {
+		return err
+	}
+	if slice || chanl {
+		return nil
+	}
+	return errNotFound
+}
@@ -0,0 +1,82 @@
+package schema_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+)
+
+func init() {
+	schema.RegisterType(quad.IRI("http://example.org/Post"), Post{})
+	schema.RegisterType(quad.IRI("http://example.org/Like"), Like{})
+}
+
+type Activity interface {
+	ActivityID() quad.IRI
+}
+
+type Post struct {
+	ID   quad.IRI `quad:"@id"`
+	Text string   `quad:"text"`
+}
+
+func (p Post) ActivityID() quad.IRI { return p.ID }
+
+type Like struct {
+	ID     quad.IRI `quad:"@id"`
+	Object quad.IRI `quad:"object"`
+}
+
+func (l *Like) ActivityID() quad.IRI { return l.ID }
+
+type Feed struct {
+	ID     quad.IRI   `quad:"@id"`
+	Items  []Activity `quad:"item"`
+	Pinned Activity   `quad:"pinned,optional"`
+}
+
+func TestPolymorphic(t *testing.T) {
+	ctx := context.TODO()
+	sch := schema.NewConfig()
+	feed := Feed{
+		ID: "feed",
+		Items: []Activity{
+			Post{ID: "p1", Text: "hello"},
+			&Like{ID: "l1", Object: "p1"},
+		},
+		Pinned: Post{ID: "p1", Text: "hello"},
+	}
+	var out quadSlice
+	if _, err := sch.WriteAsQuads(&out, feed); err != nil {
+		t.Fatal(err)
+	}
+	// unknown type is skipped
+	out = append(out, quad.Quad{iri("feed"), iri("item"), iri("x1"), nil},
+		quad.Quad{iri("x1"), iri("rdf:type"), iri("ex:Unknown"), nil})
+	qs := memstore.New(out...)
+
+	var got Feed
+	if err := sch.LoadTo(ctx, qs, &got, iri("feed")); err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(got.Items, func(i, j int) bool {
+		return got.Items[i].ActivityID() < got.Items[j].ActivityID()
+	})
+	feed.Items = []Activity{feed.Items[1], feed.Items[0]}
+	if !reflect.DeepEqual(got, feed) {
+		t.Fatalf("objects are different\n%#v\n%#v", got, feed)
+	}
+
+	var all []Activity
+	if err := sch.LoadTo(ctx, qs, &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("unexpected activities: %#v", all)
+	}
+}
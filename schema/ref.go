@@ -0,0 +1,82 @@
+//go:build go1.18
+// +build go1.18
+
+package schema
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var errRefNotBound = errors.New("reference is not bound to a quad store")
+
+var (
+	_ lazyRef  = (*Ref[struct{}])(nil)
+	_ refValue = Ref[struct{}]{}
+)
+
+// Ref is a lazy reference to a linked object of type T.
+//
+// When a field of type Ref is loaded, only the ID of the linked node is read, and the object itself
+// is loaded on the first call to Get, or explicitly with Resolve. This prevents loading large parts
+// of the graph when objects are linked to each other. Only the link is written for Ref fields.
+//
+//	type Person struct{
+//		ID    quad.IRI           `quad:"@id"`
+//		Knows []schema.Ref[Person] `quad:"knows"`
+//	}
+type Ref[T any] struct {
+	ID quad.Value
+
+	c  *Config
+	qs graph.QuadStore
+
+	// val is set when the object is loaded; it's a pointer to allow self-referencing types
+	val *T
+}
+
+// RefTo creates a reference to an object with a given ID.
+func RefTo[T any](id quad.Value) Ref[T] {
+	return Ref[T]{ID: id}
+}
+
+func (r *Ref[T]) bindRef(c *Config, qs graph.QuadStore, id quad.Value) {
+	*r = Ref[T]{ID: id, c: c, qs: qs}
+}
+
+func (r Ref[T]) refID() quad.Value {
+	return r.ID
+}
+
+// Loaded checks if the referenced object was already loaded.
+func (r *Ref[T]) Loaded() bool {
+	return r.val != nil
+}
+
+// Get returns the referenced object, loading it from the quad store it was read from, if necessary.
+func (r *Ref[T]) Get(ctx context.Context) (T, error) {
+	if r.val != nil {
+		return *r.val, nil
+	} else if r.qs == nil {
+		var zero T
+		return zero, errRefNotBound
+	}
+	return r.Resolve(ctx, r.qs)
+}
+
+// Resolve loads the referenced object from a given quad store, even if it was already loaded.
+func (r *Ref[T]) Resolve(ctx context.Context, qs graph.QuadStore) (T, error) {
+	c := r.c
+	if c == nil {
+		c = global
+	}
+	var out T
+	if err := c.LoadTo(ctx, qs, &out, r.ID); err != nil {
+		return out, err
+	}
+	r.val = &out
+	return out, nil
+}
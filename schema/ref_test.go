@@ -0,0 +1,73 @@
+//go:build go1.18
+// +build go1.18
+
+package schema_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/schema"
+)
+
+type refPerson struct {
+	ID    quad.IRI                `quad:"@id"`
+	Name  string                  `quad:"name"`
+	Knows []schema.Ref[refPerson] `quad:"knows,optional"`
+	Boss  schema.Ref[refPerson]   `quad:"boss,optional"`
+}
+
+func TestLazyRef(t *testing.T) {
+	ctx := context.TODO()
+	sch := schema.NewConfig()
+
+	var out quadSlice
+	_, err := sch.WriteAsQuads(&out, refPerson{
+		ID: "bob", Name: "Bob",
+		Knows: []schema.Ref[refPerson]{schema.RefTo[refPerson](iri("alice"))},
+		Boss:  schema.RefTo[refPerson](iri("alice")),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []quad.Quad{
+		{iri("bob"), iri("name"), quad.String("Bob"), nil},
+		{iri("bob"), iri("knows"), iri("alice"), nil},
+		{iri("bob"), iri("boss"), iri("alice"), nil},
+	}, []quad.Quad(out))
+
+	_, err = sch.WriteAsQuads(&out, refPerson{ID: "alice", Name: "Alice", Knows: []schema.Ref[refPerson]{
+		schema.RefTo[refPerson](iri("bob")),
+	}})
+	require.NoError(t, err)
+	qs := memstore.New(out...)
+
+	var bob refPerson
+	err = sch.LoadTo(ctx, qs, &bob, iri("bob"))
+	require.NoError(t, err)
+	require.Len(t, bob.Knows, 1)
+	ref := &bob.Knows[0]
+	require.Equal(t, quad.Value(iri("alice")), ref.ID)
+	require.False(t, ref.Loaded())
+
+	alice, err := ref.Get(ctx)
+	require.NoError(t, err)
+	require.True(t, ref.Loaded())
+	require.Equal(t, "Alice", alice.Name)
+	// links of the referenced object are not loaded either
+	require.Len(t, alice.Knows, 1)
+	require.False(t, alice.Knows[0].Loaded())
+
+	boss, err := bob.Boss.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, quad.IRI("alice"), boss.ID)
+
+	unbound := schema.RefTo[refPerson](iri("alice"))
+	_, err = unbound.Get(ctx)
+	require.Error(t, err)
+	p, err := unbound.Resolve(ctx, qs)
+	require.NoError(t, err)
+	require.Equal(t, "Alice", p.Name)
+}
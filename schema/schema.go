@@ -18,7 +18,20 @@ import (
 	"github.com/cayleygraph/cayley/voc/rdf"
 )
 
-var reflQuadValue = reflect.TypeOf((*quad.Value)(nil)).Elem()
+var (
+	reflQuadValue = reflect.TypeOf((*quad.Value)(nil)).Elem()
+	reflLazyRef   = reflect.TypeOf((*lazyRef)(nil)).Elem()
+)
+
+// lazyRef is implemented by lazy references to objects. Only IDs are loaded for fields of such types.
+type lazyRef interface {
+	bindRef(c *Config, qs graph.QuadStore, id quad.Value)
+}
+
+// refValue is implemented by lazy references. Only IDs are written for fields of such types.
+type refValue interface {
+	refID() quad.Value
+}
 
 type ErrReqFieldNotSet struct {
 	Field string
@@ -91,6 +104,10 @@ type saveRule struct {
 	Pred quad.IRI
 	Rev  bool
 	Opt  bool
+	// Default is assigned to the field if no values were loaded.
+	Default reflect.Value
+	// Conv converts values of the field instead of the default conversion rules.
+	Conv Converter
 }
 
 func (saveRule) isRule() {}
@@ -148,17 +165,35 @@ func (c Config) fieldRule(fld reflect.StructField) (rule, error) {
 	}
 	opt := false
 	req := false
+	var (
+		def  reflect.Value
+		conv Converter
+	)
 	for _, s := range sub {
+		s = strings.Trim(s, trim)
 		if s == "opt" || s == "optional" {
 			opt = true
 		}
 		if s == "req" || s == "required" {
 			req = true
 		}
+		if strings.HasPrefix(s, "default=") {
+			v, err := parseDefault(strings.TrimPrefix(s, "default="), fld.Type)
+			if err != nil {
+				return nil, fmt.Errorf("default value of field %s: %v", fld.Name, err)
+			}
+			def = v
+		}
+		if strings.HasPrefix(s, "conv=") {
+			name := strings.TrimPrefix(s, "conv=")
+			if conv = getConverter(name); conv == nil {
+				return nil, fmt.Errorf("unknown converter for field %s: %q", fld.Name, name)
+			}
+		}
 	}
 	if req {
 		opt = false
-	} else if fld.Type.Kind() == reflect.Slice {
+	} else if fld.Type.Kind() == reflect.Slice || def.IsValid() {
 		opt = true
 	}
 
@@ -191,7 +226,7 @@ func (c Config) fieldRule(fld reflect.StructField) (rule, error) {
 	}
 	p := c.toIRI(ps)
 	if vs == "" || vs == any && fld.Type != reflEmptyStruct {
-		return saveRule{Pred: p, Rev: rev, Opt: opt}, nil
+		return saveRule{Pred: p, Rev: rev, Opt: opt, Default: def, Conv: conv}, nil
 	} else {
 		return constraintRule{Pred: p, Val: c.toIRI(vs), Rev: rev}, nil
 	}
@@ -401,7 +436,7 @@ func isExported(name string) bool {
 
 func isZero(rv reflect.Value) bool {
 	switch rv.Kind() {
-	case reflect.Ptr:
+	case reflect.Ptr, reflect.Interface:
 		return rv.IsNil()
 	case reflect.Slice, reflect.Map:
 		return rv.IsNil() || rv.Len() == 0
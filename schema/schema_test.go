@@ -139,6 +139,14 @@ type OptFields struct {
 	Two string `quad:"two,optional"`
 }
 
+type DefaultFields struct {
+	ID     quad.IRI `quad:"@id"`
+	Name   string   `quad:"name"`
+	Status string   `quad:"status,default=active"`
+	Rating int      `quad:"rating,default=5"`
+	Tags   []string `quad:"tag,default=none"`
+}
+
 func iri(s string) quad.IRI { return quad.IRI(s) }
 
 const typeIRI = quad.IRI(rdf.Type)
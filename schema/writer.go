@@ -23,6 +23,12 @@ type writer struct {
 	c    *Config
 	w    quad.Writer
 	seen map[uintptr]quad.Value
+
+	// ordered makes the writer buffer quads of each object until all nested objects are written.
+	ordered bool
+	stack   [][]quad.Quad
+	// requireID prevents generating IDs for objects without an ID field.
+	requireID bool
 }
 
 func (c *Config) newWriter(w quad.Writer) *writer {
@@ -33,16 +39,53 @@ func (w *writer) writeQuad(s, p, o quad.Value, rev bool) error {
 	if rev {
 		s, o = o, s
 	}
-	return w.w.WriteQuad(quad.Quad{Subject: s, Predicate: p, Object: o, Label: w.c.Label})
+	q := quad.Quad{Subject: s, Predicate: p, Object: o, Label: w.c.Label}
+	if n := len(w.stack); n != 0 {
+		w.stack[n-1] = append(w.stack[n-1], q)
+		return nil
+	}
+	return w.w.WriteQuad(q)
+}
+
+// push starts buffering quads of a new object.
+func (w *writer) push() {
+	if w.ordered {
+		w.stack = append(w.stack, nil)
+	}
+}
+
+// pop writes all buffered quads of the current object. Quads of nested objects are already written at this point.
+func (w *writer) pop() error {
+	if !w.ordered {
+		return nil
+	}
+	n := len(w.stack)
+	buf := w.stack[n-1]
+	w.stack = w.stack[:n-1]
+	for _, q := range buf {
+		if err := w.w.WriteQuad(q); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // writeOneValReflect writes a set of quads corresponding to a value. It may omit writing quads if value is zero.
-func (w *writer) writeOneValReflect(id quad.Value, pred quad.Value, rv reflect.Value, rev bool) error {
+// Converter is used to get a value to write, if it's set.
+func (w *writer) writeOneValReflect(id quad.Value, pred quad.Value, rv reflect.Value, rev bool, conv Converter) error {
 	if isZero(rv) {
 		return nil
 	}
 	// write field value and get an ID
-	sid, err := w.writeAsQuads(rv)
+	var (
+		sid quad.Value
+		err error
+	)
+	if conv != nil {
+		sid, err = conv.ToValue(rv.Interface())
+	} else {
+		sid, err = w.writeAsQuads(rv)
+	}
 	if err != nil {
 		return err
 	}
@@ -95,7 +138,7 @@ func (w *writer) writeValueAs(id quad.Value, rv reflect.Value, pref string, rule
 			if f.Type.Kind() == reflect.Slice {
 				sl := rv.Field(i)
 				for j := 0; j < sl.Len(); j++ {
-					if err := w.writeOneValReflect(id, r.Pred, sl.Index(j), r.Rev); err != nil {
+					if err := w.writeOneValReflect(id, r.Pred, sl.Index(j), r.Rev, r.Conv); err != nil {
 						return err
 					}
 				}
@@ -104,7 +147,7 @@ func (w *writer) writeValueAs(id quad.Value, rv reflect.Value, pref string, rule
 				if !r.Opt && isZero(fv) {
 					return ErrReqFieldNotSet{Field: f.Name}
 				}
-				if err := w.writeOneValReflect(id, r.Pred, fv, r.Rev); err != nil {
+				if err := w.writeOneValReflect(id, r.Pred, fv, r.Rev, r.Conv); err != nil {
 					return err
 				}
 			}
@@ -114,6 +157,9 @@ func (w *writer) writeValueAs(id quad.Value, rv reflect.Value, pref string, rule
 }
 
 func (w *writer) writeAsQuads(rv reflect.Value) (quad.Value, error) {
+	if rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
 	rt := rv.Type()
 	// if node is a primitive - return directly
 	if rt.Implements(reflQuadValue) {
@@ -134,6 +180,13 @@ func (w *writer) writeAsQuads(rv reflect.Value) (quad.Value, error) {
 			kind = rt.Kind()
 		}
 	}
+	// lazy references are written as links
+	if ref, ok := rv.Interface().(refValue); ok {
+		if id := ref.refID(); id != nil {
+			return id, nil
+		}
+		return nil, fmt.Errorf("reference without an ID: %v", rt)
+	}
 	// check if it's a type that quads package supports
 	// note, that it may be a struct such as time.Time
 	if val, ok := quad.AsValue(rv.Interface()); ok {
@@ -168,6 +221,9 @@ func (w *writer) writeAsQuads(rv reflect.Value) (quad.Value, error) {
 		return nil, err
 	}
 	if id == nil {
+		if w.requireID {
+			return nil, fmt.Errorf("object of type %v has no ID", rt)
+		}
 		id = w.c.genID(prv.Interface())
 	}
 	// save a node ID to avoid loops
@@ -176,8 +232,12 @@ func (w *writer) writeAsQuads(rv reflect.Value) (quad.Value, error) {
 		ptr := prv.Pointer()
 		w.seen[ptr] = id
 	}
+	w.push()
 	if err = w.writeValueAs(id, rv, "", rules); err != nil {
 		return nil, err
 	}
+	if err = w.pop(); err != nil {
+		return nil, err
+	}
 	return id, nil
 }
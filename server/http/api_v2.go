@@ -15,6 +15,7 @@
 package cayleyhttp
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
@@ -23,17 +24,34 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/cayleygraph/cayley/clog"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/audit"
+	"github.com/cayleygraph/cayley/graph/iterator"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/graph/quota"
+	"github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/graph/trash"
+	"github.com/cayleygraph/cayley/internal/memory"
+	"github.com/cayleygraph/cayley/internal/system"
+	"github.com/cayleygraph/cayley/internal/view"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
 	"github.com/cayleygraph/cayley/query"
 	_ "github.com/cayleygraph/cayley/writer"
 )
 
+// logger is used for messages of the HTTP API.
+var logger = clog.Module("http")
+
 func NewAPIv2(h *graph.Handle) *APIv2 {
 	return NewAPIv2Writer(h, "single", nil)
 }
@@ -48,9 +66,12 @@ func NewAPIv2Writer(h *graph.Handle, wtype string, wopts graph.Options) *APIv2 {
 type APIv2 struct {
 	h     *graph.Handle
 	r     *httprouter.Router
-	ro    bool
 	batch int
 
+	// mu guards settings that can be changed while serving requests
+	mu sync.RWMutex
+	ro bool
+
 	// replication
 	wtyp string
 	wopt graph.Options
@@ -58,19 +79,142 @@ type APIv2 struct {
 	// query
 	timeout time.Duration
 	limit   int
+
+	// bulk loads
+	loads loadSessions
+
+	// multi-request transactions
+	txs       txSessions
+	txTimeout time.Duration
+
+	// idempotent writes
+	writes     appliedWrites
+	writeIDTTL time.Duration
+
+	// read-your-writes sessions
+	sessionMaxWait time.Duration
+
+	// query sessions with shared defaults
+	qsessions           querySessions
+	querySessionTimeout time.Duration
+
+	audit *audit.Log
+	feed  *replication.Feed
+
+	// system graph and query statistics recorded into it
+	sys   *system.Graph
+	stats *system.QueryStats
+	// running queries
+	active *query.Active
+	// memory budget shared by queries and the limit of a single query
+	mem      *memory.Budget
+	memLimit int64
+	// number of independent branches a query can run concurrently
+	parallelism int
+	// named graph selected by queries without the graph parameter
+	defGraph quad.Value
+	// graphs accessible by each principal
+	acl *acl.ACL
+	// token of administrators
+	adminToken string
+
+	// limits of the database and its graphs
+	quota *quota.Limits
+	// virtual graphs defined by saved queries
+	views *view.Views
+	// time deleted graphs are kept in the trash
+	trashRetention time.Duration
+}
+
+// SetDefaultGraph restricts queries and reads without the graph parameter to a named graph.
+// Requests can still select another graph by its name, or all graphs with graph=*.
+func (api *APIv2) SetDefaultGraph(label quad.Value) {
+	api.defGraph = label
+}
+
+// SetQueryMemory enables memory accounting of queries. Queries are rejected while the budget is exhausted,
+// and fail if they exceed the limit. Either can be zero.
+func (api *APIv2) SetQueryMemory(budget *memory.Budget, limit int64) {
+	api.mem, api.memLimit = budget, limit
+}
+
+// SetQueryParallelism allows each query to run up to n independent expensive branches concurrently.
+func (api *APIv2) SetQueryParallelism(n int) {
+	api.parallelism = n
+}
+
+// queryMemory attaches a memory account of a query to the context. The account must be closed when the query completes.
+// It returns memory.ErrBudget if the query must be rejected.
+func (api *APIv2) queryMemory(ctx context.Context) (context.Context, *memory.Account, error) {
+	if api.mem.Exhausted() {
+		return ctx, nil, memory.ErrBudget
+	}
+	acc := api.mem.NewAccount(api.memLimit)
+	return memory.NewContext(ctx, acc), acc, nil
+}
+
+// SetActiveQueries enables tracking of running queries, so they can be listed and cancelled.
+func (api *APIv2) SetActiveQueries(a *query.Active) {
+	api.active = a
+}
+
+// SetSystemGraph enables queries of the system graph with the graph=system parameter.
+func (api *APIv2) SetSystemGraph(g *system.Graph) {
+	api.sys = g
+}
+
+// SetQueryStats enables recording of query statistics.
+func (api *APIv2) SetQueryStats(st *system.QueryStats) {
+	api.stats = st
 }
 
 func (api *APIv2) SetReadOnly(ro bool) {
+	api.mu.Lock()
 	api.ro = ro
+	api.mu.Unlock()
 }
 func (api *APIv2) SetBatchSize(n int) {
 	api.batch = n
 }
 func (api *APIv2) SetQueryTimeout(dt time.Duration) {
+	api.mu.Lock()
 	api.timeout = dt
+	api.mu.Unlock()
+}
+func (api *APIv2) SetTxTimeout(dt time.Duration) {
+	api.txTimeout = dt
+}
+func (api *APIv2) SetWriteIDTTL(dt time.Duration) {
+	api.writeIDTTL = dt
+}
+func (api *APIv2) SetSessionWait(dt time.Duration) {
+	api.sessionMaxWait = dt
+}
+func (api *APIv2) SetQuerySessionTimeout(dt time.Duration) {
+	api.querySessionTimeout = dt
 }
 func (api *APIv2) SetQueryLimit(n int) {
+	api.mu.Lock()
 	api.limit = n
+	api.mu.Unlock()
+}
+
+func (api *APIv2) readOnly() bool {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.ro
+}
+
+func (api *APIv2) queryTimeout() time.Duration {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.timeout
+}
+
+func (api *APIv2) queryLimit() int {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.limit
 }
 func (api *APIv2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	api.r.ServeHTTP(w, r)
@@ -88,18 +232,37 @@ func wrap(h http.HandlerFunc, arr []HandlerWrapper) httprouter.Handle {
 	return wh
 }
 func (api *APIv2) RegisterDataOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
-	if !api.ro {
-		r.POST("/api/v2/write", wrap(api.ServeWrite, wrappers))
-		r.POST("/api/v2/delete", wrap(api.ServeDelete, wrappers))
-		r.POST("/api/v2/node/delete", wrap(api.ServeNodeDelete, wrappers))
-	}
+	// write handlers check the read-only flag on each request, since it can be changed while serving
+	r.POST("/api/v2/write", wrap(api.ServeWrite, wrappers))
+	r.POST("/api/v2/delete", wrap(api.ServeDelete, wrappers))
+	r.POST("/api/v2/node/delete", wrap(api.ServeNodeDelete, wrappers))
+	r.POST("/api/v2/graph/copy", wrap(api.ServeCopy, wrappers))
+	r.POST("/api/v2/graph/delete", wrap(api.ServeGraphDelete, wrappers))
+	r.POST("/api/v2/graph/restore", wrap(api.ServeGraphRestore, wrappers))
+	r.POST("/api/v2/graph/purge", wrap(api.ServeGraphPurge, wrappers))
+	r.GET("/api/v2/graph/trash", wrap(api.ServeGraphTrash, wrappers))
+	r.POST("/api/v2/load", wrap(api.ServeLoad, wrappers))
+	r.GET("/api/v2/load/status", wrap(api.ServeLoadStatus, wrappers))
+	r.POST("/api/v2/tx/begin", wrap(api.ServeTxBegin, wrappers))
+	r.POST("/api/v2/tx/write", wrap(api.ServeTxWrite, wrappers))
+	r.POST("/api/v2/tx/delete", wrap(api.ServeTxDelete, wrappers))
+	r.GET("/api/v2/tx/read", wrap(api.ServeTxRead, wrappers))
+	r.POST("/api/v2/tx/commit", wrap(api.ServeTxCommit, wrappers))
+	r.POST("/api/v2/tx/rollback", wrap(api.ServeTxRollback, wrappers))
 	r.POST("/api/v2/read", wrap(api.ServeRead, wrappers))
 	r.GET("/api/v2/read", wrap(api.ServeRead, wrappers))
 	r.GET("/api/v2/formats", wrap(api.ServeFormats, wrappers))
+	r.GET(replication.ChangesPath, wrap(api.ServeReplicationChanges, wrappers))
+	r.GET(replication.SnapshotPath, wrap(api.ServeReplicationSnapshot, wrappers))
 }
 func (api *APIv2) RegisterQueryOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
 	r.POST("/api/v2/query", wrap(api.ServeQuery, wrappers))
 	r.GET("/api/v2/query", wrap(api.ServeQuery, wrappers))
+	r.POST("/api/v2/query/session/begin", wrap(api.ServeQuerySessionBegin, wrappers))
+	r.GET("/api/v2/query/session", wrap(api.ServeQuerySession, wrappers))
+	r.POST("/api/v2/query/session/update", wrap(api.ServeQuerySessionUpdate, wrappers))
+	r.POST("/api/v2/query/session/end", wrap(api.ServeQuerySessionEnd, wrappers))
+	r.GET("/api/v2/complete", wrap(api.ServeComplete, wrappers))
 }
 func (api *APIv2) RegisterOn(r *httprouter.Router, wrappers ...HandlerWrapper) {
 	api.RegisterDataOn(r, wrappers...)
@@ -169,9 +332,76 @@ func (api *APIv2) handleForRequest(r *http.Request) (*graph.Handle, error) {
 	return HandleForRequest(api.h, api.wtyp, api.wopt, r)
 }
 
+// preconditions parses quad preconditions from the "if_exists" and "if_not_exists" URL parameters.
+// Each value is a single quad in N-Quads format.
+func preconditions(r *http.Request) ([]graph.Precondition, error) {
+	var out []graph.Precondition
+	vals := r.URL.Query()
+	for _, c := range []struct {
+		key    string
+		exists bool
+	}{
+		{"if_exists", true},
+		{"if_not_exists", false},
+	} {
+		for _, s := range vals[c.key] {
+			s = strings.TrimSpace(s)
+			if !strings.HasSuffix(s, ".") {
+				s += " ."
+			}
+			q, err := nquads.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s precondition: %v", c.key, err)
+			}
+			out = append(out, graph.Precondition{Quad: q, Exists: c.exists})
+		}
+	}
+	return out, nil
+}
+
+// applyIf applies all quads from the reader in a single transaction with given preconditions.
+func applyIf(h *graph.Handle, qr quad.Reader, conds []graph.Precondition, del bool) (int, error) {
+	quads, err := quad.ReadAll(qr)
+	if err != nil {
+		return 0, err
+	}
+	tx := graph.NewTransactionN(len(quads))
+	tx.Preconditions = conds
+	for _, q := range quads {
+		if del {
+			tx.RemoveQuad(q)
+		} else {
+			tx.AddQuad(q)
+		}
+	}
+	return len(quads), h.QuadWriter.ApplyTransaction(tx)
+}
+
+func writeErrorCode(err error) int {
+	switch {
+	case graph.IsPreconditionFailed(err):
+		return http.StatusPreconditionFailed
+	case err == graph.ErrPreconditionsNotSupported:
+		return http.StatusNotImplemented
+	case err == replication.ErrStandby:
+		return http.StatusServiceUnavailable
+	case err == acl.ErrForbidden:
+		return http.StatusForbidden
+	case quota.IsExceeded(err):
+		return quotaErrorCode(err)
+	case err == trash.ErrNotFound:
+		return http.StatusNotFound
+	case err == trash.ErrDeleted:
+		return http.StatusConflict
+	case err == trash.ErrNoLabel:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 func (api *APIv2) ServeWrite(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	if api.ro {
+	if api.readOnly() {
 		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
 		return
 	}
@@ -188,30 +418,41 @@ func (api *APIv2) ServeWrite(w http.ResponseWriter, r *http.Request) {
 	defer rd.Close()
 	qr := format.Reader(rd)
 	defer qr.Close()
-	h, err := api.handleForRequest(r)
+	h, err := api.writeHandleForRequest(w, r)
 	if err != nil {
 		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	qw := graph.NewWriter(h.QuadWriter)
-	defer qw.Close()
-	n, err := quad.CopyBatch(qw, qr, api.batch)
-	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
-		return
-	}
-	err = qw.Close()
+	conds, err := preconditions(r)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	w.Header().Set(hdrContentType, contentTypeJSON)
-	fmt.Fprintf(w, `{"result": "Successfully wrote %d quads.", "count": %d}`+"\n", n, n)
+	api.applyOnce(w, r, h.QuadStore, func(atomic bool) (string, int, error) {
+		var n int
+		if atomic || len(conds) != 0 {
+			n, err = applyIf(h, qr, conds, false)
+			if err != nil {
+				return "", writeErrorCode(err), err
+			}
+		} else {
+			qw := graph.NewWriter(h.QuadWriter)
+			defer qw.Close()
+			n, err = quad.CopyBatch(qw, qr, api.batch)
+			if err == nil {
+				err = qw.Close()
+			}
+			if err != nil {
+				return "", writeErrorCode(err), err
+			}
+		}
+		return fmt.Sprintf(`{"result": "Successfully wrote %d quads.", "count": %d}`+"\n", n, n), 0, nil
+	})
 }
 
 func (api *APIv2) ServeDelete(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	if api.ro {
+	if api.readOnly() {
 		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
 		return
 	}
@@ -228,25 +469,38 @@ func (api *APIv2) ServeDelete(w http.ResponseWriter, r *http.Request) {
 	defer rd.Close()
 	qr := format.Reader(r.Body)
 	defer qr.Close()
-	h, err := api.handleForRequest(r)
+	h, err := api.writeHandleForRequest(w, r)
 	if err != nil {
 		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	qw := graph.NewRemover(h.QuadWriter)
-	defer qw.Close()
-	n, err := quad.CopyBatch(qw, qr, api.batch)
+	conds, err := preconditions(r)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	w.Header().Set(hdrContentType, contentTypeJSON)
-	fmt.Fprintf(w, `{"result": "Successfully deleted %d quads.", "count": %d}`+"\n", n, n)
+	api.applyOnce(w, r, h.QuadStore, func(atomic bool) (string, int, error) {
+		var n int
+		if atomic || len(conds) != 0 {
+			n, err = applyIf(h, qr, conds, true)
+			if err != nil {
+				return "", writeErrorCode(err), err
+			}
+		} else {
+			qw := graph.NewRemover(h.QuadWriter)
+			defer qw.Close()
+			n, err = quad.CopyBatch(qw, qr, api.batch)
+			if err != nil {
+				return "", writeErrorCode(err), err
+			}
+		}
+		return fmt.Sprintf(`{"result": "Successfully deleted %d quads.", "count": %d}`+"\n", n, n), 0, nil
+	})
 }
 
 func (api *APIv2) ServeNodeDelete(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	if api.ro {
+	if api.readOnly() {
 		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
 		return
 	}
@@ -273,16 +527,17 @@ func (api *APIv2) ServeNodeDelete(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("cannot remove nil value"))
 		return
 	}
-	h, err := api.handleForRequest(r)
+	h, err := api.writeHandleForRequest(w, r)
 	if err != nil {
 		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
 	err = h.RemoveNode(v)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, err)
+		jsonResponse(w, writeErrorCode(err), err)
 		return
 	}
+	setSession(w, h.QuadStore)
 	w.Header().Set(hdrContentType, contentTypeJSON)
 	const n = 1
 	fmt.Fprintf(w, `{"result": "Successfully deleted %d nodes.", "count": %d}`+"\n", n, n)
@@ -309,9 +564,79 @@ func (api *APIv2) ServeRead(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	qr := graph.NewQuadStoreReader(h.QuadStore)
+	if err = api.waitSession(r.Context(), r, h.QuadStore); err != nil {
+		jsonResponse(w, sessionErrorCode(err), err)
+		return
+	}
+	asOf, err := asOfParam(r, h.QuadStore)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	qs, _, done, err := snapshotOf(w, h.QuadStore, asOf)
+	if err != nil {
+		jsonResponse(w, snapshotErrorCode(err), err)
+		return
+	}
+	defer done()
+	if qs, err = api.restrict(r, qs); err != nil {
+		jsonResponse(w, writeErrorCode(err), err)
+		return
+	}
+	if qs, err = api.evaluateView(r.Context(), r, qs); err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	qs = named.Select(qs, r.URL.Query().Get("graph"), api.defGraph)
+	var qr quad.ReadSkipCloser
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		end := api.beginQuery(w, r)
+		if end == nil {
+			return
+		}
+		defer end()
+		qu, err := queryParam(r)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		ctx, cancel := api.queryContext(r)
+		defer cancel()
+		ctx, acc, err := api.queryMemory(ctx)
+		if err != nil {
+			jsonResponse(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		defer acc.Close()
+		if qr, err = query.Subgraph(ctx, qs, lang, qu, api.queryLimit()); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		qr = graph.NewQuadStoreReader(qs)
+	}
 	defer qr.Close()
+	api.writeQuads(w, r, format, qr)
+}
 
+// queryParam returns a query from the "qu" parameter, or from the body of a POST request.
+func queryParam(r *http.Request) (string, error) {
+	qu := r.URL.Query().Get("qu")
+	if qu == "" && r.Method == "POST" {
+		data, err := readLimit(r.Body)
+		if err != nil {
+			return "", err
+		}
+		qu = string(data)
+	}
+	if qu == "" {
+		return "", errors.New("query is empty")
+	}
+	return qu, nil
+}
+
+// writeQuads streams quads from the reader to the client in a given format.
+func (api *APIv2) writeQuads(w http.ResponseWriter, r *http.Request, format *quad.Format, qr quad.Reader) {
 	wr := writerFrom(w, r, hdrAcceptEncoding)
 	defer wr.Close()
 
@@ -321,6 +646,7 @@ func (api *APIv2) ServeRead(w http.ResponseWriter, r *http.Request) {
 	if len(format.Mime) != 0 {
 		w.Header().Set(hdrContentType, format.Mime[0])
 	}
+	var err error
 	if bw, ok := qw.(quad.BatchWriter); ok {
 		_, err = quad.CopyBatch(bw, qr, api.batch)
 	} else {
@@ -332,7 +658,7 @@ func (api *APIv2) ServeRead(w http.ResponseWriter, r *http.Request) {
 	} else if err != nil {
 		// can do nothing here, since first byte (and header) was written
 		// TODO: check if client just gone away
-		clog.Errorf("read quads error: %v", err)
+		logger.Error("read quads error", clog.F("error", err))
 	}
 }
 
@@ -363,11 +689,14 @@ func (api *APIv2) ServeFormats(w http.ResponseWriter, r *http.Request) {
 
 func (api *APIv2) queryContext(r *http.Request) (ctx context.Context, cancel func()) {
 	ctx = context.TODO() // TODO(dennwc): get from request
-	if api.timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, api.timeout)
+	if timeout := api.queryTimeout(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	} else {
 		ctx, cancel = context.WithCancel(ctx)
 	}
+	if api.parallelism > 1 {
+		ctx = iterator.NewParallelContext(ctx, api.parallelism)
+	}
 	return ctx, cancel
 }
 
@@ -379,12 +708,76 @@ func defaultErrorFunc(w query.ResponseWriter, err error) {
 	w.Write([]byte("}\n"))
 }
 
-func writeResults(w io.Writer, r interface{}) {
+// writeResults writes query results to the response. Negative horizon is omitted.
+func writeResults(w io.Writer, r interface{}, horizon int64) {
 	w.Write([]byte(`{"result": `))
 	json.NewEncoder(w).Encode(r)
+	if horizon >= 0 {
+		fmt.Fprintf(w, `, "horizon": %d`, horizon)
+	}
 	w.Write([]byte("}\n"))
 }
 
+const hdrHorizon = "X-Cayley-Horizon"
+
+// asOfParam returns the horizon requested in the "as_of" parameter, or 0 if it's not set.
+// Parameter can be set either to a horizon or to a RFC 3339 timestamp.
+func asOfParam(r *http.Request, qs graph.QuadStore) (int64, error) {
+	v := r.URL.Query().Get("as_of")
+	if v == "" {
+		return 0, nil
+	}
+	if h, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if h <= 0 {
+			return 0, fmt.Errorf("invalid horizon: %d", h)
+		}
+		return h, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return 0, fmt.Errorf("as_of must be a horizon or a timestamp: %q", v)
+	}
+	h, err := graph.HorizonAt(qs, t)
+	if err == graph.ErrNoTimeTravel {
+		err = errNoAsOf
+	}
+	return h, err
+}
+
+var errNoAsOf = errors.New("database does not support as of queries")
+
+func snapshotErrorCode(err error) int {
+	if err == errNoAsOf {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// snapshotOf pins all reads to a consistent state of the quad store, if it (or the quad store it wraps) supports snapshots.
+// If asOf is set, reads will observe the state of the graph at that horizon.
+// It returns the horizon of the snapshot, or -1 if reads are not isolated.
+func snapshotOf(w http.ResponseWriter, qs graph.QuadStore, asOf int64) (graph.QuadStore, int64, func(), error) {
+	if asOf > 0 {
+		snap, err := graph.AsOf(qs, asOf)
+		if err == graph.ErrNoTimeTravel {
+			return nil, -1, nil, errNoAsOf
+		} else if err != nil {
+			return nil, -1, nil, err
+		}
+		w.Header().Set(hdrHorizon, strconv.FormatInt(asOf, 10))
+		return snap, asOf, func() { snap.Close() }, nil
+	}
+	snap, err := graph.SnapshotOf(qs)
+	if err == graph.ErrNoSnapshots {
+		return qs, -1, func() {}, nil
+	} else if err != nil {
+		return nil, -1, nil, err
+	}
+	horizon := snap.Horizon()
+	w.Header().Set(hdrHorizon, strconv.FormatInt(horizon, 10))
+	return snap, horizon, func() { snap.Close() }, nil
+}
+
 const maxQuerySize = 1024 * 1024 // 1 MB
 func readLimit(r io.Reader) ([]byte, error) {
 	lr := io.LimitReader(r, maxQuerySize).(*io.LimitedReader)
@@ -395,6 +788,8 @@ func readLimit(r io.Reader) ([]byte, error) {
 	return data, err
 }
 
+var errQueryCancelled = errors.New("query was cancelled")
+
 func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := api.queryContext(r)
 	defer cancel()
@@ -419,22 +814,102 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	default:
 	}
-	h, err := api.handleForRequest(r)
+	qses, err := api.querySession(r)
 	if err != nil {
-		errFunc(w, err)
+		jsonResponse(w, http.StatusNotFound, err)
 		return
 	}
+	r = withQuerySession(r, qses)
+	vals = r.URL.Query()
+	var (
+		qs      graph.QuadStore
+		horizon int64 = -1
+	)
+	if system := vals.Get("graph") == "system"; system {
+		if api.sys == nil {
+			jsonResponse(w, http.StatusBadRequest, "system graph is not enabled")
+			return
+		}
+		qs = api.sys.QuadStore()
+	} else {
+		h, err := api.handleForRequest(r)
+		if err != nil {
+			errFunc(w, err)
+			return
+		}
+		if err = api.waitSession(ctx, r, h.QuadStore); err != nil {
+			jsonResponse(w, sessionErrorCode(err), err)
+			return
+		}
+		asOf, err := asOfParam(r, h.QuadStore)
+		if err != nil {
+			errFunc(w, err)
+			return
+		}
+		var done func()
+		qs, horizon, done, err = snapshotOf(w, h.QuadStore, asOf)
+		if err != nil {
+			errFunc(w, err)
+			return
+		}
+		defer done()
+		if qs, err = api.restrict(r, qs); err != nil {
+			jsonResponse(w, writeErrorCode(err), err)
+			return
+		}
+		if qs, err = api.evaluateView(ctx, r, qs); err != nil {
+			errFunc(w, err)
+			return
+		}
+		qs = named.Select(qs, vals.Get("graph"), api.defGraph)
+		end := api.beginQuery(w, r)
+		if end == nil {
+			return
+		}
+		defer end()
+	}
+	// queries of the system graph are not recorded, so the statistics only describe the workload
+	var (
+		qu    string
+		rows  int
+		qerr  error
+		start = time.Now()
+	)
+	if st := api.stats; st != nil && vals.Get("graph") != "system" {
+		defer func() {
+			if qu != "" {
+				st.Record(lang, qu, time.Since(start), rows, qerr)
+			}
+		}()
+	}
 	if l.HTTPQuery != nil {
 		defer r.Body.Close()
-		l.HTTPQuery(ctx, h.QuadStore, w, r.Body)
+		data, err := readLimit(r.Body)
+		if err != nil {
+			errFunc(w, err)
+			return
+		}
+		qu = string(data)
+		ctx, acc, err := api.queryMemory(ctx)
+		if err != nil {
+			jsonResponse(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		defer acc.Close()
+		ctx, exec := api.active.Start(ctx, lang, qu)
+		defer exec.Done()
+		l.HTTPQuery(ctx, qs, w, bytes.NewReader(data))
 		return
 	}
 	if l.HTTP == nil {
 		errFunc(w, errors.New("HTTP interface is not supported for this query language"))
 		return
 	}
-	ses := l.HTTP(h.QuadStore)
-	var qu string
+	ses := l.HTTP(qs)
+	if err := setupQuerySession(ses, qses); err != nil {
+		errFunc(w, err)
+		return
+	}
 	if r.Method == "GET" {
 		qu = vals.Get("qu")
 	} else {
@@ -449,27 +924,55 @@ func (api *APIv2) ServeQuery(w http.ResponseWriter, r *http.Request) {
 		jsonResponse(w, http.StatusBadRequest, "query is empty")
 		return
 	}
-	if clog.V(1) {
-		clog.Infof("query: %s: %q", lang, qu)
+	if logger.V(1) {
+		logger.Info("query", clog.F("lang", lang), clog.F("query", qu))
+	}
+	ctx, acc, err := api.queryMemory(ctx)
+	if err != nil {
+		jsonResponse(w, http.StatusServiceUnavailable, err)
+		return
 	}
+	defer acc.Close()
+	ctx, exec := api.active.Start(ctx, lang, qu)
+	defer exec.Done()
 
 	c := make(chan query.Result, 5)
-	go ses.Execute(ctx, qu, c, api.limit)
+	go ses.Execute(ctx, qu, c, api.queryLimit())
+	defer func() {
+		// query must not use the snapshot after it's released
+		cancel()
+		for range c {
+		}
+	}()
 
 	for res := range c {
 		if err := res.Err(); err != nil {
 			if err == nil {
 				continue // wait for results channel to close
 			}
+			qerr = err
 			errFunc(w, err)
 			return
 		}
+		rows++
+		exec.AddRows(1)
 		ses.Collate(res)
 	}
+	// languages may stop without an error when the context is done
+	if err := ctx.Err(); err == context.Canceled {
+		qerr = errQueryCancelled
+		errFunc(w, qerr)
+		return
+	} else if err != nil {
+		qerr = err
+		errFunc(w, err)
+		return
+	}
 	output, err := ses.Results()
 	if err != nil {
+		qerr = err
 		errFunc(w, err)
 		return
 	}
-	writeResults(w, output)
+	writeResults(w, output, horizon)
 }
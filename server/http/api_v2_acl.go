@@ -0,0 +1,88 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"net/http"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/graph/trash"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// SetACL restricts reads and writes of each client to graphs granted to its principal.
+// Clients without a principal are anonymous and only get permissions granted to acl.Anyone.
+func (api *APIv2) SetACL(a *acl.ACL) {
+	api.acl = a
+}
+
+// scope returns graphs accessible by the client, or nil if access is not restricted.
+func (api *APIv2) scope(r *http.Request) *acl.Scope {
+	if api.acl == nil {
+		return nil
+	}
+	return api.acl.Scope(RequestPrincipal(r))
+}
+
+// selectedGraph returns a label of the graph selected by the "graph" parameter, or the default graph.
+// It returns nil if all graphs are selected.
+func (api *APIv2) selectedGraph(r *http.Request) quad.Value {
+	switch name := r.URL.Query().Get("graph"); name {
+	case named.All:
+		return nil
+	case "":
+		return api.defGraph
+	default:
+		return quad.StringToValue(name)
+	}
+}
+
+// QueryStore returns a quad store for reads made by other handlers of the server on behalf of the client.
+// Reads are pinned to a snapshot, restricted to graphs accessible by the client, and limited to the graph
+// selected by the "graph" parameter or the default graph; deleted graphs are hidden.
+//
+// It returns false if an error was already written to the client. Otherwise, done must be called after reads.
+func (api *APIv2) QueryStore(w http.ResponseWriter, r *http.Request) (qs graph.QuadStore, done func(), ok bool) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+	qs, _, done, err = snapshotOf(w, h.QuadStore, 0)
+	if err != nil {
+		jsonResponse(w, snapshotErrorCode(err), err)
+		return nil, nil, false
+	}
+	if qs, err = api.restrict(r, qs); err != nil {
+		done()
+		jsonResponse(w, writeErrorCode(err), err)
+		return nil, nil, false
+	}
+	return named.Select(qs, r.URL.Query().Get("graph"), api.defGraph), done, true
+}
+
+// restrict limits reads of a quad store to graphs accessible by the client and hides deleted graphs.
+// A graph selected by the "graph" parameter, or the default graph, must be readable.
+func (api *APIv2) restrict(r *http.Request, qs graph.QuadStore) (graph.QuadStore, error) {
+	if s := api.scope(r); s != nil {
+		if label := api.selectedGraph(r); label != nil && !s.CanRead(label) {
+			return nil, acl.ErrForbidden
+		}
+		qs = acl.Restrict(qs, s)
+	}
+	return trash.Hide(r.Context(), qs)
+}
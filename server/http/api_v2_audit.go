@@ -0,0 +1,128 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/audit"
+	"github.com/cayleygraph/cayley/graph/trash"
+)
+
+const (
+	hdrRequestID = "X-Request-Id"
+
+	maxRequestIDLen = 128
+
+	// AdminPrincipal is the principal of requests authenticated with the admin token.
+	AdminPrincipal = "admin"
+)
+
+// SetAuditLog enables recording of all changes made through the API to the audit log.
+// The log is not closed by the API.
+func (api *APIv2) SetAuditLog(l *audit.Log) {
+	api.audit = l
+}
+
+// SetAdminToken sets the bearer token of administrators. Writes made with it are recorded
+// to the audit log as made by AdminPrincipal.
+func (api *APIv2) SetAdminToken(tok string) {
+	api.adminToken = tok
+}
+
+// HasBearerToken checks if the request has a given token in the Authorization header ("Bearer <token>").
+func HasBearerToken(r *http.Request, tok string) bool {
+	auth := r.Header.Get("Authorization")
+	if tok == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(tok)) == 1
+}
+
+// requestID returns an id of the request set by the client or a proxy, or generates a new one.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(hdrRequestID); id != "" && len(id) <= maxRequestIDLen {
+		return id
+	}
+	id, err := newLoadToken()
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// RequestPrincipal returns an authenticated identity of the client.
+// Currently, only TLS client certificates are considered.
+func RequestPrincipal(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return r.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+// AuditInfo describes the client that made the request, for recording its changes to the audit log.
+// Clients are authenticated by TLS client certificates, or by the admin token, if it's set.
+// The request id is returned to the client in X-Request-Id header.
+func AuditInfo(w http.ResponseWriter, r *http.Request, adminToken string) audit.Info {
+	info := audit.Info{
+		Auth:      audit.AuthNone,
+		Addr:      r.RemoteAddr,
+		RequestID: requestID(r),
+	}
+	if p := RequestPrincipal(r); p != "" {
+		info.Principal, info.Auth = p, audit.AuthTLS
+	} else if HasBearerToken(r, adminToken) {
+		info.Principal, info.Auth = AdminPrincipal, audit.AuthToken
+	}
+	w.Header().Set(hdrRequestID, info.RequestID)
+	return info
+}
+
+// writeHandle returns a handle that only writes graphs accessible by the client, within quotas,
+// and records all writes made on behalf of the request to the audit log. Quads can't be added to deleted graphs.
+func (api *APIv2) writeHandle(w http.ResponseWriter, r *http.Request, h *graph.Handle) (*graph.Handle, error) {
+	qs := h.QuadStore
+	if api.audit != nil {
+		qs = audit.New(qs, api.audit, AuditInfo(w, r, api.adminToken))
+	}
+	// writes to deleted graphs and out of scope are rejected before they are counted by quotas
+	qs, err := trash.Hide(r.Context(), api.quota.Wrap(qs))
+	if err != nil {
+		return nil, err
+	}
+	if qs = acl.Restrict(qs, api.scope(r)); qs == h.QuadStore {
+		return h, nil
+	}
+	// reads and optional interfaces still go to the original quad store
+	qw, err := graph.NewQuadWriter(api.wtyp, qs, api.wopt)
+	if err != nil {
+		return nil, err
+	}
+	return &graph.Handle{QuadStore: h.QuadStore, QuadWriter: qw}, nil
+}
+
+// writeHandleForRequest is like handleForRequest, but only writes graphs accessible by the client,
+// within quotas, and records all writes to the audit log.
+func (api *APIv2) writeHandleForRequest(w http.ResponseWriter, r *http.Request) (*graph.Handle, error) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return api.writeHandle(w, r, h)
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/text"
+	"github.com/cayleygraph/cayley/graph/trash"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// ServeComplete returns string literals that start with the "prefix" parameter, for typeahead UIs.
+// Results can be limited to objects of the "pred" parameters. The number of results is set by "limit",
+// but is never above the query limit of the server.
+func (api *APIv2) ServeComplete(w http.ResponseWriter, r *http.Request) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	vals := r.URL.Query()
+	limit := text.DefaultCompletions
+	if s := vals.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			jsonResponse(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+	}
+	if max := api.queryLimit(); max > 0 && limit > max {
+		limit = max
+	}
+	var preds []quad.IRI
+	for _, p := range vals["pred"] {
+		// allow both "name" and "<name>"
+		preds = append(preds, quad.IRI(strings.TrimSuffix(strings.TrimPrefix(p, "<"), ">")))
+	}
+	ctx, cancel := api.queryContext(r)
+	defer cancel()
+	// completions only include nodes of graphs accessible by the client
+	qs, err := trash.Hide(ctx, acl.Restrict(h.QuadStore, api.scope(r)))
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	res, err := text.Complete(ctx, qs, vals.Get("prefix"), limit, preds...)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]string, 0, len(res))
+	for _, v := range res {
+		s, _ := text.TextOf(v)
+		out = append(out, s)
+	}
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	writeResults(w, out, -1)
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/internal/clone"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// copyOptions parses the target label and label remapping of a graph copy.
+func copyOptions(r *http.Request) (clone.Options, error) {
+	var opts clone.Options
+	vals := r.URL.Query()
+	if to := vals.Get("to"); to != "" {
+		opts.Label = quad.StringToValue(to)
+	}
+	for _, m := range vals["remap"] {
+		i := strings.LastIndex(m, "=")
+		if i < 0 || i == len(m)-1 {
+			return opts, fmt.Errorf("invalid label remapping: %q", m)
+		}
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]quad.Value)
+		}
+		key := ""
+		if old := m[:i]; old != "" {
+			key = quad.StringToValue(old).String()
+		}
+		opts.Labels[key] = quad.StringToValue(m[i+1:])
+	}
+	if opts.Label == nil && opts.Labels == nil {
+		return opts, errors.New("target graph is not specified")
+	}
+	return opts, nil
+}
+
+// ServeCopy copies quads of the graph selected by the "graph" parameter to another graph of the same database.
+func (api *APIv2) ServeCopy(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if api.readOnly() {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	}
+	opts, err := copyOptions(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h, err := api.writeHandleForRequest(w, r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	qs, horizon, done, err := snapshotOf(w, h.QuadStore, 0)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer done()
+	if qs, err = api.restrict(r, qs); err != nil {
+		jsonResponse(w, writeErrorCode(err), err)
+		return
+	}
+	qs = named.Select(qs, r.URL.Query().Get("graph"), api.defGraph)
+	opts.Batch = api.batch
+	opts.Pinned = horizon >= 0
+	n, err := clone.Copy(r.Context(), h.QuadWriter, qs, opts)
+	if err != nil {
+		jsonResponse(w, writeErrorCode(err), err)
+		return
+	}
+	setSession(w, h.QuadStore)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	fmt.Fprintf(w, `{"result": "Successfully copied %d quads.", "count": %d}`+"\n", n, n)
+}
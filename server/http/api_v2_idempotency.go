@@ -0,0 +1,155 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+const (
+	hdrWriteID       = "X-Cayley-Write-Id"
+	hdrWriteReplayed = "X-Cayley-Write-Replayed"
+
+	// DefaultWriteIDTTL is the time the server remembers ids of applied writes.
+	DefaultWriteIDTTL = 24 * time.Hour
+
+	maxWriteIDLen = 256
+)
+
+// appliedWrite is a state of a write with a client-provided id.
+type appliedWrite struct {
+	done   chan struct{}
+	ok     bool
+	result string
+	at     time.Time
+}
+
+// appliedWrites is the registry of write ids seen by the server.
+//
+// It is kept in memory only: the ids are lost on restart and are not shared with other servers of the same database.
+type appliedWrites struct {
+	sync.Mutex
+	m map[string]*appliedWrite
+}
+
+func (s *appliedWrites) gc(now time.Time, ttl time.Duration) {
+	for id, aw := range s.m {
+		select {
+		case <-aw.done:
+			if now.Sub(aw.at) > ttl {
+				delete(s.m, id)
+			}
+		default:
+			// in progress
+		}
+	}
+}
+
+// begin returns the result of a write that was already applied with a given id.
+// Otherwise, it registers an in-progress write that must be finished by the caller.
+// Concurrent requests with the same id wait for the first one to finish.
+func (s *appliedWrites) begin(id string, ttl time.Duration) (*appliedWrite, bool) {
+	for {
+		now := time.Now()
+		s.Lock()
+		s.gc(now, ttl)
+		aw := s.m[id]
+		if aw == nil {
+			if s.m == nil {
+				s.m = make(map[string]*appliedWrite)
+			}
+			aw = &appliedWrite{done: make(chan struct{}), at: now}
+			s.m[id] = aw
+			s.Unlock()
+			return aw, false
+		}
+		s.Unlock()
+		<-aw.done
+		if aw.ok {
+			return aw, true
+		}
+		// first attempt failed and was removed; try to apply it again
+	}
+}
+
+func (s *appliedWrites) finish(id string, aw *appliedWrite, result string, ok bool) {
+	s.Lock()
+	aw.ok, aw.result, aw.at = ok, result, time.Now()
+	if !ok {
+		delete(s.m, id)
+	}
+	s.Unlock()
+	close(aw.done)
+}
+
+func writeID(r *http.Request) string {
+	if id := r.URL.Query().Get("write_id"); id != "" {
+		return id
+	}
+	return r.Header.Get(hdrWriteID)
+}
+
+func (api *APIv2) writeTTL() time.Duration {
+	if api.writeIDTTL > 0 {
+		return api.writeIDTTL
+	}
+	return DefaultWriteIDTTL
+}
+
+// applyOnce runs a write at most once for each write id set by the client in this process. Retries of a
+// successful write get the response of the first attempt without changing the database.
+//
+// Writes with an id are applied atomically, so a failed attempt can be safely retried.
+// The apply function returns the response body, or an error with a status code.
+func (api *APIv2) applyOnce(w http.ResponseWriter, r *http.Request, qs graph.QuadStore, apply func(atomic bool) (string, int, error)) {
+	id := writeID(r)
+	if id == "" {
+		res, code, err := apply(false)
+		if err != nil {
+			jsonResponse(w, code, err)
+			return
+		}
+		setSession(w, qs)
+		w.Header().Set(hdrContentType, contentTypeJSON)
+		io.WriteString(w, res)
+		return
+	} else if len(id) > maxWriteIDLen {
+		jsonResponse(w, http.StatusBadRequest, "write id is too long")
+		return
+	}
+	w.Header().Set(hdrWriteID, id)
+	aw, dup := api.writes.begin(id, api.writeTTL())
+	if dup {
+		w.Header().Set(hdrWriteReplayed, "true")
+		setSession(w, qs)
+		w.Header().Set(hdrContentType, contentTypeJSON)
+		io.WriteString(w, aw.result)
+		return
+	}
+	res, code, err := apply(true)
+	api.writes.finish(id, aw, res, err == nil)
+	if err != nil {
+		jsonResponse(w, code, err)
+		return
+	}
+	setSession(w, qs)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	io.WriteString(w, res)
+}
@@ -0,0 +1,384 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/decompressor"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	hdrLoadToken = "X-Cayley-Load-Token"
+
+	// loadTokenTTL is the time an idle load token is kept by the server.
+	loadTokenTTL = 24 * time.Hour
+
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+// LoadStatus describes the progress of a single bulk load.
+type LoadStatus struct {
+	Token   string    `json:"token"`
+	Count   int64     `json:"count"`
+	Done    bool      `json:"done,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// loadSession tracks a resumable bulk load. Count is the number of quads
+// from the start of the upload that were already committed to the store.
+type loadSession struct {
+	sync.Mutex
+	busy bool
+	st   LoadStatus
+}
+
+// loadSessions is the registry of active load tokens.
+type loadSessions struct {
+	sync.Mutex
+	m map[string]*loadSession
+}
+
+func newLoadToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func (s *loadSessions) gc(now time.Time) {
+	for tok, ls := range s.m {
+		ls.Lock()
+		expired := !ls.busy && now.Sub(ls.st.Updated) > loadTokenTTL
+		ls.Unlock()
+		if expired {
+			delete(s.m, tok)
+		}
+	}
+}
+
+// acquire returns a session for a given token, or creates a new one if token is empty.
+// Session is marked as busy and must be released by the caller.
+func (s *loadSessions) acquire(token string) (*loadSession, error) {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	s.gc(now)
+	if token == "" {
+		tok, err := newLoadToken()
+		if err != nil {
+			return nil, err
+		}
+		if s.m == nil {
+			s.m = make(map[string]*loadSession)
+		}
+		ls := &loadSession{busy: true, st: LoadStatus{Token: tok, Updated: now}}
+		s.m[tok] = ls
+		return ls, nil
+	}
+	ls := s.m[token]
+	if ls == nil {
+		return nil, fmt.Errorf("unknown or expired load token: %q", token)
+	}
+	ls.Lock()
+	defer ls.Unlock()
+	if ls.busy {
+		return nil, fmt.Errorf("load %q is already in progress", token)
+	} else if ls.st.Done {
+		return nil, fmt.Errorf("load %q is already finished", token)
+	}
+	ls.busy = true
+	ls.st.Error = ""
+	return ls, nil
+}
+
+func (s *loadSessions) get(token string) (LoadStatus, bool) {
+	s.Lock()
+	ls := s.m[token]
+	s.Unlock()
+	if ls == nil {
+		return LoadStatus{}, false
+	}
+	return ls.status(), true
+}
+
+func (ls *loadSession) status() LoadStatus {
+	ls.Lock()
+	defer ls.Unlock()
+	return ls.st
+}
+
+func (ls *loadSession) commit(n int) LoadStatus {
+	ls.Lock()
+	defer ls.Unlock()
+	ls.st.Count += int64(n)
+	ls.st.Updated = time.Now()
+	return ls.st
+}
+
+func (ls *loadSession) release(err error) LoadStatus {
+	ls.Lock()
+	defer ls.Unlock()
+	ls.busy = false
+	ls.st.Updated = time.Now()
+	if err != nil {
+		ls.st.Error = err.Error()
+	} else {
+		ls.st.Done = true
+	}
+	return ls.st
+}
+
+// loadPart is a single quad stream of the load request.
+type loadPart struct {
+	r      io.Reader
+	format *quad.Format
+}
+
+// loadParts iterates over all quad streams of the request; either the body itself,
+// or each part of a multipart body.
+type loadParts struct {
+	def  *quad.Format
+	body io.Reader
+	mr   *multipart.Reader
+}
+
+func newLoadParts(r *http.Request) (*loadParts, error) {
+	lp := &loadParts{}
+	if name := r.URL.Query().Get("format"); name != "" {
+		lp.def = quad.FormatByName(name)
+		if lp.def == nil {
+			return nil, fmt.Errorf("unknown quad format %q", name)
+		}
+	}
+	ct := r.Header.Get(hdrContentType)
+	if mt, params, err := mime.ParseMediaType(ct); err == nil && strings.HasPrefix(mt, "multipart/") {
+		if params["boundary"] == "" {
+			return nil, errors.New("multipart boundary is not set")
+		}
+		lp.mr = multipart.NewReader(r.Body, params["boundary"])
+		return lp, nil
+	}
+	rd, err := readerFrom(r, hdrContentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	lp.body = rd
+	if lp.def == nil && ct != "" {
+		lp.def = quad.FormatByMime(ct)
+	}
+	return lp, nil
+}
+
+var errNoMoreParts = io.EOF
+
+func (lp *loadParts) next() (*loadPart, error) {
+	if lp.mr == nil {
+		if lp.body == nil {
+			return nil, errNoMoreParts
+		}
+		body := lp.body
+		lp.body = nil
+		return lp.open(body, lp.def)
+	}
+	for {
+		p, err := lp.mr.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		format := lp.def
+		if name := p.FileName(); name != "" && format == nil {
+			name = strings.TrimSuffix(name, ".gz")
+			name = strings.TrimSuffix(name, ".bz2")
+			format = quad.FormatByExt(filepath.Ext(name))
+		}
+		if ct := p.Header.Get(hdrContentType); ct != "" && format == nil {
+			format = quad.FormatByMime(ct)
+		}
+		if format == nil && p.FileName() == "" {
+			// regular form field, not a file
+			continue
+		}
+		return lp.open(p, format)
+	}
+}
+
+func (lp *loadParts) open(r io.Reader, format *quad.Format) (*loadPart, error) {
+	if format == nil {
+		format = quad.FormatByName(defaultFormat)
+	}
+	if format.Reader == nil {
+		return nil, fmt.Errorf("format %q is not supported for reading data", format.Name)
+	}
+	// parts may be compressed independently, so check the magic for each of them
+	dr, err := decompressor.New(r)
+	if err == io.EOF {
+		dr = r
+	} else if err != nil {
+		return nil, err
+	}
+	return &loadPart{r: dr, format: format}, nil
+}
+
+type flushWriter interface {
+	io.Writer
+	http.Flusher
+}
+
+// ServeLoad accepts a quad stream (optionally compressed) or a multipart body with
+// multiple quad files and writes quads to the database in batches.
+//
+// Response contains a load token that can be passed in the "token" parameter to resume
+// the load after a failure. Client must send the same data again, and the server will
+// skip all quads that were already committed.
+//
+// If "progress" parameter is set, the handler will stream a line with LoadStatus
+// after each written batch.
+func (api *APIv2) ServeLoad(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if api.readOnly() {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	}
+	parts, err := newLoadParts(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h, err := api.writeHandleForRequest(w, r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	// parameters must be taken from URL only, since the body is a quad stream
+	vals := r.URL.Query()
+	ls, err := api.loads.acquire(vals.Get("token"))
+	if err != nil {
+		jsonResponse(w, http.StatusConflict, err)
+		return
+	}
+	st := ls.status()
+	w.Header().Set(hdrLoadToken, st.Token)
+
+	var progress flushWriter
+	if vals.Get("progress") != "" {
+		if fw, ok := w.(flushWriter); ok {
+			progress = fw
+			w.Header().Set(hdrContentType, contentTypeNDJSON)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	n, err := api.loadParts(h, parts, ls, st.Count, progress)
+	st = ls.release(err)
+	if progress != nil {
+		json.NewEncoder(progress).Encode(st)
+		progress.Flush()
+		return
+	}
+	if err != nil {
+		jsonResponse(w, writeErrorCode(err), err)
+		return
+	}
+	setSession(w, h.QuadStore)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	fmt.Fprintf(w, `{"result": "Successfully loaded %d quads.", "count": %d, "token": %q}`+"\n", n, n, st.Token)
+}
+
+// loadParts writes quads from all parts to the database, skipping first n quads.
+func (api *APIv2) loadParts(h *graph.Handle, parts *loadParts, ls *loadSession, skip int64, progress flushWriter) (int64, error) {
+	batch := api.batch
+	if batch <= 0 {
+		batch = quad.DefaultBatch
+	}
+	buf := make([]quad.Quad, 0, batch)
+	var total int64
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		if err := h.QuadWriter.AddQuadSet(buf); err != nil {
+			return err
+		}
+		total += int64(len(buf))
+		st := ls.commit(len(buf))
+		buf = buf[:0]
+		if progress != nil {
+			json.NewEncoder(progress).Encode(st)
+			progress.Flush()
+		}
+		return nil
+	}
+	for {
+		p, err := parts.next()
+		if err == errNoMoreParts {
+			break
+		} else if err != nil {
+			return total, err
+		}
+		qr := p.format.Reader(p.r)
+		for {
+			q, err := qr.ReadQuad()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				qr.Close()
+				return total, err
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			buf = append(buf, q)
+			if len(buf) >= batch {
+				if err = flush(); err != nil {
+					qr.Close()
+					return total, err
+				}
+			}
+		}
+		qr.Close()
+	}
+	if skip > 0 {
+		return total, fmt.Errorf("stream is shorter than the number of committed quads (%d)", skip)
+	}
+	return total, flush()
+}
+
+// ServeLoadStatus returns the status of a bulk load with a given token.
+func (api *APIv2) ServeLoadStatus(w http.ResponseWriter, r *http.Request) {
+	st, ok := api.loads.get(r.URL.Query().Get("token"))
+	if !ok {
+		jsonResponse(w, http.StatusNotFound, "unknown or expired load token")
+		return
+	}
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,320 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/cayleygraph/cayley/query"
+)
+
+const (
+	hdrQuerySession = "X-Cayley-Query-Session"
+
+	// DefaultQuerySessionTimeout is the time an idle query session is kept by the server.
+	DefaultQuerySessionTimeout = 30 * time.Minute
+)
+
+var (
+	errQuerySessionNotFound = errors.New("unknown or expired query session")
+
+	reVarName = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+)
+
+// QuerySession describes defaults shared by all queries made in a server-side query session.
+type QuerySession struct {
+	ID string `json:"session"`
+	// Graph is used by queries without the graph parameter.
+	Graph string `json:"graph,omitempty"`
+	// Namespaces maps prefixes to IRI namespaces.
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+	// Vars are global variables of queries.
+	Vars    map[string]interface{} `json:"vars,omitempty"`
+	Expires time.Time              `json:"expires"`
+}
+
+// querySessionUpdate changes settings of a query session. Namespaces and variables are merged
+// with existing ones; setting them to an empty string or null removes them.
+type querySessionUpdate struct {
+	Graph      *string                `json:"graph"`
+	Namespaces map[string]string      `json:"namespaces"`
+	Vars       map[string]interface{} `json:"vars"`
+}
+
+func (u *querySessionUpdate) validate() error {
+	for pref := range u.Namespaces {
+		if !reVarName.MatchString(pref) {
+			return fmt.Errorf("invalid namespace prefix: %q", pref)
+		}
+	}
+	for name := range u.Vars {
+		if !reVarName.MatchString(name) {
+			return fmt.Errorf("invalid variable name: %q", name)
+		}
+	}
+	return nil
+}
+
+// querySession is a set of defaults of queries that is kept across requests.
+type querySession struct {
+	id        string
+	principal string
+	graph     string
+	ns        map[string]string
+	vars      map[string]interface{}
+	last      time.Time
+}
+
+func (s *querySession) apply(u *querySessionUpdate) {
+	if u.Graph != nil {
+		s.graph = *u.Graph
+	}
+	for pref, full := range u.Namespaces {
+		if full == "" {
+			delete(s.ns, pref)
+		} else {
+			s.ns[pref] = full
+		}
+	}
+	for name, v := range u.Vars {
+		if v == nil {
+			delete(s.vars, name)
+		} else {
+			s.vars[name] = v
+		}
+	}
+}
+
+func (s *querySession) status(ttl time.Duration) QuerySession {
+	st := QuerySession{ID: s.id, Graph: s.graph, Expires: s.last.Add(ttl)}
+	if len(s.ns) != 0 {
+		st.Namespaces = make(map[string]string, len(s.ns))
+		for k, v := range s.ns {
+			st.Namespaces[k] = v
+		}
+	}
+	if len(s.vars) != 0 {
+		st.Vars = make(map[string]interface{}, len(s.vars))
+		for k, v := range s.vars {
+			st.Vars[k] = v
+		}
+	}
+	return st
+}
+
+// querySessions is the registry of query sessions. Sessions are only accessible by the principal that started them.
+type querySessions struct {
+	sync.Mutex
+	m map[string]*querySession
+}
+
+func (s *querySessions) gc(now time.Time, ttl time.Duration) {
+	for id, qs := range s.m {
+		if now.Sub(qs.last) > ttl {
+			delete(s.m, id)
+		}
+	}
+}
+
+func (s *querySessions) begin(principal string, u *querySessionUpdate, ttl time.Duration) (QuerySession, error) {
+	id, err := newLoadToken()
+	if err != nil {
+		return QuerySession{}, err
+	}
+	now := time.Now()
+	qs := &querySession{
+		id: id, principal: principal, last: now,
+		ns: make(map[string]string), vars: make(map[string]interface{}),
+	}
+	qs.apply(u)
+	s.Lock()
+	defer s.Unlock()
+	s.gc(now, ttl)
+	if s.m == nil {
+		s.m = make(map[string]*querySession)
+	}
+	s.m[id] = qs
+	return qs.status(ttl), nil
+}
+
+// update changes settings of a session, if it's not nil, and returns the current state of the session.
+func (s *querySessions) update(id, principal string, u *querySessionUpdate, ttl time.Duration) (QuerySession, error) {
+	now := time.Now()
+	s.Lock()
+	defer s.Unlock()
+	s.gc(now, ttl)
+	qs := s.m[id]
+	if qs == nil || qs.principal != principal {
+		return QuerySession{}, errQuerySessionNotFound
+	}
+	qs.last = now
+	if u != nil {
+		qs.apply(u)
+	}
+	return qs.status(ttl), nil
+}
+
+func (s *querySessions) end(id, principal string) error {
+	s.Lock()
+	defer s.Unlock()
+	qs := s.m[id]
+	if qs == nil || qs.principal != principal {
+		return errQuerySessionNotFound
+	}
+	delete(s.m, id)
+	return nil
+}
+
+func (api *APIv2) querySessionTTL() time.Duration {
+	if api.querySessionTimeout > 0 {
+		return api.querySessionTimeout
+	}
+	return DefaultQuerySessionTimeout
+}
+
+func querySessionID(r *http.Request) string {
+	if id := r.URL.Query().Get("query_session"); id != "" {
+		return id
+	}
+	return r.Header.Get(hdrQuerySession)
+}
+
+// querySession returns settings of the query session of the request, or nil if the request is not in a session.
+func (api *APIv2) querySession(r *http.Request) (*QuerySession, error) {
+	id := querySessionID(r)
+	if id == "" {
+		return nil, nil
+	}
+	st, err := api.qsessions.update(id, RequestPrincipal(r), nil, api.querySessionTTL())
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// withQuerySession returns a request with the graph parameter set to the default graph of the session,
+// unless the request selects a graph.
+func withQuerySession(r *http.Request, st *QuerySession) *http.Request {
+	vals := r.URL.Query()
+	if st == nil || st.Graph == "" || vals.Get("graph") != "" {
+		return r
+	}
+	vals.Set("graph", st.Graph)
+	r = r.WithContext(r.Context())
+	u := *r.URL
+	u.RawQuery = vals.Encode()
+	r.URL = &u
+	return r
+}
+
+// setupQuerySession defines namespaces and variables of the session in a query session.
+// Languages that don't support them ignore the session, except for its default graph.
+func setupQuerySession(ses query.Session, st *QuerySession) error {
+	env, ok := ses.(query.Environment)
+	if st == nil || !ok {
+		return nil
+	}
+	for pref, full := range st.Namespaces {
+		env.AddNamespace(pref, full)
+	}
+	for name, v := range st.Vars {
+		if err := env.SetVariable(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readQuerySessionUpdate(r *http.Request) (*querySessionUpdate, error) {
+	defer r.Body.Close()
+	data, err := readLimit(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	u := &querySessionUpdate{}
+	if len(data) != 0 {
+		if err = json.Unmarshal(data, u); err != nil {
+			return nil, err
+		}
+	}
+	return u, u.validate()
+}
+
+func writeQuerySession(w http.ResponseWriter, code int, st QuerySession) {
+	w.Header().Set(hdrQuerySession, st.ID)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(st)
+}
+
+// ServeQuerySessionBegin starts a new query session with a default graph, namespaces and variables from the request body.
+// Session id is returned in the response and must be passed in the "query_session" parameter
+// (or X-Cayley-Query-Session header) of queries.
+//
+// Session is removed if no requests were made in it for the idle timeout.
+func (api *APIv2) ServeQuerySessionBegin(w http.ResponseWriter, r *http.Request) {
+	u, err := readQuerySessionUpdate(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	st, err := api.qsessions.begin(RequestPrincipal(r), u, api.querySessionTTL())
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeQuerySession(w, http.StatusCreated, st)
+}
+
+// ServeQuerySession returns settings of a query session.
+func (api *APIv2) ServeQuerySession(w http.ResponseWriter, r *http.Request) {
+	st, err := api.qsessions.update(querySessionID(r), RequestPrincipal(r), nil, api.querySessionTTL())
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, err)
+		return
+	}
+	writeQuerySession(w, http.StatusOK, st)
+}
+
+// ServeQuerySessionUpdate changes settings of a query session. Namespaces and variables are merged with existing ones.
+func (api *APIv2) ServeQuerySessionUpdate(w http.ResponseWriter, r *http.Request) {
+	u, err := readQuerySessionUpdate(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	st, err := api.qsessions.update(querySessionID(r), RequestPrincipal(r), u, api.querySessionTTL())
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, err)
+		return
+	}
+	writeQuerySession(w, http.StatusOK, st)
+}
+
+// ServeQuerySessionEnd removes a query session.
+func (api *APIv2) ServeQuerySessionEnd(w http.ResponseWriter, r *http.Request) {
+	if err := api.qsessions.end(querySessionID(r), RequestPrincipal(r)); err != nil {
+		jsonResponse(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	fmt.Fprintln(w, `{"result": "Query session ended."}`)
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"net/http"
+
+	"github.com/cayleygraph/cayley/graph/quota"
+)
+
+// SetQuota limits the size of graphs, the rate of writes and the number of concurrent queries.
+func (api *APIv2) SetQuota(l *quota.Limits) {
+	api.quota = l
+}
+
+// quotaErrorCode returns 429 Too Many Requests if the limit is not exceeded once other requests complete,
+// and 403 Forbidden otherwise.
+func quotaErrorCode(err error) int {
+	if e, ok := err.(*quota.ExceededError); ok && e.Temporary() {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusForbidden
+}
+
+// beginQuery takes a slot of concurrent queries of the database and of the selected graph, or writes an error.
+func (api *APIv2) beginQuery(w http.ResponseWriter, r *http.Request) func() {
+	done, err := api.quota.BeginQuery(api.selectedGraph(r))
+	if err != nil {
+		jsonResponse(w, quotaErrorCode(err), err)
+		return nil
+	}
+	return done
+}
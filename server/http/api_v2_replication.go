@@ -0,0 +1,132 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/replication"
+)
+
+// maxFeedWait limits the time a replica can wait for new batches in a single request.
+const maxFeedWait = time.Minute
+
+var (
+	errNoFeed            = errors.New("replication feed is not enabled")
+	errReplicationDenied = errors.New("replication requires the admin token or access to all graphs")
+)
+
+// SetReplicationFeed allows replicas to follow changes recorded in the feed.
+//
+// If access to graphs is restricted, only clients with the admin token or with access to all graphs can replicate.
+func (api *APIv2) SetReplicationFeed(f *replication.Feed) {
+	api.feed = f
+}
+
+// checkReplica checks if the client can read all quads and changes from the feed.
+func (api *APIv2) checkReplica(w http.ResponseWriter, r *http.Request) bool {
+	if api.feed == nil {
+		jsonResponse(w, http.StatusNotFound, errNoFeed)
+		return false
+	}
+	if api.adminToken != "" && HasBearerToken(r, api.adminToken) {
+		return true
+	}
+	if s := api.scope(r); s != nil && !s.Unrestricted() {
+		jsonResponse(w, http.StatusForbidden, errReplicationDenied)
+		return false
+	}
+	return true
+}
+
+// ServeReplicationChanges returns batches from the replication feed after a given sequence number.
+// If there are no new batches, the request waits for them up to the time set by the "wait" parameter.
+func (api *APIv2) ServeReplicationChanges(w http.ResponseWriter, r *http.Request) {
+	if !api.checkReplica(w, r) {
+		return
+	}
+	vals := r.URL.Query()
+	if id := vals.Get("feed"); id != api.feed.ID() {
+		jsonResponse(w, http.StatusConflict, replication.ErrUnknownFeed)
+		return
+	}
+	since, err := strconv.ParseUint(vals.Get("since"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("invalid sequence number: %q", vals.Get("since")))
+		return
+	}
+	limit := 0
+	if s := vals.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	var wait time.Duration
+	if s := vals.Get("wait"); s != "" {
+		if wait, err = time.ParseDuration(s); err != nil {
+			jsonResponse(w, http.StatusBadRequest, err)
+			return
+		} else if wait > maxFeedWait {
+			wait = maxFeedWait
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	batches, err := api.feed.Since(ctx, since, limit)
+	if err == replication.ErrFeedGone {
+		jsonResponse(w, http.StatusGone, err)
+		return
+	} else if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if batches == nil {
+		batches = []replication.Batch{}
+	}
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	json.NewEncoder(w).Encode(replication.Changes{
+		Feed: api.feed.ID(), Seq: api.feed.Seq(), Batches: batches,
+	})
+}
+
+// ServeReplicationSnapshot streams all quads to a replica, together with the position in the feed
+// that the replica must continue from.
+//
+// Quads are read after the position is taken, thus they may include changes from later batches.
+// Replicas apply those batches again with duplicate and missing quads ignored.
+func (api *APIv2) ServeReplicationSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !api.checkReplica(w, r) {
+		return
+	}
+	format := getFormat(r, "format", hdrAccept)
+	if format == nil || format.Writer == nil {
+		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("format is not supported for reading data"))
+		return
+	}
+	w.Header().Set(replication.HeaderFeedID, api.feed.ID())
+	w.Header().Set(replication.HeaderFeedOldest, strconv.FormatUint(api.feed.Oldest(), 10))
+	w.Header().Set(replication.HeaderFeedSeq, strconv.FormatUint(api.feed.Seq(), 10))
+	qr := graph.NewQuadStoreReader(api.feed)
+	defer qr.Close()
+	api.writeQuads(w, r, format, qr)
+}
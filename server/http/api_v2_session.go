@@ -0,0 +1,107 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+)
+
+const (
+	hdrSession = "X-Cayley-Session"
+
+	// DefaultSessionWait is the maximal time a read waits for the database to reach the horizon of a session token.
+	DefaultSessionWait = 5 * time.Second
+)
+
+var (
+	errSessionTimeout = errors.New("database has not reached the horizon of the session")
+	errNoSessions     = errors.New("database does not support session tokens")
+)
+
+func sessionErrorCode(err error) int {
+	if err == errSessionTimeout {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// setSession returns a session token with a current horizon of the database to the client.
+// Reads that present this token will observe all writes made before it was issued.
+func setSession(w http.ResponseWriter, qs graph.QuadStore) {
+	if h, ok := graph.HorizonOf(qs); ok {
+		w.Header().Set(hdrSession, strconv.FormatInt(h, 10))
+	}
+}
+
+// sessionToken returns the horizon from a session token of the request, or 0 if it's not set.
+func sessionToken(r *http.Request) (int64, error) {
+	tok := r.URL.Query().Get("session")
+	if tok == "" {
+		tok = r.Header.Get(hdrSession)
+	}
+	if tok == "" {
+		return 0, nil
+	}
+	h, err := strconv.ParseInt(tok, 10, 64)
+	if err != nil || h < 0 {
+		return 0, fmt.Errorf("invalid session token: %q", tok)
+	}
+	return h, nil
+}
+
+func (api *APIv2) sessionWait() time.Duration {
+	if api.sessionMaxWait > 0 {
+		return api.sessionMaxWait
+	}
+	return DefaultSessionWait
+}
+
+// waitSession blocks until the database reaches the horizon of the session token.
+// It fails if the database doesn't report its horizon, since the session cannot be honored.
+func (api *APIv2) waitSession(ctx context.Context, r *http.Request, qs graph.QuadStore) error {
+	want, err := sessionToken(r)
+	if err != nil || want == 0 {
+		return err
+	}
+	h, ok := graph.HorizonOf(qs)
+	if !ok {
+		return errNoSessions
+	}
+	ctx, cancel := context.WithTimeout(ctx, api.sessionWait())
+	defer cancel()
+	const maxBackoff = 100 * time.Millisecond
+	backoff := time.Millisecond
+	for h < want {
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errSessionTimeout
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		h, _ = graph.HorizonOf(qs)
+	}
+	return nil
+}
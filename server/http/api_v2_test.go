@@ -1,15 +1,45 @@
 package cayleyhttp
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cayleygraph/cayley/client"
 	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/audit"
 	"github.com/cayleygraph/cayley/graph/graphtest"
+	"github.com/cayleygraph/cayley/graph/kv"
+	"github.com/cayleygraph/cayley/graph/kv/btree"
 	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/quota"
+	"github.com/cayleygraph/cayley/graph/replication"
+	"github.com/cayleygraph/cayley/graph/sameas"
+	"github.com/cayleygraph/cayley/internal/system"
+	"github.com/cayleygraph/cayley/internal/view"
 	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	"github.com/cayleygraph/cayley/query"
+	_ "github.com/cayleygraph/cayley/query/gizmo"
 	"github.com/cayleygraph/cayley/writer"
 	"github.com/stretchr/testify/require"
 )
@@ -64,3 +94,1032 @@ func TestV2Read(t *testing.T) {
 	sort.Sort(quad.ByQuadString(expect))
 	require.Equal(t, expect, quads)
 }
+
+func TestV2ReadQuery(t *testing.T) {
+	addr, closer := makeServerV2(t, graphtest.MakeQuadSet()...)
+	defer closer()
+
+	body := bytes.NewBufferString(`g.V("C").Out("follows").All()`)
+	resp, err := http.Post(addr+"/api/v2/read?lang=gizmo&format=nquads", "application/javascript", body)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	quads, err := quad.ReadAll(nquads.NewReader(resp.Body, false))
+	require.NoError(t, err)
+	sort.Sort(quad.ByQuadString(quads))
+	expect := []quad.Quad{
+		quad.Make("B", "follows", "F", nil),
+		quad.Make("B", "status", "cool", "status_graph"),
+		quad.Make("D", "follows", "B", nil),
+		quad.Make("D", "follows", "G", nil),
+		quad.Make("D", "status", "cool", "status_graph"),
+	}
+	sort.Sort(quad.ByQuadString(expect))
+	require.Equal(t, expect, quads)
+
+	resp, err = http.Get(addr + "/api/v2/read?lang=gizmo")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestV2Load(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	quads := graphtest.MakeQuadSet()
+	encode := func(quads []quad.Quad) []byte {
+		buf := bytes.NewBuffer(nil)
+		zw := gzip.NewWriter(buf)
+		qw := nquads.NewWriter(zw)
+		_, err := quad.Copy(qw, quad.NewReader(quads))
+		require.NoError(t, err)
+		require.NoError(t, qw.Close())
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+	type loadResult struct {
+		Count int64  `json:"count"`
+		Token string `json:"token"`
+	}
+	load := func(url, ct string, body []byte) loadResult {
+		resp, err := http.Post(url, ct, bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out loadResult
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		require.Equal(t, out.Token, resp.Header.Get(hdrLoadToken))
+		return out
+	}
+
+	// load the first half in a separate upload
+	half := len(quads) / 2
+	res := load(srv.URL+"/api/v2/load?format=nquads", "", encode(quads[:half]))
+	require.Equal(t, int64(half), res.Count)
+	require.NotEmpty(t, res.Token)
+
+	// status of finished load must be available
+	resp, err := http.Get(srv.URL + "/api/v2/load/status?token=" + res.Token)
+	require.NoError(t, err)
+	var st LoadStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	resp.Body.Close()
+	require.True(t, st.Done)
+	require.Equal(t, int64(half), st.Count)
+	require.Len(t, graphtest.IteratedQuads(t, h, h.QuadsAllIterator()), half)
+
+	// multipart upload with one file per part
+	body := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(body)
+	for _, part := range [][]quad.Quad{quads[:half], quads[half:]} {
+		fw, err := mw.CreateFormFile("file", "data.nq.gz")
+		require.NoError(t, err)
+		_, err = fw.Write(encode(part))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	res = load(srv.URL+"/api/v2/load", mw.FormDataContentType(), body.Bytes())
+	require.Equal(t, int64(len(quads)), res.Count)
+	require.Len(t, graphtest.IteratedQuads(t, h, h.QuadsAllIterator()), len(quads))
+}
+
+func TestV2LoadResume(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	api := NewAPIv2(h)
+	api.SetBatchSize(4)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	quads := graphtest.MakeQuadSet()
+	buf := bytes.NewBuffer(nil)
+	qw := nquads.NewWriter(buf)
+	_, err := quad.Copy(qw, quad.NewReader(quads))
+	require.NoError(t, err)
+	require.NoError(t, qw.Close())
+	data := buf.Bytes()
+	half := bytes.Index(data[len(data)/2:], []byte("\n")) + len(data)/2 + 1
+
+	// the connection is lost in the middle of the body
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(conn, "POST /api/v2/load?format=nquads HTTP/1.1\r\nHost: cayley\r\nContent-Length: %d\r\n\r\n", len(data))
+	require.NoError(t, err)
+	_, err = conn.Write(data[:half])
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// the response was lost as well, so take the token from the server
+	var (
+		token string
+		st    LoadStatus
+	)
+	for i := 0; i < 200 && st.Error == ""; i++ {
+		time.Sleep(5 * time.Millisecond)
+		api.loads.Lock()
+		for tok := range api.loads.m {
+			token = tok
+		}
+		api.loads.Unlock()
+		st, _ = api.loads.get(token)
+	}
+
+	// a broken load is not finished, and keeps the number of committed quads
+	resp, err := http.Get(srv.URL + "/api/v2/load/status?token=" + token)
+	require.NoError(t, err)
+	st = LoadStatus{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	resp.Body.Close()
+	require.NotEmpty(t, st.Error)
+	require.False(t, st.Done)
+	require.True(t, st.Count > 0 && st.Count < int64(len(quads)), "committed: %d", st.Count)
+	require.Equal(t, int64(0), st.Count%4)
+	require.Len(t, graphtest.IteratedQuads(t, h, h.QuadsAllIterator()), int(st.Count))
+
+	// the same data is sent again with the token, and committed quads are skipped
+	resp, err = http.Post(srv.URL+"/api/v2/load?format=nquads&token="+token, "", bytes.NewReader(data))
+	require.NoError(t, err)
+	var res struct {
+		Count int64 `json:"count"`
+	}
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	resp.Body.Close()
+	require.Equal(t, int64(len(quads))-st.Count, res.Count)
+	graphtest.ExpectIteratedQuads(t, h, h.QuadsAllIterator(), quads, true)
+
+	// finished load can not be resumed again
+	resp, err = http.Post(srv.URL+"/api/v2/load?format=nquads&token="+token, "", bytes.NewReader(data))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestV2Tx(t *testing.T) {
+	quads := graphtest.MakeQuadSet()
+	h := makeHandle(t, quads[0])
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	encode := func(quads ...quad.Quad) *bytes.Buffer {
+		buf := bytes.NewBuffer(nil)
+		qw := nquads.NewWriter(buf)
+		_, err := quad.Copy(qw, quad.NewReader(quads))
+		require.NoError(t, err)
+		require.NoError(t, qw.Close())
+		return buf
+	}
+	post := func(path string, body *bytes.Buffer, code int) *http.Response {
+		if body == nil {
+			body = bytes.NewBuffer(nil)
+		}
+		resp, err := http.Post(srv.URL+path, "application/n-quads", body)
+		require.NoError(t, err)
+		require.Equal(t, code, resp.StatusCode)
+		return resp
+	}
+	begin := func() string {
+		resp := post("/api/v2/tx/begin", nil, http.StatusCreated)
+		defer resp.Body.Close()
+		var st TxStatus
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+		require.NotEmpty(t, st.ID)
+		return st.ID
+	}
+	read := func(id string) []quad.Quad {
+		resp, err := http.Get(srv.URL + "/api/v2/tx/read?tx=" + id)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		out, err := quad.ReadAll(nquads.NewReader(resp.Body, false))
+		require.NoError(t, err)
+		return out
+	}
+
+	id := begin()
+	post("/api/v2/tx/write?tx="+id, encode(quads[1:4]...), http.StatusOK).Body.Close()
+	post("/api/v2/tx/delete?tx="+id, encode(quads[0], quads[3]), http.StatusOK).Body.Close()
+	// staged changes are visible in the transaction, but not in the database
+	require.Equal(t, quads[1:3], read(id))
+	require.Len(t, graphtest.IteratedQuads(t, h, h.QuadsAllIterator()), 1)
+
+	post("/api/v2/tx/commit?tx="+id, nil, http.StatusOK).Body.Close()
+	got := graphtest.IteratedQuads(t, h, h.QuadsAllIterator())
+	sort.Sort(quad.ByQuadString(got))
+	expect := append([]quad.Quad{}, quads[1:3]...)
+	sort.Sort(quad.ByQuadString(expect))
+	require.Equal(t, expect, got)
+	// transaction cannot be used after commit
+	post("/api/v2/tx/commit?tx="+id, nil, http.StatusNotFound).Body.Close()
+
+	id = begin()
+	post("/api/v2/tx/write?tx="+id, encode(quads[5]), http.StatusOK).Body.Close()
+	post("/api/v2/tx/rollback?tx="+id, nil, http.StatusOK).Body.Close()
+	post("/api/v2/tx/write?tx="+id, encode(quads[5]), http.StatusNotFound).Body.Close()
+	require.Len(t, graphtest.IteratedQuads(t, h, h.QuadsAllIterator()), 2)
+}
+
+func TestV2TxTimeout(t *testing.T) {
+	api := NewAPIv2(makeHandle(t))
+	api.SetTxTimeout(time.Millisecond)
+	ts, err := api.txs.begin(api.h, api.txTTL())
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = api.txs.get(ts.id, api.txTTL())
+	require.Equal(t, errTxNotFound, err)
+}
+
+func TestV2TxLockOrder(t *testing.T) {
+	api := NewAPIv2(makeHandle(t))
+	api.SetTxTimeout(time.Minute)
+	busy, err := api.txs.begin(api.h, api.txTTL())
+	require.NoError(t, err)
+	idle, err := api.txs.begin(api.h, api.txTTL())
+	require.NoError(t, err)
+	// a slow request holds the first transaction
+	ts, err := api.txs.get(busy.id, api.txTTL())
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		ts, err := api.txs.get(idle.id, api.txTTL())
+		if err == nil {
+			api.txs.finish(ts)
+			_, err = api.txs.begin(api.h, api.txTTL())
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("other transactions are blocked by a busy one")
+	}
+	ts.Unlock()
+
+	// expired transactions are marked as done after the registry is unlocked
+	api.SetTxTimeout(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, err = api.txs.begin(api.h, api.txTTL())
+	require.NoError(t, err)
+	require.True(t, busy.done)
+}
+
+func TestV2WritePreconditions(t *testing.T) {
+	quads := graphtest.MakeQuadSet()
+	h := makeHandle(t, quads[0])
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	write := func(params url.Values, code int) {
+		body := bytes.NewBuffer(nil)
+		qw := nquads.NewWriter(body)
+		require.NoError(t, qw.WriteQuad(quads[1]))
+		require.NoError(t, qw.Close())
+		resp, err := http.Post(srv.URL+"/api/v2/write?"+params.Encode(), "application/n-quads", body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+	}
+	write(url.Values{"if_not_exists": {quads[0].NQuad()}}, http.StatusPreconditionFailed)
+	write(url.Values{"if_exists": {quads[2].NQuad()}}, http.StatusPreconditionFailed)
+	write(url.Values{"if_exists": {"<a> <b>"}}, http.StatusBadRequest)
+	require.Len(t, graphtest.IteratedQuads(t, h, h.QuadsAllIterator()), 1)
+
+	write(url.Values{"if_exists": {quads[0].NQuad()}, "if_not_exists": {quads[1].NQuad()}}, http.StatusOK)
+	require.Len(t, graphtest.IteratedQuads(t, h, h.QuadsAllIterator()), 2)
+}
+
+func TestV2WriteID(t *testing.T) {
+	quads := graphtest.MakeQuadSet()
+	h := makeHandle(t)
+	defer h.Close()
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	send := func(op string, id string, exp int) string {
+		body := bytes.NewBuffer(nil)
+		qw := nquads.NewWriter(body)
+		require.NoError(t, qw.WriteQuad(quads[0]))
+		require.NoError(t, qw.Close())
+		req, err := http.NewRequest("POST", srv.URL+"/api/v2/"+op, body)
+		require.NoError(t, err)
+		req.Header.Set(hdrContentType, "application/n-quads")
+		if id != "" {
+			req.Header.Set(hdrWriteID, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, exp, resp.StatusCode)
+		return resp.Header.Get(hdrWriteReplayed)
+	}
+	count := func() int {
+		return len(graphtest.IteratedQuads(t, h, h.QuadsAllIterator()))
+	}
+	require.Equal(t, "", send("write", "w1", http.StatusOK))
+	require.Equal(t, 1, count())
+	require.Equal(t, "", send("delete", "", http.StatusOK))
+	require.Equal(t, 0, count())
+
+	// retry is not applied again
+	require.Equal(t, "true", send("write", "w1", http.StatusOK))
+	require.Equal(t, 0, count())
+
+	require.Equal(t, "", send("write", "w2", http.StatusOK))
+	require.Equal(t, "", send("delete", "d1", http.StatusOK))
+	require.Equal(t, "true", send("delete", "d1", http.StatusOK))
+	require.Equal(t, 0, count())
+
+	// failed writes can be retried with the same id
+	require.Equal(t, "", send("delete", "d2", http.StatusInternalServerError))
+	require.Equal(t, "", send("write", "w3", http.StatusOK))
+	require.Equal(t, "", send("delete", "d2", http.StatusOK))
+	require.Equal(t, 0, count())
+}
+
+func TestV2QuerySnapshot(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: wr}
+	defer h.Close()
+	require.NoError(t, wr.AddQuadSet(graphtest.MakeQuadSet()))
+
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/v2/query?lang=gizmo", "", bytes.NewBufferString(`g.V("A").Out("follows").All()`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var out struct {
+		Result  []map[string]string `json:"result"`
+		Horizon int64               `json:"horizon"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.Len(t, out.Result, 1)
+	horizon := qs.(graph.Snapshot).Horizon()
+	require.Equal(t, horizon, out.Horizon)
+	require.Equal(t, strconv.FormatInt(horizon, 10), resp.Header.Get(hdrHorizon))
+}
+
+func TestV2QueryAsOf(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, graph.Options{kv.OptHistory: true})
+	require.NoError(t, err)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: wr}
+	defer h.Close()
+	quads := graphtest.MakeQuadSet()
+	require.NoError(t, wr.AddQuadSet(quads))
+	horizon := qs.(graph.Snapshot).Horizon()
+	require.NoError(t, wr.RemoveQuad(quad.Make("A", "follows", "B", nil)))
+
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	query := func(params string) (int, int, string) {
+		resp, err := http.Post(srv.URL+"/api/v2/query?lang=gizmo"+params, "", bytes.NewBufferString(`g.V("A").Out("follows").All()`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out struct {
+			Result []map[string]string `json:"result"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		}
+		return resp.StatusCode, len(out.Result), resp.Header.Get(hdrHorizon)
+	}
+	code, n, _ := query("")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 0, n)
+
+	code, n, hdr := query("&as_of=" + strconv.FormatInt(horizon, 10))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 1, n)
+	require.Equal(t, strconv.FormatInt(horizon, 10), hdr)
+
+	code, _, _ = query("&as_of=" + url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339)))
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, _, _ = query("&as_of=yesterday")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestV2QuerySystemGraph(t *testing.T) {
+	h := makeHandle(t, graphtest.MakeQuadSet()...)
+	defer h.Close()
+	api2 := NewAPIv2(h)
+	srv := httptest.NewServer(api2)
+	defer srv.Close()
+
+	query := func(params, qu string) (int, []map[string]interface{}) {
+		resp, err := http.Post(srv.URL+"/api/v2/query?lang=gizmo"+params, "", bytes.NewBufferString(qu))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out struct {
+			Result []map[string]interface{} `json:"result"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		}
+		return resp.StatusCode, out.Result
+	}
+	const count = `g.V().Has("<rdf:type>", "<cayley:QueryClass>").Out("<cayley:count>").All()`
+	code, _ := query("&graph=system", count)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	st := system.NewQueryStats(0)
+	api2.SetQueryStats(st)
+	api2.SetSystemGraph(system.New(st))
+	for _, name := range []string{"A", "C"} {
+		code, _ = query("", `g.V("`+name+`").Out("follows").All()`)
+		require.Equal(t, http.StatusOK, code)
+	}
+	code, res := query("&graph=system", count)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []map[string]interface{}{{"id": 2.0}}, res)
+
+	// queries of the system graph are not recorded
+	code, res = query("&graph=system", `g.V().Has("<rdf:type>", "<cayley:QueryClass>").All()`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, res, 1)
+}
+
+func TestV2QueryNamedGraph(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g2"),
+		quad.MakeIRI("a", "knows", "d", ""),
+	)
+	defer h.Close()
+	api2 := NewAPIv2(h)
+	srv := httptest.NewServer(api2)
+	defer srv.Close()
+
+	query := func(params, qu string) []interface{} {
+		resp, err := http.Post(srv.URL+"/api/v2/query?lang=gizmo"+params, "", bytes.NewBufferString(qu))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out struct {
+			Result []map[string]interface{} `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		var ids []interface{}
+		for _, r := range out.Result {
+			ids = append(ids, r["id"])
+		}
+		return ids
+	}
+	const (
+		out  = `g.V("<a>").Out("<knows>").All()`
+		out2 = `g.V("<a>").Out("<knows>").Out("<knows>").All()`
+	)
+	require.Equal(t, []interface{}{"<c>"}, query("", out2))
+	require.Equal(t, []interface{}{"<b>"}, query("&graph=<g1>", out))
+	// the path crosses from g1 to g2, thus it's empty in both graphs
+	require.Empty(t, query("&graph=<g1>", out2))
+	require.Empty(t, query("&graph=<g2>", out2))
+
+	api2.SetDefaultGraph(quad.IRI("g1"))
+	require.Equal(t, []interface{}{"<b>"}, query("", out))
+	require.Equal(t, []interface{}{"<c>"}, query("&graph=*", out2))
+}
+
+func TestV2ACL(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("a", "knows", "c", "g2"),
+		quad.MakeIRI("a", "knows", "d", ""),
+	)
+	defer h.Close()
+	api2 := NewAPIv2(h)
+	api2.SetACL(&acl.ACL{
+		Roles: []acl.Role{{Name: "writers", Principals: []string{"bob"}}},
+		Rules: []acl.Rule{
+			{Principals: []string{"alice", "bob"}, Graphs: []string{"<g1>"}, Read: true},
+			{Roles: []string{"writers"}, Graphs: []string{"<g2>"}, Read: true, Write: true},
+		},
+	})
+
+	do := func(principal, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if principal != "" {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: principal}}}}
+		}
+		if method == "POST" && strings.HasPrefix(path, "/api/v2/write") {
+			req.Header.Set(hdrContentType, "application/n-quads")
+		}
+		rec := httptest.NewRecorder()
+		api2.ServeHTTP(rec, req)
+		return rec
+	}
+	query := func(principal, params string) []interface{} {
+		rec := do(principal, "POST", "/api/v2/query?lang=gizmo"+params, `g.V("<a>").Out("<knows>").All()`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out struct {
+			Result []map[string]interface{} `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&out))
+		var ids []interface{}
+		for _, r := range out.Result {
+			ids = append(ids, r["id"])
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i].(string) < ids[j].(string) })
+		return ids
+	}
+	require.Equal(t, []interface{}{"<b>"}, query("alice", ""))
+	require.Equal(t, []interface{}{"<b>", "<c>"}, query("bob", "&graph=*"))
+	require.Empty(t, query("", ""))
+	rec := do("alice", "POST", "/api/v2/query?lang=gizmo&graph=<g2>", `g.V().All()`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = do("alice", "POST", "/api/v2/write", "<a> <knows> <e> <g1> .\n")
+	require.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	rec = do("bob", "POST", "/api/v2/write", "<a> <knows> <e> <g1> .\n")
+	require.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	rec = do("bob", "POST", "/api/v2/write", "<a> <knows> <e> <g2> .\n")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, []interface{}{"<c>", "<e>"}, query("bob", "&graph=<g2>"))
+}
+
+func TestV2Quota(t *testing.T) {
+	h := makeHandle(t)
+	defer h.Close()
+	lim, err := quota.New(quota.Config{
+		Quota: quota.Quota{MaxQueries: 1},
+		Graphs: []quota.GraphQuota{
+			{Graph: "<g1>", Quota: quota.Quota{MaxQuads: 1}},
+			{Graph: "<g2>", Quota: quota.Quota{MaxWriteQPS: 0.001}},
+		},
+	})
+	require.NoError(t, err)
+	api2 := NewAPIv2(h)
+	api2.SetQuota(lim)
+
+	do := func(path, ctype, body string) int {
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set(hdrContentType, ctype)
+		rec := httptest.NewRecorder()
+		api2.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	write := func(nq string) int {
+		return do("/api/v2/write", "application/n-quads", nq+"\n")
+	}
+	require.Equal(t, http.StatusOK, write("<a> <knows> <b> <g1> ."))
+	require.Equal(t, http.StatusForbidden, write("<a> <knows> <c> <g1> ."))
+	require.Equal(t, http.StatusOK, write("<a> <knows> <c> <g2> ."))
+	require.Equal(t, http.StatusTooManyRequests, write("<a> <knows> <d> <g2> ."))
+
+	const qu = "/api/v2/query?lang=gizmo"
+	require.Equal(t, http.StatusOK, do(qu, "", `g.V().All()`))
+	done, err := lim.BeginQuery(nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, do(qu, "", `g.V().All()`))
+	done()
+	require.Equal(t, http.StatusOK, do(qu, "", `g.V().All()`))
+}
+
+func TestV2QueryView(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("a", "type", "person", ""),
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", ""),
+	)
+	defer h.Close()
+	vs, err := view.New(h, []view.Definition{{Name: "<people>", Lang: "gizmo", Query: `g.V().Has("<type>", "<person>").All()`}})
+	require.NoError(t, err)
+	api2 := NewAPIv2(h)
+	api2.SetViews(vs)
+
+	query := func(params string) []interface{} {
+		req := httptest.NewRequest("POST", "/api/v2/query?lang=gizmo"+params, bytes.NewBufferString(`g.V().Out("<knows>").All()`))
+		rec := httptest.NewRecorder()
+		api2.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out struct {
+			Result []map[string]interface{} `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&out))
+		var ids []interface{}
+		for _, r := range out.Result {
+			ids = append(ids, r["id"])
+		}
+		return ids
+	}
+	require.Len(t, query(""), 2)
+	// only quads of <a> are in the view
+	require.Equal(t, []interface{}{"<b>"}, query("&graph=<people>"))
+	require.NoError(t, h.QuadWriter.AddQuad(quad.MakeIRI("b", "type", "person", "")))
+	require.Len(t, query("&graph=<people>"), 2)
+}
+
+func TestV2Copy(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g1"),
+		quad.MakeIRI("c", "knows", "d", ""),
+	)
+	defer h.Close()
+	api2 := NewAPIv2(h)
+
+	cp := func(params string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v2/graph/copy?"+params, nil)
+		rec := httptest.NewRecorder()
+		api2.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := cp("graph=<g1>&to=<g2>")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"count": 2`)
+	rec = cp("graph=<g2>&remap=<g2>=<g3>&remap=<g1>=<g4>")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"count": 2`)
+	rec = cp("graph=<g1>")
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	var got []string
+	it := h.QuadsAllIterator()
+	defer it.Close()
+	for it.Next(context.TODO()) {
+		got = append(got, h.Quad(it.Result()).NQuad())
+	}
+	require.NoError(t, it.Err())
+	sort.Strings(got)
+	require.Equal(t, []string{
+		"<a> <knows> <b> <g1> .",
+		"<a> <knows> <b> <g2> .",
+		"<a> <knows> <b> <g3> .",
+		"<b> <knows> <c> <g1> .",
+		"<b> <knows> <c> <g2> .",
+		"<b> <knows> <c> <g3> .",
+		"<c> <knows> <d> .",
+	}, got)
+}
+
+func TestV2Trash(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("a", "knows", "b", "g1"),
+		quad.MakeIRI("b", "knows", "c", "g2"),
+	)
+	defer h.Close()
+	api2 := NewAPIv2(h)
+	api2.SetTrashRetention(time.Hour)
+
+	do := func(method, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+		api2.ServeHTTP(rec, req)
+		return rec
+	}
+	count := func() int {
+		rec := do("POST", "/api/v2/query?lang=gizmo&graph=*", `g.V().Out("<knows>").All()`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out struct {
+			Result []interface{} `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&out))
+		return len(out.Result)
+	}
+	require.Equal(t, 2, count())
+	rec := do("POST", "/api/v2/graph/delete?graph=<g1>", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, 1, count())
+	rec = do("POST", "/api/v2/graph/delete?graph=<g1>", "")
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	rec = do("POST", "/api/v2/graph/restore?graph=<g3>", "")
+	require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+
+	// deleted graphs can't be written to
+	rec = do("POST", "/api/v2/write", "<x> <knows> <y> <g1> .\n")
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	rec = do("GET", "/api/v2/graph/trash", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list struct {
+		Result []trashEntry `json:"result"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	require.Len(t, list.Result, 1)
+	require.Equal(t, "<g1>", list.Result[0].Graph)
+	require.NotNil(t, list.Result[0].PurgeAt)
+	require.Equal(t, time.Hour, list.Result[0].PurgeAt.Sub(list.Result[0].DeletedAt))
+
+	rec = do("POST", "/api/v2/graph/restore?graph=<g1>", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, 2, count())
+
+	rec = do("POST", "/api/v2/graph/delete?graph=<g2>", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do("POST", "/api/v2/graph/purge?graph=<g2>", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"count": 2`)
+	require.Equal(t, 1, count())
+	rec = do("POST", "/api/v2/write", "<b> <knows> <c> <g2> .\n")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, 2, count())
+}
+
+func TestV2QueryCancel(t *testing.T) {
+	h := makeHandle(t, graphtest.MakeQuadSet()...)
+	defer h.Close()
+	active := query.NewActive()
+	api2 := NewAPIv2(h)
+	api2.SetActiveQueries(active)
+	srv := httptest.NewServer(api2)
+	defer srv.Close()
+
+	codes := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(srv.URL+"/api/v2/query?lang=gizmo", "", bytes.NewBufferString(`while (true) {}`))
+		if err != nil {
+			codes <- 0
+			return
+		}
+		resp.Body.Close()
+		codes <- resp.StatusCode
+	}()
+	var list []query.ExecutionInfo
+	for i := 0; i < 100 && len(list) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		list = active.List()
+	}
+	require.Len(t, list, 1)
+	require.Equal(t, "gizmo", list[0].Lang)
+	require.True(t, active.Cancel(list[0].ID))
+	select {
+	case code := <-codes:
+		require.Equal(t, http.StatusBadRequest, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("query was not cancelled")
+	}
+	require.Empty(t, active.List())
+}
+
+func TestV2Session(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	qs, err := kv.New(db, nil)
+	require.NoError(t, err)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: wr}
+	defer h.Close()
+
+	api := NewAPIv2(h)
+	api.SetSessionWait(50 * time.Millisecond)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	body := bytes.NewBuffer(nil)
+	qw := nquads.NewWriter(body)
+	require.NoError(t, qw.WriteQuad(quad.Make("A", "follows", "B", nil)))
+	require.NoError(t, qw.Close())
+	resp, err := http.Post(srv.URL+"/api/v2/write", "application/n-quads", body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	tok := resp.Header.Get(hdrSession)
+	require.Equal(t, strconv.FormatInt(qs.(graph.Snapshot).Horizon(), 10), tok)
+
+	query := func(tok string) int {
+		req, err := http.NewRequest("POST", srv.URL+"/api/v2/query?lang=gizmo", bytes.NewBufferString(`g.V("A").Out("follows").All()`))
+		require.NoError(t, err)
+		req.Header.Set(hdrSession, tok)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, query(tok))
+	require.Equal(t, http.StatusServiceUnavailable, query("1000000"))
+	require.Equal(t, http.StatusBadRequest, query("abc"))
+
+	resp, err = http.Get(srv.URL + "/api/v2/read?session=" + tok)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestV2SessionWrapped(t *testing.T) {
+	db := btree.New()
+	require.NoError(t, kv.Init(db, nil))
+	base, err := kv.New(db, graph.Options{kv.OptHistory: true})
+	require.NoError(t, err)
+	feed, err := replication.NewFeed(base, 0)
+	require.NoError(t, err)
+	qs, err := sameas.NewQuadStore(context.TODO(), feed, sameas.ModeExpand)
+	require.NoError(t, err)
+	wr, err := writer.NewSingleReplication(qs, nil)
+	require.NoError(t, err)
+	h := &graph.Handle{QuadStore: qs, QuadWriter: wr}
+	defer h.Close()
+
+	srv := httptest.NewServer(NewAPIv2(h))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/v2/write", "application/n-quads", bytes.NewBufferString(quad.Make("A", "follows", "B", nil).NQuad()+"\n"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	tok := resp.Header.Get(hdrSession)
+	require.Equal(t, strconv.FormatInt(base.(graph.Snapshot).Horizon(), 10), tok)
+	require.NoError(t, wr.RemoveQuad(quad.Make("A", "follows", "B", nil)))
+
+	query := func(params string) (int, int, string) {
+		resp, err := http.Post(srv.URL+"/api/v2/query?lang=gizmo"+params, "", bytes.NewBufferString(`g.V("A").Out("follows").All()`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out struct {
+			Result []map[string]string `json:"result"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		}
+		return resp.StatusCode, len(out.Result), resp.Header.Get(hdrHorizon)
+	}
+	code, n, hdr := query("&session=" + tok)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 0, n)
+	require.Equal(t, strconv.FormatInt(base.(graph.Snapshot).Horizon(), 10), hdr)
+
+	code, n, hdr = query("&as_of=" + tok)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 1, n)
+	require.Equal(t, tok, hdr)
+
+	// memstore doesn't report the horizon nor keep the history
+	mem := makeHandle(t)
+	defer mem.Close()
+	msrv := httptest.NewServer(NewAPIv2(mem))
+	defer msrv.Close()
+	for _, params := range []string{"&session=1", "&as_of=1"} {
+		resp, err = http.Post(msrv.URL+"/api/v2/query?lang=gizmo"+params, "", bytes.NewBufferString(`g.V().All()`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, params)
+	}
+}
+
+func TestV2Audit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cayley-audit-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	al, err := audit.Open(path, 0)
+	require.NoError(t, err)
+	defer al.Close()
+
+	h := makeHandle(t)
+	defer h.Close()
+	api := NewAPIv2(h)
+	api.SetAuditLog(al)
+	api.SetAdminToken("secret")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	q := quad.Make("A", "follows", "B", nil)
+	send := func(op, id string) string {
+		body := bytes.NewBufferString(q.NQuad() + "\n")
+		req, err := http.NewRequest("POST", srv.URL+"/api/v2/"+op, body)
+		require.NoError(t, err)
+		req.Header.Set(hdrContentType, "application/n-quads")
+		if id != "" {
+			req.Header.Set(hdrRequestID, id)
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.Header.Get(hdrRequestID)
+	}
+	require.Equal(t, "req-1", send("write", "req-1"))
+	gen := send("delete", "")
+	require.NotEmpty(t, gen)
+
+	var got []audit.Entry
+	err = audit.Read(path, func(e audit.Entry) error {
+		got = append(got, e)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "req-1", got[0].RequestID)
+	require.Equal(t, audit.Committed, got[0].State)
+	require.Equal(t, AdminPrincipal, got[0].Principal)
+	require.Equal(t, audit.AuthToken, got[0].Auth)
+	require.Equal(t, []graph.Delta{{Quad: q, Action: graph.Add}}, got[0].Deltas)
+	require.Equal(t, gen, got[1].RequestID)
+	require.Equal(t, "", got[1].Principal)
+	require.Equal(t, audit.AuthNone, got[1].Auth)
+	require.Equal(t, []graph.Delta{{Quad: q, Action: graph.Delete}}, got[1].Deltas)
+}
+
+func TestV2Complete(t *testing.T) {
+	addr, closer := makeServerV2(t,
+		quad.Make(quad.IRI("a"), quad.IRI("name"), quad.String("Alice"), nil),
+		quad.Make(quad.IRI("b"), quad.IRI("name"), quad.String("Albert"), nil),
+		quad.Make(quad.IRI("c"), quad.IRI("city"), quad.String("Albany"), nil),
+	)
+	defer closer()
+
+	complete := func(params string) []string {
+		resp, err := http.Get(addr + "/api/v2/complete?" + params)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out struct {
+			Result []string `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out.Result
+	}
+	require.Equal(t, []string{"Albany", "Albert", "Alice"}, complete("prefix=al"))
+	require.Equal(t, []string{"Albert"}, complete("prefix=al&pred=%3Cname%3E&limit=1"))
+	require.Equal(t, []string{}, complete("prefix=x"))
+
+	resp, err := http.Get(addr + "/api/v2/complete?prefix=al&limit=x")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestV2QuerySession(t *testing.T) {
+	h := makeHandle(t,
+		quad.MakeIRI("http://example.com/a", "http://example.com/knows", "http://example.com/b", "g1"),
+		quad.MakeIRI("http://example.com/b", "http://example.com/knows", "http://example.com/c", "g2"),
+	)
+	defer h.Close()
+	api2 := NewAPIv2(h)
+
+	do := func(method, path, id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if id != "" {
+			req.Header.Set(hdrQuerySession, id)
+		}
+		rec := httptest.NewRecorder()
+		api2.ServeHTTP(rec, req)
+		return rec
+	}
+	query := func(params, id, qu string) []interface{} {
+		rec := do("POST", "/api/v2/query?lang=gizmo"+params, id, qu)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out struct {
+			Result []interface{} `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&out))
+		return out.Result
+	}
+
+	rec := do("POST", "/api/v2/query/session/begin", "", `{"graph": "<g2>", "namespaces": {"ex": "http://example.com/"}, "vars": {"start": "ex:b"}}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var st QuerySession
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&st))
+	require.NotEmpty(t, st.ID)
+	require.Equal(t, st.ID, rec.Header().Get(hdrQuerySession))
+	require.Equal(t, "<g2>", st.Graph)
+
+	const qu = `g.V(g.Uri(start)).Out(g.Uri("ex:knows")).All()`
+	require.Len(t, query("", st.ID, qu), 1)
+	// the graph parameter overrides the default graph of the session
+	require.Len(t, query("&graph=<g1>", st.ID, qu), 0)
+
+	rec = do("POST", "/api/v2/query/session/update", st.ID, `{"graph": "*", "vars": {"1x": 1}}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	rec = do("POST", "/api/v2/query/session/update", st.ID, `{"graph": "*", "vars": {"start": "ex:a"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, query("", st.ID, qu), 1)
+	require.Len(t, query("", st.ID, `g.V(g.Uri(start)).Out().Out().All()`), 1)
+
+	rec = do("POST", "/api/v2/query/session/update", st.ID, `{"vars": {"g": 1}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do("POST", "/api/v2/query?lang=gizmo", st.ID, qu)
+	require.NotEqual(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do("POST", "/api/v2/query/session/update", st.ID, `{"vars": {"g": null}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = do("GET", "/api/v2/query/session", st.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&st))
+	require.Equal(t, "*", st.Graph)
+	require.Equal(t, map[string]interface{}{"start": "ex:a"}, st.Vars)
+
+	rec = do("POST", "/api/v2/query/session/end", st.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do("POST", "/api/v2/query?lang=gizmo", st.ID, qu)
+	require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/named"
+	"github.com/cayleygraph/cayley/graph/trash"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// SetTrashRetention sets the time deleted graphs are kept in the trash before they are purged.
+// It's only reported to clients; graphs are purged by trash.RunPurger.
+func (api *APIv2) SetTrashRetention(d time.Duration) {
+	api.trashRetention = d
+}
+
+// trashGraph returns a label of the graph selected by the "graph" parameter. The graph must be writable by the client.
+func (api *APIv2) trashGraph(w http.ResponseWriter, r *http.Request) quad.Value {
+	name := r.URL.Query().Get("graph")
+	if name == "" || name == named.All {
+		jsonResponse(w, http.StatusBadRequest, errors.New("graph is not specified"))
+		return nil
+	}
+	label := quad.StringToValue(name)
+	if s := api.scope(r); s != nil && !s.CanWrite(label) {
+		jsonResponse(w, http.StatusForbidden, acl.ErrForbidden)
+		return nil
+	}
+	return label
+}
+
+// serveTrash runs a change of a graph in the trash, selected by the "graph" parameter.
+func (api *APIv2) serveTrash(w http.ResponseWriter, r *http.Request, fnc func(h *graph.Handle, label quad.Value) (string, error)) {
+	defer r.Body.Close()
+	if api.readOnly() {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	}
+	label := api.trashGraph(w, r)
+	if label == nil {
+		return
+	}
+	h, err := api.writeHandleForRequest(w, r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	resp, err := fnc(h, label)
+	if err != nil {
+		jsonResponse(w, writeErrorCode(err), err)
+		return
+	}
+	setSession(w, h.QuadStore)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	fmt.Fprintln(w, resp)
+}
+
+// ServeGraphDelete moves a graph to the trash. Its quads are hidden until the graph is restored or purged.
+func (api *APIv2) ServeGraphDelete(w http.ResponseWriter, r *http.Request) {
+	api.serveTrash(w, r, func(h *graph.Handle, label quad.Value) (string, error) {
+		if err := trash.Delete(r.Context(), h.QuadStore, h.QuadWriter, label, time.Now()); err != nil {
+			return "", err
+		}
+		return `{"result": "Successfully deleted graph."}`, nil
+	})
+}
+
+// ServeGraphRestore restores a graph from the trash.
+func (api *APIv2) ServeGraphRestore(w http.ResponseWriter, r *http.Request) {
+	api.serveTrash(w, r, func(h *graph.Handle, label quad.Value) (string, error) {
+		if err := trash.Restore(r.Context(), h.QuadStore, h.QuadWriter, label); err != nil {
+			return "", err
+		}
+		return `{"result": "Successfully restored graph."}`, nil
+	})
+}
+
+// ServeGraphPurge deletes all quads of a graph in the trash without waiting for the retention window.
+func (api *APIv2) ServeGraphPurge(w http.ResponseWriter, r *http.Request) {
+	api.serveTrash(w, r, func(h *graph.Handle, label quad.Value) (string, error) {
+		n, err := trash.Purge(r.Context(), h.QuadStore, h.QuadWriter, label)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`{"result": "Successfully purged %d quads.", "count": %d}`, n, n), nil
+	})
+}
+
+type trashEntry struct {
+	Graph     string     `json:"graph"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+}
+
+// ServeGraphTrash lists deleted graphs readable by the client.
+func (api *APIv2) ServeGraphTrash(w http.ResponseWriter, r *http.Request) {
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	list, err := trash.List(r.Context(), acl.Restrict(h.QuadStore, api.scope(r)))
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]trashEntry, 0, len(list))
+	for _, e := range list {
+		te := trashEntry{Graph: e.Label.String(), DeletedAt: e.DeletedAt}
+		if api.trashRetention > 0 {
+			t := e.DeletedAt.Add(api.trashRetention)
+			te.PurgeAt = &t
+		}
+		out = append(out, te)
+	}
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	writeResults(w, out, -1)
+}
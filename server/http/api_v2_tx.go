@@ -0,0 +1,399 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/acl"
+	"github.com/cayleygraph/cayley/graph/trash"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+const (
+	hdrTx = "X-Cayley-Tx"
+
+	// DefaultTxTimeout is the time an idle transaction is kept by the server before it is rolled back.
+	DefaultTxTimeout = 5 * time.Minute
+)
+
+var errTxNotFound = errors.New("unknown or expired transaction")
+
+// TxStatus describes a server-side transaction.
+type TxStatus struct {
+	ID      string    `json:"tx"`
+	Deltas  int       `json:"deltas"`
+	Expires time.Time `json:"expires"`
+}
+
+// txSession is a transaction that accumulates deltas across multiple requests.
+//
+// The registry mutex is never acquired while holding the session mutex.
+type txSession struct {
+	sync.Mutex
+	id string
+	h  *graph.Handle
+	tx *graph.Transaction
+	// last is the time of the last request in unix nanoseconds; it's accessed atomically
+	last int64
+	done bool
+}
+
+func (ts *txSession) touch(now time.Time) {
+	atomic.StoreInt64(&ts.last, now.UnixNano())
+}
+
+func (ts *txSession) lastUsed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&ts.last))
+}
+
+// txSessions is the registry of open transactions.
+type txSessions struct {
+	sync.Mutex
+	m map[string]*txSession
+}
+
+// gc removes expired transactions from the registry and returns them.
+// It must be called with the registry locked; returned sessions must be passed to expire after it's unlocked.
+func (s *txSessions) gc(now time.Time, ttl time.Duration) []*txSession {
+	var out []*txSession
+	for id, ts := range s.m {
+		if now.Sub(ts.lastUsed()) > ttl {
+			delete(s.m, id)
+			out = append(out, ts)
+		}
+	}
+	return out
+}
+
+// expire marks transactions removed by gc as done. Requests that are still using them finish first.
+func expire(list []*txSession) {
+	for _, ts := range list {
+		ts.Lock()
+		ts.done = true
+		ts.Unlock()
+	}
+}
+
+func (s *txSessions) begin(h *graph.Handle, ttl time.Duration) (*txSession, error) {
+	id, err := newLoadToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ts := &txSession{id: id, h: h, tx: graph.NewTransaction()}
+	ts.touch(now)
+	s.Lock()
+	expired := s.gc(now, ttl)
+	if s.m == nil {
+		s.m = make(map[string]*txSession)
+	}
+	s.m[id] = ts
+	s.Unlock()
+	expire(expired)
+	return ts, nil
+}
+
+// get returns a locked transaction with a given id. Caller must unlock it.
+func (s *txSessions) get(id string, ttl time.Duration) (*txSession, error) {
+	now := time.Now()
+	s.Lock()
+	expired := s.gc(now, ttl)
+	ts := s.m[id]
+	if ts != nil {
+		// touched under the registry lock, so a concurrent gc can't expire it
+		ts.touch(now)
+	}
+	s.Unlock()
+	expire(expired)
+	if ts == nil {
+		return nil, errTxNotFound
+	}
+	ts.Lock()
+	if ts.done {
+		// committed or rolled back by a concurrent request
+		ts.Unlock()
+		return nil, errTxNotFound
+	}
+	return ts, nil
+}
+
+// finish marks a locked transaction as done, unlocks it and removes it from the registry.
+func (s *txSessions) finish(ts *txSession) {
+	ts.done = true
+	ts.Unlock()
+	s.Lock()
+	if s.m[ts.id] == ts {
+		delete(s.m, ts.id)
+	}
+	s.Unlock()
+}
+
+func (ts *txSession) status(ttl time.Duration) TxStatus {
+	return TxStatus{ID: ts.id, Deltas: len(ts.tx.Deltas), Expires: ts.lastUsed().Add(ttl)}
+}
+
+func (api *APIv2) txTTL() time.Duration {
+	if api.txTimeout > 0 {
+		return api.txTimeout
+	}
+	return DefaultTxTimeout
+}
+
+func txID(r *http.Request) string {
+	if id := r.URL.Query().Get("tx"); id != "" {
+		return id
+	}
+	return r.Header.Get(hdrTx)
+}
+
+// txForRequest returns a locked transaction for the request, or writes an error.
+func (api *APIv2) txForRequest(w http.ResponseWriter, r *http.Request) *txSession {
+	if api.readOnly() {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return nil
+	}
+	id := txID(r)
+	if id == "" {
+		jsonResponse(w, http.StatusBadRequest, errors.New("transaction id is not set"))
+		return nil
+	}
+	ts, err := api.txs.get(id, api.txTTL())
+	if err != nil {
+		jsonResponse(w, http.StatusNotFound, err)
+		return nil
+	}
+	return ts
+}
+
+func writeTxStatus(w http.ResponseWriter, st TxStatus) {
+	w.Header().Set(hdrTx, st.ID)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	json.NewEncoder(w).Encode(st)
+}
+
+// ServeTxBegin starts a new transaction. Transaction id is returned in the response
+// and must be passed in the "tx" parameter (or X-Cayley-Tx header) of the following requests.
+//
+// Transaction is rolled back automatically if no requests were made in it for the idle timeout.
+func (api *APIv2) ServeTxBegin(w http.ResponseWriter, r *http.Request) {
+	if api.readOnly() {
+		jsonResponse(w, http.StatusForbidden, errors.New("database is read-only"))
+		return
+	}
+	h, err := api.handleForRequest(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ts, err := api.txs.begin(h, api.txTTL())
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeTxStatus(w, ts.status(api.txTTL()))
+}
+
+// readTxQuads reads all quads from the request body. Quads are only staged if the whole body is valid.
+func (api *APIv2) readTxQuads(r *http.Request) ([]quad.Quad, error) {
+	format := getFormat(r, "", hdrContentType)
+	if format == nil || format.Reader == nil {
+		return nil, errors.New("format is not supported for reading data")
+	}
+	rd, err := readerFrom(r, hdrContentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	qr := format.Reader(rd)
+	defer qr.Close()
+	return quad.ReadAll(qr)
+}
+
+func (api *APIv2) serveTxStage(w http.ResponseWriter, r *http.Request, del bool) {
+	defer r.Body.Close()
+	ts := api.txForRequest(w, r)
+	if ts == nil {
+		return
+	}
+	defer ts.Unlock()
+	conds, err := preconditions(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	quads, err := api.readTxQuads(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	ts.tx.Preconditions = append(ts.tx.Preconditions, conds...)
+	for _, q := range quads {
+		if del {
+			ts.tx.RemoveQuad(q)
+		} else {
+			ts.tx.AddQuad(q)
+		}
+	}
+	writeTxStatus(w, ts.status(api.txTTL()))
+}
+
+// ServeTxWrite stages quads from the request body to be added on commit.
+func (api *APIv2) ServeTxWrite(w http.ResponseWriter, r *http.Request) {
+	api.serveTxStage(w, r, false)
+}
+
+// ServeTxDelete stages quads from the request body to be removed on commit.
+func (api *APIv2) ServeTxDelete(w http.ResponseWriter, r *http.Request) {
+	api.serveTxStage(w, r, true)
+}
+
+// ServeTxRead returns all quads as they will be visible after the commit:
+// quads of the database with staged changes applied on top of them.
+func (api *APIv2) ServeTxRead(w http.ResponseWriter, r *http.Request) {
+	format := getFormat(r, "format", hdrAccept)
+	if format == nil || format.Writer == nil {
+		jsonResponse(w, http.StatusBadRequest, fmt.Errorf("format is not supported for reading data"))
+		return
+	}
+	ts := api.txForRequest(w, r)
+	if ts == nil {
+		return
+	}
+	qs, err := trash.Hide(r.Context(), acl.Restrict(ts.h.QuadStore, api.scope(r)))
+	if err != nil {
+		ts.Unlock()
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	// the reader keeps a copy of staged deltas, so the transaction is not locked while streaming
+	qr := newTxReader(graph.NewQuadStoreReader(qs), ts.tx)
+	ts.Unlock()
+	defer qr.Close()
+	api.writeQuads(w, r, format, qr)
+}
+
+// ServeTxCommit atomically applies all staged changes to the database.
+func (api *APIv2) ServeTxCommit(w http.ResponseWriter, r *http.Request) {
+	ts := api.txForRequest(w, r)
+	if ts == nil {
+		return
+	}
+	// changes are checked and recorded on behalf of the request that commits them
+	h, err := api.writeHandle(w, r, ts.h)
+	if err != nil {
+		ts.Unlock()
+		jsonResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = h.QuadWriter.ApplyTransaction(ts.tx)
+	if graph.IsQuadExist(err) || graph.IsQuadNotExist(err) {
+		// transaction is still open, so the client can fix it or roll it back
+		ts.Unlock()
+		jsonResponse(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		ts.Unlock()
+		jsonResponse(w, writeErrorCode(err), err)
+		return
+	}
+	n := len(ts.tx.Deltas)
+	api.txs.finish(ts)
+	setSession(w, ts.h.QuadStore)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	fmt.Fprintf(w, `{"result": "Successfully committed %d deltas.", "count": %d}`+"\n", n, n)
+}
+
+// ServeTxRollback discards all staged changes.
+func (api *APIv2) ServeTxRollback(w http.ResponseWriter, r *http.Request) {
+	ts := api.txForRequest(w, r)
+	if ts == nil {
+		return
+	}
+	api.txs.finish(ts)
+	w.Header().Set(hdrContentType, contentTypeJSON)
+	fmt.Fprintln(w, `{"result": "Transaction rolled back."}`)
+}
+
+// txReader returns quads from the underlying reader with transaction deltas applied.
+type txReader struct {
+	qr    quad.ReadCloser
+	del   map[quad.Quad]struct{}
+	add   []quad.Quad
+	added map[quad.Quad]struct{}
+}
+
+func newTxReader(qr quad.ReadCloser, tx *graph.Transaction) *txReader {
+	r := &txReader{
+		qr:    qr,
+		del:   make(map[quad.Quad]struct{}),
+		added: make(map[quad.Quad]struct{}),
+	}
+	for _, d := range tx.Deltas {
+		switch d.Action {
+		case graph.Add:
+			r.add = append(r.add, d.Quad)
+			r.added[d.Quad] = struct{}{}
+		case graph.Delete:
+			r.del[d.Quad] = struct{}{}
+		}
+	}
+	return r
+}
+
+func (r *txReader) ReadQuad() (quad.Quad, error) {
+	for r.qr != nil {
+		q, err := r.qr.ReadQuad()
+		if err == io.EOF {
+			r.qr.Close()
+			r.qr = nil
+			break
+		} else if err != nil {
+			return quad.Quad{}, err
+		}
+		if _, ok := r.del[q]; ok {
+			continue
+		}
+		// quad already exists, no need to return it twice
+		delete(r.added, q)
+		return q, nil
+	}
+	for len(r.add) != 0 {
+		q := r.add[0]
+		r.add = r.add[1:]
+		if _, ok := r.added[q]; ok {
+			return q, nil
+		}
+	}
+	return quad.Quad{}, io.EOF
+}
+
+func (r *txReader) Close() error {
+	if r.qr == nil {
+		return nil
+	}
+	err := r.qr.Close()
+	r.qr = nil
+	return err
+}
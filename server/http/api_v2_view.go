@@ -0,0 +1,39 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cayleyhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/internal/view"
+)
+
+// SetViews allows queries and reads to select views by their names with the "graph" parameter.
+// Materialized views are stored in the database, thus only views evaluated on demand are handled here.
+func (api *APIv2) SetViews(vs *view.Views) {
+	api.views = vs
+}
+
+// evaluateView returns quads of the selected graph, if it's a view that is evaluated on demand.
+// The view is built from graphs of the quad store, which are already restricted to the ones accessible by the client.
+func (api *APIv2) evaluateView(ctx context.Context, r *http.Request, qs graph.QuadStore) (graph.QuadStore, error) {
+	v := api.views.Get(api.selectedGraph(r))
+	if v == nil || v.Materialized {
+		return qs, nil
+	}
+	return v.Evaluate(ctx, qs)
+}
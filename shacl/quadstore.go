@@ -0,0 +1,136 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cayleygraph/cayley/clog"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+var _ graph.ConditionalApplier = (*QuadStore)(nil)
+
+// QuadStore validates nodes changed by each write against a set of shapes.
+//
+// If enforcement is enabled, writes that make the data non-conforming are reverted
+// and a *ValidationError is returned. Otherwise, validation results are only logged.
+//
+// Writes are serialized by the wrapper, thus all writes must go through it.
+// Shapes are not reloaded when they are changed in the underlying quad store.
+type QuadStore struct {
+	graph.QuadStore
+	shapes  *Shapes
+	enforce bool
+
+	mu sync.Mutex
+}
+
+// NewQuadStore wraps a quad store to validate writes against given shapes.
+func NewQuadStore(qs graph.QuadStore, shapes *Shapes, enforce bool) *QuadStore {
+	return &QuadStore{QuadStore: qs, shapes: shapes, enforce: enforce}
+}
+
+func (qs *QuadStore) ApplyDeltas(deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	return qs.ApplyDeltasIf(nil, deltas, opts)
+}
+
+// ApplyDeltasIf applies deltas to the underlying quad store and validates all subjects and objects of changed quads.
+func (qs *QuadStore) ApplyDeltasIf(conds []graph.Precondition, deltas []graph.Delta, opts graph.IgnoreOpts) error {
+	ctx := context.TODO()
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	// remember which deltas will actually change the store, so only they are reverted
+	var applied []graph.Delta
+	if qs.enforce {
+		for _, d := range deltas {
+			ok, err := hasQuad(ctx, qs.QuadStore, d.Quad)
+			if err != nil {
+				return err
+			}
+			if ok == (d.Action == graph.Delete) {
+				applied = append(applied, d)
+			}
+		}
+	}
+	var err error
+	if len(conds) == 0 {
+		err = qs.QuadStore.ApplyDeltas(deltas, opts)
+	} else if ca, ok := qs.QuadStore.(graph.ConditionalApplier); ok {
+		err = ca.ApplyDeltasIf(conds, deltas, opts)
+	} else {
+		err = graph.ErrPreconditionsNotSupported
+	}
+	if err != nil || len(deltas) == 0 {
+		return err
+	}
+	var nodes []quad.Value
+	seen := make(map[string]bool)
+	for _, d := range deltas {
+		for _, v := range []quad.Value{d.Quad.Subject, d.Quad.Object} {
+			if !seen[v.String()] {
+				seen[v.String()] = true
+				nodes = append(nodes, v)
+			}
+		}
+	}
+	rep, err := qs.shapes.ValidateNodes(ctx, qs.QuadStore, nodes)
+	if err != nil {
+		// do not revert the changes, since we don't know if the data is invalid
+		clog.Errorf("shacl: failed to validate %d deltas: %v", len(deltas), err)
+		return nil
+	} else if rep.Conforms {
+		return nil
+	}
+	if !qs.enforce {
+		for _, r := range rep.Results {
+			clog.Warningf("shacl: %v", r)
+		}
+		return nil
+	}
+	revert := make([]graph.Delta, 0, len(applied))
+	for i := len(applied) - 1; i >= 0; i-- {
+		d := applied[i]
+		if d.Action == graph.Add {
+			d.Action = graph.Delete
+		} else {
+			d.Action = graph.Add
+		}
+		revert = append(revert, d)
+	}
+	if err := qs.QuadStore.ApplyDeltas(revert, graph.IgnoreOpts{IgnoreDup: true, IgnoreMissing: true}); err != nil {
+		clog.Errorf("shacl: failed to revert %d deltas: %v", len(revert), err)
+		return err
+	}
+	return &ValidationError{Report: rep}
+}
+
+// hasQuad checks if a quad exists in the quad store.
+func hasQuad(ctx context.Context, qs graph.QuadStore, q quad.Quad) (bool, error) {
+	ref := qs.ValueOf(q.Subject)
+	if ref == nil {
+		return false, nil
+	}
+	it := qs.QuadIterator(quad.Subject, ref)
+	defer it.Close()
+	for it.Next(ctx) {
+		if qs.Quad(it.Result()) == q {
+			return true, nil
+		}
+	}
+	return false, it.Err()
+}
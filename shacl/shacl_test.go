@@ -0,0 +1,133 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/quad/nquads"
+	"github.com/cayleygraph/cayley/voc/sh"
+)
+
+const testShapes = `
+<ex:PersonShape> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/ns/shacl#NodeShape> .
+<ex:PersonShape> <http://www.w3.org/ns/shacl#targetClass> <ex:Person> .
+<ex:PersonShape> <http://www.w3.org/ns/shacl#property> _:name .
+<ex:PersonShape> <http://www.w3.org/ns/shacl#property> _:age .
+<ex:PersonShape> <http://www.w3.org/ns/shacl#property> _:knows .
+_:name <http://www.w3.org/ns/shacl#path> <ex:name> .
+_:name <http://www.w3.org/ns/shacl#minCount> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:name <http://www.w3.org/ns/shacl#maxCount> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:name <http://www.w3.org/ns/shacl#datatype> <http://www.w3.org/2001/XMLSchema#string> .
+_:name <http://www.w3.org/ns/shacl#pattern> "^[A-Z]" .
+_:age <http://www.w3.org/ns/shacl#path> <ex:age> .
+_:age <http://www.w3.org/ns/shacl#minInclusive> "0"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:knows <http://www.w3.org/ns/shacl#path> <ex:knows> .
+_:knows <http://www.w3.org/ns/shacl#class> <ex:Person> .
+_:knows <http://www.w3.org/ns/shacl#nodeKind> <http://www.w3.org/ns/shacl#IRI> .
+`
+
+const testData = `
+<ex:Student> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <ex:Person> .
+<ex:alice> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <ex:Person> .
+<ex:alice> <ex:name> "Alice" .
+<ex:alice> <ex:age> "30"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<ex:alice> <ex:knows> <ex:bob> .
+<ex:bob> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <ex:Student> .
+<ex:bob> <ex:name> "bob" .
+<ex:bob> <ex:knows> <ex:carol> .
+<ex:carol> <ex:name> "Carol" .
+`
+
+func readShapes(t testing.TB) *Shapes {
+	shapes, err := Read(context.TODO(), nquads.NewReader(strings.NewReader(testShapes), false))
+	require.NoError(t, err)
+	return shapes
+}
+
+func newStore(t testing.TB) graph.QuadStore {
+	qs := memstore.New()
+	w := &memWriter{qs: qs}
+	_, err := quad.Copy(w, nquads.NewReader(strings.NewReader(testData), false))
+	require.NoError(t, err)
+	return qs
+}
+
+func results(rep *Report) []string {
+	var out []string
+	for _, r := range rep.Results {
+		out = append(out, string(r.Focus.(quad.IRI))+" "+string(r.Component.Short()))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	shapes := readShapes(t)
+	require.Len(t, shapes.Shapes, 1)
+	s := shapes.Shapes[0]
+	require.Equal(t, quad.IRI("ex:PersonShape"), s.ID)
+	require.Equal(t, []quad.Value{quad.IRI("ex:Person")}, s.TargetClass)
+	require.Len(t, s.Properties, 3)
+}
+
+func TestValidate(t *testing.T) {
+	shapes := readShapes(t)
+	qs := newStore(t)
+	rep, err := shapes.Validate(context.TODO(), qs)
+	require.NoError(t, err)
+	require.False(t, rep.Conforms)
+	require.Equal(t, []string{
+		"ex:bob " + sh.ClassConstraintComponent,
+		"ex:bob " + sh.PatternConstraintComponent,
+	}, results(rep))
+
+	out := rep.Quads(quad.BNode("report"))
+	require.Contains(t, out, quad.Make(quad.BNode("report"), iri(sh.Conforms), quad.Bool(false), nil))
+}
+
+func TestEnforce(t *testing.T) {
+	shapes := readShapes(t)
+	base := newStore(t)
+	qs := NewQuadStore(base, shapes, true)
+
+	bad := quad.Make(quad.IRI("ex:carol"), iri("http://www.w3.org/1999/02/22-rdf-syntax-ns#type"), quad.IRI("ex:Person"), nil)
+	neg := quad.Make(quad.IRI("ex:carol"), quad.IRI("ex:age"), quad.Int(-1), nil)
+	err := qs.ApplyDeltas([]graph.Delta{
+		{Quad: bad, Action: graph.Add},
+		{Quad: neg, Action: graph.Add},
+	}, graph.IgnoreOpts{})
+	verr, ok := err.(*ValidationError)
+	require.True(t, ok, "%v", err)
+	require.Equal(t, []string{"ex:carol " + sh.MinInclusiveConstraintComponent}, results(verr.Report))
+
+	ok, err = hasQuad(context.TODO(), base, neg)
+	require.NoError(t, err)
+	require.False(t, ok, "write was not reverted")
+
+	err = qs.ApplyDeltas([]graph.Delta{{Quad: bad, Action: graph.Add}}, graph.IgnoreOpts{})
+	require.NoError(t, err)
+	ok, err = hasQuad(context.TODO(), base, bad)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
@@ -0,0 +1,444 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shacl validates graph data against SHACL shapes.
+//
+// Shapes are loaded from a quad store or from quads read from a file. The package supports SHACL Core targets and
+// constraints on values of single predicates and their inverse: cardinality, value types, value ranges,
+// string constraints, lists of allowed values, sh:hasValue, sh:node and closed shapes.
+// Logical constraints (sh:and, sh:or, sh:not, sh:xone), complex paths and SPARQL-based constraints are not supported.
+//
+// All IRIs of the SHACL, RDF and RDFS vocabularies are expected to be in the full form,
+// as they are written by standard RDF formats.
+package shacl
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/memstore"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	"github.com/cayleygraph/cayley/voc/rdfs"
+	"github.com/cayleygraph/cayley/voc/sh"
+)
+
+// iri returns a full IRI for a vocabulary term.
+func iri(s string) quad.IRI {
+	return quad.IRI(s).Full()
+}
+
+// Path is a property path of a property shape.
+type Path struct {
+	Pred    quad.Value
+	Inverse bool // follow the predicate from objects to subjects
+}
+
+func (p *Path) String() string {
+	if p.Inverse {
+		return "^" + p.Pred.String()
+	}
+	return p.Pred.String()
+}
+
+// Shape is a node or property shape.
+type Shape struct {
+	ID quad.Value
+	// Path is set for property shapes.
+	Path *Path
+
+	TargetClass      []quad.Value
+	TargetNode       []quad.Value
+	TargetSubjectsOf []quad.Value
+	TargetObjectsOf  []quad.Value
+
+	Severity    quad.IRI
+	Message     string
+	Deactivated bool
+
+	// Properties are property shapes of this shape.
+	Properties []*Shape
+
+	constraints []constraint
+}
+
+// HasTargets checks if shape selects focus nodes by itself.
+func (s *Shape) HasTargets() bool {
+	return len(s.TargetClass)+len(s.TargetNode)+len(s.TargetSubjectsOf)+len(s.TargetObjectsOf) != 0
+}
+
+// Shapes is a set of shapes loaded from a graph.
+type Shapes struct {
+	// Shapes are all shapes that have targets.
+	Shapes []*Shape
+}
+
+// Load reads all shapes with targets from a quad store, with all property shapes and shapes they refer to.
+func Load(ctx context.Context, qs graph.QuadStore) (*Shapes, error) {
+	l := &loader{ctx: ctx, qs: qs, shapes: make(map[string]*Shape)}
+	roots := path.StartPath(qs).Has(iri(rdf.Type), iri(sh.NodeShape)).Or(
+		path.StartPath(qs).Has(iri(rdf.Type), iri(sh.PropertyShape)),
+	)
+	for _, t := range []string{sh.TargetClass, sh.TargetNode, sh.TargetSubjectsOf, sh.TargetObjectsOf} {
+		roots = roots.Or(path.StartPath(qs).Has(iri(t)))
+	}
+	ids, err := roots.Unique().Iterate(ctx).AllValues(qs)
+	if err != nil {
+		return nil, err
+	}
+	out := &Shapes{}
+	for _, id := range ids {
+		s, err := l.shape(id)
+		if err != nil {
+			return nil, err
+		}
+		if s.HasTargets() {
+			out.Shapes = append(out.Shapes, s)
+		}
+	}
+	return out, nil
+}
+
+// Read loads shapes from quads, for example from a shapes file.
+func Read(ctx context.Context, r quad.Reader) (*Shapes, error) {
+	qs := memstore.New()
+	if _, err := quad.CopyBatch(&memWriter{qs: qs}, r, 0); err != nil {
+		return nil, err
+	}
+	return Load(ctx, qs)
+}
+
+// memWriter adds quads to a memstore, ignoring duplicates.
+type memWriter struct {
+	qs *memstore.QuadStore
+}
+
+func (w *memWriter) WriteQuad(q quad.Quad) error {
+	_, err := w.WriteQuads([]quad.Quad{q})
+	return err
+}
+
+func (w *memWriter) WriteQuads(buf []quad.Quad) (int, error) {
+	deltas := make([]graph.Delta, 0, len(buf))
+	for _, q := range buf {
+		deltas = append(deltas, graph.Delta{Quad: q, Action: graph.Add})
+	}
+	if err := w.qs.ApplyDeltas(deltas, graph.IgnoreOpts{IgnoreDup: true}); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+func (w *memWriter) Close() error { return nil }
+
+type loader struct {
+	ctx    context.Context
+	qs     graph.QuadStore
+	shapes map[string]*Shape
+}
+
+func (l *loader) values(node quad.Value, pred string) ([]quad.Value, error) {
+	return path.StartPath(l.qs, node).Out(iri(pred)).Iterate(l.ctx).AllValues(l.qs)
+}
+
+func (l *loader) value(node quad.Value, pred string) (quad.Value, error) {
+	vals, err := l.values(node, pred)
+	if err != nil || len(vals) == 0 {
+		return nil, err
+	} else if len(vals) > 1 {
+		return nil, fmt.Errorf("shacl: expected a single %s for %v, got %d", pred, node, len(vals))
+	}
+	return vals[0], nil
+}
+
+// list reads an RDF list.
+func (l *loader) list(head quad.Value) ([]quad.Value, error) {
+	var out []quad.Value
+	nilIRI := iri(rdf.Nil)
+	seen := make(map[string]bool)
+	for head != nil && head != nilIRI {
+		if seen[head.String()] {
+			return nil, fmt.Errorf("shacl: loop in the RDF list at %v", head)
+		}
+		seen[head.String()] = true
+		first, err := l.value(head, rdf.First)
+		if err != nil {
+			return nil, err
+		} else if first == nil {
+			return nil, fmt.Errorf("shacl: malformed RDF list at %v", head)
+		}
+		out = append(out, first)
+		if head, err = l.value(head, rdf.Rest); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func toInt(v quad.Value) (int64, error) {
+	if ts, ok := v.(quad.TypedString); ok {
+		pv, err := ts.ParseValue()
+		if err != nil {
+			return 0, err
+		}
+		v = pv
+	}
+	switch v := v.(type) {
+	case quad.Int:
+		return int64(v), nil
+	case quad.String:
+		return strconv.ParseInt(string(v), 10, 64)
+	}
+	return 0, fmt.Errorf("shacl: expected an integer, got %v", v)
+}
+
+func toBool(v quad.Value) bool {
+	if ts, ok := v.(quad.TypedString); ok {
+		if pv, err := ts.ParseValue(); err == nil {
+			v = pv
+		}
+	}
+	switch v := v.(type) {
+	case quad.Bool:
+		return bool(v)
+	case quad.String:
+		return string(v) == "true"
+	}
+	return false
+}
+
+func (l *loader) shape(id quad.Value) (*Shape, error) {
+	if s, ok := l.shapes[id.String()]; ok {
+		return s, nil
+	}
+	s := &Shape{ID: id, Severity: iri(sh.Violation)}
+	// register before loading constraints, so recursive shapes refer to the same object
+	l.shapes[id.String()] = s
+	var err error
+	if v, err := l.value(id, sh.Deactivated); err != nil {
+		return nil, err
+	} else if v != nil {
+		s.Deactivated = toBool(v)
+	}
+	if v, err := l.value(id, sh.Severity); err != nil {
+		return nil, err
+	} else if v != nil {
+		iv, ok := v.(quad.IRI)
+		if !ok {
+			return nil, fmt.Errorf("shacl: severity of %v is not an IRI: %v", id, v)
+		}
+		s.Severity = iv
+	}
+	msgs, err := l.values(id, sh.Message)
+	if err != nil {
+		return nil, err
+	} else if len(msgs) != 0 {
+		s.Message = quad.ToString(msgs[0])
+	}
+	if s.TargetNode, err = l.values(id, sh.TargetNode); err != nil {
+		return nil, err
+	}
+	if s.TargetClass, err = l.values(id, sh.TargetClass); err != nil {
+		return nil, err
+	}
+	// implicit class target
+	if ok, err := l.has(id, rdf.Type, iri(rdfs.Class)); err != nil {
+		return nil, err
+	} else if ok {
+		s.TargetClass = append(s.TargetClass, id)
+	}
+	if s.TargetSubjectsOf, err = l.values(id, sh.TargetSubjectsOf); err != nil {
+		return nil, err
+	}
+	if s.TargetObjectsOf, err = l.values(id, sh.TargetObjectsOf); err != nil {
+		return nil, err
+	}
+	if p, err := l.value(id, sh.Path); err != nil {
+		return nil, err
+	} else if p != nil {
+		if s.Path, err = l.path(p); err != nil {
+			return nil, err
+		}
+	}
+	if err = l.constraints(s); err != nil {
+		return nil, err
+	}
+	props, err := l.values(id, sh.Property)
+	if err != nil {
+		return nil, err
+	}
+	for _, pid := range props {
+		ps, err := l.shape(pid)
+		if err != nil {
+			return nil, err
+		} else if ps.Path == nil {
+			return nil, fmt.Errorf("shacl: property shape %v has no path", pid)
+		}
+		s.Properties = append(s.Properties, ps)
+	}
+	return s, nil
+}
+
+func (l *loader) has(node quad.Value, pred string, val quad.Value) (bool, error) {
+	vals, err := l.values(node, pred)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range vals {
+		if v == val {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (l *loader) path(p quad.Value) (*Path, error) {
+	if _, ok := p.(quad.IRI); ok {
+		return &Path{Pred: p}, nil
+	}
+	inv, err := l.value(p, sh.InversePath)
+	if err != nil {
+		return nil, err
+	} else if _, ok := inv.(quad.IRI); ok {
+		return &Path{Pred: inv, Inverse: true}, nil
+	}
+	return nil, fmt.Errorf("shacl: unsupported path: %v", p)
+}
+
+func (l *loader) constraints(s *Shape) error {
+	for _, pred := range []string{sh.MinCount, sh.MaxCount, sh.MinLength, sh.MaxLength} {
+		v, err := l.value(s.ID, pred)
+		if err != nil {
+			return err
+		} else if v == nil {
+			continue
+		}
+		n, err := toInt(v)
+		if err != nil {
+			return fmt.Errorf("shacl: %s of %v: %v", pred, s.ID, err)
+		}
+		switch pred {
+		case sh.MinCount:
+			s.constraints = append(s.constraints, minCount(n))
+		case sh.MaxCount:
+			s.constraints = append(s.constraints, maxCount(n))
+		case sh.MinLength:
+			s.constraints = append(s.constraints, minLength(n))
+		case sh.MaxLength:
+			s.constraints = append(s.constraints, maxLength(n))
+		}
+	}
+	for _, pred := range []string{sh.Datatype, sh.Class, sh.NodeKind, sh.Node} {
+		vals, err := l.values(s.ID, pred)
+		if err != nil {
+			return err
+		}
+		for _, v := range vals {
+			switch pred {
+			case sh.Datatype:
+				s.constraints = append(s.constraints, datatype{v})
+			case sh.Class:
+				s.constraints = append(s.constraints, class{v})
+			case sh.NodeKind:
+				iv, ok := v.(quad.IRI)
+				if !ok {
+					return fmt.Errorf("shacl: node kind of %v is not an IRI: %v", s.ID, v)
+				}
+				s.constraints = append(s.constraints, nodeKind(iv))
+			case sh.Node:
+				ns, err := l.shape(v)
+				if err != nil {
+					return err
+				}
+				s.constraints = append(s.constraints, node{ns})
+			}
+		}
+	}
+	vals, err := l.values(s.ID, sh.HasValue)
+	if err != nil {
+		return err
+	}
+	for _, v := range vals {
+		s.constraints = append(s.constraints, hasValue{v})
+	}
+	if head, err := l.value(s.ID, sh.In); err != nil {
+		return err
+	} else if head != nil {
+		list, err := l.list(head)
+		if err != nil {
+			return err
+		}
+		s.constraints = append(s.constraints, in(list))
+	}
+	if v, err := l.value(s.ID, sh.Pattern); err != nil {
+		return err
+	} else if v != nil {
+		expr := quad.ToString(v)
+		if f, err := l.value(s.ID, sh.Flags); err != nil {
+			return err
+		} else if f != nil {
+			if flags := strings.Replace(quad.ToString(f), "x", "", -1); flags != "" {
+				// only flags supported by Go are allowed
+				expr = "(?" + flags + ")" + expr
+			}
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("shacl: pattern of %v: %v", s.ID, err)
+		}
+		s.constraints = append(s.constraints, pattern{re})
+	}
+	for _, c := range []struct {
+		pred string
+		cmp  func(c int) bool
+		comp string
+	}{
+		{sh.MinInclusive, func(c int) bool { return c >= 0 }, sh.MinInclusiveConstraintComponent},
+		{sh.MaxInclusive, func(c int) bool { return c <= 0 }, sh.MaxInclusiveConstraintComponent},
+		{sh.MinExclusive, func(c int) bool { return c > 0 }, sh.MinExclusiveConstraintComponent},
+		{sh.MaxExclusive, func(c int) bool { return c < 0 }, sh.MaxExclusiveConstraintComponent},
+	} {
+		v, err := l.value(s.ID, c.pred)
+		if err != nil {
+			return err
+		} else if v != nil {
+			s.constraints = append(s.constraints, valueRange{bound: v, ok: c.cmp, comp: c.comp})
+		}
+	}
+	if v, err := l.value(s.ID, sh.Closed); err != nil {
+		return err
+	} else if v != nil && toBool(v) {
+		cl := closed{allowed: make(map[string]bool)}
+		if head, err := l.value(s.ID, sh.IgnoredProperties); err != nil {
+			return err
+		} else if head != nil {
+			list, err := l.list(head)
+			if err != nil {
+				return err
+			}
+			for _, p := range list {
+				cl.allowed[p.String()] = true
+			}
+		}
+		// property shapes are loaded after constraints; closed constraint reads them on validation
+		cl.shape = s
+		s.constraints = append(s.constraints, cl)
+	}
+	return nil
+}
@@ -0,0 +1,628 @@
+// Copyright 2017 The Cayley Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/voc/rdf"
+	"github.com/cayleygraph/cayley/voc/rdfs"
+	"github.com/cayleygraph/cayley/voc/sh"
+)
+
+// Result is a single validation result.
+type Result struct {
+	Focus quad.Value
+	// Path is set for results of property shapes.
+	Path *Path
+	// Value is the value that caused the result, if any.
+	Value     quad.Value
+	Shape     quad.Value
+	Component quad.IRI
+	Severity  quad.IRI
+	Message   string
+}
+
+func (r Result) String() string {
+	s := fmt.Sprintf("%v: %v", r.Focus, r.Component.Short())
+	if r.Path != nil {
+		s += " on " + r.Path.String()
+	}
+	if r.Value != nil {
+		s += fmt.Sprintf(" (value %v)", r.Value)
+	}
+	if r.Message != "" {
+		s += ": " + r.Message
+	}
+	return s
+}
+
+// Report is a result of validation.
+type Report struct {
+	Conforms bool
+	Results  []Result
+}
+
+// Quads returns the report in a form of a standard SHACL validation report, with a given node as its id.
+func (r *Report) Quads(id quad.Value) []quad.Quad {
+	out := []quad.Quad{
+		quad.Make(id, iri(rdf.Type), iri(sh.ValidationReport), nil),
+		quad.Make(id, iri(sh.Conforms), quad.Bool(r.Conforms), nil),
+	}
+	for _, res := range r.Results {
+		n := quad.RandomBlankNode()
+		add := func(pred string, v quad.Value) {
+			if v != nil {
+				out = append(out, quad.Make(n, iri(pred), v, nil))
+			}
+		}
+		out = append(out, quad.Make(id, iri(sh.Result), n, nil))
+		add(rdf.Type, iri(sh.ValidationResult))
+		add(sh.FocusNode, res.Focus)
+		if p := res.Path; p != nil && !p.Inverse {
+			add(sh.ResultPath, p.Pred)
+		} else if p != nil {
+			inv := quad.RandomBlankNode()
+			add(sh.ResultPath, inv)
+			out = append(out, quad.Make(inv, iri(sh.InversePath), p.Pred, nil))
+		}
+		add(sh.Value, res.Value)
+		add(sh.SourceShape, res.Shape)
+		add(sh.SourceConstraintComponent, res.Component)
+		add(sh.ResultSeverity, res.Severity)
+		if res.Message != "" {
+			add(sh.ResultMessage, quad.String(res.Message))
+		}
+	}
+	return out
+}
+
+// ValidationError is returned when a write doesn't conform to shapes.
+type ValidationError struct {
+	Report *Report
+}
+
+func (e *ValidationError) Error() string {
+	n := len(e.Report.Results)
+	if n == 0 {
+		return "shacl: validation failed"
+	}
+	if n == 1 {
+		return "shacl: validation failed: " + e.Report.Results[0].String()
+	}
+	return fmt.Sprintf("shacl: validation failed: %v (and %d more)", e.Report.Results[0], n-1)
+}
+
+// Validate validates all focus nodes of all shapes in the quad store.
+func (s *Shapes) Validate(ctx context.Context, qs graph.QuadStore) (*Report, error) {
+	v := newValidator(ctx, qs)
+	for _, sh := range s.Shapes {
+		if sh.Deactivated {
+			continue
+		}
+		nodes, err := v.focusNodes(sh)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			if err := v.validate(sh, n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v.report(), nil
+}
+
+// ValidateNodes validates given nodes against shapes they are focus nodes of.
+func (s *Shapes) ValidateNodes(ctx context.Context, qs graph.QuadStore, nodes []quad.Value) (*Report, error) {
+	v := newValidator(ctx, qs)
+	for _, sh := range s.Shapes {
+		if sh.Deactivated {
+			continue
+		}
+		for _, n := range nodes {
+			ok, err := v.isTarget(sh, n)
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+			if err := v.validate(sh, n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v.report(), nil
+}
+
+type validator struct {
+	ctx     context.Context
+	qs      graph.QuadStore
+	results []Result
+	// conforms caches results of sh:node checks; nodes that are being checked are assumed to conform
+	conforms map[[2]string]bool
+}
+
+func newValidator(ctx context.Context, qs graph.QuadStore) *validator {
+	return &validator{ctx: ctx, qs: qs, conforms: make(map[[2]string]bool)}
+}
+
+func (v *validator) report() *Report {
+	return &Report{Conforms: len(v.results) == 0, Results: v.results}
+}
+
+// classes returns a path to all sub-classes of given classes, including themselves.
+func (v *validator) classes(classes ...quad.Value) *path.Path {
+	p := path.StartPath(v.qs, classes...)
+	return p.Or(p.FollowRecursive(path.StartMorphism().In(iri(rdfs.SubClassOf)), -1, nil))
+}
+
+func (v *validator) focusNodes(s *Shape) ([]quad.Value, error) {
+	var paths []*path.Path
+	if len(s.TargetNode) != 0 {
+		paths = append(paths, path.StartPath(v.qs, s.TargetNode...))
+	}
+	if len(s.TargetClass) != 0 {
+		paths = append(paths, v.classes(s.TargetClass...).In(iri(rdf.Type)))
+	}
+	if len(s.TargetSubjectsOf) != 0 {
+		paths = append(paths, path.StartPath(v.qs).Has(s.TargetSubjectsOf))
+	}
+	if len(s.TargetObjectsOf) != 0 {
+		paths = append(paths, path.StartPath(v.qs).HasReverse(s.TargetObjectsOf))
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	p := paths[0]
+	for _, p2 := range paths[1:] {
+		p = p.Or(p2)
+	}
+	return p.Unique().Iterate(v.ctx).AllValues(v.qs)
+}
+
+func (v *validator) isTarget(s *Shape, n quad.Value) (bool, error) {
+	for _, t := range s.TargetNode {
+		if t == n {
+			return true, nil
+		}
+	}
+	start := path.StartPath(v.qs, n)
+	var paths []*path.Path
+	if len(s.TargetClass) != 0 {
+		paths = append(paths, start.Out(iri(rdf.Type)).And(v.classes(s.TargetClass...)))
+	}
+	if len(s.TargetSubjectsOf) != 0 {
+		paths = append(paths, start.Has(s.TargetSubjectsOf))
+	}
+	if len(s.TargetObjectsOf) != 0 {
+		paths = append(paths, start.HasReverse(s.TargetObjectsOf))
+	}
+	for _, p := range paths {
+		if ok, err := v.exists(p); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func (v *validator) exists(p *path.Path) (bool, error) {
+	n, err := p.Iterate(v.ctx).Limit(1).Count()
+	return n != 0, err
+}
+
+// values returns value nodes of a shape for a focus node.
+func (v *validator) values(s *Shape, focus quad.Value) ([]quad.Value, error) {
+	if s.Path == nil {
+		return []quad.Value{focus}, nil
+	}
+	p := path.StartPath(v.qs, focus)
+	if s.Path.Inverse {
+		p = p.In(s.Path.Pred)
+	} else {
+		p = p.Out(s.Path.Pred)
+	}
+	return p.Iterate(v.ctx).AllValues(v.qs)
+}
+
+// check returns a list of violations of a shape by a focus node, without recording them.
+func (v *validator) check(s *Shape, focus quad.Value) ([]Result, error) {
+	if s.Deactivated {
+		return nil, nil
+	}
+	vals, err := v.values(s, focus)
+	if err != nil {
+		return nil, err
+	}
+	var out []Result
+	for _, c := range s.constraints {
+		bad, err := c.check(v, focus, vals)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bad {
+			out = append(out, Result{
+				Focus: focus, Path: s.Path, Value: b,
+				Shape: s.ID, Component: iri(c.component()),
+				Severity: s.Severity, Message: s.Message,
+			})
+		}
+	}
+	for _, ps := range s.Properties {
+		res, err := v.check(ps, focus)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res...)
+	}
+	return out, nil
+}
+
+func (v *validator) validate(s *Shape, focus quad.Value) error {
+	res, err := v.check(s, focus)
+	v.results = append(v.results, res...)
+	return err
+}
+
+// conformsTo checks if node conforms to a shape, as required by sh:node.
+func (v *validator) conformsTo(s *Shape, n quad.Value) (bool, error) {
+	key := [2]string{s.ID.String(), n.String()}
+	if ok, seen := v.conforms[key]; seen {
+		return ok, nil
+	}
+	v.conforms[key] = true
+	res, err := v.check(s, n)
+	if err != nil {
+		return false, err
+	}
+	ok := len(res) == 0
+	v.conforms[key] = ok
+	return ok, nil
+}
+
+type constraint interface {
+	component() string
+	// check returns values that violate the constraint; nil value marks that the whole set of values is invalid.
+	check(v *validator, focus quad.Value, vals []quad.Value) ([]quad.Value, error)
+}
+
+// checkEach returns values for which fn returns false.
+func checkEach(vals []quad.Value, fn func(quad.Value) bool) []quad.Value {
+	var bad []quad.Value
+	for _, val := range vals {
+		if !fn(val) {
+			bad = append(bad, val)
+		}
+	}
+	return bad
+}
+
+type minCount int64
+
+func (minCount) component() string { return sh.MinCountConstraintComponent }
+func (c minCount) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	if int64(len(vals)) < int64(c) {
+		return []quad.Value{nil}, nil
+	}
+	return nil, nil
+}
+
+type maxCount int64
+
+func (maxCount) component() string { return sh.MaxCountConstraintComponent }
+func (c maxCount) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	if int64(len(vals)) > int64(c) {
+		return []quad.Value{nil}, nil
+	}
+	return nil, nil
+}
+
+const xsdString = quad.IRI(`http://www.w3.org/2001/XMLSchema#string`)
+
+// datatypeOf returns a full IRI of the datatype of a literal.
+func datatypeOf(v quad.Value) (quad.IRI, bool) {
+	switch v := v.(type) {
+	case quad.String:
+		return xsdString, true
+	case quad.LangString:
+		return iri(rdf.LangString), true
+	case quad.TypedString:
+		return v.Type.Full(), true
+	case quad.TypedStringer:
+		return v.TypedString().Type.Full(), true
+	}
+	return "", false
+}
+
+type datatype struct{ typ quad.Value }
+
+func (datatype) component() string { return sh.DatatypeConstraintComponent }
+func (c datatype) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	typ, ok := c.typ.(quad.IRI)
+	if !ok {
+		return nil, fmt.Errorf("shacl: datatype is not an IRI: %v", c.typ)
+	}
+	typ = typ.Full()
+	return checkEach(vals, func(val quad.Value) bool {
+		dt, ok := datatypeOf(val)
+		if !ok {
+			return false
+		} else if dt == typ {
+			return true
+		}
+		// native values are stored with a default type, but may have been written with an equivalent one
+		ts, ok := val.(quad.TypedStringer)
+		if _, isTyped := val.(quad.TypedString); !ok || isTyped {
+			return false
+		}
+		pv, err := quad.TypedString{Value: ts.TypedString().Value, Type: typ}.ParseValue()
+		return err == nil && reflect.TypeOf(pv) == reflect.TypeOf(val)
+	}), nil
+}
+
+type class struct{ class quad.Value }
+
+func (class) component() string { return sh.ClassConstraintComponent }
+func (c class) check(v *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	var bad []quad.Value
+	for _, val := range vals {
+		ok, err := v.exists(path.StartPath(v.qs, val).Out(iri(rdf.Type)).And(v.classes(c.class)))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			bad = append(bad, val)
+		}
+	}
+	return bad, nil
+}
+
+type nodeKind quad.IRI
+
+func (nodeKind) component() string { return sh.NodeKindConstraintComponent }
+func (c nodeKind) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	var kinds []string
+	switch quad.IRI(c).Full() {
+	case iri(sh.IRI):
+		kinds = []string{sh.IRI}
+	case iri(sh.BlankNode):
+		kinds = []string{sh.BlankNode}
+	case iri(sh.Literal):
+		kinds = []string{sh.Literal}
+	case iri(sh.BlankNodeOrIRI):
+		kinds = []string{sh.BlankNode, sh.IRI}
+	case iri(sh.BlankNodeOrLiteral):
+		kinds = []string{sh.BlankNode, sh.Literal}
+	case iri(sh.IRIOrLiteral):
+		kinds = []string{sh.IRI, sh.Literal}
+	default:
+		return nil, fmt.Errorf("shacl: unknown node kind: %v", quad.IRI(c))
+	}
+	return checkEach(vals, func(val quad.Value) bool {
+		kind := sh.Literal
+		switch val.(type) {
+		case quad.IRI:
+			kind = sh.IRI
+		case quad.BNode:
+			kind = sh.BlankNode
+		}
+		for _, k := range kinds {
+			if k == kind {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+type in []quad.Value
+
+func (in) component() string { return sh.InConstraintComponent }
+func (c in) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	return checkEach(vals, func(val quad.Value) bool {
+		for _, a := range c {
+			if a == val {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+type hasValue struct{ val quad.Value }
+
+func (hasValue) component() string { return sh.HasValueConstraintComponent }
+func (c hasValue) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	for _, val := range vals {
+		if val == c.val {
+			return nil, nil
+		}
+	}
+	return []quad.Value{nil}, nil
+}
+
+// lexical returns a string form of a value that is checked by string constraints.
+func lexical(v quad.Value) (string, bool) {
+	switch v := v.(type) {
+	case quad.BNode:
+		return "", false
+	case quad.IRI:
+		return string(v), true
+	case quad.TypedStringer:
+		return string(v.TypedString().Value), true
+	}
+	return quad.ToString(v), true
+}
+
+type pattern struct{ re *regexp.Regexp }
+
+func (pattern) component() string { return sh.PatternConstraintComponent }
+func (c pattern) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	return checkEach(vals, func(val quad.Value) bool {
+		s, ok := lexical(val)
+		return ok && c.re.MatchString(s)
+	}), nil
+}
+
+type minLength int64
+
+func (minLength) component() string { return sh.MinLengthConstraintComponent }
+func (c minLength) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	return checkEach(vals, func(val quad.Value) bool {
+		s, ok := lexical(val)
+		return ok && int64(utf8.RuneCountInString(s)) >= int64(c)
+	}), nil
+}
+
+type maxLength int64
+
+func (maxLength) component() string { return sh.MaxLengthConstraintComponent }
+func (c maxLength) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	return checkEach(vals, func(val quad.Value) bool {
+		s, ok := lexical(val)
+		return ok && int64(utf8.RuneCountInString(s)) <= int64(c)
+	}), nil
+}
+
+// native converts literals to numbers, times and strings, so they can be compared.
+func native(v quad.Value) interface{} {
+	if ts, ok := v.(quad.TypedString); ok {
+		if pv, err := ts.ParseValue(); err == nil {
+			v = pv
+		}
+	}
+	switch v := v.(type) {
+	case quad.Int:
+		return float64(v)
+	case quad.Float:
+		return float64(v)
+	case quad.Time:
+		return time.Time(v)
+	case quad.String:
+		return string(v)
+	}
+	return nil
+}
+
+// compare returns the order of two literals, or false if they are not comparable.
+func compare(a, b quad.Value) (int, bool) {
+	switch a := native(a).(type) {
+	case float64:
+		b, ok := native(b).(float64)
+		switch {
+		case !ok:
+			return 0, false
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		b, ok := native(b).(time.Time)
+		switch {
+		case !ok:
+			return 0, false
+		case a.Before(b):
+			return -1, true
+		case a.After(b):
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := native(b).(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+type valueRange struct {
+	bound quad.Value
+	ok    func(c int) bool
+	comp  string
+}
+
+func (c valueRange) component() string { return c.comp }
+func (c valueRange) check(_ *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	return checkEach(vals, func(val quad.Value) bool {
+		r, ok := compare(val, c.bound)
+		return ok && c.ok(r)
+	}), nil
+}
+
+type node struct{ shape *Shape }
+
+func (node) component() string { return sh.NodeConstraintComponent }
+func (c node) check(v *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	var bad []quad.Value
+	for _, val := range vals {
+		ok, err := v.conformsTo(c.shape, val)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			bad = append(bad, val)
+		}
+	}
+	return bad, nil
+}
+
+type closed struct {
+	shape   *Shape
+	allowed map[string]bool
+}
+
+func (closed) component() string { return sh.ClosedConstraintComponent }
+func (c closed) check(v *validator, _ quad.Value, vals []quad.Value) ([]quad.Value, error) {
+	allowed := make(map[string]bool, len(c.allowed)+len(c.shape.Properties))
+	for k := range c.allowed {
+		allowed[k] = true
+	}
+	for _, p := range c.shape.Properties {
+		if !p.Path.Inverse {
+			allowed[p.Path.Pred.String()] = true
+		}
+	}
+	var bad []quad.Value
+	for _, val := range vals {
+		ref := v.qs.ValueOf(val)
+		if ref == nil {
+			continue
+		}
+		it := v.qs.QuadIterator(quad.Subject, ref)
+		for it.Next(v.ctx) {
+			q := v.qs.Quad(it.Result())
+			if !allowed[q.Predicate.String()] {
+				bad = append(bad, q.Object)
+			}
+		}
+		err := it.Err()
+		it.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bad, nil
+}
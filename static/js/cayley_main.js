@@ -15,7 +15,7 @@
 $(function() {
   s = null;
   group = null;
-  Snap.load("/static/cayley.svg", function(d, err) {
+  Snap.load(basePath + "/static/cayley.svg", function(d, err) {
     //Snap(105,65).append(d);
     s = Snap("#logo").append(d);
     svg = Snap("svg");
@@ -25,7 +25,7 @@ $(function() {
     var data = editor.getValue()
     $("#output").text(editor.getValue())
     animate();
-    $.post("/api/v1/query/" + selectedQueryLanguage, data)
+    $.post(basePath + "/api/v1/query/" + selectedQueryLanguage, data)
       .done(function(return_data) {
         if (typeof(Storage) !== "undefined") {
           localStorage.setItem("cayleySavedQueries" + selectedQueryLanguage, data)
@@ -17,7 +17,7 @@ $(function() {
 
   $("#run_button").click(function() {
     var data = editor.getValue()
-    $.post("/api/v1/shape/" + selectedQueryLanguage, data)
+    $.post(basePath + "/api/v1/shape/" + selectedQueryLanguage, data)
       .done(function(return_data) {
         if (typeof(Storage) !== "undefined") {
           localStorage.setItem("cayleySavedQueries" + selectedQueryLanguage, data)
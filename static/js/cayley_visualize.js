@@ -103,7 +103,7 @@ $(function() {
       return;
     }
     animate();
-    $.post("/api/v1/query/" + selectedQueryLanguage, data)
+    $.post(basePath + "/api/v1/query/" + selectedQueryLanguage, data)
       .done(function(return_data) {
         stopAndReset();
         if (typeof(Storage) !== "undefined") {
@@ -53,7 +53,7 @@ $(function() {
     if (!checkQuad(quad)) {
       return
     }
-    $.post("/api/v1/write", JSON.stringify([quad]))
+    $.post(basePath + "/api/v1/write", JSON.stringify([quad]))
       .done(function(return_data){
         alertSucceed("Wrote a quad!")
       })
@@ -73,7 +73,7 @@ $(function() {
     if (!checkQuad(quad)) {
       return
     }
-    $.post("/api/v1/delete", JSON.stringify([quad]))
+    $.post(basePath + "/api/v1/delete", JSON.stringify([quad]))
       .done(function(return_data){
         alertSucceed("Deleted a quad!")
       })
@@ -144,7 +144,7 @@ $(function() {
       xhr.addEventListener("load", uploadComplete, false);
       xhr.addEventListener("error", uploadFailed, false);
       xhr.addEventListener("abort", uploadCanceled, false);
-      xhr.open("POST", "/api/v1/write/file/nquad");
+      xhr.open("POST", basePath + "/api/v1/write/file/nquad");
       xhr.send(fd);
 
     } catch(err) {
@@ -2,7 +2,9 @@
 package core
 
 import (
+	_ "github.com/cayleygraph/cayley/voc/owl"
 	_ "github.com/cayleygraph/cayley/voc/rdf"
 	_ "github.com/cayleygraph/cayley/voc/rdfs"
 	_ "github.com/cayleygraph/cayley/voc/schema"
+	_ "github.com/cayleygraph/cayley/voc/sh"
 )
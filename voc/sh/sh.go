@@ -0,0 +1,159 @@
+// Package sh contains constants of the Shapes Constraint Language (SHACL)
+package sh
+
+import "github.com/cayleygraph/cayley/voc"
+
+func init() {
+	voc.RegisterPrefix(Prefix, NS)
+}
+
+const (
+	NS     = `http://www.w3.org/ns/shacl#`
+	Prefix = `sh:`
+)
+
+const (
+	// Classes
+
+	// A node shape is a shape that specifies constraint that need to be met by a focus node.
+	NodeShape = Prefix + `NodeShape`
+	// A property shape is a shape that specifies constraints on the values of a focus node for a given property or path.
+	PropertyShape = Prefix + `PropertyShape`
+	// The class of SHACL validation reports.
+	ValidationReport = Prefix + `ValidationReport`
+	// The class of validation results.
+	ValidationResult = Prefix + `ValidationResult`
+
+	// Node kinds
+
+	// The node kind of all IRIs.
+	IRI = Prefix + `IRI`
+	// The node kind of all blank nodes.
+	BlankNode = Prefix + `BlankNode`
+	// The node kind of all literals.
+	Literal = Prefix + `Literal`
+	// The node kind of all blank nodes or IRIs.
+	BlankNodeOrIRI = Prefix + `BlankNodeOrIRI`
+	// The node kind of all blank nodes or literals.
+	BlankNodeOrLiteral = Prefix + `BlankNodeOrLiteral`
+	// The node kind of all IRIs or literals.
+	IRIOrLiteral = Prefix + `IRIOrLiteral`
+
+	// Severities
+
+	// The severity for an informational validation result.
+	Info = Prefix + `Info`
+	// The severity for a violation validation result.
+	Violation = Prefix + `Violation`
+	// The severity for a warning validation result.
+	Warning = Prefix + `Warning`
+
+	// Targets
+
+	// Links a shape to a class, indicating that all instances of the class must conform to the shape.
+	TargetClass = Prefix + `targetClass`
+	// Links a shape to individual nodes, indicating that these nodes must conform to the shape.
+	TargetNode = Prefix + `targetNode`
+	// Links a shape to a property, indicating that all subjects of triples with the property must conform to the shape.
+	TargetSubjectsOf = Prefix + `targetSubjectsOf`
+	// Links a shape to a property, indicating that all objects of triples with the property must conform to the shape.
+	TargetObjectsOf = Prefix + `targetObjectsOf`
+
+	// Shape properties
+
+	// Links a shape to its property shapes.
+	Property = Prefix + `property`
+	// Specifies the property path of a property shape.
+	Path = Prefix + `path`
+	// The (single) value of this property represents an inverse path (object to subject).
+	InversePath = Prefix + `inversePath`
+	// If set to true then all nodes conform to this.
+	Deactivated = Prefix + `deactivated`
+	// Human-readable messages for the explanation of the validation result.
+	Message = Prefix + `message`
+	// Defines the severity that validation results produced by a shape must have.
+	Severity = Prefix + `severity`
+	// Human-readable labels for the property in the context of the surrounding shape.
+	Name = Prefix + `name`
+	// Human-readable descriptions for the property in the context of the surrounding shape.
+	Description = Prefix + `description`
+
+	// Constraint parameters
+
+	// The minimum cardinality. Node shapes cannot have any value other than 0.
+	MinCount = Prefix + `minCount`
+	// The maximum cardinality. Node shapes cannot have any value other than 1.
+	MaxCount = Prefix + `maxCount`
+	// Specifies an RDF datatype that all value nodes must have.
+	Datatype = Prefix + `datatype`
+	// The type that all value nodes must have.
+	Class = Prefix + `class`
+	// Specifies the node kind (e.g. IRI or literal) each value node.
+	NodeKind = Prefix + `nodeKind`
+	// Specifies a list of allowed values so that each value node must be among the members of the given list.
+	In = Prefix + `in`
+	// Specifies a value that must be among the value nodes.
+	HasValue = Prefix + `hasValue`
+	// Specifies a regular expression pattern that the string representations of the value nodes must match.
+	Pattern = Prefix + `pattern`
+	// An optional flag to be used with regular expression pattern matching.
+	Flags = Prefix + `flags`
+	// Specifies the minimum string length of each value node that satisfies the condition.
+	MinLength = Prefix + `minLength`
+	// Specifies the maximum string length of each value node that satisfies the condition.
+	MaxLength = Prefix + `maxLength`
+	// The minimum inclusive value of each value node.
+	MinInclusive = Prefix + `minInclusive`
+	// The maximum inclusive value of each value node.
+	MaxInclusive = Prefix + `maxInclusive`
+	// The minimum exclusive value of each value node.
+	MinExclusive = Prefix + `minExclusive`
+	// The maximum exclusive value of each value node.
+	MaxExclusive = Prefix + `maxExclusive`
+	// Specifies the node shape that all value nodes must conform to.
+	Node = Prefix + `node`
+	// If set to true then the shape is closed.
+	Closed = Prefix + `closed`
+	// An optional RDF list of properties that are also permitted in addition to those explicitly enumerated via sh:property.
+	IgnoredProperties = Prefix + `ignoredProperties`
+
+	// Constraint components
+
+	MinCountConstraintComponent     = Prefix + `MinCountConstraintComponent`
+	MaxCountConstraintComponent     = Prefix + `MaxCountConstraintComponent`
+	DatatypeConstraintComponent     = Prefix + `DatatypeConstraintComponent`
+	ClassConstraintComponent        = Prefix + `ClassConstraintComponent`
+	NodeKindConstraintComponent     = Prefix + `NodeKindConstraintComponent`
+	InConstraintComponent           = Prefix + `InConstraintComponent`
+	HasValueConstraintComponent     = Prefix + `HasValueConstraintComponent`
+	PatternConstraintComponent      = Prefix + `PatternConstraintComponent`
+	MinLengthConstraintComponent    = Prefix + `MinLengthConstraintComponent`
+	MaxLengthConstraintComponent    = Prefix + `MaxLengthConstraintComponent`
+	MinInclusiveConstraintComponent = Prefix + `MinInclusiveConstraintComponent`
+	MaxInclusiveConstraintComponent = Prefix + `MaxInclusiveConstraintComponent`
+	MinExclusiveConstraintComponent = Prefix + `MinExclusiveConstraintComponent`
+	MaxExclusiveConstraintComponent = Prefix + `MaxExclusiveConstraintComponent`
+	NodeConstraintComponent         = Prefix + `NodeConstraintComponent`
+	ClosedConstraintComponent       = Prefix + `ClosedConstraintComponent`
+
+	// Validation reports
+
+	// True if the validation did not produce any validation results, and false otherwise.
+	Conforms = Prefix + `conforms`
+	// The validation results contained in a validation report.
+	Result = Prefix + `result`
+	// The focus node that was validated when the result was produced.
+	FocusNode = Prefix + `focusNode`
+	// The path of a validation result, based on the path of the validated property shape.
+	ResultPath = Prefix + `resultPath`
+	// An RDF node that has caused the result.
+	Value = Prefix + `value`
+	// The shape that is was validated when the result was produced.
+	SourceShape = Prefix + `sourceShape`
+	// The constraint component that is the source of the result.
+	SourceConstraintComponent = Prefix + `sourceConstraintComponent`
+	// The severity of the result, e.g. warning.
+	ResultSeverity = Prefix + `resultSeverity`
+	// Human-readable messages explaining the cause of the result.
+	ResultMessage = Prefix + `resultMessage`
+)
@@ -115,5 +115,12 @@ func (s *Single) Close() error {
 }
 
 func (s *Single) ApplyTransaction(t *graph.Transaction) error {
+	if len(t.Preconditions) != 0 {
+		ca, ok := s.qs.(graph.ConditionalApplier)
+		if !ok {
+			return graph.ErrPreconditionsNotSupported
+		}
+		return ca.ApplyDeltasIf(t.Preconditions, t.Deltas, s.ignoreOpts)
+	}
 	return s.qs.ApplyDeltas(t.Deltas, s.ignoreOpts)
 }